@@ -54,6 +54,7 @@ func (as *assertsSuite) TestTypeNames(c *C) {
 		"base-declaration",
 		"device-session-request",
 		"model",
+		"refresh-policy",
 		"repair",
 		"serial",
 		"serial-request",
@@ -952,6 +953,7 @@ func (as *assertsSuite) TestWithAuthority(c *C) {
 		"validation",
 		"validation-set",
 		"repair",
+		"refresh-policy",
 	}
 	c.Check(withAuthority, HasLen, asserts.NumAssertionType-3) // excluding device-session-request, serial-request, account-key-request
 	for _, name := range withAuthority {
@@ -0,0 +1,257 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+// RefreshPolicySnap holds the details about how a snap listed by a
+// refresh-policy assertion is to be refreshed.
+type RefreshPolicySnap struct {
+	Name   string
+	SnapID string
+
+	// Channel is the channel the snap is to track, empty if
+	// the policy does not constrain it.
+	Channel string
+	// RefreshWindow is the schedule outside of which the snap is not
+	// auto-refreshed, empty if the policy does not constrain it.
+	RefreshWindow string
+	// MaxPostponement is how long refreshes of the snap can be held,
+	// zero if the policy does not constrain it.
+	MaxPostponement time.Duration
+	// AutoRevert is whether an automatic refresh of the snap is
+	// reverted when its post-refresh hook fails, defaults to true.
+	AutoRevert bool
+
+	refreshWindow []*timeutil.Schedule
+}
+
+// SnapName implements naming.SnapRef.
+func (s *RefreshPolicySnap) SnapName() string {
+	return s.Name
+}
+
+// ID implements naming.SnapRef.
+func (s *RefreshPolicySnap) ID() string {
+	return s.SnapID
+}
+
+// InRefreshWindow returns whether t is inside the refresh window of
+// the snap. It is always true if the policy does not constrain the
+// refresh window.
+func (s *RefreshPolicySnap) InRefreshWindow(t time.Time) bool {
+	if len(s.refreshWindow) == 0 {
+		return true
+	}
+	return timeutil.Includes(s.refreshWindow, t)
+}
+
+func checkRefreshPolicySnap(snap map[string]interface{}) (*RefreshPolicySnap, error) {
+	name, err := checkNotEmptyStringWhat(snap, "name", "of snap")
+	if err != nil {
+		return nil, err
+	}
+	if err := naming.ValidateSnap(name); err != nil {
+		return nil, fmt.Errorf("invalid snap name %q", name)
+	}
+
+	what := fmt.Sprintf("of snap %q", name)
+
+	snapID, err := checkStringMatchesWhat(snap, "id", what, naming.ValidSnapID)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := checkOptionalStringWhat(snap, "channel", what)
+	if err != nil {
+		return nil, err
+	}
+	if ch != "" {
+		if _, err := channel.ParseVerbatim(ch, "-"); err != nil {
+			return nil, fmt.Errorf("invalid channel %s: %s", what, ch)
+		}
+	}
+
+	window, err := checkOptionalStringWhat(snap, "refresh-window", what)
+	if err != nil {
+		return nil, err
+	}
+	var refreshWindow []*timeutil.Schedule
+	if window != "" {
+		refreshWindow, err = timeutil.ParseSchedule(window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid refresh-window %s: %v", what, err)
+		}
+	}
+
+	postponement, err := checkOptionalStringWhat(snap, "max-postponement", what)
+	if err != nil {
+		return nil, err
+	}
+	var maxPostponement time.Duration
+	if postponement != "" {
+		maxPostponement, err = time.ParseDuration(postponement)
+		if err != nil || maxPostponement <= 0 {
+			return nil, fmt.Errorf("max-postponement %s must be a positive duration: %s", what, postponement)
+		}
+	}
+
+	autoRevert := true
+	if v, ok := snap["auto-revert"]; ok {
+		s, ok := v.(string)
+		if !ok || (s != "true" && s != "false") {
+			return nil, fmt.Errorf(`"auto-revert" %s must be 'true' or 'false'`, what)
+		}
+		autoRevert = s == "true"
+	}
+
+	return &RefreshPolicySnap{
+		Name:            name,
+		SnapID:          snapID,
+		Channel:         ch,
+		RefreshWindow:   window,
+		MaxPostponement: maxPostponement,
+		AutoRevert:      autoRevert,
+		refreshWindow:   refreshWindow,
+	}, nil
+}
+
+func checkRefreshPolicySnaps(snapList interface{}) ([]*RefreshPolicySnap, error) {
+	const wrongHeaderType = `"snaps" header must be a list of maps`
+
+	entries, ok := snapList.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf(wrongHeaderType)
+	}
+
+	seen := make(map[string]bool, len(entries))
+	seenIDs := make(map[string]string, len(entries))
+	snaps := make([]*RefreshPolicySnap, 0, len(entries))
+	for _, entry := range entries {
+		snap, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf(wrongHeaderType)
+		}
+		policySnap, err := checkRefreshPolicySnap(snap)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[policySnap.Name] {
+			return nil, fmt.Errorf("cannot list the same snap %q multiple times", policySnap.Name)
+		}
+		seen[policySnap.Name] = true
+		snapID := policySnap.SnapID
+		if underName := seenIDs[snapID]; underName != "" {
+			return nil, fmt.Errorf("cannot specify the same snap id %q multiple times, specified for snaps %q and %q", snapID, underName, policySnap.Name)
+		}
+		seenIDs[snapID] = policySnap.Name
+
+		snaps = append(snaps, policySnap)
+	}
+
+	return snaps, nil
+}
+
+// RefreshPolicy holds a refresh-policy assertion, which is a
+// statement by a brand about how the snaps on the devices of one
+// of its models are to be refreshed.
+type RefreshPolicy struct {
+	assertionBase
+
+	snaps []*RefreshPolicySnap
+
+	timestamp time.Time
+}
+
+// Series returns the series of the model the policy applies to.
+func (rp *RefreshPolicy) Series() string {
+	return rp.HeaderString("series")
+}
+
+// BrandID returns the brand identifier of the model the policy applies to.
+func (rp *RefreshPolicy) BrandID() string {
+	return rp.HeaderString("brand-id")
+}
+
+// Model returns the name of the model the policy applies to.
+func (rp *RefreshPolicy) Model() string {
+	return rp.HeaderString("model")
+}
+
+// Snaps returns the snaps whose refreshes are constrained by the policy.
+func (rp *RefreshPolicy) Snaps() []*RefreshPolicySnap {
+	return rp.snaps
+}
+
+// Snap returns the policy for the snap with the given name, or nil if
+// the policy does not list it.
+func (rp *RefreshPolicy) Snap(name string) *RefreshPolicySnap {
+	for _, sn := range rp.snaps {
+		if sn.Name == name {
+			return sn
+		}
+	}
+	return nil
+}
+
+// Timestamp returns the time when the refresh-policy was issued.
+func (rp *RefreshPolicy) Timestamp() time.Time {
+	return rp.timestamp
+}
+
+func assembleRefreshPolicy(assert assertionBase) (Assertion, error) {
+	err := checkAuthorityMatchesBrand(&assert)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = checkModel(assert.headers)
+	if err != nil {
+		return nil, err
+	}
+
+	snapList, ok := assert.headers["snaps"]
+	if !ok {
+		return nil, fmt.Errorf(`"snaps" header is mandatory`)
+	}
+	snaps, err := checkRefreshPolicySnaps(snapList)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &RefreshPolicy{
+		assertionBase: assert,
+		snaps:         snaps,
+		timestamp:     timestamp,
+	}, nil
+}
@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+)
+
+type refreshPolicySuite struct {
+	ts     time.Time
+	tsLine string
+}
+
+var _ = Suite(&refreshPolicySuite{})
+
+func (rps *refreshPolicySuite) SetUpSuite(c *C) {
+	rps.ts = time.Now().Truncate(time.Second).UTC()
+	rps.tsLine = "timestamp: " + rps.ts.Format(time.RFC3339) + "\n"
+}
+
+const (
+	refreshPolicyExample = `type: refresh-policy
+authority-id: brand-id1
+series: 16
+brand-id: brand-id1
+model: baz-3000
+snaps:
+  -
+    name: baz-linux
+    id: bazlinuxidididididididididididid
+    channel: 20/stable
+    refresh-window: mon-fri,02:00-04:00
+    max-postponement: 240h
+    auto-revert: false
+  -
+    name: baz-app
+    id: bazappididididididididididididid
+OTHER` + "TSLINE" +
+		"body-length: 0\n" +
+		"sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij" +
+		"\n\n" +
+		"AXNpZw=="
+)
+
+func (rps *refreshPolicySuite) TestDecodeOK(c *C) {
+	encoded := strings.Replace(refreshPolicyExample, "TSLINE", rps.tsLine, 1)
+	encoded = strings.Replace(encoded, "OTHER", "", 1)
+
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.RefreshPolicyType)
+	policy := a.(*asserts.RefreshPolicy)
+	c.Check(policy.AuthorityID(), Equals, "brand-id1")
+	c.Check(policy.Timestamp(), Equals, rps.ts)
+	c.Check(policy.Series(), Equals, "16")
+	c.Check(policy.BrandID(), Equals, "brand-id1")
+	c.Check(policy.Model(), Equals, "baz-3000")
+	snaps := policy.Snaps()
+	c.Assert(snaps, HasLen, 2)
+
+	c.Check(snaps[0].Name, Equals, "baz-linux")
+	c.Check(snaps[0].SnapID, Equals, "bazlinuxidididididididididididid")
+	c.Check(snaps[0].Channel, Equals, "20/stable")
+	c.Check(snaps[0].RefreshWindow, Equals, "mon-fri,02:00-04:00")
+	c.Check(snaps[0].MaxPostponement, Equals, 10*24*time.Hour)
+	c.Check(snaps[0].AutoRevert, Equals, false)
+	c.Check(snaps[0].SnapName(), Equals, "baz-linux")
+	c.Check(snaps[0].ID(), Equals, "bazlinuxidididididididididididid")
+
+	// unconstrained snap
+	c.Check(snaps[1].Name, Equals, "baz-app")
+	c.Check(snaps[1].Channel, Equals, "")
+	c.Check(snaps[1].RefreshWindow, Equals, "")
+	c.Check(snaps[1].MaxPostponement, Equals, time.Duration(0))
+	c.Check(snaps[1].AutoRevert, Equals, true)
+
+	c.Check(policy.Snap("baz-app"), Equals, snaps[1])
+	c.Check(policy.Snap("other"), IsNil)
+}
+
+func (rps *refreshPolicySuite) TestInRefreshWindow(c *C) {
+	encoded := strings.Replace(refreshPolicyExample, "TSLINE", rps.tsLine, 1)
+	encoded = strings.Replace(encoded, "OTHER", "", 1)
+
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	policy := a.(*asserts.RefreshPolicy)
+
+	// a Monday
+	mon := time.Date(2021, time.June, 7, 3, 0, 0, 0, time.Local)
+	c.Check(policy.Snap("baz-linux").InRefreshWindow(mon), Equals, true)
+	c.Check(policy.Snap("baz-linux").InRefreshWindow(mon.Add(2*time.Hour)), Equals, false)
+	// a Sunday
+	sun := mon.AddDate(0, 0, -1)
+	c.Check(policy.Snap("baz-linux").InRefreshWindow(sun), Equals, false)
+
+	// no refresh window means always
+	c.Check(policy.Snap("baz-app").InRefreshWindow(sun), Equals, true)
+}
+
+func (rps *refreshPolicySuite) TestDecodeInvalid(c *C) {
+	const refreshPolicyErrPrefix = "assertion refresh-policy: "
+
+	encoded := strings.Replace(refreshPolicyExample, "TSLINE", rps.tsLine, 1)
+
+	snapsStanza := encoded[strings.Index(encoded, "snaps:"):strings.Index(encoded, "timestamp:")]
+
+	invalidTests := []struct{ original, invalid, expectedErr string }{
+		{"series: 16\n", "", `"series" header is mandatory`},
+		{"brand-id: brand-id1\n", "", `"brand-id" header is mandatory`},
+		{"brand-id: brand-id1\n", "brand-id: random\n", `authority-id and brand-id must match, refresh-policy assertions are expected to be signed by the brand: "brand-id1" != "random"`},
+		{"model: baz-3000\n", "", `"model" header is mandatory`},
+		{"model: baz-3000\n", "model: baz+3000\n", `"model" header contains invalid characters: "baz\+3000"`},
+		{"model: baz-3000\n", "model: Baz-3000\n", `"model" header cannot contain uppercase letters`},
+		{rps.tsLine, "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
+		{snapsStanza, "", `"snaps" header is mandatory`},
+		{snapsStanza, "snaps: snap\n", `"snaps" header must be a list of maps`},
+		{snapsStanza, "snaps:\n  - snap\n", `"snaps" header must be a list of maps`},
+		{"name: baz-linux\n", "other: 1\n", `"name" of snap is mandatory`},
+		{"name: baz-linux\n", "name: linux_2\n", `invalid snap name "linux_2"`},
+		{"id: bazlinuxidididididididididididid\n", "id: 2\n", `"id" of snap "baz-linux" contains invalid characters: "2"`},
+		{"OTHER", "  -\n    name: baz-linux\n    id: bazlinux2idididididididididididi\n", `cannot list the same snap "baz-linux" multiple times`},
+		{"OTHER", "  -\n    name: baz-linux2\n    id: bazlinuxidididididididididididid\n", `cannot specify the same snap id "bazlinuxidididididididididididid" multiple times, specified for snaps "baz-linux" and "baz-linux2"`},
+		{"channel: 20/stable\n", "channel:\n      - 20\n", `"channel" of snap "baz-linux" must be a string`},
+		{"channel: 20/stable\n", "channel: 20/stable/foo/bar\n", `invalid channel of snap "baz-linux": 20/stable/foo/bar`},
+		{"refresh-window: mon-fri,02:00-04:00\n", "refresh-window: foo\n", `invalid refresh-window of snap "baz-linux": .*`},
+		{"max-postponement: 240h\n", "max-postponement: 10d\n", `max-postponement of snap "baz-linux" must be a positive duration: 10d`},
+		{"max-postponement: 240h\n", "max-postponement: -1h\n", `max-postponement of snap "baz-linux" must be a positive duration: -1h`},
+		{"auto-revert: false\n", "auto-revert: no\n", `"auto-revert" of snap "baz-linux" must be 'true' or 'false'`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := strings.Replace(encoded, test.original, test.invalid, 1)
+		invalid = strings.Replace(invalid, "OTHER", "", 1)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, refreshPolicyErrPrefix+test.expectedErr)
+	}
+}
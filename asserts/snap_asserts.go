@@ -43,6 +43,7 @@ type SnapDeclaration struct {
 	slotRules      map[string]*SlotRule
 	autoAliases    []string
 	aliases        map[string]string
+	runAs          []string
 	timestamp      time.Time
 }
 
@@ -97,6 +98,12 @@ func (snapdcl *SnapDeclaration) Aliases() map[string]string {
 	return snapdcl.aliases
 }
 
+// RunAs returns the system usernames the services of this snap are
+// allowed to run as.
+func (snapdcl *SnapDeclaration) RunAs() []string {
+	return snapdcl.runAs
+}
+
 // Implement further consistency checks.
 func (snapdcl *SnapDeclaration) checkConsistency(db RODatabase, acck *AccountKey) error {
 	if !db.IsTrustedAccount(snapdcl.AuthorityID()) {
@@ -308,6 +315,16 @@ func assembleSnapDeclaration(assert assertionBase) (Assertion, error) {
 		return nil, err
 	}
 
+	runAs, err := checkStringList(assert.headers, "run-as")
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range runAs {
+		if !osutil.IsValidUsername(user) {
+			return nil, fmt.Errorf("\"run-as\" header contains an invalid system username: %q", user)
+		}
+	}
+
 	return &SnapDeclaration{
 		assertionBase:  assert,
 		refreshControl: refControl,
@@ -315,6 +332,7 @@ func assembleSnapDeclaration(assert assertionBase) (Assertion, error) {
 		slotRules:      slotRules,
 		autoAliases:    autoAliases,
 		aliases:        aliases,
+		runAs:          runAs,
 		timestamp:      timestamp,
 	}, nil
 }
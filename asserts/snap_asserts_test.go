@@ -69,6 +69,7 @@ func (sds *snapDeclSuite) TestDecodeOK(c *C) {
 		"publisher-id: dev-id1\n" +
 		"refresh-control:\n  - foo\n  - bar\n" +
 		"auto-aliases:\n  - cmd1\n  - cmd_2\n  - Cmd-3\n  - CMD.4\n" +
+		"run-as:\n  - snap_daemon\n" +
 		sds.tsLine +
 		`aliases:
   -
@@ -106,6 +107,7 @@ func (sds *snapDeclSuite) TestDecodeOK(c *C) {
 		"Cmd-3": "cmd-3",
 		"CMD.4": "cmd-4",
 	})
+	c.Check(snapDecl.RunAs(), DeepEquals, []string{"snap_daemon"})
 }
 
 func (sds *snapDeclSuite) TestEmptySnapName(c *C) {
@@ -143,6 +145,7 @@ func (sds *snapDeclSuite) TestMissingRefreshControlAutoAliases(c *C) {
 	snapDecl := a.(*asserts.SnapDeclaration)
 	c.Check(snapDecl.RefreshControl(), HasLen, 0)
 	c.Check(snapDecl.AutoAliases(), HasLen, 0)
+	c.Check(snapDecl.RunAs(), HasLen, 0)
 }
 
 const (
@@ -164,6 +167,7 @@ func (sds *snapDeclSuite) TestDecodeInvalid(c *C) {
 		"refresh-control:\n  - foo\n  - bar\n" +
 		"auto-aliases:\n  - cmd1\n  - cmd2\n" +
 		aliases +
+		"run-as:\n  - snap_daemon\n" +
 		"plugs:\n  interface1: true\n" +
 		"slots:\n  interface2: true\n" +
 		sds.tsLine +
@@ -194,6 +198,8 @@ func (sds *snapDeclSuite) TestDecodeInvalid(c *C) {
 		{"name: cmd_1\n", "name: .cmd1\n", `"name" in "aliases" item 1 contains invalid characters: ".cmd1"`},
 		{"target: cmd-1\n", "target: -cmd-1\n", `"target" for alias "cmd_1" contains invalid characters: "-cmd-1"`},
 		{aliases, aliases + "  -\n    name: cmd_1\n    target: foo\n", `duplicated definition in "aliases" for alias "cmd_1"`},
+		{"run-as:\n  - snap_daemon\n", "run-as: snap_daemon\n", `"run-as" header must be a list of strings`},
+		{"run-as:\n  - snap_daemon\n", "run-as:\n  - Snap Daemon\n", `"run-as" header contains an invalid system username: "Snap Daemon"`},
 		{sds.tsLine, "", `"timestamp" header is mandatory`},
 		{sds.tsLine, "timestamp: \n", `"timestamp" header should not be empty`},
 		{sds.tsLine, "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
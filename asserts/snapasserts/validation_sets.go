@@ -358,6 +358,31 @@ func (v *ValidationSets) Conflict() error {
 	return nil
 }
 
+// Revisions returns the set of snap revisions that is enforced by the
+// validation sets that ValidationSets manages, mapping snap names to
+// revisions. Snaps that are not constrained to a specific revision or
+// that are invalid are not included.
+// It errors if the validation sets are in conflict.
+func (v *ValidationSets) Revisions() (map[string]snap.Revision, error) {
+	if err := v.Conflict(); err != nil {
+		return nil, err
+	}
+
+	snapNameToRevision := make(map[string]snap.Revision, len(v.snaps))
+	for _, cstrs := range v.snaps {
+		if cstrs.presence == asserts.PresenceInvalid {
+			continue
+		}
+		for rev := range cstrs.revisions {
+			if rev == unspecifiedRevision || rev == invalidPresRevision {
+				continue
+			}
+			snapNameToRevision[cstrs.name] = rev
+		}
+	}
+	return snapNameToRevision, nil
+}
+
 // CheckInstalledSnaps checks installed snaps against the validation sets.
 func (v *ValidationSets) CheckInstalledSnaps(snaps []*InstalledSnap) error {
 	installed := naming.NewSnapSet(nil)
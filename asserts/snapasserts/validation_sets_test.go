@@ -652,3 +652,108 @@ func (s *validationSetsSuite) TestSortByRevision(c *C) {
 	sort.Sort(snapasserts.ByRevision(revs))
 	c.Assert(revs, DeepEquals, []snap.Revision{snap.R(-1), snap.R(4), snap.R(5), snap.R(10)})
 }
+
+func (s *validationSetsSuite) TestRevisions(c *C) {
+	valset1 := assertstest.FakeAssertion(map[string]interface{}{
+		"type":         "validation-set",
+		"authority-id": "acme",
+		"series":       "16",
+		"account-id":   "acme",
+		"name":         "one",
+		"sequence":     "1",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":     "snap-a",
+				"id":       "mysnapaaaaaaaaaaaaaaaaaaaaaaaaaa",
+				"presence": "required",
+				"revision": "7",
+			},
+			map[string]interface{}{
+				"name":     "snap-b",
+				"id":       "mysnapbbbbbbbbbbbbbbbbbbbbbbbbbb",
+				"presence": "required",
+			},
+			map[string]interface{}{
+				"name":     "snap-c",
+				"id":       "mysnapcccccccccccccccccccccccccc",
+				"presence": "invalid",
+			},
+		},
+	}).(*asserts.ValidationSet)
+
+	valset2 := assertstest.FakeAssertion(map[string]interface{}{
+		"type":         "validation-set",
+		"authority-id": "acme",
+		"series":       "16",
+		"account-id":   "acme",
+		"name":         "two",
+		"sequence":     "1",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":     "snap-a",
+				"id":       "mysnapaaaaaaaaaaaaaaaaaaaaaaaaaa",
+				"presence": "optional",
+			},
+			map[string]interface{}{
+				"name":     "snap-d",
+				"id":       "mysnapdddddddddddddddddddddddddd",
+				"presence": "optional",
+				"revision": "3",
+			},
+		},
+	}).(*asserts.ValidationSet)
+
+	valsets := snapasserts.NewValidationSets()
+	c.Assert(valsets.Add(valset1), IsNil)
+	c.Assert(valsets.Add(valset2), IsNil)
+
+	revs, err := valsets.Revisions()
+	c.Assert(err, IsNil)
+	c.Check(revs, DeepEquals, map[string]snap.Revision{
+		"snap-a": snap.R(7),
+		"snap-d": snap.R(3),
+	})
+}
+
+func (s *validationSetsSuite) TestRevisionsConflict(c *C) {
+	valset1 := assertstest.FakeAssertion(map[string]interface{}{
+		"type":         "validation-set",
+		"authority-id": "acme",
+		"series":       "16",
+		"account-id":   "acme",
+		"name":         "one",
+		"sequence":     "1",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":     "snap-a",
+				"id":       "mysnapaaaaaaaaaaaaaaaaaaaaaaaaaa",
+				"presence": "required",
+				"revision": "7",
+			},
+		},
+	}).(*asserts.ValidationSet)
+
+	valset2 := assertstest.FakeAssertion(map[string]interface{}{
+		"type":         "validation-set",
+		"authority-id": "acme",
+		"series":       "16",
+		"account-id":   "acme",
+		"name":         "two",
+		"sequence":     "1",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":     "snap-a",
+				"id":       "mysnapaaaaaaaaaaaaaaaaaaaaaaaaaa",
+				"presence": "required",
+				"revision": "8",
+			},
+		},
+	}).(*asserts.ValidationSet)
+
+	valsets := snapasserts.NewValidationSets()
+	c.Assert(valsets.Add(valset1), IsNil)
+	c.Assert(valsets.Add(valset2), IsNil)
+
+	_, err := valsets.Revisions()
+	c.Check(err, ErrorMatches, `validation sets are in conflict:\n- cannot constrain snap "snap-a" at different revisions 7 \(acme/one\), 8 \(acme/two\)`)
+}
@@ -22,6 +22,7 @@ package boot
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/bootloader"
@@ -41,10 +42,15 @@ const (
 	// ModeRecover is a mode in which the device boots into the recovery
 	// system.
 	ModeRecover = "recover"
+	// ModeFactoryReset is a mode in which the device performs a factory
+	// reset, that is the ubuntu-data partition is recreated from the
+	// recovery system, while ubuntu-save and with it the device identity
+	// are preserved.
+	ModeFactoryReset = "factory-reset"
 )
 
 var (
-	validModes = []string{ModeInstall, ModeRecover, ModeRun}
+	validModes = []string{ModeInstall, ModeRecover, ModeFactoryReset, ModeRun}
 )
 
 // ModeAndRecoverySystemFromKernelCommandLine returns the current system mode
@@ -76,6 +82,8 @@ func ModeAndRecoverySystemFromKernelCommandLine() (mode, sysLabel string, err er
 		return "", "", fmt.Errorf("cannot specify system label without a mode")
 	case mode == ModeInstall && sysLabel == "":
 		return "", "", fmt.Errorf("cannot specify install mode without system label")
+	case mode == ModeFactoryReset && sysLabel == "":
+		return "", "", fmt.Errorf("cannot specify factory-reset mode without system label")
 	case mode == ModeRun && sysLabel != "":
 		// XXX: should we silently ignore the label? at least log for now
 		logger.Noticef(`ignoring recovery system label %q in "run" mode`, sysLabel)
@@ -100,41 +108,45 @@ func getBootloaderManagingItsAssets(where string, opts *bootloader.Options) (boo
 }
 
 // bootVarsForTrustedCommandLineFromGadget returns a set of boot variables that
-// carry the command line arguments requested by the gadget. This is only useful
-// if snapd is managing the boot config.
-func bootVarsForTrustedCommandLineFromGadget(gadgetDirOrSnapPath string) (map[string]string, error) {
+// carry the command line arguments requested by the gadget, followed by the
+// arguments appended through system configuration. This is only useful if
+// snapd is managing the boot config.
+func bootVarsForTrustedCommandLineFromGadget(gadgetDirOrSnapPath, cmdlineAppend string) (map[string]string, error) {
 	extraOrFull, full, err := gadget.KernelCommandLineFromGadget(gadgetDirOrSnapPath)
-	if err != nil {
-		if err == gadget.ErrNoKernelCommandline {
-			// nothing set by the gadget, but we could have had
-			// arguments before, so make sure those are cleared now
-			clear := map[string]string{
-				"snapd_extra_cmdline_args": "",
-				"snapd_full_cmdline_args":  "",
-			}
-			return clear, nil
-		}
+	if err != nil && err != gadget.ErrNoKernelCommandline {
 		return nil, fmt.Errorf("cannot use kernel command line from gadget: %v", err)
 	}
-	// gadget has the kernel command line
+	// when nothing is set by the gadget, we could have had arguments
+	// before, so make sure those are cleared now
 	args := map[string]string{
 		"snapd_extra_cmdline_args": "",
 		"snapd_full_cmdline_args":  "",
 	}
 	if full {
-		args["snapd_full_cmdline_args"] = extraOrFull
+		args["snapd_full_cmdline_args"] = joinKernelArgs(extraOrFull, cmdlineAppend)
 	} else {
-		args["snapd_extra_cmdline_args"] = extraOrFull
+		args["snapd_extra_cmdline_args"] = joinKernelArgs(extraOrFull, cmdlineAppend)
 	}
 	return args, nil
 }
 
+// joinKernelArgs joins the non empty fragments of the kernel command line.
+func joinKernelArgs(fragments ...string) string {
+	var nonEmpty []string
+	for _, f := range fragments {
+		if f != "" {
+			nonEmpty = append(nonEmpty, f)
+		}
+	}
+	return strings.Join(nonEmpty, " ")
+}
+
 const (
 	currentEdition = iota
 	candidateEdition
 )
 
-func composeCommandLine(currentOrCandidate int, mode, system, gadgetDirOrSnapPath string) (string, error) {
+func composeCommandLine(currentOrCandidate int, mode, system, gadgetDirOrSnapPath, cmdlineAppend string) (string, error) {
 	if mode != ModeRun && mode != ModeRecover {
 		return "", fmt.Errorf("internal error: unsupported command line mode %q", mode)
 	}
@@ -181,6 +193,17 @@ func composeCommandLine(currentOrCandidate int, mode, system, gadgetDirOrSnapPat
 			}
 		}
 	}
+	if cmdlineAppend != "" {
+		if mode != ModeRun {
+			return "", fmt.Errorf("internal error: cannot append kernel command line arguments in %q mode", mode)
+		}
+		// arguments from system configuration always come last
+		if components.FullArgs != "" {
+			components.FullArgs = joinKernelArgs(components.FullArgs, cmdlineAppend)
+		} else {
+			components.ExtraArgs = joinKernelArgs(components.ExtraArgs, cmdlineAppend)
+		}
+	}
 	if currentOrCandidate == currentEdition {
 		return mbl.CommandLine(components)
 	} else {
@@ -194,26 +217,27 @@ func ComposeRecoveryCommandLine(model *asserts.Model, system, gadgetDirOrSnapPat
 	if model.Grade() == asserts.ModelGradeUnset {
 		return "", nil
 	}
-	return composeCommandLine(currentEdition, ModeRecover, system, gadgetDirOrSnapPath)
+	return composeCommandLine(currentEdition, ModeRecover, system, gadgetDirOrSnapPath, "")
 }
 
 // ComposeCommandLine composes the kernel command line used when booting the
-// system in run mode.
-func ComposeCommandLine(model *asserts.Model, gadgetDirOrSnapPath string) (string, error) {
+// system in run mode. The cmdlineAppend arguments, coming from system
+// configuration, are placed at the end of the command line.
+func ComposeCommandLine(model *asserts.Model, gadgetDirOrSnapPath, cmdlineAppend string) (string, error) {
 	if model.Grade() == asserts.ModelGradeUnset {
 		return "", nil
 	}
-	return composeCommandLine(currentEdition, ModeRun, "", gadgetDirOrSnapPath)
+	return composeCommandLine(currentEdition, ModeRun, "", gadgetDirOrSnapPath, cmdlineAppend)
 }
 
 // ComposeCandidateCommandLine composes the kernel command line used when
 // booting the system in run mode with the current built-in edition of managed
 // boot assets.
-func ComposeCandidateCommandLine(model *asserts.Model, gadgetDirOrSnapPath string) (string, error) {
+func ComposeCandidateCommandLine(model *asserts.Model, gadgetDirOrSnapPath, cmdlineAppend string) (string, error) {
 	if model.Grade() == asserts.ModelGradeUnset {
 		return "", nil
 	}
-	return composeCommandLine(candidateEdition, ModeRun, "", gadgetDirOrSnapPath)
+	return composeCommandLine(candidateEdition, ModeRun, "", gadgetDirOrSnapPath, cmdlineAppend)
 }
 
 // ComposeCandidateRecoveryCommandLine composes the kernel command line used
@@ -223,7 +247,7 @@ func ComposeCandidateRecoveryCommandLine(model *asserts.Model, system, gadgetDir
 	if model.Grade() == asserts.ModelGradeUnset {
 		return "", nil
 	}
-	return composeCommandLine(candidateEdition, ModeRecover, system, gadgetDirOrSnapPath)
+	return composeCommandLine(candidateEdition, ModeRecover, system, gadgetDirOrSnapPath, "")
 }
 
 // observeSuccessfulCommandLine observes a successful boot with a command line
@@ -283,7 +307,7 @@ func observeSuccessfulCommandLineUpdate(m *Modeenv) (*Modeenv, error) {
 func observeSuccessfulCommandLineCompatBoot(model *asserts.Model, m *Modeenv) (*Modeenv, error) {
 	// since this is a compatibility scenario, the kernel command line
 	// arguments would not have come from the gadget before either
-	cmdlineExpected, err := ComposeCommandLine(model, "")
+	cmdlineExpected, err := ComposeCommandLine(model, "", "")
 	if err != nil {
 		return nil, err
 	}
@@ -316,10 +340,11 @@ const (
 )
 
 // observeCommandLineUpdate observes a pending kernel command line change caused
-// by an update of boot config or the gadget snap. When needed, the modeenv is
-// updated with a candidate command line and the encryption keys are resealed.
-// This helper should be called right before updating the managed boot config.
-func observeCommandLineUpdate(model *asserts.Model, reason commandLineUpdateReason, gadgetSnapOrDir string) (updated bool, err error) {
+// by an update of boot config, the gadget snap or the arguments appended
+// through system configuration. When needed, the modeenv is updated with a
+// candidate command line and the encryption keys are resealed. This helper
+// should be called right before updating the managed boot config.
+func observeCommandLineUpdate(model *asserts.Model, reason commandLineUpdateReason, gadgetSnapOrDir, cmdlineAppend string) (updated bool, err error) {
 	// TODO:UC20: consider updating a recovery system command line
 
 	m, err := loadModeenv()
@@ -338,10 +363,10 @@ func observeCommandLineUpdate(model *asserts.Model, reason commandLineUpdateReas
 	switch reason {
 	case commandLineUpdateReasonSnapd:
 		// pending boot config update
-		candidateCmdline, err = ComposeCandidateCommandLine(model, gadgetSnapOrDir)
+		candidateCmdline, err = ComposeCandidateCommandLine(model, gadgetSnapOrDir, cmdlineAppend)
 	case commandLineUpdateReasonGadget:
-		// pending gadget update
-		candidateCmdline, err = ComposeCommandLine(model, gadgetSnapOrDir)
+		// pending gadget update or change of appended arguments
+		candidateCmdline, err = ComposeCommandLine(model, gadgetSnapOrDir, cmdlineAppend)
 	}
 	if err != nil {
 		return false, err
@@ -376,7 +401,7 @@ func kernelCommandLinesForResealWithFallback(modeenv *Modeenv) (cmdlines []strin
 	// there would be no kernel command lines arguments coming from the
 	// gadget either
 	gadgetDir := ""
-	cmdline, err := composeCommandLine(currentEdition, ModeRun, "", gadgetDir)
+	cmdline, err := composeCommandLine(currentEdition, ModeRun, "", gadgetDir, "")
 	if err != nil {
 		return nil, err
 	}
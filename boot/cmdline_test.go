@@ -86,6 +86,14 @@ func (s *kernelCommandLineSuite) TestModeAndLabel(c *C) {
 		// no recovery system label
 		cmd: "snapd_recovery_mode=install foo=bar",
 		err: `cannot specify install mode without system label`,
+	}, {
+		cmd:   "snapd_recovery_mode=factory-reset snapd_recovery_system=1234",
+		mode:  boot.ModeFactoryReset,
+		label: "1234",
+	}, {
+		// no recovery system label
+		cmd: "snapd_recovery_mode=factory-reset foo=bar",
+		err: `cannot specify factory-reset mode without system label`,
 	}, {
 		cmd: "snapd_recovery_system=1234",
 		err: `cannot specify system label without a mode`,
@@ -126,7 +134,7 @@ func (s *kernelCommandLineSuite) TestComposeCommandLineNotManagedHappy(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(cmdline, Equals, "")
 
-	cmdline, err = boot.ComposeCommandLine(model, "")
+	cmdline, err = boot.ComposeCommandLine(model, "", "")
 	c.Assert(err, IsNil)
 	c.Assert(cmdline, Equals, "")
 
@@ -137,7 +145,7 @@ func (s *kernelCommandLineSuite) TestComposeCommandLineNotManagedHappy(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(cmdline, Equals, "snapd_recovery_mode=recover snapd_recovery_system=20200314")
 
-	cmdline, err = boot.ComposeCommandLine(model, "")
+	cmdline, err = boot.ComposeCommandLine(model, "", "")
 	c.Assert(err, IsNil)
 	c.Assert(cmdline, Equals, "snapd_recovery_mode=run")
 }
@@ -152,7 +160,7 @@ func (s *kernelCommandLineSuite) TestComposeCommandLineNotUC20(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(cmdline, Equals, "")
 
-	cmdline, err = boot.ComposeCommandLine(model, "")
+	cmdline, err = boot.ComposeCommandLine(model, "", "")
 	c.Assert(err, IsNil)
 	c.Check(cmdline, Equals, "")
 }
@@ -169,18 +177,41 @@ func (s *kernelCommandLineSuite) TestComposeCommandLineManagedHappy(c *C) {
 	cmdline, err := boot.ComposeRecoveryCommandLine(model, "20200314", "")
 	c.Assert(err, IsNil)
 	c.Assert(cmdline, Equals, "snapd_recovery_mode=recover snapd_recovery_system=20200314 panic=-1")
-	cmdline, err = boot.ComposeCommandLine(model, "")
+	cmdline, err = boot.ComposeCommandLine(model, "", "")
 	c.Assert(err, IsNil)
 	c.Assert(cmdline, Equals, "snapd_recovery_mode=run panic=-1")
 
 	cmdline, err = boot.ComposeRecoveryCommandLine(model, "20200314", "")
 	c.Assert(err, IsNil)
 	c.Assert(cmdline, Equals, "snapd_recovery_mode=recover snapd_recovery_system=20200314 panic=-1")
-	cmdline, err = boot.ComposeCommandLine(model, "")
+	cmdline, err = boot.ComposeCommandLine(model, "", "")
 	c.Assert(err, IsNil)
 	c.Assert(cmdline, Equals, "snapd_recovery_mode=run panic=-1")
 }
 
+func (s *kernelCommandLineSuite) TestComposeCommandLineManagedWithAppend(c *C) {
+	model := boottest.MakeMockUC20Model()
+
+	tbl := bootloadertest.Mock("btloader", c.MkDir()).WithTrustedAssets()
+	bootloader.Force(tbl)
+	defer bootloader.Force(nil)
+
+	tbl.StaticCommandLine = "panic=-1"
+	tbl.CandidateStaticCommandLine = "candidate panic=0"
+
+	cmdline, err := boot.ComposeCommandLine(model, "", "isolcpus=1-3 quiet")
+	c.Assert(err, IsNil)
+	c.Check(cmdline, Equals, "snapd_recovery_mode=run panic=-1 isolcpus=1-3 quiet")
+	cmdline, err = boot.ComposeCandidateCommandLine(model, "", "isolcpus=1-3 quiet")
+	c.Assert(err, IsNil)
+	c.Check(cmdline, Equals, "snapd_recovery_mode=run candidate panic=0 isolcpus=1-3 quiet")
+
+	// arguments are never appended to the recovery command line
+	cmdline, err = boot.ComposeRecoveryCommandLine(model, "20200314", "")
+	c.Assert(err, IsNil)
+	c.Check(cmdline, Equals, "snapd_recovery_mode=recover snapd_recovery_system=20200314 panic=-1")
+}
+
 func (s *kernelCommandLineSuite) TestComposeCandidateCommandLineManagedHappy(c *C) {
 	model := boottest.MakeMockUC20Model()
 
@@ -191,7 +222,7 @@ func (s *kernelCommandLineSuite) TestComposeCandidateCommandLineManagedHappy(c *
 	tbl.StaticCommandLine = "panic=-1"
 	tbl.CandidateStaticCommandLine = "candidate panic=0"
 
-	cmdline, err := boot.ComposeCandidateCommandLine(model, "")
+	cmdline, err := boot.ComposeCandidateCommandLine(model, "", "")
 	c.Assert(err, IsNil)
 	c.Assert(cmdline, Equals, "snapd_recovery_mode=run candidate panic=0")
 }
@@ -273,9 +304,9 @@ func (s *kernelCommandLineSuite) TestComposeCommandLineWithGadget(c *C) {
 		var err error
 		switch tc.which {
 		case "current":
-			cmdline, err = boot.ComposeCommandLine(model, sf)
+			cmdline, err = boot.ComposeCommandLine(model, sf, "")
 		case "candidate":
-			cmdline, err = boot.ComposeCandidateCommandLine(model, sf)
+			cmdline, err = boot.ComposeCandidateCommandLine(model, sf, "")
 		default:
 			c.Fatalf("unexpected command line type")
 		}
@@ -359,9 +390,10 @@ func (s *kernelCommandLineSuite) TestComposeRecoveryCommandLineWithGadget(c *C)
 
 func (s *kernelCommandLineSuite) TestBootVarsForGadgetCommandLine(c *C) {
 	for _, tc := range []struct {
-		errMsg       string
-		files        [][]string
-		expectedVars map[string]string
+		errMsg        string
+		files         [][]string
+		cmdlineAppend string
+		expectedVars  map[string]string
 	}{{
 		files: [][]string{
 			{"cmdline.extra", "foo bar baz"},
@@ -398,11 +430,36 @@ func (s *kernelCommandLineSuite) TestBootVarsForGadgetCommandLine(c *C) {
 			"snapd_extra_cmdline_args": "",
 			"snapd_full_cmdline_args":  "",
 		},
+	}, {
+		files: [][]string{
+			{"cmdline.extra", "foo bar baz"},
+		},
+		cmdlineAppend: "isolcpus=1-3",
+		expectedVars: map[string]string{
+			"snapd_extra_cmdline_args": "foo bar baz isolcpus=1-3",
+			"snapd_full_cmdline_args":  "",
+		},
+	}, {
+		files: [][]string{
+			{"cmdline.full", "full foo bar baz"},
+		},
+		cmdlineAppend: "isolcpus=1-3",
+		expectedVars: map[string]string{
+			"snapd_extra_cmdline_args": "",
+			"snapd_full_cmdline_args":  "full foo bar baz isolcpus=1-3",
+		},
+	}, {
+		files:         [][]string{},
+		cmdlineAppend: "isolcpus=1-3",
+		expectedVars: map[string]string{
+			"snapd_extra_cmdline_args": "isolcpus=1-3",
+			"snapd_full_cmdline_args":  "",
+		},
 	}} {
 		sf := snaptest.MakeTestSnapWithFiles(c, gadgetSnapYaml, append([][]string{
 			{"meta/snap.yaml", gadgetSnapYaml},
 		}, tc.files...))
-		vars, err := boot.BootVarsForTrustedCommandLineFromGadget(sf)
+		vars, err := boot.BootVarsForTrustedCommandLineFromGadget(sf, tc.cmdlineAppend)
 		if tc.errMsg == "" {
 			c.Assert(err, IsNil)
 			c.Assert(vars, DeepEquals, tc.expectedVars)
@@ -118,6 +118,30 @@ func MockSecbootSealKeysWithFDESetupHook(f func(runHook fde.RunSetupHookFunc, ke
 	}
 }
 
+func MockSecbootAddLUKS2ContainerKey(f func(node string, existingKey, newKey []byte) error) (restore func()) {
+	old := secbootAddLUKS2ContainerKey
+	secbootAddLUKS2ContainerKey = f
+	return func() {
+		secbootAddLUKS2ContainerKey = old
+	}
+}
+
+func MockSecbootRemoveLUKS2ContainerKey(f func(node string, key []byte) error) (restore func()) {
+	old := secbootRemoveLUKS2ContainerKey
+	secbootRemoveLUKS2ContainerKey = f
+	return func() {
+		secbootRemoveLUKS2ContainerKey = old
+	}
+}
+
+func MockSecbootLUKS2ContainerHasKey(f func(node string, key []byte) (bool, error)) (restore func()) {
+	old := secbootLUKS2ContainerHasKey
+	secbootLUKS2ContainerHasKey = f
+	return func() {
+		secbootLUKS2ContainerHasKey = old
+	}
+}
+
 func MockSeedReadSystemEssential(f func(seedDir, label string, essentialTypes []snap.Type, tm timings.Measurer) (*asserts.Model, []*seed.Snap, error)) (restore func()) {
 	old := seedReadSystemEssential
 	seedReadSystemEssential = f
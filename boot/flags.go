@@ -153,9 +153,9 @@ func InitramfsActiveBootFlags(mode string) ([]string, error) {
 		// to reduce the number of times we read the modeenv ?
 		return modeenv.BootFlags, nil
 
-	case ModeInstall:
+	case ModeInstall, ModeFactoryReset:
 		// boot flags always come from the bootenv of the recovery bootloader
-		// in install mode
+		// in install and factory-reset modes
 
 		opts := &bootloader.Options{
 			Role: bootloader.RoleRecovery,
@@ -275,7 +275,7 @@ func setNextBootFlags(dev Device, rootDir string, flags []string) error {
 // HostUbuntuDataForMode returns a list of locations where the run
 // mode root filesystem is mounted for the given mode.
 // For run mode, it's "/run/mnt/data" and "/".
-// For install mode it's "/run/mnt/ubuntu-data".
+// For install and factory-reset modes it's "/run/mnt/ubuntu-data".
 // For recover mode it's either "/host/ubuntu-data" or nil if that is not
 // mounted. Note that, for recover mode, this function only returns a non-empty
 // return value if the partition is mounted and trusted, there are certain
@@ -319,11 +319,11 @@ func HostUbuntuDataForMode(mode string) ([]string, error) {
 		}
 		// otherwise leave it empty
 
-	case ModeInstall:
+	case ModeInstall, ModeFactoryReset:
 		// the var we have is for /run/mnt/ubuntu-data/writable, but the caller
 		// probably wants /run/mnt/ubuntu-data
 
-		// note that we may be running in install mode before this directory is
+		// note that we may be running in install or factory-reset mode before this directory is
 		// actually created so check if it exists first
 		installModeLocation := filepath.Dir(InstallHostWritableDir)
 		if exists, _, _ := osutil.DirExists(installModeLocation); exists {
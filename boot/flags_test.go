@@ -356,6 +356,12 @@ func (s *bootFlagsSuite) TestRunModeRootfs(c *C) {
 			createExpDirs: true,
 			comment:       "install mode after partition creation",
 		},
+		{
+			mode:          boot.ModeFactoryReset,
+			expDirs:       []string{"/run/mnt/ubuntu-data"},
+			createExpDirs: true,
+			comment:       "factory-reset mode after partition creation",
+		},
 		{
 			mode: boot.ModeRecover,
 			degradedJSON: `
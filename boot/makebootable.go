@@ -242,8 +242,7 @@ func MakeRecoverySystemBootable(rootdir string, relativeRecoverySystemDir string
 	}
 
 	// on e.g. ARM we need to extract the kernel assets on the recovery
-	// system as well, but the bootloader does not load any environment from
-	// the recovery system
+	// system as well
 	erkbl, ok := bl.(bootloader.ExtractedRecoveryKernelImageBootloader)
 	if ok {
 		kernelf, err := snapfile.Open(bootWith.KernelPath)
@@ -259,12 +258,15 @@ func MakeRecoverySystemBootable(rootdir string, relativeRecoverySystemDir string
 		if err != nil {
 			return fmt.Errorf("cannot extract recovery system kernel assets: %v", err)
 		}
-
-		return nil
 	}
 
 	rbl, ok := bl.(bootloader.RecoveryAwareBootloader)
 	if !ok {
+		if erkbl != nil {
+			// the bootloader does not load any environment from the
+			// recovery system
+			return nil
+		}
 		return fmt.Errorf("cannot use %s bootloader: does not support recovery systems", bl.Name())
 	}
 	kernelPath, err := filepath.Rel(rootdir, bootWith.KernelPath)
@@ -275,7 +277,7 @@ func MakeRecoverySystemBootable(rootdir string, relativeRecoverySystemDir string
 		"snapd_recovery_kernel": filepath.Join("/", kernelPath),
 	}
 	if _, ok := bl.(bootloader.TrustedAssetsBootloader); ok {
-		recoveryCmdlineArgs, err := bootVarsForTrustedCommandLineFromGadget(bootWith.GadgetSnapOrDir)
+		recoveryCmdlineArgs, err := bootVarsForTrustedCommandLineFromGadget(bootWith.GadgetSnapOrDir, "")
 		if err != nil {
 			return fmt.Errorf("cannot obtain recovery system command line: %v", err)
 		}
@@ -435,13 +437,13 @@ func MakeRunnableSystem(model *asserts.Model, bootWith *BootableSet, sealer *Tru
 			return fmt.Errorf("cannot install managed bootloader assets: %v", err)
 		}
 		// determine the expected command line
-		cmdline, err := ComposeCandidateCommandLine(model, bootWith.UnpackedGadgetDir)
+		cmdline, err := ComposeCandidateCommandLine(model, bootWith.UnpackedGadgetDir, "")
 		if err != nil {
 			return fmt.Errorf("cannot compose the candidate command line: %v", err)
 		}
 		modeenv.CurrentKernelCommandLines = bootCommandLines{cmdline}
 
-		cmdlineVars, err := bootVarsForTrustedCommandLineFromGadget(bootWith.UnpackedGadgetDir)
+		cmdlineVars, err := bootVarsForTrustedCommandLineFromGadget(bootWith.UnpackedGadgetDir, "")
 		if err != nil {
 			return fmt.Errorf("cannot prepare bootloader variables for kernel command line: %v", err)
 		}
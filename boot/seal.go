@@ -29,6 +29,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/bootloader"
@@ -36,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/kernel/fde"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/disks"
 	"github.com/snapcore/snapd/secboot"
 	"github.com/snapcore/snapd/seed"
 	"github.com/snapcore/snapd/snap"
@@ -47,6 +49,9 @@ var (
 	secbootSealKeys                 = secboot.SealKeys
 	secbootSealKeysWithFDESetupHook = secboot.SealKeysWithFDESetupHook
 	secbootResealKeys               = secboot.ResealKeys
+	secbootAddLUKS2ContainerKey     = secboot.AddLUKS2ContainerKey
+	secbootRemoveLUKS2ContainerKey  = secboot.RemoveLUKS2ContainerKey
+	secbootLUKS2ContainerHasKey     = secboot.LUKS2ContainerHasKey
 
 	seedReadSystemEssential = seed.ReadSystemEssential
 )
@@ -566,7 +571,7 @@ func recoveryBootChainsForSystems(systems []string, trbl bootloader.TrustedAsset
 			}
 
 			// get the command line
-			cmdline, err := composeCommandLine(currentEdition, ModeRecover, system, seedGadget.Path)
+			cmdline, err := composeCommandLine(currentEdition, ModeRecover, system, seedGadget.Path, "")
 			if err != nil {
 				return fmt.Errorf("cannot obtain recovery kernel command line: %v", err)
 			}
@@ -755,3 +760,267 @@ func isResealNeeded(pbc predictableBootChains, bootChainsFile string, expectRese
 	}
 	return true, c + 1, nil
 }
+
+// encryptedContainerDevices returns the block devices of the LUKS2 containers
+// of ubuntu-data and ubuntu-save. The ubuntu-save device is empty when there
+// is no encrypted ubuntu-save partition.
+func encryptedContainerDevices() (dataDev, saveDev string, err error) {
+	disk, err := disks.DiskFromMountPoint(InitramfsUbuntuSeedDir, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot find disk of ubuntu-seed: %v", err)
+	}
+	dataUUID, err := disk.FindMatchingPartitionUUIDWithFsLabel(secboot.EncryptedPartitionName("ubuntu-data"))
+	if err != nil {
+		return "", "", fmt.Errorf("cannot find encrypted ubuntu-data partition: %v", err)
+	}
+	dataDev = filepath.Join("/dev/disk/by-partuuid", dataUUID)
+
+	saveUUID, err := disk.FindMatchingPartitionUUIDWithFsLabel(secboot.EncryptedPartitionName("ubuntu-save"))
+	switch err.(type) {
+	case nil:
+		saveDev = filepath.Join("/dev/disk/by-partuuid", saveUUID)
+	case disks.PartitionNotFoundError:
+		// no system-save support
+	default:
+		return "", "", fmt.Errorf("cannot find encrypted ubuntu-save partition: %v", err)
+	}
+	return dataDev, saveDev, nil
+}
+
+// fdeKeys carries the fallback keys of the encrypted containers which are
+// known to snapd in run mode.
+type fdeKeys struct {
+	// recoveryKey is the recovery key of ubuntu-data
+	recoveryKey *secboot.RecoveryKey
+	// saveKey is the key of ubuntu-save
+	saveKey []byte
+	// reinstallKey is the recovery key of ubuntu-save, it is not known
+	// after a factory reset
+	reinstallKey *secboot.RecoveryKey
+}
+
+func readFDEKeys(withSave bool) (*fdeKeys, error) {
+	if _, err := sealedKeysMethod(dirs.GlobalRootDir); err != nil {
+		if err == errNoSealedKeys {
+			return nil, fmt.Errorf("system does not use full disk encryption")
+		}
+		return nil, err
+	}
+
+	var keys fdeKeys
+	rkey, err := secboot.RecoveryKeyFromFile(filepath.Join(dirs.SnapFDEDir, "recovery.key"))
+	if err != nil {
+		return nil, err
+	}
+	keys.recoveryKey = rkey
+	if !withSave {
+		return &keys, nil
+	}
+
+	keys.saveKey, err = ioutil.ReadFile(filepath.Join(dirs.SnapFDEDir, "ubuntu-save.key"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ubuntu-save key: %v", err)
+	}
+	reinstallKeyFile := filepath.Join(dirs.SnapFDEDir, "reinstall.key")
+	if osutil.FileExists(reinstallKeyFile) {
+		keys.reinstallKey, err = secboot.RecoveryKeyFromFile(reinstallKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &keys, nil
+}
+
+// recoveryKeysMu serializes the changes to the keyslots of the encrypted
+// containers.
+var recoveryKeysMu sync.Mutex
+
+// oldRecoveryKeyFile returns the file keeping the key replaced by a rotation
+// of the key stored in keyFile until it is removed from its container.
+func oldRecoveryKeyFile(keyFile string) string {
+	return keyFile + ".old"
+}
+
+// rotateRecoveryKey adds a new recovery key to the container on dev, using
+// authKey to authorize the change, stores it in keyFile and only then removes
+// the old key from the container, if one is known. Until it is removed, the
+// old key is stored next to keyFile, so that removing it can be completed by
+// removeOldRecoveryKey if the rotation fails or is interrupted.
+func rotateRecoveryKey(dev string, authKey []byte, oldKey *secboot.RecoveryKey, keyFile string) error {
+	newKey, err := secboot.NewRecoveryKey()
+	if err != nil {
+		return fmt.Errorf("cannot create recovery key: %v", err)
+	}
+	oldKeyFile := oldRecoveryKeyFile(keyFile)
+	if oldKey != nil {
+		if err := oldKey.Save(oldKeyFile); err != nil {
+			return fmt.Errorf("cannot store old recovery key: %v", err)
+		}
+	}
+	if err := secbootAddLUKS2ContainerKey(dev, authKey, newKey[:]); err != nil {
+		os.Remove(oldKeyFile)
+		return err
+	}
+	if err := newKey.Save(keyFile); err != nil {
+		// do not leave a key nobody knows about behind
+		if err := secbootRemoveLUKS2ContainerKey(dev, newKey[:]); err != nil {
+			logger.Noticef("cannot remove unsaved recovery key from %s: %v", dev, err)
+		}
+		os.Remove(oldKeyFile)
+		return fmt.Errorf("cannot store recovery key: %v", err)
+	}
+	if oldKey == nil {
+		return nil
+	}
+	if err := secbootRemoveLUKS2ContainerKey(dev, oldKey[:]); err != nil {
+		return err
+	}
+	return os.Remove(oldKeyFile)
+}
+
+// removeOldRecoveryKey completes a failed or interrupted rotation of the key
+// stored in keyFile by removing the old key from the container on dev.
+func removeOldRecoveryKey(dev, keyFile string) error {
+	oldKeyFile := oldRecoveryKeyFile(keyFile)
+	if !osutil.FileExists(oldKeyFile) {
+		return nil
+	}
+	oldKey, err := secboot.RecoveryKeyFromFile(oldKeyFile)
+	if err != nil {
+		return err
+	}
+	currentKey, err := secboot.RecoveryKeyFromFile(keyFile)
+	if err != nil {
+		return err
+	}
+	// if the new key was not stored, the old key is still the current one
+	if *oldKey != *currentKey {
+		hasKey, err := secbootLUKS2ContainerHasKey(dev, oldKey[:])
+		if err != nil {
+			return err
+		}
+		if hasKey {
+			if err := secbootRemoveLUKS2ContainerKey(dev, oldKey[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Remove(oldKeyFile)
+}
+
+// RotateRecoveryKeys replaces the recovery key of ubuntu-data and the
+// reinstall key of ubuntu-save with newly generated keys. Each new key is
+// added to its encrypted container and stored before the key it replaces is
+// removed, so that a fallback key is available at all times. If an old key
+// could not be removed, the rotation fails and removing it is retried first
+// on the next call, before any new key is added. The sealed keys are left
+// untouched, hence no reseal is needed.
+func RotateRecoveryKeys() error {
+	recoveryKeysMu.Lock()
+	defer recoveryKeysMu.Unlock()
+
+	dataDev, saveDev, err := encryptedContainerDevices()
+	if err != nil {
+		return err
+	}
+
+	recoveryKeyFile := filepath.Join(dirs.SnapFDEDir, "recovery.key")
+	reinstallKeyFile := filepath.Join(dirs.SnapFDEDir, "reinstall.key")
+	if err := removeOldRecoveryKey(dataDev, recoveryKeyFile); err != nil {
+		return fmt.Errorf("cannot remove old recovery key: %v", err)
+	}
+	if saveDev != "" {
+		if err := removeOldRecoveryKey(saveDev, reinstallKeyFile); err != nil {
+			return fmt.Errorf("cannot remove old reinstall key: %v", err)
+		}
+	}
+
+	keys, err := readFDEKeys(saveDev != "")
+	if err != nil {
+		return err
+	}
+
+	// the key of ubuntu-data is only available sealed, authorize the
+	// change with the recovery key itself
+	if err := rotateRecoveryKey(dataDev, keys.recoveryKey[:], keys.recoveryKey, recoveryKeyFile); err != nil {
+		return fmt.Errorf("cannot rotate recovery key: %v", err)
+	}
+	if saveDev == "" {
+		return nil
+	}
+	if err := rotateRecoveryKey(saveDev, keys.saveKey, keys.reinstallKey, reinstallKeyFile); err != nil {
+		return fmt.Errorf("cannot rotate reinstall key: %v", err)
+	}
+	return nil
+}
+
+// AddRecoveryPassphrase adds a keyslot which can be unlocked with the given
+// passphrase to the encrypted ubuntu-data and ubuntu-save containers. The
+// passphrase can then be used as an additional fallback with the standard
+// LUKS tools. The sealed keys are not affected.
+func AddRecoveryPassphrase(passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("cannot use an empty passphrase")
+	}
+	recoveryKeysMu.Lock()
+	defer recoveryKeysMu.Unlock()
+
+	dataDev, saveDev, err := encryptedContainerDevices()
+	if err != nil {
+		return err
+	}
+	keys, err := readFDEKeys(saveDev != "")
+	if err != nil {
+		return err
+	}
+
+	if err := secbootAddLUKS2ContainerKey(dataDev, keys.recoveryKey[:], []byte(passphrase)); err != nil {
+		return fmt.Errorf("cannot add passphrase to ubuntu-data: %v", err)
+	}
+	if saveDev == "" {
+		return nil
+	}
+	if err := secbootAddLUKS2ContainerKey(saveDev, keys.saveKey, []byte(passphrase)); err != nil {
+		return fmt.Errorf("cannot add passphrase to ubuntu-save: %v", err)
+	}
+	return nil
+}
+
+// RemoveRecoveryPassphrase removes the keyslots which can be unlocked with the
+// given passphrase from the encrypted ubuntu-data and ubuntu-save containers.
+// The keys managed by snapd cannot be removed this way, compromised recovery
+// keys are replaced with RotateRecoveryKeys instead.
+func RemoveRecoveryPassphrase(passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("cannot use an empty passphrase")
+	}
+	recoveryKeysMu.Lock()
+	defer recoveryKeysMu.Unlock()
+
+	dataDev, saveDev, err := encryptedContainerDevices()
+	if err != nil {
+		return err
+	}
+	keys, err := readFDEKeys(saveDev != "")
+	if err != nil {
+		return err
+	}
+	isManaged := string(keys.recoveryKey[:]) == passphrase || string(keys.saveKey) == passphrase
+	if keys.reinstallKey != nil && string(keys.reinstallKey[:]) == passphrase {
+		isManaged = true
+	}
+	if isManaged {
+		return fmt.Errorf("cannot remove a key managed by the system")
+	}
+
+	if err := secbootRemoveLUKS2ContainerKey(dataDev, []byte(passphrase)); err != nil {
+		return fmt.Errorf("cannot remove passphrase from ubuntu-data: %v", err)
+	}
+	if saveDev == "" {
+		return nil
+	}
+	if err := secbootRemoveLUKS2ContainerKey(saveDev, []byte(passphrase)); err != nil {
+		return fmt.Errorf("cannot remove passphrase from ubuntu-save: %v", err)
+	}
+	return nil
+}
@@ -37,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/kernel/fde"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/disks"
 	"github.com/snapcore/snapd/secboot"
 	"github.com/snapcore/snapd/seed"
 	"github.com/snapcore/snapd/snap"
@@ -331,6 +332,87 @@ func (s *sealSuite) TestSealKeyToModeenv(c *C) {
 }
 
 // TODO:UC20: also test fallback reseal
+func (s *sealSuite) TestSealKeyToModeenvSystemdBoot(c *C) {
+	rootdir := c.MkDir()
+	dirs.SetRootDir(rootdir)
+	defer dirs.SetRootDir("")
+
+	for _, dir := range []string{"run/mnt/ubuntu-seed", "run/mnt/ubuntu-boot"} {
+		cfg := filepath.Join(rootdir, dir, "loader/loader.conf")
+		c.Assert(os.MkdirAll(filepath.Dir(cfg), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(cfg, []byte("# Snapd-Boot-Config-Edition: 1\n"), 0644), IsNil)
+	}
+
+	model := boottest.MakeMockUC20Model()
+	modeenv := &boot.Modeenv{
+		RecoverySystem: "20200825",
+		CurrentTrustedRecoveryBootAssets: boot.BootAssetsMap{
+			"bootx64.efi":         []string{"shim-hash-1"},
+			"systemd-bootx64.efi": []string{"sdboot-hash-1"},
+		},
+		CurrentKernels: []string{"pc-kernel_500.snap"},
+		CurrentKernelCommandLines: boot.BootCommandLines{
+			"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1",
+		},
+		Model:          model.Model(),
+		BrandID:        model.BrandID(),
+		Grade:          string(model.Grade()),
+		ModelSignKeyID: model.SignKeyID(),
+	}
+	mockAssetsCache(c, rootdir, "systemd-boot", []string{
+		"bootx64.efi-shim-hash-1",
+		"systemd-bootx64.efi-sdboot-hash-1",
+	})
+
+	restore := boot.MockSeedReadSystemEssential(func(seedDir, label string, essentialTypes []snap.Type, tm timings.Measurer) (*asserts.Model, []*seed.Snap, error) {
+		return model, []*seed.Snap{mockKernelSeedSnap(c, snap.R(1)), mockGadgetSeedSnap(c, nil)}, nil
+	})
+	defer restore()
+
+	sealKeysCalls := 0
+	restore = boot.MockSecbootSealKeys(func(keys []secboot.SealKeyRequest, params *secboot.SealKeysParams) error {
+		sealKeysCalls++
+		c.Assert(params.ModelParams, HasLen, 1)
+
+		shim := bootloader.NewBootFile("", filepath.Join(rootdir, "var/lib/snapd/boot-assets/systemd-boot/bootx64.efi-shim-hash-1"), bootloader.RoleRecovery)
+		sdboot := bootloader.NewBootFile("", filepath.Join(rootdir, "var/lib/snapd/boot-assets/systemd-boot/systemd-bootx64.efi-sdboot-hash-1"), bootloader.RoleRecovery)
+		kernel := bootloader.NewBootFile("/var/lib/snapd/seed/snaps/pc-kernel_1.snap", "kernel.efi", bootloader.RoleRecovery)
+		runKernel := bootloader.NewBootFile(filepath.Join(rootdir, "var/lib/snapd/snaps/pc-kernel_500.snap"), "kernel.efi", bootloader.RoleRunMode)
+
+		switch sealKeysCalls {
+		case 1:
+			// the run mode kernel is booted directly by systemd-boot
+			// from the seed partition
+			c.Check(params.ModelParams[0].EFILoadChains, DeepEquals, []*secboot.LoadChain{
+				secboot.NewLoadChain(shim,
+					secboot.NewLoadChain(sdboot,
+						secboot.NewLoadChain(kernel))),
+				secboot.NewLoadChain(shim,
+					secboot.NewLoadChain(sdboot,
+						secboot.NewLoadChain(runKernel))),
+			})
+			c.Check(params.ModelParams[0].KernelCmdlines, DeepEquals, []string{
+				"snapd_recovery_mode=recover snapd_recovery_system=20200825 console=ttyS0 console=tty1 panic=-1",
+				"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1",
+			})
+		case 2:
+			c.Check(params.ModelParams[0].EFILoadChains, DeepEquals, []*secboot.LoadChain{
+				secboot.NewLoadChain(shim,
+					secboot.NewLoadChain(sdboot,
+						secboot.NewLoadChain(kernel))),
+			})
+		default:
+			c.Errorf("unexpected additional call to secboot.SealKeys (call # %d)", sealKeysCalls)
+		}
+		return nil
+	})
+	defer restore()
+
+	err := boot.SealKeyToModeenv(secboot.EncryptionKey{}, secboot.EncryptionKey{}, model, modeenv)
+	c.Assert(err, IsNil)
+	c.Check(sealKeysCalls, Equals, 2)
+}
+
 func (s *sealSuite) TestResealKeyToModeenvWithSystemFallback(c *C) {
 	var prevPbc boot.PredictableBootChains
 	var prevRecoveryPbc boot.PredictableBootChains
@@ -1938,3 +2020,280 @@ func (s *sealSuite) TestResealKeyToModeenvWithTryModel(c *C) {
 		},
 	})
 }
+
+func (s *sealSuite) mockFDEKeysAndDisk(c *C, withSave bool) {
+	c.Assert(os.MkdirAll(dirs.SnapFDEDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapFDEDir, "sealed-keys"), []byte("tpm"), 0644), IsNil)
+	c.Assert(secboot.RecoveryKey{'r', 'e', 'c', 'o', 'v', 'e', 'r', 'y'}.Save(filepath.Join(dirs.SnapFDEDir, "recovery.key")), IsNil)
+
+	labels := map[string]string{
+		"ubuntu-data-enc": "data-uuid",
+	}
+	if withSave {
+		c.Assert(secboot.EncryptionKey("save-key").Save(filepath.Join(dirs.SnapFDEDir, "ubuntu-save.key")), IsNil)
+		c.Assert(secboot.RecoveryKey{'r', 'e', 'i', 'n', 's', 't', 'a', 'l', 'l'}.Save(filepath.Join(dirs.SnapFDEDir, "reinstall.key")), IsNil)
+		labels["ubuntu-save-enc"] = "save-uuid"
+	}
+	restore := disks.MockMountPointDisksToPartitionMapping(map[disks.Mountpoint]*disks.MockDiskMapping{
+		{Mountpoint: boot.InitramfsUbuntuSeedDir}: {
+			FilesystemLabelToPartUUID: labels,
+			DiskHasPartitions:         true,
+			DevNum:                    "disk",
+		},
+	})
+	s.AddCleanup(restore)
+}
+
+type luks2Op struct {
+	op, node, key, newKey string
+}
+
+func (s *sealSuite) mockLUKS2Keys(c *C) *[]luks2Op {
+	var ops []luks2Op
+	s.AddCleanup(boot.MockSecbootAddLUKS2ContainerKey(func(node string, existingKey, newKey []byte) error {
+		ops = append(ops, luks2Op{"add", node, string(existingKey), string(newKey)})
+		return nil
+	}))
+	s.AddCleanup(boot.MockSecbootRemoveLUKS2ContainerKey(func(node string, key []byte) error {
+		ops = append(ops, luks2Op{"remove", node, string(key), ""})
+		return nil
+	}))
+	return &ops
+}
+
+func (s *sealSuite) TestRotateRecoveryKeysHappy(c *C) {
+	s.mockFDEKeysAndDisk(c, true)
+	ops := s.mockLUKS2Keys(c)
+
+	oldRecoveryKey, err := secboot.RecoveryKeyFromFile(filepath.Join(dirs.SnapFDEDir, "recovery.key"))
+	c.Assert(err, IsNil)
+	oldReinstallKey, err := secboot.RecoveryKeyFromFile(filepath.Join(dirs.SnapFDEDir, "reinstall.key"))
+	c.Assert(err, IsNil)
+
+	err = boot.RotateRecoveryKeys()
+	c.Assert(err, IsNil)
+
+	newRecoveryKey, err := secboot.RecoveryKeyFromFile(filepath.Join(dirs.SnapFDEDir, "recovery.key"))
+	c.Assert(err, IsNil)
+	c.Check(newRecoveryKey, Not(DeepEquals), oldRecoveryKey)
+	newReinstallKey, err := secboot.RecoveryKeyFromFile(filepath.Join(dirs.SnapFDEDir, "reinstall.key"))
+	c.Assert(err, IsNil)
+	c.Check(newReinstallKey, Not(DeepEquals), oldReinstallKey)
+
+	c.Check(*ops, DeepEquals, []luks2Op{
+		// new key is added before the old one is removed
+		{"add", "/dev/disk/by-partuuid/data-uuid", string(oldRecoveryKey[:]), string(newRecoveryKey[:])},
+		{"remove", "/dev/disk/by-partuuid/data-uuid", string(oldRecoveryKey[:]), ""},
+		{"add", "/dev/disk/by-partuuid/save-uuid", "save-key", string(newReinstallKey[:])},
+		{"remove", "/dev/disk/by-partuuid/save-uuid", string(oldReinstallKey[:]), ""},
+	})
+	// nothing is left to be removed
+	c.Check(filepath.Join(dirs.SnapFDEDir, "recovery.key.old"), testutil.FileAbsent)
+	c.Check(filepath.Join(dirs.SnapFDEDir, "reinstall.key.old"), testutil.FileAbsent)
+}
+
+func (s *sealSuite) TestRotateRecoveryKeysNoSaveNoReinstallKey(c *C) {
+	s.mockFDEKeysAndDisk(c, false)
+	ops := s.mockLUKS2Keys(c)
+
+	oldRecoveryKey, err := secboot.RecoveryKeyFromFile(filepath.Join(dirs.SnapFDEDir, "recovery.key"))
+	c.Assert(err, IsNil)
+
+	err = boot.RotateRecoveryKeys()
+	c.Assert(err, IsNil)
+
+	newRecoveryKey, err := secboot.RecoveryKeyFromFile(filepath.Join(dirs.SnapFDEDir, "recovery.key"))
+	c.Assert(err, IsNil)
+	c.Check(*ops, DeepEquals, []luks2Op{
+		{"add", "/dev/disk/by-partuuid/data-uuid", string(oldRecoveryKey[:]), string(newRecoveryKey[:])},
+		{"remove", "/dev/disk/by-partuuid/data-uuid", string(oldRecoveryKey[:]), ""},
+	})
+}
+
+func (s *sealSuite) TestRotateRecoveryKeysAddFails(c *C) {
+	s.mockFDEKeysAndDisk(c, true)
+	oldRecoveryKey, err := secboot.RecoveryKeyFromFile(filepath.Join(dirs.SnapFDEDir, "recovery.key"))
+	c.Assert(err, IsNil)
+
+	s.AddCleanup(boot.MockSecbootAddLUKS2ContainerKey(func(node string, existingKey, newKey []byte) error {
+		return fmt.Errorf("boom")
+	}))
+	s.AddCleanup(boot.MockSecbootRemoveLUKS2ContainerKey(func(node string, key []byte) error {
+		c.Fatalf("unexpected call")
+		return nil
+	}))
+
+	err = boot.RotateRecoveryKeys()
+	c.Assert(err, ErrorMatches, "cannot rotate recovery key: boom")
+	// the previous key is kept
+	c.Check(filepath.Join(dirs.SnapFDEDir, "recovery.key"), testutil.FileEquals, oldRecoveryKey[:])
+	c.Check(filepath.Join(dirs.SnapFDEDir, "recovery.key.old"), testutil.FileAbsent)
+}
+
+func (s *sealSuite) TestRotateRecoveryKeysRemoveOldFailsResumed(c *C) {
+	s.mockFDEKeysAndDisk(c, false)
+	recoveryKeyFile := filepath.Join(dirs.SnapFDEDir, "recovery.key")
+	oldRecoveryKey, err := secboot.RecoveryKeyFromFile(recoveryKeyFile)
+	c.Assert(err, IsNil)
+
+	s.AddCleanup(boot.MockSecbootAddLUKS2ContainerKey(func(node string, existingKey, newKey []byte) error {
+		return nil
+	}))
+	s.AddCleanup(boot.MockSecbootRemoveLUKS2ContainerKey(func(node string, key []byte) error {
+		return fmt.Errorf("boom")
+	}))
+
+	err = boot.RotateRecoveryKeys()
+	c.Assert(err, ErrorMatches, "cannot rotate recovery key: boom")
+	// the new key is in use and the old key is kept until it is removed
+	newRecoveryKey, err := secboot.RecoveryKeyFromFile(recoveryKeyFile)
+	c.Assert(err, IsNil)
+	c.Check(newRecoveryKey, Not(DeepEquals), oldRecoveryKey)
+	c.Check(recoveryKeyFile+".old", testutil.FileEquals, oldRecoveryKey[:])
+
+	ops := s.mockLUKS2Keys(c)
+	s.AddCleanup(boot.MockSecbootLUKS2ContainerHasKey(func(node string, key []byte) (bool, error) {
+		c.Check(node, Equals, "/dev/disk/by-partuuid/data-uuid")
+		c.Check(key, DeepEquals, oldRecoveryKey[:])
+		return true, nil
+	}))
+
+	err = boot.RotateRecoveryKeys()
+	c.Assert(err, IsNil)
+
+	newerRecoveryKey, err := secboot.RecoveryKeyFromFile(recoveryKeyFile)
+	c.Assert(err, IsNil)
+	c.Check(*ops, DeepEquals, []luks2Op{
+		// the old key is removed first
+		{"remove", "/dev/disk/by-partuuid/data-uuid", string(oldRecoveryKey[:]), ""},
+		{"add", "/dev/disk/by-partuuid/data-uuid", string(newRecoveryKey[:]), string(newerRecoveryKey[:])},
+		{"remove", "/dev/disk/by-partuuid/data-uuid", string(newRecoveryKey[:]), ""},
+	})
+	c.Check(recoveryKeyFile+".old", testutil.FileAbsent)
+}
+
+func (s *sealSuite) TestRotateRecoveryKeysRemoveOldFailsAgain(c *C) {
+	s.mockFDEKeysAndDisk(c, false)
+	recoveryKeyFile := filepath.Join(dirs.SnapFDEDir, "recovery.key")
+	c.Assert(secboot.RecoveryKey{'o', 'l', 'd'}.Save(recoveryKeyFile+".old"), IsNil)
+
+	s.AddCleanup(boot.MockSecbootLUKS2ContainerHasKey(func(node string, key []byte) (bool, error) {
+		return true, nil
+	}))
+	s.AddCleanup(boot.MockSecbootAddLUKS2ContainerKey(func(node string, existingKey, newKey []byte) error {
+		c.Fatalf("unexpected call")
+		return nil
+	}))
+	s.AddCleanup(boot.MockSecbootRemoveLUKS2ContainerKey(func(node string, key []byte) error {
+		return fmt.Errorf("boom")
+	}))
+
+	// no new key is added while the old one cannot be removed
+	err := boot.RotateRecoveryKeys()
+	c.Assert(err, ErrorMatches, "cannot remove old recovery key: boom")
+	c.Check(recoveryKeyFile+".old", testutil.FilePresent)
+}
+
+func (s *sealSuite) TestRotateRecoveryKeysOldKeyAlreadyRemoved(c *C) {
+	s.mockFDEKeysAndDisk(c, false)
+	recoveryKeyFile := filepath.Join(dirs.SnapFDEDir, "recovery.key")
+	currentKey, err := secboot.RecoveryKeyFromFile(recoveryKeyFile)
+	c.Assert(err, IsNil)
+	// interrupted after the old key was removed
+	c.Assert(secboot.RecoveryKey{'o', 'l', 'd'}.Save(recoveryKeyFile+".old"), IsNil)
+
+	ops := s.mockLUKS2Keys(c)
+	s.AddCleanup(boot.MockSecbootLUKS2ContainerHasKey(func(node string, key []byte) (bool, error) {
+		return false, nil
+	}))
+
+	err = boot.RotateRecoveryKeys()
+	c.Assert(err, IsNil)
+	newKey, err := secboot.RecoveryKeyFromFile(recoveryKeyFile)
+	c.Assert(err, IsNil)
+	c.Check(*ops, DeepEquals, []luks2Op{
+		{"add", "/dev/disk/by-partuuid/data-uuid", string(currentKey[:]), string(newKey[:])},
+		{"remove", "/dev/disk/by-partuuid/data-uuid", string(currentKey[:]), ""},
+	})
+	c.Check(recoveryKeyFile+".old", testutil.FileAbsent)
+}
+
+func (s *sealSuite) TestRotateRecoveryKeysInterruptedBeforeNewKeyStored(c *C) {
+	s.mockFDEKeysAndDisk(c, false)
+	recoveryKeyFile := filepath.Join(dirs.SnapFDEDir, "recovery.key")
+	currentKey, err := secboot.RecoveryKeyFromFile(recoveryKeyFile)
+	c.Assert(err, IsNil)
+	// the old key is still the current one
+	c.Assert(currentKey.Save(recoveryKeyFile+".old"), IsNil)
+
+	ops := s.mockLUKS2Keys(c)
+	s.AddCleanup(boot.MockSecbootLUKS2ContainerHasKey(func(node string, key []byte) (bool, error) {
+		c.Fatalf("unexpected call")
+		return false, nil
+	}))
+
+	err = boot.RotateRecoveryKeys()
+	c.Assert(err, IsNil)
+	newKey, err := secboot.RecoveryKeyFromFile(recoveryKeyFile)
+	c.Assert(err, IsNil)
+	// the current key is only removed once replaced
+	c.Check(*ops, DeepEquals, []luks2Op{
+		{"add", "/dev/disk/by-partuuid/data-uuid", string(currentKey[:]), string(newKey[:])},
+		{"remove", "/dev/disk/by-partuuid/data-uuid", string(currentKey[:]), ""},
+	})
+}
+
+func (s *sealSuite) TestRotateRecoveryKeysNotEncrypted(c *C) {
+	s.mockFDEKeysAndDisk(c, true)
+	c.Assert(os.Remove(filepath.Join(dirs.SnapFDEDir, "sealed-keys")), IsNil)
+	s.mockLUKS2Keys(c)
+
+	err := boot.RotateRecoveryKeys()
+	c.Assert(err, ErrorMatches, "system does not use full disk encryption")
+}
+
+func (s *sealSuite) TestAddRecoveryPassphrase(c *C) {
+	s.mockFDEKeysAndDisk(c, true)
+	ops := s.mockLUKS2Keys(c)
+
+	err := boot.AddRecoveryPassphrase("")
+	c.Assert(err, ErrorMatches, "cannot use an empty passphrase")
+
+	err = boot.AddRecoveryPassphrase("my passphrase")
+	c.Assert(err, IsNil)
+	rkey, err := secboot.RecoveryKeyFromFile(filepath.Join(dirs.SnapFDEDir, "recovery.key"))
+	c.Assert(err, IsNil)
+	c.Check(*ops, DeepEquals, []luks2Op{
+		{"add", "/dev/disk/by-partuuid/data-uuid", string(rkey[:]), "my passphrase"},
+		{"add", "/dev/disk/by-partuuid/save-uuid", "save-key", "my passphrase"},
+	})
+}
+
+func (s *sealSuite) TestRemoveRecoveryPassphrase(c *C) {
+	s.mockFDEKeysAndDisk(c, true)
+	ops := s.mockLUKS2Keys(c)
+
+	err := boot.RemoveRecoveryPassphrase("my passphrase")
+	c.Assert(err, IsNil)
+	c.Check(*ops, DeepEquals, []luks2Op{
+		{"remove", "/dev/disk/by-partuuid/data-uuid", "my passphrase", ""},
+		{"remove", "/dev/disk/by-partuuid/save-uuid", "my passphrase", ""},
+	})
+}
+
+func (s *sealSuite) TestRemoveRecoveryPassphraseManagedKeys(c *C) {
+	s.mockFDEKeysAndDisk(c, true)
+	ops := s.mockLUKS2Keys(c)
+
+	rkey, err := secboot.RecoveryKeyFromFile(filepath.Join(dirs.SnapFDEDir, "recovery.key"))
+	c.Assert(err, IsNil)
+	reinstallKey, err := secboot.RecoveryKeyFromFile(filepath.Join(dirs.SnapFDEDir, "reinstall.key"))
+	c.Assert(err, IsNil)
+	for _, key := range []string{string(rkey[:]), string(reinstallKey[:]), "save-key"} {
+		err := boot.RemoveRecoveryPassphrase(key)
+		c.Check(err, ErrorMatches, "cannot remove a key managed by the system")
+	}
+	err = boot.RemoveRecoveryPassphrase("")
+	c.Check(err, ErrorMatches, "cannot use an empty passphrase")
+	c.Check(*ops, HasLen, 0)
+}
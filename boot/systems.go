@@ -447,3 +447,48 @@ func DropRecoverySystem(dev Device, systemLabel string) error {
 	const expectReseal = true
 	return resealKeyToModeenv(dirs.GlobalRootDir, m, expectReseal)
 }
+
+// RemoveRecoverySystem drops the recovery system with the given label from the
+// list of good and current recovery systems in the modeenv, reseals the keys
+// as needed and makes sure that the recovery bootloader environment no longer
+// refers to that system. The last good recovery system cannot be removed.
+func RemoveRecoverySystem(dev Device, systemLabel string) error {
+	if !dev.HasModeenv() {
+		return fmt.Errorf("internal error: recovery systems can only be used on UC20")
+	}
+
+	m, err := loadModeenv()
+	if err != nil {
+		return err
+	}
+	otherGood, _ := dropFromRecoverySystemsList(append([]string(nil), m.GoodRecoverySystems...), systemLabel)
+	if len(otherGood) == 0 {
+		return fmt.Errorf("cannot remove the last good recovery system %q", systemLabel)
+	}
+
+	opts := &bootloader.Options{
+		// setup the recovery bootloader
+		Role: bootloader.RoleRecovery,
+	}
+	bl, err := bootloader.Find(InitramfsUbuntuSeedDir, opts)
+	if err != nil {
+		return err
+	}
+	vars, err := bl.GetBootVars("snapd_recovery_system", "try_recovery_system")
+	if err != nil {
+		return err
+	}
+	if vars["try_recovery_system"] == systemLabel {
+		return fmt.Errorf("cannot remove recovery system %q while it is being tried", systemLabel)
+	}
+	if vars["snapd_recovery_system"] == systemLabel {
+		// the removed system is the default one, use the most recent
+		// good recovery system instead
+		newDefault := otherGood[len(otherGood)-1]
+		if err := bl.SetBootVars(map[string]string{"snapd_recovery_system": newDefault}); err != nil {
+			return err
+		}
+	}
+
+	return DropRecoverySystem(dev, systemLabel)
+}
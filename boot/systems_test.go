@@ -1735,6 +1735,112 @@ func (s *systemsSuite) TestDropRecoverySystemResealErr(c *C) {
 	})
 }
 
+func (s *systemsSuite) TestRemoveRecoverySystemHappy(c *C) {
+	mtbl := s.mockTrustedBootloaderWithAssetAndChains(c, s.runKernelBf, s.recoveryKernelBf)
+	bootloader.Force(mtbl)
+	defer bootloader.Force(nil)
+
+	// system is encrypted
+	s.stampSealedKeys(c, s.rootdir)
+
+	model := s.uc20dev.Model()
+	modeenv := &boot.Modeenv{
+		Mode: "run",
+		// keep this comment to make old gofmt happy
+		CurrentRecoverySystems: []string{"20200825", "1234", "20210101"},
+		GoodRecoverySystems:    []string{"20200825", "1234", "20210101"},
+		CurrentKernels:         []string{},
+		CurrentTrustedRecoveryBootAssets: boot.BootAssetsMap{
+			"asset": []string{"asset-hash-1"},
+		},
+		CurrentTrustedBootAssets: boot.BootAssetsMap{
+			"asset": []string{"asset-hash-1"},
+		},
+
+		Model:          model.Model(),
+		BrandID:        model.BrandID(),
+		Grade:          string(model.Grade()),
+		ModelSignKeyID: model.SignKeyID(),
+	}
+	c.Assert(modeenv.WriteTo(""), IsNil)
+
+	// the removed system is the default one
+	err := mtbl.SetBootVars(map[string]string{
+		"snapd_recovery_system": "1234",
+		"snapd_recovery_mode":   "run",
+	})
+	c.Assert(err, IsNil)
+
+	restore := boot.MockSeedReadSystemEssential(func(seedDir, label string, essentialTypes []snap.Type, tm timings.Measurer) (*asserts.Model, []*seed.Snap, error) {
+		return model, []*seed.Snap{s.seedKernelSnap, s.seedGadgetSnap}, nil
+	})
+	defer restore()
+
+	resealCalls := 0
+	restore = boot.MockSecbootResealKeys(func(params *secboot.ResealKeysParams) error {
+		resealCalls++
+		c.Assert(params.ModelParams, HasLen, 1)
+		if resealCalls == 2 {
+			// fallback keys are resealed for the remaining systems
+			c.Check(params.ModelParams[0].KernelCmdlines, DeepEquals, []string{
+				"snapd_recovery_mode=recover snapd_recovery_system=20200825 static cmdline",
+				"snapd_recovery_mode=recover snapd_recovery_system=20210101 static cmdline",
+			})
+		}
+		return nil
+	})
+	defer restore()
+
+	err = boot.RemoveRecoverySystem(s.uc20dev, "1234")
+	c.Assert(err, IsNil)
+	c.Check(resealCalls, Equals, 2)
+
+	modeenvRead, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check(modeenvRead.CurrentRecoverySystems, DeepEquals, []string{"20200825", "20210101"})
+	c.Check(modeenvRead.GoodRecoverySystems, DeepEquals, []string{"20200825", "20210101"})
+
+	// the most recent good system is now the default one
+	m, err := mtbl.GetBootVars("snapd_recovery_system", "snapd_recovery_mode")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snapd_recovery_system": "20210101",
+		"snapd_recovery_mode":   "run",
+	})
+}
+
+func (s *systemsSuite) TestRemoveRecoverySystemErrors(c *C) {
+	bl := bootloadertest.Mock("mock", s.bootdir)
+	bootloader.Force(bl)
+	defer bootloader.Force(nil)
+
+	modeenv := &boot.Modeenv{
+		Mode:                   "run",
+		CurrentRecoverySystems: []string{"20200825", "1234"},
+		GoodRecoverySystems:    []string{"20200825"},
+	}
+	c.Assert(modeenv.WriteTo(""), IsNil)
+
+	err := boot.RemoveRecoverySystem(s.uc20dev, "20200825")
+	c.Assert(err, ErrorMatches, `cannot remove the last good recovery system "20200825"`)
+
+	bl.SetBootVars(map[string]string{
+		"try_recovery_system":    "1234",
+		"recovery_system_status": "try",
+	})
+	err = boot.RemoveRecoverySystem(s.uc20dev, "1234")
+	c.Assert(err, ErrorMatches, `cannot remove recovery system "1234" while it is being tried`)
+
+	// nothing was changed
+	modeenvRead, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check(modeenvRead.CurrentRecoverySystems, DeepEquals, []string{"20200825", "1234"})
+	c.Check(modeenvRead.GoodRecoverySystems, DeepEquals, []string{"20200825"})
+
+	err = boot.RemoveRecoverySystem(boottest.MockDevice(""), "1234")
+	c.Assert(err, ErrorMatches, `internal error: recovery systems can only be used on UC20`)
+}
+
 type initramfsMarkTryRecoverySystemSuite struct {
 	baseSystemsSuite
 
@@ -74,6 +74,10 @@ func MockGrubFiles(c *C, rootdir string) {
 	c.Assert(err, IsNil)
 }
 
+func NewSystemdBoot(rootdir string, opts *Options) RecoveryAwareBootloader {
+	return newSystemdBoot(rootdir, opts).(RecoveryAwareBootloader)
+}
+
 func NewLk(rootdir string, opts *Options) ExtractedRecoveryKernelImageBootloader {
 	if opts == nil {
 		opts = &Options{
@@ -0,0 +1,751 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/bootloader/androidbootenv"
+	"github.com/snapcore/snapd/bootloader/assets"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+// sanity - systemd-boot implements the required interfaces
+var (
+	_ Bootloader                             = (*systemdBoot)(nil)
+	_ RecoveryAwareBootloader                = (*systemdBoot)(nil)
+	_ ExtractedRecoveryKernelImageBootloader = (*systemdBoot)(nil)
+	_ ExtractedRunKernelImageBootloader      = (*systemdBoot)(nil)
+	_ TrustedAssetsBootloader                = (*systemdBoot)(nil)
+)
+
+const (
+	systemdBootConfigAsset = "systemd-boot-loader.conf"
+	systemdBootEnvName     = "sdbootenv"
+
+	// loader entry IDs, the entries are sorted by their sort keys, with
+	// entries that have no tries left sorted last
+	systemdBootRecoveryEntry = "snapd-recovery"
+	systemdBootTryEntry      = "snapd-try"
+	systemdBootRunEntry      = "snapd-run"
+)
+
+// systemd-boot boot counting uses the <id>+<left>[-<done>].conf entry file
+// naming, see https://systemd.io/AUTOMATIC_BOOT_ASSESSMENT/
+var systemdBootEntryRe = regexp.MustCompile(`^(.+?)(?:\+([0-9]+)(?:-([0-9]+))?)?\.conf$`)
+
+// systemdBoot implements support for systemd-boot booting Unified Kernel
+// Images shipped as kernel.efi in the kernel snap. The kernels are extracted
+// and booted through loader entries that are generated from the bootloader
+// environment. Trying a new kernel uses a loader entry with a boot counter,
+// which systemd-boot decrements before booting it, thus the kernel status is
+// derived from the name of the try entry.
+//
+// In run mode, the ubuntu-boot partition is expected to be an Extended Boot
+// Loader partition, with its entries picked up by systemd-boot from the
+// ubuntu-seed partition.
+type systemdBoot struct {
+	rootdir string
+
+	basedir string
+
+	runMode               bool
+	recovery              bool
+	nativePartitionLayout bool
+}
+
+// newSystemdBoot creates a new systemd-boot bootloader object
+func newSystemdBoot(rootdir string, opts *Options) Bootloader {
+	sb := &systemdBoot{rootdir: rootdir}
+	if opts != nil {
+		sb.runMode = opts.Role == RoleRunMode
+		sb.recovery = opts.Role == RoleRecovery
+		sb.nativePartitionLayout = opts.NoSlashBoot || sb.recovery
+	}
+	if !sb.nativePartitionLayout {
+		// the boot partition is mounted at /boot/efi
+		sb.basedir = "boot/efi"
+	}
+	return sb
+}
+
+func (sb *systemdBoot) Name() string {
+	return "systemd-boot"
+}
+
+func (sb *systemdBoot) dir() string {
+	if sb.rootdir == "" {
+		panic("internal error: unset rootdir")
+	}
+	return filepath.Join(sb.rootdir, sb.basedir)
+}
+
+func (sb *systemdBoot) configFile() string {
+	return filepath.Join(sb.dir(), "loader/loader.conf")
+}
+
+func (sb *systemdBoot) entriesDir() string {
+	return filepath.Join(sb.dir(), "loader/entries")
+}
+
+// kernelsDir is the directory kernels are extracted to, relative to the root
+// of the boot partition.
+func (sb *systemdBoot) kernelsDir() string {
+	return "EFI/ubuntu"
+}
+
+func (sb *systemdBoot) envFile() string {
+	return filepath.Join(sb.dir(), sb.kernelsDir(), systemdBootEnvName)
+}
+
+func (sb *systemdBoot) Present() (bool, error) {
+	return osutil.FileExists(sb.configFile()), nil
+}
+
+func (sb *systemdBoot) InstallBootConfig(gadgetDir string, opts *Options) error {
+	if opts != nil && (opts.Role == RoleRecovery || opts.Role == RoleRunMode) {
+		// install managed config
+		return genericSetBootConfigFromAsset(sb.configFile(), systemdBootConfigAsset)
+	}
+	gadgetFile := filepath.Join(gadgetDir, sb.Name()+".conf")
+	return genericInstallBootConfig(gadgetFile, sb.configFile())
+}
+
+func loadSystemdBootEnv(path string) (*androidbootenv.Env, error) {
+	env := androidbootenv.NewEnv(path)
+	if err := env.Load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return env, nil
+}
+
+func (sb *systemdBoot) GetBootVars(names ...string) (map[string]string, error) {
+	env, err := loadSystemdBootEnv(sb.envFile())
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(names))
+	for _, name := range names {
+		out[name] = env.Get(name)
+		if name == "kernel_status" && !sb.recovery {
+			tryEntry, err := sb.findEntry(systemdBootTryEntry)
+			if err != nil {
+				return nil, err
+			}
+			if tryEntry != nil {
+				out[name] = tryEntry.kernelStatus()
+			}
+		}
+	}
+	return out, nil
+}
+
+func (sb *systemdBoot) SetBootVars(values map[string]string) error {
+	env, err := loadSystemdBootEnv(sb.envFile())
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		env.Set(k, v)
+	}
+	if err := os.MkdirAll(filepath.Dir(sb.envFile()), 0755); err != nil {
+		return err
+	}
+	if err := env.Save(); err != nil {
+		return err
+	}
+
+	if sb.recovery {
+		_, modeSet := values["snapd_recovery_mode"]
+		_, systemSet := values["snapd_recovery_system"]
+		if modeSet || systemSet {
+			return sb.updateRecoveryEntry(env)
+		}
+		return nil
+	}
+
+	if status, ok := values["kernel_status"]; ok {
+		if err := sb.setTryEntryStatus(status); err != nil {
+			return err
+		}
+	}
+	_, extraSet := values["snapd_extra_cmdline_args"]
+	_, fullSet := values["snapd_full_cmdline_args"]
+	if extraSet || fullSet {
+		return sb.updateKernelEntries(env)
+	}
+	return nil
+}
+
+func (sb *systemdBoot) SetRecoverySystemEnv(recoverySystemDir string, values map[string]string) error {
+	if recoverySystemDir == "" {
+		return fmt.Errorf("internal error: recoverySystemDir unset")
+	}
+	envFile := filepath.Join(sb.rootdir, recoverySystemDir, systemdBootEnvName)
+	if err := os.MkdirAll(filepath.Dir(envFile), 0755); err != nil {
+		return err
+	}
+	env, err := loadSystemdBootEnv(envFile)
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		env.Set(k, v)
+	}
+	if err := env.Save(); err != nil {
+		return err
+	}
+
+	// the entry of the currently selected recovery system carries the
+	// command line from the recovery system environment
+	blEnv, err := loadSystemdBootEnv(sb.envFile())
+	if err != nil {
+		return err
+	}
+	selectedSystem := blEnv.Get("snapd_recovery_system")
+	if selectedSystem == "" || strings.TrimPrefix(filepath.Clean(recoverySystemDir), "/") != filepath.Join("systems", selectedSystem) {
+		return nil
+	}
+	return sb.updateRecoveryEntry(blEnv)
+}
+
+func (sb *systemdBoot) GetRecoverySystemEnv(recoverySystemDir string, key string) (string, error) {
+	if recoverySystemDir == "" {
+		return "", fmt.Errorf("internal error: recoverySystemDir unset")
+	}
+	env, err := loadSystemdBootEnv(filepath.Join(sb.rootdir, recoverySystemDir, systemdBootEnvName))
+	if err != nil {
+		return "", err
+	}
+	return env.Get(key), nil
+}
+
+// loader entries handling
+
+type systemdBootEntry struct {
+	id   string
+	path string
+
+	counted   bool
+	triesLeft int
+	triesDone int
+	efi       string
+}
+
+func parseSystemdBootEntryName(name string) (id string, counted bool, left, done int, ok bool) {
+	m := systemdBootEntryRe.FindStringSubmatch(name)
+	if m == nil {
+		return "", false, 0, 0, false
+	}
+	if m[2] != "" {
+		counted = true
+		left, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			done, _ = strconv.Atoi(m[3])
+		}
+	}
+	return m[1], counted, left, done, true
+}
+
+// kernelStatus returns the kernel status corresponding to the state of the
+// boot counter of the entry.
+func (e *systemdBootEntry) kernelStatus() string {
+	switch {
+	case e.counted && e.triesLeft > 0:
+		return "try"
+	case e.counted && e.triesDone > 0:
+		// systemd-boot has already attempted to boot the entry
+		return "trying"
+	}
+	return ""
+}
+
+// findEntry returns the loader entry with the given ID, or nil if there is
+// none.
+func (sb *systemdBoot) findEntry(id string) (*systemdBootEntry, error) {
+	matches, err := filepath.Glob(filepath.Join(sb.entriesDir(), id+"*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range matches {
+		entryID, counted, left, done, ok := parseSystemdBootEntryName(filepath.Base(p))
+		if !ok || entryID != id {
+			continue
+		}
+		e := &systemdBootEntry{
+			id:        id,
+			path:      p,
+			counted:   counted,
+			triesLeft: left,
+			triesDone: done,
+		}
+		if err := e.load(); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return nil, nil
+}
+
+func (e *systemdBootEntry) load() error {
+	f, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "efi ") {
+			e.efi = strings.TrimSpace(strings.TrimPrefix(line, "efi "))
+		}
+	}
+	return scanner.Err()
+}
+
+func writeSystemdBootEntry(path, title, sortKey, efi, options string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "title %s\n", title)
+	fmt.Fprintf(&buf, "sort-key %s\n", sortKey)
+	fmt.Fprintf(&buf, "efi %s\n", efi)
+	if options != "" {
+		fmt.Fprintf(&buf, "options %s\n", options)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(path, buf.Bytes(), 0644, 0)
+}
+
+// setTryEntryStatus renames the try entry such that its boot counter matches
+// the requested kernel status.
+func (sb *systemdBoot) setTryEntryStatus(status string) error {
+	tryEntry, err := sb.findEntry(systemdBootTryEntry)
+	if err != nil || tryEntry == nil {
+		return err
+	}
+	var counter string
+	switch status {
+	case "try":
+		counter = "+1"
+	case "trying":
+		counter = "+0-1"
+	default:
+		// no tries left, the entry is not booted unless selected
+		// explicitly
+		counter = "+0"
+	}
+	newPath := filepath.Join(sb.entriesDir(), systemdBootTryEntry+counter+".conf")
+	if newPath == tryEntry.path {
+		return nil
+	}
+	return os.Rename(tryEntry.path, newPath)
+}
+
+func (sb *systemdBoot) kernelEfi(s snap.PlaceInfo) string {
+	return filepath.Join("/", sb.kernelsDir(), s.Filename(), "kernel.efi")
+}
+
+func (sb *systemdBoot) runCommandLine(env *androidbootenv.Env) (string, error) {
+	edition, err := sb.currentEdition()
+	if err != nil {
+		return "", err
+	}
+	pieces := CommandLineComponents{
+		ExtraArgs: env.Get("snapd_extra_cmdline_args"),
+		FullArgs:  env.Get("snapd_full_cmdline_args"),
+	}
+	if sb.runMode {
+		pieces.ModeArg = "snapd_recovery_mode=run"
+	}
+	return sb.commandLineForEdition(edition, pieces)
+}
+
+func (sb *systemdBoot) writeKernelEntry(path string, s snap.PlaceInfo, try bool) error {
+	env, err := loadSystemdBootEnv(sb.envFile())
+	if err != nil {
+		return err
+	}
+	return sb.writeKernelEntryWithEnv(path, s, try, env)
+}
+
+func (sb *systemdBoot) writeKernelEntryWithEnv(path string, s snap.PlaceInfo, try bool, env *androidbootenv.Env) error {
+	options, err := sb.runCommandLine(env)
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprintf("Ubuntu Core (%s)", s.Filename())
+	sortKey := "snapd-2-run"
+	if try {
+		title = fmt.Sprintf("Ubuntu Core, trying %s", s.Filename())
+		sortKey = "snapd-1-try"
+	}
+	return writeSystemdBootEntry(path, title, sortKey, sb.kernelEfi(s), options)
+}
+
+// updateKernelEntries refreshes the command line of the existing kernel
+// entries.
+func (sb *systemdBoot) updateKernelEntries(env *androidbootenv.Env) error {
+	for _, id := range []string{systemdBootRunEntry, systemdBootTryEntry} {
+		e, err := sb.findEntry(id)
+		if err != nil {
+			return err
+		}
+		if e == nil {
+			continue
+		}
+		s, err := sb.entryKernel(e)
+		if err != nil {
+			return err
+		}
+		if err := sb.writeKernelEntryWithEnv(e.path, s, id == systemdBootTryEntry, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateRecoveryEntry generates the entry of the recovery system selected in
+// the bootloader environment, or removes it when booting to run mode.
+func (sb *systemdBoot) updateRecoveryEntry(env *androidbootenv.Env) error {
+	mode := env.Get("snapd_recovery_mode")
+	system := env.Get("snapd_recovery_system")
+	entryPath := filepath.Join(sb.entriesDir(), systemdBootRecoveryEntry+".conf")
+	if mode == "" || mode == "run" {
+		// run mode entries come from the boot partition
+		if err := os.Remove(entryPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if system == "" {
+		return fmt.Errorf("cannot boot %q mode without a recovery system", mode)
+	}
+	systemDir := filepath.Join("systems", system)
+	systemEnv, err := loadSystemdBootEnv(filepath.Join(sb.rootdir, systemDir, systemdBootEnvName))
+	if err != nil {
+		return err
+	}
+	edition, err := sb.currentEdition()
+	if err != nil {
+		return err
+	}
+	options, err := sb.commandLineForEdition(edition, CommandLineComponents{
+		ModeArg:   "snapd_recovery_mode=" + mode,
+		SystemArg: "snapd_recovery_system=" + system,
+		ExtraArgs: systemEnv.Get("snapd_extra_cmdline_args"),
+		FullArgs:  systemEnv.Get("snapd_full_cmdline_args"),
+	})
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprintf("Ubuntu Core %s (%s)", mode, system)
+	return writeSystemdBootEntry(entryPath, title, "snapd-0-recovery",
+		filepath.Join("/", systemDir, "kernel.efi"), options)
+}
+
+func (sb *systemdBoot) entryKernel(e *systemdBootEntry) (snap.PlaceInfo, error) {
+	if e.efi == "" {
+		return nil, fmt.Errorf("cannot find kernel image in loader entry %s", filepath.Base(e.path))
+	}
+	// check that the kernel exists before continuing
+	if !osutil.FileExists(filepath.Join(sb.dir(), e.efi)) {
+		return nil, fmt.Errorf("cannot find kernel image %s of loader entry %s", e.efi, filepath.Base(e.path))
+	}
+	kernelSnapFileName := filepath.Base(filepath.Dir(e.efi))
+	sn, err := snap.ParsePlaceInfoFromSnapFileName(kernelSnapFileName)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"cannot parse kernel snap file name from loader entry %s: %v",
+			filepath.Base(e.path),
+			err,
+		)
+	}
+	return sn, nil
+}
+
+func (sb *systemdBoot) ExtractKernelAssets(s snap.PlaceInfo, snapf snap.Container) error {
+	// the kernel is a Unified Kernel Image carrying the initrd and is
+	// always extracted
+	return extractKernelAssetsToBootDir(
+		filepath.Join(sb.dir(), sb.kernelsDir(), s.Filename()),
+		snapf,
+		[]string{"kernel.efi"},
+	)
+}
+
+func (sb *systemdBoot) ExtractRecoveryKernelAssets(recoverySystemDir string, s snap.PlaceInfo, snapf snap.Container) error {
+	if recoverySystemDir == "" {
+		return fmt.Errorf("internal error: recoverySystemDir unset")
+	}
+	return extractKernelAssetsToBootDir(
+		filepath.Join(sb.rootdir, recoverySystemDir),
+		snapf,
+		[]string{"kernel.efi"},
+	)
+}
+
+func (sb *systemdBoot) RemoveKernelAssets(s snap.PlaceInfo) error {
+	return removeKernelAssetsFromBootDir(filepath.Join(sb.dir(), sb.kernelsDir()), s)
+}
+
+// actual ExtractedRunKernelImageBootloader methods
+
+func (sb *systemdBoot) checkKernelExtracted(s snap.PlaceInfo) error {
+	if !osutil.FileExists(filepath.Join(sb.dir(), sb.kernelEfi(s))) {
+		return fmt.Errorf("cannot enable kernel %s: %v", s.Filename(), os.ErrNotExist)
+	}
+	return nil
+}
+
+// EnableKernel writes the run loader entry, booting the referenced kernel
+// snap. EnableKernel() will fail if the referenced kernel snap was not
+// extracted.
+func (sb *systemdBoot) EnableKernel(s snap.PlaceInfo) error {
+	if err := sb.checkKernelExtracted(s); err != nil {
+		return err
+	}
+	return sb.writeKernelEntry(filepath.Join(sb.entriesDir(), systemdBootRunEntry+".conf"), s, false)
+}
+
+// EnableTryKernel writes the try loader entry, booting the referenced kernel
+// snap. The entry is booted only once the kernel status is set to "try".
+// EnableTryKernel() will fail if the referenced kernel snap was not extracted.
+func (sb *systemdBoot) EnableTryKernel(s snap.PlaceInfo) error {
+	if err := sb.checkKernelExtracted(s); err != nil {
+		return err
+	}
+	tryEntry, err := sb.findEntry(systemdBootTryEntry)
+	if err != nil {
+		return err
+	}
+	// keep the boot counter of an existing entry
+	path := filepath.Join(sb.entriesDir(), systemdBootTryEntry+"+0.conf")
+	if tryEntry != nil {
+		path = tryEntry.path
+	}
+	return sb.writeKernelEntry(path, s, true)
+}
+
+// DisableTryKernel removes the try loader entry if it exists.
+func (sb *systemdBoot) DisableTryKernel() error {
+	for {
+		tryEntry, err := sb.findEntry(systemdBootTryEntry)
+		if err != nil || tryEntry == nil {
+			return err
+		}
+		if err := os.Remove(tryEntry.path); err != nil {
+			return err
+		}
+	}
+}
+
+// Kernel returns the kernel snap booted by the run loader entry.
+func (sb *systemdBoot) Kernel() (snap.PlaceInfo, error) {
+	runEntry, err := sb.findEntry(systemdBootRunEntry)
+	if err != nil {
+		return nil, err
+	}
+	if runEntry == nil {
+		return nil, fmt.Errorf("cannot find %s loader entry", systemdBootRunEntry)
+	}
+	return sb.entryKernel(runEntry)
+}
+
+// TryKernel returns the kernel snap booted by the try loader entry, or
+// ErrNoTryKernelRef if there is no such entry.
+func (sb *systemdBoot) TryKernel() (snap.PlaceInfo, error) {
+	tryEntry, err := sb.findEntry(systemdBootTryEntry)
+	if err != nil {
+		return nil, err
+	}
+	if tryEntry == nil {
+		return nil, ErrNoTryKernelRef
+	}
+	return sb.entryKernel(tryEntry)
+}
+
+// TrustedAssetsBootloader methods
+
+func (sb *systemdBoot) currentEdition() (uint, error) {
+	edition, err := editionFromDiskConfigAsset(sb.configFile())
+	if err != nil {
+		if err != errNoEdition {
+			return 0, fmt.Errorf("cannot obtain edition number of current boot config: %v", err)
+		}
+		// not managed, use the initial edition of the internal asset
+		edition = 1
+	}
+	return edition, nil
+}
+
+// UpdateBootConfig updates the loader config only if it is already managed
+// and has a lower edition. The loader entries are regenerated when the
+// config was updated.
+//
+// Implements TrustedAssetsBootloader for the systemd-boot bootloader.
+func (sb *systemdBoot) UpdateBootConfig() (bool, error) {
+	updated, err := genericUpdateBootConfigFromAssets(sb.configFile(), systemdBootConfigAsset)
+	if err != nil || !updated {
+		return updated, err
+	}
+	env, err := loadSystemdBootEnv(sb.envFile())
+	if err != nil {
+		return true, err
+	}
+	if sb.recovery {
+		return true, sb.updateRecoveryEntry(env)
+	}
+	return true, sb.updateKernelEntries(env)
+}
+
+// ManagedAssets returns a list relative paths to boot assets inside the root
+// directory of the filesystem.
+//
+// Implements TrustedAssetsBootloader for the systemd-boot bootloader.
+func (sb *systemdBoot) ManagedAssets() []string {
+	return []string{
+		filepath.Join(sb.basedir, "loader/loader.conf"),
+	}
+}
+
+func (sb *systemdBoot) commandLineForEdition(edition uint, pieces CommandLineComponents) (string, error) {
+	if err := pieces.Validate(); err != nil {
+		return "", err
+	}
+
+	var nonSnapdCmdline string
+	if pieces.FullArgs == "" {
+		staticCmdline := assets.SnippetForEdition(systemdBootConfigAsset+":static-cmdline", edition)
+		nonSnapdCmdline = string(staticCmdline) + " " + pieces.ExtraArgs
+	} else {
+		nonSnapdCmdline = pieces.FullArgs
+	}
+	args, err := osutil.KernelCommandLineSplit(nonSnapdCmdline)
+	if err != nil {
+		return "", fmt.Errorf("cannot use badly formatted kernel command line: %v", err)
+	}
+	snapdArgs := make([]string, 0, 2)
+	if pieces.ModeArg != "" {
+		snapdArgs = append(snapdArgs, pieces.ModeArg)
+	}
+	if pieces.SystemArg != "" {
+		snapdArgs = append(snapdArgs, pieces.SystemArg)
+	}
+	return strings.Join(append(snapdArgs, args...), " "), nil
+}
+
+// CommandLine returns the kernel command line composed of mode and
+// system arguments, followed by either a built-in bootloader specific
+// static arguments corresponding to the on-disk boot asset edition, and
+// any extra arguments or a separate set of arguments provided in the
+// components.
+//
+// Implements TrustedAssetsBootloader for the systemd-boot bootloader.
+func (sb *systemdBoot) CommandLine(pieces CommandLineComponents) (string, error) {
+	edition, err := sb.currentEdition()
+	if err != nil {
+		return "", err
+	}
+	return sb.commandLineForEdition(edition, pieces)
+}
+
+// CandidateCommandLine is similar to CommandLine, but uses the current
+// edition of managed built-in boot assets as reference.
+//
+// Implements TrustedAssetsBootloader for the systemd-boot bootloader.
+func (sb *systemdBoot) CandidateCommandLine(pieces CommandLineComponents) (string, error) {
+	edition, err := editionFromInternalConfigAsset(systemdBootConfigAsset)
+	if err != nil {
+		return "", err
+	}
+	return sb.commandLineForEdition(edition, pieces)
+}
+
+var systemdBootRecoveryModeTrustedAssets = []string{
+	// recovery mode shim EFI binary
+	"EFI/boot/bootx64.efi",
+	// systemd-boot EFI binary
+	"EFI/systemd/systemd-bootx64.efi",
+}
+
+// TrustedAssets returns the list of relative paths to assets inside
+// the bootloader's rootdir that are measured in the boot process in the
+// order of loading during the boot. The run mode kernels are booted by
+// systemd-boot from the recovery partition, thus there are no run mode
+// trusted assets.
+func (sb *systemdBoot) TrustedAssets() ([]string, error) {
+	if !sb.nativePartitionLayout {
+		return nil, fmt.Errorf("internal error: trusted assets called without native host-partition layout")
+	}
+	if sb.recovery {
+		return systemdBootRecoveryModeTrustedAssets, nil
+	}
+	return nil, nil
+}
+
+// RecoveryBootChain returns the load chain for recovery modes.
+// It should be called on a RoleRecovery bootloader.
+func (sb *systemdBoot) RecoveryBootChain(kernelPath string) ([]BootFile, error) {
+	if !sb.recovery {
+		return nil, fmt.Errorf("not a recovery bootloader")
+	}
+
+	chain := make([]BootFile, 0, len(systemdBootRecoveryModeTrustedAssets)+1)
+	for _, ta := range systemdBootRecoveryModeTrustedAssets {
+		chain = append(chain, NewBootFile("", ta, RoleRecovery))
+	}
+	// the extracted recovery kernel is identical to the kernel.efi
+	// inside the snap
+	chain = append(chain, NewBootFile(kernelPath, "kernel.efi", RoleRecovery))
+
+	return chain, nil
+}
+
+// BootChain returns the load chain for run mode.
+// It should be called on a RoleRecovery bootloader passing the
+// RoleRunMode bootloader.
+func (sb *systemdBoot) BootChain(runBl Bootloader, kernelPath string) ([]BootFile, error) {
+	if !sb.recovery {
+		return nil, fmt.Errorf("not a recovery bootloader")
+	}
+	if runBl.Name() != sb.Name() {
+		return nil, fmt.Errorf("run mode bootloader must be %s", sb.Name())
+	}
+
+	// systemd-boot from the recovery partition boots the run mode kernel
+	// directly
+	chain := make([]BootFile, 0, len(systemdBootRecoveryModeTrustedAssets)+1)
+	for _, ta := range systemdBootRecoveryModeTrustedAssets {
+		chain = append(chain, NewBootFile("", ta, RoleRecovery))
+	}
+	chain = append(chain, NewBootFile(kernelPath, "kernel.efi", RoleRunMode))
+
+	return chain, nil
+}
@@ -0,0 +1,414 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/bootloader/assets"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapfile"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type systemdBootTestSuite struct {
+	baseBootenvTestSuite
+}
+
+var _ = Suite(&systemdBootTestSuite{})
+
+const mockSystemdBootLoaderConf = `# Snapd-Boot-Config-Edition: 1
+timeout 0
+`
+
+func (s *systemdBootTestSuite) SetUpTest(c *C) {
+	s.baseBootenvTestSuite.SetUpTest(c)
+
+	restore := assets.MockSnippetsForEdition("systemd-boot-loader.conf:static-cmdline", []assets.ForEditions{
+		{FirstEdition: 1, Snippet: []byte("static=1")},
+		{FirstEdition: 2, Snippet: []byte("static=2")},
+	})
+	s.AddCleanup(restore)
+}
+
+func (s *systemdBootTestSuite) mockLoaderConf(c *C, content string) {
+	p := filepath.Join(s.rootdir, "loader/loader.conf")
+	c.Assert(os.MkdirAll(filepath.Dir(p), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(p, []byte(content), 0644), IsNil)
+}
+
+func (s *systemdBootTestSuite) entries(c *C) []string {
+	matches, err := filepath.Glob(filepath.Join(s.rootdir, "loader/entries/*.conf"))
+	c.Assert(err, IsNil)
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = filepath.Base(m)
+	}
+	return names
+}
+
+func (s *systemdBootTestSuite) runBootloader(c *C) bootloader.ExtractedRunKernelImageBootloader {
+	s.mockLoaderConf(c, mockSystemdBootLoaderConf)
+	sb := bootloader.NewSystemdBoot(s.rootdir, &bootloader.Options{Role: bootloader.RoleRunMode, NoSlashBoot: true})
+	ebl, ok := sb.(bootloader.ExtractedRunKernelImageBootloader)
+	c.Assert(ok, Equals, true)
+	return ebl
+}
+
+func (s *systemdBootTestSuite) makeKernelAssetSnap(c *C, snapFileName string) snap.PlaceInfo {
+	kernelSnap, err := snap.ParsePlaceInfoFromSnapFileName(snapFileName)
+	c.Assert(err, IsNil)
+
+	kernelDir := filepath.Join(s.rootdir, "EFI/ubuntu", snapFileName)
+	c.Assert(os.MkdirAll(kernelDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(kernelDir, "kernel.efi"), nil, 0644), IsNil)
+	return kernelSnap
+}
+
+func (s *systemdBootTestSuite) TestNewSystemdBoot(c *C) {
+	sb := bootloader.NewSystemdBoot(s.rootdir, nil)
+	c.Assert(sb, NotNil)
+	c.Check(sb.Name(), Equals, "systemd-boot")
+
+	present, err := sb.Present()
+	c.Assert(err, IsNil)
+	c.Check(present, Equals, false)
+
+	p := filepath.Join(s.rootdir, "boot/efi/loader/loader.conf")
+	c.Assert(os.MkdirAll(filepath.Dir(p), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(p, nil, 0644), IsNil)
+	present, err = sb.Present()
+	c.Assert(err, IsNil)
+	c.Check(present, Equals, true)
+
+	// and the bootloader is found
+	bl, err := bootloader.Find(s.rootdir, nil)
+	c.Assert(err, IsNil)
+	c.Check(bl.Name(), Equals, "systemd-boot")
+}
+
+func (s *systemdBootTestSuite) TestForGadget(c *C) {
+	gadgetDir := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "systemd-boot.conf"), nil, 0644), IsNil)
+
+	bl, err := bootloader.ForGadget(gadgetDir, s.rootdir, nil)
+	c.Assert(err, IsNil)
+	c.Check(bl.Name(), Equals, "systemd-boot")
+}
+
+func (s *systemdBootTestSuite) TestInstallBootConfig(c *C) {
+	gadgetDir := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "systemd-boot.conf"), []byte("gadget config"), 0644), IsNil)
+
+	err := bootloader.InstallBootConfig(gadgetDir, s.rootdir, nil)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(s.rootdir, "boot/efi/loader/loader.conf"), testutil.FileEquals, "gadget config")
+
+	// managed config for the recovery bootloader
+	seedDir := c.MkDir()
+	err = bootloader.InstallBootConfig(gadgetDir, seedDir, &bootloader.Options{Role: bootloader.RoleRecovery})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(seedDir, "loader/loader.conf"), testutil.FileEquals,
+		string(assets.Internal("systemd-boot-loader.conf")))
+}
+
+func (s *systemdBootTestSuite) TestKernelExtraction(c *C) {
+	sb := s.runBootloader(c)
+
+	files := [][]string{
+		{"kernel.efi", "I'm a kernel"},
+		{"another-kernel-file", "another kernel file"},
+		{"meta/kernel.yaml", "version: 4.2"},
+	}
+	si := &snap.SideInfo{
+		RealName: "ubuntu-kernel",
+		Revision: snap.R(42),
+	}
+	fn := snaptest.MakeTestSnapWithFiles(c, packageKernel, files)
+	snapf, err := snapfile.Open(fn)
+	c.Assert(err, IsNil)
+	info, err := snap.ReadInfoFromSnapFile(snapf, si)
+	c.Assert(err, IsNil)
+
+	err = sb.ExtractKernelAssets(info, snapf)
+	c.Assert(err, IsNil)
+	kernefi := filepath.Join(s.rootdir, "EFI/ubuntu/ubuntu-kernel_42.snap/kernel.efi")
+	c.Check(kernefi, testutil.FileEquals, "I'm a kernel")
+	c.Check(filepath.Join(filepath.Dir(kernefi), "another-kernel-file"), testutil.FileAbsent)
+
+	err = sb.RemoveKernelAssets(info)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Dir(kernefi), testutil.FileAbsent)
+
+	// recovery kernels are extracted to the recovery system
+	rbl := bootloader.NewSystemdBoot(s.rootdir, &bootloader.Options{Role: bootloader.RoleRecovery})
+	erbl, ok := rbl.(bootloader.ExtractedRecoveryKernelImageBootloader)
+	c.Assert(ok, Equals, true)
+	err = erbl.ExtractRecoveryKernelAssets("systems/20210301", info, snapf)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(s.rootdir, "systems/20210301/kernel.efi"), testutil.FileEquals, "I'm a kernel")
+}
+
+func (s *systemdBootTestSuite) TestEnableKernel(c *C) {
+	sb := s.runBootloader(c)
+
+	kernel := s.makeKernelAssetSnap(c, "pc-kernel_1.snap")
+	err := sb.SetBootVars(map[string]string{"snapd_extra_cmdline_args": "extra=1"})
+	c.Assert(err, IsNil)
+
+	err = sb.EnableKernel(kernel)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(s.rootdir, "loader/entries/snapd-run.conf"), testutil.FileEquals, `title Ubuntu Core (pc-kernel_1.snap)
+sort-key snapd-2-run
+efi /EFI/ubuntu/pc-kernel_1.snap/kernel.efi
+options snapd_recovery_mode=run static=1 extra=1
+`)
+
+	k, err := sb.Kernel()
+	c.Assert(err, IsNil)
+	c.Check(k.Filename(), Equals, "pc-kernel_1.snap")
+
+	// changing the command line updates the entry
+	err = sb.SetBootVars(map[string]string{"snapd_extra_cmdline_args": "", "snapd_full_cmdline_args": "full=1"})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(s.rootdir, "loader/entries/snapd-run.conf"), testutil.FileContains,
+		"options snapd_recovery_mode=run full=1\n")
+
+	// kernels that were not extracted cannot be enabled
+	missing, err := snap.ParsePlaceInfoFromSnapFileName("pc-kernel_2.snap")
+	c.Assert(err, IsNil)
+	err = sb.EnableKernel(missing)
+	c.Assert(err, ErrorMatches, "cannot enable kernel pc-kernel_2.snap: file does not exist")
+	err = sb.EnableTryKernel(missing)
+	c.Assert(err, ErrorMatches, "cannot enable kernel pc-kernel_2.snap: file does not exist")
+}
+
+func (s *systemdBootTestSuite) TestKernelNoEntry(c *C) {
+	sb := s.runBootloader(c)
+
+	_, err := sb.Kernel()
+	c.Assert(err, ErrorMatches, "cannot find snapd-run loader entry")
+
+	_, err = sb.TryKernel()
+	c.Assert(err, Equals, bootloader.ErrNoTryKernelRef)
+}
+
+func (s *systemdBootTestSuite) TestTryKernelBootCounting(c *C) {
+	sb := s.runBootloader(c)
+
+	kernel := s.makeKernelAssetSnap(c, "pc-kernel_1.snap")
+	tryKernel := s.makeKernelAssetSnap(c, "pc-kernel_2.snap")
+	c.Assert(sb.EnableKernel(kernel), IsNil)
+
+	m, err := sb.GetBootVars("kernel_status")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{"kernel_status": ""})
+
+	// the try entry is not booted until the status is set
+	c.Assert(sb.EnableTryKernel(tryKernel), IsNil)
+	c.Check(s.entries(c), DeepEquals, []string{"snapd-run.conf", "snapd-try+0.conf"})
+	c.Check(filepath.Join(s.rootdir, "loader/entries/snapd-try+0.conf"), testutil.FileEquals, `title Ubuntu Core, trying pc-kernel_2.snap
+sort-key snapd-1-try
+efi /EFI/ubuntu/pc-kernel_2.snap/kernel.efi
+options snapd_recovery_mode=run static=1
+`)
+	m, err = sb.GetBootVars("kernel_status")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{"kernel_status": ""})
+
+	c.Assert(sb.SetBootVars(map[string]string{"kernel_status": "try"}), IsNil)
+	c.Check(s.entries(c), DeepEquals, []string{"snapd-run.conf", "snapd-try+1.conf"})
+	m, err = sb.GetBootVars("kernel_status")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{"kernel_status": "try"})
+
+	// enabling another try kernel keeps the counter
+	c.Assert(sb.EnableTryKernel(tryKernel), IsNil)
+	c.Check(s.entries(c), DeepEquals, []string{"snapd-run.conf", "snapd-try+1.conf"})
+
+	// systemd-boot decrements the counter when booting the entry
+	err = os.Rename(filepath.Join(s.rootdir, "loader/entries/snapd-try+1.conf"),
+		filepath.Join(s.rootdir, "loader/entries/snapd-try+0-1.conf"))
+	c.Assert(err, IsNil)
+	m, err = sb.GetBootVars("kernel_status")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{"kernel_status": "trying"})
+
+	k, err := sb.TryKernel()
+	c.Assert(err, IsNil)
+	c.Check(k.Filename(), Equals, "pc-kernel_2.snap")
+
+	// mark successful
+	c.Assert(sb.SetBootVars(map[string]string{"kernel_status": ""}), IsNil)
+	c.Check(s.entries(c), DeepEquals, []string{"snapd-run.conf", "snapd-try+0.conf"})
+	c.Assert(sb.EnableKernel(tryKernel), IsNil)
+	c.Assert(sb.DisableTryKernel(), IsNil)
+	c.Check(s.entries(c), DeepEquals, []string{"snapd-run.conf"})
+	k, err = sb.Kernel()
+	c.Assert(err, IsNil)
+	c.Check(k.Filename(), Equals, "pc-kernel_2.snap")
+
+	// disabling is idempotent
+	c.Assert(sb.DisableTryKernel(), IsNil)
+}
+
+func (s *systemdBootTestSuite) TestTryKernelMissingImage(c *C) {
+	sb := s.runBootloader(c)
+
+	tryKernel := s.makeKernelAssetSnap(c, "pc-kernel_2.snap")
+	c.Assert(sb.EnableTryKernel(tryKernel), IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(s.rootdir, "EFI/ubuntu/pc-kernel_2.snap")), IsNil)
+
+	_, err := sb.TryKernel()
+	c.Assert(err, ErrorMatches, `cannot find kernel image /EFI/ubuntu/pc-kernel_2.snap/kernel.efi of loader entry snapd-try\+0.conf`)
+}
+
+func (s *systemdBootTestSuite) TestRecoveryEntry(c *C) {
+	s.mockLoaderConf(c, mockSystemdBootLoaderConf)
+	rbl := bootloader.NewSystemdBoot(s.rootdir, &bootloader.Options{Role: bootloader.RoleRecovery})
+
+	err := rbl.SetBootVars(map[string]string{
+		"snapd_recovery_mode":   "install",
+		"snapd_recovery_system": "20210301",
+	})
+	c.Assert(err, IsNil)
+	entry := filepath.Join(s.rootdir, "loader/entries/snapd-recovery.conf")
+	c.Check(entry, testutil.FileEquals, `title Ubuntu Core install (20210301)
+sort-key snapd-0-recovery
+efi /systems/20210301/kernel.efi
+options snapd_recovery_mode=install snapd_recovery_system=20210301 static=1
+`)
+
+	// the recovery system environment carries the command line
+	err = rbl.SetRecoverySystemEnv("/systems/20210301", map[string]string{
+		"snapd_recovery_kernel":    "/snaps/pc-kernel_1.snap",
+		"snapd_extra_cmdline_args": "extra=1",
+	})
+	c.Assert(err, IsNil)
+	c.Check(entry, testutil.FileContains,
+		"options snapd_recovery_mode=install snapd_recovery_system=20210301 static=1 extra=1\n")
+	v, err := rbl.GetRecoverySystemEnv("/systems/20210301", "snapd_recovery_kernel")
+	c.Assert(err, IsNil)
+	c.Check(v, Equals, "/snaps/pc-kernel_1.snap")
+
+	// other systems do not affect the entry
+	err = rbl.SetRecoverySystemEnv("/systems/20210401", map[string]string{
+		"snapd_extra_cmdline_args": "other=1",
+	})
+	c.Assert(err, IsNil)
+	c.Check(entry, testutil.FileContains, "static=1 extra=1\n")
+
+	m, err := rbl.GetBootVars("snapd_recovery_mode", "snapd_recovery_system")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snapd_recovery_mode":   "install",
+		"snapd_recovery_system": "20210301",
+	})
+
+	// run mode entries come from the boot partition
+	err = rbl.SetBootVars(map[string]string{"snapd_recovery_mode": "run"})
+	c.Assert(err, IsNil)
+	c.Check(entry, testutil.FileAbsent)
+
+	err = rbl.SetBootVars(map[string]string{"snapd_recovery_mode": "recover", "snapd_recovery_system": ""})
+	c.Assert(err, ErrorMatches, `cannot boot "recover" mode without a recovery system`)
+}
+
+func (s *systemdBootTestSuite) TestUpdateBootConfig(c *C) {
+	restore := assets.MockInternal("systemd-boot-loader.conf", []byte("# Snapd-Boot-Config-Edition: 2\ntimeout 0\n"))
+	defer restore()
+
+	sb := s.runBootloader(c)
+	kernel := s.makeKernelAssetSnap(c, "pc-kernel_1.snap")
+	c.Assert(sb.EnableKernel(kernel), IsNil)
+	c.Check(filepath.Join(s.rootdir, "loader/entries/snapd-run.conf"), testutil.FileContains,
+		"options snapd_recovery_mode=run static=1\n")
+
+	tbl, ok := sb.(bootloader.TrustedAssetsBootloader)
+	c.Assert(ok, Equals, true)
+	c.Check(tbl.ManagedAssets(), DeepEquals, []string{"loader/loader.conf"})
+
+	cmdline, err := tbl.CandidateCommandLine(bootloader.CommandLineComponents{ModeArg: "snapd_recovery_mode=run"})
+	c.Assert(err, IsNil)
+	c.Check(cmdline, Equals, "snapd_recovery_mode=run static=2")
+
+	updated, err := tbl.UpdateBootConfig()
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, true)
+	// the entries use the new static command line
+	c.Check(filepath.Join(s.rootdir, "loader/entries/snapd-run.conf"), testutil.FileContains,
+		"options snapd_recovery_mode=run static=2\n")
+
+	cmdline, err = tbl.CommandLine(bootloader.CommandLineComponents{ModeArg: "snapd_recovery_mode=run", ExtraArgs: "extra=1"})
+	c.Assert(err, IsNil)
+	c.Check(cmdline, Equals, "snapd_recovery_mode=run static=2 extra=1")
+
+	updated, err = tbl.UpdateBootConfig()
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, false)
+}
+
+func (s *systemdBootTestSuite) TestTrustedAssetsAndBootChains(c *C) {
+	s.mockLoaderConf(c, mockSystemdBootLoaderConf)
+	rbl := bootloader.NewSystemdBoot(s.rootdir, &bootloader.Options{Role: bootloader.RoleRecovery})
+	tab, ok := rbl.(bootloader.TrustedAssetsBootloader)
+	c.Assert(ok, Equals, true)
+	runBl := bootloader.NewSystemdBoot(s.rootdir, &bootloader.Options{Role: bootloader.RoleRunMode, NoSlashBoot: true})
+	runTab := runBl.(bootloader.TrustedAssetsBootloader)
+
+	ta, err := tab.TrustedAssets()
+	c.Assert(err, IsNil)
+	c.Check(ta, DeepEquals, []string{"EFI/boot/bootx64.efi", "EFI/systemd/systemd-bootx64.efi"})
+	ta, err = runTab.TrustedAssets()
+	c.Assert(err, IsNil)
+	c.Check(ta, HasLen, 0)
+
+	_, err = bootloader.NewSystemdBoot(s.rootdir, nil).(bootloader.TrustedAssetsBootloader).TrustedAssets()
+	c.Assert(err, ErrorMatches, "internal error: trusted assets called without native host-partition layout")
+
+	chain, err := tab.RecoveryBootChain("kernel.snap")
+	c.Assert(err, IsNil)
+	c.Check(chain, DeepEquals, []bootloader.BootFile{
+		{Path: "EFI/boot/bootx64.efi", Role: bootloader.RoleRecovery},
+		{Path: "EFI/systemd/systemd-bootx64.efi", Role: bootloader.RoleRecovery},
+		{Snap: "kernel.snap", Path: "kernel.efi", Role: bootloader.RoleRecovery},
+	})
+
+	chain, err = tab.BootChain(runBl, "kernel.snap")
+	c.Assert(err, IsNil)
+	c.Check(chain, DeepEquals, []bootloader.BootFile{
+		{Path: "EFI/boot/bootx64.efi", Role: bootloader.RoleRecovery},
+		{Path: "EFI/systemd/systemd-bootx64.efi", Role: bootloader.RoleRecovery},
+		{Snap: "kernel.snap", Path: "kernel.efi", Role: bootloader.RoleRunMode},
+	})
+
+	_, err = runTab.RecoveryBootChain("kernel.snap")
+	c.Check(err, ErrorMatches, "not a recovery bootloader")
+	_, err = runTab.BootChain(runBl, "kernel.snap")
+	c.Check(err, ErrorMatches, "not a recovery bootloader")
+	_, err = tab.BootChain(bootloader.NewGrub(s.rootdir, nil), "kernel.snap")
+	c.Check(err, ErrorMatches, "run mode bootloader must be systemd-boot")
+}
@@ -55,6 +55,9 @@ type AppInfo struct {
 	Active      bool             `json:"active,omitempty"`
 	CommonID    string           `json:"common-id,omitempty"`
 	Activators  []AppActivator   `json:"activators,omitempty"`
+	// Environment holds the environment overrides set by the
+	// administrator for the app.
+	Environment map[string]string `json:"environment,omitempty"`
 }
 
 // IsService returns true if the application is a background daemon.
@@ -265,3 +268,23 @@ func (client *Client) Restart(names []string, opts RestartOptions) (changeID str
 	}
 	return client.doAsync("POST", "/v2/apps", nil, nil, bytes.NewReader(buf))
 }
+
+// AppEnvironmentChange describes environment overrides to set and unset for
+// an app of a snap.
+type AppEnvironmentChange struct {
+	App   string            `json:"app"`
+	Set   map[string]string `json:"set,omitempty"`
+	Unset []string          `json:"unset,omitempty"`
+}
+
+// SetAppEnvironment sets and unsets environment overrides for an app of the
+// given snap. The overrides apply the next time the app, or the service, is
+// started.
+func (client *Client) SetAppEnvironment(snapName string, change *AppEnvironmentChange) error {
+	buf, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	_, err = client.doSync("PUT", "/v2/snaps/"+snapName+"/env", nil, nil, bytes.NewReader(buf), nil)
+	return err
+}
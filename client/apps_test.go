@@ -402,3 +402,24 @@ func (cs *clientSuite) TestClientServiceRestart(c *check.C) {
 		}
 	}
 }
+
+func (cs *clientSuite) TestClientSetAppEnvironment(c *check.C) {
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": null}`
+
+	err := cs.cli.SetAppEnvironment("foo", &client.AppEnvironmentChange{
+		App:   "svc",
+		Set:   map[string]string{"LOG_LEVEL": "debug"},
+		Unset: []string{"HTTP_PROXY"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "PUT")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/foo/env")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"app":   "svc",
+		"set":   map[string]interface{}{"LOG_LEVEL": "debug"},
+		"unset": []interface{}{"HTTP_PROXY"},
+	})
+}
@@ -747,3 +747,38 @@ func (client *Client) SystemRecoveryKeys(result interface{}) error {
 	_, err := client.doSync("GET", "/v2/system-recovery-keys", nil, nil, nil, &result)
 	return err
 }
+
+func (client *Client) systemRecoveryKeysAction(action, passphrase string) error {
+	req := struct {
+		Action     string `json:"action"`
+		Passphrase string `json:"passphrase,omitempty"`
+	}{
+		Action:     action,
+		Passphrase: passphrase,
+	}
+	body, err := json.Marshal(&req)
+	if err != nil {
+		return err
+	}
+	_, err = client.doSync("POST", "/v2/system-recovery-keys", nil, nil, bytes.NewReader(body), nil)
+	return err
+}
+
+// RotateSystemRecoveryKeys replaces the recovery keys of the encrypted
+// partitions with new ones, which can then be obtained with
+// SystemRecoveryKeys.
+func (client *Client) RotateSystemRecoveryKeys() error {
+	return client.systemRecoveryKeysAction("rotate", "")
+}
+
+// AddSystemRecoveryPassphrase adds a passphrase which can be used to unlock
+// the encrypted partitions.
+func (client *Client) AddSystemRecoveryPassphrase(passphrase string) error {
+	return client.systemRecoveryKeysAction("add-passphrase", passphrase)
+}
+
+// RemoveSystemRecoveryPassphrase removes a passphrase previously added with
+// AddSystemRecoveryPassphrase.
+func (client *Client) RemoveSystemRecoveryPassphrase(passphrase string) error {
+	return client.systemRecoveryKeysAction("remove-passphrase", passphrase)
+}
@@ -641,3 +641,44 @@ func (cs *clientSuite) TestClientSystemRecoveryKeys(c *C) {
 	c.Check(cs.reqs[0].URL.Path, Equals, "/v2/system-recovery-keys")
 	c.Check(key.RecoveryKey, Equals, "42")
 }
+
+func (cs *clientSuite) TestClientRotateSystemRecoveryKeys(c *C) {
+	cs.rsp = `{"type":"sync", "result":null}`
+
+	err := cs.cli.RotateSystemRecoveryKeys()
+	c.Assert(err, IsNil)
+	c.Check(cs.reqs, HasLen, 1)
+	c.Check(cs.reqs[0].Method, Equals, "POST")
+	c.Check(cs.reqs[0].URL.Path, Equals, "/v2/system-recovery-keys")
+	data, err := ioutil.ReadAll(cs.reqs[0].Body)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"action":"rotate"}`)
+}
+
+func (cs *clientSuite) TestClientSystemRecoveryPassphrase(c *C) {
+	cs.rsp = `{"type":"sync", "result":null}`
+
+	err := cs.cli.AddSystemRecoveryPassphrase("foo")
+	c.Assert(err, IsNil)
+	err = cs.cli.RemoveSystemRecoveryPassphrase("bar")
+	c.Assert(err, IsNil)
+	c.Check(cs.reqs, HasLen, 2)
+	for i, expected := range []string{
+		`{"action":"add-passphrase","passphrase":"foo"}`,
+		`{"action":"remove-passphrase","passphrase":"bar"}`,
+	} {
+		c.Check(cs.reqs[i].Method, Equals, "POST")
+		c.Check(cs.reqs[i].URL.Path, Equals, "/v2/system-recovery-keys")
+		data, err := ioutil.ReadAll(cs.reqs[i].Body)
+		c.Assert(err, IsNil)
+		c.Check(string(data), Equals, expected)
+	}
+}
+
+func (cs *clientSuite) TestClientRotateSystemRecoveryKeysError(c *C) {
+	cs.status = 500
+	cs.rsp = `{"type":"error", "result":{"message":"cannot rotate recovery keys: boom"}}`
+
+	err := cs.cli.RotateSystemRecoveryKeys()
+	c.Assert(err, ErrorMatches, "cannot rotate recovery keys: boom")
+}
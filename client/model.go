@@ -24,15 +24,49 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/url"
+	"os"
+	"path/filepath"
 
 	"golang.org/x/xerrors"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/snap"
 )
 
 type remodelData struct {
 	NewModel string `json:"new-model"`
+	DryRun   bool   `json:"dry-run,omitempty"`
+}
+
+// RemodelPlan describes what a remodel would do, without doing it.
+type RemodelPlan struct {
+	// Kind is the kind of remodel, e.g. "store switch remodel".
+	Kind string `json:"kind"`
+	// Snaps are the snaps required by the new model and what the remodel
+	// would do with them.
+	Snaps []PlannedRemodelSnap `json:"snaps"`
+	// StoreChecked is true if the availability of the snaps was checked
+	// with the store of the new model.
+	StoreChecked bool `json:"store-checked"`
+	// Tasks are the tasks that would make up the remodel change, in order.
+	Tasks          []PlannedTask `json:"tasks"`
+	RebootRequired bool          `json:"reboot-required"`
+	// Problems are the reasons why the remodel is expected to fail.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// PlannedRemodelSnap describes what a remodel would do with a snap
+// required by the new model.
+type PlannedRemodelSnap struct {
+	Name        string        `json:"name"`
+	Role        string        `json:"role"`
+	Channel     string        `json:"channel"`
+	Action      string        `json:"action"`
+	Revision    snap.Revision `json:"revision"`
+	Unavailable string        `json:"unavailable,omitempty"`
 }
 
 // Remodel tries to remodel the system with the given assertion data
@@ -50,6 +84,89 @@ func (client *Client) Remodel(b []byte) (changeID string, err error) {
 	return client.doAsync("POST", "/v2/model", nil, headers, bytes.NewReader(data))
 }
 
+// RemodelOffline tries to remodel the system with the given assertion
+// data, using the snap files at snapPaths and the assertions in the files
+// at assertPaths instead of fetching the snaps from the store.
+func (client *Client) RemodelOffline(b []byte, snapPaths, assertPaths []string) (changeID string, err error) {
+	var files []*os.File
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	paths := make([]string, 0, len(assertPaths)+len(snapPaths))
+	paths = append(paths, assertPaths...)
+	paths = append(paths, snapPaths...)
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			closeAll()
+			return "", fmt.Errorf("cannot open: %q", path)
+		}
+		files = append(files, f)
+	}
+	assertFiles, snapFiles := files[:len(assertPaths)], files[len(assertPaths):]
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		defer closeAll()
+		pw.CloseWithError(sendRemodelFiles(b, snapFiles, assertFiles, mw))
+	}()
+
+	headers := map[string]string{
+		"Content-Type": mw.FormDataContentType(),
+	}
+
+	_, changeID, err = client.doAsyncFull("POST", "/v2/model", nil, headers, pr, doNoTimeoutAndRetry)
+	return changeID, err
+}
+
+func sendRemodelFiles(model []byte, snapFiles, assertFiles []*os.File, mw *multipart.Writer) error {
+	if err := mw.WriteField("new-model", string(model)); err != nil {
+		return err
+	}
+	for _, field := range []struct {
+		name  string
+		files []*os.File
+	}{
+		{"assertion", assertFiles},
+		{"snap", snapFiles},
+	} {
+		for _, f := range field.files {
+			fw, err := mw.CreateFormFile(field.name, filepath.Base(f.Name()))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(fw, f); err != nil {
+				return err
+			}
+		}
+	}
+	return mw.Close()
+}
+
+// PlanRemodel returns what remodeling the system with the given assertion
+// data would do, without remodeling anything.
+func (client *Client) PlanRemodel(b []byte) (*RemodelPlan, error) {
+	data, err := json.Marshal(&remodelData{
+		NewModel: string(b),
+		DryRun:   true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal remodel data: %v", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	var plan RemodelPlan
+	if _, err := client.doSync("POST", "/v2/model", nil, headers, bytes.NewReader(data), &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
 // CurrentModelAssertion returns the current model assertion
 func (client *Client) CurrentModelAssertion() (*asserts.Model, error) {
 	assert, err := currentAssertion(client, "/v2/model")
@@ -23,12 +23,17 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"path/filepath"
 
 	"golang.org/x/xerrors"
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
 )
 
 const happyModelAssertionResponse = `type: model
@@ -129,6 +134,103 @@ func (cs *clientSuite) TestClientRemodel(c *C) {
 	c.Check(jsonBody["new-model"], Equals, string(remodelJsonData))
 }
 
+func (cs *clientSuite) TestClientRemodelOffline(c *C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": {},
+		"change": "d728"
+	}`
+	dir := c.MkDir()
+	snapPath := filepath.Join(dir, "foo.snap")
+	c.Assert(ioutil.WriteFile(snapPath, []byte("snap-data"), 0644), IsNil)
+	assertPath := filepath.Join(dir, "foo.assert")
+	c.Assert(ioutil.WriteFile(assertPath, []byte("assertion-data"), 0644), IsNil)
+
+	id, err := cs.cli.RemodelOffline([]byte("some-model"), []string{snapPath}, []string{assertPath})
+	c.Assert(err, IsNil)
+	c.Check(id, Equals, "d728")
+	c.Check(cs.req.Method, Equals, "POST")
+	c.Check(cs.req.URL.Path, Equals, "/v2/model")
+
+	mediaType, params, err := mime.ParseMediaType(cs.req.Header.Get("Content-Type"))
+	c.Assert(err, IsNil)
+	c.Check(mediaType, Equals, "multipart/form-data")
+	form, err := multipart.NewReader(cs.req.Body, params["boundary"]).ReadForm(1024)
+	c.Assert(err, IsNil)
+	defer form.RemoveAll()
+	c.Check(form.Value, DeepEquals, map[string][]string{
+		"new-model": {"some-model"},
+	})
+	for field, content := range map[string]string{
+		"snap":      "snap-data",
+		"assertion": "assertion-data",
+	} {
+		c.Assert(form.File[field], HasLen, 1)
+		f, err := form.File[field][0].Open()
+		c.Assert(err, IsNil)
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		c.Assert(err, IsNil)
+		c.Check(string(data), Equals, content)
+	}
+	c.Check(form.File["snap"][0].Filename, Equals, "foo.snap")
+}
+
+func (cs *clientSuite) TestClientRemodelOfflineMissingFile(c *C) {
+	_, err := cs.cli.RemodelOffline([]byte("some-model"), []string{"/no/such/file.snap"}, nil)
+	c.Assert(err, ErrorMatches, `cannot open: "/no/such/file.snap"`)
+}
+
+func (cs *clientSuite) TestClientPlanRemodel(c *C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"kind": "store switch remodel",
+			"snaps": [
+				{"name": "pc", "role": "gadget", "channel": "20", "action": "refresh", "revision": "3"},
+				{"name": "foo", "role": "required", "channel": "stable", "action": "install", "revision": "unset", "unavailable": "snap not found"}
+			],
+			"store-checked": true,
+			"tasks": [
+				{"id": "1", "kind": "set-model", "summary": "Set new model assertion"}
+			],
+			"reboot-required": true,
+			"problems": ["cannot get snap \"foo\" from the store: snap not found"]
+		}
+	}`
+	remodelJsonData := []byte(`{"new-model": "some-model"}`)
+	plan, err := cs.cli.PlanRemodel(remodelJsonData)
+	c.Assert(err, IsNil)
+	c.Check(cs.req.Method, Equals, "POST")
+	c.Check(cs.req.URL.Path, Equals, "/v2/model")
+	c.Check(plan, DeepEquals, &client.RemodelPlan{
+		Kind: "store switch remodel",
+		Snaps: []client.PlannedRemodelSnap{
+			{Name: "pc", Role: "gadget", Channel: "20", Action: "refresh", Revision: snap.R(3)},
+			{Name: "foo", Role: "required", Channel: "stable", Action: "install", Unavailable: "snap not found"},
+		},
+		StoreChecked: true,
+		Tasks: []client.PlannedTask{
+			{ID: "1", Kind: "set-model", Summary: "Set new model assertion"},
+		},
+		RebootRequired: true,
+		Problems:       []string{`cannot get snap "foo" from the store: snap not found`},
+	})
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, IsNil)
+	var jsonBody map[string]interface{}
+	err = json.Unmarshal(body, &jsonBody)
+	c.Assert(err, IsNil)
+	c.Check(jsonBody, DeepEquals, map[string]interface{}{
+		"new-model": string(remodelJsonData),
+		"dry-run":   true,
+	})
+}
+
 func (cs *clientSuite) TestClientGetModelHappy(c *C) {
 	cs.status = 200
 	cs.rsp = happyModelAssertionResponse
@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/snapcore/snapd/gadget/quantity"
 	"golang.org/x/xerrors"
@@ -46,12 +47,21 @@ type QuotaGroupResult struct {
 }
 
 type QuotaValues struct {
-	Memory quantity.Size `json:"memory,omitempty"`
+	Memory  quantity.Size       `json:"memory,omitempty"`
+	Journal *QuotaJournalValues `json:"journal,omitempty"`
+}
+
+// QuotaJournalValues are the limits of the journal namespace of a quota group.
+// Empty values remove the journal limits of an existing quota group.
+type QuotaJournalValues struct {
+	Size       quantity.Size `json:"size,omitempty"`
+	RateCount  int           `json:"rate-count,omitempty"`
+	RatePeriod time.Duration `json:"rate-period,omitempty"`
 }
 
 // EnsureQuota creates a quota group or updates an existing group.
-// The list of snaps can be empty.
-func (client *Client) EnsureQuota(groupName string, parent string, snaps []string, maxMemory quantity.Size) (changeID string, err error) {
+// The list of snaps can be empty, and the journal limits are optional.
+func (client *Client) EnsureQuota(groupName string, parent string, snaps []string, maxMemory quantity.Size, journal *QuotaJournalValues) (changeID string, err error) {
 	if groupName == "" {
 		return "", xerrors.Errorf("cannot create or update quota group without a name")
 	}
@@ -63,7 +73,8 @@ func (client *Client) EnsureQuota(groupName string, parent string, snaps []strin
 		Parent:    parent,
 		Snaps:     snaps,
 		Constraints: &QuotaValues{
-			Memory: maxMemory,
+			Memory:  maxMemory,
+			Journal: journal,
 		},
 	}
 
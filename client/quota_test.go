@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -32,7 +33,7 @@ import (
 )
 
 func (cs *clientSuite) TestCreateQuotaGroupInvalidName(c *check.C) {
-	_, err := cs.cli.EnsureQuota("", "", nil, 0)
+	_, err := cs.cli.EnsureQuota("", "", nil, 0, nil)
 	c.Check(err, check.ErrorMatches, `cannot create or update quota group without a name`)
 }
 
@@ -44,7 +45,7 @@ func (cs *clientSuite) TestEnsureQuotaGroup(c *check.C) {
 		"change": "42"
 	}`
 
-	chgID, err := cs.cli.EnsureQuota("foo", "bar", []string{"snap-a", "snap-b"}, 1001)
+	chgID, err := cs.cli.EnsureQuota("foo", "bar", []string{"snap-a", "snap-b"}, 1001, nil)
 	c.Assert(err, check.IsNil)
 	c.Assert(chgID, check.Equals, "42")
 	c.Check(cs.req.Method, check.Equals, "POST")
@@ -65,10 +66,45 @@ func (cs *clientSuite) TestEnsureQuotaGroup(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestEnsureQuotaGroupWithJournal(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"change": "42"
+	}`
+
+	journal := &client.QuotaJournalValues{
+		Size:       quantity.SizeMiB,
+		RateCount:  10,
+		RatePeriod: time.Second,
+	}
+	chgID, err := cs.cli.EnsureQuota("foo", "", nil, 1001, journal)
+	c.Assert(err, check.IsNil)
+	c.Assert(chgID, check.Equals, "42")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]interface{}
+	err = jsonutil.DecodeWithNumber(bytes.NewReader(body), &req)
+	c.Assert(err, check.IsNil)
+	c.Assert(req, check.DeepEquals, map[string]interface{}{
+		"action":     "ensure",
+		"group-name": "foo",
+		"constraints": map[string]interface{}{
+			"memory": json.Number("1001"),
+			"journal": map[string]interface{}{
+				"size":        json.Number("1048576"),
+				"rate-count":  json.Number("10"),
+				"rate-period": json.Number("1000000000"),
+			},
+		},
+	})
+}
+
 func (cs *clientSuite) TestEnsureQuotaGroupError(c *check.C) {
 	cs.status = 500
 	cs.rsp = `{"type": "error"}`
-	_, err := cs.cli.EnsureQuota("foo", "bar", []string{"snap-a"}, 1)
+	_, err := cs.cli.EnsureQuota("foo", "bar", []string{"snap-a"}, 1, nil)
 	c.Check(err, check.ErrorMatches, `server error: "Internal Server Error"`)
 }
 
@@ -28,6 +28,8 @@ import (
 	"mime/multipart"
 	"os"
 	"path/filepath"
+
+	"github.com/snapcore/snapd/snap"
 )
 
 type SnapOptions struct {
@@ -86,12 +88,17 @@ type actionData struct {
 	Name     string `json:"name,omitempty"`
 	SnapPath string `json:"snap-path,omitempty"`
 	*SnapOptions
+
+	// only used when sideloading
+	Delta     bool   `json:"-"`
+	DeltaFrom string `json:"-"`
 }
 
 type multiActionData struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+	DryRun bool     `json:"dry-run,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -211,6 +218,65 @@ func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, o
 	return client.doAsyncFull("POST", "/v2/snaps", nil, headers, bytes.NewBuffer(data), nil)
 }
 
+// RefreshPlan describes what a refresh would do, without doing it.
+type RefreshPlan struct {
+	// Snaps are the snaps that would be refreshed.
+	Snaps []string `json:"snaps"`
+	// Downloads are the snaps that would be downloaded, including
+	// prerequisites that are not installed yet.
+	Downloads         []PlannedDownload `json:"downloads"`
+	TotalDownloadSize int64             `json:"total-download-size"`
+	// Tasks are the tasks that would make up the refresh change, in order.
+	Tasks []PlannedTask `json:"tasks"`
+	// AffectedSnaps maps the snaps with a gate-auto-refresh hook that
+	// are affected by the refresh to the snaps affecting them.
+	AffectedSnaps  map[string][]string `json:"affected-snaps,omitempty"`
+	RebootRequired bool                `json:"reboot-required"`
+}
+
+// PlannedDownload describes a snap that would be downloaded by a refresh.
+type PlannedDownload struct {
+	Snap         string        `json:"snap"`
+	Revision     snap.Revision `json:"revision"`
+	Channel      string        `json:"channel,omitempty"`
+	Size         int64         `json:"size"`
+	Prerequisite bool          `json:"prerequisite,omitempty"`
+}
+
+// PlannedTask describes a task that would be part of a refresh, with the
+// ids of the tasks it would wait for.
+type PlannedTask struct {
+	ID        string   `json:"id"`
+	Kind      string   `json:"kind"`
+	Summary   string   `json:"summary"`
+	Snap      string   `json:"snap,omitempty"`
+	WaitTasks []string `json:"wait-tasks,omitempty"`
+}
+
+// PlanRefresh returns what refreshing the given snaps (or all snaps if
+// names is empty) would do, without refreshing anything.
+func (client *Client) PlanRefresh(names []string) (*RefreshPlan, error) {
+	action := multiActionData{
+		Action: "refresh",
+		Snaps:  names,
+		DryRun: true,
+	}
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal multi-snap action: %s", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	var plan RefreshPlan
+	if _, err := client.doSync("POST", "/v2/snaps", nil, headers, bytes.NewBuffer(data), &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
 // InstallPath sideloads the snap with the given path under optional provided name,
 // returning the UUID of the background operation upon success.
 func (client *Client) InstallPath(path, name string, options *SnapOptions) (changeID string, err error) {
@@ -238,6 +304,40 @@ func (client *Client) InstallPath(path, name string, options *SnapOptions) (chan
 	return changeID, err
 }
 
+// InstallDelta sideloads the snap obtained by applying the delta with the
+// given path to the installed revision fromRevision of the named snap, or
+// to its current revision if fromRevision is empty, returning the UUID of
+// the background operation upon success.
+func (client *Client) InstallDelta(deltaPath, name, fromRevision string, options *SnapOptions) (changeID string, err error) {
+	if name == "" {
+		return "", fmt.Errorf("cannot install from a delta without a snap name")
+	}
+	f, err := os.Open(deltaPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot open: %q", deltaPath)
+	}
+
+	action := actionData{
+		Action:      "install",
+		Name:        name,
+		SnapPath:    deltaPath,
+		SnapOptions: options,
+		Delta:       true,
+		DeltaFrom:   fromRevision,
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go sendSnapFile(deltaPath, f, pw, mw, &action)
+
+	headers := map[string]string{
+		"Content-Type": mw.FormDataContentType(),
+	}
+
+	_, changeID, err = client.doAsyncFull("POST", "/v2/snaps", nil, headers, pr, doNoTimeoutAndRetry)
+	return changeID, err
+}
+
 // Try
 func (client *Client) Try(path string, options *SnapOptions) (changeID string, err error) {
 	if options == nil {
@@ -275,6 +375,7 @@ func sendSnapFile(snapPath string, snapFile *os.File, pw *io.PipeWriter, mw *mul
 		{"name", action.Name},
 		{"snap-path", action.SnapPath},
 		{"channel", action.Channel},
+		{"delta-from", action.DeltaFrom},
 	}
 	for _, s := range fields {
 		if s.value == "" {
@@ -296,6 +397,11 @@ func sendSnapFile(snapPath string, snapFile *os.File, pw *io.PipeWriter, mw *mul
 		return
 	}
 
+	if err := writeFieldBool(mw, "delta", action.Delta); err != nil {
+		pw.CloseWithError(err)
+		return
+	}
+
 	fw, err := mw.CreateFormFile("snap", filepath.Base(snapPath))
 	if err != nil {
 		pw.CloseWithError(err)
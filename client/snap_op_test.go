@@ -33,6 +33,7 @@ import (
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
 )
 
 var chanName = "achan"
@@ -186,6 +187,54 @@ func (cs *clientSuite) TestClientMultiOpSnap(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientPlanRefresh(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {
+			"snaps": ["foo"],
+			"downloads": [
+				{"snap": "foo", "revision": "2", "channel": "stable", "size": 10},
+				{"snap": "core18", "revision": "5", "size": 20, "prerequisite": true}
+			],
+			"total-download-size": 30,
+			"tasks": [
+				{"id": "1", "kind": "prerequisites", "summary": "Ensure prerequisites", "snap": "foo"},
+				{"id": "2", "kind": "download-snap", "summary": "Download", "snap": "foo", "wait-tasks": ["1"]}
+			],
+			"reboot-required": true
+		}
+	}`
+	plan, err := cs.cli.PlanRefresh([]string{"foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(plan, check.DeepEquals, &client.RefreshPlan{
+		Snaps: []string{"foo"},
+		Downloads: []client.PlannedDownload{
+			{Snap: "foo", Revision: snap.R(2), Channel: "stable", Size: 10},
+			{Snap: "core18", Revision: snap.R(5), Size: 20, Prerequisite: true},
+		},
+		TotalDownloadSize: 30,
+		Tasks: []client.PlannedTask{
+			{ID: "1", Kind: "prerequisites", Summary: "Ensure prerequisites", Snap: "foo"},
+			{ID: "2", Kind: "download-snap", Summary: "Download", Snap: "foo", WaitTasks: []string{"1"}},
+		},
+		RebootRequired: true,
+	})
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"refresh","snaps":["foo"],"dry-run":true}`)
+}
+
+func (cs *clientSuite) TestClientPlanRefreshError(c *check.C) {
+	cs.status = 400
+	cs.rsp = `{"type": "error", "result": {"message": "boom"}}`
+	_, err := cs.cli.PlanRefresh(nil)
+	c.Check(err, check.ErrorMatches, "boom")
+}
+
 func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 	// Note body is essentially the same as TestClientMultiOpSnap; keep in sync
 	cs.status = 202
@@ -242,6 +291,42 @@ func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	c.Check(id, check.Equals, "66b3")
 }
 
+func (cs *clientSuite) TestClientOpInstallDelta(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"change": "66b3",
+		"status-code": 202,
+		"type": "async"
+	}`
+	bodyData := []byte("delta-data")
+
+	delta := filepath.Join(c.MkDir(), "foo.xdelta3")
+	err := ioutil.WriteFile(delta, bodyData, 0644)
+	c.Assert(err, check.IsNil)
+
+	id, err := cs.cli.InstallDelta(delta, "foo", "7", nil)
+	c.Assert(err, check.IsNil)
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+
+	c.Assert(string(body), check.Matches, "(?s).*\r\ndelta-data\r\n.*")
+	c.Assert(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"action\"\r\n\r\ninstall\r\n.*")
+	c.Assert(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"name\"\r\n\r\nfoo\r\n.*")
+	c.Assert(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"delta\"\r\n\r\ntrue\r\n.*")
+	c.Assert(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"delta-from\"\r\n\r\n7\r\n.*")
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Assert(cs.req.Header.Get("Content-Type"), check.Matches, "multipart/form-data; boundary=.*")
+	c.Check(id, check.Equals, "66b3")
+}
+
+func (cs *clientSuite) TestClientOpInstallDeltaNoName(c *check.C) {
+	_, err := cs.cli.InstallDelta("/some/delta", "", "", nil)
+	c.Assert(err, check.ErrorMatches, "cannot install from a delta without a snap name")
+}
+
 func (cs *clientSuite) TestClientOpInstallPathIgnoreRunning(c *check.C) {
 	cs.status = 202
 	cs.rsp = `{
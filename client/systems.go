@@ -138,3 +138,28 @@ func (client *Client) RebootToSystem(systemLabel, mode string) error {
 	}
 	return nil
 }
+
+// RemoveRecoverySystem issues a request to remove the recovery system with
+// the given label from the seed. The current and the last good recovery
+// system cannot be removed.
+func (client *Client) RemoveRecoverySystem(systemLabel string) (changeID string, err error) {
+	if systemLabel == "" {
+		return "", fmt.Errorf("cannot remove a recovery system without a label")
+	}
+
+	req := struct {
+		Action string `json:"action"`
+	}{
+		Action: "remove",
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(&req); err != nil {
+		return "", err
+	}
+	changeID, err = client.doAsync("POST", "/v2/systems/"+systemLabel, nil, nil, &body)
+	if err != nil {
+		return "", xerrors.Errorf("cannot remove recovery system %q: %v", systemLabel, err)
+	}
+	return changeID, nil
+}
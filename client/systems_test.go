@@ -215,3 +215,44 @@ func (cs *clientSuite) TestRequestSystemRebootErrorWithSystem(c *check.C) {
 	c.Check(cs.req.Method, check.Equals, "POST")
 	c.Check(cs.req.URL.Path, check.Equals, "/v2/systems/1234")
 }
+
+func (cs *clientSuite) TestRemoveRecoverySystemHappy(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+	    "type": "async",
+	    "status-code": 202,
+	    "change": "42"
+	}`
+	chgID, err := cs.cli.RemoveRecoverySystem("20201212")
+	c.Assert(err, check.IsNil)
+	c.Check(chgID, check.Equals, "42")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/systems/20201212")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]interface{}
+	err = json.Unmarshal(body, &req)
+	c.Assert(err, check.IsNil)
+	c.Assert(req, check.DeepEquals, map[string]interface{}{
+		"action": "remove",
+	})
+}
+
+func (cs *clientSuite) TestRemoveRecoverySystemError(c *check.C) {
+	cs.rsp = `{
+	    "type": "error",
+	    "status-code": 400,
+	    "result": {"message": "failed"}
+	}`
+	_, err := cs.cli.RemoveRecoverySystem("1234")
+	c.Assert(err, check.ErrorMatches, `cannot remove recovery system "1234": failed`)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/systems/1234")
+}
+
+func (cs *clientSuite) TestRemoveRecoverySystemNoLabel(c *check.C) {
+	_, err := cs.cli.RemoveRecoverySystem("")
+	c.Assert(err, check.ErrorMatches, `cannot remove a recovery system without a label`)
+	c.Check(cs.req, check.IsNil)
+}
@@ -51,6 +51,7 @@ type postValidationSetData struct {
 	Action   string `json:"action"`
 	Mode     string `json:"mode,omitempty"`
 	Sequence int    `json:"sequence,omitempty"`
+	Refresh  bool   `json:"refresh,omitempty"`
 }
 
 // ForgetValidationSet forgets the given validation set identified by account,
@@ -101,6 +102,34 @@ func (client *Client) ApplyValidationSet(accountID, name string, opts *ValidateA
 	return nil
 }
 
+// RefreshAndEnforceValidationSet enforces the given validation set identified
+// by account, name and optional sequence (if non-zero), after installing,
+// refreshing and removing snaps as needed for the system to conform to it.
+func (client *Client) RefreshAndEnforceValidationSet(accountID, name string, sequence int) (changeID string, err error) {
+	if accountID == "" || name == "" {
+		return "", xerrors.Errorf("cannot enforce validation set without account ID and name")
+	}
+
+	data := &postValidationSetData{
+		Action:   "apply",
+		Mode:     "enforce",
+		Sequence: sequence,
+		Refresh:  true,
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(data); err != nil {
+		return "", err
+	}
+	path := fmt.Sprintf("/v2/validation-sets/%s/%s", accountID, name)
+	changeID, err = client.doAsync("POST", path, nil, nil, &body)
+	if err != nil {
+		fmt := "cannot enforce validation set: %w"
+		return "", xerrors.Errorf(fmt, err)
+	}
+	return changeID, nil
+}
+
 // ListValidationsSets queries all validation sets.
 func (client *Client) ListValidationsSets() ([]*ValidationSetResult, error) {
 	var res []*ValidationSetResult
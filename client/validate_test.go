@@ -117,6 +117,45 @@ func (cs *clientSuite) TestApplyValidationSetInvalidArgs(c *check.C) {
 	c.Assert(err, check.ErrorMatches, `cannot apply validation set without account ID and name`)
 }
 
+func (cs *clientSuite) TestRefreshAndEnforceValidationSet(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"change": "42"
+	}`
+	chgID, err := cs.cli.RefreshAndEnforceValidationSet("foo", "bar", 3)
+	c.Assert(err, check.IsNil)
+	c.Check(chgID, check.Equals, "42")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/validation-sets/foo/bar")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]interface{}
+	err = json.Unmarshal(body, &req)
+	c.Assert(err, check.IsNil)
+	c.Assert(req, check.DeepEquals, map[string]interface{}{
+		"action":   "apply",
+		"mode":     "enforce",
+		"sequence": float64(3),
+		"refresh":  true,
+	})
+}
+
+func (cs *clientSuite) TestRefreshAndEnforceValidationSetError(c *check.C) {
+	cs.status = 500
+	cs.rsp = errorResponseJSON
+	_, err := cs.cli.RefreshAndEnforceValidationSet("foo", "bar", 0)
+	c.Assert(err, check.ErrorMatches, "cannot enforce validation set: failed")
+}
+
+func (cs *clientSuite) TestRefreshAndEnforceValidationSetInvalidArgs(c *check.C) {
+	_, err := cs.cli.RefreshAndEnforceValidationSet("", "bar", 0)
+	c.Assert(err, check.ErrorMatches, `cannot enforce validation set without account ID and name`)
+	_, err = cs.cli.RefreshAndEnforceValidationSet("foo", "", 0)
+	c.Assert(err, check.ErrorMatches, `cannot enforce validation set without account ID and name`)
+}
+
 func (cs *clientSuite) TestForgetValidationSet(c *check.C) {
 	cs.rsp = `{
 		"type": "sync",
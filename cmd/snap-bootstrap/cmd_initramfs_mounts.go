@@ -139,6 +139,8 @@ func generateInitramfsMounts() (err error) {
 		err = generateMountsModeRecover(mst)
 	case "install":
 		err = generateMountsModeInstall(mst)
+	case "factory-reset":
+		err = generateMountsModeFactoryReset(mst)
 	case "run":
 		err = generateMountsModeRun(mst)
 	default:
@@ -202,6 +204,49 @@ func generateMountsModeInstall(mst *initramfsMountsState) error {
 	return nil
 }
 
+func generateMountsModeFactoryReset(mst *initramfsMountsState) error {
+	// steps 1 and 2 are shared with install mode
+	model, snaps, err := generateMountsCommonInstallRecover(mst)
+	if err != nil {
+		return err
+	}
+
+	// 3. unlock and mount ubuntu-save, which keeps the device identity
+	//    across a factory reset, using the fallback key on ubuntu-seed
+	disk, err := disks.DiskFromMountPoint(boot.InitramfsUbuntuSeedDir, nil)
+	if err != nil {
+		return err
+	}
+	unlockOpts := &secboot.UnlockVolumeUsingSealedKeyOptions{
+		AllowRecoveryKey: true,
+		WhichModel: func() (*asserts.Model, error) {
+			return model, nil
+		},
+	}
+	saveFallbackKey := filepath.Join(boot.InitramfsSeedEncryptionKeyDir, "ubuntu-save.recovery.sealed-key")
+	unlockRes, err := secbootUnlockVolumeUsingSealedKeyIfEncrypted(disk, "ubuntu-save", saveFallbackKey, unlockOpts)
+	if err != nil {
+		return fmt.Errorf("cannot unlock ubuntu-save: %v", err)
+	}
+	// TODO: should we fsck ubuntu-save ?
+	if err := doSystemdMount(unlockRes.FsDevice, boot.InitramfsUbuntuSaveDir, nil); err != nil {
+		return err
+	}
+
+	// 4. final step: write modeenv to tmpfs data dir
+	modeEnv, err := mst.EphemeralModeenvForModel(model, snaps)
+	if err != nil {
+		return err
+	}
+	if err := modeEnv.WriteTo(boot.InitramfsWritableDir); err != nil {
+		return err
+	}
+
+	// done, no output, no error indicates to initramfs we are done with
+	// mounting stuff
+	return nil
+}
+
 // copyNetworkConfig copies the network configuration to the target
 // directory. This is used to copy the network configuration
 // data from a real uc20 ubuntu-data partition into a ephemeral one.
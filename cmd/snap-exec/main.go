@@ -193,6 +193,9 @@ func execApp(snapApp, revision, command string, args []string) error {
 	for _, eenv := range app.EnvChain() {
 		env.ExtendWithExpanded(eenv)
 	}
+	// environment overrides set by the administrator, conveyed by snap run,
+	// take precedence over the environment declared by the snap
+	snapenv.UnescapeEnvOverrides(env)
 
 	// strings.Split() is ok here because we validate all app fields and the
 	// whitelist is pretty strict (see snap/validate.go:appContentWhitelist)
@@ -25,6 +25,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	. "gopkg.in/check.v1"
@@ -183,6 +184,35 @@ func (s *snapExecSuite) TestSnapExecAppIntegration(c *C) {
 	c.Check(execEnv, testutil.Contains, "TEST_PATH=/custom")
 }
 
+func (s *snapExecSuite) TestSnapExecAppEnvOverrides(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	snaptest.MockSnap(c, string(mockYaml), &snap.SideInfo{
+		Revision: snap.R("42"),
+	})
+
+	execEnv := []string{}
+	restore := snapExec.MockSyscallExec(func(argv0 string, argv []string, env []string) error {
+		execEnv = env
+		return nil
+	})
+	defer restore()
+
+	// overrides escaped by snap run win over the environment of the app
+	os.Setenv("SNAP_ENV_OVERRIDE_TEST_PATH", "/override")
+	defer os.Unsetenv("SNAP_ENV_OVERRIDE_TEST_PATH")
+	os.Setenv("SNAP_ENV_OVERRIDE_LOG_LEVEL", "debug")
+	defer os.Unsetenv("SNAP_ENV_OVERRIDE_LOG_LEVEL")
+
+	err := snapExec.ExecApp("snapname.app", "42", "stop", nil)
+	c.Assert(err, IsNil)
+	c.Check(execEnv, testutil.Contains, "TEST_PATH=/override")
+	c.Check(execEnv, Not(testutil.Contains), "TEST_PATH=/custom")
+	c.Check(execEnv, testutil.Contains, "LOG_LEVEL=debug")
+	for _, e := range execEnv {
+		c.Check(strings.HasPrefix(e, "SNAP_ENV_OVERRIDE_"), Equals, false, Commentf(e))
+	}
+}
+
 func (s *snapExecSuite) TestSnapExecAppCommandChainIntegration(c *C) {
 	dirs.SetRootDir(c.MkDir())
 	snaptest.MockSnap(c, string(mockYaml), &snap.SideInfo{
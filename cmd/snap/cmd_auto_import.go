@@ -330,13 +330,14 @@ func removableBlockDevices() (removableDevices []string) {
 	return removableDevices
 }
 
-// inInstallmode returns true if it's UC20 system in install mode
+// inInstallmode returns true if it's UC20 system in install or
+// factory-reset mode
 func inInstallMode() bool {
 	mode, _, err := boot.ModeAndRecoverySystemFromKernelCommandLine()
 	if err != nil {
 		return false
 	}
-	return mode == "install"
+	return mode == "install" || mode == "factory-reset"
 }
 
 func (x *cmdAutoImport) Execute(args []string) error {
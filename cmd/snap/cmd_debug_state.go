@@ -20,8 +20,8 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -65,13 +65,13 @@ func loadState(path string) (*state.State, error) {
 	if path == "" {
 		path = "state.json"
 	}
-	r, err := os.Open(path)
+	// take into account the state journal, if any
+	data, err := state.ReadStateData(path)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read the state file: %s", err)
 	}
-	defer r.Close()
 
-	return state.ReadState(nil, r)
+	return state.ReadState(nil, bytes.NewReader(data))
 }
 
 func init() {
@@ -26,6 +26,8 @@ import (
 	. "gopkg.in/check.v1"
 
 	main "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
 )
 
 var stateJSON = []byte(`
@@ -291,3 +293,24 @@ func (s *SnapSuite) TestDebugIsSeededNo(c *C) {
 	c.Check(s.Stdout(), Matches, "false\n")
 	c.Check(s.Stderr(), Equals, "")
 }
+
+func (s *SnapSuite) TestDebugIsSeededJournaledState(c *C) {
+	dir := c.MkDir()
+	stateFile := filepath.Join(dir, "test-state.json")
+	c.Assert(ioutil.WriteFile(stateFile, []byte("{}"), 0644), IsNil)
+
+	j, err := state.OpenJournal(stateFile)
+	c.Assert(err, IsNil)
+	defer j.Close()
+	entries, err := state.SplitEntries([]byte(`{"data":{"seeded":true}}`))
+	c.Assert(err, IsNil)
+	c.Assert(j.Commit(entries), IsNil)
+	// the state file itself is unchanged
+	c.Assert(stateFile, testutil.FileEquals, "{}")
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--is-seeded", stateFile})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Matches, "true\n")
+	c.Check(s.Stderr(), Equals, "")
+}
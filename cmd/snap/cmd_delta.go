@@ -0,0 +1,153 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/snap/snapdelta"
+)
+
+type cmdDelta struct{}
+
+var shortDeltaHelp = i18n.G("Create and install snap deltas")
+var longDeltaHelp = i18n.G(`
+The delta command contains sub-commands to create binary deltas between
+two revisions of a snap, and to install a revision of a snap from such a
+delta and the revision it was created from.
+`)
+
+type cmdDeltaCreate struct {
+	Output     string `long:"output" short:"o"`
+	Positional struct {
+		Source flags.Filename `positional-arg-name:"<old-snap>"`
+		Target flags.Filename `positional-arg-name:"<new-snap>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortDeltaCreateHelp = i18n.G("Create a delta between two snap files")
+var longDeltaCreateHelp = i18n.G(`
+The create command creates a delta that turns the old snap file into the
+new snap file. Both snap files must be revisions of the same snap.
+
+The delta can then be installed with 'snap delta install' on a system that
+has the old revision of the snap installed.
+`)
+
+type cmdDeltaInstall struct {
+	colorMixin
+	waitMixin
+
+	From      string `long:"from"`
+	Dangerous bool   `long:"dangerous"`
+
+	Positional struct {
+		Snap  installedSnapName `positional-arg-name:"<snap>"`
+		Delta flags.Filename    `positional-arg-name:"<delta>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortDeltaInstallHelp = i18n.G("Install a snap from a delta")
+var longDeltaInstallHelp = i18n.G(`
+The install command installs the revision of the given snap obtained by
+applying the delta to the currently installed revision of the snap, or to
+the installed revision given with --from.
+
+Unless --dangerous is given, the reconstructed snap must match the
+snap-revision assertion that was acknowledged for it with 'snap ack'.
+`)
+
+func init() {
+	cmd := addDeltaCommand("create", shortDeltaCreateHelp, longDeltaCreateHelp, func() flags.Commander {
+		return &cmdDeltaCreate{}
+	}, map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"output": i18n.G("Write the delta to this file"),
+	}, nil)
+	cmd.extra = func(cmd *flags.Command) {
+		// TRANSLATORS: this describes the default filename for a delta, e.g. foo_2.xdelta3
+		cmd.FindOptionByLongName("output").DefaultMask = i18n.G("<new-snap>.xdelta3")
+	}
+
+	addDeltaCommand("install", shortDeltaInstallHelp, longDeltaInstallHelp, func() flags.Commander {
+		return &cmdDeltaInstall{}
+	}, colorDescs.also(waitDescs).also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"from": i18n.G("Apply the delta to this installed revision of the snap"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"dangerous": i18n.G("Install the reconstructed snap even if it has no signatures"),
+	}), nil)
+}
+
+func (x *cmdDeltaCreate) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	source := string(x.Positional.Source)
+	target := string(x.Positional.Target)
+	output := x.Output
+	if output == "" {
+		output = strings.TrimSuffix(filepath.Base(target), ".snap") + "." + snapdelta.Format
+	}
+
+	if err := snapdelta.Generate(source, target, output); err != nil {
+		return err
+	}
+
+	// TRANSLATORS: %s is the path to the created delta file
+	fmt.Fprintf(Stdout, i18n.G("created: %s\n"), output)
+	return nil
+}
+
+func (x *cmdDeltaInstall) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	snapName := string(x.Positional.Snap)
+	deltaPath := string(x.Positional.Delta)
+	opts := &client.SnapOptions{Dangerous: x.Dangerous}
+
+	changeID, err := x.client.InstallDelta(deltaPath, snapName, x.From, opts)
+	if err != nil {
+		msg, err := errorToCmdMessage(snapName, err, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(Stderr, msg)
+		return nil
+	}
+
+	if _, err := x.wait(changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	return showDone(x.client, []string{snapName}, "install", opts, x.getEscapes())
+}
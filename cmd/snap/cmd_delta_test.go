@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *SnapSuite) TestDeltaCreate(c *check.C) {
+	cmd := testutil.MockCommand(c, "xdelta3", `for last; do :; done; echo delta > "$last"`)
+	defer cmd.Restore()
+
+	source := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 1", nil)
+	target := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 2", nil)
+
+	cwd, err := os.Getwd()
+	c.Assert(err, check.IsNil)
+	defer os.Chdir(cwd)
+	tmpdir := c.MkDir()
+	c.Assert(os.Chdir(tmpdir), check.IsNil)
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"delta", "create", source, target})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "created: foo_2_all.xdelta3\n")
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(filepath.Join(tmpdir, "foo_2_all.xdelta3"), testutil.FileEquals, "delta\n")
+	c.Check(cmd.Calls(), check.DeepEquals, [][]string{
+		{"xdelta3", "-e", "-9", "-f", "-s", source, target, "foo_2_all.xdelta3.partial"},
+	})
+}
+
+func (s *SnapSuite) TestDeltaCreateOutput(c *check.C) {
+	cmd := testutil.MockCommand(c, "xdelta3", `for last; do :; done; echo delta > "$last"`)
+	defer cmd.Restore()
+
+	source := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 1", nil)
+	target := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 2", nil)
+	output := filepath.Join(c.MkDir(), "foo.delta")
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"delta", "create", "--output", output, source, target})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "created: "+output+"\n")
+	c.Check(output, testutil.FileEquals, "delta\n")
+}
+
+func (s *SnapSuite) TestDeltaCreateDifferentSnaps(c *check.C) {
+	source := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 1", nil)
+	target := snaptest.MakeTestSnapWithFiles(c, "name: bar\nversion: 2", nil)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"delta", "create", source, target})
+	c.Assert(err, check.ErrorMatches, `cannot create delta between different snaps "foo" and "bar"`)
+}
+
+func (s *SnapOpSuite) TestDeltaInstall(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+
+		form := testForm(r, c)
+		defer form.RemoveAll()
+
+		c.Check(form.Value["action"], check.DeepEquals, []string{"install"})
+		c.Check(form.Value["name"], check.DeepEquals, []string{"foo"})
+		c.Check(form.Value["delta"], check.DeepEquals, []string{"true"})
+		c.Check(form.Value["delta-from"], check.DeepEquals, []string{"7"})
+		c.Check(form.Value["dangerous"], check.IsNil)
+		c.Check(form.Value["snap-path"], check.NotNil)
+		c.Check(form.Value, check.HasLen, 5)
+
+		name, _, body := formFile(form, c)
+		c.Check(name, check.Equals, "snap")
+		c.Check(string(body), check.Equals, "delta-data")
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	deltaPath := filepath.Join(c.MkDir(), "foo.xdelta3")
+	err := ioutil.WriteFile(deltaPath, []byte("delta-data"), 0644)
+	c.Assert(err, check.IsNil)
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"delta", "install", "--from", "7", "foo", deltaPath})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo 1.0 from Bar installed`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
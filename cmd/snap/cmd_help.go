@@ -208,7 +208,7 @@ var helpCategories = []helpCategory{
 	}, {
 		Label:       i18n.G("Configuration"),
 		Description: i18n.G("system administration and configuration"),
-		Commands:    []string{"get", "set", "unset", "wait"},
+		Commands:    []string{"get", "set", "unset", "set-env", "unset-env", "wait"},
 	}, {
 		Label:       i18n.G("App Aliases"),
 		Description: i18n.G("manage aliases"),
@@ -247,7 +247,7 @@ var helpCategories = []helpCategory{
 		Label:           i18n.G("Development"),
 		Description:     i18n.G("developer-oriented features"),
 		Commands:        []string{"download", "pack", "run", "try"},
-		AllOnlyCommands: []string{"prepare-image"},
+		AllOnlyCommands: []string{"prepare-image", "delta"},
 	},
 }
 
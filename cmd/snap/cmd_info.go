@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
@@ -533,6 +534,36 @@ func (iw *infoWriter) maybePrintServices() {
 	}
 }
 
+func (iw *infoWriter) maybePrintEnvironment() {
+	if !iw.verbose || iw.localSnap == nil {
+		return
+	}
+
+	var lines []string
+	for _, app := range iw.localSnap.Apps {
+		if len(app.Environment) == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %s:", snap.JoinSnapApp(iw.localSnap.Name, app.Name)))
+		keys := make([]string, 0, len(app.Environment))
+		for key := range app.Environment {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			lines = append(lines, fmt.Sprintf("    %s=%s", key, app.Environment[key]))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Fprintf(iw, "environment:\n")
+	for _, line := range lines {
+		fmt.Fprintln(iw, line)
+	}
+}
+
 func (iw *infoWriter) maybePrintNotes() {
 	if !iw.verbose {
 		return
@@ -739,6 +770,7 @@ func (x *infoCmd) Execute([]string) error {
 		iw.printDescr()
 		iw.maybePrintCommands()
 		iw.maybePrintServices()
+		iw.maybePrintEnvironment()
 		iw.maybePrintNotes()
 		// stops the notes etc trying to be aligned with channels
 		iw.Flush()
@@ -977,6 +977,43 @@ func (infoSuite) TestMaybePrintCohortKey(c *check.C) {
 	}
 }
 
+func (infoSuite) TestMaybePrintEnvironment(c *check.C) {
+	withEnv := &client.Snap{
+		Name: "foo",
+		Apps: []client.AppInfo{
+			{Snap: "foo", Name: "bar"},
+			{Snap: "foo", Name: "svc", Daemon: "simple", Environment: map[string]string{"LOG_LEVEL": "debug", "HTTP_PROXY": "http://proxy:3128"}},
+		},
+	}
+
+	type T struct {
+		snap     *client.Snap
+		verbose  bool
+		expected string
+	}
+	tests := []T{
+		{snap: nil, verbose: true, expected: ""},
+		{snap: &client.Snap{Name: "foo", Apps: []client.AppInfo{{Snap: "foo", Name: "bar"}}}, verbose: true, expected: ""},
+		{snap: withEnv, verbose: false, expected: ""},
+		{snap: withEnv, verbose: true, expected: `environment:
+  foo.svc:
+    HTTP_PROXY=http://proxy:3128
+    LOG_LEVEL=debug
+`},
+	}
+
+	var buf flushBuffer
+	iw := snap.NewInfoWriter(&buf)
+	for i, t := range tests {
+		buf.Reset()
+		snap.SetupSnap(iw, t.snap, nil, nil)
+		snap.SetVerbose(iw, t.verbose)
+		snap.MaybePrintEnvironment(iw)
+		iw.Flush()
+		c.Check(buf.String(), check.Equals, t.expected, check.Commentf("%d", i))
+	}
+}
+
 func (infoSuite) TestMaybePrintHealth(c *check.C) {
 	type T struct {
 		snap     *client.Snap
@@ -22,7 +22,9 @@ package main
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 
@@ -79,6 +81,14 @@ Adding new snaps to a quota group will result in all non-disabled services in
 that snap being restarted.
 
 An existing sub group cannot be moved from one parent to another.
+
+The services of the snaps in a quota group can also be given a journal of their
+own with --journal-size and --journal-rate-limit, so that they cannot evict the
+logs of other services from the system journal. The rate limit is expressed as
+the number of messages allowed per period, for example 100/10s. Setting either
+of them to 0 removes that limit, and once both are removed the services log into
+the system journal again. Sub groups without journal limits of their own log
+into the journal of their parent group.
 `)
 
 func init() {
@@ -99,14 +109,77 @@ func init() {
 type cmdSetQuota struct {
 	waitMixin
 
-	MemoryMax  string `long:"memory" optional:"true"`
-	Parent     string `long:"parent" optional:"true"`
-	Positional struct {
+	MemoryMax        string `long:"memory" optional:"true"`
+	JournalSize      string `long:"journal-size" optional:"true"`
+	JournalRateLimit string `long:"journal-rate-limit" optional:"true"`
+	Parent           string `long:"parent" optional:"true"`
+	Positional       struct {
 		GroupName string              `positional-arg-name:"<group-name>" required:"true"`
 		Snaps     []installedSnapName `positional-arg-name:"<snap>" optional:"true"`
 	} `positional-args:"yes"`
 }
 
+// parseJournalRateLimit parses a journal rate limit of the form
+// <count>/<period>, e.g. 100/10s.
+func parseJournalRateLimit(rateLimit string) (count int, period time.Duration, err error) {
+	parts := strings.SplitN(rateLimit, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("cannot parse journal rate limit %q: expected <count>/<period>", rateLimit)
+	}
+	count, err = strconv.Atoi(parts[0])
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("cannot parse journal rate limit %q: invalid message count %q", rateLimit, parts[0])
+	}
+	period, err = time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return 0, 0, fmt.Errorf("cannot parse journal rate limit %q: invalid period %q", rateLimit, parts[1])
+	}
+	return count, period, nil
+}
+
+// journalValues returns the journal limits to set for the group, the limits
+// that were not given are kept from the current ones, and a limit of "0"
+// removes it. Empty values then remove the journal limits of the group.
+func (x *cmdSetQuota) journalValues(current *client.QuotaJournalValues) (*client.QuotaJournalValues, error) {
+	if x.JournalSize == "" && x.JournalRateLimit == "" {
+		return nil, nil
+	}
+
+	journal := &client.QuotaJournalValues{}
+	if current != nil {
+		*journal = *current
+	}
+	switch x.JournalSize {
+	case "":
+	case "0":
+		journal.Size = 0
+	default:
+		size, err := strutil.ParseByteSize(x.JournalSize)
+		if err != nil {
+			return nil, err
+		}
+		journal.Size = quantity.Size(size)
+	}
+	switch x.JournalRateLimit {
+	case "":
+	case "0":
+		journal.RateCount = 0
+		journal.RatePeriod = 0
+	default:
+		count, period, err := parseJournalRateLimit(x.JournalRateLimit)
+		if err != nil {
+			return nil, err
+		}
+		journal.RateCount = count
+		journal.RatePeriod = period
+	}
+	if current == nil && *journal == (client.QuotaJournalValues{}) {
+		// nothing to set nor to remove
+		return nil, nil
+	}
+	return journal, nil
+}
+
 func (x *cmdSetQuota) Execute(args []string) (err error) {
 	var maxMemory string
 	switch {
@@ -114,18 +187,32 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 		maxMemory = x.MemoryMax
 	}
 
+	// catch invalid journal limits before talking to snapd
+	if _, err := x.journalValues(nil); err != nil {
+		return err
+	}
+
 	names := installedSnapNames(x.Positional.Snaps)
 
 	// figure out if the group exists or not to make error messages more useful
 	groupExists := false
-	if _, err = x.client.GetQuotaGroup(x.Positional.GroupName); err == nil {
+	var currentJournal *client.QuotaJournalValues
+	if group, err := x.client.GetQuotaGroup(x.Positional.GroupName); err == nil {
 		groupExists = true
+		if group.Constraints != nil {
+			currentJournal = group.Constraints.Journal
+		}
+	}
+
+	journal, err := x.journalValues(currentJournal)
+	if err != nil {
+		return err
 	}
 
 	var chgID string
 
 	switch {
-	case maxMemory == "" && x.Parent == "" && len(x.Positional.Snaps) == 0:
+	case maxMemory == "" && journal == nil && x.Parent == "" && len(x.Positional.Snaps) == 0:
 		// no snaps were specified, no memory limit was specified, and no parent
 		// was specified, so just the group name was provided - this is not
 		// supported since there is nothing to change/create
@@ -135,7 +222,7 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 		}
 		return fmt.Errorf("cannot create quota group without memory limit")
 
-	case maxMemory == "" && x.Parent != "" && len(x.Positional.Snaps) == 0:
+	case maxMemory == "" && journal == nil && x.Parent != "" && len(x.Positional.Snaps) == 0:
 		// this is either trying to create a new group with a parent and forgot
 		// to specify the memory limit for the new group, or the user is trying
 		// to re-parent a group, i.e. move it from the current parent to a
@@ -164,21 +251,21 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 		// orphan a sub-group to no longer have a parent, but currently it just
 		// means leave the group with whatever parent it has, or if it doesn't
 		// currently exist, create the group without a parent group
-		chgID, err = x.client.EnsureQuota(x.Positional.GroupName, x.Parent, names, quantity.Size(mem))
+		chgID, err = x.client.EnsureQuota(x.Positional.GroupName, x.Parent, names, quantity.Size(mem), journal)
 		if err != nil {
 			return err
 		}
-	case len(x.Positional.Snaps) != 0:
-		// there are snaps specified for this group but no memory limit, so the
-		// group must already exist and we must be adding the specified snaps to
-		// the group
+	case len(x.Positional.Snaps) != 0 || journal != nil:
+		// there are snaps or journal limits specified for this group but no
+		// memory limit, so the group must already exist and we must be adding
+		// the specified snaps to the group or updating its journal limits
 
 		// TODO: this case may someday also imply overwriting the current set of
 		// snaps with whatever was specified with some option, but we don't
 		// currently support that, so currently all snaps specified here are
 		// just added to the group
 
-		chgID, err = x.client.EnsureQuota(x.Positional.GroupName, x.Parent, names, 0)
+		chgID, err = x.client.EnsureQuota(x.Positional.GroupName, x.Parent, names, 0, journal)
 		if err != nil {
 			return err
 		}
@@ -232,6 +319,14 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 	}
 	val := strings.TrimSpace(fmtSize(int64(group.Constraints.Memory)))
 	fmt.Fprintf(w, "  memory:\t%s\n", val)
+	if journal := group.Constraints.Journal; journal != nil {
+		if journal.Size != 0 {
+			fmt.Fprintf(w, "  journal-size:\t%s\n", strings.TrimSpace(fmtSize(int64(journal.Size))))
+		}
+		if journal.RateCount != 0 {
+			fmt.Fprintf(w, "  journal-rate-limit:\t%d/%s\n", journal.RateCount, journal.RatePeriod)
+		}
+	}
 
 	fmt.Fprintf(w, "current:\n")
 	if group.Current == nil {
@@ -306,6 +401,14 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 		}
 
 		constraintVal := "memory=" + strings.TrimSpace(fmtSize(int64(q.Constraints.Memory)))
+		if journal := q.Constraints.Journal; journal != nil {
+			if journal.Size != 0 {
+				constraintVal += ",journal-size=" + strings.TrimSpace(fmtSize(int64(journal.Size)))
+			}
+			if journal.RateCount != 0 {
+				constraintVal += fmt.Sprintf(",journal-rate-limit=%d/%s", journal.RateCount, journal.RatePeriod)
+			}
+		}
 		currentVal := ""
 		if q.Current != nil && q.Current.Memory != 0 {
 			currentVal = "memory=" + strings.TrimSpace(fmtSize(int64(q.Current.Memory)))
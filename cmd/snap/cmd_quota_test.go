@@ -100,6 +100,7 @@ type fakeQuotaGroupPostHandlerOpts struct {
 	parentName string
 	snaps      []string
 	maxMemory  int64
+	journal    map[string]interface{}
 }
 
 type quotasEnsureBody struct {
@@ -137,6 +138,9 @@ func makeFakeQuotaPostHandler(c *check.C, opts fakeQuotaGroupPostHandlerOpts) fu
 			if opts.maxMemory != 0 {
 				exp.Constraints["memory"] = json.Number(fmt.Sprintf("%d", opts.maxMemory))
 			}
+			if opts.journal != nil {
+				exp.Constraints["journal"] = opts.journal
+			}
 
 			postJSON := quotasEnsureBody{}
 			err := jsonutil.DecodeWithNumber(bytes.NewReader(buf), &postJSON)
@@ -178,6 +182,11 @@ func (s *quotaSuite) TestSetQuotaInvalidArgs(c *check.C) {
 		{[]string{"set-quota", "--memory=99B"}, "the required argument `<group-name>` was not provided"},
 		{[]string{"set-quota", "--memory=99", "foo"}, `cannot parse "99": need a number with a unit as input`},
 		{[]string{"set-quota", "--memory=888X", "foo"}, `cannot parse "888X\": try 'kB' or 'MB'`},
+		{[]string{"set-quota", "--journal-size=99", "foo"}, `cannot parse "99": need a number with a unit as input`},
+		{[]string{"set-quota", "--journal-rate-limit=100", "foo"}, `cannot parse journal rate limit "100": expected <count>/<period>`},
+		{[]string{"set-quota", "--journal-rate-limit=x/10s", "foo"}, `cannot parse journal rate limit "x/10s": invalid message count "x"`},
+		{[]string{"set-quota", "--journal-rate-limit=0/10s", "foo"}, `cannot parse journal rate limit "0/10s": invalid message count "0"`},
+		{[]string{"set-quota", "--journal-rate-limit=10/soon", "foo"}, `cannot parse journal rate limit "10/soon": invalid period "soon"`},
 		// remove-quota command
 		{[]string{"remove-quota"}, "the required argument `<group-name>` was not provided"},
 	} {
@@ -227,6 +236,40 @@ snaps:
 `[1:])
 }
 
+func (s *quotaSuite) TestGetQuotaGroupWithJournal(c *check.C) {
+	restore := main.MockIsStdinTTY(true)
+	defer restore()
+
+	const json = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name":"foo",
+			"constraints": {
+				"memory": 1000,
+				"journal": {"size": 64000000, "rate-count": 100, "rate-period": 10000000000}
+			},
+			"current": { "memory": 900 }
+		}
+	}`
+
+	s.RedirectClientToTestServer(makeFakeGetQuotaGroupHandler(c, json))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+name:  foo
+constraints:
+  memory:              1000B
+  journal-size:        64.0MB
+  journal-rate-limit:  100/10s
+current:
+  memory:  900B
+`[1:])
+}
+
 func (s *quotaSuite) TestGetQuotaGroupSimple(c *check.C) {
 	restore := main.MockIsStdinTTY(true)
 	defer restore()
@@ -416,6 +459,100 @@ func (s *quotaSuite) TestSetQuotaGroupUpdateExisting(c *check.C) {
 	c.Check(s.Stdout(), check.Equals, "")
 }
 
+func (s *quotaSuite) TestSetQuotaGroupUpdateJournal(c *check.C) {
+	const postJSON = `{"type": "async", "status-code": 202,"change":"42", "result": []}`
+	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
+		action:    "ensure",
+		body:      postJSON,
+		groupName: "foo",
+		journal: map[string]interface{}{
+			"size":        json.Number("64000000"),
+			"rate-count":  json.Number("100"),
+			"rate-period": json.Number("10000000000"),
+		},
+	}
+
+	routes := map[string]http.HandlerFunc{
+		"/v2/quotas": makeFakeQuotaPostHandler(
+			c,
+			fakeHandlerOpts,
+		),
+		"/v2/quotas/foo": makeFakeGetQuotaGroupHandler(c, `{
+			"type": "sync",
+			"status-code": 200,
+			"result": {
+				"group-name":"foo",
+				"constraints": { "memory": 1000 }
+			}
+		}`),
+		"/v2/changes/42": makeChangesHandler(c),
+	}
+
+	s.RedirectClientToTestServer(dispatchFakeHandlers(c, routes))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"set-quota", "foo", "--journal-size=64MB", "--journal-rate-limit=100/10s"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "")
+}
+
+func (s *quotaSuite) testSetQuotaGroupJournalKeepsCurrent(c *check.C, args []string, journal map[string]interface{}) {
+	const postJSON = `{"type": "async", "status-code": 202,"change":"42", "result": []}`
+	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
+		action:    "ensure",
+		body:      postJSON,
+		groupName: "foo",
+		journal:   journal,
+	}
+
+	routes := map[string]http.HandlerFunc{
+		"/v2/quotas": makeFakeQuotaPostHandler(
+			c,
+			fakeHandlerOpts,
+		),
+		"/v2/quotas/foo": makeFakeGetQuotaGroupHandler(c, `{
+			"type": "sync",
+			"status-code": 200,
+			"result": {
+				"group-name":"foo",
+				"constraints": {
+					"memory": 1000,
+					"journal": {"size": 64000000, "rate-count": 100, "rate-period": 10000000000}
+				}
+			}
+		}`),
+		"/v2/changes/42": makeChangesHandler(c),
+	}
+
+	s.RedirectClientToTestServer(dispatchFakeHandlers(c, routes))
+
+	rest, err := main.Parser(main.Client()).ParseArgs(append([]string{"set-quota", "foo"}, args...))
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "")
+}
+
+func (s *quotaSuite) TestSetQuotaGroupUpdateJournalSizeKeepsRateLimit(c *check.C) {
+	s.testSetQuotaGroupJournalKeepsCurrent(c, []string{"--journal-size=128MB"}, map[string]interface{}{
+		"size":        json.Number("128000000"),
+		"rate-count":  json.Number("100"),
+		"rate-period": json.Number("10000000000"),
+	})
+}
+
+func (s *quotaSuite) TestSetQuotaGroupRemoveJournalRateLimit(c *check.C) {
+	s.testSetQuotaGroupJournalKeepsCurrent(c, []string{"--journal-rate-limit=0"}, map[string]interface{}{
+		"size": json.Number("64000000"),
+	})
+}
+
+func (s *quotaSuite) TestSetQuotaGroupRemoveJournal(c *check.C) {
+	// empty journal limits remove them
+	s.testSetQuotaGroupJournalKeepsCurrent(c, []string{"--journal-size=0", "--journal-rate-limit=0"}, map[string]interface{}{})
+}
+
 func (s *quotaSuite) TestRemoveQuotaGroup(c *check.C) {
 	const json = `{"type": "async", "status-code": 202,"change": "42"}`
 	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
@@ -472,6 +609,25 @@ bbb      zzz     memory=1000B  memory=400B
 `[1:])
 }
 
+func (s *quotaSuite) TestGetAllQuotaGroupsWithJournal(c *check.C) {
+	restore := main.MockIsStdinTTY(true)
+	defer restore()
+
+	s.RedirectClientToTestServer(makeFakeGetQuotaGroupsHandler(c,
+		`{"type": "sync", "status-code": 200, "result": [
+			{"group-name":"aaa","constraints":{"memory":1000,"journal":{"size":64000000,"rate-count":10,"rate-period":60000000000}}}
+			]}`))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quotas"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+Quota  Parent  Constraints                                                  Current
+aaa            memory=1000B,journal-size=64.0MB,journal-rate-limit=10/1m0s  
+`[1:])
+}
+
 func (s *quotaSuite) TestGetAllQuotaGroupsInconsistencyError(c *check.C) {
 	restore := main.MockIsStdinTTY(true)
 	defer restore()
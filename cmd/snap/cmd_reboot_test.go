@@ -46,13 +46,17 @@ current system to enter the given mode.
 Note that "recover" and "run" modes are only available for the
 current system.
 
+The "factory-reset" mode wipes the user data of the device, while keeping
+its identity, and reinstalls it from the selected recovery system.
+
 [reboot command options]
-      --run        Boot into run mode
-      --install    Boot into install mode
-      --recover    Boot into recover mode
+      --run              Boot into run mode
+      --install          Boot into install mode
+      --recover          Boot into recover mode
+      --factory-reset    Boot into factory-reset mode
 
 [reboot command arguments]
-  <label>:         The recovery system label
+  <label>:               The recovery system label
 `
 	s.testSubCommandHelp(c, "reboot", msg)
 }
@@ -89,6 +93,18 @@ func (s *SnapSuite) TestRebootHappy(c *C) {
 			expectedJSON:     `{"action":"reboot","mode":"recover"}`,
 			expectedMsg:      `Reboot into "20200101" "recover" mode.`,
 		},
+		{
+			cmdline:          []string{"reboot", "--factory-reset"},
+			expectedEndpoint: "/v2/systems",
+			expectedJSON:     `{"action":"reboot","mode":"factory-reset"}`,
+			expectedMsg:      `Reboot into "factory-reset" mode.`,
+		},
+		{
+			cmdline:          []string{"reboot", "--factory-reset", "20200101"},
+			expectedEndpoint: "/v2/systems/20200101",
+			expectedJSON:     `{"action":"reboot","mode":"factory-reset"}`,
+			expectedMsg:      `Reboot into "20200101" "factory-reset" mode.`,
+		},
 	} {
 
 		n := 0
@@ -133,6 +149,10 @@ func (s *SnapSuite) TestRebootUnhappy(c *C) {
 			args:   []string{"reboot", "--run", "--recover", "20200101"},
 			errStr: "Please specify a single mode",
 		},
+		{
+			args:   []string{"reboot", "--install", "--factory-reset"},
+			errStr: "Please specify a single mode",
+		},
 		{
 			args:   []string{"reboot", "--unknown-mode", "20200101"},
 			errStr: "unknown flag `unknown-mode'",
@@ -33,10 +33,12 @@ import (
 )
 
 type cmdRecovery struct {
-	clientMixin
+	waitMixin
 	colorMixin
 
-	ShowKeys bool `long:"show-keys"`
+	ShowKeys  bool   `long:"show-keys"`
+	RotateKey bool   `long:"rotate-key"`
+	Remove    string `long:"remove" value-name:"<label>"`
 }
 
 var shortRecoveryHelp = i18n.G("List available recovery systems")
@@ -44,16 +46,24 @@ var longRecoveryHelp = i18n.G(`
 The recovery command lists the available recovery systems.
 
 With --show-keys it displays recovery keys that can be used to unlock the encrypted partitions if the device-specific automatic unlocking does not work.
+
+With --rotate-key it replaces the recovery keys with newly generated ones and displays them. The previous recovery keys can no longer be used afterwards.
+
+With --remove it removes the recovery system with the given label, along with the seed snaps no other recovery system uses. The current and the last good recovery system cannot be removed.
 `)
 
 func init() {
 	addCommand("recovery", shortRecoveryHelp, longRecoveryHelp, func() flags.Commander {
 		// XXX: if we want more/nicer details we can add `snap recovery <system>` later
 		return &cmdRecovery{}
-	}, colorDescs.also(
+	}, colorDescs.also(waitDescs).also(
 		map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"show-keys": i18n.G("Show recovery keys (if available) to unlock encrypted partitions."),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"rotate-key": i18n.G("Replace the recovery keys with new ones and show them."),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"remove": i18n.G("Remove the recovery system with the given label."),
 		}), nil)
 }
 
@@ -78,10 +88,50 @@ func (x *cmdRecovery) showKeys(w io.Writer) error {
 	return nil
 }
 
+func (x *cmdRecovery) rotateKeys(w io.Writer) error {
+	if release.OnClassic {
+		return errors.New(`command "rotate-key" is not available on classic systems`)
+	}
+	if err := x.client.RotateSystemRecoveryKeys(); err != nil {
+		return err
+	}
+	return x.showKeys(w)
+}
+
+func (x *cmdRecovery) removeSystem(label string) error {
+	if release.OnClassic {
+		return errors.New(`command "remove" is not available on classic systems`)
+	}
+	changeID, err := x.client.RemoveRecoverySystem(label)
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+	fmt.Fprintf(Stdout, i18n.G("Recovery system %q removed\n"), label)
+	return nil
+}
+
 func (x *cmdRecovery) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
 	}
+	opts := 0
+	for _, set := range []bool{x.ShowKeys, x.RotateKey, x.Remove != ""} {
+		if set {
+			opts++
+		}
+	}
+	if opts > 1 {
+		return errors.New(i18n.G("cannot use more than one of --show-keys, --rotate-key and --remove"))
+	}
+	if x.Remove != "" {
+		return x.removeSystem(x.Remove)
+	}
 
 	esc := x.getEscapes()
 	w := tabWriter()
@@ -90,6 +140,9 @@ func (x *cmdRecovery) Execute(args []string) error {
 	if x.ShowKeys {
 		return x.showKeys(w)
 	}
+	if x.RotateKey {
+		return x.rotateKeys(w)
+	}
 
 	systems, err := x.client.ListSystems()
 	if err != nil {
@@ -38,13 +38,26 @@ The recovery command lists the available recovery systems.
 With --show-keys it displays recovery keys that can be used to unlock the
 encrypted partitions if the device-specific automatic unlocking does not work.
 
+With --rotate-key it replaces the recovery keys with newly generated ones and
+displays them. The previous recovery keys can no longer be used afterwards.
+
+With --remove it removes the recovery system with the given label, along with
+the seed snaps no other recovery system uses. The current and the last good
+recovery system cannot be removed.
+
 [recovery command options]
-      --color=[auto|never|always]     Use a little bit of color to highlight
-                                      some things. (default: auto)
-      --unicode=[auto|never|always]   Use a little bit of Unicode to improve
-                                      legibility. (default: auto)
-      --show-keys                     Show recovery keys (if available) to
-                                      unlock encrypted partitions.
+      --no-wait                          Do not wait for the operation to
+                                         finish but just print the change id.
+      --color=[auto|never|always]        Use a little bit of color to highlight
+                                         some things. (default: auto)
+      --unicode=[auto|never|always]      Use a little bit of Unicode to improve
+                                         legibility. (default: auto)
+      --show-keys                        Show recovery keys (if available) to
+                                         unlock encrypted partitions.
+      --rotate-key                       Replace the recovery keys with new
+                                         ones and show them.
+      --remove=<label>                   Remove the recovery system with the
+                                         given label.
 `
 	s.testSubCommandHelp(c, "recovery", msg)
 }
@@ -192,3 +205,133 @@ reinstall:  1234
 	c.Check(s.Stderr(), Equals, "")
 	c.Check(n, Equals, 1)
 }
+
+func (s *SnapSuite) TestRecoveryRemoveOnClassicErrors(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected server call")
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"recovery", "--remove", "1234"})
+	c.Assert(err, ErrorMatches, `command "remove" is not available on classic systems`)
+}
+
+func (s *SnapSuite) TestRecoveryExclusiveOptionsErrors(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected server call")
+	})
+	for _, args := range [][]string{
+		{"recovery", "--remove", "1234", "--show-keys"},
+		{"recovery", "--rotate-key", "--show-keys"},
+		{"recovery", "--remove", "1234", "--rotate-key"},
+	} {
+		_, err := snap.Parser(snap.Client()).ParseArgs(args)
+		c.Check(err, ErrorMatches, `cannot use more than one of --show-keys, --rotate-key and --remove`)
+	}
+}
+
+func (s *SnapSuite) TestRecoveryRotateKeyOnClassicErrors(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected server call")
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"recovery", "--rotate-key"})
+	c.Assert(err, ErrorMatches, `command "rotate-key" is not available on classic systems`)
+}
+
+func (s *SnapSuite) TestRecoveryRotateKeyHappy(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/system-recovery-keys")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "rotate",
+			})
+			fmt.Fprintln(w, `{"type": "sync", "result": null}`)
+		case 1:
+			c.Check(r.Method, Equals, "GET")
+			c.Check(r.URL.Path, Equals, "/v2/system-recovery-keys")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"recovery-key": "61665-00531-54469-09783-47273-19035-40077-28287", "reinstall-key":"1234"}}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"recovery", "--rotate-key"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `recovery:   61665-00531-54469-09783-47273-19035-40077-28287
+reinstall:  1234
+`)
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(n, Equals, 2)
+}
+
+func (s *SnapSuite) TestRecoveryRotateKeyError(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		w.WriteHeader(500)
+		fmt.Fprintln(w, `{"type": "error", "result": {"message": "cannot rotate recovery keys: boom"}, "status-code": 500}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"recovery", "--rotate-key"})
+	c.Assert(err, ErrorMatches, `cannot rotate recovery keys: boom`)
+}
+
+func (s *SnapSuite) TestRecoveryRemoveHappy(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/systems/1234")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "remove",
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		case 1:
+			c.Check(r.Method, Equals, "GET")
+			c.Check(r.URL.Path, Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"recovery", "--remove", "1234"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "Recovery system \"1234\" removed\n")
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(n, Equals, 2)
+}
+
+func (s *SnapSuite) TestRecoveryRemoveError(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/v2/systems/1234")
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "result": {"message": "cannot remove the current recovery system \"1234\""}, "status-code": 400}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"recovery", "--remove", "1234"})
+	c.Assert(err, ErrorMatches, `cannot remove recovery system "1234": cannot remove the current recovery system "1234"`)
+}
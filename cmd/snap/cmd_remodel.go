@@ -20,8 +20,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"strings"
 
 	"github.com/jessevdk/go-flags"
 
@@ -36,12 +38,25 @@ revision or a full new model.
 
 In the process it applies any implied changes to the device: new required
 snaps, new kernel or gadget etc.
+
+With --dry-run, the remodel is planned but not performed: the kind of remodel,
+what would happen to each snap of the new model, whether the snaps are
+available in the store, problems such as an incompatible gadget, whether a
+reboot would be needed and the tasks that would run are shown instead.
+
+With --snap and --assert, the snaps needed by the new model are taken from the
+given local files instead of the store, so that the device can be remodeled
+without network access. Every snap to install or refresh must be provided,
+together with its assertions.
 `)
 )
 
 type cmdRemodel struct {
 	waitMixin
-	RemodelOptions struct {
+	DryRun          bool     `long:"dry-run"`
+	LocalSnaps      []string `long:"snap"`
+	LocalAssertions []string `long:"assert"`
+	RemodelOptions  struct {
 		NewModelFile flags.Filename
 	} `positional-args:"true" required:"true"`
 }
@@ -52,7 +67,14 @@ func init() {
 		longRemodelHelp,
 		func() flags.Commander {
 			return &cmdRemodel{}
-		}, nil, []argDesc{{
+		}, waitDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"dry-run": i18n.G("Show what the remodel would do, including store availability and gadget checks, but do not perform it"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"snap": i18n.G("Use the given local snap file instead of the store (can be repeated)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"assert": i18n.G("Acknowledge the assertions in the given file for the local snaps (can be repeated)"),
+		}), []argDesc{{
 			// TRANSLATORS: This needs to begin with < and end with >
 			name: i18n.G("<new model file>"),
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -70,7 +92,21 @@ func (x *cmdRemodel) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
-	changeID, err := x.client.Remodel(modelData)
+	if len(x.LocalAssertions) > 0 && len(x.LocalSnaps) == 0 {
+		return errors.New(i18n.G("cannot use --assert without --snap"))
+	}
+	if x.DryRun {
+		if len(x.LocalSnaps) > 0 {
+			return errors.New(i18n.G("cannot use --dry-run with --snap"))
+		}
+		return x.showRemodelPlan(modelData)
+	}
+	var changeID string
+	if len(x.LocalSnaps) > 0 {
+		changeID, err = x.client.RemodelOffline(modelData, x.LocalSnaps, x.LocalAssertions)
+	} else {
+		changeID, err = x.client.Remodel(modelData)
+	}
 	if err != nil {
 		return fmt.Errorf("cannot remodel: %v", err)
 	}
@@ -84,3 +120,57 @@ func (x *cmdRemodel) Execute(args []string) error {
 	fmt.Fprintf(Stdout, i18n.G("New model %s set\n"), newModelFile)
 	return nil
 }
+
+func (x *cmdRemodel) showRemodelPlan(modelData []byte) error {
+	plan, err := x.client.PlanRemodel(modelData)
+	if err != nil {
+		return fmt.Errorf("cannot remodel: %v", err)
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Remodel kind: %s\n\n"), plan.Kind)
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Name\tRole\tChannel\tRev\tAction\tNotes"))
+	for _, sn := range plan.Snaps {
+		rev := "-"
+		if !sn.Revision.Unset() {
+			rev = sn.Revision.String()
+		}
+		notes := "-"
+		if sn.Unavailable != "" {
+			notes = fmt.Sprintf(i18n.G("unavailable: %s"), sn.Unavailable)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", sn.Name, sn.Role, sn.Channel, rev, sn.Action, notes)
+	}
+	w.Flush()
+
+	fmt.Fprintln(Stdout)
+	if !plan.StoreChecked {
+		fmt.Fprintln(Stdout, i18n.G("The snaps cannot be checked with the store of the new model before re-registration."))
+	}
+	if len(plan.Problems) > 0 {
+		fmt.Fprintln(Stdout, i18n.G("The remodel is expected to fail:"))
+		for _, problem := range plan.Problems {
+			fmt.Fprintf(Stdout, "  - %s\n", problem)
+		}
+	}
+	if plan.RebootRequired {
+		fmt.Fprintln(Stdout, i18n.G("A reboot will be required to complete the remodel."))
+	}
+	if len(plan.Tasks) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(Stdout)
+	w = tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("ID\tWaits for\tSummary"))
+	for _, t := range plan.Tasks {
+		waits := "-"
+		if len(t.WaitTasks) > 0 {
+			waits = strings.Join(t.WaitTasks, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.ID, waits, t.Summary)
+	}
+
+	return nil
+}
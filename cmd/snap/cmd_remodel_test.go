@@ -0,0 +1,193 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestRemodelDryRun(c *check.C) {
+	modelFile := filepath.Join(c.MkDir(), "new.model")
+	c.Assert(ioutil.WriteFile(modelFile, []byte("some-model"), 0644), check.IsNil)
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/model")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"new-model": "some-model",
+				"dry-run":   true,
+			})
+			fmt.Fprintln(w, `{"type": "sync", "result": {
+				"kind": "store switch remodel",
+				"snaps": [
+					{"name": "pc-kernel", "role": "kernel", "channel": "20", "action": "none", "revision": "5"},
+					{"name": "pc", "role": "gadget", "channel": "21", "action": "refresh", "revision": "7"},
+					{"name": "foo", "role": "required", "channel": "stable", "action": "install", "revision": "unset", "unavailable": "snap not found"}
+				],
+				"store-checked": true,
+				"tasks": [],
+				"reboot-required": true,
+				"problems": [
+					"cannot get snap \"foo\" from the store: snap not found",
+					"cannot remodel to an incompatible gadget: volume pc is different"
+				]
+			}}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"remodel", "--dry-run", modelFile})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `Remodel kind: store switch remodel
+
+Name       Role      Channel  Rev  Action   Notes
+pc-kernel  kernel    20       5    none     -
+pc         gadget    21       7    refresh  -
+foo        required  stable   -    install  unavailable: snap not found
+
+The remodel is expected to fail:
+  - cannot get snap "foo" from the store: snap not found
+  - cannot remodel to an incompatible gadget: volume pc is different
+A reboot will be required to complete the remodel.
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestRemodelDryRunRereg(c *check.C) {
+	modelFile := filepath.Join(c.MkDir(), "new.model")
+	c.Assert(ioutil.WriteFile(modelFile, []byte("some-model"), 0644), check.IsNil)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/model")
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+			"kind": "re-registration remodel",
+			"snaps": [
+				{"name": "pc", "role": "gadget", "channel": "stable", "action": "none", "revision": "unset"}
+			],
+			"store-checked": false,
+			"tasks": [
+				{"id": "1", "kind": "request-serial", "summary": "Request new device serial"},
+				{"id": "2", "kind": "prepare-remodeling", "summary": "Prepare remodeling", "wait-tasks": ["1"]}
+			],
+			"reboot-required": false
+		}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"remodel", "--dry-run", modelFile})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `Remodel kind: re-registration remodel
+
+Name  Role    Channel  Rev  Action  Notes
+pc    gadget  stable   -    none    -
+
+The snaps cannot be checked with the store of the new model before re-registration.
+
+ID   Waits for  Summary
+1    -          Request new device serial
+2    1          Prepare remodeling
+`)
+}
+
+func (s *SnapSuite) TestRemodelDryRunError(c *check.C) {
+	modelFile := filepath.Join(c.MkDir(), "new.model")
+	c.Assert(ioutil.WriteFile(modelFile, []byte("some-model"), 0644), check.IsNil)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "status-code": 400, "result": {"message": "cannot remodel device: cannot remodel without a serial"}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"remodel", "--dry-run", modelFile})
+	c.Assert(err, check.ErrorMatches, "cannot remodel: cannot remodel device: cannot remodel without a serial")
+}
+
+func (s *SnapSuite) TestRemodelOffline(c *check.C) {
+	dir := c.MkDir()
+	modelFile := filepath.Join(dir, "new.model")
+	c.Assert(ioutil.WriteFile(modelFile, []byte("some-model"), 0644), check.IsNil)
+	for _, name := range []string{"a.snap", "b.snap", "bundle.assert"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, name), []byte(name+"-data"), 0644), check.IsNil)
+	}
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/model")
+			mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			c.Assert(err, check.IsNil)
+			c.Check(mediaType, check.Equals, "multipart/form-data")
+			form, err := multipart.NewReader(r.Body, params["boundary"]).ReadForm(1024)
+			c.Assert(err, check.IsNil)
+			defer form.RemoveAll()
+			c.Check(form.Value["new-model"], check.DeepEquals, []string{"some-model"})
+			var snaps, assertions []string
+			for _, fh := range form.File["snap"] {
+				snaps = append(snaps, fh.Filename)
+			}
+			for _, fh := range form.File["assertion"] {
+				assertions = append(assertions, fh.Filename)
+			}
+			c.Check(snaps, check.DeepEquals, []string{"a.snap", "b.snap"})
+			c.Check(assertions, check.DeepEquals, []string{"bundle.assert"})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"remodel", "--no-wait",
+		"--snap", filepath.Join(dir, "a.snap"), "--snap", filepath.Join(dir, "b.snap"),
+		"--assert", filepath.Join(dir, "bundle.assert"), modelFile})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "42\n")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestRemodelOfflineUnhappy(c *check.C) {
+	modelFile := filepath.Join(c.MkDir(), "new.model")
+	c.Assert(ioutil.WriteFile(modelFile, []byte("some-model"), 0644), check.IsNil)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"remodel", "--assert", "bundle.assert", modelFile})
+	c.Check(err, check.ErrorMatches, "cannot use --assert without --snap")
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"remodel", "--dry-run", "--snap", "a.snap", modelFile})
+	c.Check(err, check.ErrorMatches, "cannot use --dry-run with --snap")
+}
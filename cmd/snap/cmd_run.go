@@ -371,6 +371,11 @@ func createUserDataDirs(info *snap.Info) error {
 	if err != nil {
 		return fmt.Errorf(i18n.G("cannot get the current user: %v"), err)
 	}
+	if usr.HomeDir == osutil.NonexistentHomeDir {
+		// system users, e.g. the ones services run as, have no home
+		// directory and thus no user data directories
+		return nil
+	}
 
 	// see snapenv.User
 	instanceUserData := info.UserDataDir(usr.HomeDir)
@@ -1081,6 +1086,15 @@ func (x *cmdRun) runSnapConfine(info *snap.Info, securityTag, snapApp, hook stri
 	}
 	snapenv.ExtendEnvForRun(env, info)
 
+	_, appName := snap.SplitSnapApp(snapApp)
+	if app := info.Apps[appName]; hook == "" && app != nil {
+		// the environment overrides set by the administrator are
+		// applied by snap-exec on top of the environment of the app
+		if err := snapenv.ExtendEnvWithOverrides(env, app); err != nil {
+			return err
+		}
+	}
+
 	if len(xauthPath) > 0 {
 		// Environment is not nil here because it comes from
 		// osutil.OSEnvironment and that guarantees this
@@ -1140,7 +1154,6 @@ func (x *cmdRun) runSnapConfine(info *snap.Info, securityTag, snapApp, hook stri
 	//
 	// For more information about systemd cgroups, including unit types, see:
 	// https://www.freedesktop.org/wiki/Software/systemd/ControlGroupInterface/
-	_, appName := snap.SplitSnapApp(snapApp)
 	needsTracking := true
 	if app := info.Apps[appName]; hook == "" && app != nil && app.IsService() {
 		// If we are running a service app then we do not need to use
@@ -22,6 +22,7 @@ package main_test
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"os/user"
@@ -205,6 +206,37 @@ func (s *RunSuite) TestSnapRunAppIntegration(c *check.C) {
 	c.Check(execEnv, testutil.Contains, fmt.Sprintf("TMPDIR=%s", tmpdir))
 }
 
+func (s *RunSuite) TestSnapRunAppWithEnvOverrides(c *check.C) {
+	defer mockSnapConfine(dirs.DistroLibExecDir)()
+
+	// mock installed snap
+	snaptest.MockSnapCurrent(c, string(mockYaml), &snap.SideInfo{
+		Revision: snap.R("x2"),
+	})
+	c.Assert(os.MkdirAll(dirs.SnapAppEnvDir, 0755), check.IsNil)
+	envFile := filepath.Join(dirs.SnapAppEnvDir, "snap.snapname.app.env")
+	c.Assert(ioutil.WriteFile(envFile, []byte("# comment\nLOG_LEVEL=debug\nHTTP_PROXY=http://proxy:3128/?a=b\n"), 0644), check.IsNil)
+
+	// escaped overrides inherited from the caller are dropped
+	os.Setenv("SNAP_ENV_OVERRIDE_FOO", "bar")
+	defer os.Unsetenv("SNAP_ENV_OVERRIDE_FOO")
+
+	execEnv := []string{}
+	restorer := snaprun.MockSyscallExec(func(arg0 string, args []string, envv []string) error {
+		execEnv = envv
+		return nil
+	})
+	defer restorer()
+
+	_, err := snaprun.Parser(snaprun.Client()).ParseArgs([]string{"run", "--", "snapname.app"})
+	c.Assert(err, check.IsNil)
+	c.Check(execEnv, testutil.Contains, "SNAP_ENV_OVERRIDE_LOG_LEVEL=debug")
+	c.Check(execEnv, testutil.Contains, "SNAP_ENV_OVERRIDE_HTTP_PROXY=http://proxy:3128/?a=b")
+	for _, e := range execEnv {
+		c.Check(strings.HasPrefix(e, "SNAP_ENV_OVERRIDE_FOO="), check.Equals, false)
+	}
+}
+
 func (s *RunSuite) TestSnapRunClassicAppIntegration(c *check.C) {
 	defer mockSnapConfine(dirs.DistroLibExecDir)()
 
@@ -355,6 +387,22 @@ func (s *RunSuite) TestSnapRunCreateDataDirs(c *check.C) {
 	c.Check(osutil.FileExists(filepath.Join(s.fakeHome, "/snap/snapname/common")), check.Equals, true)
 }
 
+func (s *RunSuite) TestSnapRunCreateDataDirsSystemUser(c *check.C) {
+	// services running as a system user have no home directory
+	restore := snaprun.MockUserCurrent(func() (*user.User, error) {
+		return &user.User{Uid: "584788", HomeDir: osutil.NonexistentHomeDir}, nil
+	})
+	defer restore()
+
+	info, err := snap.InfoFromSnapYaml(mockYaml)
+	c.Assert(err, check.IsNil)
+	info.SideInfo.Revision = snap.R(42)
+
+	err = snaprun.CreateUserDataDirs(info)
+	c.Assert(err, check.IsNil)
+	c.Check(osutil.FileExists(osutil.NonexistentHomeDir), check.Equals, false)
+}
+
 func (s *RunSuite) TestParallelInstanceSnapRunCreateDataDirs(c *check.C) {
 	info, err := snap.InfoFromSnapYaml(mockYaml)
 	c.Assert(err, check.IsNil)
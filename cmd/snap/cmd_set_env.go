@@ -0,0 +1,132 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/snap"
+)
+
+var shortSetEnvHelp = i18n.G("Set environment variables for a snap app")
+var longSetEnvHelp = i18n.G(`
+The set-env command sets environment variables for the given snap app,
+overriding the environment declared by the snap.
+
+    $ snap set-env snap-name.app-name LOG_LEVEL=debug
+
+Variables in the SNAP_ namespace are reserved and cannot be set. The
+variables apply the next time the app is run; services need to be
+restarted, e.g. with 'snap restart', for the change to take effect.
+`)
+
+var shortUnsetEnvHelp = i18n.G("Unset environment variables for a snap app")
+var longUnsetEnvHelp = i18n.G(`
+The unset-env command unsets environment variables previously set for the
+given snap app with set-env.
+
+    $ snap unset-env snap-name.app-name LOG_LEVEL
+
+The change applies the next time the app is run; services need to be
+restarted, e.g. with 'snap restart', for the change to take effect.
+`)
+
+type cmdSetEnv struct {
+	clientMixin
+	Positional struct {
+		App       string   `positional-arg-name:"<snap>.<app>"`
+		EnvValues []string `positional-arg-name:"<key=value>" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type cmdUnsetEnv struct {
+	clientMixin
+	Positional struct {
+		App  string   `positional-arg-name:"<snap>.<app>"`
+		Keys []string `positional-arg-name:"<key>" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addCommand("set-env", shortSetEnvHelp, longSetEnvHelp, func() flags.Commander { return &cmdSetEnv{} }, nil, []argDesc{
+		{
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<snap>.<app>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("The app to set environment variables for (e.g. hello-world.hello)"),
+		}, {
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<key=value>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("Environment variable to set"),
+		},
+	})
+	addCommand("unset-env", shortUnsetEnvHelp, longUnsetEnvHelp, func() flags.Commander { return &cmdUnsetEnv{} }, nil, []argDesc{
+		{
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<snap>.<app>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("The app to unset environment variables for (e.g. hello-world.hello)"),
+		}, {
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<key>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("Environment variable to unset"),
+		},
+	})
+}
+
+func (x *cmdSetEnv) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	set := make(map[string]string, len(x.Positional.EnvValues))
+	for _, envValue := range x.Positional.EnvValues {
+		parts := strings.SplitN(envValue, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf(i18n.G("invalid environment variable: %q (want key=value)"), envValue)
+		}
+		set[parts[0]] = parts[1]
+	}
+
+	snapName, appName := snap.SplitSnapApp(x.Positional.App)
+	return x.client.SetAppEnvironment(snapName, &client.AppEnvironmentChange{
+		App: appName,
+		Set: set,
+	})
+}
+
+func (x *cmdUnsetEnv) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	snapName, appName := snap.SplitSnapApp(x.Positional.App)
+	return x.client.SetAppEnvironment(snapName, &client.AppEnvironmentChange{
+		App:   appName,
+		Unset: x.Positional.Keys,
+	})
+}
@@ -0,0 +1,82 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) mockSetEnvServer(c *check.C, expected map[string]interface{}) *int {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, check.Equals, "PUT")
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo/env")
+		var body map[string]interface{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&body), check.IsNil)
+		c.Check(body, check.DeepEquals, expected)
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": null}`)
+	})
+	return &n
+}
+
+func (s *SnapSuite) TestSetEnv(c *check.C) {
+	n := s.mockSetEnvServer(c, map[string]interface{}{
+		"app": "svc",
+		"set": map[string]interface{}{"LOG_LEVEL": "debug", "OPTS": "a=b"},
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"set-env", "foo.svc", "LOG_LEVEL=debug", "OPTS=a=b"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(*n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestSetEnvInvalid(c *check.C) {
+	n := s.mockSetEnvServer(c, nil)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"set-env", "foo.svc", "LOG_LEVEL"})
+	c.Assert(err, check.ErrorMatches, `invalid environment variable: "LOG_LEVEL" \(want key=value\)`)
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"set-env", "foo.svc", "=debug"})
+	c.Assert(err, check.ErrorMatches, `invalid environment variable: "=debug" \(want key=value\)`)
+	c.Check(*n, check.Equals, 0)
+}
+
+func (s *SnapSuite) TestUnsetEnv(c *check.C) {
+	n := s.mockSetEnvServer(c, map[string]interface{}{
+		"app":   "svc",
+		"unset": []interface{}{"LOG_LEVEL", "OPTS"},
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"unset-env", "foo.svc", "LOG_LEVEL", "OPTS"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(*n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "")
+}
@@ -92,6 +92,10 @@ have developer access to the snap, either directly or through the
 store's collaboration feature, and to be logged in (see 'snap help login').
 
 Note a later refresh will typically undo a revision override.
+
+With --dry-run, the refresh is planned but not performed: the snaps that would
+be downloaded, the tasks that would run and whether a reboot would be needed
+are shown instead.
 `)
 
 var longTryHelp = i18n.G(`
@@ -638,6 +642,7 @@ type cmdRefresh struct {
 	LeaveCohort      bool   `long:"leave-cohort"`
 	List             bool   `long:"list"`
 	Time             bool   `long:"time"`
+	DryRun           bool   `long:"dry-run"`
 	IgnoreValidation bool   `long:"ignore-validation"`
 	IgnoreRunning    bool   `long:"ignore-running" hidden:"yes"`
 	Positional       struct {
@@ -771,6 +776,62 @@ func (x *cmdRefresh) listRefresh() error {
 	return nil
 }
 
+func (x *cmdRefresh) showRefreshPlan(names []string) error {
+	plan, err := x.client.PlanRefresh(names)
+	if err != nil {
+		return err
+	}
+	if len(plan.Snaps) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("All snaps up to date."))
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Name\tRev\tChannel\tSize\tNotes"))
+	for _, dl := range plan.Downloads {
+		channel := dl.Channel
+		if channel == "" {
+			channel = "-"
+		}
+		notes := "-"
+		if dl.Prerequisite {
+			notes = i18n.G("prerequisite")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", dl.Snap, dl.Revision, channel, strutil.SizeToStr(dl.Size), notes)
+	}
+	w.Flush()
+
+	fmt.Fprintf(Stdout, i18n.G("\nTotal download size: %s\n"), strutil.SizeToStr(plan.TotalDownloadSize))
+	if len(plan.AffectedSnaps) > 0 {
+		affected := make([]string, 0, len(plan.AffectedSnaps))
+		for name := range plan.AffectedSnaps {
+			affected = append(affected, name)
+		}
+		sort.Strings(affected)
+		fmt.Fprintln(Stdout, i18n.G("Snaps affected by the refresh:"))
+		for _, name := range affected {
+			fmt.Fprintf(Stdout, "  %s (%s)\n", name, strings.Join(plan.AffectedSnaps[name], ", "))
+		}
+	}
+	if plan.RebootRequired {
+		fmt.Fprintln(Stdout, i18n.G("A reboot will be required to complete the refresh."))
+	}
+
+	fmt.Fprintln(Stdout)
+	w = tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("ID\tWaits for\tSummary"))
+	for _, t := range plan.Tasks {
+		waits := "-"
+		if len(t.WaitTasks) > 0 {
+			waits = strings.Join(t.WaitTasks, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.ID, waits, t.Summary)
+	}
+
+	return nil
+}
+
 func (x *cmdRefresh) Execute([]string) error {
 	if err := x.setChannelFromCommandline(); err != nil {
 		return err
@@ -794,6 +855,14 @@ func (x *cmdRefresh) Execute([]string) error {
 		return x.listRefresh()
 	}
 
+	if x.DryRun {
+		if x.asksForMode() || x.asksForChannel() || x.Amend || x.Revision != "" || x.Cohort != "" || x.LeaveCohort || x.IgnoreValidation || x.IgnoreRunning {
+			return errors.New(i18n.G("--dry-run does not take mode, channel, revision, cohort or ignore flags"))
+		}
+
+		return x.showRefreshPlan(installedSnapNames(x.Positional.Snaps))
+	}
+
 	if len(x.Positional.Snaps) == 0 && os.Getenv("SNAP_REFRESH_FROM_TIMER") == "1" {
 		fmt.Fprintf(Stdout, "Ignoring `snap refresh` from the systemd timer")
 		return nil
@@ -1120,6 +1189,8 @@ func init() {
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"time": i18n.G("Show auto refresh information but do not perform a refresh"),
 			// TRANSLATORS: This should not start with a lowercase letter.
+			"dry-run": i18n.G("Show what a refresh would do, including downloads and tasks, but do not perform it"),
+			// TRANSLATORS: This should not start with a lowercase letter.
 			"ignore-validation": i18n.G("Ignore validation by other snaps blocking the refresh"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"ignore-running": i18n.G("Ignore running hooks or applications blocking the refresh"),
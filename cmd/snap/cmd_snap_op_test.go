@@ -1096,6 +1096,80 @@ foo +4.2update1 +17 +bar +-.*
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *SnapSuite) TestRefreshDryRunLessOptions(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatal("expected to get 0 requests")
+	})
+
+	for _, flag := range []string{"--beta", "--channel=potato", "--classic", "--revision=1", "--cohort=x", "--amend", "--ignore-validation"} {
+		_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--dry-run", flag, "some-snap"})
+		c.Assert(err, check.ErrorMatches, "--dry-run does not take mode, channel, revision, cohort or ignore flags")
+	}
+}
+
+func (s *SnapSuite) TestRefreshDryRun(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action":  "refresh",
+				"snaps":   []interface{}{"foo"},
+				"dry-run": true,
+			})
+			fmt.Fprintln(w, `{"type": "sync", "result": {
+				"snaps": ["foo"],
+				"downloads": [
+					{"snap": "foo", "revision": "2", "channel": "latest/stable", "size": 10000},
+					{"snap": "core18", "revision": "5", "size": 20000, "prerequisite": true}
+				],
+				"total-download-size": 30000,
+				"tasks": [
+					{"id": "1", "kind": "prerequisites", "summary": "Ensure prerequisites for \"foo\" are available", "snap": "foo"},
+					{"id": "2", "kind": "download-snap", "summary": "Download snap \"foo\" (2) from channel \"latest/stable\"", "snap": "foo", "wait-tasks": ["1"]}
+				],
+				"affected-snaps": {"bar": ["foo"]},
+				"reboot-required": true
+			}}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--dry-run", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `Name    Rev  Channel        Size  Notes
+foo     2    latest/stable  10kB  -
+core18  5    -              20kB  prerequisite
+
+Total download size: 30kB
+Snaps affected by the refresh:
+  bar (foo)
+A reboot will be required to complete the refresh.
+
+ID   Waits for  Summary
+1    -          Ensure prerequisites for "foo" are available
+2    1          Download snap "foo" (2) from channel "latest/stable"
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestRefreshDryRunNothingToDo(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		fmt.Fprintln(w, `{"type": "sync", "result": {"snaps": [], "downloads": [], "tasks": []}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--dry-run"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "All snaps up to date.\n")
+}
+
 func (s *SnapSuite) TestRefreshLegacyTime(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
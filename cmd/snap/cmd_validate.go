@@ -32,10 +32,10 @@ import (
 )
 
 type cmdValidate struct {
-	clientMixin
-	Monitor bool `long:"monitor"`
-	// XXX: enforce mode is not supported yet
-	Enforce    bool `long:"enforce" hidden:"yes"`
+	waitMixin
+	Monitor    bool `long:"monitor"`
+	Enforce    bool `long:"enforce"`
+	Refresh    bool `long:"refresh"`
 	Forget     bool `long:"forget"`
 	Positional struct {
 		ValidationSet string `positional-arg-name:"<validation-set>"`
@@ -45,16 +45,22 @@ type cmdValidate struct {
 
 var shortValidateHelp = i18n.G("List or apply validation sets")
 var longValidateHelp = i18n.G(`
-The validate command lists or applies validations sets
+The validate command lists or applies validations sets.
+
+With --enforce and --refresh, snaps are installed, refreshed to the required
+revisions or removed as needed for the system to conform to the validation set
+before it is enforced.
 `)
 
 func init() {
-	cmd := addCommand("validate", shortValidateHelp, longValidateHelp, func() flags.Commander { return &cmdValidate{} }, colorDescs.also(map[string]string{
+	cmd := addCommand("validate", shortValidateHelp, longValidateHelp, func() flags.Commander { return &cmdValidate{} }, colorDescs.also(waitDescs).also(map[string]string{
 		// TRANSLATORS: This should not start with a lowercase letter.
 		"monitor": i18n.G("Monitor the given validations set"),
 		// TRANSLATORS: This should not start with a lowercase letter.
 		"enforce": i18n.G("Enforce the given validation set"),
 		// TRANSLATORS: This should not start with a lowercase letter.
+		"refresh": i18n.G("Install, refresh and remove snaps as required by the validation set when enforcing it"),
+		// TRANSLATORS: This should not start with a lowercase letter.
 		"forget": i18n.G("Forget the given validation set"),
 	}), []argDesc{{
 		// TRANSLATORS: This needs to begin with < and end with >
@@ -132,6 +138,10 @@ func (cmd *cmdValidate) Execute(args []string) error {
 		return fmt.Errorf("missing validation set argument")
 	}
 
+	if cmd.Refresh && !cmd.Enforce {
+		return fmt.Errorf("--refresh can only be used together with --enforce")
+	}
+
 	var accountID, name string
 	var seq int
 	var err error
@@ -147,6 +157,20 @@ func (cmd *cmdValidate) Execute(args []string) error {
 		if cmd.Forget {
 			return cmd.client.ForgetValidationSet(accountID, name, seq)
 		}
+		// enforce and bring the snaps into conformity
+		if cmd.Refresh {
+			changeID, err := cmd.client.RefreshAndEnforceValidationSet(accountID, name, seq)
+			if err != nil {
+				return err
+			}
+			if _, err := cmd.wait(changeID); err != nil {
+				if err == noWait {
+					return nil
+				}
+				return err
+			}
+			return nil
+		}
 		// apply
 		opts := &client.ValidateApplyOptions{
 			Mode:     action,
@@ -107,6 +107,8 @@ func (s *validateSuite) TestValidateInvalidArgs(c *check.C) {
 		{[]string{"--monitor"}, `missing validation set argument`},
 		{[]string{"--forget"}, `missing validation set argument`},
 		{[]string{"--forget", "foo/-"}, `cannot parse validation set "foo/-": invalid validation set name "-"`},
+		{[]string{"--refresh", "foo/bar"}, `--refresh can only be used together with --enforce`},
+		{[]string{"--monitor", "--refresh", "foo/bar"}, `--refresh can only be used together with --enforce`},
 	} {
 		s.stdout.Reset()
 		s.stderr.Reset()
@@ -156,6 +158,54 @@ func (s *validateSuite) TestValidateEnforcePinned(c *check.C) {
 	c.Check(s.Stdout(), check.Equals, "")
 }
 
+func (s *validateSuite) TestValidateEnforceRefresh(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch n {
+		case 1:
+			c.Check(r.URL.Path, check.Equals, "/v2/validation-sets/foo/bar")
+			c.Check(r.Method, check.Equals, "POST")
+			buf, err := ioutil.ReadAll(r.Body)
+			c.Assert(err, check.IsNil)
+			c.Check(string(buf), check.Equals, "{\"action\":\"apply\",\"mode\":\"enforce\",\"sequence\":5,\"refresh\":true}\n")
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "42"}`)
+		case 2:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			c.Check(r.Method, check.Equals, "GET")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected 2 requests, got %d", n)
+		}
+	})
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"validate", "--enforce", "--refresh", "foo/bar=5"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(n, check.Equals, 2)
+}
+
+func (s *validateSuite) TestValidateEnforceRefreshNoWait(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.URL.Path, check.Equals, "/v2/validation-sets/foo/bar")
+		c.Check(r.Method, check.Equals, "POST")
+		w.WriteHeader(202)
+		fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "42"}`)
+	})
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"validate", "--enforce", "--refresh", "--no-wait", "foo/bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "42\n")
+	c.Check(n, check.Equals, 1)
+}
+
 func (s *validateSuite) TestValidateForget(c *check.C) {
 	s.RedirectClientToTestServer(makeFakeValidationSetPostHandler(c, `{"type": "sync", "status-code": 200, "result": []}`, "forget", 0))
 
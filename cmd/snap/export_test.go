@@ -137,6 +137,7 @@ var (
 	MaybePrintSum               = (*infoWriter).maybePrintSum
 	MaybePrintCohortKey         = (*infoWriter).maybePrintCohortKey
 	MaybePrintHealth            = (*infoWriter).maybePrintHealth
+	MaybePrintEnvironment       = (*infoWriter).maybePrintEnvironment
 )
 
 func MockPollTime(d time.Duration) (restore func()) {
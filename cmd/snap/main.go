@@ -108,6 +108,9 @@ var debugCommands []*cmdInfo
 // routineCommands holds information about all internal commands.
 var routineCommands []*cmdInfo
 
+// deltaCommands holds information about all delta commands.
+var deltaCommands []*cmdInfo
+
 // addCommand replaces parser.addCommand() in a way that is compatible with
 // re-constructing a pristine parser.
 func addCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
@@ -155,6 +158,22 @@ func addRoutineCommand(name, shortHelp, longHelp string, builder func() flags.Co
 	return info
 }
 
+// addDeltaCommand replaces parser.addCommand() in a way that is
+// compatible with re-constructing a pristine parser. It is meant for
+// adding "snap delta" commands.
+func addDeltaCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
+	info := &cmdInfo{
+		name:      name,
+		shortHelp: shortHelp,
+		longHelp:  longHelp,
+		builder:   builder,
+		optDescs:  optDescs,
+		argDescs:  argDescs,
+	}
+	deltaCommands = append(deltaCommands, info)
+	return info
+}
+
 type parserSetter interface {
 	setParser(*flags.Parser)
 }
@@ -337,7 +356,7 @@ func Parser(cli *client.Client) *flags.Parser {
 	// add --help like what go-flags would do for us, but hidden
 	addHelp(parser)
 
-	seen := make(map[string]bool, len(commands)+len(debugCommands)+len(routineCommands))
+	seen := make(map[string]bool, len(commands)+len(debugCommands)+len(routineCommands)+len(deltaCommands))
 	checkUnique := func(ci *cmdInfo, kind string) {
 		if seen[ci.shortHelp] && ci.shortHelp != "Internal" && ci.shortHelp != "Deprecated (hidden)" {
 			logger.Panicf(`%scommand %q has an already employed description != "Internal"|"Deprecated (hidden)": %s`, kind, ci.name, ci.shortHelp)
@@ -358,6 +377,15 @@ func Parser(cli *client.Client) *flags.Parser {
 	registerCommands(cli, parser, debugCommand, debugCommands, func(ci *cmdInfo) {
 		checkUnique(ci, "debug ")
 	})
+	// Add the delta command
+	deltaCommand, err := parser.AddCommand("delta", shortDeltaHelp, longDeltaHelp, &cmdDelta{})
+	if err != nil {
+		logger.Panicf("cannot add command %q: %v", "delta", err)
+	}
+	// Add all the sub-commands of the delta command
+	registerCommands(cli, parser, deltaCommand, deltaCommands, func(ci *cmdInfo) {
+		checkUnique(ci, "delta ")
+	})
 	// Add the internal command
 	routineCommand, err := parser.AddCommand("routine", shortRoutineHelp, longRoutineHelp, &cmdRoutine{})
 	routineCommand.Hidden = true
@@ -47,6 +47,7 @@ var api = []*Command{
 	snapFileCmd,
 	snapDownloadCmd,
 	snapConfCmd,
+	snapEnvCmd,
 	interfacesCmd,
 	assertsCmd,
 	assertsFindManyCmd,
@@ -133,10 +134,12 @@ func storeFrom(d *Daemon) snapstate.StoreService {
 var (
 	snapstateInstall           = snapstate.Install
 	snapstateInstallPath       = snapstate.InstallPath
+	snapstateApplyDelta        = snapstate.ApplyDelta
 	snapstateRefreshCandidates = snapstate.RefreshCandidates
 	snapstateTryPath           = snapstate.TryPath
 	snapstateUpdate            = snapstate.Update
 	snapstateUpdateMany        = snapstate.UpdateMany
+	snapstatePlanUpdateMany    = snapstate.PlanUpdateMany
 	snapstateInstallMany       = snapstate.InstallMany
 	snapstateRemoveMany        = snapstate.RemoveMany
 	snapstateRevert            = snapstate.Revert
@@ -214,8 +214,15 @@ func getLogs(c *Command, r *http.Request, user *auth.UserState) Response {
 		serviceNames[i] = appInfo.ServiceName()
 	}
 
+	// services of snaps in quota groups with a journal quota log into the
+	// journal namespace of their group, which needs to be read too
+	namespaces, err := appsUseJournalNamespaces(c.d.overlord.State(), appInfos)
+	if err != nil {
+		return InternalError("cannot get logs: %v", err)
+	}
+
 	sysd := systemd.New(systemd.SystemMode, progress.Null)
-	reader, err := sysd.LogReader(serviceNames, n, follow)
+	reader, err := sysd.LogReader(serviceNames, n, follow, namespaces)
 	if err != nil {
 		return InternalError("cannot get logs: %v", err)
 	}
@@ -226,6 +233,37 @@ func getLogs(c *Command, r *http.Request, user *auth.UserState) Response {
 	}
 }
 
+// appsUseJournalNamespaces returns whether any of the given apps belongs to
+// a snap in a quota group with a journal quota, either its own or inherited
+// from a parent group.
+func appsUseJournalNamespaces(st *state.State, appInfos []*snap.AppInfo) (bool, error) {
+	st.Lock()
+	defer st.Unlock()
+
+	grps, err := servicestate.AllQuotas(st)
+	if err != nil {
+		return false, err
+	}
+
+	snapsWithNamespace := make(map[string]bool)
+	for _, grp := range grps {
+		// sub-groups log into the journal namespace of their parent
+		if grp.JournalQuotaGroup() == nil {
+			continue
+		}
+		for _, sn := range grp.Snaps {
+			snapsWithNamespace[sn] = true
+		}
+	}
+
+	for _, appInfo := range appInfos {
+		if snapsWithNamespace[appInfo.Snap.InstanceName()] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 var servicestateControl = servicestate.Control
 
 func postApps(c *Command, r *http.Request, user *auth.UserState) Response {
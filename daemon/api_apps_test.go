@@ -37,11 +37,14 @@ import (
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/servicestate/servicestatetest"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/testutil"
 )
@@ -55,6 +58,7 @@ type appsSuite struct {
 	jctlSvcses         [][]string
 	jctlNs             []int
 	jctlFollows        []bool
+	jctlNamespaces     []bool
 	jctlRCs            []io.ReadCloser
 	jctlErrs           []error
 
@@ -64,10 +68,11 @@ type appsSuite struct {
 	infoA, infoB, infoC, infoD, infoE *snap.Info
 }
 
-func (s *appsSuite) journalctl(svcs []string, n int, follow bool) (rc io.ReadCloser, err error) {
+func (s *appsSuite) journalctl(svcs []string, n int, follow, namespaces bool) (rc io.ReadCloser, err error) {
 	s.jctlSvcses = append(s.jctlSvcses, svcs)
 	s.jctlNs = append(s.jctlNs, n)
 	s.jctlFollows = append(s.jctlFollows, follow)
+	s.jctlNamespaces = append(s.jctlNamespaces, namespaces)
 
 	if len(s.jctlErrs) > 0 {
 		err, s.jctlErrs = s.jctlErrs[0], s.jctlErrs[1:]
@@ -132,6 +137,7 @@ func (s *appsSuite) SetUpTest(c *check.C) {
 	s.jctlSvcses = nil
 	s.jctlNs = nil
 	s.jctlFollows = nil
+	s.jctlNamespaces = nil
 	s.jctlRCs = nil
 	s.jctlErrs = nil
 
@@ -646,6 +652,7 @@ func (s *appsSuite) TestLogs(c *check.C) {
 	c.Check(s.jctlSvcses, check.DeepEquals, [][]string{{"snap.snap-a.svc2.service"}})
 	c.Check(s.jctlNs, check.DeepEquals, []int{42})
 	c.Check(s.jctlFollows, check.DeepEquals, []bool{false})
+	c.Check(s.jctlNamespaces, check.DeepEquals, []bool{false})
 
 	c.Check(rec.Code, check.Equals, 200)
 	c.Check(rec.HeaderMap.Get("Content-Type"), check.Equals, "application/json-seq")
@@ -658,6 +665,39 @@ func (s *appsSuite) TestLogs(c *check.C) {
 `[1:])
 }
 
+func (s *appsSuite) TestLogsJournalNamespace(c *check.C) {
+	s.expectLogsAccess()
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestatetest.MockQuotaInState(st, "foo", "", []string{"snap-a"}, quantity.SizeGiB)
+	c.Assert(err, check.IsNil)
+	grps, err := servicestate.AllQuotas(st)
+	c.Assert(err, check.IsNil)
+	grps["foo"].JournalLimit = &quota.JournalQuota{Size: quantity.SizeMiB}
+	_, err = servicestatetest.PatchQuotas(st, grps["foo"])
+	c.Assert(err, check.IsNil)
+	st.Unlock()
+
+	s.jctlRCs = []io.ReadCloser{ioutil.NopCloser(strings.NewReader("")), ioutil.NopCloser(strings.NewReader(""))}
+
+	// services of snaps in a group with a journal quota read all namespaces
+	req, err := http.NewRequest("GET", "/v2/logs?names=snap-a.svc2", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	s.req(c, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+
+	// but other snaps only read the default journal
+	req, err = http.NewRequest("GET", "/v2/logs?names=snap-b", nil)
+	c.Assert(err, check.IsNil)
+	rec = httptest.NewRecorder()
+	s.req(c, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+
+	c.Check(s.jctlNamespaces, check.DeepEquals, []bool{true, false})
+}
+
 func (s *appsSuite) TestLogsN(c *check.C) {
 	s.expectLogsAccess()
 
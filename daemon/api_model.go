@@ -21,13 +21,23 @@ package daemon
 
 import (
 	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 var (
@@ -45,10 +55,15 @@ var (
 	}
 )
 
-var devicestateRemodel = devicestate.Remodel
+var (
+	devicestateRemodel               = devicestate.Remodel
+	devicestateRemodelWithLocalSnaps = devicestate.RemodelWithLocalSnaps
+	devicestatePlanRemodel           = devicestate.PlanRemodel
+)
 
 type postModelData struct {
 	NewModel string `json:"new-model"`
+	DryRun   bool   `json:"dry-run,omitempty"`
 }
 
 type modelAssertJSON struct {
@@ -58,32 +73,184 @@ type modelAssertJSON struct {
 
 func postModel(c *Command, r *http.Request, _ *auth.UserState) Response {
 	defer r.Body.Close()
+
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") {
+		return remodelWithLocalSnaps(c, r.Body, params["boundary"])
+	}
+
 	var data postModelData
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&data); err != nil {
 		return BadRequest("cannot decode request body into remodel operation: %v", err)
 	}
-	rawNewModel, err := asserts.Decode([]byte(data.NewModel))
+	newModel, rsp := decodeNewModel(data.NewModel)
+	if rsp != nil {
+		return rsp
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if data.DryRun {
+		plan, err := devicestatePlanRemodel(st, newModel)
+		if err != nil {
+			return BadRequest("cannot remodel device: %v", err)
+		}
+		return SyncResponse(plan)
+	}
+
+	chg, err := devicestateRemodel(st, newModel)
+	if err != nil {
+		return BadRequest("cannot remodel device: %v", err)
+	}
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, chg.ID())
+
+}
+
+func decodeNewModel(rawModel string) (*asserts.Model, Response) {
+	rawNewModel, err := asserts.Decode([]byte(rawModel))
 	if err != nil {
-		return BadRequest("cannot decode new model assertion: %v", err)
+		return nil, BadRequest("cannot decode new model assertion: %v", err)
 	}
 	newModel, ok := rawNewModel.(*asserts.Model)
 	if !ok {
-		return BadRequest("new model is not a model assertion: %v", newModel.Type())
+		return nil, BadRequest("new model is not a model assertion: %v", rawNewModel.Type())
+	}
+	return newModel, nil
+}
+
+// remodelWithLocalSnaps remodels the device using the snap files and
+// assertions uploaded with the new model, so that no store access is
+// needed. Like for sideloaded snaps, each snap must be accompanied by
+// the assertions for it.
+func remodelWithLocalSnaps(c *Command, body io.Reader, boundary string) Response {
+	form, err := multipart.NewReader(body, boundary).ReadForm(maxReadBuflen)
+	if err != nil {
+		return BadRequest("cannot read POST form: %v", err)
+	}
+	defer form.RemoveAll()
+
+	if len(form.Value["new-model"]) != 1 {
+		return BadRequest("cannot find a single new model assertion in provided multipart/form-data payload")
+	}
+	newModel, rsp := decodeNewModel(form.Value["new-model"][0])
+	if rsp != nil {
+		return rsp
+	}
+
+	batch := asserts.NewBatch(nil)
+	for _, fheader := range form.File["assertion"] {
+		if rsp := addAssertionsFromFormFile(batch, fheader); rsp != nil {
+			return rsp
+		}
+	}
+
+	// we are in charge of the tempfiles life cycle until we hand
+	// them off to the change
+	changeTriggered := false
+	var tempPaths []string
+	defer func() {
+		if !changeTriggered {
+			for _, tempPath := range tempPaths {
+				os.Remove(tempPath)
+			}
+		}
+	}()
+	var origPaths []string
+	for _, fheader := range form.File["snap"] {
+		tempPath, rsp := copyFormFileToSnapBlob(fheader)
+		if rsp != nil {
+			return rsp
+		}
+		tempPaths = append(tempPaths, tempPath)
+		origPaths = append(origPaths, fheader.Filename)
+	}
+	if len(tempPaths) == 0 {
+		return BadRequest(`cannot find "snap" file field in provided multipart/form-data payload`)
 	}
 
 	st := c.d.overlord.State()
 	st.Lock()
 	defer st.Unlock()
 
-	chg, err := devicestateRemodel(st, newModel)
+	// the assertions are only added to the system database once the
+	// remodel is accepted, until then they are checked and used from a
+	// temporary database
+	tempDB := assertstate.TemporaryDB(st)
+	if err := batch.CommitTo(tempDB, &asserts.CommitOptions{
+		Precheck: true,
+	}); err != nil {
+		return BadRequest("cannot add assertions for the local snaps: %v", err)
+	}
+
+	sideInfos := make([]*snap.SideInfo, len(tempPaths))
+	for i, tempPath := range tempPaths {
+		si, err := snapasserts.DeriveSideInfo(tempPath, tempDB)
+		switch {
+		case err == nil:
+			sideInfos[i] = si
+		case asserts.IsNotFound(err):
+			return BadRequest("cannot find signatures with metadata for snap %q", origPaths[i])
+		default:
+			return BadRequest(err.Error())
+		}
+	}
+
+	chg, err := devicestateRemodelWithLocalSnaps(st, newModel, sideInfos, tempPaths)
 	if err != nil {
 		return BadRequest("cannot remodel device: %v", err)
 	}
+	// the change cannot run before the state is unlocked
+	if err := assertstate.AddBatch(st, batch, &asserts.CommitOptions{
+		Precheck: true,
+	}); err != nil {
+		chg.Abort()
+		return BadRequest("cannot add assertions for the local snaps: %v", err)
+	}
+	changeTriggered = true
 	ensureStateSoon(st)
 
 	return AsyncResponse(nil, chg.ID())
+}
+
+func addAssertionsFromFormFile(batch *asserts.Batch, fheader *multipart.FileHeader) Response {
+	f, err := fheader.Open()
+	if err != nil {
+		return BadRequest(`cannot open uploaded "assertion" file: %v`, err)
+	}
+	defer f.Close()
+	if _, err := batch.AddStream(f); err != nil {
+		return BadRequest("cannot decode assertions from %q: %v", fheader.Filename, err)
+	}
+	return nil
+}
+
+func copyFormFileToSnapBlob(fheader *multipart.FileHeader) (tempPath string, rsp Response) {
+	snapBody, err := fheader.Open()
+	if err != nil {
+		return "", BadRequest(`cannot open uploaded "snap" file: %v`, err)
+	}
+	defer snapBody.Close()
+
+	// if you change this prefix, look for it in the tests
+	// also see localInstallCleanup in snapstate/snapmgr.go
+	tmpf, err := ioutil.TempFile(dirs.SnapBlobDir, dirs.LocalInstallBlobTempPrefix)
+	if err != nil {
+		return "", InternalError("cannot create temporary file: %v", err)
+	}
+	defer tmpf.Close()
+
+	if _, err := io.Copy(tmpf, snapBody); err != nil {
+		os.Remove(tmpf.Name())
+		return "", InternalError("cannot copy request into temporary file: %v", err)
+	}
+	tmpf.Sync()
 
+	return tmpf.Name(), nil
 }
 
 // getModel gets the current model assertion using the DeviceManager
@@ -22,9 +22,12 @@ package daemon_test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"time"
 
 	"gopkg.in/check.v1"
@@ -33,12 +36,17 @@ import (
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/assertstate/assertstatetest"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
 )
 
 var modelDefaults = map[string]interface{}{
@@ -132,6 +140,239 @@ func (s *modelSuite) TestPostRemodel(c *check.C) {
 	c.Assert(soon, check.Equals, 1)
 }
 
+func (s *modelSuite) TestPostRemodelDryRun(c *check.C) {
+	s.expectRootAccess()
+
+	oldModel := s.Brands.Model("my-brand", "my-old-model", modelDefaults)
+	newModel := s.Brands.Model("my-brand", "my-old-model", modelDefaults, map[string]interface{}{
+		"revision": "2",
+	})
+
+	d := s.daemonWithOverlordMockAndStore(c)
+	st := d.Overlord().State()
+	st.Lock()
+	assertstatetest.AddMany(st, s.StoreSigning.StoreAccountKey(""))
+	assertstatetest.AddMany(st, s.Brands.AccountsAndKeys("my-brand")...)
+	s.mockModel(c, st, oldModel)
+	st.Unlock()
+
+	defer daemon.MockDevicestateRemodel(func(st *state.State, nm *asserts.Model) (*state.Change, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})()
+	var gotModel *asserts.Model
+	defer daemon.MockDevicestatePlanRemodel(func(st *state.State, nm *asserts.Model) (*devicestate.RemodelPlan, error) {
+		gotModel = nm
+		return &devicestate.RemodelPlan{
+			Kind: "revision update remodel",
+			Snaps: []devicestate.PlannedRemodelSnap{
+				{Name: "foo", Role: "required", Channel: "stable", Action: "install", Revision: snap.R(3)},
+			},
+			StoreChecked: true,
+			Tasks: []snapstate.PlannedTask{
+				{ID: "1", Kind: "set-model", Summary: "Set new model assertion"},
+			},
+		}, nil
+	})()
+
+	data, err := json.Marshal(daemon.PostModelData{NewModel: string(asserts.Encode(newModel)), DryRun: true})
+	c.Check(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/model", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(gotModel, check.DeepEquals, newModel)
+	c.Check(rsp.Result, check.DeepEquals, &devicestate.RemodelPlan{
+		Kind: "revision update remodel",
+		Snaps: []devicestate.PlannedRemodelSnap{
+			{Name: "foo", Role: "required", Channel: "stable", Action: "install", Revision: snap.R(3)},
+		},
+		StoreChecked: true,
+		Tasks: []snapstate.PlannedTask{
+			{ID: "1", Kind: "set-model", Summary: "Set new model assertion"},
+		},
+	})
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
+func (s *modelSuite) TestPostRemodelDryRunError(c *check.C) {
+	s.expectRootAccess()
+
+	newModel := s.Brands.Model("my-brand", "my-old-model", modelDefaults)
+	s.daemonWithOverlordMockAndStore(c)
+
+	defer daemon.MockDevicestatePlanRemodel(func(st *state.State, nm *asserts.Model) (*devicestate.RemodelPlan, error) {
+		return nil, errors.New("cannot remodel until fully seeded")
+	})()
+
+	data, err := json.Marshal(daemon.PostModelData{NewModel: string(asserts.Encode(newModel)), DryRun: true})
+	c.Check(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/model", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Assert(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "cannot remodel device: cannot remodel until fully seeded")
+}
+
+func (s *modelSuite) remodelLocalSnapsRequest(c *check.C, newModel *asserts.Model, assertions []asserts.Assertion) *http.Request {
+	buf := bytes.NewBuffer(nil)
+	mw := multipart.NewWriter(buf)
+	c.Assert(mw.WriteField("new-model", string(asserts.Encode(newModel))), check.IsNil)
+	if len(assertions) > 0 {
+		fw, err := mw.CreateFormFile("assertion", "bundle.assert")
+		c.Assert(err, check.IsNil)
+		enc := asserts.NewEncoder(fw)
+		for _, a := range assertions {
+			c.Assert(enc.Encode(a), check.IsNil)
+		}
+	}
+	fw, err := mw.CreateFormFile("snap", "x.snap")
+	c.Assert(err, check.IsNil)
+	_, err = fw.Write([]byte("xyzzy"))
+	c.Assert(err, check.IsNil)
+	c.Assert(mw.Close(), check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/model", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func (s *modelSuite) localSnapAssertions(c *check.C) []asserts.Assertion {
+	dev1Acct := assertstest.NewAccount(s.StoreSigning, "devel1", nil, "")
+	snapDecl, err := s.StoreSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+		"series":       "16",
+		"snap-id":      "x-id",
+		"snap-name":    "x",
+		"publisher-id": dev1Acct.AccountID(),
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	snapRev, err := s.StoreSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-sha3-384": "YK0GWATaZf09g_fvspYPqm_qtaiqf-KjaNj5uMEQCjQpuXWPjqQbeBINL5H_A0Lo",
+		"snap-size":     "5",
+		"snap-id":       "x-id",
+		"snap-revision": "41",
+		"developer-id":  dev1Acct.AccountID(),
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	return []asserts.Assertion{dev1Acct, snapDecl, snapRev}
+}
+
+func (s *modelSuite) TestPostRemodelLocalSnaps(c *check.C) {
+	s.expectRootAccess()
+
+	newModel := s.Brands.Model("my-brand", "my-old-model", modelDefaults, map[string]interface{}{
+		"revision": "2",
+	})
+	d := s.daemonWithOverlordMockAndStore(c)
+	st := d.Overlord().State()
+	st.Lock()
+	assertstatetest.AddMany(st, s.StoreSigning.StoreAccountKey(""))
+	st.Unlock()
+
+	defer daemon.MockDevicestateRemodel(func(st *state.State, nm *asserts.Model) (*state.Change, error) {
+		c.Fatalf("unexpected remodel without local snaps")
+		return nil, nil
+	})()
+	var tempPaths []string
+	defer daemon.MockDevicestateRemodelWithLocalSnaps(func(st *state.State, nm *asserts.Model, localSnaps []*snap.SideInfo, paths []string) (*state.Change, error) {
+		c.Check(nm, check.DeepEquals, newModel)
+		c.Check(localSnaps, check.DeepEquals, []*snap.SideInfo{{
+			RealName: "x",
+			SnapID:   "x-id",
+			Revision: snap.R(41),
+		}})
+		c.Assert(paths, check.HasLen, 1)
+		c.Check(filepath.Dir(paths[0]), check.Equals, dirs.SnapBlobDir)
+		c.Check(paths[0], testutil.FileEquals, "xyzzy")
+		tempPaths = paths
+		// the assertions are not added before the remodel is accepted
+		_, err := assertstate.SnapDeclaration(st, "x-id")
+		c.Check(asserts.IsNotFound(err), check.Equals, true)
+		return st.NewChange("remodel", "..."), nil
+	})()
+
+	req := s.remodelLocalSnapsRequest(c, newModel, s.localSnapAssertions(c))
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+
+	// the snap file is handed off to the change
+	c.Assert(tempPaths, check.HasLen, 1)
+	c.Check(tempPaths[0], testutil.FilePresent)
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "remodel")
+
+	_, err := assertstate.SnapDeclaration(st, "x-id")
+	c.Check(err, check.IsNil)
+}
+
+func (s *modelSuite) TestPostRemodelLocalSnapsNoSignatures(c *check.C) {
+	s.expectRootAccess()
+
+	newModel := s.Brands.Model("my-brand", "my-old-model", modelDefaults, map[string]interface{}{
+		"revision": "2",
+	})
+	s.daemonWithOverlordMockAndStore(c)
+
+	defer daemon.MockDevicestateRemodelWithLocalSnaps(func(st *state.State, nm *asserts.Model, localSnaps []*snap.SideInfo, paths []string) (*state.Change, error) {
+		c.Fatalf("unexpected remodel")
+		return nil, nil
+	})()
+
+	req := s.remodelLocalSnapsRequest(c, newModel, nil)
+	rspe := s.errorReq(c, req, nil)
+	c.Assert(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot find signatures with metadata for snap "x.snap"`)
+
+	// the temporary snap file was removed
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapBlobDir, dirs.LocalInstallBlobTempPrefix+"*"))
+	c.Assert(err, check.IsNil)
+	c.Check(matches, check.HasLen, 0)
+}
+
+func (s *modelSuite) TestPostRemodelLocalSnapsError(c *check.C) {
+	s.expectRootAccess()
+
+	newModel := s.Brands.Model("my-brand", "my-old-model", modelDefaults, map[string]interface{}{
+		"revision": "2",
+	})
+	d := s.daemonWithOverlordMockAndStore(c)
+	st := d.Overlord().State()
+	st.Lock()
+	assertstatetest.AddMany(st, s.StoreSigning.StoreAccountKey(""))
+	st.Unlock()
+
+	defer daemon.MockDevicestateRemodelWithLocalSnaps(func(st *state.State, nm *asserts.Model, localSnaps []*snap.SideInfo, paths []string) (*state.Change, error) {
+		return nil, errors.New(`cannot remodel with snap "x" not required by the new model`)
+	})()
+
+	req := s.remodelLocalSnapsRequest(c, newModel, s.localSnapAssertions(c))
+	rspe := s.errorReq(c, req, nil)
+	c.Assert(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot remodel device: cannot remodel with snap "x" not required by the new model`)
+
+	// the assertions of the refused remodel were not added
+	st.Lock()
+	_, err := assertstate.SnapDeclaration(st, "x-id")
+	st.Unlock()
+	c.Check(asserts.IsNotFound(err), check.Equals, true)
+
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapBlobDir, dirs.LocalInstallBlobTempPrefix+"*"))
+	c.Assert(err, check.IsNil)
+	c.Check(matches, check.HasLen, 0)
+}
+
 func (s *modelSuite) TestGetModelNoModelAssertion(c *check.C) {
 
 	d := s.daemonWithOverlordMockAndStore(c)
@@ -63,6 +63,33 @@ var (
 	servicestateRemoveQuota = servicestate.RemoveQuota
 )
 
+// journalQuotaFromValues converts the journal constraints of a request into
+// a journal quota, it returns nil if no or empty journal constraints were
+// given.
+func journalQuotaFromValues(values *client.QuotaJournalValues) *quota.JournalQuota {
+	if values == nil || *values == (client.QuotaJournalValues{}) {
+		return nil
+	}
+	return &quota.JournalQuota{
+		Size:       values.Size,
+		RateCount:  values.RateCount,
+		RatePeriod: values.RatePeriod,
+	}
+}
+
+// journalValuesFromQuota converts the journal quota of a group into journal
+// constraints for a response.
+func journalValuesFromQuota(jq *quota.JournalQuota) *client.QuotaJournalValues {
+	if jq == nil {
+		return nil
+	}
+	return &client.QuotaJournalValues{
+		Size:       jq.Size,
+		RateCount:  jq.RateCount,
+		RatePeriod: jq.RatePeriod,
+	}
+}
+
 var getQuotaMemUsage = func(grp *quota.Group) (quantity.Size, error) {
 	return grp.CurrentMemoryUsage()
 }
@@ -101,7 +128,8 @@ func getQuotaGroups(c *Command, r *http.Request, _ *auth.UserState) Response {
 			Subgroups: group.SubGroups,
 			Snaps:     group.Snaps,
 			Constraints: &client.QuotaValues{
-				Memory: group.MemoryLimit,
+				Memory:  group.MemoryLimit,
+				Journal: journalValuesFromQuota(group.JournalLimit),
 			},
 			Current: &client.QuotaValues{
 				Memory: memoryUsage,
@@ -142,7 +170,8 @@ func getQuotaGroupInfo(c *Command, r *http.Request, _ *auth.UserState) Response
 		Snaps:     group.Snaps,
 		Subgroups: group.SubGroups,
 		Constraints: &client.QuotaValues{
-			Memory: group.MemoryLimit,
+			Memory:  group.MemoryLimit,
+			Journal: journalValuesFromQuota(group.JournalLimit),
 		},
 		Current: &client.QuotaValues{
 			Memory: memoryUsage,
@@ -181,16 +210,20 @@ func postQuotaGroup(c *Command, r *http.Request, _ *auth.UserState) Response {
 		}
 		if err == servicestate.ErrQuotaNotFound {
 			// then we need to create the quota
-			ts, err = servicestateCreateQuota(st, data.GroupName, data.Parent, data.Snaps, data.Constraints.Memory)
+			ts, err = servicestateCreateQuota(st, data.GroupName, data.Parent, data.Snaps, data.Constraints.Memory, journalQuotaFromValues(data.Constraints.Journal))
 			if err != nil {
 				return errToResponse(err, nil, BadRequest, "cannot create quota group: %v")
 			}
 			chgSummary = "Create quota group"
 		} else if err == nil {
 			// the quota group already exists, update it
+			// empty journal constraints remove the journal limit
+			journal := data.Constraints.Journal
 			updateOpts := servicestate.QuotaGroupUpdate{
-				AddSnaps:       data.Snaps,
-				NewMemoryLimit: data.Constraints.Memory,
+				AddSnaps:           data.Snaps,
+				NewMemoryLimit:     data.Constraints.Memory,
+				NewJournalLimit:    journalQuotaFromValues(journal),
+				RemoveJournalLimit: journal != nil && *journal == (client.QuotaJournalValues{}),
 			}
 			ts, err = servicestateUpdateQuota(st, data.GroupName, updateOpts)
 			if err != nil {
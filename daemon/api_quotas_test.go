@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -103,7 +104,7 @@ func (s *apiQuotaSuite) TestPostQuotaInvalidGroupName(c *check.C) {
 }
 
 func (s *apiQuotaSuite) TestPostEnsureQuotaUnhappy(c *check.C) {
-	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size) (*state.TaskSet, error) {
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size, journalLimit *quota.JournalQuota) (*state.TaskSet, error) {
 		c.Check(name, check.Equals, "booze")
 		c.Check(parentName, check.Equals, "foo")
 		c.Check(snaps, check.DeepEquals, []string{"bar"})
@@ -131,7 +132,7 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaUnhappy(c *check.C) {
 
 func (s *apiQuotaSuite) TestPostEnsureQuotaCreateHappy(c *check.C) {
 	var createCalled int
-	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size) (*state.TaskSet, error) {
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size, journalLimit *quota.JournalQuota) (*state.TaskSet, error) {
 		createCalled++
 		c.Check(name, check.Equals, "booze")
 		c.Check(parentName, check.Equals, "foo")
@@ -159,9 +160,46 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaCreateHappy(c *check.C) {
 	c.Assert(s.ensureSoonCalled, check.Equals, 1)
 }
 
+func (s *apiQuotaSuite) TestPostEnsureQuotaCreateWithJournalHappy(c *check.C) {
+	var createCalled int
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size, journalLimit *quota.JournalQuota) (*state.TaskSet, error) {
+		createCalled++
+		c.Check(name, check.Equals, "booze")
+		c.Check(memoryLimit, check.DeepEquals, quantity.Size(1000))
+		c.Check(journalLimit, check.DeepEquals, &quota.JournalQuota{
+			Size:       quantity.SizeMiB,
+			RateCount:  20,
+			RatePeriod: time.Minute,
+		})
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "booze",
+		Constraints: client.QuotaValues{
+			Memory: quantity.Size(1000),
+			Journal: &client.QuotaJournalValues{
+				Size:       quantity.SizeMiB,
+				RateCount:  20,
+				RatePeriod: time.Minute,
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(createCalled, check.Equals, 1)
+}
+
 func (s *apiQuotaSuite) TestPostEnsureQuotaCreateQuotaConflicts(c *check.C) {
 	var createCalled int
-	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size) (*state.TaskSet, error) {
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size, journalLimit *quota.JournalQuota) (*state.TaskSet, error) {
 		c.Check(name, check.Equals, "booze")
 		c.Check(parentName, check.Equals, "foo")
 		c.Check(snaps, check.DeepEquals, []string{"some-snap"})
@@ -224,7 +262,7 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateHappy(c *check.C) {
 	st.Unlock()
 	c.Assert(err, check.IsNil)
 
-	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size) (*state.TaskSet, error) {
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size, journalLimit *quota.JournalQuota) (*state.TaskSet, error) {
 		c.Errorf("should not have called create quota")
 		return nil, fmt.Errorf("broken test")
 	})
@@ -259,6 +297,40 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateHappy(c *check.C) {
 	c.Assert(s.ensureSoonCalled, check.Equals, 1)
 }
 
+func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateRemoveJournal(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestatetest.MockQuotaInState(st, "ginger-ale", "", nil, 5000)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	updateCalled := 0
+	r := daemon.MockServicestateUpdateQuota(func(st *state.State, name string, opts servicestate.QuotaGroupUpdate) (*state.TaskSet, error) {
+		updateCalled++
+		c.Assert(name, check.Equals, "ginger-ale")
+		// empty journal constraints remove the journal limit
+		c.Assert(opts, check.DeepEquals, servicestate.QuotaGroupUpdate{
+			RemoveJournalLimit: true,
+		})
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:      "ensure",
+		GroupName:   "ginger-ale",
+		Constraints: client.QuotaValues{Journal: &client.QuotaJournalValues{}},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(updateCalled, check.Equals, 1)
+}
+
 func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateConflicts(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
@@ -266,7 +338,7 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateConflicts(c *check.C) {
 	st.Unlock()
 	c.Assert(err, check.IsNil)
 
-	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size) (*state.TaskSet, error) {
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size, journalLimit *quota.JournalQuota) (*state.TaskSet, error) {
 		c.Errorf("should not have called create quota")
 		return nil, fmt.Errorf("broken test")
 	})
@@ -544,6 +616,37 @@ func (s *apiQuotaSuite) TestGetQuota(c *check.C) {
 	c.Check(s.ensureSoonCalled, check.Equals, 0)
 }
 
+func (s *apiQuotaSuite) TestGetQuotaWithJournal(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	mockQuotas(st, c)
+	grps, err := servicestate.AllQuotas(st)
+	c.Assert(err, check.IsNil)
+	grps["bar"].JournalLimit = &quota.JournalQuota{Size: quantity.SizeMiB}
+	_, err = servicestatetest.PatchQuotas(st, grps["bar"])
+	c.Assert(err, check.IsNil)
+	st.Unlock()
+
+	r := daemon.MockGetQuotaMemUsage(func(grp *quota.Group) (quantity.Size, error) {
+		return quantity.Size(500), nil
+	})
+	defer r()
+
+	req, err := http.NewRequest("GET", "/v2/quotas/bar", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, client.QuotaGroupResult{
+		GroupName: "bar",
+		Parent:    "foo",
+		Constraints: &client.QuotaValues{
+			Memory:  quantity.Size(6000),
+			Journal: &client.QuotaJournalValues{Size: quantity.SizeMiB},
+		},
+		Current: &client.QuotaValues{Memory: quantity.Size(500)},
+	})
+}
+
 func (s *apiQuotaSuite) TestGetQuotaInvalidName(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
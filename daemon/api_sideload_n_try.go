@@ -137,6 +137,28 @@ out:
 	st.Lock()
 	defer st.Unlock()
 
+	fromDelta := isTrue(form, "delta")
+	if fromDelta {
+		if instanceName == "" {
+			return BadRequest("cannot install from a delta without a snap name")
+		}
+		var fromRev snap.Revision
+		if len(form.Value["delta-from"]) > 0 {
+			fromRev, err = snap.ParseRevision(form.Value["delta-from"][0])
+			if err != nil {
+				return BadRequest("invalid delta source revision: %v", err)
+			}
+		}
+		snapPath, rsp := applySideloadDelta(st, instanceName, fromRev, tempPath)
+		if rsp != nil {
+			return rsp
+		}
+		// from here on the reconstructed snap is verified and
+		// installed like any other sideloaded snap
+		os.Remove(tempPath)
+		tempPath = snapPath
+	}
+
 	var snapName string
 	var sideInfo *snap.SideInfo
 
@@ -182,7 +204,12 @@ out:
 	}
 
 	msg := fmt.Sprintf(i18n.G("Install %q snap from file"), instanceName)
-	if origPath != "" {
+	switch {
+	case fromDelta && origPath != "":
+		msg = fmt.Sprintf(i18n.G("Install %q snap from delta %q"), instanceName, origPath)
+	case fromDelta:
+		msg = fmt.Sprintf(i18n.G("Install %q snap from delta"), instanceName)
+	case origPath != "":
 		msg = fmt.Sprintf(i18n.G("Install %q snap from file %q"), instanceName, origPath)
 	}
 
@@ -203,6 +230,24 @@ out:
 	return AsyncResponse(nil, chg.ID())
 }
 
+// applySideloadDelta reconstructs a snap file by applying the uploaded
+// delta to the given revision of the installed snap, returning the path
+// of the reconstructed file.
+func applySideloadDelta(st *state.State, instanceName string, fromRev snap.Revision, deltaPath string) (string, Response) {
+	tmpf, err := ioutil.TempFile(dirs.SnapBlobDir, dirs.LocalInstallBlobTempPrefix)
+	if err != nil {
+		return "", InternalError("cannot create temporary file: %v", err)
+	}
+	tmpf.Close()
+	snapPath := tmpf.Name()
+
+	if err := snapstateApplyDelta(st, instanceName, fromRev, deltaPath, snapPath); err != nil {
+		os.Remove(snapPath)
+		return "", errToResponse(err, []string{instanceName}, BadRequest, "cannot install snap from delta: %v")
+	}
+	return snapPath, nil
+}
+
 func trySnap(st *state.State, trydir string, flags snapstate.Flags) Response {
 	st.Lock()
 	defer st.Unlock()
@@ -457,6 +457,82 @@ func (s *sideloadSuite) TestSideloadSnapInstanceNameMismatch(c *check.C) {
 	c.Check(rspe.Message, check.Equals, `instance name "foo_instance" does not match snap name "bar"`)
 }
 
+func (s *sideloadSuite) TestSideloadSnapFromDelta(c *check.C) {
+	body := sideLoadBodyWithoutDevMode +
+		"Content-Disposition: form-data; name=\"name\"\r\n" +
+		"\r\n" +
+		"local\r\n" +
+		"----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"delta\"\r\n" +
+		"\r\n" +
+		"true\r\n" +
+		"----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"delta-from\"\r\n" +
+		"\r\n" +
+		"7\r\n" +
+		"----hello--\r\n"
+	head := map[string]string{"Content-Type": "multipart/thing; boundary=--hello--"}
+
+	var deltaPaths []string
+	defer daemon.MockSnapstateApplyDelta(func(st *state.State, instanceName string, fromRev snap.Revision, deltaPath, targetPath string) error {
+		c.Check(instanceName, check.Equals, "local")
+		c.Check(fromRev, check.Equals, snap.R(7))
+		c.Check(deltaPath, testutil.FileEquals, "xyzzy")
+		c.Check(targetPath, check.Not(check.Equals), deltaPath)
+		deltaPaths = append(deltaPaths, deltaPath)
+		// the reconstructed snap is what gets installed
+		return ioutil.WriteFile(targetPath, []byte("xyzzy"), 0600)
+	})()
+
+	chgSummary := s.sideloadCheck(c, body, head, "local", snapstate.Flags{RemoveSnapPath: true})
+	c.Check(chgSummary, check.Equals, `Install "local" snap from delta "a/b/local.snap"`)
+	c.Assert(deltaPaths, check.HasLen, 1)
+	c.Check(deltaPaths[0], testutil.FileAbsent)
+}
+
+func (s *sideloadSuite) TestSideloadSnapFromDeltaErrors(c *check.C) {
+	s.daemonWithFakeSnapManager(c)
+
+	defer daemon.MockSnapstateApplyDelta(func(st *state.State, instanceName string, fromRev snap.Revision, deltaPath, targetPath string) error {
+		c.Check(fromRev.Unset(), check.Equals, true)
+		return &snap.NotInstalledError{Snap: instanceName}
+	})()
+
+	deltaBody := sideLoadBodyWithoutDevMode +
+		"Content-Disposition: form-data; name=\"delta\"\r\n" +
+		"\r\n" +
+		"true\r\n" +
+		"----hello--\r\n"
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{deltaBody, "cannot install from a delta without a snap name"},
+		{deltaBody +
+			"Content-Disposition: form-data; name=\"name\"\r\n" +
+			"\r\n" +
+			"local\r\n" +
+			"----hello--\r\n" +
+			"Content-Disposition: form-data; name=\"delta-from\"\r\n" +
+			"\r\n" +
+			"foo\r\n" +
+			"----hello--\r\n", `invalid delta source revision: invalid snap revision: "foo"`},
+		{deltaBody +
+			"Content-Disposition: form-data; name=\"name\"\r\n" +
+			"\r\n" +
+			"local\r\n" +
+			"----hello--\r\n", `snap "local" is not installed`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "multipart/thing; boundary=--hello--")
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Message, check.Equals, t.err)
+	}
+}
+
 func (s *sideloadSuite) TestInstallPathUnaliased(c *check.C) {
 	body := "" +
 		"----hello--\r\n" +
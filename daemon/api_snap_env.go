@@ -0,0 +1,70 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+)
+
+var (
+	snapEnvCmd = &Command{
+		Path:        "/v2/snaps/{name}/env",
+		PUT:         setSnapEnv,
+		WriteAccess: rootAccess{},
+	}
+)
+
+func setSnapEnv(c *Command, r *http.Request, user *auth.UserState) Response {
+	snapName := muxVars(r)["name"]
+
+	var change client.AppEnvironmentChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		return BadRequest("cannot decode request body into environment change: %v", err)
+	}
+	if change.App == "" {
+		return BadRequest("cannot change environment without an app")
+	}
+	if len(change.Set) == 0 && len(change.Unset) == 0 {
+		return BadRequest("cannot change environment without variables to set or unset")
+	}
+	for key, value := range change.Set {
+		if err := snapstate.ValidateAppEnvironment(key, value); err != nil {
+			return BadRequest("%v", err)
+		}
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if err := snapstate.SetAppEnvironment(st, snapName, change.App, change.Set, change.Unset); err != nil {
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			return SnapNotFound(snapName, err)
+		}
+		return BadRequest("%v", err)
+	}
+	return SyncResponse(nil)
+}
@@ -0,0 +1,104 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/testutil"
+)
+
+var _ = check.Suite(&snapEnvSuite{})
+
+type snapEnvSuite struct {
+	apiBaseSuite
+}
+
+func (s *snapEnvSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectRootAccess()
+}
+
+const envYaml = `
+name: env-snap
+version: 1
+apps:
+  svc:
+    command: bin/svc
+    daemon: simple
+`
+
+func (s *snapEnvSuite) TestSetSnapEnv(c *check.C) {
+	d := s.daemon(c)
+	s.mockSnap(c, envYaml)
+
+	buf := bytes.NewBufferString(`{"app": "svc", "set": {"LOG_LEVEL": "debug", "FOO": "bar"}}`)
+	req, err := http.NewRequest("PUT", "/v2/snaps/env-snap/env", buf)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+
+	buf = bytes.NewBufferString(`{"app": "svc", "unset": ["FOO"]}`)
+	req, err = http.NewRequest("PUT", "/v2/snaps/env-snap/env", buf)
+	c.Assert(err, check.IsNil)
+	s.syncReq(c, req, nil)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(st, "env-snap", &snapst), check.IsNil)
+	c.Check(snapst.AppEnvironment, check.DeepEquals, map[string]map[string]string{
+		"svc": {"LOG_LEVEL": "debug"},
+	})
+	c.Check(filepath.Join(dirs.SnapAppEnvDir, "snap.env-snap.svc.env"), testutil.FileContains, "\nLOG_LEVEL=debug\n")
+}
+
+func (s *snapEnvSuite) TestSetSnapEnvErrors(c *check.C) {
+	s.daemon(c)
+	s.mockSnap(c, envYaml)
+
+	for _, t := range []struct {
+		snap, body string
+		status     int
+		err        string
+	}{
+		{"env-snap", `garbage`, 400, `cannot decode request body into environment change: .*`},
+		{"env-snap", `{"set": {"FOO": "bar"}}`, 400, `cannot change environment without an app`},
+		{"env-snap", `{"app": "svc"}`, 400, `cannot change environment without variables to set or unset`},
+		{"env-snap", `{"app": "svc", "set": {"SNAP_DATA": "/tmp"}}`, 400, `cannot set reserved environment variable "SNAP_DATA"`},
+		{"env-snap", `{"app": "svc", "set": {"1FOO": "bar"}}`, 400, `invalid environment variable name "1FOO"`},
+		{"env-snap", `{"app": "other", "set": {"FOO": "bar"}}`, 400, `snap "env-snap" has no app "other"`},
+		{"other-snap", `{"app": "svc", "set": {"FOO": "bar"}}`, 404, `snap "other-snap" is not installed`},
+	} {
+		req, err := http.NewRequest("PUT", "/v2/snaps/"+t.snap+"/env", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, t.status, check.Commentf(t.body))
+		c.Check(rspe.Message, check.Matches, t.err, check.Commentf(t.body))
+	}
+}
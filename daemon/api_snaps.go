@@ -135,6 +135,9 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 	if err := inst.validate(); err != nil {
 		return BadRequest("%s", err)
 	}
+	if inst.DryRun {
+		return BadRequest("dry-run is only supported for multi-snap refresh")
+	}
 
 	impl := inst.dispatch()
 	if impl == nil {
@@ -193,6 +196,7 @@ type snapInstruction struct {
 	IgnoreRunning    bool     `json:"ignore-running"`
 	Unaliased        bool     `json:"unaliased"`
 	Purge            bool     `json:"purge,omitempty"`
+	DryRun           bool     `json:"dry-run,omitempty"`
 	Snaps            []string `json:"snaps"`
 	Users            []string `json:"users"`
 
@@ -240,6 +244,9 @@ func (inst *snapInstruction) validate() error {
 			return fmt.Errorf("leave-cohort can only be specified for refresh or switch")
 		}
 	}
+	if inst.DryRun && inst.Action != "refresh" {
+		return fmt.Errorf("dry-run can only be specified for refresh")
+	}
 	if inst.Action == "install" {
 		for _, snapName := range inst.Snaps {
 			// FIXME: alternatively we could simply mutate *inst
@@ -510,6 +517,14 @@ func snapOpMany(c *Command, r *http.Request, user *auth.UserState) Response {
 		inst.userID = user.ID
 	}
 
+	if inst.DryRun {
+		plan, err := snapRefreshPlan(&inst, st)
+		if err != nil {
+			return inst.errToResponse(err)
+		}
+		return SyncResponse(plan)
+	}
+
 	op := inst.dispatchForMany()
 	if op == nil {
 		return BadRequest("unsupported multi-snap operation %q", inst.Action)
@@ -616,6 +631,14 @@ func snapUpdateMany(inst *snapInstruction, st *state.State) (*snapInstructionRes
 	}, nil
 }
 
+// snapRefreshPlan computes what refreshing the snaps of the instruction
+// would do, without queueing any change. Unlike an actual refresh it does
+// not refresh snap-declarations first, as it must not modify the state.
+func snapRefreshPlan(inst *snapInstruction, st *state.State) (*snapstate.RefreshPlan, error) {
+	// TODO: use a per-request context
+	return snapstatePlanUpdateMany(context.TODO(), st, inst.Snaps, inst.userID, nil)
+}
+
 func snapRemoveMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
 	removed, tasksets, err := snapstateRemoveMany(st, inst.Snaps)
 	if err != nil {
@@ -517,6 +517,88 @@ func (s *snapsSuite) TestPostSnapsOpInvalidCharset(c *check.C) {
 	c.Check(rspe.Message, testutil.Contains, "unknown charset in content type")
 }
 
+func (s *snapsSuite) TestPostSnapsOpDryRun(c *check.C) {
+	defer daemon.MockAssertstateRefreshSnapDeclarations(func(*state.State, int) error {
+		c.Fatalf("unexpected refresh of snap-declarations")
+		return nil
+	})()
+	plan := &snapstate.RefreshPlan{
+		Snaps: []string{"foo"},
+		Downloads: []snapstate.PlannedDownload{
+			{Snap: "foo", Revision: snap.R(2), Size: 10},
+		},
+		TotalDownloadSize: 10,
+		Tasks: []snapstate.PlannedTask{
+			{ID: "1", Kind: "prerequisites", Summary: "...", Snap: "foo"},
+		},
+	}
+	defer daemon.MockSnapstatePlanUpdateMany(func(_ context.Context, s *state.State, names []string, userID int, flags *snapstate.Flags) (*snapstate.RefreshPlan, error) {
+		c.Check(names, check.DeepEquals, []string{"foo"})
+		return plan, nil
+	})()
+	defer daemon.MockSnapstateUpdateMany(func(_ context.Context, s *state.State, names []string, userID int, flags *snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		c.Fatalf("unexpected call to UpdateMany")
+		return nil, nil, nil
+	})()
+
+	d := s.daemonWithOverlordMockAndStore(c)
+
+	buf := strings.NewReader(`{"action": "refresh", "snaps": ["foo"], "dry-run": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, plan)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
+func (s *snapsSuite) TestPostSnapsOpDryRunError(c *check.C) {
+	defer daemon.MockSnapstatePlanUpdateMany(func(_ context.Context, s *state.State, names []string, userID int, flags *snapstate.Flags) (*snapstate.RefreshPlan, error) {
+		return nil, &snapstate.InsufficientSpaceError{Path: "/var/lib/snapd", Snaps: names, ChangeKind: "refresh"}
+	})()
+
+	s.daemonWithOverlordMockAndStore(c)
+
+	buf := strings.NewReader(`{"action": "refresh", "snaps": ["foo"], "dry-run": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 507)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindInsufficientDiskSpace)
+}
+
+func (s *snapsSuite) TestPostSnapsOpDryRunOnlyRefresh(c *check.C) {
+	s.daemonWithOverlordMockAndStore(c)
+
+	buf := strings.NewReader(`{"action": "remove", "snaps": ["foo"], "dry-run": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `dry-run can only be specified for refresh`)
+}
+
+func (s *snapsSuite) TestPostSnapDryRunUnsupported(c *check.C) {
+	s.daemonWithOverlordMockAndStore(c)
+
+	buf := strings.NewReader(`{"action": "refresh", "dry-run": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `dry-run is only supported for multi-snap refresh`)
+}
+
 func (s *snapsSuite) TestRefreshAll(c *check.C) {
 	refreshSnapDecls := false
 	defer daemon.MockAssertstateRefreshSnapDeclarations(func(s *state.State, userID int) error {
@@ -1007,6 +1089,9 @@ func (s *snapsSuite) TestMapLocalFields(c *check.C) {
 			DevMode:          true,
 			JailMode:         true,
 		},
+		AppEnvironment: map[string]map[string]string{
+			"foo": {"LOG_LEVEL": "debug"},
+		},
 	},
 	)
 
@@ -1041,7 +1126,7 @@ func (s *snapsSuite) TestMapLocalFields(c *check.C) {
 		Media:            media,
 		Apps: []client.AppInfo{
 			{Snap: "some-snap_instance", Name: "bar"},
-			{Snap: "some-snap_instance", Name: "foo"},
+			{Snap: "some-snap_instance", Name: "foo", Environment: map[string]string{"LOG_LEVEL": "debug"}},
 		},
 	}
 	c.Check(daemon.MapLocal(about, nil), check.DeepEquals, expected)
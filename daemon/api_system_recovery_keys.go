@@ -20,9 +20,11 @@
 package daemon
 
 import (
+	"encoding/json"
 	"net/http"
 	"path/filepath"
 
+	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -30,11 +32,19 @@ import (
 )
 
 var systemRecoveryKeysCmd = &Command{
-	Path:       "/v2/system-recovery-keys",
-	GET:        getSystemRecoveryKeys,
-	ReadAccess: rootAccess{},
+	Path:        "/v2/system-recovery-keys",
+	GET:         getSystemRecoveryKeys,
+	POST:        postSystemRecoveryKeys,
+	ReadAccess:  rootAccess{},
+	WriteAccess: rootAccess{},
 }
 
+var (
+	bootRotateRecoveryKeys       = boot.RotateRecoveryKeys
+	bootAddRecoveryPassphrase    = boot.AddRecoveryPassphrase
+	bootRemoveRecoveryPassphrase = boot.RemoveRecoveryPassphrase
+)
+
 func getSystemRecoveryKeys(c *Command, r *http.Request, user *auth.UserState) Response {
 	var rsp client.SystemRecoveryKeysResponse
 
@@ -52,3 +62,48 @@ func getSystemRecoveryKeys(c *Command, r *http.Request, user *auth.UserState) Re
 
 	return SyncResponse(&rsp)
 }
+
+type postSystemRecoveryKeysData struct {
+	Action     string `json:"action"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+func postSystemRecoveryKeys(c *Command, r *http.Request, user *auth.UserState) Response {
+	var data postSystemRecoveryKeysData
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&data); err != nil {
+		return BadRequest("cannot decode request body into recovery keys action: %v", err)
+	}
+	if decoder.More() {
+		return BadRequest("extra content found in request body")
+	}
+
+	if data.Action == "rotate" && data.Passphrase != "" {
+		return BadRequest("recovery keys action %q does not take a passphrase", data.Action)
+	}
+	if (data.Action == "add-passphrase" || data.Action == "remove-passphrase") && data.Passphrase == "" {
+		return BadRequest("recovery keys action %q requires a passphrase", data.Action)
+	}
+
+	// adding keys runs an expensive key derivation, the state is not
+	// locked while the key slots are modified, boot serializes the
+	// changes to them instead
+	switch data.Action {
+	case "rotate":
+		if err := bootRotateRecoveryKeys(); err != nil {
+			return InternalError("cannot rotate recovery keys: %v", err)
+		}
+	case "add-passphrase":
+		if err := bootAddRecoveryPassphrase(data.Passphrase); err != nil {
+			return InternalError("cannot add recovery passphrase: %v", err)
+		}
+	case "remove-passphrase":
+		if err := bootRemoveRecoveryPassphrase(data.Passphrase); err != nil {
+			return InternalError("cannot remove recovery passphrase: %v", err)
+		}
+	default:
+		return BadRequest("unsupported recovery keys action %q", data.Action)
+	}
+	return SyncResponse(nil)
+}
@@ -21,15 +21,18 @@ package daemon_test
 
 import (
 	"encoding/hex"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/secboot"
 )
@@ -96,3 +99,105 @@ func (s *recoveryKeysSuite) TestSystemGetRecoveryAsUserErrors(c *C) {
 	s.serveHTTP(c, rec, req)
 	c.Assert(rec.Code, Equals, 403)
 }
+
+func (s *recoveryKeysSuite) mockKeyOps(c *C, d *daemon.Daemon) *[]string {
+	st := d.Overlord().State()
+	// the key slots are modified without holding the state lock
+	checkUnlocked := func() {
+		st.Lock()
+		st.Unlock()
+	}
+	var calls []string
+	s.AddCleanup(daemon.MockBootRotateRecoveryKeys(func() error {
+		checkUnlocked()
+		calls = append(calls, "rotate")
+		return nil
+	}))
+	s.AddCleanup(daemon.MockBootAddRecoveryPassphrase(func(passphrase string) error {
+		checkUnlocked()
+		calls = append(calls, "add:"+passphrase)
+		return nil
+	}))
+	s.AddCleanup(daemon.MockBootRemoveRecoveryPassphrase(func(passphrase string) error {
+		checkUnlocked()
+		calls = append(calls, "remove:"+passphrase)
+		return nil
+	}))
+	return &calls
+}
+
+func (s *recoveryKeysSuite) TestPostSystemRecoveryKeysHappy(c *C) {
+	d := s.daemon(c)
+	calls := s.mockKeyOps(c, d)
+
+	for _, body := range []string{
+		`{"action": "rotate"}`,
+		`{"action": "add-passphrase", "passphrase": "foo"}`,
+		`{"action": "remove-passphrase", "passphrase": "bar"}`,
+	} {
+		req, err := http.NewRequest("POST", "/v2/system-recovery-keys", strings.NewReader(body))
+		c.Assert(err, IsNil)
+		rsp := s.syncReq(c, req, nil)
+		c.Check(rsp.Status, Equals, 200)
+	}
+	c.Check(*calls, DeepEquals, []string{"rotate", "add:foo", "remove:bar"})
+}
+
+func (s *recoveryKeysSuite) TestPostSystemRecoveryKeysBadRequest(c *C) {
+	d := s.daemon(c)
+	calls := s.mockKeyOps(c, d)
+
+	for _, tc := range []struct {
+		body, err string
+	}{
+		{`{"action": "rotate", "passphrase": "foo"}`, `recovery keys action "rotate" does not take a passphrase`},
+		{`{"action": "add-passphrase"}`, `recovery keys action "add-passphrase" requires a passphrase`},
+		{`{"action": "remove-passphrase"}`, `recovery keys action "remove-passphrase" requires a passphrase`},
+		{`{"action": "foo"}`, `unsupported recovery keys action "foo"`},
+		{`{"action": "rotate"}{}`, `extra content found in request body`},
+		{`{`, `cannot decode request body into recovery keys action: unexpected EOF`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/system-recovery-keys", strings.NewReader(tc.body))
+		c.Assert(err, IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, Equals, 400)
+		c.Check(rspe.Message, Equals, tc.err)
+	}
+	c.Check(*calls, HasLen, 0)
+}
+
+func (s *recoveryKeysSuite) TestPostSystemRecoveryKeysError(c *C) {
+	s.daemon(c)
+	s.AddCleanup(daemon.MockBootRotateRecoveryKeys(func() error {
+		return fmt.Errorf("boom")
+	}))
+	s.AddCleanup(daemon.MockBootRemoveRecoveryPassphrase(func(passphrase string) error {
+		return fmt.Errorf("cannot remove a key managed by the system")
+	}))
+
+	for _, tc := range []struct {
+		body, err string
+	}{
+		{`{"action": "rotate"}`, `cannot rotate recovery keys: boom`},
+		{`{"action": "remove-passphrase", "passphrase": "foo"}`, `cannot remove recovery passphrase: cannot remove a key managed by the system`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/system-recovery-keys", strings.NewReader(tc.body))
+		c.Assert(err, IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, Equals, 500)
+		c.Check(rspe.Message, Equals, tc.err)
+	}
+}
+
+func (s *recoveryKeysSuite) TestPostSystemRecoveryKeysAsUserErrors(c *C) {
+	d := s.daemon(c)
+	calls := s.mockKeyOps(c, d)
+
+	req, err := http.NewRequest("POST", "/v2/system-recovery-keys", strings.NewReader(`{"action": "rotate"}`))
+	c.Assert(err, IsNil)
+	s.asUserAuth(c, req)
+	rec := httptest.NewRecorder()
+	s.serveHTTP(c, rec, req)
+	c.Assert(rec.Code, Equals, 403)
+	c.Check(*calls, HasLen, 0)
+}
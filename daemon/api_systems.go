@@ -120,6 +120,8 @@ func postSystemsAction(c *Command, r *http.Request, user *auth.UserState) Respon
 		return postSystemActionDo(c, systemLabel, &req)
 	case "reboot":
 		return postSystemActionReboot(c, systemLabel, &req)
+	case "remove":
+		return postSystemActionRemove(c, systemLabel)
 	default:
 		return BadRequest("unsupported action %q", req.Action)
 	}
@@ -168,3 +170,24 @@ func postSystemActionDo(c *Command, systemLabel string, req *systemActionRequest
 	}
 	return SyncResponse(nil)
 }
+
+// wrapped for unit tests
+var devicestateRemoveRecoverySystem = devicestate.RemoveRecoverySystem
+
+func postSystemActionRemove(c *Command, systemLabel string) Response {
+	if systemLabel == "" {
+		return BadRequest("system action requires the system label to be provided")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg, err := devicestateRemoveRecoverySystem(st, systemLabel)
+	if err != nil {
+		return errToResponse(err, nil, BadRequest, "cannot remove recovery system: %v")
+	}
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, chg.ID())
+}
@@ -42,6 +42,7 @@ import (
 	"github.com/snapcore/snapd/overlord/assertstate/assertstatetest"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/seed"
 	"github.com/snapcore/snapd/seed/seedtest"
@@ -173,6 +174,7 @@ func (s *systemsSuite) TestSystemsGetSome(c *check.C) {
 				},
 				Actions: []client.SystemAction{
 					{Title: "Install", Mode: "install"},
+					{Title: "Factory reset", Mode: "factory-reset"},
 				},
 			}, {
 				Current: true,
@@ -191,6 +193,7 @@ func (s *systemsSuite) TestSystemsGetSome(c *check.C) {
 				Actions: []client.SystemAction{
 					{Title: "Reinstall", Mode: "install"},
 					{Title: "Recover", Mode: "recover"},
+					{Title: "Factory reset", Mode: "factory-reset"},
 					{Title: "Run normally", Mode: "run"},
 				},
 			},
@@ -356,6 +359,7 @@ func (s *systemsSuite) TestSystemActionRequestWithSeeded(c *check.C) {
 	tt := []struct {
 		currentMode    string
 		actionMode     string
+		label          string
 		expUnsupported bool
 		expRestart     bool
 		comment        string
@@ -374,6 +378,22 @@ func (s *systemsSuite) TestSystemActionRequestWithSeeded(c *check.C) {
 			expRestart:  true,
 			comment:     "run mode to recover mode",
 		},
+		{
+			// from run mode -> factory-reset mode works to wipe the system
+			currentMode: "run",
+			actionMode:  "factory-reset",
+			expRestart:  true,
+			comment:     "run mode to factory-reset mode",
+		},
+		{
+			// from run mode -> factory-reset mode of another seeded
+			// system works too
+			currentMode: "run",
+			actionMode:  "factory-reset",
+			label:       "20200318",
+			expRestart:  true,
+			comment:     "run mode to factory-reset mode of other system",
+		},
 		{
 			// from run mode -> run mode is no-op
 			currentMode: "run",
@@ -394,6 +414,13 @@ func (s *systemsSuite) TestSystemActionRequestWithSeeded(c *check.C) {
 			expRestart:  true,
 			comment:     "recover mode to install mode",
 		},
+		{
+			// from recover mode -> factory-reset mode works to wipe the system if all is lost
+			currentMode: "recover",
+			actionMode:  "factory-reset",
+			expRestart:  true,
+			comment:     "recover mode to factory-reset mode",
+		},
 		{
 			// from recover mode -> recover mode is no-op
 			currentMode:    "recover",
@@ -422,10 +449,21 @@ func (s *systemsSuite) TestSystemActionRequestWithSeeded(c *check.C) {
 			expUnsupported: true,
 			comment:        "install mode to recover mode not supported",
 		},
+		{
+			// from install mode -> factory-reset mode is no-no
+			currentMode:    "install",
+			actionMode:     "factory-reset",
+			expUnsupported: true,
+			comment:        "install mode to factory-reset mode not supported",
+		},
 	}
 
 	for _, tc := range tt {
 		c.Logf("tc: %v", tc.comment)
+		label := tc.label
+		if label == "" {
+			label = "20191119"
+		}
 		// daemon setup - need to do this per-test because we need to re-read
 		// the modeenv during devicemgr startup
 		m := boot.Modeenv{
@@ -460,7 +498,7 @@ func (s *systemsSuite) TestSystemActionRequestWithSeeded(c *check.C) {
 		b, err := json.Marshal(body)
 		c.Assert(err, check.IsNil, check.Commentf(tc.comment))
 		buf := bytes.NewBuffer(b)
-		req, err := http.NewRequest("POST", "/v2/systems/20191119", buf)
+		req, err := http.NewRequest("POST", path.Join("/v2/systems", label), buf)
 		c.Assert(err, check.IsNil, check.Commentf(tc.comment))
 		// as root
 		s.asRootAuth(req)
@@ -480,7 +518,7 @@ func (s *systemsSuite) TestSystemActionRequestWithSeeded(c *check.C) {
 		if tc.expUnsupported {
 			expResp = map[string]interface{}{
 				"result": map[string]interface{}{
-					"message": fmt.Sprintf("requested action is not supported by system %q", "20191119"),
+					"message": fmt.Sprintf("requested action is not supported by system %q", label),
 				},
 				"status":      "Bad Request",
 				"status-code": 400.0,
@@ -689,3 +727,67 @@ func (s *systemsSuite) TestSystemRebootUnhappy(c *check.C) {
 		c.Check(result["message"], check.Equals, tc.expectedErr)
 	}
 }
+
+func (s *systemsSuite) TestSystemActionRemoveHappy(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+
+	soon := 0
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {
+		soon++
+	})
+	defer restore()
+
+	var removedLabel string
+	defer daemon.MockDevicestateRemoveRecoverySystem(func(st *state.State, label string) (*state.Change, error) {
+		removedLabel = label
+		return st.NewChange("remove-recovery-system", "..."), nil
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/systems/20200101", strings.NewReader(`{"action":"remove"}`))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 202)
+	c.Check(removedLabel, check.Equals, "20200101")
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "remove-recovery-system")
+	c.Check(soon, check.Equals, 1)
+}
+
+func (s *systemsSuite) TestSystemActionRemoveUnhappy(c *check.C) {
+	s.daemon(c)
+
+	for _, tc := range []struct {
+		label            string
+		removeErr        error
+		expectedHttpCode int
+		expectedErr      string
+	}{
+		{"", nil, 400, "system action requires the system label to be provided"},
+		{"20200101", fmt.Errorf(`cannot remove the current recovery system "20200101"`), 400, `cannot remove recovery system: cannot remove the current recovery system "20200101"`},
+		{"20200101", &snapstate.ChangeConflictError{
+			Message:    "creating recovery system in progress, no other changes allowed until this is done",
+			ChangeKind: "create-recovery-system",
+		}, 409, "creating recovery system in progress, no other changes allowed until this is done"},
+	} {
+		restore := daemon.MockDevicestateRemoveRecoverySystem(func(st *state.State, label string) (*state.Change, error) {
+			c.Check(label, check.Equals, tc.label)
+			return nil, tc.removeErr
+		})
+		defer restore()
+
+		url := "/v2/systems"
+		if tc.label != "" {
+			url += "/" + tc.label
+		}
+		req, err := http.NewRequest("POST", url, strings.NewReader(`{"action":"remove"}`))
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, tc.expectedHttpCode)
+		c.Check(rspe.Message, check.Equals, tc.expectedErr)
+	}
+}
@@ -20,6 +20,7 @@
 package daemon
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -29,6 +30,7 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -240,6 +242,7 @@ type validationSetApplyRequest struct {
 	Action   string `json:"action"`
 	Mode     string `json:"mode"`
 	Sequence int    `json:"sequence,omitempty"`
+	Refresh  bool   `json:"refresh,omitempty"`
 }
 
 func applyValidationSet(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -274,25 +277,43 @@ func applyValidationSet(c *Command, r *http.Request, user *auth.UserState) Respo
 	case "forget":
 		return forgetValidationSet(st, accountID, name, req.Sequence)
 	case "apply":
-		return updateValidationSet(st, accountID, name, req.Mode, req.Sequence, user)
+		return updateValidationSet(st, accountID, name, req.Mode, req.Sequence, req.Refresh, user)
 	default:
 		return BadRequest("unsupported action %q", req.Action)
 	}
 }
 
-var validationSetAssertionForMonitor = assertstate.ValidationSetAssertionForMonitor
+var (
+	validationSetAssertionForMonitor = assertstate.ValidationSetAssertionForMonitor
+	validationSetAssertionForEnforce = assertstate.ValidationSetAssertionForEnforce
+	assertstateEnforceValidationSet  = assertstate.EnforceValidationSet
+)
 
-// updateValidationSet handles snap validate --monitor and --enforce accountId/name[=sequence].
-func updateValidationSet(st *state.State, accountID, name string, reqMode string, sequence int, user *auth.UserState) Response {
+// updateValidationSet handles snap validate --monitor and --enforce [--refresh] accountId/name[=sequence].
+func updateValidationSet(st *state.State, accountID, name string, reqMode string, sequence int, refresh bool, user *auth.UserState) Response {
 	var mode assertstate.ValidationSetMode
-	// TODO: only monitor mode for now, add enforce.
 	switch reqMode {
 	case "monitor":
 		mode = assertstate.Monitor
+	case "enforce":
+		mode = assertstate.Enforce
 	default:
 		return BadRequest("invalid mode %q", reqMode)
 	}
 
+	if refresh && mode != assertstate.Enforce {
+		return BadRequest("refresh is only supported in enforce mode")
+	}
+
+	userID := 0
+	if user != nil {
+		userID = user.ID
+	}
+
+	if mode == assertstate.Enforce {
+		return enforceValidationSet(st, accountID, name, sequence, refresh, userID)
+	}
+
 	tr := assertstate.ValidationSetTracking{
 		AccountID: accountID,
 		Name:      name,
@@ -301,10 +322,6 @@ func updateValidationSet(st *state.State, accountID, name string, reqMode string
 		PinnedAt: sequence,
 	}
 
-	userID := 0
-	if user != nil {
-		userID = user.ID
-	}
 	pinned := sequence > 0
 	opts := assertstate.ResolveOptions{AllowLocalFallback: true}
 	as, local, err := validationSetAssertionForMonitor(st, accountID, name, sequence, pinned, userID, &opts)
@@ -318,6 +335,41 @@ func updateValidationSet(st *state.State, accountID, name string, reqMode string
 	return SyncResponse(nil)
 }
 
+// enforceValidationSet enforces the validation set. Unless refresh is set, it
+// fails if the installed snaps do not conform to the validation set, otherwise
+// it creates a change installing, refreshing and removing snaps as needed
+// before enforcing it.
+func enforceValidationSet(st *state.State, accountID, name string, sequence int, refresh bool, userID int) Response {
+	key := assertstate.ValidationSetKey(accountID, name)
+	if refresh {
+		// TODO: use a per-request context
+		tss, affected, err := assertstateEnforceValidationSet(context.TODO(), st, accountID, name, sequence, userID)
+		if err != nil {
+			return BadRequest("cannot enforce validation set %v: %v", key, err)
+		}
+		chg := newChange(st, "enforce-validation-set", fmt.Sprintf(i18n.G("Enforce validation set %s"), key), tss, affected)
+		ensureStateSoon(st)
+		return AsyncResponse(nil, chg.ID())
+	}
+
+	as, local, err := validationSetAssertionForEnforce(st, accountID, name, sequence, userID)
+	if err != nil {
+		return BadRequest("cannot enforce validation set %v: %v", key, err)
+	}
+
+	tr := assertstate.ValidationSetTracking{
+		AccountID: accountID,
+		Name:      name,
+		Mode:      assertstate.Enforce,
+		// note, Sequence may be 0, meaning not pinned.
+		PinnedAt:  sequence,
+		Current:   as.Sequence(),
+		LocalOnly: local,
+	}
+	assertstate.UpdateValidationSet(st, &tr)
+	return SyncResponse(nil)
+}
+
 // forgetValidationSet forgets the validation set.
 // The state needs to be locked by the caller.
 func forgetValidationSet(st *state.State, accountID, name string, sequence int) Response {
@@ -20,6 +20,7 @@
 package daemon_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -799,6 +800,121 @@ func (s *apiValidationSetsSuite) TestApplyValidationSetMonitorModeError(c *check
 	c.Check(rspe.Message, check.Equals, fmt.Sprintf(`cannot get validation set assertion for %s/bar: boom`, s.dev1acct.AccountID()))
 }
 
+func (s *apiValidationSetsSuite) TestApplyValidationSetMonitorModeRefreshError(c *check.C) {
+	body := `{"action":"apply","mode":"monitor","refresh":true}`
+	req, err := http.NewRequest("POST", fmt.Sprintf("/v2/validation-sets/%s/bar", s.dev1acct.AccountID()), strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Assert(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `refresh is only supported in enforce mode`)
+}
+
+func (s *apiValidationSetsSuite) TestApplyValidationSetEnforceMode(c *check.C) {
+	st := s.d.Overlord().State()
+
+	var called int
+	restore := daemon.MockValidationSetAssertionForEnforce(func(st *state.State, accountID, name string, sequence int, userID int) (*asserts.ValidationSet, bool, error) {
+		called++
+		c.Assert(accountID, check.Equals, s.dev1acct.AccountID())
+		c.Assert(name, check.Equals, "bar")
+		c.Assert(sequence, check.Equals, 0)
+		c.Assert(userID, check.Equals, 0)
+		vs := s.mockAssert(c, "bar", "5")
+		return vs.(*asserts.ValidationSet), false, nil
+	})
+	defer restore()
+
+	body := `{"action":"apply","mode":"enforce"}`
+	req, err := http.NewRequest("POST", fmt.Sprintf("/v2/validation-sets/%s/bar", s.dev1acct.AccountID()), strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(called, check.Equals, 1)
+
+	var tr assertstate.ValidationSetTracking
+
+	st.Lock()
+	err = assertstate.GetValidationSet(st, s.dev1acct.AccountID(), "bar", &tr)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(tr, check.DeepEquals, assertstate.ValidationSetTracking{
+		Mode:      assertstate.Enforce,
+		AccountID: s.dev1acct.AccountID(),
+		Name:      "bar",
+		Current:   5,
+	})
+}
+
+func (s *apiValidationSetsSuite) TestApplyValidationSetEnforceModeError(c *check.C) {
+	restore := daemon.MockValidationSetAssertionForEnforce(func(st *state.State, accountID, name string, sequence int, userID int) (*asserts.ValidationSet, bool, error) {
+		return nil, false, fmt.Errorf("boom")
+	})
+	defer restore()
+
+	body := `{"action":"apply","mode":"enforce","sequence":3}`
+	req, err := http.NewRequest("POST", fmt.Sprintf("/v2/validation-sets/%s/bar", s.dev1acct.AccountID()), strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Assert(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, fmt.Sprintf(`cannot enforce validation set %s/bar: boom`, s.dev1acct.AccountID()))
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	var tr assertstate.ValidationSetTracking
+	c.Check(assertstate.GetValidationSet(st, s.dev1acct.AccountID(), "bar", &tr), check.Equals, state.ErrNoState)
+}
+
+func (s *apiValidationSetsSuite) TestApplyValidationSetEnforceModeRefresh(c *check.C) {
+	restore := daemon.MockAssertstateEnforceValidationSet(func(ctx context.Context, st *state.State, accountID, name string, sequence, userID int) ([]*state.TaskSet, []string, error) {
+		c.Assert(accountID, check.Equals, s.dev1acct.AccountID())
+		c.Assert(name, check.Equals, "bar")
+		c.Assert(sequence, check.Equals, 3)
+		t1 := st.NewTask("fake-install-snap", "...")
+		t2 := st.NewTask("enforce-validation-set", "...")
+		t2.WaitFor(t1)
+		return []*state.TaskSet{state.NewTaskSet(t1), state.NewTaskSet(t2)}, []string{"foo"}, nil
+	})
+	defer restore()
+
+	body := `{"action":"apply","mode":"enforce","sequence":3,"refresh":true}`
+	req, err := http.NewRequest("POST", fmt.Sprintf("/v2/validation-sets/%s/bar", s.dev1acct.AccountID()), strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "enforce-validation-set")
+	c.Check(chg.Summary(), check.Equals, fmt.Sprintf("Enforce validation set %s/bar", s.dev1acct.AccountID()))
+	c.Check(chg.Tasks(), check.HasLen, 2)
+	var snapNames []string
+	c.Assert(chg.Get("snap-names", &snapNames), check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"foo"})
+}
+
+func (s *apiValidationSetsSuite) TestApplyValidationSetEnforceModeRefreshError(c *check.C) {
+	restore := daemon.MockAssertstateEnforceValidationSet(func(ctx context.Context, st *state.State, accountID, name string, sequence, userID int) ([]*state.TaskSet, []string, error) {
+		return nil, nil, fmt.Errorf("boom")
+	})
+	defer restore()
+
+	body := `{"action":"apply","mode":"enforce","refresh":true}`
+	req, err := http.NewRequest("POST", fmt.Sprintf("/v2/validation-sets/%s/bar", s.dev1acct.AccountID()), strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Assert(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, fmt.Sprintf(`cannot enforce validation set %s/bar: boom`, s.dev1acct.AccountID()))
+}
+
 func (s *apiValidationSetsSuite) TestForgetValidationSet(c *check.C) {
 	st := s.d.Overlord().State()
 
@@ -887,13 +1003,6 @@ func (s *apiValidationSetsSuite) TestApplyValidationSetsErrors(c *check.C) {
 			message:       `invalid mode "bad"`,
 			status:        400,
 		},
-		// XXX: enable when enforcing is implemented.
-		{
-			validationSet: "foo/bar",
-			mode:          "enforce",
-			message:       `invalid mode "enforce"`,
-			status:        400,
-		},
 		{
 			validationSet: "foo/bar",
 			sequence:      "-1",
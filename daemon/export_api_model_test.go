@@ -21,7 +21,9 @@ package daemon
 
 import (
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 func MockDevicestateRemodel(mock func(*state.State, *asserts.Model) (*state.Change, error)) (restore func()) {
@@ -32,6 +34,22 @@ func MockDevicestateRemodel(mock func(*state.State, *asserts.Model) (*state.Chan
 	}
 }
 
+func MockDevicestateRemodelWithLocalSnaps(mock func(*state.State, *asserts.Model, []*snap.SideInfo, []string) (*state.Change, error)) (restore func()) {
+	old := devicestateRemodelWithLocalSnaps
+	devicestateRemodelWithLocalSnaps = mock
+	return func() {
+		devicestateRemodelWithLocalSnaps = old
+	}
+}
+
+func MockDevicestatePlanRemodel(mock func(*state.State, *asserts.Model) (*devicestate.RemodelPlan, error)) (restore func()) {
+	oldDevicestatePlanRemodel := devicestatePlanRemodel
+	devicestatePlanRemodel = mock
+	return func() {
+		devicestatePlanRemodel = oldDevicestatePlanRemodel
+	}
+}
+
 type (
 	PostModelData   = postModelData
 	ModelAssertJSON = modelAssertJSON
@@ -0,0 +1,44 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+func MockBootRotateRecoveryKeys(f func() error) (restore func()) {
+	old := bootRotateRecoveryKeys
+	bootRotateRecoveryKeys = f
+	return func() {
+		bootRotateRecoveryKeys = old
+	}
+}
+
+func MockBootAddRecoveryPassphrase(f func(passphrase string) error) (restore func()) {
+	old := bootAddRecoveryPassphrase
+	bootAddRecoveryPassphrase = f
+	return func() {
+		bootAddRecoveryPassphrase = old
+	}
+}
+
+func MockBootRemoveRecoveryPassphrase(f func(passphrase string) error) (restore func()) {
+	old := bootRemoveRecoveryPassphrase
+	bootRemoveRecoveryPassphrase = f
+	return func() {
+		bootRemoveRecoveryPassphrase = old
+	}
+}
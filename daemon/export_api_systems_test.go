@@ -21,6 +21,7 @@ package daemon
 
 import (
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/state"
 )
 
 func MockDeviceManagerReboot(f func(*devicestate.DeviceManager, string, string) error) (restore func()) {
@@ -31,6 +32,14 @@ func MockDeviceManagerReboot(f func(*devicestate.DeviceManager, string, string)
 	}
 }
 
+func MockDevicestateRemoveRecoverySystem(f func(*state.State, string) (*state.Change, error)) (restore func()) {
+	old := devicestateRemoveRecoverySystem
+	devicestateRemoveRecoverySystem = f
+	return func() {
+		devicestateRemoveRecoverySystem = old
+	}
+}
+
 type (
 	SystemsResponse = systemsResponse
 )
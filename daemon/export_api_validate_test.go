@@ -20,6 +20,8 @@
 package daemon
 
 import (
+	"context"
+
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/overlord/assertstate"
@@ -45,3 +47,19 @@ func MockValidationSetAssertionForMonitor(f func(st *state.State, accountID, nam
 		validationSetAssertionForMonitor = old
 	}
 }
+
+func MockValidationSetAssertionForEnforce(f func(st *state.State, accountID, name string, sequence int, userID int) (*asserts.ValidationSet, bool, error)) func() {
+	old := validationSetAssertionForEnforce
+	validationSetAssertionForEnforce = f
+	return func() {
+		validationSetAssertionForEnforce = old
+	}
+}
+
+func MockAssertstateEnforceValidationSet(f func(ctx context.Context, st *state.State, accountID, name string, sequence, userID int) ([]*state.TaskSet, []string, error)) func() {
+	old := assertstateEnforceValidationSet
+	assertstateEnforceValidationSet = f
+	return func() {
+		assertstateEnforceValidationSet = old
+	}
+}
@@ -129,6 +129,14 @@ func MockSnapstateInstallPath(mock func(*state.State, *snap.SideInfo, string, st
 	}
 }
 
+func MockSnapstateApplyDelta(mock func(*state.State, string, snap.Revision, string, string) error) (restore func()) {
+	oldSnapstateApplyDelta := snapstateApplyDelta
+	snapstateApplyDelta = mock
+	return func() {
+		snapstateApplyDelta = oldSnapstateApplyDelta
+	}
+}
+
 func MockSnapstateUpdate(mock func(*state.State, string, *snapstate.RevisionOptions, int, snapstate.Flags) (*state.TaskSet, error)) (restore func()) {
 	oldSnapstateUpdate := snapstateUpdate
 	snapstateUpdate = mock
@@ -185,6 +193,14 @@ func MockSnapstateUpdateMany(mock func(context.Context, *state.State, []string,
 	}
 }
 
+func MockSnapstatePlanUpdateMany(mock func(context.Context, *state.State, []string, int, *snapstate.Flags) (*snapstate.RefreshPlan, error)) (restore func()) {
+	oldSnapstatePlanUpdateMany := snapstatePlanUpdateMany
+	snapstatePlanUpdateMany = mock
+	return func() {
+		snapstatePlanUpdateMany = oldSnapstatePlanUpdateMany
+	}
+}
+
 func MockSnapstateRemoveMany(mock func(*state.State, []string) ([]string, []*state.TaskSet, error)) (restore func()) {
 	oldSnapstateRemoveMany := snapstateRemoveMany
 	snapstateRemoveMany = mock
@@ -208,6 +208,12 @@ func mapLocal(about aboutSnap, sd clientutil.StatusDecorator) *client.Snap {
 	}
 	result.Health = about.health
 
+	for i := range result.Apps {
+		if env := snapst.AppEnvironment[result.Apps[i].Name]; len(env) != 0 {
+			result.Apps[i].Environment = env
+		}
+	}
+
 	return result
 }
 
@@ -67,6 +67,7 @@ var (
 
 	SnapAssertsDBDir      string
 	SnapCookieDir         string
+	SnapAppEnvDir         string
 	SnapTrustedAccountKey string
 	SnapAssertsSpoolDir   string
 	SnapSeqDir            string
@@ -88,12 +89,14 @@ var (
 	SnapCommandsDB      string
 	SnapAuxStoreInfoDir string
 
-	SnapBinariesDir     string
-	SnapServicesDir     string
-	SnapUserServicesDir string
-	SnapSystemdConfDir  string
-	SnapDesktopFilesDir string
-	SnapDesktopIconsDir string
+	SnapBinariesDir        string
+	SnapServicesDir        string
+	SnapRuntimeServicesDir string
+	SnapUserServicesDir    string
+	SnapSystemdConfDir     string
+	SnapSystemdDir         string
+	SnapDesktopFilesDir    string
+	SnapDesktopIconsDir    string
 
 	SnapDBusSessionPolicyDir   string
 	SnapDBusSystemPolicyDir    string
@@ -345,6 +348,7 @@ func SetRootDir(rootdir string) {
 
 	SnapAssertsDBDir = filepath.Join(rootdir, snappyDir, "assertions")
 	SnapCookieDir = filepath.Join(rootdir, snappyDir, "cookie")
+	SnapAppEnvDir = filepath.Join(rootdir, snappyDir, "environment")
 	SnapAssertsSpoolDir = filepath.Join(rootdir, "run/snapd/auto-import")
 	SnapSeqDir = filepath.Join(rootdir, snappyDir, "sequence")
 
@@ -376,8 +380,10 @@ func SetRootDir(rootdir string) {
 
 	SnapBinariesDir = filepath.Join(SnapMountDir, "bin")
 	SnapServicesDir = filepath.Join(rootdir, "/etc/systemd/system")
+	SnapRuntimeServicesDir = filepath.Join(rootdir, "/run/systemd/system")
 	SnapUserServicesDir = filepath.Join(rootdir, "/etc/systemd/user")
 	SnapSystemdConfDir = SnapSystemdConfDirUnder(rootdir)
+	SnapSystemdDir = filepath.Join(rootdir, "/etc/systemd")
 
 	SnapDBusSystemPolicyDir = filepath.Join(rootdir, "/etc/dbus-1/system.d")
 	SnapDBusSessionPolicyDir = filepath.Join(rootdir, "/etc/dbus-1/session.d")
@@ -65,6 +65,10 @@ const (
 	// QuotaGroups enable creating resource quota groups for snaps via the rest API and cli.
 	QuotaGroups
 
+	// JournaledState persists snapd state incrementally via a journal
+	// instead of rewriting the whole state file on each change.
+	JournaledState
+
 	// lastFeature is the final known feature, it is only used for testing.
 	lastFeature
 )
@@ -103,6 +107,8 @@ var featureNames = map[SnapdFeature]string{
 	GateAutoRefreshHook: "gate-auto-refresh-hook",
 
 	QuotaGroups: "quota-groups",
+
+	JournaledState: "journaled-state",
 }
 
 // featuresEnabledWhenUnset contains a set of features that are enabled when not explicitly configured.
@@ -122,6 +128,8 @@ var featuresExported = map[SnapdFeature]bool{
 	ClassicPreservesXdgRuntimeDir: true,
 	RobustMountNamespaceUpdates:   true,
 	HiddenSnapFolder:              true,
+
+	JournaledState: true,
 }
 
 // String returns the name of a snapd feature.
@@ -55,6 +55,7 @@ func (*featureSuite) TestName(c *C) {
 	c.Check(features.CheckDiskSpaceRemove.String(), Equals, "check-disk-space-remove")
 	c.Check(features.GateAutoRefreshHook.String(), Equals, "gate-auto-refresh-hook")
 	c.Check(features.QuotaGroups.String(), Equals, "quota-groups")
+	c.Check(features.JournaledState.String(), Equals, "journaled-state")
 	c.Check(func() { _ = features.SnapdFeature(1000).String() }, PanicMatches, "unknown feature flag code 1000")
 }
 
@@ -83,6 +84,7 @@ func (*featureSuite) TestIsExported(c *C) {
 	c.Check(features.CheckDiskSpaceRefresh.IsExported(), Equals, false)
 	c.Check(features.CheckDiskSpaceRemove.IsExported(), Equals, false)
 	c.Check(features.GateAutoRefreshHook.IsExported(), Equals, false)
+	c.Check(features.JournaledState.IsExported(), Equals, true)
 }
 
 func (*featureSuite) TestIsEnabled(c *C) {
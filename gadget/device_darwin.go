@@ -38,3 +38,7 @@ func findDeviceForStructureWithFallback(ps *LaidOutStructure) (string, quantity.
 func findMountPointForStructure(ps *LaidOutStructure) (string, error) {
 	return "", errNotImplemented
 }
+
+func ParentDiskFromMountSource(mountSource string) (string, error) {
+	return "", errNotImplemented
+}
@@ -72,3 +72,11 @@ func MockEvalSymlinks(mock func(path string) (string, error)) (restore func()) {
 func (m *MountedFilesystemWriter) WriteDirectory(volumeRoot, src, dst string, preserveInDst []string) error {
 	return m.writeDirectory(volumeRoot, src, dst, preserveInDst)
 }
+
+func MockNewLayoutUpdater(f LayoutUpdaterFunc) (restore func()) {
+	old := NewLayoutUpdater
+	NewLayoutUpdater = f
+	return func() {
+		NewLayoutUpdater = old
+	}
+}
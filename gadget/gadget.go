@@ -99,6 +99,26 @@ type Info struct {
 	Defaults map[string]map[string]interface{} `yaml:"defaults,omitempty"`
 
 	Connections []Connection `yaml:"connections"`
+
+	// KernelCmdline describes the kernel command line arguments that can
+	// be appended to the command line of the run system through system
+	// configuration.
+	KernelCmdline KernelCmdline `yaml:"kernel-cmdline,omitempty"`
+
+	// CriticalSnaps lists the snap-ids of snaps whose services are
+	// critical to the device, a failure of such services can be
+	// escalated to a reboot of the device.
+	CriticalSnaps []string `yaml:"critical-snaps,omitempty"`
+}
+
+// KernelCmdline carries the kernel command line related settings of the
+// gadget.
+type KernelCmdline struct {
+	// Allow is a list of patterns of kernel command line arguments the
+	// gadget allows to be appended. A pattern is either a plain argument,
+	// eg. "quiet", an argument with a value, eg. "console=ttyS0", or an
+	// argument with any value, eg. "isolcpus=*".
+	Allow []string `yaml:"allow,omitempty"`
 }
 
 // Volume defines the structure and content for the image to be written into a
@@ -329,6 +349,16 @@ func InfoFromGadgetYaml(gadgetYaml []byte, model Model) (*Info, error) {
 		}
 	}
 
+	if err := validateKernelCmdlineAllow(gi.KernelCmdline.Allow); err != nil {
+		return nil, err
+	}
+
+	for _, snapID := range gi.CriticalSnaps {
+		if naming.ValidateSnapID(snapID) != nil {
+			return nil, fmt.Errorf("critical-snaps entry is not a snap-id: %s", snapID)
+		}
+	}
+
 	if len(gi.Volumes) == 0 && classicOrUndetermined(model) {
 		// volumes can be left out on classic
 		// can still specify defaults though
@@ -346,10 +376,10 @@ func InfoFromGadgetYaml(gadgetYaml []byte, model Model) (*Info, error) {
 		switch v.Bootloader {
 		case "":
 			// pass
-		case "grub", "u-boot", "android-boot", "lk":
+		case "grub", "u-boot", "android-boot", "lk", "systemd-boot":
 			bootloadersFound += 1
 		default:
-			return nil, errors.New("bootloader must be one of grub, u-boot, android-boot or lk")
+			return nil, errors.New("bootloader must be one of grub, u-boot, android-boot, lk or systemd-boot")
 		}
 	}
 	switch {
@@ -1057,6 +1087,53 @@ func KernelCommandLineFromGadget(gadgetDirOrSnapPath string) (cmdline string, fu
 	return parsed, full, nil
 }
 
+func validateKernelCmdlineAllow(allow []string) error {
+	for _, pattern := range allow {
+		kargs, err := osutil.KernelCommandLineSplit(pattern)
+		if err != nil || len(kargs) != 1 || kargs[0] != pattern {
+			return fmt.Errorf("invalid kernel-cmdline allow pattern %q", pattern)
+		}
+		split := strings.SplitN(pattern, "=", 2)
+		if !isKernelArgumentAllowed(split[0]) {
+			return fmt.Errorf("disallowed kernel argument %q in kernel-cmdline allow list", pattern)
+		}
+	}
+	return nil
+}
+
+func kernelArgumentMatches(pattern, arg string) bool {
+	if pattern == arg {
+		return true
+	}
+	if strings.HasSuffix(pattern, "=*") {
+		return strings.HasPrefix(arg, pattern[:len(pattern)-1])
+	}
+	return false
+}
+
+// CheckKernelCommandLineAppend checks that all arguments of the given kernel
+// command line fragment are allowed by the kernel-cmdline allow list of the
+// gadget. The fragment is returned in a normalized form.
+func CheckKernelCommandLineAppend(gi *Info, cmdlineAppend string) (string, error) {
+	kargs, err := osutil.KernelCommandLineSplit(cmdlineAppend)
+	if err != nil {
+		return "", err
+	}
+	for _, arg := range kargs {
+		allowed := false
+		for _, pattern := range gi.KernelCmdline.Allow {
+			if kernelArgumentMatches(pattern, arg) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", fmt.Errorf("kernel argument %q is not allowed by the gadget", arg)
+		}
+	}
+	return strings.Join(kargs, " "), nil
+}
+
 // parseCommandLineFromGadget parses the command line file and returns a
 // reassembled kernel command line as a single string. The file can be multi
 // line, where only lines stating with # are treated as comments, eg.
@@ -641,6 +641,105 @@ func (s *gadgetYamlTestSuite) TestReadGadgetDefaultsMultiline(c *C) {
 	})
 }
 
+func (s *gadgetYamlTestSuite) TestReadGadgetYamlKernelCmdlineAllow(c *C) {
+	err := ioutil.WriteFile(s.gadgetYamlPath, []byte(`
+kernel-cmdline:
+  allow:
+    - quiet
+    - console=ttyS0,115200
+    - isolcpus=*
+`), 0644)
+	c.Assert(err, IsNil)
+
+	ginfo, err := gadget.ReadInfo(s.dir, &modelCharateristics{classic: true})
+	c.Assert(err, IsNil)
+	c.Assert(ginfo, DeepEquals, &gadget.Info{
+		KernelCmdline: gadget.KernelCmdline{
+			Allow: []string{"quiet", "console=ttyS0,115200", "isolcpus=*"},
+		},
+	})
+}
+
+func (s *gadgetYamlTestSuite) TestReadGadgetYamlKernelCmdlineAllowErrors(c *C) {
+	for _, tc := range []struct {
+		pattern string
+		err     string
+	}{
+		{"foo bar", `invalid kernel-cmdline allow pattern "foo bar"`},
+		{`"foo"`, `invalid kernel-cmdline allow pattern "\\"foo\\""`},
+		{"=*", `invalid kernel-cmdline allow pattern "=\*"`},
+		{"root=*", `disallowed kernel argument "root=\*" in kernel-cmdline allow list`},
+		{"snapd_recovery_mode=run", `disallowed kernel argument "snapd_recovery_mode=run" in kernel-cmdline allow list`},
+	} {
+		err := ioutil.WriteFile(s.gadgetYamlPath, []byte(fmt.Sprintf(`
+kernel-cmdline:
+  allow:
+    - '%s'
+`, tc.pattern)), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = gadget.ReadInfo(s.dir, &modelCharateristics{classic: true})
+		c.Check(err, ErrorMatches, tc.err, Commentf("pattern %q", tc.pattern))
+	}
+}
+
+func (s *gadgetYamlTestSuite) TestReadGadgetYamlCriticalSnaps(c *C) {
+	err := ioutil.WriteFile(s.gadgetYamlPath, []byte(`
+critical-snaps:
+  - mysnapidmysnapidmysnapidmysnapid
+`), 0644)
+	c.Assert(err, IsNil)
+
+	ginfo, err := gadget.ReadInfo(s.dir, &modelCharateristics{classic: true})
+	c.Assert(err, IsNil)
+	c.Assert(ginfo, DeepEquals, &gadget.Info{
+		CriticalSnaps: []string{"mysnapidmysnapidmysnapidmysnapid"},
+	})
+
+	err = ioutil.WriteFile(s.gadgetYamlPath, []byte(`
+critical-snaps:
+  - foo
+`), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = gadget.ReadInfo(s.dir, &modelCharateristics{classic: true})
+	c.Check(err, ErrorMatches, `critical-snaps entry is not a snap-id: foo`)
+}
+
+func (s *gadgetYamlTestSuite) TestCheckKernelCommandLineAppend(c *C) {
+	gi := &gadget.Info{
+		KernelCmdline: gadget.KernelCmdline{
+			Allow: []string{"quiet", "console=ttyS0,115200", "isolcpus=*"},
+		},
+	}
+	for _, tc := range []struct {
+		cmdline    string
+		normalized string
+		err        string
+	}{
+		{"", "", ""},
+		{"quiet", "quiet", ""},
+		{"  quiet   isolcpus=1-3 ", "quiet isolcpus=1-3", ""},
+		{"console=ttyS0,115200 isolcpus=", "console=ttyS0,115200 isolcpus=", ""},
+		{"console=tty1", "", `kernel argument "console=tty1" is not allowed by the gadget`},
+		{"quiet=1", "", `kernel argument "quiet=1" is not allowed by the gadget`},
+		{"isolcpus", "", `kernel argument "isolcpus" is not allowed by the gadget`},
+		{`quiet "foo`, "", `unexpected quoting`},
+	} {
+		normalized, err := gadget.CheckKernelCommandLineAppend(gi, tc.cmdline)
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, tc.err, Commentf("cmdline %q", tc.cmdline))
+			continue
+		}
+		c.Assert(err, IsNil, Commentf("cmdline %q", tc.cmdline))
+		c.Check(normalized, Equals, tc.normalized)
+	}
+
+	// nothing is allowed by default
+	_, err := gadget.CheckKernelCommandLineAppend(&gadget.Info{}, "quiet")
+	c.Check(err, ErrorMatches, `kernel argument "quiet" is not allowed by the gadget`)
+}
+
 func asOffsetPtr(offs quantity.Offset) *quantity.Offset {
 	goff := offs
 	return &goff
@@ -775,7 +874,7 @@ volumes:
 	c.Assert(err, IsNil)
 
 	_, err = gadget.ReadInfo(s.dir, nil)
-	c.Assert(err, ErrorMatches, "bootloader must be one of grub, u-boot, android-boot or lk")
+	c.Assert(err, ErrorMatches, "bootloader must be one of grub, u-boot, android-boot, lk or systemd-boot")
 }
 
 func (s *gadgetYamlTestSuite) TestReadGadgetYamlEmptyBootloader(c *C) {
@@ -2274,7 +2373,8 @@ volumes:
 	}{
 		{mockOtherYaml, `cannot find entry for volume "volumename" in updated gadget info`},
 		{mockManyYaml, "gadgets with multiple volumes are unsupported"},
-		{mockNewStructuresYaml, `incompatible layout change: incompatible change in the number of structures from 0 to 1`},
+		// structures can be appended
+		{mockNewStructuresYaml, ``},
 		{mockBadIDYaml, "incompatible layout change: incompatible ID change from 0C to 0D"},
 		{mockSchemaYaml, "incompatible layout change: incompatible schema change from mbr to gpt"},
 		{mockBootloaderYaml, "incompatible layout change: incompatible bootloader change from u-boot to grub"},
@@ -28,6 +28,7 @@ var (
 	EnsureLayoutCompatibility = ensureLayoutCompatibility
 	DeviceFromRole            = deviceFromRole
 	NewEncryptedDevice        = newEncryptedDevice
+	UbuntuSaveUnlockKey       = ubuntuSaveUnlockKey
 )
 
 func MockSecbootFormatEncryptedDevice(f func(key secboot.EncryptionKey, label, node string) error) (restore func()) {
@@ -45,3 +46,11 @@ func MockSecbootAddRecoveryKey(f func(key secboot.EncryptionKey, rkey secboot.Re
 		secbootAddRecoveryKey = old
 	}
 }
+
+func MockSecbootUnlockKeyFromKernel(f func(devicePath string) (secboot.EncryptionKey, error)) (restore func()) {
+	old := secbootUnlockKeyFromKernel
+	secbootUnlockKeyFromKernel = f
+	return func() {
+		secbootUnlockKeyFromKernel = old
+	}
+}
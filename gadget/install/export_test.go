@@ -37,6 +37,8 @@ var (
 
 	CreatedDuringInstall = createdDuringInstall
 	CreationSupported    = creationSupported
+
+	PartitionsForFactoryReset = partitionsForFactoryReset
 )
 
 func MockContentMountpoint(new string) (restore func()) {
@@ -70,3 +72,13 @@ func MockEnsureNodesExist(f func(dss []gadget.OnDiskStructure, timeout time.Dura
 		ensureNodesExist = old
 	}
 }
+
+var NewLayoutUpdater = newLayoutUpdater
+
+func MockFindVolumeDevice(f func(change *gadget.VolumeLayoutChange) (string, error)) (restore func()) {
+	old := findVolumeDevice
+	findVolumeDevice = f
+	return func() {
+		findVolumeDevice = old
+	}
+}
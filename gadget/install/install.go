@@ -27,11 +27,18 @@ import (
 
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil/disks"
 	"github.com/snapcore/snapd/secboot"
 	"github.com/snapcore/snapd/timings"
 )
 
+var (
+	disksDiskFromDeviceName    = disks.DiskFromDeviceName
+	secbootUnlockKeyFromKernel = secboot.UnlockKeyFromKernel
+)
+
 const (
 	ubuntuDataLabel = "ubuntu-data"
 	ubuntuSaveLabel = "ubuntu-save"
@@ -127,21 +134,6 @@ func Run(model gadget.Model, gadgetRoot, kernelRoot, device string, options Opti
 		return nil, fmt.Errorf("cannot create the partitions: %v", err)
 	}
 
-	makeKeySet := func() (*EncryptionKeySet, error) {
-		key, err := secboot.NewEncryptionKey()
-		if err != nil {
-			return nil, fmt.Errorf("cannot create encryption key: %v", err)
-		}
-
-		rkey, err := secboot.NewRecoveryKey()
-		if err != nil {
-			return nil, fmt.Errorf("cannot create recovery key: %v", err)
-		}
-		return &EncryptionKeySet{
-			Key:         key,
-			RecoveryKey: rkey,
-		}, nil
-	}
 	roleNeedsEncryption := func(role string) bool {
 		return role == gadget.SystemData || role == gadget.SystemSave
 	}
@@ -155,61 +147,185 @@ func Run(model gadget.Model, gadgetRoot, kernelRoot, device string, options Opti
 		logger.Noticef("created new partition %v for structure %v (size %v) %s",
 			part.Node, part, part.Size.IECString(), roleFmt)
 		if options.Encrypt && roleNeedsEncryption(part.Role) {
-			var keys *EncryptionKeySet
-			timings.Run(perfTimings, fmt.Sprintf("make-key-set[%s]", roleOrLabelOrName(part)), fmt.Sprintf("Create encryption key set for %s", roleOrLabelOrName(part)), func(timings.Measurer) {
-				keys, err = makeKeySet()
-			})
-			if err != nil {
-				return nil, err
-			}
-			logger.Noticef("encrypting partition device %v", part.Node)
-			var dataPart *encryptedDevice
-			timings.Run(perfTimings, fmt.Sprintf("new-encrypted-device[%s]", roleOrLabelOrName(part)), fmt.Sprintf("Create encryption device for %s", roleOrLabelOrName(part)), func(timings.Measurer) {
-				dataPart, err = newEncryptedDevice(&part, keys.Key, part.Label)
-			})
-			if err != nil {
-				return nil, err
-			}
-
-			timings.Run(perfTimings, fmt.Sprintf("add-recovery-key[%s]", roleOrLabelOrName(part)), fmt.Sprintf("Adding recovery key for %s", roleOrLabelOrName(part)), func(timings.Measurer) {
-				err = dataPart.AddRecoveryKey(keys.Key, keys.RecoveryKey)
-			})
+			keys, err := encryptPartition(&part, perfTimings)
 			if err != nil {
 				return nil, err
 			}
-
-			// update the encrypted device node
-			part.Node = dataPart.Node
 			if keysForRoles == nil {
 				keysForRoles = map[string]*EncryptionKeySet{}
 			}
 			keysForRoles[part.Role] = keys
-			logger.Noticef("encrypted device %v", part.Node)
 		}
 
-		// use the diskLayout.SectorSize here instead of lv.SectorSize, we check
-		// that if there is a sector-size specified in the gadget that it
-		// matches what is on the disk, but sometimes there may not be a sector
-		// size specified in the gadget.yaml, but we will always have the sector
-		// size from the physical disk device
-		timings.Run(perfTimings, fmt.Sprintf("make-filesystem[%s]", roleOrLabelOrName(part)), fmt.Sprintf("Create filesystem for %s", part.Node), func(timings.Measurer) {
-			err = makeFilesystem(&part, diskLayout.SectorSize)
-		})
+		if err := installOnePartition(&part, diskLayout.SectorSize, gadgetRoot, observer, options.Mount, perfTimings); err != nil {
+			return nil, err
+		}
+	}
+
+	return &InstalledSystemSideData{
+		KeysForRoles: keysForRoles,
+	}, nil
+}
+
+func makeKeySet() (*EncryptionKeySet, error) {
+	key, err := secboot.NewEncryptionKey()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create encryption key: %v", err)
+	}
+
+	rkey, err := secboot.NewRecoveryKey()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create recovery key: %v", err)
+	}
+	return &EncryptionKeySet{
+		Key:         key,
+		RecoveryKey: rkey,
+	}, nil
+}
+
+// encryptPartition creates a new key set and an encrypted device for the given
+// partition, updating its node to the one of the encrypted device.
+func encryptPartition(part *gadget.OnDiskStructure, perfTimings timings.Measurer) (keys *EncryptionKeySet, err error) {
+	timings.Run(perfTimings, fmt.Sprintf("make-key-set[%s]", roleOrLabelOrName(*part)), fmt.Sprintf("Create encryption key set for %s", roleOrLabelOrName(*part)), func(timings.Measurer) {
+		keys, err = makeKeySet()
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Noticef("encrypting partition device %v", part.Node)
+	var dataPart *encryptedDevice
+	timings.Run(perfTimings, fmt.Sprintf("new-encrypted-device[%s]", roleOrLabelOrName(*part)), fmt.Sprintf("Create encryption device for %s", roleOrLabelOrName(*part)), func(timings.Measurer) {
+		dataPart, err = newEncryptedDevice(part, keys.Key, part.Label)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timings.Run(perfTimings, fmt.Sprintf("add-recovery-key[%s]", roleOrLabelOrName(*part)), fmt.Sprintf("Adding recovery key for %s", roleOrLabelOrName(*part)), func(timings.Measurer) {
+		err = dataPart.AddRecoveryKey(keys.Key, keys.RecoveryKey)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// update the encrypted device node
+	part.Node = dataPart.Node
+	logger.Noticef("encrypted device %v", part.Node)
+	return keys, nil
+}
+
+// installOnePartition creates the filesystem of a partition, writes its
+// content and optionally mounts it.
+func installOnePartition(part *gadget.OnDiskStructure, sectorSize quantity.Size, gadgetRoot string, observer gadget.ContentObserver, mount bool, perfTimings timings.Measurer) (err error) {
+	// use the diskLayout.SectorSize here instead of lv.SectorSize, we check
+	// that if there is a sector-size specified in the gadget that it
+	// matches what is on the disk, but sometimes there may not be a sector
+	// size specified in the gadget.yaml, but we will always have the sector
+	// size from the physical disk device
+	timings.Run(perfTimings, fmt.Sprintf("make-filesystem[%s]", roleOrLabelOrName(*part)), fmt.Sprintf("Create filesystem for %s", part.Node), func(timings.Measurer) {
+		err = makeFilesystem(part, sectorSize)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot make filesystem for partition %s: %v", roleOrLabelOrName(*part), err)
+	}
+
+	timings.Run(perfTimings, fmt.Sprintf("write-content[%s]", roleOrLabelOrName(*part)), fmt.Sprintf("Write content for %s", roleOrLabelOrName(*part)), func(timings.Measurer) {
+		err = writeContent(part, gadgetRoot, observer)
+	})
+	if err != nil {
+		return err
+	}
+
+	if mount && part.Label != "" && part.HasFilesystem() {
+		if err := mountFilesystem(part, boot.InitramfsRunMntDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FactoryReset resets the run system partitions of a device. The ubuntu-boot
+// and ubuntu-data partitions are recreated in place, with ubuntu-data getting
+// a new encryption key set when encryption is used, while ubuntu-save and its
+// content are preserved. The ubuntu-save partition is expected to have been
+// unlocked and mounted already, its current key is returned as part of the
+// side data so that it can be sealed again. With encryption, the keys sealed
+// for the previous run system are left in place to be replaced by the new
+// ones when those are sealed.
+func FactoryReset(model gadget.Model, gadgetRoot, kernelRoot, device string, options Options, observer gadget.ContentObserver, perfTimings timings.Measurer) (*InstalledSystemSideData, error) {
+	logger.Noticef("performing factory reset on an installed system")
+	logger.Noticef("        gadget data from: %v", gadgetRoot)
+	if options.Encrypt {
+		logger.Noticef("        encryption: on")
+	}
+	if gadgetRoot == "" {
+		return nil, fmt.Errorf("cannot use empty gadget root directory")
+	}
+
+	lv, err := gadget.LaidOutSystemVolumeFromGadget(gadgetRoot, kernelRoot, model)
+	if err != nil {
+		return nil, fmt.Errorf("cannot layout the volume: %v", err)
+	}
+
+	// auto-detect device if no device is forced
+	if device == "" {
+		device, err = deviceFromRole(lv, gadget.SystemSeed)
 		if err != nil {
-			return nil, fmt.Errorf("cannot make filesystem for partition %s: %v", roleOrLabelOrName(part), err)
+			return nil, fmt.Errorf("cannot find device for factory reset: %v", err)
 		}
+	}
+
+	diskLayout, err := gadget.OnDiskVolumeFromDevice(device)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %v partitions: %v", device, err)
+	}
+
+	// the partition table must have been created by a previous install
+	if err := ensureLayoutCompatibility(lv, diskLayout); err != nil {
+		return nil, fmt.Errorf("gadget and %v partition table not compatible: %v", device, err)
+	}
 
-		timings.Run(perfTimings, fmt.Sprintf("write-content[%s]", roleOrLabelOrName(part)), fmt.Sprintf("Write content for %s", roleOrLabelOrName(part)), func(timings.Measurer) {
-			err = writeContent(&part, gadgetRoot, observer)
-		})
+	toReset, err := partitionsForFactoryReset(lv, diskLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	var keysForRoles map[string]*EncryptionKeySet
+	if !options.Encrypt {
+		// no keys are sealed for the new run system, the ones sealed
+		// for the previous one are obsolete
+		sealedKeyFiles, _ := filepath.Glob(filepath.Join(boot.InitramfsSeedEncryptionKeyDir, "*.sealed-key"))
+		for _, keyFile := range sealedKeyFiles {
+			if err := os.Remove(keyFile); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("cannot cleanup obsolete key file: %v", keyFile)
+			}
+		}
+	} else {
+		// keep the previous keys so that ubuntu-save can still be
+		// unlocked if the reset fails mid-way
+		saveKey, err := ubuntuSaveUnlockKey(diskLayout.Device)
 		if err != nil {
 			return nil, err
 		}
+		keysForRoles = map[string]*EncryptionKeySet{
+			// the recovery key of ubuntu-save is not known anymore
+			gadget.SystemSave: {Key: saveKey},
+		}
+	}
 
-		if options.Mount && part.Label != "" && part.HasFilesystem() {
-			if err := mountFilesystem(&part, boot.InitramfsRunMntDir); err != nil {
+	for _, part := range toReset {
+		logger.Noticef("resetting partition %v for structure %v (size %v) role %v",
+			part.Node, part, part.Size.IECString(), part.Role)
+		if options.Encrypt && part.Role == gadget.SystemData {
+			keys, err := encryptPartition(&part, perfTimings)
+			if err != nil {
 				return nil, err
 			}
+			keysForRoles[part.Role] = keys
+		}
+
+		if err := installOnePartition(&part, diskLayout.SectorSize, gadgetRoot, observer, options.Mount, perfTimings); err != nil {
+			return nil, err
 		}
 	}
 
@@ -218,6 +334,20 @@ func Run(model gadget.Model, gadgetRoot, kernelRoot, device string, options Opti
 	}, nil
 }
 
+// ubuntuSaveUnlockKey returns the key with which the encrypted ubuntu-save
+// partition on the given device was unlocked.
+func ubuntuSaveUnlockKey(device string) (secboot.EncryptionKey, error) {
+	disk, err := disksDiskFromDeviceName(device)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find disk %v: %v", device, err)
+	}
+	partUUID, err := disk.FindMatchingPartitionUUIDWithFsLabel(secboot.EncryptedPartitionName(ubuntuSaveLabel))
+	if err != nil {
+		return nil, fmt.Errorf("cannot find encrypted ubuntu-save partition: %v", err)
+	}
+	return secbootUnlockKeyFromKernel(filepath.Join("/dev/disk/by-partuuid", partUUID))
+}
+
 // isCreatableAtInstall returns whether the gadget structure would be created at
 // install - currently that is only ubuntu-save, ubuntu-data, and ubuntu-boot
 func isCreatableAtInstall(gv *gadget.VolumeStructure) bool {
@@ -328,15 +458,3 @@ func ensureLayoutCompatibility(gadgetLayout *gadget.LaidOutVolume, diskLayout *g
 
 	return nil
 }
-
-func isCompatibleSchema(gadgetSchema, diskSchema string) bool {
-	switch gadgetSchema {
-	// XXX: "mbr,gpt" is currently unsupported
-	case "", "gpt":
-		return diskSchema == "gpt"
-	case "mbr":
-		return diskSchema == "dos"
-	default:
-		return false
-	}
-}
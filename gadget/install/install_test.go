@@ -31,6 +31,8 @@ import (
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/gadget/install"
 	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/secboot"
 	"github.com/snapcore/snapd/testutil"
 	"github.com/snapcore/snapd/timings"
 )
@@ -61,6 +63,54 @@ func (s *installSuite) TestInstallRunError(c *C) {
 	c.Check(sys, IsNil)
 }
 
+func (s *installSuite) TestFactoryResetError(c *C) {
+	sys, err := install.FactoryReset(nil, "", "", "", install.Options{}, nil, timings.New(nil))
+	c.Assert(err, ErrorMatches, "cannot use empty gadget root directory")
+	c.Check(sys, IsNil)
+}
+
+func (s *installSuite) TestUbuntuSaveUnlockKey(c *C) {
+	restore := disks.MockDeviceNameDisksToPartitionMapping(map[string]*disks.MockDiskMapping{
+		"/dev/node": {
+			FilesystemLabelToPartUUID: map[string]string{
+				"ubuntu-save-enc": "save-partuuid",
+			},
+		},
+	})
+	defer restore()
+	restore = install.MockSecbootUnlockKeyFromKernel(func(devicePath string) (secboot.EncryptionKey, error) {
+		c.Check(devicePath, Equals, "/dev/disk/by-partuuid/save-partuuid")
+		return secboot.EncryptionKey("save-key"), nil
+	})
+	defer restore()
+
+	key, err := install.UbuntuSaveUnlockKey("/dev/node")
+	c.Assert(err, IsNil)
+	c.Check(key, DeepEquals, secboot.EncryptionKey("save-key"))
+
+	_, err = install.UbuntuSaveUnlockKey("/dev/other")
+	c.Assert(err, ErrorMatches, `cannot find disk /dev/other: .*`)
+}
+
+func (s *installSuite) TestUbuntuSaveUnlockKeyNotEncrypted(c *C) {
+	restore := disks.MockDeviceNameDisksToPartitionMapping(map[string]*disks.MockDiskMapping{
+		"/dev/node": {
+			FilesystemLabelToPartUUID: map[string]string{
+				"ubuntu-save": "save-partuuid",
+			},
+		},
+	})
+	defer restore()
+	restore = install.MockSecbootUnlockKeyFromKernel(func(devicePath string) (secboot.EncryptionKey, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})
+	defer restore()
+
+	_, err := install.UbuntuSaveUnlockKey("/dev/node")
+	c.Assert(err, ErrorMatches, `cannot find encrypted ubuntu-save partition: .*`)
+}
+
 const mockGadgetYaml = `volumes:
   pc:
     bootloader: grub
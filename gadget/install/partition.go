@@ -158,6 +158,18 @@ func buildPartitionList(dl *gadget.OnDiskVolume, pv *gadget.LaidOutVolume) (sfdi
 	return buf, toBeCreated
 }
 
+func isCompatibleSchema(gadgetSchema, diskSchema string) bool {
+	switch gadgetSchema {
+	// XXX: "mbr,gpt" is currently unsupported
+	case "", "gpt":
+		return diskSchema == "gpt"
+	case "mbr":
+		return diskSchema == "dos"
+	default:
+		return false
+	}
+}
+
 func partitionType(label, ptype string) string {
 	t := strings.Split(ptype, ",")
 	if len(t) < 1 {
@@ -308,3 +320,39 @@ func createdDuringInstall(lv *gadget.LaidOutVolume, layout *gadget.OnDiskVolume)
 	}
 	return created
 }
+
+// partitionsForFactoryReset returns the on disk structures of the partitions
+// that are recreated during factory reset, that is the ones with system-boot
+// and system-data roles. The ubuntu-save partition, if the gadget defines one,
+// must exist on disk as it is preserved across the reset.
+func partitionsForFactoryReset(lv *gadget.LaidOutVolume, dl *gadget.OnDiskVolume) ([]gadget.OnDiskStructure, error) {
+	onDisk := func(gs gadget.LaidOutStructure) *gadget.OnDiskStructure {
+		for _, s := range dl.Structure {
+			if s.StartOffset == gs.StartOffset {
+				return &s
+			}
+		}
+		return nil
+	}
+
+	var toReset []gadget.OnDiskStructure
+	for _, gs := range lv.LaidOutStructure {
+		switch gs.Role {
+		case gadget.SystemBoot, gadget.SystemData:
+			s := onDisk(gs)
+			if s == nil {
+				return nil, fmt.Errorf("cannot find partition for role %q on disk", gs.Role)
+			}
+			toReset = append(toReset, gadget.OnDiskStructure{
+				LaidOutStructure: gs,
+				Node:             s.Node,
+				Size:             s.Size,
+			})
+		case gadget.SystemSave:
+			if onDisk(gs) == nil {
+				return nil, fmt.Errorf("cannot find partition for role %q on disk", gs.Role)
+			}
+		}
+	}
+	return toReset, nil
+}
@@ -732,3 +732,47 @@ func (s *partitionTestSuite) TestCreationSupported(c *C) {
 	c.Check(install.CreationSupported(winBasic), Equals, true)
 	c.Check(install.CreationSupported("invalid-partion-uuid"), Equals, false)
 }
+
+func (s *partitionTestSuite) TestPartitionsForFactoryReset(c *C) {
+	err := makeMockGadget(s.gadgetRoot, mbrGadgetContentWithSave)
+	c.Assert(err, IsNil)
+	pv, err := mustLayOutVolumeFromGadget(c, s.gadgetRoot, "", uc20Mod)
+	c.Assert(err, IsNil)
+
+	dl := &gadget.OnDiskVolume{Device: "/dev/node", Schema: "dos"}
+	for i, ls := range pv.LaidOutStructure {
+		ds := gadget.OnDiskStructure{
+			Node: fmt.Sprintf("/dev/node%d", i+1),
+			Size: ls.Size,
+		}
+		ds.StartOffset = ls.StartOffset
+		if ls.Role == gadget.SystemData {
+			// data was expanded at install
+			ds.Size = 2 * ls.Size
+		}
+		dl.Structure = append(dl.Structure, ds)
+	}
+
+	toReset, err := install.PartitionsForFactoryReset(pv, dl)
+	c.Assert(err, IsNil)
+	c.Assert(toReset, HasLen, 2)
+	c.Check(toReset[0].Role, Equals, gadget.SystemBoot)
+	c.Check(toReset[0].Node, Equals, "/dev/node2")
+	c.Check(toReset[0].Label, Equals, "ubuntu-boot")
+	c.Check(toReset[0].Size, Equals, 1200*quantity.SizeMiB)
+	c.Check(toReset[1].Role, Equals, gadget.SystemData)
+	c.Check(toReset[1].Node, Equals, "/dev/node4")
+	c.Check(toReset[1].Size, Equals, 2400*quantity.SizeMiB)
+
+	all := dl.Structure
+
+	// ubuntu-data is missing
+	dl.Structure = all[:3]
+	_, err = install.PartitionsForFactoryReset(pv, dl)
+	c.Assert(err, ErrorMatches, `cannot find partition for role "system-data" on disk`)
+
+	// ubuntu-save is missing
+	dl.Structure = append(all[:2:2], all[3])
+	_, err = install.PartitionsForFactoryReset(pv, dl)
+	c.Assert(err, ErrorMatches, `cannot find partition for role "system-save" on disk`)
+}
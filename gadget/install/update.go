@@ -0,0 +1,428 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package install
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/disks"
+)
+
+func init() {
+	gadget.NewLayoutUpdater = newLayoutUpdater
+}
+
+var (
+	findVolumeDevice = findVolumeDeviceImpl
+)
+
+// partitionTableBackupName is the name of the file in the rollback
+// directory holding the partition table from before the update.
+const partitionTableBackupName = "partition-table.sfdisk"
+
+// partitionResize describes a partition of the volume whose size changes.
+type partitionResize struct {
+	// ds is the partition as found on disk
+	ds gadget.OnDiskStructure
+	// ps is the new definition of the structure
+	ps *gadget.LaidOutStructure
+	// size is the new size of the partition
+	size quantity.Size
+	// growFilesystem is set if the filesystem needs to be grown
+	// together with the partition
+	growFilesystem bool
+}
+
+// layoutUpdater applies changes to the partitioning of a volume as part of
+// a gadget update. Existing partitions are grown or shrunk in place, the
+// filesystems of grown partitions are resized and new partitions are
+// created and populated with their content.
+type layoutUpdater struct {
+	change      *gadget.VolumeLayoutChange
+	gadgetRoot  string
+	rollbackDir string
+
+	diskLayout *gadget.OnDiskVolume
+	resizes    []partitionResize
+	toCreate   int
+
+	// what was done by Update, so that it can be rolled back
+	resized        []partitionResize
+	grownFs        map[string]bool
+	createdNumbers []int
+}
+
+func newLayoutUpdater(change *gadget.VolumeLayoutChange, gadgetRoot, rollbackDir string) (gadget.Updater, error) {
+	if change == nil || change.Volume == nil {
+		return nil, fmt.Errorf("internal error: no layout change")
+	}
+	if rollbackDir == "" {
+		return nil, fmt.Errorf("internal error: backup directory cannot be unset")
+	}
+
+	device, err := findVolumeDevice(change)
+	if err != nil {
+		return nil, err
+	}
+	diskLayout, err := gadget.OnDiskVolumeFromDevice(device)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read partitioning of %s: %v", device, err)
+	}
+	if !isCompatibleSchema(change.Volume.Schema, diskLayout.Schema) {
+		return nil, fmt.Errorf("cannot update partitioning of %s: disk schema %q does not match gadget schema %q", device, diskLayout.Schema, change.Volume.Schema)
+	}
+
+	u := &layoutUpdater{
+		change:      change,
+		gadgetRoot:  gadgetRoot,
+		rollbackDir: rollbackDir,
+		diskLayout:  diskLayout,
+		grownFs:     make(map[string]bool),
+	}
+	if err := u.checkResizes(); err != nil {
+		return nil, err
+	}
+	if err := u.checkAdditions(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// findVolumeDeviceImpl returns the disk device holding the volume, located
+// through its partitions that are already deployed.
+func findVolumeDeviceImpl(change *gadget.VolumeLayoutChange) (string, error) {
+	added := make(map[quantity.Offset]bool, len(change.Added))
+	for _, ps := range change.Added {
+		added[ps.StartOffset] = true
+	}
+	for i := range change.Volume.LaidOutStructure {
+		ps := &change.Volume.LaidOutStructure[i]
+		if !ps.IsPartition() || added[ps.StartOffset] {
+			continue
+		}
+		node, err := gadget.FindDeviceForStructure(ps)
+		if err == gadget.ErrDeviceNotFound {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return gadget.ParentDiskFromMountSource(node)
+	}
+	return "", fmt.Errorf("cannot find the device holding the volume")
+}
+
+// onDiskStructureAt returns the partition on disk which starts at the given
+// offset.
+func onDiskStructureAt(dl *gadget.OnDiskVolume, offset quantity.Offset) (gadget.OnDiskStructure, bool) {
+	for _, ds := range dl.Structure {
+		if ds.StartOffset == offset {
+			return ds, true
+		}
+	}
+	return gadget.OnDiskStructure{}, false
+}
+
+// spaceAfter returns the end of the free space following the given offset,
+// which is either the start of the next partition or the end of the disk.
+func spaceAfter(dl *gadget.OnDiskVolume, offset quantity.Offset) quantity.Offset {
+	end := quantity.Offset(dl.Size)
+	for _, ds := range dl.Structure {
+		if ds.StartOffset > offset && ds.StartOffset < end {
+			end = ds.StartOffset
+		}
+	}
+	return end
+}
+
+// checkResizes verifies that the resized structures exist on disk and that
+// there is room for them to grow.
+func (u *layoutUpdater) checkResizes() error {
+	dl := u.diskLayout
+	disk, err := disks.DiskFromDeviceName(dl.Device)
+	if err != nil {
+		return fmt.Errorf("cannot find disk %s: %v", dl.Device, err)
+	}
+	for _, rs := range u.change.Resized {
+		ps := rs.To
+		// make sure that the structure is indeed on this disk
+		if ps.Name != "" && dl.Schema == "gpt" {
+			if _, err := disk.FindMatchingPartitionUUIDWithPartLabel(disks.BlkIDEncodeLabel(ps.Name)); err != nil {
+				return fmt.Errorf("cannot find structure %v on disk %s: %v", ps, dl.Device, err)
+			}
+		}
+		if ps.HasFilesystem() && ps.Label != "" {
+			if _, err := disk.FindMatchingPartitionUUIDWithFsLabel(disks.BlkIDEncodeLabel(ps.Label)); err != nil {
+				return fmt.Errorf("cannot find structure %v on disk %s: %v", ps, dl.Device, err)
+			}
+		}
+		ds, ok := onDiskStructureAt(dl, ps.StartOffset)
+		if !ok {
+			return fmt.Errorf("cannot find structure %v on disk %s at offset %v", ps, dl.Device, ps.StartOffset)
+		}
+
+		// the size on disk may differ from the one in the gadget, as
+		// the partition may have been expanded at install time
+		onDiskSize := ds.VolumeStructure.Size
+		resize := partitionResize{ds: ds, ps: ps}
+		switch {
+		case ps.Size > rs.From.Size:
+			if onDiskSize >= ps.Size {
+				// already large enough
+				continue
+			}
+			end := quantity.Offset(ps.Size) + ps.StartOffset
+			if end > spaceAfter(dl, ds.StartOffset) {
+				return fmt.Errorf("cannot grow structure %v to %v: not enough free space after it on disk", ps, ps.Size)
+			}
+			if ps.HasFilesystem() {
+				if ds.VolumeStructure.Filesystem != ps.Filesystem {
+					return fmt.Errorf("cannot grow structure %v: unexpected filesystem %q on disk", ps, ds.VolumeStructure.Filesystem)
+				}
+				resize.growFilesystem = true
+			}
+		case ps.Size < rs.From.Size:
+			if onDiskSize <= ps.Size {
+				continue
+			}
+		default:
+			continue
+		}
+		resize.size = ps.Size
+		u.resizes = append(u.resizes, resize)
+	}
+	return nil
+}
+
+// checkAdditions verifies that there is free space on disk for the new
+// structures and that partitions can be created for them.
+func (u *layoutUpdater) checkAdditions() error {
+	if len(u.change.Added) == 0 {
+		return nil
+	}
+	dl := u.diskLayout
+
+	// the resized partitions take up their new size
+	sizeOnDisk := func(ds gadget.OnDiskStructure) quantity.Size {
+		for _, r := range u.resizes {
+			if r.ds.StartOffset == ds.StartOffset {
+				return r.size
+			}
+		}
+		return ds.VolumeStructure.Size
+	}
+	for _, ps := range u.change.Added {
+		start := ps.StartOffset
+		end := ps.StartOffset + quantity.Offset(ps.Size)
+		if end > quantity.Offset(dl.Size) {
+			return fmt.Errorf("cannot add structure %v: not enough space on disk", ps)
+		}
+		for _, ds := range dl.Structure {
+			dsEnd := ds.StartOffset + quantity.Offset(sizeOnDisk(ds))
+			if start < dsEnd && ds.StartOffset < end {
+				return fmt.Errorf("cannot add structure %v: overlaps with partition %s on disk", ps, ds.Node)
+			}
+		}
+	}
+
+	_, toCreate := buildPartitionList(dl, u.change.Volume)
+	created := make(map[quantity.Offset]bool, len(toCreate))
+	for _, ds := range toCreate {
+		created[ds.StartOffset] = true
+	}
+	for _, ps := range u.change.Added {
+		if !created[ps.StartOffset] {
+			return fmt.Errorf("cannot create partition for structure %v: unsupported partition type %q", ps, ps.Type)
+		}
+		delete(created, ps.StartOffset)
+	}
+	if len(created) != 0 {
+		// would recreate a partition that should be there already
+		return fmt.Errorf("cannot update partitioning of %s: some existing structures are missing on disk", dl.Device)
+	}
+	u.toCreate = len(toCreate)
+	return nil
+}
+
+func (u *layoutUpdater) backupPath() string {
+	return filepath.Join(u.rollbackDir, partitionTableBackupName)
+}
+
+// Backup saves the partition table of the device.
+func (u *layoutUpdater) Backup() error {
+	output, err := exec.Command("sfdisk", "--dump", u.diskLayout.Device).Output()
+	if err != nil {
+		return fmt.Errorf("cannot dump partition table: %v", osutil.OutputErr(output, err))
+	}
+	return osutil.AtomicWriteFile(u.backupPath(), output, 0600, 0)
+}
+
+// Update resizes and creates the partitions.
+func (u *layoutUpdater) Update() error {
+	if len(u.resizes) == 0 && u.toCreate == 0 {
+		return gadget.ErrNoUpdate
+	}
+	dl := u.diskLayout
+
+	if len(u.resizes) > 0 {
+		for _, r := range u.resizes {
+			logger.Debugf("resize partition %s of %s to %v", r.ds.Node, dl.Device, r.size)
+			// only the size of the given partition is changed
+			input := fmt.Sprintf(",%d\n", r.size/dl.SectorSize)
+			if err := sfdiskChangePartition(dl.Device, r.ds.Index, input); err != nil {
+				return fmt.Errorf("cannot resize partition %s: %v", r.ds.Node, err)
+			}
+			u.resized = append(u.resized, r)
+		}
+		if err := reloadPartitionTable(dl.Device); err != nil {
+			return err
+		}
+		for _, r := range u.resizes {
+			if !r.growFilesystem {
+				continue
+			}
+			// ext4 can be grown while mounted
+			if output, err := exec.Command("resize2fs", r.ds.Node).CombinedOutput(); err != nil {
+				return fmt.Errorf("cannot resize filesystem of %s: %v", r.ds.Node, osutil.OutputErr(output, err))
+			}
+			u.grownFs[r.ds.Node] = true
+		}
+	}
+
+	if u.toCreate > 0 {
+		created, err := createMissingPartitions(dl, u.change.Volume)
+		if err != nil {
+			return fmt.Errorf("cannot create partitions: %v", err)
+		}
+		for _, ds := range created {
+			u.createdNumbers = append(u.createdNumbers, partitionNumber(u.change.Volume, ds.StartOffset))
+		}
+		for i := range created {
+			ds := &created[i]
+			if err := makeFilesystem(ds, dl.SectorSize); err != nil {
+				return fmt.Errorf("cannot make filesystem for structure %v: %v", ds, err)
+			}
+			if err := writeContent(ds, u.gadgetRoot, nil); err != nil {
+				return fmt.Errorf("cannot write content of structure %v: %v", ds, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Rollback removes the created partitions and restores the size of the
+// resized ones. Grown filesystems cannot be shrunk back, those partitions
+// are kept at their new size which is compatible with the old layout.
+func (u *layoutUpdater) Rollback() error {
+	if len(u.resized) == 0 && len(u.createdNumbers) == 0 {
+		return nil
+	}
+	dl := u.diskLayout
+
+	if len(u.createdNumbers) > 0 {
+		numbers := make([]string, len(u.createdNumbers))
+		for i, n := range u.createdNumbers {
+			numbers[i] = strconv.Itoa(n)
+		}
+		cmd := exec.Command("sfdisk", append([]string{"--no-reread", "--delete", dl.Device}, numbers...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("cannot remove created partitions: %v", osutil.OutputErr(output, err))
+		}
+	}
+
+	if len(u.resized) > 0 {
+		dump, err := ioutil.ReadFile(u.backupPath())
+		if err != nil {
+			return fmt.Errorf("cannot read partition table backup: %v", err)
+		}
+		entries := partitionDumpEntries(dump)
+		for _, r := range u.resized {
+			if u.grownFs[r.ds.Node] {
+				logger.Noticef("cannot shrink the filesystem of %s back, keeping the partition at its new size", r.ds.Node)
+				continue
+			}
+			entry, ok := entries[r.ds.Node]
+			if !ok {
+				return fmt.Errorf("cannot find partition %s in partition table backup", r.ds.Node)
+			}
+			if err := sfdiskChangePartition(dl.Device, r.ds.Index, entry+"\n"); err != nil {
+				return fmt.Errorf("cannot restore partition %s: %v", r.ds.Node, err)
+			}
+		}
+	}
+
+	return reloadPartitionTable(dl.Device)
+}
+
+// sfdiskChangePartition changes the partition with the given number as
+// described by the sfdisk input.
+func sfdiskChangePartition(device string, number int, input string) error {
+	// partitions of the device are in use, see createMissingPartitions
+	cmd := exec.Command("sfdisk", "--no-reread", "-N", strconv.Itoa(number), device)
+	cmd.Stdin = strings.NewReader(input)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return osutil.OutputErr(output, err)
+	}
+	return nil
+}
+
+// partitionNumber returns the number of the partition of the structure at
+// the given offset, as assigned when creating partitions.
+func partitionNumber(pv *gadget.LaidOutVolume, offset quantity.Offset) int {
+	n := 0
+	for _, ps := range pv.LaidOutStructure {
+		if !ps.IsPartition() {
+			continue
+		}
+		n++
+		if ps.StartOffset == offset {
+			break
+		}
+	}
+	return n
+}
+
+// partitionDumpEntries maps the device nodes of the partitions to their
+// entries in the sfdisk dump.
+func partitionDumpEntries(dump []byte) map[string]string {
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(dump))
+	for scanner.Scan() {
+		line := scanner.Text()
+		// /dev/sda1 : start=        2048, size=        2048, type=...
+		idx := strings.Index(line, " : ")
+		if idx == -1 || !strings.HasPrefix(line, "/") {
+			continue
+		}
+		entries[strings.TrimSpace(line[:idx])] = strings.TrimSpace(line[idx+len(" : "):])
+	}
+	return entries
+}
@@ -0,0 +1,296 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package install_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/gadget/install"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type layoutUpdaterTestSuite struct {
+	testutil.BaseTest
+
+	gadgetRoot  string
+	rollbackDir string
+
+	cmdSfdisk    *testutil.MockCmd
+	cmdPartx     *testutil.MockCmd
+	cmdResize2fs *testutil.MockCmd
+	cmdMkfsExt4  *testutil.MockCmd
+
+	mountCalls []string
+}
+
+var _ = Suite(&layoutUpdaterTestSuite{})
+
+const sfdiskDumpScript = `
+if [ "$1" = "--dump" ]; then
+	cat <<SFDISK
+label: gpt
+label-id: 9151F25B-CDF0-48F1-9EDE-68CBD616E2CA
+device: /dev/node
+unit: sectors
+
+/dev/node1 : start=        2048, size=        2048, type=21686148-6449-6E6F-744E-656564454649, uuid=2E59D969-52AB-430B-88AC-F83873519F6F, name="BIOS Boot"
+/dev/node2 : start=        4096, size=     2457600, type=C12A7328-F81F-11D2-BA4B-00A0C93EC93B, uuid=44C3D5C3-CAE1-4306-83E8-DF437ACDB32F, name="Recovery"
+/dev/node3 : start=     2461696, size=     2457600, type=0FC63DAF-8483-4772-8E79-3D69D8477DE4, uuid=f940029d-bfbb-4887-9d44-321e85c63866, name="Writable"
+SFDISK
+	exit 0
+fi
+if [ "$1" != "--json" ]; then
+	cat > /dev/null
+	exit 0
+fi
+`
+
+const gadgetContentLayoutUpdate = `volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: mbr
+        type: mbr
+        size: 440
+        content:
+          - image: pc-boot.img
+      - name: BIOS Boot
+        type: DA,21686148-6449-6E6F-744E-656564454649
+        size: 1M
+        offset: 1M
+        offset-write: mbr+92
+        content:
+          - image: pc-core.img
+      - name: Recovery
+        role: system-seed
+        filesystem: vfat
+        type: EF,C12A7328-F81F-11D2-BA4B-00A0C93EC93B
+        size: 1200M
+        content:
+          - source: grubx64.efi
+            target: EFI/boot/grubx64.efi
+      - name: Writable
+        role: system-data
+        filesystem: ext4
+        type: 83,0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        size: 1500M
+      - name: Extra
+        filesystem: ext4
+        filesystem-label: extra
+        type: 83,0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        size: 100M
+        content:
+          - source: extra-content/
+            target: /
+`
+
+func (s *layoutUpdaterTestSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.gadgetRoot = c.MkDir()
+	c.Assert(makeMockGadget(s.gadgetRoot, gadgetContentLayoutUpdate), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(s.gadgetRoot, "extra-content"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.gadgetRoot, "extra-content/foo"), []byte("foo"), 0644), IsNil)
+	s.rollbackDir = c.MkDir()
+
+	s.cmdSfdisk = testutil.MockCommand(c, "sfdisk", sfdiskDumpScript+makeSfdiskScript(scriptPartitionsBiosSeedData))
+	s.AddCleanup(s.cmdSfdisk.Restore)
+	cmdLsblk := testutil.MockCommand(c, "lsblk", makeLsblkScript(scriptPartitionsBiosSeedData))
+	s.AddCleanup(cmdLsblk.Restore)
+	cmdBlockdev := testutil.MockCommand(c, "blockdev", blockdevSectorSize512Script)
+	s.AddCleanup(cmdBlockdev.Restore)
+	s.cmdPartx = testutil.MockCommand(c, "partx", "")
+	s.AddCleanup(s.cmdPartx.Restore)
+	cmdUdevadm := testutil.MockCommand(c, "udevadm", "")
+	s.AddCleanup(cmdUdevadm.Restore)
+	s.cmdResize2fs = testutil.MockCommand(c, "resize2fs", "")
+	s.AddCleanup(s.cmdResize2fs.Restore)
+	s.cmdMkfsExt4 = testutil.MockCommand(c, "mkfs.ext4", "")
+	s.AddCleanup(s.cmdMkfsExt4.Restore)
+
+	s.AddCleanup(install.MockFindVolumeDevice(func(change *gadget.VolumeLayoutChange) (string, error) {
+		return "/dev/node", nil
+	}))
+	s.AddCleanup(disks.MockDeviceNameDisksToPartitionMapping(map[string]*disks.MockDiskMapping{
+		"/dev/node": {
+			FilesystemLabelToPartUUID: map[string]string{
+				"ubuntu-seed": "44c3d5c3-cae1-4306-83e8-df437acdb32f",
+				"ubuntu-data": "f940029d-bfbb-4887-9d44-321e85c63866",
+			},
+			PartitionLabelToPartUUID: map[string]string{
+				"BIOS\\x20Boot": "2e59d969-52ab-430b-88ac-f83873519f6f",
+				"Recovery":      "44c3d5c3-cae1-4306-83e8-df437acdb32f",
+				"Writable":      "f940029d-bfbb-4887-9d44-321e85c63866",
+			},
+			DiskHasPartitions: true,
+		},
+	}))
+	s.AddCleanup(install.MockEnsureNodesExist(func(dss []gadget.OnDiskStructure, timeout time.Duration) error {
+		return nil
+	}))
+	s.AddCleanup(install.MockContentMountpoint(c.MkDir()))
+	s.mountCalls = nil
+	s.AddCleanup(install.MockSysMount(func(source, target, fstype string, flags uintptr, data string) error {
+		s.mountCalls = append(s.mountCalls, source)
+		return nil
+	}))
+	s.AddCleanup(install.MockSysUnmount(func(target string, flags int) error {
+		return nil
+	}))
+}
+
+// layoutChange returns a change growing the system-data structure from
+// 1200M and, if withExtra is set, adding the extra structure.
+func (s *layoutUpdaterTestSuite) layoutChange(c *C, withExtra bool) *gadget.VolumeLayoutChange {
+	pv, err := mustLayOutVolumeFromGadget(c, s.gadgetRoot, "", uc20Mod)
+	c.Assert(err, IsNil)
+	c.Assert(pv.LaidOutStructure, HasLen, 5)
+
+	writable := &pv.LaidOutStructure[3]
+	c.Assert(writable.Name, Equals, "Writable")
+	oldVs := *writable.VolumeStructure
+	oldVs.Size = 1200 * quantity.SizeMiB
+	oldWritable := *writable
+	oldWritable.VolumeStructure = &oldVs
+
+	change := &gadget.VolumeLayoutChange{
+		Volume: pv,
+		Resized: []gadget.ResizedStructure{
+			{From: &oldWritable, To: writable},
+		},
+	}
+	if withExtra {
+		change.Added = []*gadget.LaidOutStructure{&pv.LaidOutStructure[4]}
+	} else {
+		pv.LaidOutStructure = pv.LaidOutStructure[:4]
+	}
+	return change
+}
+
+func (s *layoutUpdaterTestSuite) TestRegistered(c *C) {
+	c.Check(gadget.NewLayoutUpdater, NotNil)
+}
+
+func (s *layoutUpdaterTestSuite) TestHappy(c *C) {
+	change := s.layoutChange(c, true)
+	up, err := install.NewLayoutUpdater(change, s.gadgetRoot, s.rollbackDir)
+	c.Assert(err, IsNil)
+
+	c.Assert(up.Backup(), IsNil)
+	c.Check(filepath.Join(s.rollbackDir, "partition-table.sfdisk"), testutil.FileContains,
+		`/dev/node3 : start=     2461696, size=     2457600`)
+
+	c.Assert(up.Update(), IsNil)
+	c.Check(s.cmdSfdisk.Calls(), DeepEquals, [][]string{
+		{"sfdisk", "--json", "/dev/node"},
+		{"sfdisk", "--dump", "/dev/node"},
+		// grow system-data to 1500M
+		{"sfdisk", "--no-reread", "-N", "3", "/dev/node"},
+		// and add the new partition
+		{"sfdisk", "--append", "--no-reread", "/dev/node"},
+	})
+	c.Check(s.cmdPartx.Calls(), DeepEquals, [][]string{
+		{"partx", "-u", "/dev/node"},
+		{"partx", "-u", "/dev/node"},
+	})
+	c.Check(s.cmdResize2fs.Calls(), DeepEquals, [][]string{
+		{"resize2fs", "/dev/node3"},
+	})
+	mkfsCalls := s.cmdMkfsExt4.Calls()
+	c.Assert(mkfsCalls, HasLen, 1)
+	c.Check(mkfsCalls[0][len(mkfsCalls[0])-1], Equals, "/dev/node4")
+	// the content of the new structure was written
+	c.Check(s.mountCalls, DeepEquals, []string{"/dev/node4"})
+
+	// the grown filesystem is kept, the new partition is removed
+	s.cmdSfdisk.ForgetCalls()
+	s.cmdPartx.ForgetCalls()
+	c.Assert(up.Rollback(), IsNil)
+	c.Check(s.cmdSfdisk.Calls(), DeepEquals, [][]string{
+		{"sfdisk", "--no-reread", "--delete", "/dev/node", "4"},
+	})
+	c.Check(s.cmdPartx.Calls(), DeepEquals, [][]string{
+		{"partx", "-u", "/dev/node"},
+	})
+}
+
+func (s *layoutUpdaterTestSuite) TestRollbackRestoresPartition(c *C) {
+	cmdResize2fs := testutil.MockCommand(c, "resize2fs", "echo boom; exit 1")
+	defer cmdResize2fs.Restore()
+
+	change := s.layoutChange(c, false)
+	up, err := install.NewLayoutUpdater(change, s.gadgetRoot, s.rollbackDir)
+	c.Assert(err, IsNil)
+	c.Assert(up.Backup(), IsNil)
+	err = up.Update()
+	c.Assert(err, ErrorMatches, "cannot resize filesystem of /dev/node3: boom")
+
+	s.cmdSfdisk.ForgetCalls()
+	c.Assert(up.Rollback(), IsNil)
+	// the partition entry is restored from the backup
+	c.Check(s.cmdSfdisk.Calls(), DeepEquals, [][]string{
+		{"sfdisk", "--no-reread", "-N", "3", "/dev/node"},
+	})
+}
+
+func (s *layoutUpdaterTestSuite) TestNothingToDo(c *C) {
+	change := s.layoutChange(c, false)
+	// the partition is already large enough on disk
+	change.Resized[0].To.Size = 1100 * quantity.SizeMiB
+	change.Resized[0].From.Size = 1000 * quantity.SizeMiB
+	up, err := install.NewLayoutUpdater(change, s.gadgetRoot, s.rollbackDir)
+	c.Assert(err, IsNil)
+	c.Assert(up.Backup(), IsNil)
+	c.Check(up.Update(), Equals, gadget.ErrNoUpdate)
+	c.Check(up.Rollback(), IsNil)
+	c.Check(s.cmdResize2fs.Calls(), HasLen, 0)
+}
+
+func (s *layoutUpdaterTestSuite) TestErrors(c *C) {
+	change := s.layoutChange(c, false)
+	change.Resized[0].To.Size = 3000 * quantity.SizeMiB
+	_, err := install.NewLayoutUpdater(change, s.gadgetRoot, s.rollbackDir)
+	c.Check(err, ErrorMatches, `cannot grow structure #3 \("Writable"\) to 3145728000: not enough free space after it on disk`)
+
+	change = s.layoutChange(c, true)
+	change.Added[0].StartOffset = quantity.Offset(1500 * quantity.SizeMiB)
+	_, err = install.NewLayoutUpdater(change, s.gadgetRoot, s.rollbackDir)
+	c.Check(err, ErrorMatches, `cannot add structure #4 \("Extra"\): overlaps with partition /dev/node3 on disk`)
+
+	change = s.layoutChange(c, false)
+	change.Resized[0].To.Label = "other-label"
+	_, err = install.NewLayoutUpdater(change, s.gadgetRoot, s.rollbackDir)
+	c.Check(err, ErrorMatches, `cannot find structure #3 \("Writable"\) on disk /dev/node: .*`)
+
+	restore := install.MockFindVolumeDevice(func(change *gadget.VolumeLayoutChange) (string, error) {
+		return "", gadget.ErrDeviceNotFound
+	})
+	defer restore()
+	_, err = install.NewLayoutUpdater(change, s.gadgetRoot, s.rollbackDir)
+	c.Check(err, Equals, gadget.ErrDeviceNotFound)
+}
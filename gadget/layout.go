@@ -497,14 +497,14 @@ func isLayoutCompatible(current, new *PartiallyLaidOutVolume) error {
 			current.Bootloader, new.Bootloader)
 	}
 
-	// XXX: the code below asssumes both volumes have the same number of
-	// structures, this limitation may be lifted later
-	if len(current.LaidOutStructure) != len(new.LaidOutStructure) {
+	// structures can be appended to the volume, but not removed
+	if len(current.LaidOutStructure) > len(new.LaidOutStructure) {
 		return fmt.Errorf("incompatible change in the number of structures from %v to %v",
 			len(current.LaidOutStructure), len(new.LaidOutStructure))
 	}
 
-	// at the structure level we expect the volume to be identical
+	// at the structure level we expect the volume to be identical, other
+	// than for the changes that can be applied by an update
 	for i := range current.LaidOutStructure {
 		from := &current.LaidOutStructure[i]
 		to := &new.LaidOutStructure[i]
@@ -512,5 +512,11 @@ func isLayoutCompatible(current, new *PartiallyLaidOutVolume) error {
 			return fmt.Errorf("incompatible structure %v change: %v", to, err)
 		}
 	}
+	for i := len(current.LaidOutStructure); i < len(new.LaidOutStructure); i++ {
+		to := &new.LaidOutStructure[i]
+		if err := canAddStructure(to); err != nil {
+			return fmt.Errorf("incompatible structure %v addition: %v", to, err)
+		}
+	}
 	return nil
 }
@@ -27,6 +27,7 @@ import (
 	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/kernel"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/strutil"
 )
 
 var (
@@ -66,6 +67,34 @@ type UpdatePolicyFunc func(from, to *LaidOutStructure) (bool, ResolvedContentFil
 // the kernel snap.
 type ResolvedContentFilterFunc func(*ResolvedContent) bool
 
+// VolumeLayoutChange describes the changes to the partitioning of a volume
+// that are part of a gadget update.
+type VolumeLayoutChange struct {
+	// Volume is the new layout of the volume
+	Volume *LaidOutVolume
+	// Resized lists the existing structures whose size changes
+	Resized []ResizedStructure
+	// Added lists the structures appended to the volume, with their
+	// content resolved
+	Added []*LaidOutStructure
+}
+
+// ResizedStructure is a pair of the current and the new definition of a
+// structure whose size changes.
+type ResizedStructure struct {
+	From *LaidOutStructure
+	To   *LaidOutStructure
+}
+
+// LayoutUpdaterFunc returns an Updater applying the given change to the
+// partitioning of the volume on disk. The data needed to roll back the
+// change is kept in rollbackDir.
+type LayoutUpdaterFunc func(change *VolumeLayoutChange, newRootDir, rollbackDir string) (Updater, error)
+
+// NewLayoutUpdater implements changes to the partitioning of a volume. It is
+// provided by gadget/install which has the partitioning helpers.
+var NewLayoutUpdater LayoutUpdaterFunc
+
 // ContentChange carries paths to files containing the content data being
 // modified by the operation.
 type ContentChange struct {
@@ -168,20 +197,44 @@ func Update(old, new GadgetData, rollbackDirPath string, updatePolicy UpdatePoli
 		return nil
 	}
 
-	oldVol, newVol, err := resolveVolume(old.Info, new.Info)
+	layoutChange, updates, err := prepareUpdate(old, new, updatePolicy)
 	if err != nil {
 		return err
 	}
 
+	return applyUpdates(new, layoutChange, updates, rollbackDirPath, observer)
+}
+
+// CheckUpdate performs the same checks as Update when going from the old to the
+// new gadget data with the given update policy, without touching anything.
+// Returns nil when the update could be applied, ErrNoUpdate when there is
+// nothing to update, or an error describing why the update is not possible.
+func CheckUpdate(old, new GadgetData, updatePolicy UpdatePolicyFunc) error {
+	if len(new.Info.Volumes) != 1 || len(old.Info.Volumes) != 1 {
+		// not updated by Update either
+		return ErrNoUpdate
+	}
+	_, _, err := prepareUpdate(old, new, updatePolicy)
+	return err
+}
+
+// prepareUpdate lays out the old and the new volume and resolves the structures
+// that need to be updated, together with the changes to the partition layout.
+func prepareUpdate(old, new GadgetData, updatePolicy UpdatePolicyFunc) (layoutChange *VolumeLayoutChange, updates []updatePair, err error) {
+	oldVol, newVol, err := resolveVolume(old.Info, new.Info)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	if oldVol.Schema == "" || newVol.Schema == "" {
-		return fmt.Errorf("internal error: unset volume schemas: old: %q new: %q", oldVol.Schema, newVol.Schema)
+		return nil, nil, fmt.Errorf("internal error: unset volume schemas: old: %q new: %q", oldVol.Schema, newVol.Schema)
 	}
 
 	// layout old partially, without going deep into the layout of structure
 	// content
 	pOld, err := LayoutVolumePartially(oldVol, DefaultConstraints)
 	if err != nil {
-		return fmt.Errorf("cannot lay out the old volume: %v", err)
+		return nil, nil, fmt.Errorf("cannot lay out the old volume: %v", err)
 	}
 
 	// Layout new volume, delay resolving of filesystem content
@@ -189,11 +242,11 @@ func Update(old, new GadgetData, rollbackDirPath string, updatePolicy UpdatePoli
 	constraints.SkipResolveContent = true
 	pNew, err := LayoutVolume(new.RootDir, new.KernelRootDir, newVol, constraints)
 	if err != nil {
-		return fmt.Errorf("cannot lay out the new volume: %v", err)
+		return nil, nil, fmt.Errorf("cannot lay out the new volume: %v", err)
 	}
 
 	if err := canUpdateVolume(pOld, pNew); err != nil {
-		return fmt.Errorf("cannot apply update to volume: %v", err)
+		return nil, nil, fmt.Errorf("cannot apply update to volume: %v", err)
 	}
 
 	if updatePolicy == nil {
@@ -203,30 +256,46 @@ func Update(old, new GadgetData, rollbackDirPath string, updatePolicy UpdatePoli
 	// ensure all required kernel assets are found in the gadget
 	kernelInfo, err := kernel.ReadInfo(new.KernelRootDir)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	if err := gadgetVolumeConsumesOneKernelUpdateAsset(pNew.Volume, kernelInfo); err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// now we know which structure is which, find which ones need an update
-	updates, err := resolveUpdate(pOld, pNew, updatePolicy, new.RootDir, new.KernelRootDir, kernelInfo)
+	updates, err = resolveUpdate(pOld, pNew, updatePolicy, new.RootDir, new.KernelRootDir, kernelInfo)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	// structures that are resized or added are updated regardless of the
+	// policy
+	layoutChange, err = resolveLayoutChange(pOld, pNew, new.RootDir, new.KernelRootDir, kernelInfo)
+	if err != nil {
+		return nil, nil, err
 	}
-	if len(updates) == 0 {
+	if len(updates) == 0 && layoutChange == nil {
 		// nothing to update
-		return ErrNoUpdate
+		return nil, nil, ErrNoUpdate
 	}
 
-	// can update old layout to new layout
+	// can update old layout to new layout, both for the structures
+	// updated according to the policy and the ones being resized
+	updated := make(map[*LaidOutStructure]bool, len(updates))
 	for _, update := range updates {
-		if err := canUpdateStructure(update.from, update.to, pNew.Schema); err != nil {
-			return fmt.Errorf("cannot update volume structure %v: %v", update.to, err)
+		updated[update.to] = true
+	}
+	for j := range pOld.LaidOutStructure {
+		from := &pOld.LaidOutStructure[j]
+		to := &pNew.LaidOutStructure[j]
+		if !updated[to] && from.Size == to.Size {
+			continue
+		}
+		if err := canUpdateStructure(from, to, pNew.Schema); err != nil {
+			return nil, nil, fmt.Errorf("cannot update volume structure %v: %v", to, err)
 		}
 	}
 
-	return applyUpdates(new, updates, rollbackDirPath, observer)
+	return layoutChange, updates, nil
 }
 
 func resolveVolume(old *Info, new *Info) (oldVol, newVol *Volume, err error) {
@@ -282,7 +351,9 @@ func canUpdateStructure(from *LaidOutStructure, to *LaidOutStructure, schema str
 		return fmt.Errorf("cannot change structure name from %q to %q", from.Name, to.Name)
 	}
 	if from.Size != to.Size {
-		return fmt.Errorf("cannot change structure size from %v to %v", from.Size, to.Size)
+		if err := canResizeStructure(from, to); err != nil {
+			return err
+		}
 	}
 	if !isSameOffset(from.Offset, to.Offset) {
 		return fmt.Errorf("cannot change structure offset from %v to %v", from.Offset, to.Offset)
@@ -326,6 +397,40 @@ func canUpdateStructure(from *LaidOutStructure, to *LaidOutStructure, schema str
 	return nil
 }
 
+// resizableFilesystems lists the filesystems that can be grown in place.
+var resizableFilesystems = []string{"ext4"}
+
+func canResizeStructure(from *LaidOutStructure, to *LaidOutStructure) error {
+	// only partitions can be resized, the partition table entry is
+	// what keeps track of the size
+	if !from.IsPartition() || !to.IsPartition() {
+		return fmt.Errorf("cannot change structure size from %v to %v", from.Size, to.Size)
+	}
+	if to.HasFilesystem() {
+		// shrinking a filesystem is not safe while it is in use
+		if to.Size < from.Size {
+			return fmt.Errorf("cannot shrink filesystem structure from %v to %v", from.Size, to.Size)
+		}
+		if !strutil.ListContains(resizableFilesystems, to.Filesystem) {
+			return fmt.Errorf("cannot change size of %q filesystem structure from %v to %v", to.Filesystem, from.Size, to.Size)
+		}
+	}
+	return nil
+}
+
+// canAddStructure checks whether the structure can be appended to an already
+// deployed volume.
+func canAddStructure(ps *LaidOutStructure) error {
+	if !ps.IsPartition() {
+		return fmt.Errorf("cannot add a structure without a partition table entry")
+	}
+	if ps.Role != "" {
+		// structures with roles are set up at install time
+		return fmt.Errorf("cannot add a structure with role %q", ps.Role)
+	}
+	return nil
+}
+
 func canUpdateVolume(from *PartiallyLaidOutVolume, to *LaidOutVolume) error {
 	if from.ID != to.ID {
 		return fmt.Errorf("cannot change volume ID from %q to %q", from.ID, to.ID)
@@ -333,9 +438,15 @@ func canUpdateVolume(from *PartiallyLaidOutVolume, to *LaidOutVolume) error {
 	if from.Schema != to.Schema {
 		return fmt.Errorf("cannot change volume schema from %q to %q", from.Schema, to.Schema)
 	}
-	if len(from.LaidOutStructure) != len(to.LaidOutStructure) {
+	// structures can be appended, but not removed
+	if len(from.LaidOutStructure) > len(to.LaidOutStructure) {
 		return fmt.Errorf("cannot change the number of structures within volume from %v to %v", len(from.LaidOutStructure), len(to.LaidOutStructure))
 	}
+	for j := len(from.LaidOutStructure); j < len(to.LaidOutStructure); j++ {
+		if err := canAddStructure(&to.LaidOutStructure[j]); err != nil {
+			return fmt.Errorf("cannot add volume structure %v: %v", to.LaidOutStructure[j], err)
+		}
+	}
 	return nil
 }
 
@@ -381,7 +492,7 @@ func KernelUpdatePolicy(from, to *LaidOutStructure) (bool, ResolvedContentFilter
 }
 
 func resolveUpdate(oldVol *PartiallyLaidOutVolume, newVol *LaidOutVolume, policy UpdatePolicyFunc, newGadgetRootDir, newKernelRootDir string, kernelInfo *kernel.Info) (updates []updatePair, err error) {
-	if len(oldVol.LaidOutStructure) != len(newVol.LaidOutStructure) {
+	if len(oldVol.LaidOutStructure) > len(newVol.LaidOutStructure) {
 		return nil, errors.New("internal error: the number of structures in new and old volume definitions is different")
 	}
 	for j, oldStruct := range oldVol.LaidOutStructure {
@@ -413,6 +524,36 @@ func resolveUpdate(oldVol *PartiallyLaidOutVolume, newVol *LaidOutVolume, policy
 	return updates, nil
 }
 
+// resolveLayoutChange returns the changes to the partitioning needed to go
+// from the old to the new volume layout, or nil if there are none. The new
+// volume is not modified, the content of the added structures is resolved on
+// copies of them.
+func resolveLayoutChange(oldVol *PartiallyLaidOutVolume, newVol *LaidOutVolume, newGadgetRootDir, newKernelRootDir string, kernelInfo *kernel.Info) (*VolumeLayoutChange, error) {
+	change := &VolumeLayoutChange{Volume: newVol}
+	for j := range oldVol.LaidOutStructure {
+		from := &oldVol.LaidOutStructure[j]
+		to := &newVol.LaidOutStructure[j]
+		if from.Size == to.Size {
+			continue
+		}
+		change.Resized = append(change.Resized, ResizedStructure{From: from, To: to})
+	}
+	for j := len(oldVol.LaidOutStructure); j < len(newVol.LaidOutStructure); j++ {
+		// new structures are deployed together with their content
+		ps := newVol.LaidOutStructure[j]
+		resolvedContent, err := resolveVolumeContent(newGadgetRootDir, newKernelRootDir, kernelInfo, &ps, nil)
+		if err != nil {
+			return nil, err
+		}
+		ps.ResolvedContent = resolvedContent
+		change.Added = append(change.Added, &ps)
+	}
+	if len(change.Resized) == 0 && len(change.Added) == 0 {
+		return nil, nil
+	}
+	return change, nil
+}
+
 type Updater interface {
 	// Update applies the update or errors out on failures. When no actual
 	// update was applied because the new content is identical a special
@@ -425,21 +566,38 @@ type Updater interface {
 	Rollback() error
 }
 
-func applyUpdates(new GadgetData, updates []updatePair, rollbackDir string, observer ContentUpdateObserver) error {
-	updaters := make([]Updater, len(updates))
+func applyUpdates(new GadgetData, layoutChange *VolumeLayoutChange, updates []updatePair, rollbackDir string, observer ContentUpdateObserver) error {
+	var updaters []Updater
+	// what each of the updaters applies to, for error messages
+	var targets []string
+
+	if layoutChange != nil {
+		// the partitioning is updated first so that the content of
+		// grown structures fits
+		if NewLayoutUpdater == nil {
+			return fmt.Errorf("cannot update volume layout: not supported")
+		}
+		up, err := NewLayoutUpdater(layoutChange, new.RootDir, rollbackDir)
+		if err != nil {
+			return fmt.Errorf("cannot prepare update of volume layout: %v", err)
+		}
+		updaters = append(updaters, up)
+		targets = append(targets, "volume layout")
+	}
 
-	for i, one := range updates {
+	for _, one := range updates {
 		up, err := updaterForStructure(one.to, new.RootDir, rollbackDir, observer)
 		if err != nil {
 			return fmt.Errorf("cannot prepare update for volume structure %v: %v", one.to, err)
 		}
-		updaters[i] = up
+		updaters = append(updaters, up)
+		targets = append(targets, fmt.Sprintf("volume structure %v", one.to))
 	}
 
 	var backupErr error
 	for i, one := range updaters {
 		if err := one.Backup(); err != nil {
-			backupErr = fmt.Errorf("cannot backup volume structure %v: %v", updates[i].to, err)
+			backupErr = fmt.Errorf("cannot backup %s: %v", targets[i], err)
 			break
 		}
 	}
@@ -467,7 +625,7 @@ func applyUpdates(new GadgetData, updates []updatePair, rollbackDir string, obse
 				skipped++
 				continue
 			}
-			updateErr = fmt.Errorf("cannot update volume structure %v: %v", updates[i].to, err)
+			updateErr = fmt.Errorf("cannot update %s: %v", targets[i], err)
 			break
 		}
 	}
@@ -487,7 +645,7 @@ func applyUpdates(new GadgetData, updates []updatePair, rollbackDir string, obse
 		one := updaters[i]
 		if err := one.Rollback(); err != nil {
 			// TODO: log errors to oplog
-			logger.Noticef("cannot rollback volume structure %v update: %v", updates[i].to, err)
+			logger.Noticef("cannot rollback %s update: %v", targets[i], err)
 		}
 	}
 
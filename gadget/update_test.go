@@ -120,14 +120,68 @@ func (u *updateTestSuite) TestCanUpdateSize(c *C) {
 
 	cases := []canUpdateTestCase{
 		{
-			// size change
+			// size change of a structure without partition table entry
+			from: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Type: "bare", Size: 1 * quantity.SizeMiB},
+			},
+			to: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Type: "bare", Size: 1*quantity.SizeMiB + 1*quantity.SizeKiB},
+			},
+			err: "cannot change structure size from [0-9]+ to [0-9]+",
+		}, {
+			// size change of MBR
+			from: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Role: "mbr", Type: "mbr", Size: 440},
+			},
+			to: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Role: "mbr", Type: "mbr", Size: 446},
+			},
+			err: "cannot change structure size from 440 to 446",
+		}, {
+			// grow a raw partition
 			from: gadget.LaidOutStructure{
 				VolumeStructure: &gadget.VolumeStructure{Size: 1 * quantity.SizeMiB},
 			},
 			to: gadget.LaidOutStructure{
 				VolumeStructure: &gadget.VolumeStructure{Size: 1*quantity.SizeMiB + 1*quantity.SizeKiB},
 			},
-			err: "cannot change structure size from [0-9]+ to [0-9]+",
+			err: "",
+		}, {
+			// shrink a raw partition
+			from: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Size: 2 * quantity.SizeMiB},
+			},
+			to: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Size: 1 * quantity.SizeMiB},
+			},
+			err: "",
+		}, {
+			// grow an ext4 filesystem
+			from: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Size: 1 * quantity.SizeMiB, Filesystem: "ext4"},
+			},
+			to: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Size: 2 * quantity.SizeMiB, Filesystem: "ext4"},
+			},
+			err: "",
+		}, {
+			// shrink an ext4 filesystem
+			from: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Size: 2 * quantity.SizeMiB, Filesystem: "ext4"},
+			},
+			to: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Size: 1 * quantity.SizeMiB, Filesystem: "ext4"},
+			},
+			err: "cannot shrink filesystem structure from 2097152 to 1048576",
+		}, {
+			// grow a vfat filesystem
+			from: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Size: 1 * quantity.SizeMiB, Filesystem: "vfat"},
+			},
+			to: gadget.LaidOutStructure{
+				VolumeStructure: &gadget.VolumeStructure{Size: 2 * quantity.SizeMiB, Filesystem: "vfat"},
+			},
+			err: `cannot change size of "vfat" filesystem structure from 1048576 to 2097152`,
 		}, {
 			// size change
 			from: gadget.LaidOutStructure{
@@ -557,6 +611,52 @@ func (u *updateTestSuite) TestCanUpdateVolume(c *C) {
 				},
 			},
 			err: `cannot change the number of structures within volume from 2 to 1`,
+		}, {
+			from: gadget.PartiallyLaidOutVolume{
+				Volume: &gadget.Volume{},
+				LaidOutStructure: []gadget.LaidOutStructure{
+					{VolumeStructure: &gadget.VolumeStructure{Name: "foo"}},
+				},
+			},
+			to: gadget.LaidOutVolume{
+				Volume: &gadget.Volume{},
+				LaidOutStructure: []gadget.LaidOutStructure{
+					{VolumeStructure: &gadget.VolumeStructure{Name: "foo"}},
+					{VolumeStructure: &gadget.VolumeStructure{Name: "bar", Role: "system-save"}, Index: 1},
+				},
+			},
+			err: `cannot add volume structure #1 \("bar"\): cannot add a structure with role "system-save"`,
+		}, {
+			from: gadget.PartiallyLaidOutVolume{
+				Volume: &gadget.Volume{},
+				LaidOutStructure: []gadget.LaidOutStructure{
+					{VolumeStructure: &gadget.VolumeStructure{Name: "foo"}},
+				},
+			},
+			to: gadget.LaidOutVolume{
+				Volume: &gadget.Volume{},
+				LaidOutStructure: []gadget.LaidOutStructure{
+					{VolumeStructure: &gadget.VolumeStructure{Name: "foo"}},
+					{VolumeStructure: &gadget.VolumeStructure{Name: "bar", Type: "bare"}, Index: 1},
+				},
+			},
+			err: `cannot add volume structure #1 \("bar"\): cannot add a structure without a partition table entry`,
+		}, {
+			// valid, structure appended
+			from: gadget.PartiallyLaidOutVolume{
+				Volume: &gadget.Volume{},
+				LaidOutStructure: []gadget.LaidOutStructure{
+					{VolumeStructure: &gadget.VolumeStructure{Name: "foo"}},
+				},
+			},
+			to: gadget.LaidOutVolume{
+				Volume: &gadget.Volume{},
+				LaidOutStructure: []gadget.LaidOutStructure{
+					{VolumeStructure: &gadget.VolumeStructure{Name: "foo"}},
+					{VolumeStructure: &gadget.VolumeStructure{Name: "bar"}, Index: 1},
+				},
+			},
+			err: ``,
 		}, {
 			// valid
 			from: gadget.PartiallyLaidOutVolume{
@@ -882,7 +982,7 @@ func (u *updateTestSuite) TestUpdateApplyErrorIllegalVolumeUpdate(c *C) {
 			"foo": {
 				Bootloader: "grub",
 				Schema:     "gpt",
-				Structure:  []gadget.VolumeStructure{bareStruct},
+				Structure:  []gadget.VolumeStructure{bareStruct, bareStructUpdate},
 			},
 		},
 	}
@@ -891,8 +991,8 @@ func (u *updateTestSuite) TestUpdateApplyErrorIllegalVolumeUpdate(c *C) {
 			"foo": {
 				Bootloader: "grub",
 				Schema:     "gpt",
-				// more structures than old
-				Structure: []gadget.VolumeStructure{bareStruct, bareStructUpdate},
+				// fewer structures than old
+				Structure: []gadget.VolumeStructure{bareStruct},
 			},
 		},
 	}
@@ -909,7 +1009,160 @@ func (u *updateTestSuite) TestUpdateApplyErrorIllegalVolumeUpdate(c *C) {
 	makeSizedFile(c, filepath.Join(newRootDir, "first.img"), 900*quantity.SizeKiB, nil)
 
 	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
-	c.Assert(err, ErrorMatches, `cannot apply update to volume: cannot change the number of structures within volume from 1 to 2`)
+	c.Assert(err, ErrorMatches, `cannot apply update to volume: cannot change the number of structures within volume from 2 to 1`)
+}
+
+func (u *updateTestSuite) TestUpdateApplyLayoutChange(c *C) {
+	oldData, newData, rollbackDir := updateDataSet(c)
+	// make room for growing the second structure
+	offset := quantity.Offset(20 * quantity.SizeMiB)
+	oldData.Info.Volumes["foo"].Structure[2].Offset = &offset
+	newData.Info.Volumes["foo"].Structure[2].Offset = &offset
+	newData.Info.Volumes["foo"].Structure[1].Size = 14 * quantity.SizeMiB
+	// and append a new structure
+	newData.Info.Volumes["foo"].Structure = append(newData.Info.Volumes["foo"].Structure, gadget.VolumeStructure{
+		Name:       "fourth",
+		Size:       5 * quantity.SizeMiB,
+		Filesystem: "ext4",
+		Content: []gadget.VolumeContent{
+			{UnresolvedSource: "/fourth-content", Target: "/"},
+		},
+	})
+	makeSizedFile(c, filepath.Join(newData.RootDir, "/fourth-content/baz"), quantity.SizeKiB, nil)
+
+	var calls []string
+	restore := gadget.MockNewLayoutUpdater(func(change *gadget.VolumeLayoutChange, rootDir, psRollbackDir string) (gadget.Updater, error) {
+		c.Check(rootDir, Equals, newData.RootDir)
+		c.Check(psRollbackDir, Equals, rollbackDir)
+		c.Check(change.Volume.LaidOutStructure, HasLen, 4)
+		c.Assert(change.Resized, HasLen, 1)
+		c.Check(change.Resized[0].From.Name, Equals, "second")
+		c.Check(change.Resized[0].From.Size, Equals, 10*quantity.SizeMiB)
+		c.Check(change.Resized[0].To.Size, Equals, 14*quantity.SizeMiB)
+		c.Assert(change.Added, HasLen, 1)
+		c.Check(change.Added[0].Name, Equals, "fourth")
+		c.Check(change.Added[0].StartOffset, Equals, offset+quantity.Offset(5*quantity.SizeMiB))
+		// content of new structures is resolved
+		c.Check(change.Added[0].ResolvedContent, DeepEquals, []gadget.ResolvedContent{
+			{
+				VolumeContent:  &change.Added[0].Content[0],
+				ResolvedSource: filepath.Join(newData.RootDir, "/fourth-content"),
+			},
+		})
+		// on a copy, the new volume layout is left untouched
+		c.Check(change.Added[0], Not(Equals), &change.Volume.LaidOutStructure[3])
+		c.Check(change.Volume.LaidOutStructure[3].ResolvedContent, IsNil)
+		return &mockUpdater{
+			backupCb: func() error {
+				calls = append(calls, "layout-backup")
+				return nil
+			},
+			updateCb: func() error {
+				calls = append(calls, "layout-update")
+				return nil
+			},
+		}, nil
+	})
+	defer restore()
+	restore = gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, psRootDir, psRollbackDir string, observer gadget.ContentUpdateObserver) (gadget.Updater, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})
+	defer restore()
+
+	// no structure content update with the default policy, but the
+	// layout is updated
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(calls, DeepEquals, []string{"layout-backup", "layout-update"})
+}
+
+func (u *updateTestSuite) TestUpdateApplyLayoutChangeBeforeStructures(c *C) {
+	oldData, newData, rollbackDir := updateDataSet(c)
+	newData.Info.Volumes["foo"].Structure = append(newData.Info.Volumes["foo"].Structure, gadget.VolumeStructure{
+		Name: "fourth",
+		Size: 1 * quantity.SizeMiB,
+	})
+	newData.Info.Volumes["foo"].Structure[0].Update.Edition = 1
+
+	var calls []string
+	restore := gadget.MockNewLayoutUpdater(func(change *gadget.VolumeLayoutChange, rootDir, psRollbackDir string) (gadget.Updater, error) {
+		return &mockUpdater{
+			backupCb: func() error {
+				calls = append(calls, "layout-backup")
+				return nil
+			},
+			updateCb: func() error {
+				calls = append(calls, "layout-update")
+				return nil
+			},
+			rollbackCb: func() error {
+				calls = append(calls, "layout-rollback")
+				return nil
+			},
+		}, nil
+	})
+	defer restore()
+	restore = gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, psRootDir, psRollbackDir string, observer gadget.ContentUpdateObserver) (gadget.Updater, error) {
+		return &mockUpdater{
+			backupCb: func() error {
+				calls = append(calls, ps.Name+"-backup")
+				return nil
+			},
+			updateCb: func() error {
+				calls = append(calls, ps.Name+"-update")
+				return errors.New("failed")
+			},
+			rollbackCb: func() error {
+				calls = append(calls, ps.Name+"-rollback")
+				return nil
+			},
+		}, nil
+	})
+	defer restore()
+
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, ErrorMatches, `cannot update volume structure #0 \("first"\): failed`)
+	c.Check(calls, DeepEquals, []string{
+		"layout-backup", "first-backup",
+		"layout-update", "first-update",
+		"layout-rollback", "first-rollback",
+	})
+}
+
+func (u *updateTestSuite) TestUpdateApplyLayoutChangeErrors(c *C) {
+	oldData, newData, rollbackDir := updateDataSet(c)
+	// vfat cannot be resized
+	newData.Info.Volumes["foo"].Structure[2].Size = 6 * quantity.SizeMiB
+	err := gadget.Update(oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, ErrorMatches, `cannot update volume structure #2 \("third"\): cannot change size of "vfat" filesystem structure from 5242880 to 6291456`)
+
+	oldData, newData, rollbackDir = updateDataSet(c)
+	newData.Info.Volumes["foo"].Structure = append(newData.Info.Volumes["foo"].Structure, gadget.VolumeStructure{
+		Name: "fourth",
+		Size: 1 * quantity.SizeMiB,
+	})
+	// not supported without an implementation
+	restore := gadget.MockNewLayoutUpdater(nil)
+	defer restore()
+	err = gadget.Update(oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, ErrorMatches, `cannot update volume layout: not supported`)
+
+	restore = gadget.MockNewLayoutUpdater(func(change *gadget.VolumeLayoutChange, rootDir, psRollbackDir string) (gadget.Updater, error) {
+		return nil, errors.New("boom")
+	})
+	defer restore()
+	err = gadget.Update(oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, ErrorMatches, `cannot prepare update of volume layout: boom`)
+
+	restore = gadget.MockNewLayoutUpdater(func(change *gadget.VolumeLayoutChange, rootDir, psRollbackDir string) (gadget.Updater, error) {
+		return &mockUpdater{
+			backupCb: func() error { return errors.New("backup failed") },
+		}, nil
+	})
+	defer restore()
+	err = gadget.Update(oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, ErrorMatches, `cannot backup volume layout: backup failed`)
 }
 
 func (u *updateTestSuite) TestUpdateApplyErrorIllegalStructureUpdate(c *C) {
@@ -1162,6 +1415,36 @@ func (u *updateTestSuite) TestUpdateApplyUpdatesRemodelPolicy(c *C) {
 	})
 }
 
+func (u *updateTestSuite) TestCheckUpdate(c *C) {
+	oldData, newData, _ := policyDataSet(c)
+
+	restore := gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, psRootDir, psRollbackDir string, observer gadget.ContentUpdateObserver) (gadget.Updater, error) {
+		c.Fatalf("unexpected call")
+		return nil, errors.New("not called")
+	})
+	defer restore()
+
+	// old structures have higher Edition, nothing to update with the
+	// default policy
+	oldData.Info.Volumes["foo"].Structure[0].Update.Edition = 1
+	oldData.Info.Volumes["foo"].Structure[1].Update.Edition = 1
+	oldData.Info.Volumes["foo"].Structure[2].Update.Edition = 3
+	oldData.Info.Volumes["foo"].Structure[3].Update.Edition = 4
+	oldData.Info.Volumes["foo"].Structure[4].Update.Edition = 5
+
+	err := gadget.CheckUpdate(oldData, newData, nil)
+	c.Assert(err, Equals, gadget.ErrNoUpdate)
+
+	// but the remodel policy updates them
+	err = gadget.CheckUpdate(oldData, newData, gadget.RemodelUpdatePolicy)
+	c.Assert(err, IsNil)
+
+	// incompatible volumes are reported
+	newData.Info.Volumes["foo"].Structure = newData.Info.Volumes["foo"].Structure[1:]
+	err = gadget.CheckUpdate(oldData, newData, gadget.RemodelUpdatePolicy)
+	c.Assert(err, ErrorMatches, `cannot apply update to volume: cannot change the number of structures within volume from 5 to 4`)
+}
+
 func (u *updateTestSuite) TestUpdateApplyBackupFails(c *C) {
 	oldData, newData, rollbackDir := updateDataSet(c)
 	// update both structs
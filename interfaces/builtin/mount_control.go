@@ -0,0 +1,350 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+const mountControlSummary = `allows creating transient and persistent mounts`
+
+const mountControlBaseDeclarationPlugs = `
+  mount-control:
+    allow-installation: false
+    deny-auto-connection: true
+`
+
+const mountControlBaseDeclarationSlots = `
+  mount-control:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+// mountControlFlags are the options accepted in the "options" attribute which
+// are generic mount flags. Any other option must be of the form key=value and
+// is passed on to the filesystem.
+var mountControlFlags = []string{
+	"async",
+	"atime",
+	"bind",
+	"diratime",
+	"dirsync",
+	"exec",
+	"lazytime",
+	"noatime",
+	"nodev",
+	"nodiratime",
+	"noexec",
+	"nolazytime",
+	"norelatime",
+	"nosuid",
+	"nostrictatime",
+	"rbind",
+	"relatime",
+	"ro",
+	"rw",
+	"strictatime",
+	"sync",
+}
+
+// MountControlOrigin marks the mount units created via the mount-control
+// interface.
+const MountControlOrigin = "mount-control"
+
+// mountControlDefaultOptions are always used for mounts created via the
+// mount-control interface.
+var mountControlDefaultOptions = []string{"nodev", "nosuid"}
+
+var (
+	mountControlTypeRegexp   = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+	mountControlOptionRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*=[^,"\s]*$`)
+)
+
+// MountControlEntry describes one of the mounts a snap with a connected
+// mount-control plug is allowed to create.
+type MountControlEntry struct {
+	// What is the source of the mount, it may contain "*" wildcards.
+	What string
+	// Where is the mount point, it may start with $SNAP_COMMON or
+	// $SNAP_DATA and may contain "*" wildcards.
+	Where string
+	// Types lists the allowed filesystem types, if empty the type must
+	// not be specified.
+	Types []string
+	// Options lists the allowed mount options.
+	Options []string
+	// Persistent indicates whether the mount may persist across reboots.
+	Persistent bool
+}
+
+// MountControlEntries returns the mounts allowed by the given mount-control
+// plug, as declared by its "mount" attribute.
+func MountControlEntries(plug interfaces.Attrer) ([]*MountControlEntry, error) {
+	var rawEntries []interface{}
+	if err := plug.Attr("mount", &rawEntries); err != nil || len(rawEntries) == 0 {
+		return nil, fmt.Errorf(`"mount" attribute must be a non-empty list of mount entries`)
+	}
+
+	entries := make([]*MountControlEntry, 0, len(rawEntries))
+	for _, rawEntry := range rawEntries {
+		attrs, ok := rawEntry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf(`mount entry must be a map, found %T`, rawEntry)
+		}
+		entry, err := parseMountControlEntry(attrs)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseMountControlEntry(attrs map[string]interface{}) (*MountControlEntry, error) {
+	for key := range attrs {
+		switch key {
+		case "what", "where", "type", "options", "persistent":
+		default:
+			return nil, fmt.Errorf("unknown mount entry attribute %q", key)
+		}
+	}
+
+	var entry MountControlEntry
+	var ok bool
+	if entry.What, ok = attrs["what"].(string); !ok || entry.What == "" {
+		return nil, fmt.Errorf(`mount entry must have a "what" string attribute`)
+	}
+	if err := validateMountControlPattern(entry.What); err != nil {
+		return nil, err
+	}
+
+	if entry.Where, ok = attrs["where"].(string); !ok || entry.Where == "" {
+		return nil, fmt.Errorf(`mount entry must have a "where" string attribute`)
+	}
+	if err := validateMountControlWhere(entry.Where); err != nil {
+		return nil, err
+	}
+
+	types, err := mountControlStringList(attrs, "type")
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range types {
+		if !mountControlTypeRegexp.MatchString(t) {
+			return nil, fmt.Errorf("invalid mount type %q", t)
+		}
+	}
+	entry.Types = types
+
+	options, err := mountControlStringList(attrs, "options")
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range options {
+		if err := validateMountControlOption(o); err != nil {
+			return nil, err
+		}
+	}
+	entry.Options = options
+
+	if persistent, ok := attrs["persistent"]; ok {
+		if entry.Persistent, ok = persistent.(bool); !ok {
+			return nil, fmt.Errorf(`mount entry "persistent" attribute must be a boolean`)
+		}
+	}
+	if entry.Persistent && strings.HasPrefix(entry.Where, "$SNAP_DATA") {
+		return nil, fmt.Errorf("persistent mounts cannot use $SNAP_DATA, use $SNAP_COMMON instead: %q", entry.Where)
+	}
+
+	return &entry, nil
+}
+
+func mountControlStringList(attrs map[string]interface{}, key string) ([]string, error) {
+	raw, ok := attrs[key]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("mount entry %q attribute must be a list of strings", key)
+	}
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("mount entry %q attribute must be a list of strings", key)
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+// validateMountControlPattern checks that the given pattern does not use any
+// AppArmor regular expression other than the "*" wildcard.
+func validateMountControlPattern(pattern string) error {
+	if err := apparmor.ValidateNoAppArmorRegexp(strings.Replace(pattern, "*", "", -1)); err != nil {
+		return err
+	}
+	if strings.Contains(pattern, "**") {
+		return fmt.Errorf(`%q cannot contain "**"`, pattern)
+	}
+	if strings.ContainsAny(pattern, " \t\n,") {
+		return fmt.Errorf("%q cannot contain whitespace or commas", pattern)
+	}
+	return nil
+}
+
+func validateMountControlWhere(where string) error {
+	if err := validateMountControlPattern(where); err != nil {
+		return err
+	}
+	p := where
+	for _, variable := range []string{"$SNAP_COMMON", "$SNAP_DATA"} {
+		if strings.HasPrefix(where, variable) {
+			p = strings.TrimPrefix(where, variable)
+			if p != "" && !strings.HasPrefix(p, "/") {
+				return fmt.Errorf("%q must be a path below %s", where, variable)
+			}
+			break
+		}
+	}
+	if strings.Contains(p, "$") {
+		return fmt.Errorf("%q can only start with $SNAP_COMMON or $SNAP_DATA", where)
+	}
+	if p == where && !strings.HasPrefix(p, "/") {
+		return fmt.Errorf(`%q must start with "/", $SNAP_COMMON or $SNAP_DATA`, where)
+	}
+	if p != "" && filepath.Clean(p) != p {
+		return fmt.Errorf("cannot use %q: path is not clean", where)
+	}
+	return nil
+}
+
+func validateMountControlOption(option string) error {
+	if strutil.ListContains(mountControlFlags, option) {
+		return nil
+	}
+	switch option {
+	case "dev", "suid":
+		return fmt.Errorf("mount option %q is not allowed", option)
+	}
+	if !mountControlOptionRegexp.MatchString(option) {
+		return fmt.Errorf("invalid mount option %q", option)
+	}
+	if strings.HasPrefix(option, "context=") || strings.HasPrefix(option, "fscontext=") || strings.HasPrefix(option, "defcontext=") || strings.HasPrefix(option, "rootcontext=") {
+		return fmt.Errorf("mount option %q is not allowed", option)
+	}
+	return nil
+}
+
+// ExpandMountControlWhere expands the $SNAP_COMMON and $SNAP_DATA variables
+// of the given mount point pattern for the given snap.
+func ExpandMountControlWhere(where string, info *snap.Info) string {
+	switch {
+	case strings.HasPrefix(where, "$SNAP_COMMON"):
+		return info.CommonDataDir() + strings.TrimPrefix(where, "$SNAP_COMMON")
+	case strings.HasPrefix(where, "$SNAP_DATA"):
+		return info.DataDir() + strings.TrimPrefix(where, "$SNAP_DATA")
+	}
+	return where
+}
+
+// Allows returns whether the entry allows the given snap to mount what at
+// where, with the given filesystem type and options.
+func (e *MountControlEntry) Allows(info *snap.Info, what, where, fstype string, options []string, persistent bool) bool {
+	if persistent && !e.Persistent {
+		return false
+	}
+	if ok, err := filepath.Match(e.What, what); err != nil || !ok {
+		return false
+	}
+	if !e.AllowsWhere(info, where) {
+		return false
+	}
+	if fstype == "" {
+		if len(e.Types) != 0 {
+			return false
+		}
+	} else if !strutil.ListContains(e.Types, fstype) {
+		return false
+	}
+	for _, option := range options {
+		if !strutil.ListContains(e.Options, option) && !strutil.ListContains(mountControlDefaultOptions, option) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowsWhere returns whether the entry allows the given snap to mount
+// something at where.
+func (e *MountControlEntry) AllowsWhere(info *snap.Info, where string) bool {
+	ok, err := filepath.Match(ExpandMountControlWhere(e.Where, info), where)
+	return err == nil && ok
+}
+
+// MountControlOptions returns the options used for a mount created via the
+// mount-control interface with the given requested options.
+func MountControlOptions(options []string) []string {
+	all := append([]string(nil), mountControlDefaultOptions...)
+	for _, option := range options {
+		if !strutil.ListContains(all, option) {
+			all = append(all, option)
+		}
+	}
+	return all
+}
+
+// mountControlInterface grants no direct access to the mount syscalls, the
+// mounts are created by snapd as systemd mount units on behalf of the snap via
+// "snapctl mount".
+type mountControlInterface struct {
+	commonInterface
+}
+
+func (iface *mountControlInterface) BeforePreparePlug(plug *snap.PlugInfo) error {
+	if _, err := MountControlEntries(plug); err != nil {
+		return fmt.Errorf("cannot add mount-control plug: %v", err)
+	}
+	return nil
+}
+
+func init() {
+	registerIface(&mountControlInterface{
+		commonInterface{
+			name:                 "mount-control",
+			summary:              mountControlSummary,
+			implicitOnCore:       true,
+			implicitOnClassic:    true,
+			baseDeclarationPlugs: mountControlBaseDeclarationPlugs,
+			baseDeclarationSlots: mountControlBaseDeclarationSlots,
+		},
+	})
+}
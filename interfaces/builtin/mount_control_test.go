@@ -0,0 +1,194 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type MountControlInterfaceSuite struct {
+	iface    interfaces.Interface
+	slotInfo *snap.SlotInfo
+	slot     *interfaces.ConnectedSlot
+	plugInfo *snap.PlugInfo
+	plug     *interfaces.ConnectedPlug
+}
+
+const mountControlConsumerYaml = `name: consumer
+version: 0
+plugs:
+  mntctl:
+    interface: mount-control
+    mount:
+      - what: /dev/sd*
+        where: /media/*
+        type: [ext4, vfat]
+        options: [rw, sync]
+        persistent: true
+      - what: "server:/export"
+        where: $SNAP_COMMON/nfs
+        type: [nfs]
+        options: [ro, vers=4]
+apps:
+  app:
+    plugs: [mntctl]
+`
+
+const mountControlCoreYaml = `name: core
+version: 0
+type: os
+slots:
+  mount-control:
+`
+
+var _ = Suite(&MountControlInterfaceSuite{
+	iface: builtin.MustInterface("mount-control"),
+})
+
+func (s *MountControlInterfaceSuite) SetUpTest(c *C) {
+	s.plug, s.plugInfo = MockConnectedPlug(c, mountControlConsumerYaml, nil, "mntctl")
+	s.slot, s.slotInfo = MockConnectedSlot(c, mountControlCoreYaml, nil, "mount-control")
+}
+
+func (s *MountControlInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "mount-control")
+}
+
+func (s *MountControlInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
+}
+
+func (s *MountControlInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(interfaces.BeforePreparePlug(s.iface, s.plugInfo), IsNil)
+}
+
+func (s *MountControlInterfaceSuite) TestSanitizePlugUnhappy(c *C) {
+	const mockSnapYaml = `name: consumer
+version: 1.0
+plugs:
+  mount-control:
+    $t
+`
+	errPrefix := `cannot add mount-control plug: `
+	var testCases = []struct {
+		inp    string
+		errStr string
+	}{
+		{`foo: bar`, `"mount" attribute must be a non-empty list of mount entries`},
+		{`mount: []`, `"mount" attribute must be a non-empty list of mount entries`},
+		{`mount: [ foo ]`, `mount entry must be a map, found string`},
+		{`mount: [ {where: /media} ]`, `mount entry must have a "what" string attribute`},
+		{`mount: [ {what: /dev/sda} ]`, `mount entry must have a "where" string attribute`},
+		{`mount: [ {what: /dev/sda, where: /media, foo: bar} ]`, `unknown mount entry attribute "foo"`},
+		{`mount: [ {what: "/dev/sd[ab]", where: /media} ]`, `"/dev/sd\[ab\]" contains a reserved apparmor char from .*`},
+		{`mount: [ {what: /dev/**, where: /media} ]`, `"/dev/\*\*" cannot contain "\*\*"`},
+		{`mount: [ {what: "/dev/sda,rw", where: /media} ]`, `"/dev/sda,rw" cannot contain whitespace or commas`},
+		{`mount: [ {what: /dev/sda, where: media} ]`, `"media" must start with "/", \$SNAP_COMMON or \$SNAP_DATA`},
+		{`mount: [ {what: /dev/sda, where: $SNAP_COMMONfoo} ]`, `"\$SNAP_COMMONfoo" must be a path below \$SNAP_COMMON`},
+		{`mount: [ {what: /dev/sda, where: /media/$SNAP} ]`, `"/media/\$SNAP" can only start with \$SNAP_COMMON or \$SNAP_DATA`},
+		{`mount: [ {what: /dev/sda, where: /media/../etc} ]`, `cannot use "/media/../etc": path is not clean`},
+		{`mount: [ {what: /dev/sda, where: /media, type: ext4} ]`, `mount entry "type" attribute must be a list of strings`},
+		{`mount: [ {what: /dev/sda, where: /media, type: [Ext4]} ]`, `invalid mount type "Ext4"`},
+		{`mount: [ {what: /dev/sda, where: /media, options: [suid]} ]`, `mount option "suid" is not allowed`},
+		{`mount: [ {what: /dev/sda, where: /media, options: [foo]} ]`, `invalid mount option "foo"`},
+		{`mount: [ {what: /dev/sda, where: /media, options: [context=foo]} ]`, `mount option "context=foo" is not allowed`},
+		{`mount: [ {what: /dev/sda, where: /media, persistent: yes-please} ]`, `mount entry "persistent" attribute must be a boolean`},
+		{`mount: [ {what: /dev/sda, where: $SNAP_DATA/media, persistent: true} ]`, `persistent mounts cannot use \$SNAP_DATA, use \$SNAP_COMMON instead: "\$SNAP_DATA/media"`},
+	}
+
+	for _, t := range testCases {
+		yml := strings.Replace(mockSnapYaml, "$t", t.inp, -1)
+		info := snaptest.MockInfo(c, yml, nil)
+		plug := info.Plugs["mount-control"]
+
+		c.Check(interfaces.BeforePreparePlug(s.iface, plug), ErrorMatches, errPrefix+t.errStr, Commentf("unexpected error for %q", t.inp))
+	}
+}
+
+func (s *MountControlInterfaceSuite) TestAppArmorSpec(c *C) {
+	// mounts are created by snapd via systemd, the snap needs no extra rules
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.SecurityTags(), HasLen, 0)
+}
+
+func (s *MountControlInterfaceSuite) TestSecCompSpec(c *C) {
+	spec := &seccomp.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.SecurityTags(), HasLen, 0)
+}
+
+func (s *MountControlInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows creating transient and persistent mounts`)
+	c.Assert(si.BaseDeclarationPlugs, testutil.Contains, "mount-control")
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "mount-control")
+}
+
+func (s *MountControlInterfaceSuite) TestEntryAllows(c *C) {
+	entries, err := builtin.MountControlEntries(s.plugInfo)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	info := s.plugInfo.Snap
+
+	for _, t := range []struct {
+		entry               int
+		what, where, fstype string
+		options             []string
+		persistent, allowed bool
+	}{
+		{0, "/dev/sdb1", "/media/usb", "ext4", []string{"rw"}, true, true},
+		{0, "/dev/sdb1", "/media/usb", "vfat", []string{"sync", "nodev", "nosuid"}, false, true},
+		{0, "/dev/sdb1", "/media/usb", "", nil, false, false},
+		{0, "/dev/sdb1", "/media/usb", "btrfs", nil, false, false},
+		{0, "/dev/sdb1", "/media/usb", "ext4", []string{"exec"}, false, false},
+		{0, "/dev/sdb1", "/media/usb/sub", "ext4", nil, false, false},
+		{0, "/dev/vda1", "/media/usb", "ext4", nil, false, false},
+		{1, "server:/export", info.CommonDataDir() + "/nfs", "nfs", []string{"ro", "vers=4"}, false, true},
+		{1, "server:/export", info.CommonDataDir() + "/nfs", "nfs", nil, true, false},
+		{1, "server:/export", "/var/snap/other/common/nfs", "nfs", nil, false, false},
+		{1, "server:/export", info.CommonDataDir() + "/nfs", "nfs", []string{"vers=3"}, false, false},
+	} {
+		comment := Commentf("%+v", t)
+		allowed := entries[t.entry].Allows(info, t.what, t.where, t.fstype, t.options, t.persistent)
+		c.Check(allowed, Equals, t.allowed, comment)
+	}
+}
+
+func (s *MountControlInterfaceSuite) TestMountControlOptions(c *C) {
+	c.Check(builtin.MountControlOptions(nil), DeepEquals, []string{"nodev", "nosuid"})
+	c.Check(builtin.MountControlOptions([]string{"ro", "nodev", "vers=4"}), DeepEquals, []string{"nodev", "nosuid", "ro", "vers=4"})
+}
+
+func (s *MountControlInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
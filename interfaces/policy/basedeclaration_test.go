@@ -715,6 +715,7 @@ func (s *baseDeclSuite) TestPlugInstallation(c *C) {
 		"kernel-module-control": true,
 		"kubernetes-support":    true,
 		"lxd-support":           true,
+		"mount-control":         true,
 		"multipass-support":     true,
 		"packagekit-control":    true,
 		"personal-files":        true,
@@ -951,6 +952,7 @@ func (s *baseDeclSuite) TestSanity(c *C) {
 		"kernel-module-control": true,
 		"kubernetes-support":    true,
 		"lxd-support":           true,
+		"mount-control":         true,
 		"multipass-support":     true,
 		"packagekit-control":    true,
 		"personal-files":        true,
@@ -55,6 +55,10 @@ type AddUserOptions struct {
 // allows as valid usernames
 var IsValidUsername = regexp.MustCompile(`^[a-z0-9][-a-z0-9+._]*$`).MatchString
 
+// NonexistentHomeDir is the home directory of the system users created by
+// EnsureUserGroup, it never exists.
+const NonexistentHomeDir = "/nonexistent"
+
 // EnsureUserGroup uses the standard shadow utilities' 'useradd' and 'groupadd'
 // commands for creating non-login system users and groups that is portable
 // cross-distro. It will create the group with groupname 'name' and gid 'id' as
@@ -130,7 +134,7 @@ func EnsureUserGroup(name string, id uint32, extraUsers bool) error {
 	userCmdStr := []string{
 		"useradd",
 		"--system",
-		"--home-dir", "/nonexistent", "--no-create-home",
+		"--home-dir", NonexistentHomeDir, "--no-create-home",
 		"--shell", LookPathDefault("false", "/bin/false"),
 		"--gid", strconv.FormatUint(uint64(id), 10), "--no-user-group",
 		"--uid", strconv.FormatUint(uint64(id), 10),
@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/asserts/sysdb"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
 )
 
 // AssertManager is responsible for the enforcement of assertions in
@@ -42,6 +43,7 @@ func Manager(s *state.State, runner *state.TaskRunner) (*AssertManager, error) {
 	delayedCrossMgrInit()
 
 	runner.AddHandler("validate-snap", doValidateSnap, nil)
+	runner.AddHandler("enforce-validation-set", doEnforceValidationSet, nil)
 
 	db, err := sysdb.Open()
 	if err != nil {
@@ -145,3 +147,41 @@ func doValidateSnap(t *state.Task, _ *tomb.Tomb) error {
 	// TODO: set DeveloperID from assertions
 	return nil
 }
+
+// doEnforceValidationSet switches a validation set to enforce mode, after
+// checking that the installed snaps conform to it.
+func doEnforceValidationSet(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var tr ValidationSetTracking
+	if err := t.Get("validation-set-tracking", &tr); err != nil {
+		return fmt.Errorf("internal error: cannot obtain validation set tracking: %v", err)
+	}
+
+	as, err := DB(st).Find(asserts.ValidationSetType, map[string]string{
+		"series":     release.Series,
+		"account-id": tr.AccountID,
+		"name":       tr.Name,
+		"sequence":   fmt.Sprintf("%d", tr.Current),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot find validation set %s: %v", ValidationSetKey(tr.AccountID, tr.Name), err)
+	}
+
+	sets, err := enforcedValidationSets(st, as.(*asserts.ValidationSet))
+	if err != nil {
+		return err
+	}
+	snaps, err := installedSnaps(st)
+	if err != nil {
+		return err
+	}
+	if err := sets.CheckInstalledSnaps(snaps); err != nil {
+		return fmt.Errorf("cannot enforce validation set %s: %v", ValidationSetKey(tr.AccountID, tr.Name), err)
+	}
+
+	UpdateValidationSet(st, &tr)
+	return nil
+}
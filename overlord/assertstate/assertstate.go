@@ -23,12 +23,14 @@
 package assertstate
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -290,6 +292,27 @@ func Store(s *state.State, store string) (*asserts.Store, error) {
 	return a.(*asserts.Store), nil
 }
 
+func refreshPolicyRef(modelAs *asserts.Model) *asserts.Ref {
+	return &asserts.Ref{
+		Type:       asserts.RefreshPolicyType,
+		PrimaryKey: []string{modelAs.Series(), modelAs.BrandID(), modelAs.Model()},
+	}
+}
+
+// RefreshPolicy returns the refresh-policy assertion for the model of
+// the device if it is present in the system assertion database.
+func RefreshPolicy(s *state.State) (*asserts.RefreshPolicy, error) {
+	deviceCtx, err := snapstate.DeviceCtx(s, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	a, err := refreshPolicyRef(deviceCtx.Model()).Resolve(DB(s).Find)
+	if err != nil {
+		return nil, err
+	}
+	return a.(*asserts.RefreshPolicy), nil
+}
+
 // AutoAliases returns the explicit automatic aliases alias=>app mapping for the given installed snap.
 func AutoAliases(s *state.State, info *snap.Info) (map[string]string, error) {
 	if info.SnapID == "" {
@@ -323,6 +346,16 @@ func AutoAliases(s *state.State, info *snap.Info) (map[string]string, error) {
 	return res, nil
 }
 
+// AllowedRunAs returns the system usernames the snap declaration of the
+// given snap-id allows its services to run as.
+func AllowedRunAs(st *state.State, snapID string) ([]string, error) {
+	decl, err := SnapDeclaration(st, snapID)
+	if err != nil {
+		return nil, err
+	}
+	return decl.RunAs(), nil
+}
+
 func delayedCrossMgrInit() {
 	// hook validation of refreshes into snapstate logic
 	snapstate.ValidateRefreshes = ValidateRefreshes
@@ -330,8 +363,12 @@ func delayedCrossMgrInit() {
 	snapstate.AutoRefreshAssertions = AutoRefreshAssertions
 	// hook retrieving auto-aliases into snapstate logic
 	snapstate.AutoAliases = AutoAliases
+	// hook retrieving the allowed run-as system usernames into snapstate
+	snapstate.AllowedRunAs = AllowedRunAs
 	// hook the helper for getting enforced validation sets
 	snapstate.EnforcedValidationSets = EnforcedValidationSets
+	// hook the helper for getting the refresh-policy of the device
+	snapstate.RefreshPolicy = RefreshPolicy
 }
 
 // AutoRefreshAssertions tries to refresh all assertions
@@ -339,7 +376,21 @@ func AutoRefreshAssertions(s *state.State, userID int) error {
 	if err := RefreshSnapDeclarations(s, userID); err != nil {
 		return err
 	}
-	return RefreshValidationSetAssertions(s, userID)
+	if err := RefreshValidationSetAssertions(s, userID); err != nil {
+		return err
+	}
+	return RefreshRefreshPolicyAssertion(s, userID)
+}
+
+// RefreshRefreshPolicyAssertion tries to refresh the refresh-policy
+// assertion for the model of the device, fetching it if the brand
+// issued one since the last time.
+func RefreshRefreshPolicyAssertion(s *state.State, userID int) error {
+	deviceCtx, err := snapstate.DevicePastSeeding(s, nil)
+	if err != nil {
+		return err
+	}
+	return bulkRefreshRefreshPolicy(s, userID, deviceCtx)
 }
 
 // RefreshValidationSetAssertions tries to refresh all validation set
@@ -538,6 +589,75 @@ func ValidationSetAssertionForMonitor(st *state.State, accountID, name string, s
 	return as, false, err
 }
 
+// ValidationSetAssertionForEnforce tries to fetch or refresh the validation
+// set assertion with accountID/name/sequence (sequence is optional) and checks
+// that it can be enforced, i.e. that it is not in conflict with the validation
+// sets already in enforce mode and that the installed snaps conform to it.
+// It returns a *snapasserts.ValidationSetsValidationError alongside the
+// assertion if the installed snaps do not conform.
+func ValidationSetAssertionForEnforce(st *state.State, accountID, name string, sequence int, userID int) (as *asserts.ValidationSet, local bool, err error) {
+	as, local, _, err = validationSetAssertionForEnforce(st, accountID, name, sequence, userID)
+	return as, local, err
+}
+
+func validationSetAssertionForEnforce(st *state.State, accountID, name string, sequence int, userID int) (as *asserts.ValidationSet, local bool, sets *snapasserts.ValidationSets, err error) {
+	pinned := sequence > 0
+	opts := ResolveOptions{AllowLocalFallback: true}
+	as, local, err = ValidationSetAssertionForMonitor(st, accountID, name, sequence, pinned, userID, &opts)
+	if err != nil {
+		return nil, false, nil, err
+	}
+
+	sets, err = enforcedValidationSets(st, as)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	if err := sets.Conflict(); err != nil {
+		return nil, false, nil, err
+	}
+
+	snaps, err := installedSnaps(st)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	return as, local, sets, sets.CheckInstalledSnaps(snaps)
+}
+
+// EnforceValidationSet returns the task sets to install, refresh and remove
+// snaps as required for the validation set with accountID/name/sequence
+// (sequence is optional) to be enforced, together with a final task switching
+// the validation set to enforce mode once the installed snaps conform to it.
+// It also returns the names of the affected snaps.
+func EnforceValidationSet(ctx context.Context, st *state.State, accountID, name string, sequence, userID int) ([]*state.TaskSet, []string, error) {
+	as, local, sets, err := validationSetAssertionForEnforce(st, accountID, name, sequence, userID)
+	var tss []*state.TaskSet
+	var affected []string
+	if verr, ok := err.(*snapasserts.ValidationSetsValidationError); ok {
+		tss, affected, err = snapstate.EnforceSnaps(ctx, st, sets, verr, userID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tr := ValidationSetTracking{
+		AccountID: accountID,
+		Name:      name,
+		Mode:      Enforce,
+		// note, PinnedAt may be 0, meaning not pinned.
+		PinnedAt:  sequence,
+		Current:   as.Sequence(),
+		LocalOnly: local,
+	}
+
+	enforce := st.NewTask("enforce-validation-set", fmt.Sprintf(i18n.G("Enforce validation set %s"), ValidationSetKey(accountID, name)))
+	enforce.Set("validation-set-tracking", &tr)
+	for _, ts := range tss {
+		enforce.WaitAll(ts)
+	}
+
+	return append(tss, state.NewTaskSet(enforce)), affected, nil
+}
+
 // TemporaryDB returns a temporary database stacked on top of the assertions
 // database. Writing to it will not affect the assertions database.
 func TemporaryDB(st *state.State) *asserts.Database {
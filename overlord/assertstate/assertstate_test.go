@@ -37,6 +37,7 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/asserts/sysdb"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/httputil"
@@ -2026,6 +2027,40 @@ apps:
 	})
 }
 
+func (s *assertMgrSuite) TestAllowedRunAs(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// prereqs for developer assertions in the system db
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+
+	// missing
+	_, err = assertstate.AllowedRunAs(s.state, "foo-id")
+	c.Check(err, ErrorMatches, `snap-declaration \(foo-id; series:16\) not found`)
+
+	// no run-as
+	snapDeclFoo := s.snapDecl(c, "foo", nil)
+	err = assertstate.Add(s.state, snapDeclFoo)
+	c.Assert(err, IsNil)
+	runAs, err := assertstate.AllowedRunAs(s.state, "foo-id")
+	c.Assert(err, IsNil)
+	c.Check(runAs, HasLen, 0)
+
+	// some run-as
+	snapDeclFoo = s.snapDecl(c, "foo", map[string]interface{}{
+		"run-as":   []interface{}{"snap_daemon"},
+		"revision": "1",
+	})
+	err = assertstate.Add(s.state, snapDeclFoo)
+	c.Assert(err, IsNil)
+	runAs, err = assertstate.AllowedRunAs(s.state, "foo-id")
+	c.Assert(err, IsNil)
+	c.Check(runAs, DeepEquals, []string{"snap_daemon"})
+}
+
 func (s *assertMgrSuite) TestAutoAliasesExplicit(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -2141,6 +2176,108 @@ func (s *assertMgrSuite) TestStore(c *C) {
 	c.Check(store.Store(), Equals, "foo")
 }
 
+func (s *assertMgrSuite) setupModelAndRefreshPolicy(c *C, revision string) *asserts.RefreshPolicy {
+	// a model by developer1 acting as brand
+	a := assertstest.FakeAssertion(map[string]interface{}{
+		"type":         "model",
+		"authority-id": s.dev1Acct.AccountID(),
+		"series":       "16",
+		"brand-id":     s.dev1Acct.AccountID(),
+		"model":        "my-model",
+		"architecture": "amd64",
+		"gadget":       "gadget",
+		"kernel":       "krnl",
+	})
+	s.setModel(a.(*asserts.Model))
+
+	a, err := s.dev1Signing.Sign(asserts.RefreshPolicyType, map[string]interface{}{
+		"series":   "16",
+		"brand-id": s.dev1Acct.AccountID(),
+		"model":    "my-model",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":           "foo",
+				"id":             "fooididididididididididididididi",
+				"channel":        "2.0/stable",
+				"refresh-window": "02:00-04:00",
+			},
+		},
+		"revision":  revision,
+		"timestamp": time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	return a.(*asserts.RefreshPolicy)
+}
+
+func (s *assertMgrSuite) TestRefreshPolicy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	policy := s.setupModelAndRefreshPolicy(c, "0")
+
+	_, err := assertstate.RefreshPolicy(s.state)
+	c.Check(asserts.IsNotFound(err), Equals, true)
+
+	c.Assert(assertstate.Add(s.state, s.storeSigning.StoreAccountKey("")), IsNil)
+	c.Assert(assertstate.Add(s.state, s.dev1Acct), IsNil)
+	c.Assert(assertstate.Add(s.state, s.dev1AcctKey), IsNil)
+	c.Assert(assertstate.Add(s.state, policy), IsNil)
+
+	found, err := assertstate.RefreshPolicy(s.state)
+	c.Assert(err, IsNil)
+	c.Check(found.Model(), Equals, "my-model")
+	c.Check(found.Snap("foo").Channel, Equals, "2.0/stable")
+}
+
+func (s *assertMgrSuite) TestRefreshRefreshPolicyAssertion(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	policy := s.setupModelAndRefreshPolicy(c, "0")
+	c.Assert(s.storeSigning.Add(policy), IsNil)
+
+	// the policy gets fetched
+	err := assertstate.RefreshRefreshPolicyAssertion(s.state, 0)
+	c.Assert(err, IsNil)
+	a, err := assertstate.RefreshPolicy(s.state)
+	c.Assert(err, IsNil)
+	c.Check(a.Revision(), Equals, 0)
+
+	// and then refreshed
+	policy = s.setupModelAndRefreshPolicy(c, "1")
+	c.Assert(s.storeSigning.Add(policy), IsNil)
+
+	err = assertstate.RefreshRefreshPolicyAssertion(s.state, 0)
+	c.Assert(err, IsNil)
+	a, err = assertstate.RefreshPolicy(s.state)
+	c.Assert(err, IsNil)
+	c.Check(a.Revision(), Equals, 1)
+}
+
+func (s *assertMgrSuite) TestRefreshRefreshPolicyAssertionNotFound(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the brand issued no refresh-policy
+	s.setModel(sysdb.GenericClassicModel())
+
+	err := assertstate.RefreshRefreshPolicyAssertion(s.state, 0)
+	c.Assert(err, IsNil)
+	_, err = assertstate.RefreshPolicy(s.state)
+	c.Check(asserts.IsNotFound(err), Equals, true)
+}
+
+func (s *assertMgrSuite) TestRefreshRefreshPolicyAssertionStoreError(c *C) {
+	s.fakeStore.(*fakeStore).snapActionErr = &store.UnexpectedHTTPStatusError{StatusCode: 400}
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setModel(sysdb.GenericClassicModel())
+
+	err := assertstate.RefreshRefreshPolicyAssertion(s.state, 0)
+	c.Assert(err, ErrorMatches, `cannot refresh refresh-policy assertion: cannot : got unexpected HTTP status code 400.*`)
+}
+
 // validation-sets related tests
 
 func (s *assertMgrSuite) TestRefreshValidationSetAssertionsNop(c *C) {
@@ -2768,6 +2905,203 @@ func (s *assertMgrSuite) TestValidationSetAssertionForMonitorUnpinnedNotFound(c
 	c.Assert(err, ErrorMatches, fmt.Sprintf(`cannot fetch and resolve assertions:\n - validation-set/16/%s/bar: validation-set assertion not found.*`, s.dev1Acct.AccountID()))
 }
 
+func (s *assertMgrSuite) setupValidationSetEnforce(c *C) {
+	// have a model and the store assertion available
+	storeAs := s.setupModelAndStore(c)
+	c.Assert(s.storeSigning.Add(storeAs), IsNil)
+	c.Assert(assertstate.Add(s.state, s.storeSigning.StoreAccountKey("")), IsNil)
+	c.Assert(assertstate.Add(s.state, s.dev1Acct), IsNil)
+	c.Assert(assertstate.Add(s.state, s.dev1AcctKey), IsNil)
+}
+
+func (s *assertMgrSuite) mockInstalledFoo(revno snap.Revision) {
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", SnapID: "qOqKhntON3vR7kwEbVPsILm7bUViPDzz", Revision: revno},
+		},
+		Current:  revno,
+		SnapType: "app",
+	})
+}
+
+func (s *assertMgrSuite) TestValidationSetAssertionForEnforceHappy(c *C) {
+	st := s.state
+
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupValidationSetEnforce(c)
+	s.mockInstalledFoo(snap.R(1))
+
+	vsetAs := s.validationSetAssert(c, "bar", "2", "1", "required")
+	c.Assert(s.storeSigning.Add(vsetAs), IsNil)
+
+	vs, local, err := assertstate.ValidationSetAssertionForEnforce(st, s.dev1Acct.AccountID(), "bar", 0, 0)
+	c.Assert(err, IsNil)
+	c.Check(local, Equals, false)
+	c.Check(vs.Sequence(), Equals, 2)
+}
+
+func (s *assertMgrSuite) TestValidationSetAssertionForEnforceNotMet(c *C) {
+	st := s.state
+
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupValidationSetEnforce(c)
+
+	vsetAs := s.validationSetAssert(c, "bar", "1", "1", "required")
+	c.Assert(s.storeSigning.Add(vsetAs), IsNil)
+
+	vs, _, err := assertstate.ValidationSetAssertionForEnforce(st, s.dev1Acct.AccountID(), "bar", 1, 0)
+	c.Assert(err, FitsTypeOf, &snapasserts.ValidationSetsValidationError{})
+	c.Check(err.(*snapasserts.ValidationSetsValidationError).MissingSnaps, DeepEquals, map[string][]string{
+		"foo": {fmt.Sprintf("%s/bar", s.dev1Acct.AccountID())},
+	})
+	c.Assert(vs, NotNil)
+	c.Check(vs.Sequence(), Equals, 1)
+}
+
+func (s *assertMgrSuite) TestValidationSetAssertionForEnforceConflict(c *C) {
+	st := s.state
+
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupValidationSetEnforce(c)
+	s.mockInstalledFoo(snap.R(1))
+
+	// already enforced
+	vsetAs1 := s.validationSetAssert(c, "foo", "1", "1", "required")
+	c.Assert(assertstate.Add(st, vsetAs1), IsNil)
+	assertstate.UpdateValidationSet(st, &assertstate.ValidationSetTracking{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "foo",
+		Mode:      assertstate.Enforce,
+		Current:   1,
+	})
+
+	vsetAs2 := s.validationSetAssert(c, "bar", "1", "1", "invalid")
+	c.Assert(s.storeSigning.Add(vsetAs2), IsNil)
+
+	_, _, err := assertstate.ValidationSetAssertionForEnforce(st, s.dev1Acct.AccountID(), "bar", 0, 0)
+	c.Check(err, ErrorMatches, `validation sets are in conflict:\n- cannot constrain snap "foo" as both invalid .* and required at revision 1.*`)
+}
+
+func (s *assertMgrSuite) TestEnforceValidationSetNothingToDo(c *C) {
+	st := s.state
+
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupValidationSetEnforce(c)
+	s.mockInstalledFoo(snap.R(1))
+
+	vsetAs := s.validationSetAssert(c, "bar", "2", "1", "required")
+	c.Assert(s.storeSigning.Add(vsetAs), IsNil)
+
+	tss, affected, err := assertstate.EnforceValidationSet(context.Background(), st, s.dev1Acct.AccountID(), "bar", 0, 0)
+	c.Assert(err, IsNil)
+	c.Check(affected, HasLen, 0)
+	c.Assert(tss, HasLen, 1)
+	c.Assert(tss[0].Tasks(), HasLen, 1)
+	c.Check(tss[0].Tasks()[0].Kind(), Equals, "enforce-validation-set")
+
+	chg := st.NewChange("enforce-validation-set", "...")
+	chg.AddAll(tss[0])
+
+	st.Unlock()
+	s.settle(c)
+	st.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+
+	var tr assertstate.ValidationSetTracking
+	c.Assert(assertstate.GetValidationSet(st, s.dev1Acct.AccountID(), "bar", &tr), IsNil)
+	c.Check(tr, DeepEquals, assertstate.ValidationSetTracking{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Mode:      assertstate.Enforce,
+		Current:   2,
+	})
+}
+
+func (s *assertMgrSuite) TestEnforceValidationSetRemovesInvalidSnaps(c *C) {
+	st := s.state
+
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupValidationSetEnforce(c)
+	s.mockInstalledFoo(snap.R(1))
+
+	vsetAs := s.validationSetAssert(c, "bar", "1", "1", "invalid")
+	c.Assert(s.storeSigning.Add(vsetAs), IsNil)
+
+	oldAutomaticSnapshot := snapstate.AutomaticSnapshot
+	defer func() { snapstate.AutomaticSnapshot = oldAutomaticSnapshot }()
+	snapstate.AutomaticSnapshot = func(st *state.State, instanceName string) (ts *state.TaskSet, err error) {
+		return nil, snapstate.ErrNothingToDo
+	}
+
+	tss, affected, err := assertstate.EnforceValidationSet(context.Background(), st, s.dev1Acct.AccountID(), "bar", 1, 0)
+	c.Assert(err, IsNil)
+	c.Check(affected, DeepEquals, []string{"foo"})
+	c.Assert(tss, HasLen, 2)
+
+	removeTasks := tss[0].Tasks()
+	c.Check(removeTasks[0].Kind(), Equals, "stop-snap-services")
+	enforce := tss[1].Tasks()[0]
+	c.Check(enforce.Kind(), Equals, "enforce-validation-set")
+	c.Check(enforce.WaitTasks(), DeepEquals, removeTasks)
+
+	var tr assertstate.ValidationSetTracking
+	c.Assert(enforce.Get("validation-set-tracking", &tr), IsNil)
+	c.Check(tr, DeepEquals, assertstate.ValidationSetTracking{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Mode:      assertstate.Enforce,
+		PinnedAt:  1,
+		Current:   1,
+	})
+}
+
+func (s *assertMgrSuite) TestEnforceValidationSetTaskNotMet(c *C) {
+	st := s.state
+
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupValidationSetEnforce(c)
+	s.mockInstalledFoo(snap.R(1))
+
+	vsetAs := s.validationSetAssert(c, "bar", "1", "1", "required")
+	c.Assert(assertstate.Add(st, vsetAs), IsNil)
+
+	// pretend foo went away in the meantime
+	snapstate.Set(st, "foo", nil)
+
+	chg := st.NewChange("enforce-validation-set", "...")
+	t := st.NewTask("enforce-validation-set", "...")
+	t.Set("validation-set-tracking", &assertstate.ValidationSetTracking{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Mode:      assertstate.Enforce,
+		Current:   1,
+	})
+	chg.AddTask(t)
+
+	st.Unlock()
+	s.settle(c)
+	st.Lock()
+
+	c.Check(chg.Err(), ErrorMatches, fmt.Sprintf(`(?s).*cannot enforce validation set %s/bar: validation sets assertions are not met:.*- missing required snaps:.*foo.*`, s.dev1Acct.AccountID()))
+
+	var tr assertstate.ValidationSetTracking
+	c.Check(assertstate.GetValidationSet(st, s.dev1Acct.AccountID(), "bar", &tr), Equals, state.ErrNoState)
+}
+
 func (s *assertMgrSuite) TestTemporaryDB(c *C) {
 	st := s.state
 
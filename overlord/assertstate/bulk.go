@@ -33,7 +33,10 @@ import (
 	"github.com/snapcore/snapd/store"
 )
 
-const storeGroup = "store assertion"
+const (
+	storeGroup         = "store assertion"
+	refreshPolicyGroup = "refresh-policy assertion"
+)
 
 // maxGroups is the maximum number of assertion groups we set with the
 // asserts.Pool used to refresh snap assertions, it corresponds
@@ -200,6 +203,42 @@ func bulkRefreshValidationSetAsserts(s *state.State, vsets map[string]*Validatio
 	return fmt.Errorf("cannot refresh validation set assertions: %v", err)
 }
 
+func bulkRefreshRefreshPolicy(s *state.State, userID int, deviceCtx snapstate.DeviceContext) error {
+	db := cachedDB(s)
+	pool := asserts.NewPool(db, maxGroups)
+
+	policyRef := refreshPolicyRef(deviceCtx.Model())
+	if err := pool.AddToUpdate(policyRef, refreshPolicyGroup); err != nil {
+		if !asserts.IsNotFound(err) {
+			return fmt.Errorf("cannot prepare refresh-policy assertion refresh: %v", err)
+		}
+		// assertion is not present in the db yet,
+		// we'll try to resolve it (fetch it) first
+		policyAt := &asserts.AtRevision{
+			Ref:      *policyRef,
+			Revision: asserts.RevisionNotKnown,
+		}
+		if err := pool.AddUnresolved(policyAt, refreshPolicyGroup); err != nil {
+			return fmt.Errorf("cannot prepare refresh-policy assertion fetching: %v", err)
+		}
+	}
+
+	err := resolvePoolNoFallback(s, pool, nil, userID, deviceCtx)
+	if err == nil {
+		return nil
+	}
+	if rerr, ok := err.(*resolvePoolError); ok {
+		// most models have no refresh-policy
+		if e := rerr.errors[refreshPolicyGroup]; asserts.IsNotFound(e) || e == asserts.ErrUnresolved {
+			delete(rerr.errors, refreshPolicyGroup)
+		}
+		if len(rerr.errors) == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot refresh refresh-policy assertion: %v", err)
+}
+
 // marker error to request falling back to the old implemention for assertion
 // refreshes
 type bulkAssertionFallbackError struct {
@@ -25,6 +25,7 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 )
@@ -51,9 +52,9 @@ type ValidationSetTracking struct {
 	Current int `json:"current,omitempty"`
 
 	// LocalOnly indicates that the assertion was only available locally at the
-	// time it was applied for monitor mode. This tells bulk refresh logic not
-	// to error out on such assertion if it's not in the store.
-	// This flag makes sense only in monitor mode and if pinned.
+	// time it was applied. This tells bulk refresh logic not to error out on
+	// such assertion if it's not in the store.
+	// This flag makes sense only if pinned.
 	LocalOnly bool `json:"local-only,omitempty"`
 }
 
@@ -137,6 +138,14 @@ func ValidationSets(st *state.State) (map[string]*ValidationSetTracking, error)
 // EnforcedValidationSets returns ValidationSets object with all currently tracked
 // validation sets that are in enforcing mode.
 func EnforcedValidationSets(st *state.State) (*snapasserts.ValidationSets, error) {
+	return enforcedValidationSets(st)
+}
+
+// enforcedValidationSets returns ValidationSets object with all currently
+// tracked validation sets that are in enforcing mode, combined with the given
+// extra validation sets. An extra validation set replaces the tracked one with
+// the same key.
+func enforcedValidationSets(st *state.State, extraVss ...*asserts.ValidationSet) (*snapasserts.ValidationSets, error) {
 	valsets, err := ValidationSets(st)
 	if err != nil {
 		return nil, err
@@ -145,10 +154,18 @@ func EnforcedValidationSets(st *state.State) (*snapasserts.ValidationSets, error
 	db := DB(st)
 	sets := snapasserts.NewValidationSets()
 
+	skip := make(map[string]bool, len(extraVss))
+	for _, extraVs := range extraVss {
+		skip[ValidationSetKey(extraVs.AccountID(), extraVs.Name())] = true
+	}
+
 	for _, vs := range valsets {
 		if vs.Mode != Enforce {
 			continue
 		}
+		if skip[ValidationSetKey(vs.AccountID, vs.Name)] {
+			continue
+		}
 
 		sequence := vs.Current
 		if vs.PinnedAt > 0 {
@@ -170,5 +187,26 @@ func EnforcedValidationSets(st *state.State) (*snapasserts.ValidationSets, error
 		sets.Add(vsetAssert)
 	}
 
+	for _, extraVs := range extraVss {
+		if err := sets.Add(extraVs); err != nil {
+			return nil, err
+		}
+	}
+
 	return sets, err
 }
+
+// installedSnaps returns the installed snaps in the form needed to check
+// them against validation sets.
+func installedSnaps(st *state.State) ([]*snapasserts.InstalledSnap, error) {
+	var snaps []*snapasserts.InstalledSnap
+	all, err := snapstate.All(st)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapst := range all {
+		cur := snapst.CurrentSideInfo()
+		snaps = append(snaps, snapasserts.NewInstalledSnap(snapst.InstanceName(), cur.SnapID, cur.Revision))
+	}
+	return snaps, nil
+}
@@ -36,6 +36,25 @@ func (osb *overlordStateBackend) Checkpoint(data []byte) error {
 	return osutil.AtomicWriteFile(osb.path, data, 0600, 0)
 }
 
+// overlordJournaledStateBackend persists the state incrementally through
+// a state journal.
+type overlordJournaledStateBackend struct {
+	*overlordStateBackend
+	journal *state.Journal
+}
+
+func (ojsb *overlordJournaledStateBackend) Checkpoint(data []byte) error {
+	entries, err := state.SplitEntries(data)
+	if err != nil {
+		return err
+	}
+	return ojsb.journal.Commit(entries)
+}
+
+func (ojsb *overlordJournaledStateBackend) CheckpointEntries(delta *state.EntriesDelta) error {
+	return ojsb.journal.CommitEntries(delta)
+}
+
 func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
 	osb.ensureBefore(d)
 }
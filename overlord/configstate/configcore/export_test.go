@@ -19,7 +19,12 @@
 
 package configcore
 
-import "github.com/snapcore/snapd/osutil/sys"
+import (
+	"time"
+
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/overlord/state"
+)
 
 var (
 	UpdatePiConfig       = updatePiConfig
@@ -48,3 +53,27 @@ func MockChownPath(f func(string, sys.UserID, sys.GroupID) error) func() {
 		sysChownPath = old
 	}
 }
+
+func MockDevicestateUpdateKernelCommandLineAppend(f func(st *state.State, cmdlineAppend string) error) func() {
+	old := devicestateUpdateKernelCommandLineAppend
+	devicestateUpdateKernelCommandLineAppend = f
+	return func() {
+		devicestateUpdateKernelCommandLineAppend = old
+	}
+}
+
+func MockNetplanApply(f func() error) func() {
+	old := netplanApply
+	netplanApply = f
+	return func() {
+		netplanApply = old
+	}
+}
+
+func MockNetworkCheckConnectivity(f func(target string, timeout time.Duration) error) func() {
+	old := networkCheckConnectivity
+	networkCheckConnectivity = f
+	return func() {
+		networkCheckConnectivity = old
+	}
+}
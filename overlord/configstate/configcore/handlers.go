@@ -73,6 +73,9 @@ func init() {
 	// network.disable-ipv6
 	addFSOnlyHandler(validateNetworkSettings, handleNetworkConfiguration, coreOnly)
 
+	// network.netplan, network.check-{target,timeout}
+	addFSOnlyHandler(validateNetplanSettings, handleNetplanConfiguration, coreOnly)
+
 	// service.*.disable
 	addFSOnlyHandler(nil, handleServiceDisableConfiguration, coreOnly)
 
@@ -97,6 +100,15 @@ func init() {
 	// system.timezone
 	addFSOnlyHandler(validateTimezoneSettings, handleTimezoneConfiguration, coreOnly)
 
+	// system.hostname
+	addFSOnlyHandler(validateHostnameSettings, handleHostnameConfiguration, coreOnly)
+
+	// system.timeserver
+	addFSOnlyHandler(validateTimeserverSettings, handleTimeserverConfiguration, coreOnly)
+
+	// system.locale
+	addFSOnlyHandler(validateLocaleSettings, handleLocaleConfiguration, coreOnly)
+
 	sysconfig.ApplyFilesystemOnlyDefaultsImpl = filesystemOnlyApply
 }
 
@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/sysconfig"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.system.hostname"] = true
+}
+
+// the kernel limits hostnames to 64 characters
+const maxHostnameLen = 64
+
+// each dot separated label of a hostname must follow RFC 1123
+var validHostnameLabel = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`).MatchString
+
+func validateHostname(hostname string) error {
+	if len(hostname) > maxHostnameLen {
+		return fmt.Errorf("name too long")
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		if !validHostnameLabel(label) {
+			return fmt.Errorf("name not valid")
+		}
+	}
+	return nil
+}
+
+func validateHostnameSettings(tr config.ConfGetter) error {
+	hostname, err := coreCfg(tr, "system.hostname")
+	if err != nil {
+		return err
+	}
+	if hostname == "" {
+		return nil
+	}
+	if err := validateHostname(hostname); err != nil {
+		return fmt.Errorf("cannot set hostname %q: %v", hostname, err)
+	}
+	return nil
+}
+
+func handleHostnameConfiguration(_ sysconfig.Device, tr config.ConfGetter, opts *fsOnlyContext) error {
+	hostname, err := coreCfg(tr, "system.hostname")
+	if err != nil {
+		return err
+	}
+	if hostname == "" {
+		// restore the default hostname only when the option got unset,
+		// a hostname that was never set through snap set is left alone
+		var pristineHostname string
+		if err := tr.GetPristine("core", "system.hostname", &pristineHostname); err != nil && !config.IsNoOption(err) {
+			return err
+		}
+		if pristineHostname == "" {
+			return nil
+		}
+	}
+	// runtime system
+	if opts == nil {
+		// an empty static hostname makes systemd fall back to the
+		// default hostname
+		output, err := exec.Command("hostnamectl", "set-hostname", hostname).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("cannot set hostname: %v", osutil.OutputErr(output, err))
+		}
+		return nil
+	}
+
+	// On the UC16/UC18/UC20 images the file /etc/hostname is a symlink to
+	// /etc/writable/hostname, set the latter as /etc/hostname is not part
+	// of the "writable-path".
+	hostnamePath := filepath.Join(opts.RootDir, "/etc/writable/hostname")
+	if err := os.MkdirAll(filepath.Dir(hostnamePath), 0755); err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(hostnamePath, []byte(hostname+"\n"), 0644, 0); err != nil {
+		return fmt.Errorf("cannot write hostname: %v", err)
+	}
+	return nil
+}
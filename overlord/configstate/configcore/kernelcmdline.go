@@ -0,0 +1,83 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+// +build !nomanagers
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicestate"
+)
+
+const kernelCmdlineAppendOpt = "system.kernel.cmdline-append"
+
+var devicestateUpdateKernelCommandLineAppend = devicestate.UpdateKernelCommandLineAppend
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core."+kernelCmdlineAppendOpt] = true
+}
+
+func validateKernelCmdlineAppend(tr config.Conf) error {
+	var v interface{}
+	if err := tr.Get("core", kernelCmdlineAppendOpt, &v); err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	cmdlineAppend, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("%s must be a string", kernelCmdlineAppendOpt)
+	}
+	// the arguments are checked against the allow list of the gadget when
+	// handling the change
+	if _, err := osutil.KernelCommandLineSplit(cmdlineAppend); err != nil {
+		return fmt.Errorf("cannot parse %s: %v", kernelCmdlineAppendOpt, err)
+	}
+	return nil
+}
+
+func handleKernelCmdlineAppend(tr config.Conf, opts *fsOnlyContext) error {
+	var pristineCmdlineAppend, newCmdlineAppend string
+
+	if err := tr.GetPristine("core", kernelCmdlineAppendOpt, &pristineCmdlineAppend); err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	if err := tr.Get("core", kernelCmdlineAppendOpt, &newCmdlineAppend); err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	if pristineCmdlineAppend == newCmdlineAppend {
+		return nil
+	}
+
+	st := tr.State()
+	st.Lock()
+	defer st.Unlock()
+
+	// the kernel command line is updated, and a reboot requested if
+	// needed, by a task that runs once the configuration is committed
+	if err := devicestateUpdateKernelCommandLineAppend(st, newCmdlineAppend); err != nil {
+		return fmt.Errorf("cannot set %s: %v", kernelCmdlineAppendOpt, err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type restartBackend struct {
+	restartRequested []state.RestartType
+}
+
+func (b *restartBackend) Checkpoint(data []byte) error { return nil }
+func (b *restartBackend) EnsureBefore(d time.Duration) {}
+func (b *restartBackend) RequestRestart(t state.RestartType) {
+	b.restartRequested = append(b.restartRequested, t)
+}
+
+type kernelCmdlineSuite struct {
+	configcoreSuite
+
+	backend *restartBackend
+	updates []string
+}
+
+var _ = Suite(&kernelCmdlineSuite{})
+
+func (s *kernelCmdlineSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	err := os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/etc/"), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(dirs.GlobalRootDir, "/etc/environment"), nil, 0644)
+	c.Assert(err, IsNil)
+
+	s.backend = &restartBackend{}
+	s.state = state.New(s.backend)
+	s.state.Lock()
+	s.state.VerifyReboot("boot-id-0")
+	s.state.Unlock()
+
+	s.updates = nil
+	s.AddCleanup(configcore.MockDevicestateUpdateKernelCommandLineAppend(func(st *state.State, cmdlineAppend string) error {
+		c.Check(st, Equals, s.state)
+		s.updates = append(s.updates, cmdlineAppend)
+		return nil
+	}))
+}
+
+func (s *kernelCmdlineSuite) TestConfigureKernelCmdlineAppendHappy(c *C) {
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet isolcpus=1-3",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.updates, DeepEquals, []string{"quiet isolcpus=1-3"})
+	// the reboot is requested by the update task once the configuration
+	// is committed
+	c.Check(s.backend.restartRequested, HasLen, 0)
+
+	// unsetting drops the arguments again
+	err = configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet isolcpus=1-3",
+		},
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.updates, DeepEquals, []string{"quiet isolcpus=1-3", ""})
+	c.Check(s.backend.restartRequested, HasLen, 0)
+}
+
+func (s *kernelCmdlineSuite) TestConfigureKernelCmdlineAppendNoChange(c *C) {
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet",
+		},
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.updates, HasLen, 0)
+	c.Check(s.backend.restartRequested, HasLen, 0)
+}
+
+func (s *kernelCmdlineSuite) TestConfigureKernelCmdlineAppendOtherOptionInvalid(c *C) {
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet",
+			"refresh.timer":                "invalid",
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot parse "invalid": .*`)
+	// nothing is queued when the configuration is rejected
+	c.Check(s.updates, HasLen, 0)
+	c.Check(s.backend.restartRequested, HasLen, 0)
+}
+
+func (s *kernelCmdlineSuite) TestConfigureKernelCmdlineAppendClassic(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.updates, HasLen, 0)
+}
+
+func (s *kernelCmdlineSuite) TestConfigureKernelCmdlineAppendInvalid(c *C) {
+	for _, tc := range []struct {
+		value interface{}
+		err   string
+	}{
+		{`quiet="foo`, `cannot parse system.kernel.cmdline-append: unbalanced quoting`},
+		{"=foo", `cannot parse system.kernel.cmdline-append: unexpected assignment`},
+		{1, `system.kernel.cmdline-append must be a string`},
+	} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				"system.kernel.cmdline-append": tc.value,
+			},
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("value %v", tc.value))
+	}
+	c.Check(s.updates, HasLen, 0)
+}
+
+func (s *kernelCmdlineSuite) TestConfigureKernelCmdlineAppendUpdateError(c *C) {
+	restore := configcore.MockDevicestateUpdateKernelCommandLineAppend(func(st *state.State, cmdlineAppend string) error {
+		return fmt.Errorf(`kernel argument "foo" is not allowed by the gadget`)
+	})
+	defer restore()
+
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "foo",
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot set system.kernel.cmdline-append: kernel argument "foo" is not allowed by the gadget`)
+	c.Check(s.backend.restartRequested, HasLen, 0)
+}
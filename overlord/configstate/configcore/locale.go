@@ -0,0 +1,98 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/sysconfig"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.system.locale"] = true
+}
+
+// defaultLocale is the system locale of the Ubuntu Core images
+const defaultLocale = "C.UTF-8"
+
+// locales look like language[_territory][.codeset][@modifier], e.g.
+// en_US.UTF-8, with C and POSIX being the special ones
+var validLocale = regexp.MustCompile(`^([a-zA-Z]{2,3}(_[a-zA-Z]{2})?|C|POSIX)(\.[a-zA-Z0-9-]+)?(@[a-zA-Z0-9]+)?$`).MatchString
+
+func validateLocaleSettings(tr config.ConfGetter) error {
+	locale, err := coreCfg(tr, "system.locale")
+	if err != nil {
+		return err
+	}
+	if locale == "" {
+		return nil
+	}
+	if !validLocale(locale) {
+		return fmt.Errorf("cannot set locale %q: name not valid", locale)
+	}
+	return nil
+}
+
+func handleLocaleConfiguration(_ sysconfig.Device, tr config.ConfGetter, opts *fsOnlyContext) error {
+	locale, err := coreCfg(tr, "system.locale")
+	if err != nil {
+		return err
+	}
+	if locale == "" {
+		// restore the default locale only when the option got unset,
+		// a locale that was never set through snap set is left alone
+		var pristineLocale string
+		if err := tr.GetPristine("core", "system.locale", &pristineLocale); err != nil && !config.IsNoOption(err) {
+			return err
+		}
+		if pristineLocale == "" {
+			return nil
+		}
+		locale = defaultLocale
+	}
+	// runtime system
+	if opts == nil {
+		output, err := exec.Command("localectl", "set-locale", "LANG="+locale).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("cannot set locale: %v", osutil.OutputErr(output, err))
+		}
+		return nil
+	}
+
+	// On the UC16/UC18/UC20 images the file /etc/default/locale, where
+	// localectl keeps the system locale, is a symlink to
+	// /etc/writable/locale, set the latter as /etc/default/locale is
+	// not part of the "writable-path".
+	localePath := filepath.Join(opts.RootDir, "/etc/writable/locale")
+	if err := os.MkdirAll(filepath.Dir(localePath), 0755); err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(localePath, []byte(fmt.Sprintf("LANG=%s\n", locale)), 0644, 0); err != nil {
+		return fmt.Errorf("cannot write locale: %v", err)
+	}
+	return nil
+}
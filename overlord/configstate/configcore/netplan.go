@@ -0,0 +1,369 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/sysconfig"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.network.netplan"] = true
+	supportedConfigurations["core.network.check-target"] = true
+	supportedConfigurations["core.network.check-timeout"] = true
+}
+
+const (
+	netplanConfigName = "90-snapd-config.yaml"
+
+	netplanConfigHeader = "# This file is generated by snapd from the network.netplan system\n# configuration, do not edit\n"
+
+	defaultNetworkCheckTimeout = 30 * time.Second
+)
+
+var (
+	netplanApply             = netplanApplyImpl
+	networkCheckConnectivity = networkCheckConnectivityImpl
+)
+
+// netplan device definitions grouped by their type
+var netplanDeviceTypes = map[string]bool{
+	"ethernets":  true,
+	"wifis":      true,
+	"bonds":      true,
+	"bridges":    true,
+	"vlans":      true,
+	"tunnels":    true,
+	"modems":     true,
+	"vrfs":       true,
+	"nm-devices": true,
+}
+
+// netplan device IDs end up as interface names or match identifiers
+var validNetplanDeviceID = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.:-]*$`).MatchString
+
+// normalizeNetplanValue converts a value obtained either from YAML or from
+// the JSON backed configuration into plain maps, lists and scalars that can
+// be validated and marshalled back into YAML.
+func normalizeNetplanValue(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			n, err := normalizeNetplanValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = n
+		}
+		return m, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprintf("%v", k)
+			}
+			n, err := normalizeNetplanValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = n
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(val))
+		for i, elem := range val {
+			n, err := normalizeNetplanValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = n
+		}
+		return l, nil
+	case int:
+		return int64(val), nil
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i, nil
+		}
+		return val.Float64()
+	default:
+		return v, nil
+	}
+}
+
+// netplanConfig returns the netplan document set either as a YAML string or
+// as a tree of configuration keys under network.netplan, or nil if it is not
+// set.
+func netplanConfig(tr config.ConfGetter) (map[string]interface{}, error) {
+	var v interface{}
+	if err := tr.Get("core", "network.netplan", &v); err != nil {
+		if config.IsNoOption(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var doc interface{}
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if val == "" {
+			return nil, nil
+		}
+		if err := yaml.Unmarshal([]byte(val), &doc); err != nil {
+			return nil, fmt.Errorf("cannot parse netplan configuration: %v", err)
+		}
+	default:
+		doc = val
+	}
+
+	normalized, err := normalizeNetplanValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := normalized.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("netplan configuration must be a map, got %T", normalized)
+	}
+	return m, nil
+}
+
+func validateNetplanDocument(doc map[string]interface{}) error {
+	for k := range doc {
+		if k != "network" {
+			return fmt.Errorf("unsupported top-level key %q", k)
+		}
+	}
+	network, ok := doc["network"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf(`"network" must be a map`)
+	}
+
+	for k, v := range network {
+		switch {
+		case k == "version":
+			if version, ok := v.(int64); !ok || version != 2 {
+				return fmt.Errorf("unsupported version %v", v)
+			}
+		case k == "renderer":
+			if v != "networkd" && v != "NetworkManager" {
+				return fmt.Errorf("unsupported renderer %v", v)
+			}
+		case netplanDeviceTypes[k]:
+			devices, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%q must be a map of device definitions", k)
+			}
+			for id, def := range devices {
+				if !validNetplanDeviceID(id) {
+					return fmt.Errorf("invalid device ID %q in %q", id, k)
+				}
+				if _, ok := def.(map[string]interface{}); !ok {
+					return fmt.Errorf("definition of device %q in %q must be a map", id, k)
+				}
+			}
+		default:
+			return fmt.Errorf("unsupported key %q under \"network\"", k)
+		}
+	}
+	return nil
+}
+
+func networkCheckTarget(tr config.ConfGetter) (target string, timeout time.Duration, err error) {
+	target, err = coreCfg(tr, "network.check-target")
+	if err != nil {
+		return "", 0, err
+	}
+	if target != "" {
+		_, port, err := net.SplitHostPort(target)
+		if err != nil {
+			return "", 0, fmt.Errorf("cannot use network check target %q: %v", target, err)
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return "", 0, fmt.Errorf("cannot use network check target %q: invalid port", target)
+		}
+	}
+
+	timeout = defaultNetworkCheckTimeout
+	timeoutStr, err := coreCfg(tr, "network.check-timeout")
+	if err != nil {
+		return "", 0, err
+	}
+	if timeoutStr != "" {
+		timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil || timeout <= 0 {
+			return "", 0, fmt.Errorf("network.check-timeout must be a positive duration, got %q", timeoutStr)
+		}
+	}
+	return target, timeout, nil
+}
+
+func validateNetplanSettings(tr config.ConfGetter) error {
+	doc, err := netplanConfig(tr)
+	if err != nil {
+		return err
+	}
+	if doc != nil {
+		if err := validateNetplanDocument(doc); err != nil {
+			return fmt.Errorf("cannot set netplan configuration: %v", err)
+		}
+	}
+	_, _, err = networkCheckTarget(tr)
+	return err
+}
+
+func netplanApplyImpl() error {
+	output, err := exec.Command("netplan", "apply").CombinedOutput()
+	if err != nil {
+		return osutil.OutputErr(output, err)
+	}
+	return nil
+}
+
+// networkCheckConnectivityImpl tries to open a TCP connection to target
+// until it succeeds or the timeout expires.
+func networkCheckConnectivityImpl(target string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", target, 5*time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().Add(time.Second).After(deadline) {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// writeNetplanConfig writes or, when content is nil, removes the snapd
+// generated netplan configuration and reports whether anything changed.
+func writeNetplanConfig(dir string, content []byte) (bool, error) {
+	dirContent := map[string]osutil.FileState{}
+	if content != nil {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return false, err
+		}
+		// netplan warns about world readable configuration as it may
+		// contain secrets such as wifi passwords
+		dirContent[netplanConfigName] = &osutil.MemoryFileState{
+			Content: content,
+			Mode:    0600,
+		}
+	}
+	changed, removed, err := osutil.EnsureDirState(dir, netplanConfigName, dirContent)
+	if err != nil {
+		return false, err
+	}
+	return len(changed) > 0 || len(removed) > 0, nil
+}
+
+func handleNetplanConfiguration(_ sysconfig.Device, tr config.ConfGetter, opts *fsOnlyContext) error {
+	doc, err := netplanConfig(tr)
+	if err != nil {
+		return err
+	}
+	var content []byte
+	if doc != nil {
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		content = append([]byte(netplanConfigHeader), out...)
+	}
+
+	root := dirs.GlobalRootDir
+	if opts != nil {
+		root = opts.RootDir
+	}
+	dir := filepath.Join(root, "/etc/netplan")
+
+	if opts != nil {
+		// the configuration is applied when the system boots
+		_, err := writeNetplanConfig(dir, content)
+		return err
+	}
+
+	// keep the previous configuration around for rollback
+	oldContent, err := ioutil.ReadFile(filepath.Join(dir, netplanConfigName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	target, timeout, err := networkCheckTarget(tr)
+	if err != nil {
+		return err
+	}
+	// connectivity can only be lost if it was there in the first place,
+	// and only if the configuration actually changes
+	contentChanged := !bytes.Equal(oldContent, content) || (oldContent == nil) != (content == nil)
+	checkConnectivity := false
+	if target != "" && contentChanged {
+		checkConnectivity = networkCheckConnectivity(target, timeout) == nil
+	}
+
+	changed, err := writeNetplanConfig(dir, content)
+	if err != nil || !changed {
+		return err
+	}
+
+	rollback := func(reason error) error {
+		if _, err := writeNetplanConfig(dir, oldContent); err != nil {
+			return fmt.Errorf("cannot apply network configuration: %v (and cannot restore the previous configuration: %v)", reason, err)
+		}
+		if err := netplanApply(); err != nil {
+			return fmt.Errorf("cannot apply network configuration: %v (and cannot apply the previous configuration: %v)", reason, err)
+		}
+		return fmt.Errorf("cannot apply network configuration: %v (previous configuration restored)", reason)
+	}
+
+	if err := netplanApply(); err != nil {
+		return rollback(err)
+	}
+	if checkConnectivity {
+		if err := networkCheckConnectivity(target, timeout); err != nil {
+			logger.Noticef("lost connectivity to %s after applying network configuration: %v", target, err)
+			return rollback(fmt.Errorf("lost connectivity to %s", target))
+		}
+	}
+	return nil
+}
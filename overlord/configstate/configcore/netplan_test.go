@@ -0,0 +1,309 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type netplanSuite struct {
+	configcoreSuite
+
+	netplanConfig string
+	applyCalls    int
+	applyErr      error
+	checkCalls    []string
+	checkErrs     []error
+}
+
+var _ = Suite(&netplanSuite{})
+
+const netplanYAML = `network:
+  version: 2
+  renderer: networkd
+  ethernets:
+    eth0:
+      dhcp4: true
+`
+
+const expectedNetplanConfig = `# This file is generated by snapd from the network.netplan system
+# configuration, do not edit
+network:
+  ethernets:
+    eth0:
+      dhcp4: true
+  renderer: networkd
+  version: 2
+`
+
+func (s *netplanSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	s.netplanConfig = filepath.Join(dirs.GlobalRootDir, "/etc/netplan/90-snapd-config.yaml")
+	s.applyCalls = 0
+	s.applyErr = nil
+	s.checkCalls = nil
+	s.checkErrs = nil
+
+	err := os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/etc/"), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(dirs.GlobalRootDir, "/etc/environment"), nil, 0644)
+	c.Assert(err, IsNil)
+
+	s.AddCleanup(configcore.MockNetplanApply(func() error {
+		s.applyCalls++
+		return s.applyErr
+	}))
+	s.AddCleanup(configcore.MockNetworkCheckConnectivity(func(target string, timeout time.Duration) error {
+		c.Check(timeout, Equals, 10*time.Second)
+		s.checkCalls = append(s.checkCalls, target)
+		if len(s.checkErrs) == 0 {
+			return nil
+		}
+		err := s.checkErrs[0]
+		s.checkErrs = s.checkErrs[1:]
+		return err
+	}))
+}
+
+func (s *netplanSuite) TestConfigureNetplanInvalid(c *C) {
+	for _, tc := range []struct {
+		netplan interface{}
+		err     string
+	}{
+		{"network: [", `cannot parse netplan configuration: .*`},
+		{"- foo", `netplan configuration must be a map, got \[\]interface {}`},
+		{"foo: bar", `cannot set netplan configuration: unsupported top-level key "foo"`},
+		{"network: foo", `cannot set netplan configuration: "network" must be a map`},
+		{"network: {version: 1}", `cannot set netplan configuration: unsupported version 1`},
+		{"network: {renderer: foo}", `cannot set netplan configuration: unsupported renderer foo`},
+		{"network: {ethernets: foo}", `cannot set netplan configuration: "ethernets" must be a map of device definitions`},
+		{"network: {ethernets: {-eth0: {}}}", `cannot set netplan configuration: invalid device ID "-eth0" in "ethernets"`},
+		{"network: {ethernets: {eth0: foo}}", `cannot set netplan configuration: definition of device "eth0" in "ethernets" must be a map`},
+		{"network: {foo: {}}", `cannot set netplan configuration: unsupported key "foo" under "network"`},
+		{map[string]interface{}{"network": map[string]interface{}{"version": "2"}}, `cannot set netplan configuration: unsupported version 2`},
+	} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"network.netplan": tc.netplan,
+			},
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.netplan))
+	}
+	c.Check(s.netplanConfig, testutil.FileAbsent)
+	c.Check(s.applyCalls, Equals, 0)
+}
+
+func (s *netplanSuite) TestConfigureNetworkCheckInvalid(c *C) {
+	for _, tc := range []struct {
+		target, timeout string
+		err             string
+	}{
+		{"example.com", "", `cannot use network check target "example.com": address example.com: missing port in address`},
+		{"example.com:http", "", `cannot use network check target "example.com:http": invalid port`},
+		{"example.com:80", "foo", `network.check-timeout must be a positive duration, got "foo"`},
+		{"example.com:80", "-1s", `network.check-timeout must be a positive duration, got "-1s"`},
+	} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"network.check-target":  tc.target,
+				"network.check-timeout": tc.timeout,
+			},
+		})
+		c.Check(err, ErrorMatches, tc.err)
+	}
+}
+
+func (s *netplanSuite) TestConfigureNetplanYAML(c *C) {
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"network.netplan": netplanYAML,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.netplanConfig, testutil.FileEquals, expectedNetplanConfig)
+	st, err := os.Stat(s.netplanConfig)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0600))
+	c.Check(s.applyCalls, Equals, 1)
+	// no check target configured
+	c.Check(s.checkCalls, HasLen, 0)
+
+	// nothing changed, netplan is not applied again
+	err = configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"network.netplan": netplanYAML,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.applyCalls, Equals, 1)
+
+	// unsetting removes the configuration
+	err = configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"network.netplan": "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.netplanConfig, testutil.FileAbsent)
+	c.Check(s.applyCalls, Equals, 2)
+}
+
+func (s *netplanSuite) TestConfigureNetplanKeyTree(c *C) {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "network.netplan.network.version", 2), IsNil)
+	c.Assert(tr.Set("core", "network.netplan.network.renderer", "networkd"), IsNil)
+	c.Assert(tr.Set("core", "network.netplan.network.ethernets.eth0.dhcp4", true), IsNil)
+	s.state.Unlock()
+
+	err := configcore.Run(coreDev, tr)
+	c.Assert(err, IsNil)
+	c.Check(s.netplanConfig, testutil.FileEquals, expectedNetplanConfig)
+	c.Check(s.applyCalls, Equals, 1)
+}
+
+func (s *netplanSuite) TestConfigureNetplanConnectivityKept(c *C) {
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"network.netplan":       netplanYAML,
+			"network.check-target":  "example.com:443",
+			"network.check-timeout": "10s",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.netplanConfig, testutil.FileEquals, expectedNetplanConfig)
+	c.Check(s.applyCalls, Equals, 1)
+	// checked before and after applying the configuration
+	c.Check(s.checkCalls, DeepEquals, []string{"example.com:443", "example.com:443"})
+}
+
+func (s *netplanSuite) TestConfigureNetplanUnchangedNoCheck(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(s.netplanConfig), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.netplanConfig, []byte(expectedNetplanConfig), 0600), IsNil)
+
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"network.netplan":       netplanYAML,
+			"network.check-target":  "example.com:443",
+			"network.check-timeout": "10s",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.netplanConfig, testutil.FileEquals, expectedNetplanConfig)
+	// nothing changed so neither applied nor probed
+	c.Check(s.applyCalls, Equals, 0)
+	c.Check(s.checkCalls, HasLen, 0)
+}
+
+func (s *netplanSuite) TestConfigureNetplanConnectivityLostRollback(c *C) {
+	const oldConfig = "network: {version: 2}\n"
+	c.Assert(os.MkdirAll(filepath.Dir(s.netplanConfig), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.netplanConfig, []byte(oldConfig), 0600), IsNil)
+
+	s.checkErrs = []error{nil, errors.New("timeout")}
+
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"network.netplan":       netplanYAML,
+			"network.check-target":  "example.com:443",
+			"network.check-timeout": "10s",
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot apply network configuration: lost connectivity to example.com:443 \(previous configuration restored\)`)
+	c.Check(s.netplanConfig, testutil.FileEquals, oldConfig)
+	// applied once with the new and once with the old configuration
+	c.Check(s.applyCalls, Equals, 2)
+}
+
+func (s *netplanSuite) TestConfigureNetplanNoConnectivityBefore(c *C) {
+	// the target is unreachable before applying so there is nothing to lose
+	s.checkErrs = []error{errors.New("timeout"), errors.New("timeout")}
+
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"network.netplan":       netplanYAML,
+			"network.check-target":  "example.com:443",
+			"network.check-timeout": "10s",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.netplanConfig, testutil.FileEquals, expectedNetplanConfig)
+	c.Check(s.checkCalls, DeepEquals, []string{"example.com:443"})
+}
+
+func (s *netplanSuite) TestConfigureNetplanApplyErrorRollback(c *C) {
+	s.applyErr = errors.New("boom")
+
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"network.netplan": netplanYAML,
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot apply network configuration: boom \(and cannot apply the previous configuration: boom\)`)
+	// there was no previous configuration
+	c.Check(s.netplanConfig, testutil.FileAbsent)
+	c.Check(s.applyCalls, Equals, 2)
+}
+
+func (s *netplanSuite) TestFilesystemOnlyApply(c *C) {
+	// gadget defaults are decoded from YAML
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"network.netplan": map[interface{}]interface{}{
+			"network": map[interface{}]interface{}{
+				"version":  2,
+				"renderer": "networkd",
+				"ethernets": map[interface{}]interface{}{
+					"eth0": map[interface{}]interface{}{
+						"dhcp4": true,
+					},
+				},
+			},
+		},
+	})
+	tmpDir := c.MkDir()
+	c.Assert(configcore.FilesystemOnlyApply(coreDev, tmpDir, conf), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/netplan/90-snapd-config.yaml"), testutil.FileEquals, expectedNetplanConfig)
+	// the configuration is applied on boot
+	c.Check(s.applyCalls, Equals, 0)
+	c.Check(s.checkCalls, HasLen, 0)
+}
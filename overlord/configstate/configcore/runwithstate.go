@@ -49,6 +49,9 @@ func init() {
 	// store-certs.*
 	addWithStateHandler(validateCertSettings, handleCertConfiguration, nil)
 
+	// system.kernel.cmdline-append
+	addWithStateHandler(validateKernelCmdlineAppend, handleKernelCmdlineAppend, coreOnly)
+
 	// users.create.automatic
 	addWithStateHandler(validateUsersSettings, handleUserSettings, &flags{earlyConfigFilter: earlyUsersSettingsFilter})
 
@@ -116,6 +119,8 @@ func applyHandlers(dev sysconfig.Device, cfg config.Conf, handlers []configHandl
 			if !validCertOption(k) {
 				return fmt.Errorf("cannot set store ssl certificate under name %q: name must only contain word characters or a dash", k)
 			}
+		case strings.HasPrefix(k, "core.network.netplan."):
+			// the netplan document is validated as a whole by its handler
 		case !supportedConfigurations[k]:
 			return fmt.Errorf("cannot set %q: unsupported system option", k)
 		}
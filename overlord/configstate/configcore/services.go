@@ -102,7 +102,7 @@ func switchDisableConsoleConfService(sysd systemd.Systemd, serviceName string, d
 	// at runtime we can not change this setting
 	if opts == nil {
 
-		// Special case: during install mode the
+		// Special case: during install (or factory-reset) mode the
 		// gadget-defaults will also be set as part of the
 		// system install change. However during install mode
 		// console-conf has no "complete" file, it just never runs
@@ -113,7 +113,7 @@ func switchDisableConsoleConfService(sysd systemd.Systemd, serviceName string, d
 		//      they are the same but that requires some more changes.
 		// TODO: leverage sysconfig.Device instead
 		mode, _, _ := boot.ModeAndRecoverySystemFromKernelCommandLine()
-		if mode == boot.ModeInstall {
+		if mode == boot.ModeInstall || mode == boot.ModeFactoryReset {
 			return nil
 		}
 
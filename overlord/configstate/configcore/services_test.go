@@ -254,6 +254,23 @@ func (s *servicesSuite) TestConfigureConsoleConfEnableDuringInstallMode(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *servicesSuite) TestConfigureConsoleConfEnableDuringFactoryResetMode(c *C) {
+	mockProcCmdline := filepath.Join(c.MkDir(), "cmdline")
+	err := ioutil.WriteFile(mockProcCmdline, []byte("snapd_recovery_mode=factory-reset snapd_recovery_system=20201212\n"), 0644)
+	c.Assert(err, IsNil)
+	restore := osutil.MockProcCmdline(mockProcCmdline)
+	defer restore()
+
+	err = configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"service.console-conf.disable": true,
+		},
+	})
+	// no error because we are in factory-reset mode
+	c.Assert(err, IsNil)
+}
+
 func (s *servicesSuite) TestConfigureServiceEnableIntegration(c *C) {
 	err := os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/etc/ssh"), 0755)
 	c.Assert(err, IsNil)
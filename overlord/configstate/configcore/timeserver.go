@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/sysconfig"
+	"github.com/snapcore/snapd/systemd"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.system.timeserver"] = true
+}
+
+// timeservers returns the NTP servers from the whitespace separated list
+// of system.timeserver.
+func timeservers(tr config.ConfGetter) ([]string, error) {
+	output, err := coreCfg(tr, "system.timeserver")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
+}
+
+func validateTimeserverSettings(tr config.ConfGetter) error {
+	servers, err := timeservers(tr)
+	if err != nil {
+		return err
+	}
+	for _, server := range servers {
+		if net.ParseIP(server) != nil {
+			continue
+		}
+		if err := validateHostname(server); err != nil {
+			return fmt.Errorf("cannot set timeserver %q: %v", server, err)
+		}
+	}
+	return nil
+}
+
+func handleTimeserverConfiguration(_ sysconfig.Device, tr config.ConfGetter, opts *fsOnlyContext) error {
+	servers, err := timeservers(tr)
+	if err != nil {
+		return err
+	}
+
+	rootDir := dirs.GlobalRootDir
+	if opts != nil {
+		rootDir = opts.RootDir
+	}
+	dir := filepath.Join(rootDir, "/etc/systemd/timesyncd.conf.d")
+	name := "snapd-timeserver.conf"
+
+	// when unset the drop-in is removed and the default servers are used
+	dirContent := make(map[string]osutil.FileState, 1)
+	if len(servers) > 0 {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		dirContent[name] = &osutil.MemoryFileState{
+			Content: []byte(fmt.Sprintf("[Time]\nNTP=%s\n", strings.Join(servers, " "))),
+			Mode:    0644,
+		}
+	}
+	changed, removed, err := osutil.EnsureDirState(dir, name, dirContent)
+	if err != nil {
+		return err
+	}
+
+	// restart timesyncd to pick up the change, unless it is not running
+	if opts == nil && (len(changed) > 0 || len(removed) > 0) {
+		sysd := systemd.New(systemd.SystemMode, &sysdLogger{})
+		active, err := sysd.IsActive("systemd-timesyncd.service")
+		if err != nil {
+			return err
+		}
+		if active {
+			return sysd.ReloadOrRestart("systemd-timesyncd.service")
+		}
+	}
+	return nil
+}
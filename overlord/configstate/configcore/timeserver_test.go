@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type timeserverSuite struct {
+	configcoreSuite
+
+	confPath string
+}
+
+var _ = Suite(&timeserverSuite{})
+
+func (s *timeserverSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	s.confPath = filepath.Join(dirs.GlobalRootDir, "/etc/systemd/timesyncd.conf.d/snapd-timeserver.conf")
+
+	err := os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/etc/"), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(dirs.GlobalRootDir, "/etc/environment"), nil, 0644)
+	c.Assert(err, IsNil)
+}
+
+type inactiveError struct{}
+
+func (inactiveError) Msg() []byte   { return []byte("inactive\n") }
+func (inactiveError) ExitCode() int { return 3 }
+func (inactiveError) Error() string { return "inactive" }
+
+func (s *timeserverSuite) TestConfigureTimeserverInvalid(c *C) {
+	for _, servers := range []string{
+		"-foo", "ntp.example.com no_underscore", "1.2.3.4 no..empty-label",
+	} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.timeserver": servers,
+			},
+		})
+		c.Check(err, ErrorMatches, `cannot set timeserver ".*": name not valid`, Commentf("%q", servers))
+	}
+	c.Check(s.confPath, testutil.FileAbsent)
+}
+
+func (s *timeserverSuite) TestConfigureTimeserverIntegration(c *C) {
+	// timesyncd is running
+	s.systemctlOutput = func(args ...string) []byte {
+		return []byte("active")
+	}
+
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.timeserver": "ntp.example.com  192.168.1.1 fe80::1",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileEquals, "[Time]\nNTP=ntp.example.com 192.168.1.1 fe80::1\n")
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"is-active", "systemd-timesyncd.service"},
+		{"reload-or-restart", "systemd-timesyncd.service"},
+	})
+	s.systemctlArgs = nil
+
+	// setting the same servers again does not restart timesyncd
+	err = configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.timeserver": "ntp.example.com 192.168.1.1 fe80::1",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, HasLen, 0)
+
+	// unsetting goes back to the default servers
+	err = configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.timeserver": "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileAbsent)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"is-active", "systemd-timesyncd.service"},
+		{"reload-or-restart", "systemd-timesyncd.service"},
+	})
+}
+
+func (s *timeserverSuite) TestConfigureTimeserverInactiveTimesyncd(c *C) {
+	s.AddCleanup(systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		s.systemctlArgs = append(s.systemctlArgs, args[:])
+		if args[0] == "is-active" {
+			return nil, inactiveError{}
+		}
+		return nil, nil
+	}))
+
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.timeserver": "ntp.example.com",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileEquals, "[Time]\nNTP=ntp.example.com\n")
+	// timesyncd is not started if it was not running
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"is-active", "systemd-timesyncd.service"},
+	})
+}
+
+func (s *timeserverSuite) TestFilesystemOnlyApply(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.timeserver": "ntp.example.com",
+	})
+	tmpDir := c.MkDir()
+	c.Assert(configcore.FilesystemOnlyApply(coreDev, tmpDir, conf), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/systemd/timesyncd.conf.d/snapd-timeserver.conf"), testutil.FileEquals, "[Time]\nNTP=ntp.example.com\n")
+	c.Check(s.systemctlArgs, HasLen, 0)
+	_, err := os.Stat(s.confPath)
+	c.Check(os.IsNotExist(err), Equals, true)
+}
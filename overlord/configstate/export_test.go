@@ -21,6 +21,8 @@ package configstate
 
 import (
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/sysconfig"
 )
 
@@ -41,3 +43,11 @@ func MockConfigcoreEarly(mock func(dev sysconfig.Device, cfg config.Conf, values
 		configcoreEarly = old
 	}
 }
+
+func MockServicestateReloadOnConfig(mock func(st *state.State, snapName string, changedKeys []string, context *hookstate.Context) ([]*state.TaskSet, error)) (restore func()) {
+	old := servicestateReloadOnConfig
+	servicestateReloadOnConfig = mock
+	return func() {
+		servicestateReloadOnConfig = old
+	}
+}
@@ -20,6 +20,7 @@
 package configstate_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
@@ -260,6 +261,64 @@ volumes:
 	c.Check(err, ErrorMatches, `cannot apply gadget config defaults for snap "test-snap", no configure hook`)
 }
 
+func (s *configureHandlerSuite) TestDoneQueuesReloadOnConfig(c *C) {
+	var changedKeys []string
+	restore := configstate.MockServicestateReloadOnConfig(func(st *state.State, snapName string, keys []string, context *hookstate.Context) ([]*state.TaskSet, error) {
+		c.Check(snapName, Equals, "test-snap")
+		c.Check(context, Equals, s.context)
+		changedKeys = keys
+		return []*state.TaskSet{state.NewTaskSet(st.NewTask("service-control", "..."))}, nil
+	})
+	defer restore()
+
+	s.context.Lock()
+	hookTask, _ := s.context.Task()
+	chg := s.state.NewChange("configure", "...")
+	chg.AddTask(hookTask)
+	s.context.Set("patch", map[string]interface{}{
+		"foo":    "bar",
+		"server": map[string]interface{}{"port": 8080},
+	})
+	s.context.Unlock()
+
+	c.Assert(s.handler.Before(), IsNil)
+	c.Assert(s.handler.Done(), IsNil)
+
+	c.Check(changedKeys, DeepEquals, []string{"foo", "server.port"})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 2)
+	c.Check(tasks[1].Kind(), Equals, "service-control")
+	c.Check(tasks[1].WaitTasks(), DeepEquals, []*state.Task{hookTask})
+}
+
+func (s *configureHandlerSuite) TestDoneNoChangesNoReload(c *C) {
+	restore := configstate.MockServicestateReloadOnConfig(func(st *state.State, snapName string, keys []string, context *hookstate.Context) ([]*state.TaskSet, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})
+	defer restore()
+
+	c.Assert(s.handler.Before(), IsNil)
+	c.Assert(s.handler.Done(), IsNil)
+}
+
+func (s *configureHandlerSuite) TestDoneReloadOnConfigError(c *C) {
+	restore := configstate.MockServicestateReloadOnConfig(func(st *state.State, snapName string, keys []string, context *hookstate.Context) ([]*state.TaskSet, error) {
+		return nil, fmt.Errorf("boom")
+	})
+	defer restore()
+
+	s.context.Lock()
+	s.context.Set("patch", map[string]interface{}{"foo": "bar"})
+	s.context.Unlock()
+
+	c.Assert(s.handler.Before(), IsNil)
+	c.Assert(s.handler.Done(), ErrorMatches, "boom")
+}
+
 type configcoreHandlerSuite struct {
 	testutil.BaseTest
 
@@ -21,9 +21,11 @@ package configstate
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 )
@@ -121,10 +123,41 @@ func (h *configureHandler) Before() error {
 	return nil
 }
 
+var servicestateReloadOnConfig = servicestate.ReloadOnConfig
+
 // Done is called by the HookManager after the configure hook has exited
-// successfully.
+// successfully. It queues the reload of the services of the snap that
+// declare reload-on-config matching any of the changed configuration keys.
 func (h *configureHandler) Done() error {
-	return nil
+	h.context.Lock()
+	defer h.context.Unlock()
+
+	instanceName := h.context.InstanceName()
+	// core is configured internally and has no services
+	if instanceName == "core" {
+		return nil
+	}
+
+	tr := ContextTransaction(h.context)
+	prefix := instanceName + "."
+	var changedKeys []string
+	for _, change := range tr.Changes() {
+		if strings.HasPrefix(change, prefix) {
+			changedKeys = append(changedKeys, change[len(prefix):])
+		}
+	}
+	if len(changedKeys) == 0 {
+		return nil
+	}
+
+	tts, err := servicestateReloadOnConfig(h.context.State(), instanceName, changedKeys, h.context)
+	if err != nil {
+		return err
+	}
+	if len(tts) == 0 {
+		return nil
+	}
+	return h.context.QueueTaskSets(tts)
 }
 
 // Error is called by the HookManager after the configure hook has exited
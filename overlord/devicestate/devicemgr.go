@@ -98,6 +98,8 @@ type DeviceManager struct {
 
 	ensureInstalledRan bool
 
+	ensureFactoryResetRan bool
+
 	ensureTriedRecoverySystemRan bool
 
 	cloudInitAlreadyRestricted           bool
@@ -148,6 +150,7 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 	runner.AddHandler("mark-preseeded", m.doMarkPreseeded, nil)
 	runner.AddHandler("mark-seeded", m.doMarkSeeded, nil)
 	runner.AddHandler("setup-run-system", m.doSetupRunSystem, nil)
+	runner.AddHandler("factory-reset-run-system", m.doFactoryResetRunSystem, nil)
 	runner.AddHandler("restart-system-to-run-mode", m.doRestartSystemToRunMode, nil)
 	runner.AddHandler("prepare-remodeling", m.doPrepareRemodeling, nil)
 	runner.AddCleanup("prepare-remodeling", m.cleanupRemodel)
@@ -166,12 +169,17 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 	runner.AddHandler("update-managed-boot-config", m.doUpdateManagedBootConfig, nil)
 	// kernel command line updates from a gadget supplied file
 	runner.AddHandler("update-gadget-cmdline", m.doUpdateGadgetCommandLine, m.undoUpdateGadgetCommandLine)
+	// kernel command line updates from system configuration, there is no
+	// undo as the arguments come from the committed configuration
+	runner.AddHandler("update-kernel-cmdline-append", m.doUpdateKernelCommandLineAppend, nil)
 	// recovery systems
 	runner.AddHandler("create-recovery-system", m.doCreateRecoverySystem, m.undoCreateRecoverySystem)
 	runner.AddHandler("finalize-recovery-system", m.doFinalizeTriedRecoverySystem, m.undoFinalizeTriedRecoverySystem)
 	runner.AddCleanup("finalize-recovery-system", m.cleanupRecoverySystem)
+	runner.AddHandler("remove-recovery-system", m.doRemoveRecoverySystem, nil)
 
 	runner.AddBlocked(gadgetUpdateBlocked)
+	runner.AddBlocked(kernelCmdlineAppendUpdateBlocked)
 
 	// wire FDE kernel hook support into boot
 	boot.HasFDESetupHook = m.hasFDESetupHook
@@ -348,6 +356,28 @@ func gadgetUpdateBlocked(cand *state.Task, running []*state.Task) bool {
 	return false
 }
 
+// kernelCmdlineAppendUpdateBlocked holds off updating the kernel command line
+// with the arguments from system configuration while the core configure hook
+// is running, such that the update observes the committed configuration.
+func kernelCmdlineAppendUpdateBlocked(cand *state.Task, running []*state.Task) bool {
+	if cand.Kind() != "update-kernel-cmdline-append" {
+		return false
+	}
+	for _, other := range running {
+		if other.Kind() != "run-hook" {
+			continue
+		}
+		var hooksup hookstate.HookSetup
+		if err := other.Get("hook-setup", &hooksup); err != nil {
+			continue
+		}
+		if hooksup.Snap == "core" && hooksup.Hook == "configure" {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *DeviceManager) changeInFlight(kind string) bool {
 	for _, chg := range m.state.Changes() {
 		if chg.Kind() == kind && !chg.Status().Ready() {
@@ -506,6 +536,24 @@ func (m *DeviceManager) ensureOperational() error {
 		hasPrepareDeviceHook = (gadgetInfo.Hooks["prepare-device"] != nil)
 	}
 
+	factoryResetMarker := factoryResetMarkerUnder(dirs.GlobalRootDir)
+	if model.Grade() != asserts.ModelGradeUnset && osutil.FileExists(factoryResetMarker) {
+		// on the first boot after a factory reset the device
+		// identity, that is the serial assertion and the device key,
+		// is still available in ubuntu-save
+		restored, err := m.maybeRestoreSerialFromSave(model, device)
+		if err != nil {
+			return err
+		}
+		// the restore is attempted only once
+		if err := os.Remove(factoryResetMarker); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if restored {
+			return nil
+		}
+	}
+
 	// have some backoff between full retries
 	if m.ensureOperationalShouldBackoff(time.Now()) {
 		return nil
@@ -548,6 +596,73 @@ func (m *DeviceManager) ensureOperational() error {
 	return nil
 }
 
+// maybeRestoreSerialFromSave restores the serial assertion and device
+// key kept in ubuntu-save for the given model, as is the case after a
+// factory reset. It returns true if the device registration could be
+// restored.
+func (m *DeviceManager) maybeRestoreSerialFromSave(model *asserts.Model, device *auth.DeviceState) (restored bool, err error) {
+	var serial *asserts.Serial
+	err = m.withSaveAssertDB(func(savedb *asserts.Database) error {
+		serials, err := savedb.FindMany(asserts.SerialType, map[string]string{
+			"brand-id": model.BrandID(),
+			"model":    model.Model(),
+		})
+		if asserts.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = m.withKeypairMgr(func(keypairMgr asserts.KeypairManager) error {
+			// pick the serial whose device key is still around
+			for _, a := range serials {
+				candidate := a.(*asserts.Serial)
+				if _, err := keypairMgr.Get(candidate.DeviceKey().ID()); err == nil {
+					serial = candidate
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil || serial == nil {
+			return err
+		}
+
+		retrieve := func(ref *asserts.Ref) (asserts.Assertion, error) {
+			return ref.Resolve(savedb.Find)
+		}
+		b := asserts.NewBatch(nil)
+		err = b.Fetch(assertstate.DB(m.state), retrieve, func(f asserts.Fetcher) error {
+			return f.Save(serial)
+		})
+		if err != nil {
+			return err
+		}
+		return assertstate.AddBatch(m.state, b, &asserts.CommitOptions{Precheck: true})
+	})
+	if err == errNoSaveSupport {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot restore serial from device save assertion database: %v", err)
+	}
+	if serial == nil {
+		return false, nil
+	}
+
+	logger.Noticef("restored serial %q from the device save assertion database", serial.Serial())
+	device.KeyID = serial.DeviceKey().ID()
+	device.Serial = serial.Serial()
+	if err := m.setDevice(device); err != nil {
+		return false, err
+	}
+	m.markRegistered()
+	// make sure we timely consider anything that was blocked on
+	// registration
+	m.state.EnsureBefore(0)
+	return true, nil
+}
+
 var startTime time.Time
 
 func init() {
@@ -962,20 +1077,34 @@ func (m *DeviceManager) ensureInstalled() error {
 		return fmt.Errorf("internal error: core device brand and model are set but there is no model assertion")
 	}
 
+	ts, err := m.runSystemTasks(model, "setup-run-system", i18n.G("Setup system for run mode"))
+	if err != nil {
+		return err
+	}
+
+	m.ensureInstalledRan = true
+
+	chg := m.state.NewChange("install-system", i18n.G("Install the system"))
+	chg.AddAll(ts)
+
+	return nil
+}
+
+// runSystemTasks returns a task set made of a task of the given kind setting up
+// the run system, followed by the gadget install-device hook, if there is one,
+// and the restart into run mode.
+func (m *DeviceManager) runSystemTasks(model *asserts.Model, setupKind, setupSummary string) (*state.TaskSet, error) {
 	// check if the gadget has an install-device hook
 	var hasInstallDeviceHook bool
 
 	gadgetInfo, err := snapstate.CurrentInfo(m.state, model.Gadget())
 	if err != nil {
-		return fmt.Errorf("internal error: device is seeded in install mode but has no gadget snap: %v", err)
+		return nil, fmt.Errorf("internal error: device is seeded in %s mode but has no gadget snap: %v", m.SystemMode(SysHasModeenv), err)
 	}
 	hasInstallDeviceHook = (gadgetInfo.Hooks["install-device"] != nil)
 
-	m.ensureInstalledRan = true
-
 	var prev *state.Task
-	setupRunSystem := m.state.NewTask("setup-run-system", i18n.G("Setup system for run mode"))
-
+	setupRunSystem := m.state.NewTask(setupKind, setupSummary)
 	tasks := []*state.Task{setupRunSystem}
 	addTask := func(t *state.Task) {
 		t.WaitFor(prev)
@@ -1006,8 +1135,55 @@ func (m *DeviceManager) ensureInstalled() error {
 		installDevice.Set("restart-task", restartSystem.ID())
 	}
 
-	chg := m.state.NewChange("install-system", i18n.G("Install the system"))
-	chg.AddAll(state.NewTaskSet(tasks...))
+	return state.NewTaskSet(tasks...), nil
+}
+
+func (m *DeviceManager) ensureFactoryReset() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	if release.OnClassic {
+		return nil
+	}
+
+	if m.ensureFactoryResetRan {
+		return nil
+	}
+
+	if m.SystemMode(SysHasModeenv) != "factory-reset" {
+		return nil
+	}
+
+	var seeded bool
+	err := m.state.Get("seeded", &seeded)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !seeded {
+		return nil
+	}
+
+	if m.changeInFlight("factory-reset") {
+		return nil
+	}
+
+	model, err := m.Model()
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("internal error: core device brand and model are set but there is no model assertion")
+	}
+
+	ts, err := m.runSystemTasks(model, "factory-reset-run-system", i18n.G("Perform factory reset of the system"))
+	if err != nil {
+		return err
+	}
+
+	m.ensureFactoryResetRan = true
+
+	chg := m.state.NewChange("factory-reset", i18n.G("Perform factory reset"))
+	chg.AddAll(ts)
 
 	return nil
 }
@@ -1208,6 +1384,10 @@ func (m *DeviceManager) Ensure() error {
 			errs = append(errs, err)
 		}
 
+		if err := m.ensureFactoryReset(); err != nil {
+			errs = append(errs, err)
+		}
+
 		if err := m.ensureTriedRecoverySystem(); err != nil {
 			errs = append(errs, err)
 		}
@@ -1431,14 +1611,17 @@ type System struct {
 
 var defaultSystemActions = []SystemAction{
 	{Title: "Install", Mode: "install"},
+	{Title: "Factory reset", Mode: "factory-reset"},
 }
 var currentSystemActions = []SystemAction{
 	{Title: "Reinstall", Mode: "install"},
 	{Title: "Recover", Mode: "recover"},
+	{Title: "Factory reset", Mode: "factory-reset"},
 	{Title: "Run normally", Mode: "run"},
 }
 var recoverSystemActions = []SystemAction{
 	{Title: "Reinstall", Mode: "install"},
+	{Title: "Factory reset", Mode: "factory-reset"},
 	{Title: "Run normally", Mode: "run"},
 }
 
@@ -1591,8 +1774,9 @@ func (m *DeviceManager) switchToSystemAndMode(systemLabel, mode string, sameSyst
 			sameSystemAndMode()
 			return nil
 		}
-	case "install":
-		// requesting system actions in install mode does not make sense atm
+	case "install", "factory-reset":
+		// requesting system actions in install or factory-reset mode does
+		// not make sense atm
 		//
 		// TODO:UC20: maybe factory hooks will be able to something like
 		// this?
@@ -24,6 +24,7 @@ package devicestate
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strconv"
 	"sync"
@@ -43,13 +44,15 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/seed"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/naming"
 )
 
 var (
-	snapstateInstallWithDeviceContext = snapstate.InstallWithDeviceContext
-	snapstateUpdateWithDeviceContext  = snapstate.UpdateWithDeviceContext
+	snapstateInstallWithDeviceContext     = snapstate.InstallWithDeviceContext
+	snapstateInstallPathWithDeviceContext = snapstate.InstallPathWithDeviceContext
+	snapstateUpdateWithDeviceContext      = snapstate.UpdateWithDeviceContext
 )
 
 // findModel returns the device model assertion.
@@ -107,6 +110,18 @@ func findSerial(st *state.State, device *auth.DeviceState) (*asserts.Serial, err
 	return a.(*asserts.Serial), nil
 }
 
+// Model returns the device model assertion, or state.ErrNoState if the
+// device has no model yet.
+func Model(st *state.State) (*asserts.Model, error) {
+	return findModel(st)
+}
+
+// Serial returns the device serial assertion, or state.ErrNoState if the
+// device is not registered yet.
+func Serial(st *state.State) (*asserts.Serial, error) {
+	return findSerial(st, nil)
+}
+
 // auto-refresh
 func canAutoRefresh(st *state.State) (bool, error) {
 	// we need to be seeded first
@@ -313,6 +328,53 @@ func CanManageRefreshes(st *state.State) bool {
 	return false
 }
 
+// UpdateKernelCommandLineAppend checks that the given kernel command line
+// arguments, appended through system configuration, are allowed by the
+// kernel-cmdline allow list of the gadget and queues a change that updates
+// the kernel command line of the run system. The change picks up the
+// arguments once the configuration carrying them has been committed and
+// requests a reboot when the kernel command line was updated.
+func UpdateKernelCommandLineAppend(st *state.State, cmdlineAppend string) error {
+	deviceCtx, err := DeviceCtx(st, nil, nil)
+	if err != nil {
+		return err
+	}
+	if deviceCtx.Model().Grade() == asserts.ModelGradeUnset {
+		if cmdlineAppend == "" {
+			return nil
+		}
+		return fmt.Errorf("cannot append kernel command line arguments on a pre-UC20 system")
+	}
+
+	var seeded bool
+	if err := st.Get("seeded", &seeded); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !seeded {
+		// the arguments are picked up with the next update of the
+		// command line
+		// TODO:UC20: apply the arguments when installing the system
+		return nil
+	}
+
+	gadgetData, err := currentGadgetInfo(st, deviceCtx)
+	if err != nil {
+		return fmt.Errorf("cannot obtain current gadget data: %v", err)
+	}
+	if gadgetData == nil {
+		return fmt.Errorf("internal error: no current gadget")
+	}
+	if _, err := gadget.CheckKernelCommandLineAppend(gadgetData.Info, cmdlineAppend); err != nil {
+		return err
+	}
+
+	chg := st.NewChange("update-kernel-cmdline", i18n.G("Update kernel command line from system configuration"))
+	t := st.NewTask("update-kernel-cmdline-append", i18n.G("Update kernel command line with arguments from system configuration"))
+	chg.AddTask(t)
+	st.EnsureBefore(0)
+	return nil
+}
+
 func getAllRequiredSnapsForModel(model *asserts.Model) *naming.SnapSet {
 	reqSnaps := model.RequiredWithEssentialSnaps()
 	return naming.NewSnapSet(reqSnaps)
@@ -350,13 +412,92 @@ func notInstalled(st *state.State, name string) (bool, error) {
 	return false, err
 }
 
-func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Model, deviceCtx snapstate.DeviceContext, fromChange string) ([]*state.TaskSet, error) {
+// localRemodelSnaps maps the names of the snaps provided as files for an
+// offline remodel to their side info and path.
+type localRemodelSnaps map[string]*localRemodelSnap
+
+type localRemodelSnap struct {
+	sideInfo *snap.SideInfo
+	path     string
+	// used is set when the remodel installs the snap from the file
+	used bool
+}
+
+// checkLocalRemodelSnaps verifies that the given local snaps, with side
+// infos derived from their assertions, are all snaps of the new model.
+func checkLocalRemodelSnaps(new *asserts.Model, localSnaps []*snap.SideInfo, paths []string) (localRemodelSnaps, error) {
+	if len(localSnaps) != len(paths) {
+		return nil, fmt.Errorf("internal error: local snaps and paths must have the same length")
+	}
+	if len(localSnaps) == 0 {
+		return nil, nil
+	}
+
+	modelSnaps := make(map[string]naming.SnapRef)
+	for _, snapRef := range new.RequiredWithEssentialSnaps() {
+		modelSnaps[snapRef.SnapName()] = snapRef
+	}
+	for _, name := range []string{new.Kernel(), new.Base(), new.Gadget()} {
+		if _, ok := modelSnaps[name]; !ok && name != "" {
+			modelSnaps[name] = naming.Snap(name)
+		}
+	}
+
+	local := make(localRemodelSnaps, len(localSnaps))
+	for i, si := range localSnaps {
+		if si.SnapID == "" || si.Revision.Unset() {
+			return nil, fmt.Errorf("cannot remodel with snap %q without assertions", si.RealName)
+		}
+		modelSnap, ok := modelSnaps[si.RealName]
+		if !ok {
+			return nil, fmt.Errorf("cannot remodel with snap %q not required by the new model", si.RealName)
+		}
+		if id := modelSnap.ID(); id != "" && id != si.SnapID {
+			return nil, fmt.Errorf("cannot remodel with snap %q: snap id %q does not match the model snap id %q", si.RealName, si.SnapID, id)
+		}
+		if _, ok := local[si.RealName]; ok {
+			return nil, fmt.Errorf("cannot remodel with snap %q provided more than once", si.RealName)
+		}
+		local[si.RealName] = &localRemodelSnap{sideInfo: si, path: paths[i]}
+	}
+	return local, nil
+}
+
+func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Model, deviceCtx snapstate.DeviceContext, fromChange string, localSnaps localRemodelSnaps) ([]*state.TaskSet, error) {
 	userID := 0
 	var tss []*state.TaskSet
 
+	// with local snaps the remodel is offline, all the snaps it
+	// needs must be provided as files
+	installFromFile := func(name string, opts *snapstate.RevisionOptions, flags snapstate.Flags) (*state.TaskSet, error) {
+		local := localSnaps[name]
+		if local == nil {
+			return nil, fmt.Errorf("cannot remodel offline: no snap file provided for %q", name)
+		}
+		flags.RemoveSnapPath = true
+		ts, err := snapstateInstallPathWithDeviceContext(st, local.sideInfo, local.path, name, opts, userID, flags, deviceCtx, fromChange)
+		if err != nil {
+			return nil, err
+		}
+		local.used = true
+		return ts, nil
+	}
+	install := func(name string, opts *snapstate.RevisionOptions, flags snapstate.Flags) (*state.TaskSet, error) {
+		if localSnaps != nil {
+			return installFromFile(name, opts, flags)
+		}
+		return snapstateInstallWithDeviceContext(ctx, st, name, opts, userID, flags, deviceCtx, fromChange)
+	}
+	update := func(name string, opts *snapstate.RevisionOptions, flags snapstate.Flags) (*state.TaskSet, error) {
+		if localSnaps != nil {
+			return installFromFile(name, opts, flags)
+		}
+		return snapstateUpdateWithDeviceContext(st, name, opts, userID, flags, deviceCtx, fromChange)
+	}
+
 	// kernel
 	if current.Kernel() == new.Kernel() && current.KernelTrack() != new.KernelTrack() {
-		ts, err := snapstateUpdateWithDeviceContext(st, new.Kernel(), &snapstate.RevisionOptions{Channel: new.KernelTrack()}, userID, snapstate.Flags{NoReRefresh: true}, deviceCtx, fromChange)
+		ts, err := update(new.Kernel(), &snapstate.RevisionOptions{Channel: new.KernelTrack()}, snapstate.Flags{NoReRefresh: true})
 		if err != nil {
 			return nil, err
 		}
@@ -370,7 +511,7 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 			return nil, err
 		}
 		if needsInstall {
-			ts, err = snapstateInstallWithDeviceContext(ctx, st, new.Kernel(), &snapstate.RevisionOptions{Channel: new.KernelTrack()}, userID, snapstate.Flags{}, deviceCtx, fromChange)
+			ts, err = install(new.Kernel(), &snapstate.RevisionOptions{Channel: new.KernelTrack()}, snapstate.Flags{})
 		} else {
 			ts, err = snapstate.LinkNewBaseOrKernel(st, new.Base())
 		}
@@ -385,7 +526,7 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 			return nil, err
 		}
 		if needsInstall {
-			ts, err = snapstateInstallWithDeviceContext(ctx, st, new.Base(), nil, userID, snapstate.Flags{}, deviceCtx, fromChange)
+			ts, err = install(new.Base(), nil, snapstate.Flags{})
 		} else {
 			ts, err = snapstate.LinkNewBaseOrKernel(st, new.Base())
 		}
@@ -396,14 +537,14 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 	}
 	// gadget
 	if current.Gadget() == new.Gadget() && current.GadgetTrack() != new.GadgetTrack() {
-		ts, err := snapstateUpdateWithDeviceContext(st, new.Gadget(), &snapstate.RevisionOptions{Channel: new.GadgetTrack()}, userID, snapstate.Flags{NoReRefresh: true}, deviceCtx, fromChange)
+		ts, err := update(new.Gadget(), &snapstate.RevisionOptions{Channel: new.GadgetTrack()}, snapstate.Flags{NoReRefresh: true})
 		if err != nil {
 			return nil, err
 		}
 		tss = append(tss, ts)
 	}
 	if current.Gadget() != new.Gadget() {
-		ts, err := snapstateInstallWithDeviceContext(ctx, st, new.Gadget(), &snapstate.RevisionOptions{Channel: new.GadgetTrack()}, userID, snapstate.Flags{}, deviceCtx, fromChange)
+		ts, err := install(new.Gadget(), &snapstate.RevisionOptions{Channel: new.GadgetTrack()}, snapstate.Flags{})
 		if err != nil {
 			return nil, err
 		}
@@ -421,7 +562,7 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 		}
 		if needsInstall {
 			// If the snap is not installed we need to install it now.
-			ts, err := snapstateInstallWithDeviceContext(ctx, st, snapRef.SnapName(), nil, userID, snapstate.Flags{Required: true}, deviceCtx, fromChange)
+			ts, err := install(snapRef.SnapName(), nil, snapstate.Flags{Required: true})
 			if err != nil {
 				return nil, err
 			}
@@ -562,19 +703,11 @@ func AllowUC20RemodelTesting(allow bool) (restore func()) {
 	}
 }
 
-// Remodel takes a new model assertion and generates a change that
-// takes the device from the old to the new model or an error if the
-// transition is not possible.
-//
-// TODO:
-// - Check estimated disk size delta
-// - Check all relevant snaps exist in new store
-//   (need to check that even unchanged snaps are accessible)
-// - Make sure this works with Core 20 as well, in the Core 20 case
-//   we must enforce the default-channels from the model as well
-func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
+// checkRemodel verifies that the device can be remodeled to the given new
+// model and returns the current model.
+func checkRemodel(st *state.State, new *asserts.Model) (current *asserts.Model, err error) {
 	var seeded bool
-	err := st.Get("seeded", &seeded)
+	err = st.Get("seeded", &seeded)
 	if err != nil && err != state.ErrNoState {
 		return nil, err
 	}
@@ -582,7 +715,7 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 		return nil, fmt.Errorf("cannot remodel until fully seeded")
 	}
 
-	current, err := findModel(st)
+	current, err = findModel(st)
 	if err != nil {
 		return nil, err
 	}
@@ -618,8 +751,6 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 	// model transitions before we allow cross vault
 	// transitions.
 
-	remodelKind := ClassifyRemodel(current, new)
-
 	// TODO: should we restrict remodel from one arch to another?
 	// There are valid use-cases here though, i.e. amd64 machine that
 	// remodels itself to/from i386 (if the HW can do both 32/64 bit)
@@ -633,6 +764,47 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 		return nil, fmt.Errorf("cannot remodel from core to bases yet")
 	}
 
+	return current, nil
+}
+
+// Remodel takes a new model assertion and generates a change that
+// takes the device from the old to the new model or an error if the
+// transition is not possible.
+//
+// TODO:
+// - Check estimated disk size delta
+// - Check all relevant snaps exist in new store
+//   (need to check that even unchanged snaps are accessible)
+// - Make sure this works with Core 20 as well, in the Core 20 case
+//   we must enforce the default-channels from the model as well
+func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
+	return RemodelWithLocalSnaps(st, new, nil, nil)
+}
+
+// RemodelWithLocalSnaps is like Remodel but takes the snaps needed by the
+// new model from the given local files instead of from the store, so
+// that the remodel does not need network access. The side infos must be
+// derived from the assertions of the snaps, and every snap that the
+// remodel installs or refreshes must be provided. The files are removed
+// once installed, the files of snaps that the remodel does not need, like
+// snaps that are already installed, are removed when setting up the
+// change. On error the files are left for the caller to remove.
+func RemodelWithLocalSnaps(st *state.State, new *asserts.Model, localSnaps []*snap.SideInfo, paths []string) (*state.Change, error) {
+	current, err := checkRemodel(st, new)
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := checkLocalRemodelSnaps(new, localSnaps, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	remodelKind := ClassifyRemodel(current, new)
+	if local != nil && remodelKind == ReregRemodel {
+		return nil, fmt.Errorf("cannot remodel offline to a model that requires a new serial")
+	}
+
 	// Do we do this only for the more complicated cases (anything
 	// more than adding required-snaps really)?
 	if err := snapstate.CheckChangeConflictRunExclusively(st, "remodel"); err != nil {
@@ -658,17 +830,20 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 		if sto == nil {
 			return nil, fmt.Errorf("internal error: a store switch remodeling should have built a store")
 		}
-		// ensure a new session accounting for the new brand store
-		st.Unlock()
-		_, err := sto.EnsureDeviceSession()
-		st.Lock()
-		if err != nil {
-			return nil, fmt.Errorf("cannot get a store session based on the new model assertion: %v", err)
+		// ensure a new session accounting for the new brand store,
+		// when offline it is obtained the next time the store is used
+		if local == nil {
+			st.Unlock()
+			_, err := sto.EnsureDeviceSession()
+			st.Lock()
+			if err != nil {
+				return nil, fmt.Errorf("cannot get a store session based on the new model assertion: %v", err)
+			}
 		}
 		fallthrough
 	case UpdateRemodel:
 		var err error
-		tss, err = remodelTasks(context.TODO(), st, current, new, remodCtx, "")
+		tss, err = remodelTasks(context.TODO(), st, current, new, remodCtx, "", local)
 		if err != nil {
 			return nil, err
 		}
@@ -702,6 +877,17 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 		chg.AddAll(ts)
 	}
 
+	// nothing refers to the files of the snaps that are not used by
+	// the change anymore
+	for name, localSnap := range local {
+		if localSnap.used {
+			continue
+		}
+		if err := os.Remove(localSnap.path); err != nil && !os.IsNotExist(err) {
+			logger.Noticef("cannot remove unused file of snap %q: %v", name, err)
+		}
+	}
+
 	return chg, nil
 }
 
@@ -800,3 +986,70 @@ func CreateRecoverySystem(st *state.State, label string) (*state.Change, error)
 	chg.AddAll(ts)
 	return chg, nil
 }
+
+// RemoveRecoverySystem creates a change removing the recovery system with the
+// given label from the seed, along with the seed snaps no other recovery
+// system uses. The current and the last good recovery system cannot be
+// removed.
+func RemoveRecoverySystem(st *state.State, label string) (*state.Change, error) {
+	if release.OnClassic {
+		// TODO: this may need to be lifted in the future
+		return nil, fmt.Errorf("cannot remove recovery systems on a classic system")
+	}
+	var seeded bool
+	err := st.Get("seeded", &seeded)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if !seeded {
+		return nil, fmt.Errorf("cannot remove recovery systems until fully seeded")
+	}
+
+	// the label ends up in the path of the directory being removed
+	if err := seed.ValidateUC20SeedSystemLabel(label); err != nil {
+		return nil, fmt.Errorf("cannot remove recovery system: %v", err)
+	}
+
+	systemDirectory := filepath.Join(boot.InitramfsUbuntuSeedDir, "systems", label)
+	exists, _, err := osutil.DirExists(systemDirectory)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("recovery system %q does not exist", label)
+	}
+
+	var whatseeded []seededSystem
+	if err := st.Get("seeded-systems", &whatseeded); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if len(whatseeded) > 0 && whatseeded[0].System == label {
+		return nil, fmt.Errorf("cannot remove the current recovery system %q", label)
+	}
+	modeEnv, err := maybeReadModeenv()
+	if err != nil {
+		return nil, err
+	}
+	if modeEnv == nil {
+		return nil, fmt.Errorf("cannot remove recovery systems without a modeenv")
+	}
+	if modeEnv.RecoverySystem == label {
+		return nil, fmt.Errorf("cannot remove the current recovery system %q", label)
+	}
+	if len(modeEnv.GoodRecoverySystems) == 1 && modeEnv.GoodRecoverySystems[0] == label {
+		return nil, fmt.Errorf("cannot remove the last good recovery system %q", label)
+	}
+
+	if err := snapstate.CheckChangeConflictRunExclusively(st, "remove-recovery-system"); err != nil {
+		return nil, err
+	}
+
+	chg := st.NewChange("remove-recovery-system", fmt.Sprintf("Remove recovery system with label %q", label))
+	remove := st.NewTask("remove-recovery-system", fmt.Sprintf("Remove recovery system with label %q", label))
+	remove.Set("recovery-system-setup", &recoverySystemSetup{
+		Label:     label,
+		Directory: systemDirectory,
+	})
+	chg.AddTask(remove)
+	return chg, nil
+}
@@ -38,8 +38,10 @@ import (
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
 	"github.com/snapcore/snapd/overlord/state"
@@ -1254,6 +1256,283 @@ func (s *deviceMgrGadgetSuite) TestUpdateGadgetCommandlineWithNewArgs(c *C) {
 	})
 }
 
+var gadgetYamlWithCmdlineAllow = gadgetYaml + `
+kernel-cmdline:
+  allow:
+    - isolcpus=*
+`
+
+func (s *deviceMgrGadgetSuite) TestUpdateGadgetCommandlineWithArgsFromConfig(c *C) {
+	bootloader.Force(s.managedbl)
+	s.state.Lock()
+	s.setupUC20ModelWithGadget(c, "pc")
+	s.mockModeenvForMode(c, "run")
+	devicestate.SetBootOkRan(s.mgr, true)
+	s.state.Set("seeded", true)
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "system.kernel.cmdline-append", "isolcpus=1-3"), IsNil)
+	tr.Commit()
+
+	m, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	m.CurrentKernelCommandLines = []string{
+		"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1 isolcpus=1-3",
+	}
+	c.Assert(m.Write(), IsNil)
+	err = s.managedbl.SetBootVars(map[string]string{
+		"snapd_extra_cmdline_args": "isolcpus=1-3",
+	})
+	c.Assert(err, IsNil)
+	s.managedbl.SetBootVarsCalls = 0
+
+	s.state.Unlock()
+
+	const update = true
+	s.testGadgetCommandlineUpdateRun(c,
+		[][]string{
+			{"meta/gadget.yaml", gadgetYamlWithCmdlineAllow},
+		},
+		[][]string{
+			{"meta/gadget.yaml", gadgetYamlWithCmdlineAllow},
+			{"cmdline.extra", "args from new gadget"},
+		},
+		"", "Updated kernel command line", update)
+
+	m, err = boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check([]string(m.CurrentKernelCommandLines), DeepEquals, []string{
+		"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1 isolcpus=1-3",
+		// arguments from config are kept after the gadget ones
+		"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1 args from new gadget isolcpus=1-3",
+	})
+	vars, err := s.managedbl.GetBootVars("snapd_extra_cmdline_args")
+	c.Assert(err, IsNil)
+	c.Assert(vars, DeepEquals, map[string]string{
+		"snapd_extra_cmdline_args": "args from new gadget isolcpus=1-3",
+	})
+}
+
+func (s *deviceMgrGadgetSuite) TestUpdateGadgetCommandlineWithArgsFromConfigNotAllowed(c *C) {
+	bootloader.Force(s.managedbl)
+	s.state.Lock()
+	s.setupUC20ModelWithGadget(c, "pc")
+	s.mockModeenvForMode(c, "run")
+	devicestate.SetBootOkRan(s.mgr, true)
+	s.state.Set("seeded", true)
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "system.kernel.cmdline-append", "isolcpus=1-3"), IsNil)
+	tr.Commit()
+	s.state.Unlock()
+
+	s.testGadgetCommandlineUpdateRun(c,
+		[][]string{
+			{"meta/gadget.yaml", gadgetYamlWithCmdlineAllow},
+		},
+		[][]string{
+			// new gadget no longer allows the argument
+			{"meta/gadget.yaml", gadgetYaml},
+			{"cmdline.extra", "args from new gadget"},
+		},
+		`(?s).*cannot use kernel command line arguments from system configuration: kernel argument "isolcpus=1-3" is not allowed by the gadget.*`,
+		"", false)
+}
+
+func (s *deviceMgrGadgetSuite) mockCurrentGadgetWithFiles(c *C, files [][]string) {
+	si := &snap.SideInfo{
+		RealName: "pc",
+		Revision: snap.R(33),
+		SnapID:   "foo-id",
+	}
+	snapstate.Set(s.state, "pc", &snapstate.SnapState{
+		SnapType: "gadget",
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		Active:   true,
+	})
+	snaptest.MockSnapWithFiles(c, pcGadgetSnapYaml, si, files)
+}
+
+func (s *deviceMgrGadgetSuite) setKernelCommandLineAppendConfig(c *C, cmdlineAppend string) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "system.kernel.cmdline-append", cmdlineAppend), IsNil)
+	tr.Commit()
+}
+
+func (s *deviceMgrGadgetSuite) TestUpdateKernelCommandLineAppend(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	bootloader.Force(s.managedbl)
+	s.state.Lock()
+	s.setupUC20ModelWithGadget(c, "pc")
+	s.mockModeenvForMode(c, "run")
+	devicestate.SetBootOkRan(s.mgr, true)
+	s.state.Set("seeded", true)
+	s.mockCurrentGadgetWithFiles(c, [][]string{
+		{"meta/gadget.yaml", gadgetYamlWithCmdlineAllow},
+	})
+
+	m, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	m.CurrentKernelCommandLines = []string{
+		"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1",
+	}
+	c.Assert(m.Write(), IsNil)
+
+	err = devicestate.UpdateKernelCommandLineAppend(s.state, "  isolcpus=1-3 ")
+	c.Assert(err, IsNil)
+	// the configuration carrying the arguments gets committed
+	s.setKernelCommandLineAppendConfig(c, "  isolcpus=1-3 ")
+
+	chgs := s.state.Changes()
+	c.Assert(chgs, HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), Equals, "update-kernel-cmdline")
+	tsks := chg.Tasks()
+	c.Assert(tsks, HasLen, 1)
+	c.Check(tsks[0].Kind(), Equals, "update-kernel-cmdline-append")
+	// nothing is applied until the task runs
+	c.Check(s.managedbl.SetBootVarsCalls, Equals, 0)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.IsReady(), Equals, true)
+	c.Check(chg.Err(), IsNil)
+	c.Check(tsks[0].Status(), Equals, state.DoneStatus)
+	c.Check(s.restartRequests, DeepEquals, []state.RestartType{state.RestartSystem})
+
+	m, err = boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check([]string(m.CurrentKernelCommandLines), DeepEquals, []string{
+		"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1",
+		"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1 isolcpus=1-3",
+	})
+	vars, err := s.managedbl.GetBootVars("snapd_extra_cmdline_args")
+	c.Assert(err, IsNil)
+	c.Assert(vars, DeepEquals, map[string]string{
+		"snapd_extra_cmdline_args": "isolcpus=1-3",
+	})
+
+	// pretend we rebooted with the new command line
+	m.CurrentKernelCommandLines = []string{
+		"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1 isolcpus=1-3",
+	}
+	c.Assert(m.Write(), IsNil)
+	s.restartRequests = nil
+
+	// the same arguments again do not require a reboot
+	err = devicestate.UpdateKernelCommandLineAppend(s.state, "isolcpus=1-3")
+	c.Assert(err, IsNil)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	c.Assert(s.state.Changes(), HasLen, 2)
+	for _, chg := range s.state.Changes() {
+		c.Check(chg.Status(), Equals, state.DoneStatus)
+	}
+	c.Check(s.restartRequests, HasLen, 0)
+}
+
+func (s *deviceMgrGadgetSuite) TestUpdateKernelCommandLineAppendConfigNotCommitted(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	bootloader.Force(s.managedbl)
+	s.state.Lock()
+	s.setupUC20ModelWithGadget(c, "pc")
+	s.mockModeenvForMode(c, "run")
+	devicestate.SetBootOkRan(s.mgr, true)
+	s.state.Set("seeded", true)
+	s.mockCurrentGadgetWithFiles(c, [][]string{
+		{"meta/gadget.yaml", gadgetYamlWithCmdlineAllow},
+	})
+
+	m, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	m.CurrentKernelCommandLines = []string{
+		"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1",
+	}
+	c.Assert(m.Write(), IsNil)
+
+	// the configuration that queued the update fails and is never
+	// committed
+	err = devicestate.UpdateKernelCommandLineAppend(s.state, "isolcpus=1-3")
+	c.Assert(err, IsNil)
+	c.Assert(s.state.Changes(), HasLen, 1)
+	chg := s.state.Changes()[0]
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.IsReady(), Equals, true)
+	c.Check(chg.Err(), IsNil)
+	c.Check(s.restartRequests, HasLen, 0)
+	c.Check(s.managedbl.SetBootVarsCalls, Equals, 0)
+
+	m, err = boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check([]string(m.CurrentKernelCommandLines), DeepEquals, []string{
+		"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1",
+	})
+}
+
+func (s *deviceMgrGadgetSuite) TestUpdateKernelCommandLineAppendErrors(c *C) {
+	bootloader.Force(s.managedbl)
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupUC20ModelWithGadget(c, "pc")
+	s.mockModeenvForMode(c, "run")
+	s.state.Set("seeded", true)
+	s.mockCurrentGadgetWithFiles(c, [][]string{
+		{"meta/gadget.yaml", gadgetYamlWithCmdlineAllow},
+	})
+
+	err := devicestate.UpdateKernelCommandLineAppend(s.state, "isolcpus=1-3 quiet")
+	c.Assert(err, ErrorMatches, `kernel argument "quiet" is not allowed by the gadget`)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *deviceMgrGadgetSuite) TestUpdateKernelCommandLineAppendNotSeeded(c *C) {
+	bootloader.Force(s.managedbl)
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupUC20ModelWithGadget(c, "pc")
+	s.mockModeenvForMode(c, "run")
+
+	err := devicestate.UpdateKernelCommandLineAppend(s.state, "isolcpus=1-3")
+	c.Assert(err, IsNil)
+	c.Check(s.state.Changes(), HasLen, 0)
+	c.Check(s.managedbl.SetBootVarsCalls, Equals, 0)
+}
+
+func (s *deviceMgrGadgetSuite) TestKernelCmdlineAppendUpdateBlockedByCoreConfigure(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tUpdate := s.state.NewTask("update-kernel-cmdline-append", "update kernel command line")
+	tConfigure := s.state.NewTask("run-hook", "run configure hook")
+	tConfigure.Set("hook-setup", &hookstate.HookSetup{Snap: "core", Hook: "configure"})
+	tOtherHook := s.state.NewTask("run-hook", "run other configure hook")
+	tOtherHook.Set("hook-setup", &hookstate.HookSetup{Snap: "foo", Hook: "configure"})
+	tOther := s.state.NewTask("other-task", "other")
+
+	c.Check(devicestate.KernelCmdlineAppendUpdateBlocked(tUpdate, nil), Equals, false)
+	c.Check(devicestate.KernelCmdlineAppendUpdateBlocked(tUpdate, []*state.Task{tOther, tOtherHook}), Equals, false)
+	// blocked while the core configuration is being applied
+	c.Check(devicestate.KernelCmdlineAppendUpdateBlocked(tUpdate, []*state.Task{tOther, tConfigure}), Equals, true)
+	// other tasks are not affected
+	c.Check(devicestate.KernelCmdlineAppendUpdateBlocked(tOther, []*state.Task{tConfigure}), Equals, false)
+}
+
 func (s *deviceMgrGadgetSuite) TestUpdateGadgetCommandlineDroppedArgs(c *C) {
 	// no command line arguments prior to the gadget up
 	s.state.Lock()
@@ -1548,3 +1548,195 @@ mock output of: snap debug timings 2
 		{"snap", "debug", "timings", "2"},
 	})
 }
+
+func (s *deviceMgrInstallModeSuite) findFactoryReset() *state.Change {
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "factory-reset" {
+			return chg
+		}
+	}
+	return nil
+}
+
+func (s *deviceMgrInstallModeSuite) TestFactoryResetExpTasks(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	restore = devicestate.MockInstallRun(func(mod gadget.Model, gadgetRoot, kernelRoot, device string, options install.Options, _ gadget.ContentObserver, _ timings.Measurer) (*install.InstalledSystemSideData, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})
+	defer restore()
+	factoryResetCalls := 0
+	restore = devicestate.MockInstallFactoryReset(func(mod gadget.Model, gadgetRoot, kernelRoot, device string, options install.Options, _ gadget.ContentObserver, _ timings.Measurer) (*install.InstalledSystemSideData, error) {
+		// ensure we can grab the lock here, i.e. that it's not taken
+		s.state.Lock()
+		s.state.Unlock()
+
+		c.Check(gadgetRoot, Equals, filepath.Join(dirs.SnapMountDir, "/pc/1"))
+		c.Check(options, DeepEquals, install.Options{Mount: true})
+		factoryResetCalls++
+		return nil, nil
+	})
+	defer restore()
+
+	err := ioutil.WriteFile(filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd/modeenv"),
+		[]byte("mode=factory-reset\nrecovery_system=20191218\n"), 0644)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	s.makeMockInstalledPcGadget(c, "dangerous", "", "")
+	devicestate.SetSystemMode(s.mgr, "factory-reset")
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.findInstallSystem(), IsNil)
+	factoryReset := s.findFactoryReset()
+	c.Assert(factoryReset, NotNil)
+	c.Check(factoryReset.Err(), IsNil)
+	c.Check(factoryReset.Status(), Equals, state.DoneStatus)
+
+	tasks := factoryReset.Tasks()
+	c.Assert(tasks, HasLen, 2)
+	factoryResetTask := tasks[0]
+	restartSystemToRunModeTask := tasks[1]
+
+	c.Assert(factoryResetTask.Kind(), Equals, "factory-reset-run-system")
+	c.Assert(restartSystemToRunModeTask.Kind(), Equals, "restart-system-to-run-mode")
+	c.Assert(restartSystemToRunModeTask.WaitTasks(), DeepEquals, []*state.Task{factoryResetTask})
+
+	c.Check(factoryResetCalls, Equals, 1)
+	// the run system knows that it comes from a factory reset
+	c.Check(filepath.Join(boot.InstallHostWritableDir, "var/lib/snapd/device/factory-reset"), testutil.FilePresent)
+	// we did request a restart through restartSystemToRunModeTask
+	c.Check(s.restartRequests, DeepEquals, []state.RestartType{state.RestartSystemNow})
+}
+
+func (s *deviceMgrInstallModeSuite) TestFactoryResetEncryptedKeepsSaveKey(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	restore = devicestate.MockInstallFactoryReset(func(mod gadget.Model, gadgetRoot, kernelRoot, device string, options install.Options, obs gadget.ContentObserver, _ timings.Measurer) (*install.InstalledSystemSideData, error) {
+		c.Check(options, DeepEquals, install.Options{Mount: true, Encrypt: true})
+		c.Check(obs, NotNil)
+		return &install.InstalledSystemSideData{
+			KeysForRoles: map[string]*install.EncryptionKeySet{
+				gadget.SystemData: {
+					Key:         dataEncryptionKey,
+					RecoveryKey: dataRecoveryKey,
+				},
+				// only the key of the preserved ubuntu-save is known
+				gadget.SystemSave: {
+					Key: saveKey,
+				},
+			},
+		}, nil
+	})
+	defer restore()
+	restore = devicestate.MockSecbootCheckTPMKeySealingSupported(func() error { return nil })
+	defer restore()
+	restore = devicestate.MockBootMakeSystemRunnable(func(model *asserts.Model, bootWith *boot.BootableSet, seal *boot.TrustedAssetsInstallObserver) error {
+		c.Check(bootWith.RecoverySystemDir, Equals, "/systems/20191218")
+		c.Check(seal, NotNil)
+		return nil
+	})
+	defer restore()
+
+	tab := bootloadertest.Mock("trusted", c.MkDir()).WithTrustedAssets()
+	tab.TrustedAssetsList = []string{"trusted-asset"}
+	bootloader.Force(tab)
+	defer bootloader.Force(nil)
+	err := os.MkdirAll(boot.InitramfsUbuntuSeedDir, 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(boot.InitramfsUbuntuSeedDir, "trusted-asset"), nil, 0644)
+	c.Assert(err, IsNil)
+
+	modeenv := boot.Modeenv{
+		Mode:           "factory-reset",
+		RecoverySystem: "20191218",
+	}
+	c.Assert(modeenv.WriteTo(""), IsNil)
+
+	// normally done by snap-bootstrap
+	err = os.MkdirAll(boot.InitramfsUbuntuBootDir, 0755)
+	c.Assert(err, IsNil)
+	// the marker of the previous install
+	err = os.MkdirAll(boot.InstallHostFDESaveDir, 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(boot.InstallHostFDESaveDir, "marker"), []byte("old-marker"), 0600)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	s.makeMockInstalledPcGadget(c, "secured", "", "")
+	devicestate.SetSystemMode(s.mgr, "factory-reset")
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	factoryReset := s.findFactoryReset()
+	c.Assert(factoryReset, NotNil)
+	c.Assert(factoryReset.Err(), IsNil)
+
+	c.Check(filepath.Join(boot.InstallHostFDEDataDir, "recovery.key"), testutil.FileEquals, dataRecoveryKey[:])
+	c.Check(filepath.Join(boot.InstallHostFDEDataDir, "ubuntu-save.key"), testutil.FileEquals, []byte(saveKey))
+	c.Check(filepath.Join(boot.InstallHostFDEDataDir, "reinstall.key"), testutil.FileAbsent)
+	// data and save are paired again
+	marker, err := ioutil.ReadFile(filepath.Join(boot.InstallHostFDEDataDir, "marker"))
+	c.Assert(err, IsNil)
+	c.Check(marker, HasLen, 32)
+	c.Check(filepath.Join(boot.InstallHostFDESaveDir, "marker"), testutil.FileEquals, marker)
+}
+
+func (s *deviceMgrInstallModeSuite) TestFactoryResetError(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	restore = devicestate.MockInstallFactoryReset(func(mod gadget.Model, gadgetRoot, kernelRoot, device string, options install.Options, _ gadget.ContentObserver, _ timings.Measurer) (*install.InstalledSystemSideData, error) {
+		return nil, fmt.Errorf("cannot find partition for role \"system-save\" on disk")
+	})
+	defer restore()
+
+	err := ioutil.WriteFile(filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd/modeenv"),
+		[]byte("mode=factory-reset\nrecovery_system=20191218\n"), 0644)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	s.makeMockInstalledPcGadget(c, "dangerous", "", "")
+	devicestate.SetSystemMode(s.mgr, "factory-reset")
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	factoryReset := s.findFactoryReset()
+	c.Assert(factoryReset, NotNil)
+	c.Check(factoryReset.Err(), ErrorMatches, `(?s).*\(cannot perform factory reset: cannot find partition for role "system-save" on disk\)`)
+	// no restart
+	c.Check(s.restartRequests, HasLen, 0)
+}
+
+func (s *deviceMgrInstallModeSuite) TestFactoryResetNotInFactoryResetModeNoChg(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	s.state.Lock()
+	devicestate.SetSystemMode(s.mgr, "")
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the factory-reset change is *not* created (not in factory-reset mode)
+	c.Check(s.findFactoryReset(), IsNil)
+}
@@ -35,7 +35,9 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/gadget/edition"
 	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/assertstate/assertstatetest"
@@ -46,11 +48,14 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storecontext"
+	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapfile"
 	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/store/storetest"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type deviceMgrRemodelSuite struct {
@@ -334,7 +339,7 @@ func (s *deviceMgrRemodelSuite) testRemodelTasksSwitchTrack(c *C, whatRefreshes
 
 	testDeviceCtx = &snapstatetest.TrivialDeviceContext{Remodeling: true}
 
-	tss, err := devicestate.RemodelTasks(context.Background(), s.state, current, new, testDeviceCtx, "99")
+	tss, err := devicestate.RemodelTasks(context.Background(), s.state, current, new, testDeviceCtx, "99", nil)
 	c.Assert(err, IsNil)
 	// 2 snaps, plus one track switch plus the remodel task, the
 	// wait chain is tested in TestRemodel*
@@ -409,7 +414,7 @@ func (s *deviceMgrRemodelSuite) testRemodelSwitchTasks(c *C, whatsNew, whatNewTr
 
 	testDeviceCtx = &snapstatetest.TrivialDeviceContext{Remodeling: true}
 
-	tss, err := devicestate.RemodelTasks(context.Background(), s.state, current, new, testDeviceCtx, "99")
+	tss, err := devicestate.RemodelTasks(context.Background(), s.state, current, new, testDeviceCtx, "99", nil)
 	c.Assert(err, IsNil)
 	// 1 of switch-kernel/base/gadget plus the remodel task
 	c.Assert(tss, HasLen, 2)
@@ -1292,9 +1297,41 @@ func (s *deviceMgrRemodelSuite) TestCheckGadgetRemodelCompatibleWithYamlHappy(c
 }
 
 func (s *deviceMgrRemodelSuite) TestCheckGadgetRemodelCompatibleWithYamlBad(c *C) {
+	mockCurrentGadgetYaml := `
+type: gadget
+name: gadget
+volumes:
+  volume:
+    schema: gpt
+    bootloader: grub
+    structure:
+      - name: foo
+        size: 20M
+        filesystem: ext4
+        type: 00000000-0000-0000-0000-0000deadbeef
+`
 	mockBadGadgetYaml := `
 type: gadget
 name: gadget
+volumes:
+  volume:
+    schema: gpt
+    bootloader: grub
+    structure:
+      - name: foo
+        size: 10M
+        filesystem: ext4
+        type: 00000000-0000-0000-0000-0000deadbeef
+`
+
+	errMatch := `cannot remodel to an incompatible gadget: incompatible layout change: incompatible structure #0 \("foo"\) change: cannot shrink filesystem structure from 20971520 to 10485760`
+	s.testCheckGadgetRemodelCompatibleWithYaml(c, mockCurrentGadgetYaml, mockBadGadgetYaml, errMatch)
+}
+
+func (s *deviceMgrRemodelSuite) TestCheckGadgetRemodelCompatibleWithYamlGrowPartition(c *C) {
+	mockGrownGadgetYaml := `
+type: gadget
+name: gadget
 volumes:
   volume:
     schema: gpt
@@ -1305,8 +1342,7 @@ volumes:
         type: 00000000-0000-0000-0000-0000deadbeef
 `
 
-	errMatch := `cannot remodel to an incompatible gadget: incompatible layout change: incompatible structure #0 \("foo"\) change: cannot change structure size from 10485760 to 20971520`
-	s.testCheckGadgetRemodelCompatibleWithYaml(c, compatibleTestMockOkGadget, mockBadGadgetYaml, errMatch)
+	s.testCheckGadgetRemodelCompatibleWithYaml(c, compatibleTestMockOkGadget, mockGrownGadgetYaml, "")
 }
 
 func (s *deviceMgrRemodelSuite) TestRemodelGadgetAssetsUpdate(c *C) {
@@ -1643,7 +1679,7 @@ func (s *deviceMgrSuite) TestRemodelSwitchBase(c *C) {
 
 	testDeviceCtx = &snapstatetest.TrivialDeviceContext{Remodeling: true}
 
-	tss, err := devicestate.RemodelTasks(context.Background(), s.state, current, new, testDeviceCtx, "99")
+	tss, err := devicestate.RemodelTasks(context.Background(), s.state, current, new, testDeviceCtx, "99", nil)
 	c.Assert(err, IsNil)
 	// 1 switch to a new base plus the remodel task
 	c.Assert(tss, HasLen, 2)
@@ -1975,3 +2011,650 @@ func (s *deviceMgrRemodelSuite) TestRemodelUC20LabelConflictsError(c *C) {
 		expectedErr:      fmt.Sprintf(`cannot select non-conflicting label for recovery system "%[1]s": stat .*/run/mnt/ubuntu-seed/systems/%[1]s: permission denied`, nowLabel),
 	})
 }
+
+type remodelPlanStore struct {
+	storetest.Store
+
+	unavailable map[string]error
+	gadgetSnap  string
+
+	actions             []*store.SnapAction
+	downloads           []string
+	ensureDeviceSession int
+}
+
+func (sto *remodelPlanStore) EnsureDeviceSession() (*auth.DeviceState, error) {
+	sto.ensureDeviceSession++
+	return nil, nil
+}
+
+func (sto *remodelPlanStore) SnapAction(ctx context.Context, currentSnaps []*store.CurrentSnap, actions []*store.SnapAction, assertQuery store.AssertionQuery, user *auth.UserState, opts *store.RefreshOptions) ([]store.SnapActionResult, []store.AssertionResult, error) {
+	sto.actions = append(sto.actions, actions...)
+
+	var results []store.SnapActionResult
+	installErrs := make(map[string]error)
+	for _, a := range actions {
+		if err := sto.unavailable[a.InstanceName]; err != nil {
+			installErrs[a.InstanceName] = err
+			continue
+		}
+		info := &snap.Info{
+			SideInfo: snap.SideInfo{
+				RealName: a.InstanceName,
+				Revision: snap.R(10),
+			},
+		}
+		results = append(results, store.SnapActionResult{Info: info})
+	}
+	if len(installErrs) > 0 {
+		return results, nil, &store.SnapActionError{Install: installErrs}
+	}
+	return results, nil, nil
+}
+
+func (sto *remodelPlanStore) Download(ctx context.Context, name string, targetFn string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *store.DownloadOptions) error {
+	sto.downloads = append(sto.downloads, name)
+	content, err := ioutil.ReadFile(sto.gadgetSnap)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(targetFn, content, 0644)
+}
+
+func (s *deviceMgrRemodelSuite) setupRemodelPlan(c *C) *remodelPlanStore {
+	s.state.Set("seeded", true)
+	s.state.Set("refresh-privacy-key", "some-privacy-key")
+
+	s.makeModelAssertionInState(c, "canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+	})
+	s.makeSerialAssertionInState(c, "canonical", "pc-model", "1234")
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand:  "canonical",
+		Model:  "pc-model",
+		Serial: "1234",
+	})
+
+	for _, sn := range []struct {
+		name     string
+		snapType snap.Type
+		channel  string
+	}{
+		{"pc-kernel", snap.TypeKernel, "18/stable"},
+		{"pc", snap.TypeGadget, "18/stable"},
+		{"core18", snap.TypeBase, "latest/stable"},
+	} {
+		si := &snap.SideInfo{RealName: sn.name, Revision: snap.R(1)}
+		snapstate.Set(s.state, sn.name, &snapstate.SnapState{
+			SnapType:        string(sn.snapType),
+			Sequence:        []*snap.SideInfo{si},
+			Current:         si.Revision,
+			Active:          true,
+			TrackingChannel: sn.channel,
+		})
+	}
+
+	sto := &remodelPlanStore{}
+	snapstate.ReplaceStore(s.state, sto)
+	return sto
+}
+
+func (s *deviceMgrRemodelSuite) TestPlanRemodelUnhappyNotSeeded(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", false)
+
+	newModel := s.brands.Model("canonical", "pc", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+	_, err := devicestate.PlanRemodel(s.state, newModel)
+	c.Assert(err, ErrorMatches, "cannot remodel until fully seeded")
+}
+
+func (s *deviceMgrRemodelSuite) TestPlanRemodelRequiredSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	sto := s.setupRemodelPlan(c)
+
+	restore := devicestate.MockSnapstateInstallWithDeviceContext(func(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Check(flags.Required, Equals, true)
+		c.Check(deviceCtx.ForRemodeling(), Equals, true)
+
+		tDownload := st.NewTask("fake-download", fmt.Sprintf("Download %s", name))
+		tValidate := st.NewTask("validate-snap", fmt.Sprintf("Validate %s", name))
+		tValidate.WaitFor(tDownload)
+		tInstall := st.NewTask("fake-install", fmt.Sprintf("Install %s", name))
+		tInstall.WaitFor(tValidate)
+		ts := state.NewTaskSet(tDownload, tValidate, tInstall)
+		ts.MarkEdge(tValidate, snapstate.DownloadAndChecksDoneEdge)
+		return ts, nil
+	})
+	defer restore()
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture":   "amd64",
+		"kernel":         "pc-kernel",
+		"gadget":         "pc",
+		"base":           "core18",
+		"required-snaps": []interface{}{"new-required-snap-1"},
+		"revision":       "1",
+	})
+	plan, err := devicestate.PlanRemodel(s.state, new)
+	c.Assert(err, IsNil)
+
+	c.Check(plan.Kind, Equals, "revision update remodel")
+	c.Check(plan.StoreChecked, Equals, true)
+	c.Check(plan.RebootRequired, Equals, false)
+	c.Check(plan.Problems, HasLen, 0)
+	c.Check(plan.Snaps, DeepEquals, []devicestate.PlannedRemodelSnap{
+		{Name: "pc-kernel", Role: "kernel", Channel: "18/stable", Action: "none", Revision: snap.R(10)},
+		{Name: "core18", Role: "base", Channel: "latest/stable", Action: "none", Revision: snap.R(10)},
+		{Name: "pc", Role: "gadget", Channel: "18/stable", Action: "none", Revision: snap.R(10)},
+		{Name: "new-required-snap-1", Role: "required", Channel: "stable", Action: "install", Revision: snap.R(10)},
+	})
+
+	// all snaps are checked in a single request
+	c.Assert(sto.actions, HasLen, 4)
+	c.Check(sto.actions[3], DeepEquals, &store.SnapAction{
+		Action:       "install",
+		InstanceName: "new-required-snap-1",
+		Channel:      "stable",
+	})
+	c.Check(sto.downloads, HasLen, 0)
+
+	c.Assert(plan.Tasks, HasLen, 3+1)
+	c.Check(plan.Tasks[0].Kind, Equals, "fake-download")
+	c.Check(plan.Tasks[2].Kind, Equals, "fake-install")
+	c.Check(plan.Tasks[3].Kind, Equals, "set-model")
+	c.Check(plan.Tasks[3].WaitTasks, DeepEquals, []string{plan.Tasks[0].ID, plan.Tasks[1].ID, plan.Tasks[2].ID})
+
+	// no change or task was created
+	c.Check(s.state.Changes(), HasLen, 0)
+	c.Check(s.state.Tasks(), HasLen, 0)
+}
+
+func (s *deviceMgrRemodelSuite) TestPlanRemodelUnavailableSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	sto := s.setupRemodelPlan(c)
+	sto.unavailable = map[string]error{
+		"pc":                store.ErrNoUpdateAvailable,
+		"missing-snap":      store.ErrSnapNotFound,
+		"new-required-snap": nil,
+	}
+
+	restore := devicestate.MockSnapstateInstallWithDeviceContext(func(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})
+	defer restore()
+	restore = devicestate.MockSnapstateUpdateWithDeviceContext(func(st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})
+	defer restore()
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture":   "amd64",
+		"kernel":         "pc-kernel",
+		"gadget":         "pc=20",
+		"base":           "core18",
+		"required-snaps": []interface{}{"new-required-snap", "missing-snap"},
+		"revision":       "1",
+	})
+	plan, err := devicestate.PlanRemodel(s.state, new)
+	c.Assert(err, IsNil)
+
+	c.Check(plan.StoreChecked, Equals, true)
+	c.Check(plan.RebootRequired, Equals, true)
+	c.Check(plan.Snaps, DeepEquals, []devicestate.PlannedRemodelSnap{
+		{Name: "pc-kernel", Role: "kernel", Channel: "18/stable", Action: "none", Revision: snap.R(10)},
+		{Name: "core18", Role: "base", Channel: "latest/stable", Action: "none", Revision: snap.R(10)},
+		{Name: "pc", Role: "gadget", Channel: "20", Action: "refresh", Unavailable: "snap has no updates available"},
+		{Name: "new-required-snap", Role: "required", Channel: "stable", Action: "install", Revision: snap.R(10)},
+		{Name: "missing-snap", Role: "required", Channel: "stable", Action: "install", Unavailable: "snap not found"},
+	})
+	c.Check(plan.Problems, DeepEquals, []string{
+		`cannot get snap "pc" from the store: snap has no updates available`,
+		`cannot get snap "missing-snap" from the store: snap not found`,
+	})
+	// the remodel tasks cannot be created
+	c.Check(plan.Tasks, HasLen, 0)
+	c.Check(sto.downloads, HasLen, 0)
+}
+
+func (s *deviceMgrRemodelSuite) TestPlanRemodelConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupRemodelPlan(c)
+
+	chg := s.state.NewChange("other", "...")
+	chg.AddTask(s.state.NewTask("nop", "..."))
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+		"revision":     "1",
+	})
+	plan, err := devicestate.PlanRemodel(s.state, new)
+	c.Assert(err, IsNil)
+	c.Check(plan.Problems, DeepEquals, []string{
+		`other changes in progress (conflicting change "other"), change "remodel" not allowed until they are done`,
+	})
+	// only set-model
+	c.Assert(plan.Tasks, HasLen, 1)
+	c.Check(plan.Tasks[0].Kind, Equals, "set-model")
+}
+
+func (s *deviceMgrRemodelSuite) TestPlanRemodelGadget(c *C) {
+	var currentGadgetYaml = `
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+       - name: foo
+         type: 00000000-0000-0000-0000-0000deadcafe
+         size: 10M
+`
+	var newGadgetYaml = `
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+       - name: foo
+         type: 00000000-0000-0000-0000-0000deadcafe
+         size: 10M
+         update:
+           edition: 1
+`
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	sto := s.setupRemodelPlan(c)
+
+	snaptest.MockSnapWithFiles(c, pcGadgetSnapYaml, &snap.SideInfo{RealName: "pc", Revision: snap.R(1)}, [][]string{
+		{"meta/gadget.yaml", currentGadgetYaml},
+	})
+	sto.gadgetSnap = snaptest.MakeTestSnapWithFiles(c, "name: new-gadget\ntype: gadget\nversion: 1", [][]string{
+		{"meta/gadget.yaml", newGadgetYaml},
+	})
+
+	restore := devicestate.MockSnapstateInstallWithDeviceContext(func(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Check(name, Equals, "new-gadget")
+		tDownload := st.NewTask("fake-download", fmt.Sprintf("Download %s", name))
+		tValidate := st.NewTask("validate-snap", fmt.Sprintf("Validate %s", name))
+		tValidate.WaitFor(tDownload)
+		tInstall := st.NewTask("fake-install", fmt.Sprintf("Install %s", name))
+		tInstall.WaitFor(tValidate)
+		ts := state.NewTaskSet(tDownload, tValidate, tInstall)
+		ts.MarkEdge(tValidate, snapstate.DownloadAndChecksDoneEdge)
+		return ts, nil
+	})
+	defer restore()
+
+	compatibleCalls := 0
+	restore = devicestate.MockGadgetIsCompatible(func(current, update *gadget.Info) error {
+		compatibleCalls++
+		c.Check(current.Volumes["pc"].Structure[0].Update.Edition, Equals, edition.Number(0))
+		c.Check(update.Volumes["pc"].Structure[0].Update.Edition, Equals, edition.Number(1))
+		return nil
+	})
+	defer restore()
+
+	checkUpdateCalls := 0
+	restore = devicestate.MockGadgetCheckUpdate(func(current, update gadget.GadgetData, policy gadget.UpdatePolicyFunc) error {
+		checkUpdateCalls++
+		c.Check(reflect.ValueOf(policy).Pointer(), Equals, reflect.ValueOf(gadget.RemodelUpdatePolicy).Pointer())
+		c.Check(current.RootDir, Equals, filepath.Join(dirs.SnapMountDir, "pc/1"))
+		c.Check(current.KernelRootDir, Equals, filepath.Join(dirs.SnapMountDir, "pc-kernel/1"))
+		c.Check(update.KernelRootDir, Equals, current.KernelRootDir)
+		c.Check(filepath.Join(update.RootDir, "meta/gadget.yaml"), testutil.FileEquals, newGadgetYaml)
+		return errors.New("cannot change structure foo")
+	})
+	defer restore()
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "new-gadget",
+		"base":         "core18",
+		"revision":     "1",
+	})
+	plan, err := devicestate.PlanRemodel(s.state, new)
+	c.Assert(err, IsNil)
+
+	c.Check(sto.downloads, DeepEquals, []string{"new-gadget"})
+	c.Check(compatibleCalls, Equals, 1)
+	c.Check(checkUpdateCalls, Equals, 1)
+	c.Check(plan.RebootRequired, Equals, true)
+	c.Check(plan.Snaps[2], DeepEquals, devicestate.PlannedRemodelSnap{
+		Name: "new-gadget", Role: "gadget", Channel: "stable", Action: "install", Revision: snap.R(10),
+	})
+	c.Check(plan.Problems, DeepEquals, []string{
+		"cannot update gadget assets: cannot change structure foo",
+	})
+	c.Check(plan.Tasks, HasLen, 3+1)
+}
+
+func (s *deviceMgrRemodelSuite) TestPlanRemodelGadgetIncompatible(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	sto := s.setupRemodelPlan(c)
+
+	snaptest.MockSnapWithFiles(c, pcGadgetSnapYaml, &snap.SideInfo{RealName: "pc", Revision: snap.R(1)}, [][]string{
+		{"meta/gadget.yaml", gadgetYaml},
+	})
+	sto.gadgetSnap = snaptest.MakeTestSnapWithFiles(c, pcGadgetSnapYaml+"version: 1", [][]string{
+		{"meta/gadget.yaml", gadgetYaml},
+	})
+
+	restore := devicestate.MockSnapstateUpdateWithDeviceContext(func(st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		tDownload := st.NewTask("fake-download", fmt.Sprintf("Download %s", name))
+		tValidate := st.NewTask("validate-snap", fmt.Sprintf("Validate %s", name))
+		tValidate.WaitFor(tDownload)
+		tInstall := st.NewTask("fake-install", fmt.Sprintf("Install %s", name))
+		tInstall.WaitFor(tValidate)
+		ts := state.NewTaskSet(tDownload, tValidate, tInstall)
+		ts.MarkEdge(tValidate, snapstate.DownloadAndChecksDoneEdge)
+		return ts, nil
+	})
+	defer restore()
+	restore = devicestate.MockGadgetIsCompatible(func(current, update *gadget.Info) error {
+		return errors.New("volume pc is not compatible")
+	})
+	defer restore()
+	restore = devicestate.MockGadgetCheckUpdate(func(current, update gadget.GadgetData, policy gadget.UpdatePolicyFunc) error {
+		c.Fatalf("unexpected call")
+		return nil
+	})
+	defer restore()
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc=20",
+		"base":         "core18",
+		"revision":     "1",
+	})
+	plan, err := devicestate.PlanRemodel(s.state, new)
+	c.Assert(err, IsNil)
+
+	c.Check(sto.downloads, DeepEquals, []string{"pc"})
+	c.Check(plan.Snaps[2].Action, Equals, "refresh")
+	c.Check(plan.Problems, DeepEquals, []string{
+		"cannot remodel to an incompatible gadget: volume pc is not compatible",
+	})
+}
+
+func (s *deviceMgrRemodelSuite) TestPlanRemodelStoreSwitch(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupRemodelPlan(c)
+
+	planStore := &remodelPlanStore{}
+	s.newFakeStore = func(devBE storecontext.DeviceBackend) snapstate.StoreService {
+		return planStore
+	}
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+		"store":        "switched-store",
+		"revision":     "1",
+	})
+	plan, err := devicestate.PlanRemodel(s.state, new)
+	c.Assert(err, IsNil)
+
+	c.Check(plan.Kind, Equals, "store switch remodel")
+	c.Check(plan.StoreChecked, Equals, true)
+	c.Check(planStore.ensureDeviceSession, Equals, 1)
+	// the new store was asked
+	c.Check(planStore.actions, HasLen, 3)
+	c.Check(plan.Problems, HasLen, 0)
+
+	// the device session was not changed
+	device, err := devicestatetest.Device(s.state)
+	c.Assert(err, IsNil)
+	c.Check(device.SessionMacaroon, Equals, "")
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *deviceMgrRemodelSuite) TestPlanRemodelRereg(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	sto := s.setupRemodelPlan(c)
+
+	s.newFakeStore = func(devBE storecontext.DeviceBackend) snapstate.StoreService {
+		return nil
+	}
+
+	new := s.brands.Model("canonical", "rereg-model", map[string]interface{}{
+		"architecture":   "amd64",
+		"kernel":         "pc-kernel",
+		"gadget":         "pc",
+		"base":           "core18",
+		"required-snaps": []interface{}{"new-required-snap-1"},
+	})
+	plan, err := devicestate.PlanRemodel(s.state, new)
+	c.Assert(err, IsNil)
+
+	c.Check(plan.Kind, Equals, "re-registration remodel")
+	c.Check(plan.StoreChecked, Equals, false)
+	c.Check(sto.actions, HasLen, 0)
+	c.Check(plan.Snaps, HasLen, 4)
+	c.Check(plan.Snaps[3].Unavailable, Equals, "")
+	c.Assert(plan.Tasks, HasLen, 2)
+	c.Check(plan.Tasks[0].Kind, Equals, "request-serial")
+	c.Check(plan.Tasks[1].Kind, Equals, "prepare-remodeling")
+	c.Check(plan.Tasks[1].WaitTasks, DeepEquals, []string{plan.Tasks[0].ID})
+	c.Check(s.state.Changes(), HasLen, 0)
+	c.Check(s.state.Tasks(), HasLen, 0)
+}
+
+func (s *deviceMgrRemodelSuite) setupOfflineRemodel(c *C) {
+	s.state.Set("seeded", true)
+	s.state.Set("refresh-privacy-key", "some-privacy-key")
+
+	// set a model assertion
+	s.makeModelAssertionInState(c, "canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+	})
+	s.makeSerialAssertionInState(c, "canonical", "pc-model", "1234")
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand:  "canonical",
+		Model:  "pc-model",
+		Serial: "1234",
+	})
+}
+
+func (s *deviceMgrRemodelSuite) TestRemodelOfflineLocalSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupOfflineRemodel(c)
+
+	restore := devicestate.MockSnapstateInstallWithDeviceContext(func(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Fatalf("unexpected install of %q from the store", name)
+		return nil, nil
+	})
+	defer restore()
+
+	var installed []string
+	restore = devicestate.MockSnapstateInstallPathWithDeviceContext(func(st *state.State, si *snap.SideInfo, path, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Check(si.RealName, Equals, name)
+		c.Check(path, Equals, "/path/to/"+name+".snap")
+		c.Check(flags.Required, Equals, true)
+		c.Check(flags.RemoveSnapPath, Equals, true)
+		c.Check(deviceCtx, NotNil)
+		c.Check(deviceCtx.ForRemodeling(), Equals, true)
+		installed = append(installed, name)
+
+		tPrepare := s.state.NewTask("fake-prepare", fmt.Sprintf("Prepare %s", name))
+		tInstall := s.state.NewTask("fake-install", fmt.Sprintf("Install %s", name))
+		tInstall.WaitFor(tPrepare)
+		ts := state.NewTaskSet(tPrepare, tInstall)
+		ts.MarkEdge(tPrepare, snapstate.DownloadAndChecksDoneEdge)
+		return ts, nil
+	})
+	defer restore()
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture":   "amd64",
+		"kernel":         "pc-kernel",
+		"gadget":         "pc",
+		"base":           "core18",
+		"store":          "switched-store",
+		"required-snaps": []interface{}{"new-required-snap-1", "new-required-snap-2"},
+		"revision":       "1",
+	})
+
+	freshStore := &freshSessionStore{}
+	s.newFakeStore = func(devBE storecontext.DeviceBackend) snapstate.StoreService {
+		return freshStore
+	}
+
+	localSnaps := []*snap.SideInfo{
+		{RealName: "new-required-snap-2", SnapID: "snap-2-id", Revision: snap.R(2)},
+		{RealName: "new-required-snap-1", SnapID: "snap-1-id", Revision: snap.R(1)},
+	}
+	paths := []string{"/path/to/new-required-snap-2.snap", "/path/to/new-required-snap-1.snap"}
+	chg, err := devicestate.RemodelWithLocalSnaps(s.state, new, localSnaps, paths)
+	c.Assert(err, IsNil)
+	c.Assert(chg.Summary(), Equals, "Refresh model assertion from revision 0 to 1")
+
+	// no store session is needed when offline
+	c.Check(freshStore.ensureDeviceSession, Equals, 0)
+	c.Check(installed, DeepEquals, []string{"new-required-snap-1", "new-required-snap-2"})
+
+	tl := chg.Tasks()
+	// 2 snaps * 2 tasks + 1 "set-model" task at the end
+	c.Assert(tl, HasLen, 2*2+1)
+	tPrepareSnap1, tInstallSnap1 := tl[0], tl[1]
+	tPrepareSnap2, tInstallSnap2 := tl[2], tl[3]
+	c.Check(tPrepareSnap2.WaitTasks(), DeepEquals, []*state.Task{tPrepareSnap1})
+	c.Check(tInstallSnap1.WaitTasks(), DeepEquals, []*state.Task{tPrepareSnap1, tPrepareSnap2})
+	c.Check(tInstallSnap2.WaitTasks(), DeepEquals, []*state.Task{tPrepareSnap2, tInstallSnap1})
+	c.Check(tl[4].Kind(), Equals, "set-model")
+}
+
+func (s *deviceMgrRemodelSuite) TestRemodelOfflineRemovesUnusedLocalSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupOfflineRemodel(c)
+
+	restore := devicestate.MockSnapstateInstallPathWithDeviceContext(func(st *state.State, si *snap.SideInfo, path, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Check(name, Equals, "new-required-snap-1")
+		tPrepare := s.state.NewTask("fake-prepare", fmt.Sprintf("Prepare %s", name))
+		tInstall := s.state.NewTask("fake-install", fmt.Sprintf("Install %s", name))
+		tInstall.WaitFor(tPrepare)
+		ts := state.NewTaskSet(tPrepare, tInstall)
+		ts.MarkEdge(tPrepare, snapstate.DownloadAndChecksDoneEdge)
+		return ts, nil
+	})
+	defer restore()
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture":   "amd64",
+		"kernel":         "pc-kernel",
+		"gadget":         "pc",
+		"base":           "core18",
+		"required-snaps": []interface{}{"new-required-snap-1"},
+		"revision":       "1",
+	})
+
+	// the kernel does not change, its file is not used by the remodel
+	localSnaps := []*snap.SideInfo{
+		{RealName: "pc-kernel", SnapID: "pc-kernel-id", Revision: snap.R(2)},
+		{RealName: "new-required-snap-1", SnapID: "snap-1-id", Revision: snap.R(1)},
+	}
+	dir := c.MkDir()
+	paths := []string{filepath.Join(dir, "pc-kernel.snap"), filepath.Join(dir, "new-required-snap-1.snap")}
+	for _, p := range paths {
+		c.Assert(ioutil.WriteFile(p, nil, 0644), IsNil)
+	}
+
+	chg, err := devicestate.RemodelWithLocalSnaps(s.state, new, localSnaps, paths)
+	c.Assert(err, IsNil)
+	// 2 tasks for the snap + 1 "set-model" task at the end
+	c.Check(chg.Tasks(), HasLen, 2+1)
+
+	// the unused file is gone, the other one is removed once installed
+	c.Check(paths[0], testutil.FileAbsent)
+	c.Check(paths[1], testutil.FilePresent)
+}
+
+func (s *deviceMgrRemodelSuite) TestRemodelOfflineMissingLocalSnap(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupOfflineRemodel(c)
+
+	restore := devicestate.MockSnapstateInstallPathWithDeviceContext(func(st *state.State, si *snap.SideInfo, path, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		tPrepare := s.state.NewTask("fake-prepare", fmt.Sprintf("Prepare %s", name))
+		ts := state.NewTaskSet(tPrepare)
+		ts.MarkEdge(tPrepare, snapstate.DownloadAndChecksDoneEdge)
+		return ts, nil
+	})
+	defer restore()
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture":   "amd64",
+		"kernel":         "pc-kernel",
+		"gadget":         "pc",
+		"base":           "core18",
+		"required-snaps": []interface{}{"new-required-snap-1", "new-required-snap-2"},
+		"revision":       "1",
+	})
+
+	localSnaps := []*snap.SideInfo{
+		{RealName: "new-required-snap-1", SnapID: "snap-1-id", Revision: snap.R(1)},
+	}
+	_, err := devicestate.RemodelWithLocalSnaps(s.state, new, localSnaps, []string{"/path/to/new-required-snap-1.snap"})
+	c.Assert(err, ErrorMatches, `cannot remodel offline: no snap file provided for "new-required-snap-2"`)
+}
+
+func (s *deviceMgrRemodelSuite) TestRemodelOfflineUnhappy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupOfflineRemodel(c)
+
+	newModel := func(model string) *asserts.Model {
+		return s.brands.Model("canonical", model, map[string]interface{}{
+			"architecture":   "amd64",
+			"kernel":         "pc-kernel",
+			"gadget":         "pc",
+			"base":           "core18",
+			"required-snaps": []interface{}{"new-required-snap-1"},
+			"revision":       "1",
+		})
+	}
+	snap1 := &snap.SideInfo{RealName: "new-required-snap-1", SnapID: "snap-1-id", Revision: snap.R(1)}
+
+	for _, tc := range []struct {
+		model      string
+		localSnaps []*snap.SideInfo
+		err        string
+	}{
+		{"pc-model", []*snap.SideInfo{{RealName: "other-snap", SnapID: "other-id", Revision: snap.R(1)}}, `cannot remodel with snap "other-snap" not required by the new model`},
+		{"pc-model", []*snap.SideInfo{{RealName: "new-required-snap-1"}}, `cannot remodel with snap "new-required-snap-1" without assertions`},
+		{"pc-model", []*snap.SideInfo{snap1, snap1}, `cannot remodel with snap "new-required-snap-1" provided more than once`},
+		{"rereg-model", []*snap.SideInfo{snap1}, `cannot remodel offline to a model that requires a new serial`},
+	} {
+		paths := make([]string, len(tc.localSnaps))
+		for i, si := range tc.localSnaps {
+			paths[i] = "/path/to/" + si.RealName + ".snap"
+		}
+		_, err := devicestate.RemodelWithLocalSnaps(s.state, newModel(tc.model), tc.localSnaps, paths)
+		c.Check(err, ErrorMatches, tc.err)
+	}
+}
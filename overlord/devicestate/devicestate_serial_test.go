@@ -21,6 +21,7 @@ package devicestate_test
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -2017,3 +2018,128 @@ func (s *deviceMgrSerialSuite) TestFullDeviceRegistrationUC20Happy(c *C) {
 	})
 	c.Assert(err, IsNil)
 }
+
+// setupUC20SerialInSave sets up a seeded UC20 device whose device key and
+// serial were preserved in ubuntu-save, as is the case after a factory
+// reset. The state must be locked by the caller.
+func (s *deviceMgrSerialSuite) setupUC20SerialInSave(c *C) asserts.PrivateKey {
+	privKey, _ := assertstest.GenerateKey(testKeyLength)
+
+	// setup state as will be done by first-boot after a factory reset
+	s.makeModelAssertionInState(c, "canonical", "pc-20", map[string]interface{}{
+		"architecture": "amd64",
+		// UC20
+		"base": "core20",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":            "pc-kernel",
+				"id":              snaptest.AssertedSnapID("pc-kernel"),
+				"type":            "kernel",
+				"default-channel": "20",
+			},
+			map[string]interface{}{
+				"name":            "pc",
+				"id":              snaptest.AssertedSnapID("pc"),
+				"type":            "gadget",
+				"default-channel": "20",
+			},
+		},
+	})
+
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc-20",
+	})
+
+	// save is available
+	devicestate.SetSaveAvailable(s.mgr, true)
+
+	// the device key and serial were preserved in ubuntu-save
+	devicestate.KeypairManager(s.mgr).Put(privKey)
+	encDevKey, err := asserts.EncodePublicKey(privKey.PublicKey())
+	c.Assert(err, IsNil)
+	serial, err := s.storeSigning.Sign(asserts.SerialType, map[string]interface{}{
+		"brand-id":            "canonical",
+		"model":               "pc-20",
+		"serial":              "9999",
+		"device-key":          string(encDevKey),
+		"device-key-sha3-384": privKey.PublicKey().ID(),
+		"timestamp":           time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	savedb, err := sysdb.OpenAt(dirs.SnapDeviceSaveDir)
+	c.Assert(err, IsNil)
+	c.Assert(savedb.Add(s.storeSigning.StoreAccountKey("")), IsNil)
+	c.Assert(savedb.Add(serial), IsNil)
+
+	// avoid full seeding
+	s.seeding()
+
+	devicestatetest.MockGadget(c, s.state, "pc", snap.R(2), nil)
+	// mark it as seeded
+	s.state.Set("seeded", true)
+	// skip boot ok logic
+	devicestate.SetBootOkRan(s.mgr, true)
+
+	return privKey
+}
+
+func (s *deviceMgrSerialSuite) TestDeviceRegistrationUC20RestoredFromSave(c *C) {
+	defer sysdb.InjectTrusted([]asserts.Assertion{s.storeSigning.TrustedKey})()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	privKey := s.setupUC20SerialInSave(c)
+
+	// first boot after a factory reset
+	marker := filepath.Join(dirs.SnapDeviceDir, "factory-reset")
+	c.Assert(os.MkdirAll(filepath.Dir(marker), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(marker, nil, 0644), IsNil)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	// no registration was needed
+	c.Check(s.findBecomeOperationalChange(), IsNil)
+
+	device, err := devicestatetest.Device(s.state)
+	c.Assert(err, IsNil)
+	c.Check(device.Serial, Equals, "9999")
+	c.Check(device.KeyID, Equals, privKey.PublicKey().ID())
+
+	select {
+	case <-s.mgr.Registered():
+	case <-time.After(5 * time.Second):
+		c.Fatal("should have been marked registered")
+	}
+
+	_, err = s.db.Find(asserts.SerialType, map[string]string{
+		"brand-id": "canonical",
+		"model":    "pc-20",
+		"serial":   "9999",
+	})
+	c.Assert(err, IsNil)
+
+	// the restore is not attempted again
+	c.Check(marker, testutil.FileAbsent)
+}
+
+func (s *deviceMgrSerialSuite) TestDeviceRegistrationUC20NotRestoredWithoutFactoryReset(c *C) {
+	defer sysdb.InjectTrusted([]asserts.Assertion{s.storeSigning.TrustedKey})()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupUC20SerialInSave(c)
+
+	// no factory reset marker, ubuntu-save is not looked at
+	s.state.Unlock()
+	err := s.mgr.Ensure()
+	s.state.Lock()
+	c.Assert(err, IsNil)
+
+	c.Check(s.findBecomeOperationalChange(), NotNil)
+	device, err := devicestatetest.Device(s.state)
+	c.Assert(err, IsNil)
+	c.Check(device.Serial, Equals, "")
+}
@@ -272,10 +272,12 @@ func (s *deviceMgrSystemsSuite) TestListSystemsNotPossible(c *C) {
 // TODO:UC20 update once we can list actions
 var defaultSystemActions []devicestate.SystemAction = []devicestate.SystemAction{
 	{Title: "Install", Mode: "install"},
+	{Title: "Factory reset", Mode: "factory-reset"},
 }
 var currentSystemActions []devicestate.SystemAction = []devicestate.SystemAction{
 	{Title: "Reinstall", Mode: "install"},
 	{Title: "Recover", Mode: "recover"},
+	{Title: "Factory reset", Mode: "factory-reset"},
 	{Title: "Run normally", Mode: "run"},
 }
 
@@ -659,6 +661,28 @@ func (s *deviceMgrSystemsSuite) TestRequestInstallForOther(c *C) {
 	s.testRequestModeWithRestart(c, []string{"install"}, s.mockedSystemSeeds[1].label)
 }
 
+func (s *deviceMgrSystemsSuite) TestRequestFactoryResetForOther(c *C) {
+	devicestate.SetSystemMode(s.mgr, "run")
+	// non run modes use modeenv
+	modeenv := boot.Modeenv{
+		Mode: "run",
+	}
+	err := modeenv.WriteTo("")
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	s.state.Set("seeded-systems", []devicestate.SeededSystem{
+		{
+			System:  s.mockedSystemSeeds[0].label,
+			Model:   s.mockedSystemSeeds[0].model.Model(),
+			BrandID: s.mockedSystemSeeds[0].brand.AccountID(),
+		},
+	})
+	s.state.Unlock()
+	// factory reset from different system seed is ok
+	s.testRequestModeWithRestart(c, []string{"factory-reset"}, s.mockedSystemSeeds[1].label)
+}
+
 func (s *deviceMgrSystemsSuite) TestRequestAction1618(c *C) {
 	s.setPCModelInState(c)
 	// system mode is unset in 16/18
@@ -1931,6 +1955,193 @@ func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemReboo
 	c.Check(triedSystems, HasLen, 0)
 }
 
+func (s *deviceMgrSystemsCreateSuite) mockSeedWithSystems(c *C) {
+	seed20 := &seedtest.TestingSeed20{
+		SeedSnaps: *s.ss,
+		SeedDir:   boot.InitramfsUbuntuSeedDir,
+	}
+	restore := seed.MockTrusted(s.storeSigning.Trusted)
+	s.AddCleanup(restore)
+
+	seed20.MakeAssertedSnap(c, "name: snapd\nversion: 1\ntype: snapd", nil, snap.R(1), "canonical", seed20.StoreSigning.Database)
+	seed20.MakeAssertedSnap(c, "name: pc\nversion: 1\ntype: gadget\nbase: core20", nil, snap.R(1), "canonical", seed20.StoreSigning.Database)
+	seed20.MakeAssertedSnap(c, "name: pc-kernel\nversion: 1\ntype: kernel", nil, snap.R(1), "canonical", seed20.StoreSigning.Database)
+	seed20.MakeAssertedSnap(c, "name: core20\nversion: 1\ntype: base", nil, snap.R(1), "canonical", seed20.StoreSigning.Database)
+	seed20.MakeAssertedSnap(c, "name: foo\nversion: 1\nbase: core20", nil, snap.R(1), "canonical", seed20.StoreSigning.Database)
+
+	essentialSnaps := []interface{}{
+		map[string]interface{}{
+			"name":            "pc-kernel",
+			"id":              seed20.AssertedSnapID("pc-kernel"),
+			"type":            "kernel",
+			"default-channel": "20",
+		},
+		map[string]interface{}{
+			"name":            "pc",
+			"id":              seed20.AssertedSnapID("pc"),
+			"type":            "gadget",
+			"default-channel": "20",
+		},
+	}
+	seed20.MakeSeed(c, "20191119", "my-brand", "my-model", map[string]interface{}{
+		"display-name": "my fancy model",
+		"architecture": "amd64",
+		"base":         "core20",
+		"snaps":        essentialSnaps,
+	}, nil)
+	seed20.MakeSeed(c, "1234", "my-brand", "my-model", map[string]interface{}{
+		"display-name": "my fancy model",
+		"architecture": "amd64",
+		"base":         "core20",
+		"revision":     "1",
+		"snaps": append(essentialSnaps, map[string]interface{}{
+			"name": "foo",
+			"id":   seed20.AssertedSnapID("foo"),
+		}),
+	}, nil)
+
+	m := boot.Modeenv{
+		Mode:                   "run",
+		Base:                   "core20_3.snap",
+		CurrentKernels:         []string{"pc-kernel_2.snap"},
+		CurrentRecoverySystems: []string{"20191119", "1234"},
+		GoodRecoverySystems:    []string{"20191119", "1234"},
+
+		Model:          s.model.Model(),
+		BrandID:        s.model.BrandID(),
+		Grade:          string(s.model.Grade()),
+		ModelSignKeyID: s.model.SignKeyID(),
+	}
+	c.Assert(m.WriteTo(""), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded-systems", []devicestate.SeededSystem{{
+		System:  "20191119",
+		Model:   "my-model",
+		BrandID: "my-brand",
+	}})
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerRemoveRecoverySystemTasksAndChange(c *C) {
+	s.mockSeedWithSystems(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	chg, err := devicestate.RemoveRecoverySystem(s.state, "1234")
+	c.Assert(err, IsNil)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "remove-recovery-system")
+	tsks := chg.Tasks()
+	c.Assert(tsks, HasLen, 1)
+	c.Check(tsks[0].Kind(), Equals, "remove-recovery-system")
+	c.Check(tsks[0].Summary(), Equals, `Remove recovery system with label "1234"`)
+	var systemSetupData map[string]interface{}
+	err = tsks[0].Get("recovery-system-setup", &systemSetupData)
+	c.Assert(err, IsNil)
+	c.Check(systemSetupData, DeepEquals, map[string]interface{}{
+		"label":            "1234",
+		"directory":        filepath.Join(boot.InitramfsUbuntuSeedDir, "systems/1234"),
+		"snap-setup-tasks": nil,
+	})
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerRemoveRecoverySystemHappy(c *C) {
+	devicestate.SetBootOkRan(s.mgr, true)
+	s.mockSeedWithSystems(c)
+	s.bootloader.SetBootVars(map[string]string{
+		"snapd_recovery_system": "1234",
+	})
+
+	s.state.Lock()
+	chg, err := devicestate.RemoveRecoverySystem(s.state, "1234")
+	c.Assert(err, IsNil)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+
+	// the system is gone
+	c.Check(filepath.Join(boot.InitramfsUbuntuSeedDir, "systems/1234"), testutil.FileAbsent)
+	c.Check(filepath.Join(boot.InitramfsUbuntuSeedDir, "systems/20191119"), testutil.FilePresent)
+	// and so is the snap only it used
+	p, err := filepath.Glob(filepath.Join(boot.InitramfsUbuntuSeedDir, "snaps/*"))
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, []string{
+		filepath.Join(boot.InitramfsUbuntuSeedDir, "snaps/core20_1.snap"),
+		filepath.Join(boot.InitramfsUbuntuSeedDir, "snaps/pc-kernel_1.snap"),
+		filepath.Join(boot.InitramfsUbuntuSeedDir, "snaps/pc_1.snap"),
+		filepath.Join(boot.InitramfsUbuntuSeedDir, "snaps/snapd_1.snap"),
+	})
+
+	m, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check(m.CurrentRecoverySystems, DeepEquals, []string{"20191119"})
+	c.Check(m.GoodRecoverySystems, DeepEquals, []string{"20191119"})
+	// the default recovery system was updated
+	vars, err := s.bootloader.GetBootVars("snapd_recovery_system")
+	c.Assert(err, IsNil)
+	c.Check(vars["snapd_recovery_system"], Equals, "20191119")
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerRemoveRecoverySystemErrors(c *C) {
+	s.mockSeedWithSystems(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, tc := range []struct {
+		label  string
+		good   []string
+		seeded interface{}
+		err    string
+	}{
+		{label: "9999", err: `recovery system "9999" does not exist`},
+		{label: "20191119", err: `cannot remove the current recovery system "20191119"`},
+		{label: "1234", good: []string{"1234"}, err: `cannot remove the last good recovery system "1234"`},
+		{label: "1234", seeded: false, err: `cannot remove recovery systems until fully seeded`},
+		{label: "../../../etc", err: `cannot remove recovery system: invalid seed system label: "../../../etc"`},
+		{label: "", err: `cannot remove recovery system: invalid seed system label: ""`},
+		{label: "1234/..", err: `cannot remove recovery system: invalid seed system label: "1234/.."`},
+	} {
+		m, err := boot.ReadModeenv("")
+		c.Assert(err, IsNil)
+		if tc.good != nil {
+			m.GoodRecoverySystems = tc.good
+		} else {
+			m.GoodRecoverySystems = []string{"20191119", "1234"}
+		}
+		c.Assert(m.Write(), IsNil)
+		if tc.seeded != nil {
+			s.state.Set("seeded", tc.seeded)
+		} else {
+			s.state.Set("seeded", true)
+		}
+
+		chg, err := devicestate.RemoveRecoverySystem(s.state, tc.label)
+		c.Check(err, ErrorMatches, tc.err)
+		c.Check(chg, IsNil)
+	}
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerRemoveRecoverySystemConflict(c *C) {
+	s.mockSeedWithSystems(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("create-recovery-system", "...")
+	chg.AddTask(s.state.NewTask("create-recovery-system", "..."))
+
+	_, err := devicestate.RemoveRecoverySystem(s.state, "1234")
+	c.Assert(err, FitsTypeOf, &snapstate.ChangeConflictError{})
+	c.Check(err, ErrorMatches, "creating recovery system in progress, no other changes allowed until this is done")
+}
+
 type systemSnapTrackingSuite struct {
 	deviceMgrSystemsBaseSuite
 }
@@ -33,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storecontext"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/sysconfig"
 	"github.com/snapcore/snapd/timings"
 )
@@ -141,6 +142,14 @@ func MockSnapstateInstallWithDeviceContext(f func(ctx context.Context, st *state
 	}
 }
 
+func MockSnapstateInstallPathWithDeviceContext(f func(st *state.State, si *snap.SideInfo, path, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateInstallPathWithDeviceContext
+	snapstateInstallPathWithDeviceContext = f
+	return func() {
+		snapstateInstallPathWithDeviceContext = old
+	}
+}
+
 func MockSnapstateUpdateWithDeviceContext(f func(st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error)) (restore func()) {
 	old := snapstateUpdateWithDeviceContext
 	snapstateUpdateWithDeviceContext = f
@@ -224,9 +233,10 @@ var (
 	CleanupRemodelCtx = cleanupRemodelCtx
 	CachedRemodelCtx  = cachedRemodelCtx
 
-	GadgetUpdateBlocked = gadgetUpdateBlocked
-	CurrentGadgetInfo   = currentGadgetInfo
-	PendingGadgetInfo   = pendingGadgetInfo
+	GadgetUpdateBlocked              = gadgetUpdateBlocked
+	KernelCmdlineAppendUpdateBlocked = kernelCmdlineAppendUpdateBlocked
+	CurrentGadgetInfo                = currentGadgetInfo
+	PendingGadgetInfo                = pendingGadgetInfo
 
 	CriticalTaskEdges = criticalTaskEdges
 
@@ -252,6 +262,14 @@ func MockGadgetIsCompatible(mock func(current, update *gadget.Info) error) (rest
 	}
 }
 
+func MockGadgetCheckUpdate(mock func(old, new gadget.GadgetData, updatePolicy gadget.UpdatePolicyFunc) error) (restore func()) {
+	old := gadgetCheckUpdate
+	gadgetCheckUpdate = mock
+	return func() {
+		gadgetCheckUpdate = old
+	}
+}
+
 func MockBootMakeSystemRunnable(f func(model *asserts.Model, bootWith *boot.BootableSet, seal *boot.TrustedAssetsInstallObserver) error) (restore func()) {
 	old := bootMakeRunnable
 	bootMakeRunnable = f
@@ -300,6 +318,14 @@ func MockInstallRun(f func(model gadget.Model, gadgetRoot, kernelRoot, device st
 	}
 }
 
+func MockInstallFactoryReset(f func(model gadget.Model, gadgetRoot, kernelRoot, device string, options install.Options, observer gadget.ContentObserver, perfTimings timings.Measurer) (*install.InstalledSystemSideData, error)) (restore func()) {
+	old := installFactoryReset
+	installFactoryReset = f
+	return func() {
+		installFactoryReset = old
+	}
+}
+
 func MockCloudInitStatus(f func() (sysconfig.CloudInitState, error)) (restore func()) {
 	old := cloudInitStatus
 	cloudInitStatus = f
@@ -72,7 +72,11 @@ func (m *DeviceManager) doUpdateManagedBootConfig(t *state.Task, _ *tomb.Tomb) e
 	}
 
 	// TODO:UC20 update recovery boot config
-	updated, err := boot.UpdateManagedBootConfigs(devCtx, currentData.RootDir)
+	cmdlineAppend, err := kernelCommandLineAppend(st, currentData)
+	if err != nil {
+		return err
+	}
+	updated, err := boot.UpdateManagedBootConfigs(devCtx, currentData.RootDir, cmdlineAppend)
 	if err != nil {
 		return fmt.Errorf("cannot update boot config assets: %v", err)
 	}
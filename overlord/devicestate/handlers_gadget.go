@@ -30,6 +30,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
@@ -63,6 +64,25 @@ func currentGadgetInfo(st *state.State, curDeviceCtx snapstate.DeviceContext) (*
 	return ci, nil
 }
 
+// kernelCommandLineAppend returns the kernel command line arguments appended
+// through system configuration, checked against the allow list of the given
+// gadget.
+func kernelCommandLineAppend(st *state.State, gadgetData *gadget.GadgetData) (string, error) {
+	var cmdlineAppend string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "system.kernel.cmdline-append", &cmdlineAppend); err != nil && !config.IsNoOption(err) {
+		return "", err
+	}
+	if cmdlineAppend == "" {
+		return "", nil
+	}
+	cmdlineAppend, err := gadget.CheckKernelCommandLineAppend(gadgetData.Info, cmdlineAppend)
+	if err != nil {
+		return "", fmt.Errorf("cannot use kernel command line arguments from system configuration: %v", err)
+	}
+	return cmdlineAppend, nil
+}
+
 func pendingGadgetInfo(snapsup *snapstate.SnapSetup, pendingDeviceCtx snapstate.DeviceContext) (*gadget.GadgetData, error) {
 	info, err := snap.ReadInfo(snapsup.InstanceName(), snapsup.SideInfo)
 	if err != nil {
@@ -240,7 +260,11 @@ func (m *DeviceManager) updateGadgetCommandLine(t *state.Task, st *state.State,
 		}
 		gadgetData = currentGadgetData
 	}
-	updated, err = boot.UpdateCommandLineForGadgetComponent(devCtx, gadgetData.RootDir)
+	cmdlineAppend, err := kernelCommandLineAppend(st, gadgetData)
+	if err != nil {
+		return false, err
+	}
+	updated, err = boot.UpdateCommandLineForGadgetComponent(devCtx, gadgetData.RootDir, cmdlineAppend)
 	if err != nil {
 		return false, fmt.Errorf("cannot update kernel command line from gadget: %v", err)
 	}
@@ -324,3 +348,61 @@ func (m *DeviceManager) undoUpdateGadgetCommandLine(t *state.Task, _ *tomb.Tomb)
 	st.RequestRestart(state.RestartSystem)
 	return nil
 }
+
+func (m *DeviceManager) doUpdateKernelCommandLineAppend(t *state.Task, _ *tomb.Tomb) error {
+	if release.OnClassic {
+		return fmt.Errorf("internal error: cannot run update kernel command line task on a classic system")
+	}
+
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var seeded bool
+	err := st.Get("seeded", &seeded)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !seeded {
+		// do nothing during first boot & seeding
+		return nil
+	}
+
+	devCtx, err := DeviceCtx(st, t, nil)
+	if err != nil {
+		return err
+	}
+	if devCtx.Model().Grade() == asserts.ModelGradeUnset {
+		// pre UC20 system, do nothing
+		return nil
+	}
+	gadgetData, err := currentGadgetInfo(st, devCtx)
+	if err != nil {
+		return err
+	}
+	if gadgetData == nil {
+		return fmt.Errorf("internal error: no current gadget")
+	}
+	// the arguments are read from the committed configuration, thus if
+	// the configuration that queued the task failed to apply, the command
+	// line stays as it is
+	cmdlineAppend, err := kernelCommandLineAppend(st, gadgetData)
+	if err != nil {
+		return err
+	}
+	updated, err := boot.UpdateCommandLineForGadgetComponent(devCtx, gadgetData.RootDir, cmdlineAppend)
+	if err != nil {
+		return fmt.Errorf("cannot update kernel command line: %v", err)
+	}
+	if !updated {
+		logger.Debugf("no kernel command line update from system configuration")
+		return nil
+	}
+	t.Logf("Updated kernel command line")
+
+	t.SetStatus(state.DoneStatus)
+
+	// kernel command line was updated, request a reboot to make it effective
+	st.RequestRestart(state.RestartSystem)
+	return nil
+}
@@ -30,6 +30,7 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/gadget/install"
 	"github.com/snapcore/snapd/logger"
@@ -46,6 +47,7 @@ var (
 	bootMakeRunnable            = boot.MakeRunnableSystem
 	bootEnsureNextBootToRunMode = boot.EnsureNextBootToRunMode
 	installRun                  = install.Run
+	installFactoryReset         = install.FactoryReset
 
 	sysconfigConfigureTargetSystem = sysconfig.ConfigureTargetSystem
 )
@@ -197,6 +199,17 @@ func writeTimings(st *state.State, rootdir string) error {
 }
 
 func (m *DeviceManager) doSetupRunSystem(t *state.Task, _ *tomb.Tomb) error {
+	return m.setupRunSystem(t, false)
+}
+
+func (m *DeviceManager) doFactoryResetRunSystem(t *state.Task, _ *tomb.Tomb) error {
+	return m.setupRunSystem(t, true)
+}
+
+// setupRunSystem sets up the run system, either on a pristine device or, when
+// performing a factory reset, on a device that was installed before, keeping
+// ubuntu-save and with it the device identity intact.
+func (m *DeviceManager) setupRunSystem(t *state.Task, factoryReset bool) error {
 	st := t.State()
 	st.Lock()
 	defer st.Unlock()
@@ -273,15 +286,28 @@ func (m *DeviceManager) doSetupRunSystem(t *state.Task, _ *tomb.Tomb) error {
 	}
 
 	var installedSystem *install.InstalledSystemSideData
-	// run the create partition code
-	logger.Noticef("create and deploy partitions")
-	timings.Run(perfTimings, "install-run", "Install the run system", func(tm timings.Measurer) {
-		st.Unlock()
-		defer st.Lock()
-		installedSystem, err = installRun(model, gadgetDir, kernelDir, "", bopts, installObserver, tm)
-	})
-	if err != nil {
-		return fmt.Errorf("cannot install system: %v", err)
+	if factoryReset {
+		// recreate the run system partitions, keeping ubuntu-save
+		logger.Noticef("reset and deploy partitions")
+		timings.Run(perfTimings, "factory-reset", "Factory reset the run system", func(tm timings.Measurer) {
+			st.Unlock()
+			defer st.Lock()
+			installedSystem, err = installFactoryReset(model, gadgetDir, kernelDir, "", bopts, installObserver, tm)
+		})
+		if err != nil {
+			return fmt.Errorf("cannot perform factory reset: %v", err)
+		}
+	} else {
+		// run the create partition code
+		logger.Noticef("create and deploy partitions")
+		timings.Run(perfTimings, "install-run", "Install the run system", func(tm timings.Measurer) {
+			st.Unlock()
+			defer st.Lock()
+			installedSystem, err = installRun(model, gadgetDir, kernelDir, "", bopts, installObserver, tm)
+		})
+		if err != nil {
+			return fmt.Errorf("cannot install system: %v", err)
+		}
 	}
 
 	if trustedInstallObserver != nil {
@@ -318,6 +344,13 @@ func (m *DeviceManager) doSetupRunSystem(t *state.Task, _ *tomb.Tomb) error {
 		return fmt.Errorf("cannot store the model: %v", err)
 	}
 
+	if factoryReset {
+		// let the run system know that it comes from a factory reset
+		if err := writeFactoryResetMarker(); err != nil {
+			return fmt.Errorf("cannot write the factory reset marker: %v", err)
+		}
+	}
+
 	// configure the run system
 	opts := &sysconfig.Options{TargetRootDir: boot.InstallHostWritableDir, GadgetDir: gadgetDir}
 	// configure cloud init
@@ -354,6 +387,23 @@ func (m *DeviceManager) doSetupRunSystem(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+// factoryResetMarkerUnder returns the path of the marker that is present
+// in the run system under rootdir until its first boot after a factory reset
+// is done restoring the device identity.
+func factoryResetMarkerUnder(rootdir string) string {
+	return filepath.Join(dirs.SnapDeviceDirUnder(rootdir), "factory-reset")
+}
+
+// writeFactoryResetMarker writes the factory reset marker of the run system
+// being set up.
+func writeFactoryResetMarker() error {
+	marker := factoryResetMarkerUnder(boot.InstallHostWritableDir)
+	if err := os.MkdirAll(filepath.Dir(marker), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(marker, nil, 0644, 0)
+}
+
 // writeMarkers writes markers containing the same secret to pair data and save.
 func writeMarkers() error {
 	// ensure directory for markers exists
@@ -409,6 +459,10 @@ func saveKeys(keysForRoles map[string]*install.EncryptionKeySet) error {
 	if err := saveKeySet.Key.Save(saveKey); err != nil {
 		return fmt.Errorf("cannot store system save key: %v", err)
 	}
+	if saveKeySet.RecoveryKey == (secboot.RecoveryKey{}) {
+		// the recovery key of a preserved ubuntu-save is not known
+		return nil
+	}
 	if err := saveKeySet.RecoveryKey.Save(reinstallSaveKey); err != nil {
 		return fmt.Errorf("cannot store reinstall key: %v", err)
 	}
@@ -37,9 +37,11 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/seed"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapfile"
 	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timings"
 )
 
 func taskRecoverySystemSetup(t *state.Task) (*recoverySystemSetup, error) {
@@ -416,3 +418,126 @@ func (m *DeviceManager) cleanupRecoverySystem(t *state.Task, _ *tomb.Tomb) error
 	}
 	return nil
 }
+
+// seedSnapsOfSystem returns the paths of all the snap files used by the
+// recovery system with the given label in the seed.
+func seedSnapsOfSystem(seedDir, label string) ([]string, error) {
+	sd, err := seed.Open(seedDir, label)
+	if err != nil {
+		return nil, err
+	}
+	if err := sd.LoadAssertions(nil, nil); err != nil {
+		return nil, err
+	}
+	if err := sd.LoadMeta(timings.New(nil)); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, sn := range sd.EssentialSnaps() {
+		paths = append(paths, sn.Path)
+	}
+	for _, mode := range []string{"run", "install", "recover"} {
+		modeSnaps, err := sd.ModeSnaps(mode)
+		if err != nil {
+			return nil, err
+		}
+		for _, sn := range modeSnaps {
+			if !strutil.ListContains(paths, sn.Path) {
+				paths = append(paths, sn.Path)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// unusedSeedSnapsOfSystem returns the paths of the snap files of the recovery
+// system with the given label which are not used by any other recovery system
+// in the seed. Snap files kept inside the system directory are not included.
+func unusedSeedSnapsOfSystem(seedDir, label string) ([]string, error) {
+	snaps, err := seedSnapsOfSystem(seedDir, label)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load recovery system %q: %v", label, err)
+	}
+	systemDirs, err := filepath.Glob(filepath.Join(seedDir, "systems", "*"))
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	for _, systemDir := range systemDirs {
+		otherLabel := filepath.Base(systemDir)
+		if otherLabel == label {
+			continue
+		}
+		otherSnaps, err := seedSnapsOfSystem(seedDir, otherLabel)
+		if err != nil {
+			// be conservative, the snaps may still be in use
+			return nil, fmt.Errorf("cannot load recovery system %q: %v", otherLabel, err)
+		}
+		for _, path := range otherSnaps {
+			used[path] = true
+		}
+	}
+	systemDirectory := filepath.Join(seedDir, "systems", label)
+	var unused []string
+	for _, path := range snaps {
+		if strings.HasPrefix(path, systemDirectory+"/") || used[path] {
+			continue
+		}
+		unused = append(unused, path)
+	}
+	return unused, nil
+}
+
+func (m *DeviceManager) doRemoveRecoverySystem(t *state.Task, _ *tomb.Tomb) error {
+	if release.OnClassic {
+		// TODO: this may need to be lifted in the future
+		return fmt.Errorf("internal error: cannot remove recovery systems on a classic system")
+	}
+
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	deviceCtx, err := DeviceCtx(st, t, nil)
+	if err != nil {
+		return err
+	}
+
+	setup, err := taskRecoverySystemSetup(t)
+	if err != nil {
+		return fmt.Errorf("internal error: cannot obtain recovery system setup information")
+	}
+	label := setup.Label
+
+	// 1. make sure the system is no longer used for booting and that the
+	// fallback keys are no longer sealed for it
+	if err := boot.RemoveRecoverySystem(deviceCtx, label); err != nil {
+		return fmt.Errorf("cannot remove recovery system %q: %v", label, err)
+	}
+
+	// 2. drop the seed snaps which no other recovery system uses
+	exists, _, err := osutil.DirExists(setup.Directory)
+	if err != nil {
+		return err
+	}
+	if exists {
+		unused, err := unusedSeedSnapsOfSystem(boot.InitramfsUbuntuSeedDir, label)
+		if err != nil {
+			t.Logf("not removing seed snaps: %v", err)
+		}
+		for _, path := range unused {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("cannot remove seed snap %q: %v", path, err)
+			}
+			t.Logf("removed seed snap %v", path)
+		}
+	}
+
+	// 3. and finally the system itself
+	if err := os.RemoveAll(setup.Directory); err != nil {
+		return fmt.Errorf("cannot remove recovery system %q: %v", label, err)
+	}
+	t.Logf("removed recovery system directory %v", setup.Directory)
+
+	return nil
+}
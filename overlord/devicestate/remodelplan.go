@@ -0,0 +1,353 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapfile"
+	"github.com/snapcore/snapd/store"
+)
+
+// RemodelPlan describes what a remodel would do, without doing it.
+type RemodelPlan struct {
+	// Kind is the kind of remodel, as described by RemodelKind.
+	Kind string `json:"kind"`
+	// Snaps are the essential and required snaps of the new model
+	// together with what the remodel would do with them.
+	Snaps []PlannedRemodelSnap `json:"snaps"`
+	// StoreChecked is true if the availability of the snaps was checked
+	// with the store of the new model. This is not possible before a
+	// re-registration.
+	StoreChecked bool `json:"store-checked"`
+	// Tasks are the tasks that would make up the remodel change, in
+	// order, together with the tasks each of them would wait for.
+	Tasks []snapstate.PlannedTask `json:"tasks"`
+	// RebootRequired is true if the remodel would require a reboot.
+	RebootRequired bool `json:"reboot-required"`
+	// Problems are the reasons why the remodel is expected to fail.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// PlannedRemodelSnap describes what a remodel would do with a snap
+// required by the new model.
+type PlannedRemodelSnap struct {
+	Name string `json:"name"`
+	// Role is one of "kernel", "base", "gadget" or "required".
+	Role    string `json:"role"`
+	Channel string `json:"channel"`
+	// Action is one of "install", "refresh", "switch" (to a snap that
+	// is already installed) or "none".
+	Action string `json:"action"`
+	// Revision is the revision found in the store.
+	Revision snap.Revision `json:"revision"`
+	// Unavailable is the reason the snap cannot be obtained from the
+	// store, if any.
+	Unavailable string `json:"unavailable,omitempty"`
+}
+
+var gadgetCheckUpdate = gadget.CheckUpdate
+
+// PlanRemodel computes what Remodel would do for the given new model
+// without creating a change. The same checks as for a real remodel are
+// performed, the availability of all the snaps of the new model is checked
+// with the store and, when the gadget changes, the new gadget is downloaded
+// and checked for compatibility with the current one. Problems that would
+// make the remodel fail mid-way are reported in the plan. The tasks of the
+// plan are created on a copy of the state that is discarded, st is not
+// modified.
+// Note that the state must be locked by the caller.
+func PlanRemodel(st *state.State, new *asserts.Model) (*RemodelPlan, error) {
+	current, err := checkRemodel(st, new)
+	if err != nil {
+		return nil, err
+	}
+
+	remodelKind := ClassifyRemodel(current, new)
+	plan := &RemodelPlan{
+		Kind:  remodelKind.String(),
+		Tasks: []snapstate.PlannedTask{},
+	}
+
+	if err := snapstate.CheckChangeConflictRunExclusively(st, "remodel"); err != nil {
+		plan.Problems = append(plan.Problems, err.Error())
+	}
+
+	remodCtx, err := remodelCtx(st, current, new)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Snaps, err = plannedRemodelSnaps(st, current, new)
+	if err != nil {
+		return nil, err
+	}
+	for _, sn := range plan.Snaps {
+		if !release.OnClassic && sn.Role != "required" && sn.Action != "none" {
+			plan.RebootRequired = true
+		}
+	}
+	if new.Grade() != asserts.ModelGradeUnset {
+		// a new recovery system is created and tried
+		plan.RebootRequired = true
+	}
+
+	// the tasks of the plan are never run, create them on a copy of the
+	// state that is discarded afterwards
+	planSt := st.Copy()
+	planSt.Lock()
+	defer planSt.Unlock()
+
+	var tss []*state.TaskSet
+	switch remodelKind {
+	case ReregRemodel:
+		// the snaps are checked and the remodel tasks are created
+		// only once the device is registered with the new brand
+		requestSerial := planSt.NewTask("request-serial", i18n.G("Request new device serial"))
+		prepare := planSt.NewTask("prepare-remodeling", i18n.G("Prepare remodeling"))
+		prepare.WaitFor(requestSerial)
+		tss = []*state.TaskSet{state.NewTaskSet(requestSerial, prepare)}
+	case StoreSwitchRemodel:
+		sto := remodCtx.Store()
+		if sto == nil {
+			return nil, fmt.Errorf("internal error: a store switch remodeling should have built a store")
+		}
+		st.Unlock()
+		_, err := sto.EnsureDeviceSession()
+		st.Lock()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get a store session based on the new model assertion: %v", err)
+		}
+		fallthrough
+	case UpdateRemodel:
+		available, err := checkRemodelSnapsAvailable(st, plan, remodCtx)
+		if err != nil {
+			return nil, err
+		}
+		plan.StoreChecked = true
+		if len(available) < len(plan.Snaps) {
+			// the remodel tasks cannot be created
+			break
+		}
+
+		if gadgetInfo := available[new.Gadget()]; gadgetInfo != nil && gadgetChanges(current, new) {
+			if err := checkRemodelGadget(st, gadgetInfo, remodCtx); err != nil {
+				plan.Problems = append(plan.Problems, err.Error())
+			}
+		}
+
+		tss, err = remodelTasks(context.TODO(), planSt, current, new, remodCtx, "", nil)
+		if err != nil {
+			plan.Problems = append(plan.Problems, err.Error())
+		}
+	}
+	plan.Tasks = snapstate.PlannedTasks(tss)
+
+	return plan, nil
+}
+
+func gadgetChanges(current, new *asserts.Model) bool {
+	return current.Gadget() != new.Gadget() || current.GadgetTrack() != new.GadgetTrack()
+}
+
+// plannedRemodelSnaps returns the essential and required snaps of the new
+// model along with the action remodelTasks would take for each of them.
+func plannedRemodelSnaps(st *state.State, current, new *asserts.Model) ([]PlannedRemodelSnap, error) {
+	var snaps []PlannedRemodelSnap
+
+	add := func(name, role, track string, changed, switchable bool) error {
+		if name == "" {
+			return nil
+		}
+		sn := PlannedRemodelSnap{
+			Name:    name,
+			Role:    role,
+			Channel: track,
+			Action:  "none",
+		}
+		var snapst snapstate.SnapState
+		err := snapstate.Get(st, name, &snapst)
+		if err != nil && err != state.ErrNoState {
+			return err
+		}
+		switch {
+		case !snapst.IsInstalled():
+			sn.Action = "install"
+		case changed && switchable:
+			sn.Action = "switch"
+		case changed:
+			sn.Action = "refresh"
+		case track == "":
+			// keep the channel the snap is tracking
+			sn.Channel = snapst.TrackingChannel
+		}
+		if sn.Channel == "" {
+			sn.Channel = "stable"
+		}
+		snaps = append(snaps, sn)
+		return nil
+	}
+
+	if current.Kernel() == new.Kernel() {
+		if err := add(new.Kernel(), "kernel", new.KernelTrack(), current.KernelTrack() != new.KernelTrack(), false); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := add(new.Kernel(), "kernel", new.KernelTrack(), true, true); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(new.Base(), "base", "", current.Base() != new.Base(), true); err != nil {
+		return nil, err
+	}
+	if err := add(new.Gadget(), "gadget", new.GadgetTrack(), gadgetChanges(current, new), false); err != nil {
+		return nil, err
+	}
+	for _, snapRef := range new.RequiredNoEssentialSnaps() {
+		if err := add(snapRef.SnapName(), "required", "", false, false); err != nil {
+			return nil, err
+		}
+	}
+
+	return snaps, nil
+}
+
+// checkRemodelSnapsAvailable asks the store of the remodel context for all
+// the snaps of the plan, recording the revisions found or the reasons for
+// the snaps being unavailable. It returns the information about the
+// available snaps.
+func checkRemodelSnapsAvailable(st *state.State, plan *RemodelPlan, deviceCtx snapstate.DeviceContext) (map[string]*snap.Info, error) {
+	actions := make([]*store.SnapAction, 0, len(plan.Snaps))
+	for _, sn := range plan.Snaps {
+		actions = append(actions, &store.SnapAction{
+			Action:       "install",
+			InstanceName: sn.Name,
+			Channel:      sn.Channel,
+		})
+	}
+
+	sto := snapstate.Store(st, deviceCtx)
+	st.Unlock()
+	results, _, err := sto.SnapAction(context.TODO(), nil, actions, nil, nil, &store.RefreshOptions{})
+	st.Lock()
+
+	unavailable := map[string]error{}
+	if err != nil {
+		saErr, ok := err.(*store.SnapActionError)
+		if !ok || len(saErr.Other) > 0 || (saErr.NoResults && len(saErr.Install) == 0) {
+			return nil, fmt.Errorf("cannot check snaps with the store: %v", err)
+		}
+		unavailable = saErr.Install
+	}
+
+	available := make(map[string]*snap.Info, len(results))
+	for _, res := range results {
+		available[res.InstanceName()] = res.Info
+	}
+
+	for i := range plan.Snaps {
+		sn := &plan.Snaps[i]
+		if info := available[sn.Name]; info != nil {
+			sn.Revision = info.Revision
+			continue
+		}
+		reason := unavailable[sn.Name]
+		if reason == nil {
+			reason = fmt.Errorf("no result from the store")
+		}
+		sn.Unavailable = reason.Error()
+		plan.Problems = append(plan.Problems, fmt.Sprintf("cannot get snap %q from the store: %v", sn.Name, reason))
+	}
+
+	return available, nil
+}
+
+// checkRemodelGadget downloads the new gadget and checks it against the
+// current one in the same way as the remodel would.
+func checkRemodelGadget(st *state.State, gadgetInfo *snap.Info, remodCtx remodelContext) error {
+	if release.OnClassic {
+		return nil
+	}
+	groundCtx := remodCtx.GroundContext()
+
+	currentData, err := currentGadgetInfo(st, groundCtx)
+	if err != nil {
+		return err
+	}
+	if currentData == nil {
+		return fmt.Errorf("cannot identify the current gadget snap")
+	}
+	// as when updating the gadget assets, the current kernel is used
+	if kernelInfo, err := snapstate.CurrentInfo(st, groundCtx.Model().Kernel()); err == nil {
+		currentData.KernelRootDir = kernelInfo.MountDir()
+	}
+
+	tmpDir, err := ioutil.TempDir("", "snapd-remodel-plan")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	snapPath := filepath.Join(tmpDir, gadgetInfo.Filename())
+	sto := snapstate.Store(st, remodCtx)
+	st.Unlock()
+	err = sto.Download(context.TODO(), gadgetInfo.SnapName(), snapPath, &gadgetInfo.DownloadInfo, nil, nil, nil)
+	st.Lock()
+	if err != nil {
+		return fmt.Errorf("cannot download new gadget: %v", err)
+	}
+
+	snapf, err := snapfile.Open(snapPath)
+	if err != nil {
+		return fmt.Errorf("cannot open new gadget: %v", err)
+	}
+	pendingInfo, err := gadget.ReadInfoFromSnapFile(snapf, remodCtx.Model())
+	if err != nil {
+		return fmt.Errorf("cannot read new gadget metadata: %v", err)
+	}
+	if err := gadgetIsCompatible(currentData.Info, pendingInfo); err != nil {
+		return fmt.Errorf("cannot remodel to an incompatible gadget: %v", err)
+	}
+
+	unpackDir := filepath.Join(tmpDir, "unpacked")
+	if err := snapf.Unpack("*", unpackDir); err != nil {
+		return fmt.Errorf("cannot unpack new gadget: %v", err)
+	}
+	pendingData := gadget.GadgetData{
+		Info:          pendingInfo,
+		RootDir:       unpackDir,
+		KernelRootDir: currentData.KernelRootDir,
+	}
+	if err := gadgetCheckUpdate(*currentData, pendingData, gadget.RemodelUpdatePolicy); err != nil && err != gadget.ErrNoUpdate {
+		return fmt.Errorf("cannot update gadget assets: %v", err)
+	}
+	return nil
+}
@@ -124,8 +124,8 @@ func currentSystemForMode(st *state.State, mode string) (*currentSystem, error)
 	case "run":
 		actions = currentSystemActions
 		system, err = currentSeededSystem(st)
-	case "install":
-		// there is no current system for install mode
+	case "install", "factory-reset":
+		// there is no current system for install or factory-reset modes
 		return nil, nil
 	case "recover":
 		actions = recoverSystemActions
@@ -29,9 +29,11 @@ import (
 
 	"github.com/snapcore/snapd/jsonutil"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/randutil"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
 )
 
 // Context represents the context under which the snap is calling back into snapd.
@@ -284,6 +286,50 @@ func (c *Context) ChangeID() string {
 	return ""
 }
 
+// QueueTaskSets adds the given task sets to the change of the hook task so
+// that they run after the tasks of its lanes, but before any final tasks of
+// the change. It fails for ephemeral contexts. Note that the context needs to
+// be locked and unlocked by the caller.
+func (c *Context) QueueTaskSets(tts []*state.TaskSet) error {
+	hookTask, ok := c.Task()
+	if !ok {
+		return fmt.Errorf("attempted to queue command with ephemeral context")
+	}
+
+	change := hookTask.Change()
+	hookTaskLanes := hookTask.Lanes()
+	tasks := change.LaneTasks(hookTaskLanes...)
+
+	// When installing or updating multiple snaps, there is one lane per snap.
+	// We want service command to join respective lane (it's the lane the hook belongs to).
+	// In case there are no lanes, only the default lane no. 0, there is no need to join it.
+	if len(hookTaskLanes) == 1 && hookTaskLanes[0] == 0 {
+		hookTaskLanes = nil
+	}
+	for _, l := range hookTaskLanes {
+		for _, ts := range tts {
+			ts.JoinLane(l)
+		}
+	}
+
+	for _, ts := range tts {
+		for _, t := range tasks {
+			// queue service command after all tasks, except for final tasks which must come after service commands
+			if strutil.ListContains(snapstate.FinalTasks, t.Kind()) {
+				t.WaitAll(ts)
+			} else {
+				ts.WaitFor(t)
+			}
+		}
+		change.AddAll(ts)
+	}
+	// As this can be run from what was originally the last task of a change,
+	// make sure the tasks added to the change are considered immediately.
+	c.state.EnsureBefore(0)
+
+	return nil
+}
+
 // Logf logs to the context, either to the logger for ephemeral contexts
 // or the task log.
 //
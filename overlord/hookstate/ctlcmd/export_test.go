@@ -22,6 +22,7 @@ package ctlcmd
 import (
 	"fmt"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/servicestate"
@@ -48,6 +49,18 @@ func MockDevicestateSystemModeInfoFromState(f func(*state.State) (*devicestate.S
 	return func() { devicestateSystemModeInfoFromState = old }
 }
 
+func MockDevicestateModel(f func(*state.State) (*asserts.Model, error)) (restore func()) {
+	old := devicestateModel
+	devicestateModel = f
+	return func() { devicestateModel = old }
+}
+
+func MockDevicestateSerial(f func(*state.State) (*asserts.Serial, error)) (restore func()) {
+	old := devicestateSerial
+	devicestateSerial = f
+	return func() { devicestateSerial = old }
+}
+
 func AddMockCommand(name string) *MockCommand {
 	return addMockCmd(name, false)
 }
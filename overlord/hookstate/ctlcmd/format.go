@@ -0,0 +1,48 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// formatMixin provides the --format option of the commands that print
+// structured information.
+type formatMixin struct {
+	Format string `long:"format" choice:"json" choice:"yaml" description:"Print the output in the given machine-readable format"`
+}
+
+// formatOutput formats the given value as JSON or YAML.
+func formatOutput(format string, v interface{}) ([]byte, error) {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(v, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	case "yaml":
+		return yaml.Marshal(v)
+	}
+	return nil, fmt.Errorf("internal error: unsupported output format %q", format)
+}
@@ -24,6 +24,8 @@ import (
 	"fmt"
 	"strings"
 
+	"gopkg.in/yaml.v2"
+
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/overlord/configstate"
@@ -34,6 +36,7 @@ import (
 
 type getCommand struct {
 	baseCommand
+	formatMixin
 
 	// these two options are mutually exclusive
 	ForceSlotSide bool `long:"slot" description:"return attribute values from the slot side of the connection"`
@@ -80,6 +83,12 @@ by explicitly requesting that via the --plug and --slot command line options:
     $ snapctl get :myplug --slot usb-vendor
 
 This requests the "usb-vendor" setting from the slot that is connected to "myplug".
+
+With --format=json or --format=yaml a document is always returned, in the
+given format:
+
+    $ snapctl get --format=yaml username
+    username: frank
 `)
 
 func init() {
@@ -101,6 +110,10 @@ func (c *getCommand) printValues(getByKey func(string) (interface{}, bool, error
 		}
 	}
 
+	if c.Format != "" {
+		return c.printFormatted(patch)
+	}
+
 	var confToPrint interface{} = patch
 	if !c.Document && len(c.Positional.Keys) == 1 {
 		confToPrint = patch[c.Positional.Keys[0]]
@@ -130,6 +143,31 @@ func (c *getCommand) printValues(getByKey func(string) (interface{}, bool, error
 	return nil
 }
 
+func (c *getCommand) printFormatted(patch map[string]interface{}) error {
+	var doc interface{} = patch
+	if c.Format == "yaml" {
+		// configuration values are decoded with json.Number, which would
+		// be rendered as quoted strings in YAML; JSON is valid YAML so
+		// round-trip through it to get native YAML scalars
+		b, err := json.Marshal(patch)
+		if err != nil {
+			return err
+		}
+		var normalized interface{}
+		if err := yaml.Unmarshal(b, &normalized); err != nil {
+			return err
+		}
+		doc = normalized
+	}
+
+	b, err := formatOutput(c.Format, doc)
+	if err != nil {
+		return err
+	}
+	c.printf("%s", string(b))
+	return nil
+}
+
 func (c *getCommand) Execute(args []string) error {
 	if len(c.Positional.Keys) == 0 && c.Positional.PlugOrSlotSpec == "" {
 		return fmt.Errorf(i18n.G("get which option?"))
@@ -144,6 +182,10 @@ func (c *getCommand) Execute(args []string) error {
 		return fmt.Errorf("cannot use -d and -t together")
 	}
 
+	if c.Format != "" && (c.Typed || c.Document) {
+		return fmt.Errorf("cannot use --format with -d or -t")
+	}
+
 	if strings.Contains(c.Positional.PlugOrSlotSpec, ":") {
 		parts := strings.SplitN(c.Positional.PlugOrSlotSpec, ":", 2)
 		snap, name := parts[0], parts[1]
@@ -113,6 +113,18 @@ var getTests = []struct {
 }, {
 	args:   "get test-key1 test-key2",
 	stdout: "{\n\t\"test-key1\": \"test-value1\",\n\t\"test-key2\": 2\n}\n",
+}, {
+	args:   "get --format=json test-key1",
+	stdout: "{\n\t\"test-key1\": \"test-value1\"\n}\n",
+}, {
+	args:   "get --format=yaml test-key1 test-key2",
+	stdout: "test-key1: test-value1\ntest-key2: 2\n",
+}, {
+	args:  "get --format=json -d test-key1",
+	error: "cannot use --format with -d or -t",
+}, {
+	args:  "get --format=yaml -t test-key1",
+	error: "cannot use --format with -d or -t",
 }}
 
 func (s *getSuite) TestGetTests(c *C) {
@@ -36,15 +36,6 @@ import (
 	"github.com/snapcore/snapd/snap"
 )
 
-var finalTasks map[string]bool
-
-func init() {
-	finalTasks = make(map[string]bool, len(snapstate.FinalTasks))
-	for _, kind := range snapstate.FinalTasks {
-		finalTasks[kind] = true
-	}
-}
-
 func getServiceInfos(st *state.State, snapName string, serviceNames []string) ([]*snap.AppInfo, error) {
 	st.Lock()
 	defer st.Unlock()
@@ -86,47 +77,10 @@ func getServiceInfos(st *state.State, snapName string, serviceNames []string) ([
 var servicestateControl = servicestate.Control
 
 func queueCommand(context *hookstate.Context, tts []*state.TaskSet) error {
-	hookTask, ok := context.Task()
-	if !ok {
-		return fmt.Errorf("attempted to queue command with ephemeral context")
-	}
-
-	st := context.State()
-	st.Lock()
-	defer st.Unlock()
-
-	change := hookTask.Change()
-	hookTaskLanes := hookTask.Lanes()
-	tasks := change.LaneTasks(hookTaskLanes...)
-
-	// When installing or updating multiple snaps, there is one lane per snap.
-	// We want service command to join respective lane (it's the lane the hook belongs to).
-	// In case there are no lanes, only the default lane no. 0, there is no need to join it.
-	if len(hookTaskLanes) == 1 && hookTaskLanes[0] == 0 {
-		hookTaskLanes = nil
-	}
-	for _, l := range hookTaskLanes {
-		for _, ts := range tts {
-			ts.JoinLane(l)
-		}
-	}
-
-	for _, ts := range tts {
-		for _, t := range tasks {
-			// queue service command after all tasks, except for final tasks which must come after service commands
-			if finalTasks[t.Kind()] {
-				t.WaitAll(ts)
-			} else {
-				ts.WaitFor(t)
-			}
-		}
-		change.AddAll(ts)
-	}
-	// As this can be run from what was originally the last task of a change,
-	// make sure the tasks added to the change are considered immediately.
-	st.EnsureBefore(0)
+	context.Lock()
+	defer context.Unlock()
 
-	return nil
+	return context.QueueTaskSets(tts)
 }
 
 func runServiceCommand(context *hookstate.Context, inst *servicestate.Instruction) error {
@@ -40,6 +40,7 @@ const (
 
 type isConnectedCommand struct {
 	baseCommand
+	formatMixin
 
 	Positional struct {
 		PlugOrSlotSpec string `positional-args:"true" positional-arg-name:"<plug|slot>"`
@@ -68,6 +69,14 @@ classic confinement, or 11 if the other process is not snap confined.
 
 The --pid and --apparmor-label options may only be used with slots of
 interface type "pulseaudio", "audio-record", or "cups-control".
+
+With --format=json or --format=yaml the connection status is also printed
+in the given format; the exit code is the same as without it:
+
+$ snapctl is-connected --format=json plug
+{
+	"connected": false
+}
 `)
 
 func init() {
@@ -87,7 +96,33 @@ func isConnectedPidCheckAllowed(info *snap.Info, plugOrSlot string) bool {
 	return false
 }
 
+type connectedStatus struct {
+	Connected bool `json:"connected" yaml:"connected"`
+}
+
 func (c *isConnectedCommand) Execute(args []string) error {
+	err := c.checkConnected()
+	if c.Format == "" {
+		return err
+	}
+	var status connectedStatus
+	switch err.(type) {
+	case nil:
+		status.Connected = true
+	case *UnsuccessfulError:
+		status.Connected = false
+	default:
+		return err
+	}
+	b, ferr := formatOutput(c.Format, &status)
+	if ferr != nil {
+		return ferr
+	}
+	c.printf("%s", string(b))
+	return err
+}
+
+func (c *isConnectedCommand) checkConnected() error {
 	plugOrSlot := c.Positional.PlugOrSlotSpec
 
 	context := c.context()
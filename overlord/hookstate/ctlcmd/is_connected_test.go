@@ -132,6 +132,20 @@ var isConnectedTests = []struct {
 	// snap1:audio-record slot is not connected to classic snap5
 	args:     []string{"is-connected", "--apparmor-label", "snap.snap5.app", "audio-record"},
 	exitCode: ctlcmd.ClassicSnapCode,
+}, {
+	args:   []string{"is-connected", "--format=json", "plug1"},
+	stdout: "{\n\t\"connected\": true\n}\n",
+}, {
+	args:     []string{"is-connected", "--format=yaml", "plug2"},
+	stdout:   "connected: false\n",
+	exitCode: 1,
+}, {
+	args:     []string{"is-connected", "--format=yaml", "--pid", "42", "cc"},
+	stdout:   "connected: false\n",
+	exitCode: ctlcmd.NotASnapCode,
+}, {
+	args: []string{"is-connected", "--format=json", "foo"},
+	err:  `snap "snap1" has no plug or slot named "foo"`,
 }}
 
 func mockInstalledSnap(c *C, st *state.State, snapYaml string) {
@@ -0,0 +1,143 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type modelCommand struct {
+	baseCommand
+	formatMixin
+
+	Serial    bool `long:"serial" description:"Show the serial assertion of the device instead of the model assertion"`
+	Assertion bool `long:"assertion" description:"Print the full assertion instead of its headers"`
+}
+
+var shortModelHelp = i18n.G("Get the model or serial assertion of the device")
+
+var longModelHelp = i18n.G(`
+The model command returns the headers of the model assertion of the device,
+or of its serial assertion when --serial is given. The headers are printed
+in YAML format, or in JSON format with --format=json. With --assertion the
+full signed assertion is printed instead.
+
+The command is only available to the gadget and kernel snaps of the model
+and to snaps published by the brand of the model.
+
+$ snapctl model
+$ snapctl model --serial --assertion
+`)
+
+func init() {
+	addCommand("model", shortModelHelp, longModelHelp, func() command { return &modelCommand{} })
+}
+
+var (
+	devicestateModel  = devicestate.Model
+	devicestateSerial = devicestate.Serial
+)
+
+// checkModelAccess checks whether the given snap is allowed to access the
+// device identity assertions of the given model.
+func checkModelAccess(st *state.State, model *asserts.Model, snapName string) error {
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Type() == snap.TypeGadget && snapName == model.Gadget():
+		return nil
+	case info.Type() == snap.TypeKernel && snapName == model.Kernel():
+		return nil
+	}
+	if info.SnapID != "" {
+		decl, err := assertstate.SnapDeclaration(st, info.SnapID)
+		if err != nil && !asserts.IsNotFound(err) {
+			return err
+		}
+		if decl != nil && decl.PublisherID() == model.BrandID() {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot use %q: snap %q is not the gadget or kernel of the model nor published by its brand %q", "snapctl model", snapName, model.BrandID())
+}
+
+func (c *modelCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf("cannot run model without a context")
+	}
+	if c.Assertion && c.Format != "" {
+		return fmt.Errorf("cannot use --format with --assertion")
+	}
+
+	st := context.State()
+	st.Lock()
+	defer st.Unlock()
+
+	model, err := devicestateModel(st)
+	if err == state.ErrNoState {
+		return fmt.Errorf("cannot get model assertion: device has no model yet")
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkModelAccess(st, model, context.InstanceName()); err != nil {
+		return err
+	}
+
+	var a asserts.Assertion = model
+	if c.Serial {
+		serial, err := devicestateSerial(st)
+		if err == state.ErrNoState {
+			return fmt.Errorf("cannot get serial assertion: device is not registered yet")
+		}
+		if err != nil {
+			return err
+		}
+		a = serial
+	}
+
+	if c.Assertion {
+		c.printf("%s", asserts.Encode(a))
+		return nil
+	}
+
+	format := c.Format
+	if format == "" {
+		format = "yaml"
+	}
+	b, err := formatOutput(format, a.Headers())
+	if err != nil {
+		return err
+	}
+	c.printf("%s", string(b))
+
+	return nil
+}
@@ -0,0 +1,267 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type modelSuite struct {
+	testutil.BaseTest
+	st          *state.State
+	mockHandler *hooktest.MockHandler
+
+	storeSigning *assertstest.StoreStack
+	db           *asserts.Database
+
+	model  *asserts.Model
+	serial *asserts.Serial
+}
+
+var _ = Suite(&modelSuite{})
+
+func (s *modelSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+	s.st = state.New(nil)
+	s.mockHandler = hooktest.NewMockHandler()
+
+	s.storeSigning = assertstest.NewStoreStack("canonical", nil)
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   s.storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(db.Add(s.storeSigning.StoreAccountKey("")), IsNil)
+	s.db = db
+	s.st.Lock()
+	assertstate.ReplaceDB(s.st, db)
+	s.st.Unlock()
+
+	s.model = assertstest.FakeAssertion(map[string]interface{}{
+		"type":         "model",
+		"authority-id": "my-brand",
+		"series":       "16",
+		"brand-id":     "my-brand",
+		"model":        "my-model",
+		"architecture": "amd64",
+		"gadget":       "pc",
+		"kernel":       "pc-kernel",
+	}).(*asserts.Model)
+	devKey, _ := assertstest.GenerateKey(752)
+	encDevKey, err := asserts.EncodePublicKey(devKey.PublicKey())
+	c.Assert(err, IsNil)
+	s.serial = assertstest.FakeAssertion(map[string]interface{}{
+		"type":                "serial",
+		"authority-id":        "my-brand",
+		"brand-id":            "my-brand",
+		"model":               "my-model",
+		"serial":              "serial-serial",
+		"device-key":          string(encDevKey),
+		"device-key-sha3-384": devKey.PublicKey().ID(),
+	}).(*asserts.Serial)
+
+	s.AddCleanup(ctlcmd.MockDevicestateModel(func(st *state.State) (*asserts.Model, error) {
+		// the mocked function requires the state lock,
+		// panic if it is not held
+		st.Unlock()
+		defer st.Lock()
+		return s.model, nil
+	}))
+	s.AddCleanup(ctlcmd.MockDevicestateSerial(func(st *state.State) (*asserts.Serial, error) {
+		st.Unlock()
+		defer st.Lock()
+		return s.serial, nil
+	}))
+}
+
+func (s *modelSuite) mockSnap(c *C, snapYaml, snapID, publisherID string) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	si := &snap.SideInfo{Revision: snap.R(1), SnapID: snapID}
+	info := snaptest.MockSnapCurrent(c, snapYaml, si)
+	si.RealName = info.SnapName()
+	snapstate.Set(s.st, info.InstanceName(), &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+
+	if snapID != "" {
+		acct := assertstest.NewAccount(s.storeSigning, publisherID, map[string]interface{}{
+			"account-id": publisherID,
+		}, "")
+		c.Assert(s.db.Add(acct), IsNil)
+		snapDecl, err := s.storeSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+			"series":       "16",
+			"snap-id":      snapID,
+			"snap-name":    info.SnapName(),
+			"publisher-id": publisherID,
+			"timestamp":    time.Now().Format(time.RFC3339),
+		}, nil, "")
+		c.Assert(err, IsNil)
+		c.Assert(s.db.Add(snapDecl), IsNil)
+	}
+}
+
+func (s *modelSuite) mockContext(c *C, snapName string) *hookstate.Context {
+	s.st.Lock()
+	defer s.st.Unlock()
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: snapName, Revision: snap.R(1), Hook: "test-hook"}
+	mockContext, err := hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+	return mockContext
+}
+
+func (s *modelSuite) TestModelHeaders(c *C) {
+	s.mockSnap(c, "name: pc\nversion: 1\ntype: gadget\n", "", "")
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext(c, "pc"), []string{"model"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stderr), Equals, "")
+
+	var headers map[string]interface{}
+	c.Assert(yaml.Unmarshal(stdout, &headers), IsNil)
+	c.Check(headers, DeepEquals, map[string]interface{}{
+		"type":              "model",
+		"authority-id":      "my-brand",
+		"series":            "16",
+		"brand-id":          "my-brand",
+		"model":             "my-model",
+		"architecture":      "amd64",
+		"gadget":            "pc",
+		"kernel":            "pc-kernel",
+		"sign-key-sha3-384": s.model.SignKeyID(),
+		"timestamp":         s.model.HeaderString("timestamp"),
+	})
+}
+
+func (s *modelSuite) TestSerialHeaders(c *C) {
+	s.mockSnap(c, "name: pc-kernel\nversion: 1\ntype: kernel\n", "", "")
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext(c, "pc-kernel"), []string{"model", "--serial"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stderr), Equals, "")
+
+	var headers map[string]interface{}
+	c.Assert(yaml.Unmarshal(stdout, &headers), IsNil)
+	c.Check(headers, DeepEquals, map[string]interface{}{
+		"type":                "serial",
+		"authority-id":        "my-brand",
+		"brand-id":            "my-brand",
+		"model":               "my-model",
+		"serial":              "serial-serial",
+		"device-key":          s.serial.HeaderString("device-key"),
+		"device-key-sha3-384": s.serial.DeviceKey().ID(),
+		"sign-key-sha3-384":   s.serial.SignKeyID(),
+		"timestamp":           s.serial.HeaderString("timestamp"),
+	})
+}
+
+func (s *modelSuite) TestModelHeadersJSON(c *C) {
+	s.mockSnap(c, "name: pc\nversion: 1\ntype: gadget\n", "", "")
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext(c, "pc"), []string{"model", "--format=json"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stderr), Equals, "")
+
+	var headers map[string]interface{}
+	c.Assert(json.Unmarshal(stdout, &headers), IsNil)
+	c.Check(headers["model"], Equals, "my-model")
+	c.Check(headers["gadget"], Equals, "pc")
+	c.Check(headers["timestamp"], Equals, s.model.HeaderString("timestamp"))
+
+	_, _, err = ctlcmd.Run(s.mockContext(c, "pc"), []string{"model", "--assertion", "--format=json"}, 0)
+	c.Check(err, ErrorMatches, "cannot use --format with --assertion")
+}
+
+func (s *modelSuite) TestAssertion(c *C) {
+	s.mockSnap(c, "name: agent\nversion: 1\n", "agentidididididididididididididi", "my-brand")
+
+	stdout, _, err := ctlcmd.Run(s.mockContext(c, "agent"), []string{"model", "--assertion"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, string(asserts.Encode(s.model)))
+
+	stdout, _, err = ctlcmd.Run(s.mockContext(c, "agent"), []string{"model", "--serial", "--assertion"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, string(asserts.Encode(s.serial)))
+}
+
+func (s *modelSuite) TestModelNotAllowed(c *C) {
+	// published by someone else than the brand
+	s.mockSnap(c, "name: other\nversion: 1\n", "otheridididididididididididididi", "other-publisher")
+	// unasserted snap
+	s.mockSnap(c, "name: local\nversion: 1\n", "", "")
+	// a gadget snap which is not the gadget of the model
+	s.mockSnap(c, "name: other-gadget\nversion: 1\ntype: gadget\n", "", "")
+
+	for _, name := range []string{"other", "local", "other-gadget"} {
+		stdout, _, err := ctlcmd.Run(s.mockContext(c, name), []string{"model"}, 0)
+		c.Check(err, ErrorMatches, fmt.Sprintf(`cannot use "snapctl model": snap %q is not the gadget or kernel of the model nor published by its brand "my-brand"`, name))
+		c.Check(stdout, HasLen, 0)
+	}
+}
+
+func (s *modelSuite) TestModelNoModelOrSerial(c *C) {
+	s.mockSnap(c, "name: pc\nversion: 1\ntype: gadget\n", "", "")
+
+	s.serial = nil
+	restore := ctlcmd.MockDevicestateSerial(func(st *state.State) (*asserts.Serial, error) {
+		return nil, state.ErrNoState
+	})
+	defer restore()
+	_, _, err := ctlcmd.Run(s.mockContext(c, "pc"), []string{"model", "--serial"}, 0)
+	c.Check(err, ErrorMatches, "cannot get serial assertion: device is not registered yet")
+
+	restore = ctlcmd.MockDevicestateModel(func(st *state.State) (*asserts.Model, error) {
+		return nil, state.ErrNoState
+	})
+	defer restore()
+	_, _, err = ctlcmd.Run(s.mockContext(c, "pc"), []string{"model"}, 0)
+	c.Check(err, ErrorMatches, "cannot get model assertion: device has no model yet")
+}
+
+func (s *modelSuite) TestModelNonRoot(c *C) {
+	s.mockSnap(c, "name: pc\nversion: 1\ntype: gadget\n", "", "")
+
+	_, _, err := ctlcmd.Run(s.mockContext(c, "pc"), []string{"model"}, 1000)
+	c.Check(err, ErrorMatches, `cannot use "model" with uid 1000, try with sudo`)
+}
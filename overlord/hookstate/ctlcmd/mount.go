@@ -0,0 +1,209 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	shortMountHelp = i18n.G("Create a temporary or permanent mount")
+	longMountHelp  = i18n.G(`
+The mount command mounts the given source onto the given target, using a
+systemd mount unit. The mount must be allowed by one of the connected
+mount-control plugs of the snap, which lists the allowed sources, targets,
+filesystem types and options.
+
+    $ snapctl mount -t nfs -o ro,vers=4 server:/export $SNAP_COMMON/backup
+
+Unless --persistent is given, the mount does not survive a reboot.
+`)
+)
+
+func init() {
+	addCommand("mount", shortMountHelp, longMountHelp, func() command { return &mountCommand{} })
+}
+
+type mountCommand struct {
+	baseCommand
+
+	Positional struct {
+		What  string `positional-arg-name:"<what>" required:"yes" description:"path to the mount source"`
+		Where string `positional-arg-name:"<where>" required:"yes" description:"path to the mount target"`
+	} `positional-args:"yes" required:"yes"`
+	Persistent bool   `long:"persistent" description:"Make the mount persist across reboots"`
+	Type       string `short:"t" description:"Filesystem type"`
+	Options    string `short:"o" description:"Comma-separated list of mount options"`
+}
+
+// connectedMountControlPlugs returns the connected mount-control plugs of the
+// given snap.
+func connectedMountControlPlugs(st *state.State, info *snap.Info) ([]*snap.PlugInfo, error) {
+	conns, err := ifacestate.ConnectionStates(st)
+	if err != nil {
+		return nil, fmt.Errorf("internal error: cannot get connections: %s", err)
+	}
+
+	var plugs []*snap.PlugInfo
+	for refStr, connState := range conns {
+		if connState.Undesired || connState.HotplugGone || connState.Interface != "mount-control" {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(refStr)
+		if err != nil {
+			return nil, fmt.Errorf("internal error: %s", err)
+		}
+		if connRef.PlugRef.Snap != info.InstanceName() {
+			continue
+		}
+		if plug := info.Plugs[connRef.PlugRef.Name]; plug != nil {
+			plugs = append(plugs, plug)
+		}
+	}
+	return plugs, nil
+}
+
+// validateMountPath checks that a mount source or target can be written
+// verbatim into a mount unit.
+func validateMountPath(path string) error {
+	for _, r := range path {
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			return fmt.Errorf("cannot mount %q: path cannot contain whitespace or control characters", path)
+		}
+	}
+	return nil
+}
+
+// checkNoSymlinks checks that none of the existing components of the given
+// mount target is a symbolic link, so that a mount cannot be redirected
+// outside of what the mount-control connection allows.
+func checkNoSymlinks(where string) error {
+	path := "/"
+	for _, component := range strings.Split(strings.TrimPrefix(where, "/"), "/") {
+		path = filepath.Join(path, component)
+		fi, err := os.Lstat(filepath.Join(dirs.GlobalRootDir, path))
+		if os.IsNotExist(err) {
+			// systemd creates the missing directories
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("cannot mount at %q: %q is a symbolic link", where, path)
+		}
+	}
+	return nil
+}
+
+func (c *mountCommand) Execute([]string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf("cannot use mount command without a context")
+	}
+
+	what := c.Positional.What
+	where := c.Positional.Where
+	for _, path := range []string{what, where} {
+		if err := validateMountPath(path); err != nil {
+			return err
+		}
+	}
+	if !filepath.IsAbs(where) || filepath.Clean(where) != where {
+		return fmt.Errorf("cannot mount at %q: target must be an absolute, clean path", where)
+	}
+	var options []string
+	if c.Options != "" {
+		options = strings.Split(c.Options, ",")
+	}
+
+	snapName := context.InstanceName()
+
+	st := context.State()
+	st.Lock()
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		st.Unlock()
+		return fmt.Errorf("internal error: cannot get snap info: %s", err)
+	}
+	plugs, err := connectedMountControlPlugs(st, info)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	allowed := false
+	for _, plug := range plugs {
+		entries, err := builtin.MountControlEntries(plug)
+		if err != nil {
+			return fmt.Errorf("internal error: invalid mount-control plug %q: %v", plug.Name, err)
+		}
+		for _, entry := range entries {
+			if entry.Allows(info, what, where, c.Type, options, c.Persistent) {
+				allowed = true
+				break
+			}
+		}
+		if allowed {
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("cannot mount %q at %q: no matching mount-control connection found", what, where)
+	}
+	if err := checkNoSymlinks(where); err != nil {
+		return err
+	}
+
+	lifetime := systemd.Transient
+	if c.Persistent {
+		lifetime = systemd.Persistent
+	}
+	sysd := systemd.New(systemd.SystemMode, progress.Null)
+	_, err = sysd.AddMountUnitFileWithOptions(&systemd.MountUnitOptions{
+		Lifetime: lifetime,
+		SnapName: snapName,
+		Revision: info.Revision.String(),
+		What:     what,
+		Where:    where,
+		Fstype:   c.Type,
+		Options:  builtin.MountControlOptions(options),
+		Origin:   builtin.MountControlOrigin,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot mount %q at %q: %v", what, where, err)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type mountSuite struct {
+	testutil.BaseTest
+	st          *state.State
+	mockContext *hookstate.Context
+	sysdArgs    [][]string
+}
+
+var _ = Suite(&mountSuite{})
+
+const mountControlSnapYaml = `name: consumer
+version: 1
+plugs:
+  mntctl:
+    interface: mount-control
+    mount:
+      - what: /dev/sd*
+        where: /media/*
+        type: [ext4]
+        options: [rw, sync]
+        persistent: true
+      - what: "server:/export"
+        where: $SNAP_COMMON/nfs
+        type: [nfs]
+        options: [ro]
+`
+
+func (s *mountSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+
+	s.sysdArgs = nil
+	s.AddCleanup(systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		s.sysdArgs = append(s.sysdArgs, args)
+		return nil, nil
+	}))
+	s.AddCleanup(osutil.MockMountInfo(""))
+
+	s.st = state.New(nil)
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	mockInstalledSnap(c, s.st, mountControlSnapYaml)
+	s.st.Set("conns", map[string]interface{}{
+		"consumer:mntctl core:mount-control": map[string]interface{}{"interface": "mount-control"},
+	})
+
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "consumer", Revision: snap.R(1), Hook: "test-hook"}
+	var err error
+	s.mockContext, err = hookstate.NewContext(task, s.st, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+}
+
+func (s *mountSuite) TestMountTransient(c *C) {
+	where := filepath.Join(dirs.SnapDataDir, "consumer/common/nfs")
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"mount", "-t", "nfs", "-o", "ro", "server:/export", where}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+
+	unitName := systemd.EscapeUnitNamePath(where) + ".mount"
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, unitName), testutil.FileContains, "X-SnapdOrigin=mount-control\n")
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, unitName), testutil.FileContains, "Type=nfs\nOptions=nodev,nosuid,ro\n")
+	c.Check(s.sysdArgs, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"start", unitName},
+	})
+}
+
+func (s *mountSuite) TestMountPersistent(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "--persistent", "-t", "ext4", "-o", "rw,sync", "/dev/sdb1", "/media/usb"}, 0)
+	c.Assert(err, IsNil)
+
+	c.Check(filepath.Join(dirs.SnapServicesDir, "media-usb.mount"), testutil.FileContains, "What=/dev/sdb1\nWhere=/media/usb\nType=ext4\nOptions=nodev,nosuid,rw,sync\n")
+	c.Check(s.sysdArgs, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"enable", "media-usb.mount"},
+		{"start", "media-usb.mount"},
+	})
+}
+
+func (s *mountSuite) TestMountNotAllowed(c *C) {
+	nfsDir := filepath.Join(dirs.SnapDataDir, "consumer/common/nfs")
+	for _, args := range [][]string{
+		// wrong source
+		{"mount", "-t", "ext4", "/dev/vda1", "/media/usb"},
+		// wrong target
+		{"mount", "-t", "ext4", "/dev/sdb1", "/mnt/usb"},
+		// wrong type
+		{"mount", "-t", "vfat", "/dev/sdb1", "/media/usb"},
+		// missing type
+		{"mount", "/dev/sdb1", "/media/usb"},
+		// option not allowed
+		{"mount", "-t", "ext4", "-o", "exec", "/dev/sdb1", "/media/usb"},
+		// not persistent
+		{"mount", "--persistent", "-t", "nfs", "server:/export", nfsDir},
+	} {
+		_, _, err := ctlcmd.Run(s.mockContext, args, 0)
+		c.Check(err, ErrorMatches, `cannot mount ".*" at ".*": no matching mount-control connection found`, Commentf("%v", args))
+	}
+	c.Check(s.sysdArgs, HasLen, 0)
+}
+
+func (s *mountSuite) TestMountNotConnected(c *C) {
+	s.st.Lock()
+	s.st.Set("conns", map[string]interface{}{
+		"consumer:mntctl core:mount-control": map[string]interface{}{"interface": "mount-control", "undesired": true},
+	})
+	s.st.Unlock()
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "-t", "ext4", "/dev/sdb1", "/media/usb"}, 0)
+	c.Check(err, ErrorMatches, `cannot mount "/dev/sdb1" at "/media/usb": no matching mount-control connection found`)
+	c.Check(s.sysdArgs, HasLen, 0)
+}
+
+func (s *mountSuite) TestMountBadTarget(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "-t", "ext4", "/dev/sdb1", "media/usb"}, 0)
+	c.Check(err, ErrorMatches, `cannot mount at "media/usb": target must be an absolute, clean path`)
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"mount", "-t", "ext4", "/dev/sdb1", "/media/usb/"}, 0)
+	c.Check(err, ErrorMatches, `cannot mount at "/media/usb/": target must be an absolute, clean path`)
+}
+
+func (s *mountSuite) TestMountInvalidPaths(c *C) {
+	for _, t := range []struct {
+		what, where string
+	}{
+		{"/dev/sdb1\nExecStartPre=/bin/sh", "/media/usb"},
+		{"/dev/sdb1", "/media/usb\n[Install]"},
+		{"/dev/sdb1", "/media/my usb"},
+		{"/dev/sdb1\t", "/media/usb"},
+		{"/dev/sdb1", "/media/usb\x00"},
+	} {
+		_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "-t", "ext4", t.what, t.where}, 0)
+		c.Check(err, ErrorMatches, `(?s)cannot mount ".*": path cannot contain whitespace or control characters`, Commentf("%q %q", t.what, t.where))
+	}
+	c.Check(s.sysdArgs, HasLen, 0)
+}
+
+func (s *mountSuite) TestMountTargetThroughSymlink(c *C) {
+	elsewhere := c.MkDir()
+	c.Assert(os.Symlink(elsewhere, filepath.Join(dirs.GlobalRootDir, "/media")), IsNil)
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "-t", "ext4", "/dev/sdb1", "/media/usb"}, 0)
+	c.Check(err, ErrorMatches, `cannot mount at "/media/usb": "/media" is a symbolic link`)
+	c.Check(filepath.Join(dirs.SnapServicesDir, "media-usb.mount"), testutil.FileAbsent)
+	c.Check(s.sysdArgs, HasLen, 0)
+}
+
+func (s *mountSuite) TestMountNonRoot(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "/dev/sdb1", "/media/usb"}, 1000)
+	c.Check(err, ErrorMatches, `cannot use "mount" with uid 1000, try with sudo`)
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"umount", "/media/usb"}, 1000)
+	c.Check(err, ErrorMatches, `cannot use "umount" with uid 1000, try with sudo`)
+}
+
+func (s *mountSuite) TestMountWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"mount", "/dev/sdb1", "/media/usb"}, 0)
+	c.Check(err, ErrorMatches, `cannot use mount command without a context`)
+	_, _, err = ctlcmd.Run(nil, []string{"umount", "/media/usb"}, 0)
+	c.Check(err, ErrorMatches, `cannot use umount command without a context`)
+}
+
+func (s *mountSuite) TestUmount(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "--persistent", "-t", "ext4", "/dev/sdb1", "/media/usb"}, 0)
+	c.Assert(err, IsNil)
+	s.sysdArgs = nil
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"umount", "/media/usb"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+
+	c.Check(filepath.Join(dirs.SnapServicesDir, "media-usb.mount"), testutil.FileAbsent)
+	c.Check(s.sysdArgs, DeepEquals, [][]string{
+		{"disable", "media-usb.mount"},
+		{"daemon-reload"},
+	})
+}
+
+func (s *mountSuite) TestUmountNotOwned(c *C) {
+	// a mount unit of another snap
+	_, err := systemd.New(systemd.SystemMode, nil).AddMountUnitFileWithOptions(&systemd.MountUnitOptions{
+		Lifetime: systemd.Persistent,
+		SnapName: "other",
+		Revision: "1",
+		What:     "/dev/sdb1",
+		Where:    "/media/usb",
+		Origin:   "mount-control",
+	})
+	c.Assert(err, IsNil)
+	s.sysdArgs = nil
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"umount", "/media/usb"}, 0)
+	c.Check(err, ErrorMatches, `cannot unmount "/media/usb": not a mount created by snap "consumer"`)
+	c.Check(filepath.Join(dirs.SnapServicesDir, "media-usb.mount"), testutil.FilePresent)
+	c.Check(s.sysdArgs, HasLen, 0)
+}
@@ -23,8 +23,6 @@ import (
 	"fmt"
 	"time"
 
-	"gopkg.in/yaml.v2"
-
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -33,6 +31,7 @@ import (
 
 type refreshCommand struct {
 	baseCommand
+	formatMixin
 
 	Pending bool `long:"pending" description:"Show pending refreshes of the calling snap"`
 	// these two options are mutually exclusive
@@ -56,6 +55,8 @@ Snap can query pending refreshes with:
     base: false
     restart: false
 
+The pending refreshes can be printed in JSON format with --format=json.
+
 The 'pending' flag can be "ready", "none" or "inhibited". It is set to "none"
 when a snap has no pending refreshes. It is set to "ready" when there are
 pending refreshes and to ”inhibited” when pending refreshes are being
@@ -101,6 +102,9 @@ func (c *refreshCommand) Execute(args []string) error {
 	if c.Proceed && c.Hold {
 		return fmt.Errorf("cannot use --proceed and --hold together")
 	}
+	if c.Format != "" && !c.Pending {
+		return fmt.Errorf("cannot use --format without --pending")
+	}
 
 	// --pending --proceed is a verbose way of saying --proceed, so only
 	// print pending if proceed wasn't requested.
@@ -121,13 +125,13 @@ func (c *refreshCommand) Execute(args []string) error {
 }
 
 type updateDetails struct {
-	Pending  string `yaml:"pending,omitempty"`
-	Channel  string `yaml:"channel,omitempty"`
-	Version  string `yaml:"version,omitempty"`
-	Revision int    `yaml:"revision,omitempty"`
+	Pending  string `json:"pending,omitempty" yaml:"pending,omitempty"`
+	Channel  string `json:"channel,omitempty" yaml:"channel,omitempty"`
+	Version  string `json:"version,omitempty" yaml:"version,omitempty"`
+	Revision int    `json:"revision,omitempty" yaml:"revision,omitempty"`
 	// TODO: epoch
-	Base    bool `yaml:"base"`
-	Restart bool `yaml:"restart"`
+	Base    bool `json:"base" yaml:"base"`
+	Restart bool `json:"restart" yaml:"restart"`
 }
 
 // refreshCandidate is a subset of refreshCandidate defined by snapstate and
@@ -204,7 +208,11 @@ func (c *refreshCommand) printPendingInfo() error {
 	if details == nil {
 		return nil
 	}
-	out, err := yaml.Marshal(details)
+	format := c.Format
+	if format == "" {
+		format = "yaml"
+	}
+	out, err := formatOutput(format, details)
 	if err != nil {
 		return err
 	}
@@ -88,6 +88,16 @@ var refreshFromHookTests = []struct {
 	args:      []string{"refresh", "--pending"},
 	inhibited: true,
 	stdout:    "pending: inhibited\nchannel: stable\nbase: false\nrestart: false\n",
+}, {
+	args:              []string{"refresh", "--pending", "--format=json"},
+	refreshCandidates: map[string]interface{}{"snap1": mockRefreshCandidate("snap1", "", "edge", "v1", snap.Revision{N: 3})},
+	stdout:            "{\n\t\"pending\": \"ready\",\n\t\"channel\": \"edge\",\n\t\"version\": \"v1\",\n\t\"revision\": 3,\n\t\"base\": false,\n\t\"restart\": false\n}\n",
+}, {
+	args:   []string{"refresh", "--pending", "--format=yaml"},
+	stdout: "pending: none\nchannel: stable\nbase: false\nrestart: false\n",
+}, {
+	args: []string{"refresh", "--hold", "--format=json"},
+	err:  "cannot use --format without --pending",
 }}
 
 func (s *refreshSuite) TestRefreshFromHook(c *C) {
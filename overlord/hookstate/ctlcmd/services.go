@@ -23,8 +23,10 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 	"text/tabwriter"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/client/clientutil"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/servicestate"
@@ -36,6 +38,10 @@ var (
 	shortServicesHelp = i18n.G("Query the status of services")
 	longServicesHelp  = i18n.G(`
 The services command lists information about the services specified.
+
+With --format=json or --format=yaml the information is printed in the given
+format, as a list of services with their name, whether they are enabled and
+active, and any notes. The active state is omitted for user services.
 `)
 )
 
@@ -45,6 +51,7 @@ func init() {
 
 type servicesCommand struct {
 	baseCommand
+	formatMixin
 	Positional struct {
 		ServiceNames []string `positional-arg-name:"<service>"`
 	} `positional-args:"yes"`
@@ -52,6 +59,13 @@ type servicesCommand struct {
 
 var errNoContextForServices = errors.New(i18n.G("cannot query services without a context"))
 
+type serviceStatus struct {
+	Service string   `json:"service" yaml:"service"`
+	Enabled bool     `json:"enabled" yaml:"enabled"`
+	Active  *bool    `json:"active,omitempty" yaml:"active,omitempty"`
+	Notes   []string `json:"notes,omitempty" yaml:"notes,omitempty"`
+}
+
 type byApp []*snap.AppInfo
 
 func (a byApp) Len() int      { return len(a) }
@@ -76,10 +90,17 @@ func (c *servicesCommand) Execute([]string) error {
 	sd := servicestate.NewStatusDecorator(progress.Null)
 
 	services, err := clientutil.ClientAppInfosFromSnapAppInfos(svcInfos, sd)
-	if err != nil || len(services) == 0 {
+	if err != nil {
 		return err
 	}
 
+	if c.Format != "" {
+		return c.printFormatted(services)
+	}
+	if len(services) == 0 {
+		return nil
+	}
+
 	w := tabwriter.NewWriter(c.stdout, 5, 3, 2, ' ', 0)
 	defer w.Flush()
 
@@ -101,3 +122,28 @@ func (c *servicesCommand) Execute([]string) error {
 
 	return nil
 }
+
+func (c *servicesCommand) printFormatted(services []client.AppInfo) error {
+	statuses := make([]serviceStatus, 0, len(services))
+	for _, svc := range services {
+		status := serviceStatus{
+			Service: svc.Snap + "." + svc.Name,
+			Enabled: svc.Enabled,
+		}
+		if svc.DaemonScope != snap.UserDaemon {
+			active := svc.Active
+			status.Active = &active
+		}
+		if notes := clientutil.ClientAppInfoNotes(&svc); notes != "-" {
+			status.Notes = strings.Split(notes, ",")
+		}
+		statuses = append(statuses, status)
+	}
+
+	b, err := formatOutput(c.Format, statuses)
+	if err != nil {
+		return err
+	}
+	c.printf("%s", string(b))
+	return nil
+}
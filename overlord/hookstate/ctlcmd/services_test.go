@@ -639,3 +639,49 @@ test-snap.test-service  enabled  active   -
 `[1:])
 	c.Check(string(stderr), Equals, "")
 }
+
+func (s *servicectlSuite) TestServicesFormat(c *C) {
+	restore := systemd.MockSystemctl(func(args ...string) (buf []byte, err error) {
+		switch args[0] {
+		case "show":
+			return []byte(fmt.Sprintf(`Id=%s
+Type=simple
+ActiveState=inactive
+UnitFileState=disabled
+`, args[2])), nil
+		case "--user":
+			return []byte("enabled\n"), nil
+		default:
+			c.Errorf("unexpected systemctl command: %v", args)
+			return nil, fmt.Errorf("should not be reached")
+		}
+	})
+	defer restore()
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"services", "--format=json", "test-snap.test-service", "test-snap.user-service"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `[
+	{
+		"service": "test-snap.test-service",
+		"enabled": false,
+		"active": false
+	},
+	{
+		"service": "test-snap.user-service",
+		"enabled": true,
+		"notes": [
+			"user"
+		]
+	}
+]
+`)
+	c.Check(string(stderr), Equals, "")
+
+	stdout, _, err = ctlcmd.Run(s.mockContext, []string{"services", "--format=yaml", "test-snap.test-service"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `
+- service: test-snap.test-service
+  enabled: false
+  active: false
+`[1:])
+}
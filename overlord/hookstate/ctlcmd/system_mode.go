@@ -22,8 +22,6 @@ package ctlcmd
 import (
 	"fmt"
 
-	"gopkg.in/yaml.v2"
-
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/strutil"
@@ -31,6 +29,7 @@ import (
 
 type systemModeCommand struct {
 	baseCommand
+	formatMixin
 }
 
 var shortSystemModeHelp = i18n.G("Get the current system mode and associated details")
@@ -42,7 +41,8 @@ This information includes the mode itself and whether the model snaps have been
 
 Retrieved information can also include "factory mode" details: 'factory: true' declares whether the device booted an image flagged as for factory use. This flag can be set for convenience when building the image. No security sensitive decisions should be based on this bit alone.
 
-The output is in YAML format. Example output:
+The output is in YAML format, or in JSON format with --format=json. Example
+output:
     $ snapctl system-mode
     system-mode: install
     seed-loaded: true
@@ -56,9 +56,9 @@ func init() {
 var devicestateSystemModeInfoFromState = devicestate.SystemModeInfoFromState
 
 type systemModeResult struct {
-	SystemMode string `yaml:"system-mode,omitempty"`
-	Seeded     bool   `yaml:"seed-loaded"`
-	Factory    bool   `yaml:"factory,omitempty"`
+	SystemMode string `json:"system-mode,omitempty" yaml:"system-mode,omitempty"`
+	Seeded     bool   `json:"seed-loaded" yaml:"seed-loaded"`
+	Factory    bool   `json:"factory,omitempty" yaml:"factory,omitempty"`
 }
 
 func (c *systemModeCommand) Execute(args []string) error {
@@ -84,7 +84,11 @@ func (c *systemModeCommand) Execute(args []string) error {
 		res.Factory = true
 	}
 
-	b, err := yaml.Marshal(res)
+	format := c.Format
+	if format == "" {
+		format = "yaml"
+	}
+	b, err := formatOutput(format, res)
 	if err != nil {
 		return err
 	}
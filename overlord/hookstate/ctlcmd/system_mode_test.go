@@ -124,3 +124,39 @@ func (s *systemModeSuite) TestSystemMode(c *C) {
 		}
 	}
 }
+
+func (s *systemModeSuite) TestSystemModeFormat(c *C) {
+	s.st.Lock()
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "snap1", Revision: snap.R(1), Hook: "test-hook"}
+	mockContext, err := hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Check(err, IsNil)
+	s.st.Unlock()
+
+	r := ctlcmd.MockDevicestateSystemModeInfoFromState(func(s *state.State) (*devicestate.SystemModeInfo, error) {
+		return &devicestate.SystemModeInfo{
+			Mode:       "install",
+			HasModeenv: true,
+			Seeded:     true,
+			BootFlags:  []string{"factory"},
+		}, nil
+	})
+	defer r()
+
+	stdout, stderr, err := ctlcmd.Run(mockContext, []string{"system-mode", "--format=json"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `{
+	"system-mode": "install",
+	"seed-loaded": true,
+	"factory": true
+}
+`)
+	c.Check(string(stderr), Equals, "")
+
+	stdout, _, err = ctlcmd.Run(mockContext, []string{"system-mode", "--format=yaml"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "system-mode: install\nseed-loaded: true\nfactory: true\n")
+
+	_, _, err = ctlcmd.Run(mockContext, []string{"system-mode", "--format=xml"}, 0)
+	c.Check(err, ErrorMatches, `.*Invalid value .xml. for option .--format.*`)
+}
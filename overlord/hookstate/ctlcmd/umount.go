@@ -0,0 +1,77 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	shortUmountHelp = i18n.G("Remove a mount")
+	longUmountHelp  = i18n.G(`
+The umount command unmounts the given mount target and removes its systemd
+mount unit. Only mounts created by the snap with "snapctl mount" can be
+unmounted.
+
+    $ snapctl umount $SNAP_COMMON/backup
+`)
+)
+
+func init() {
+	addCommand("umount", shortUmountHelp, longUmountHelp, func() command { return &umountCommand{} })
+}
+
+type umountCommand struct {
+	baseCommand
+
+	Positional struct {
+		Where string `positional-arg-name:"<where>" required:"yes" description:"path to the mount target"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *umountCommand) Execute([]string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf("cannot use umount command without a context")
+	}
+
+	where := c.Positional.Where
+	sysd := systemd.New(systemd.SystemMode, progress.Null)
+	mountPoints, err := sysd.ListMountUnits(context.InstanceName(), builtin.MountControlOrigin)
+	if err != nil {
+		return fmt.Errorf("cannot list mounts of snap %q: %v", context.InstanceName(), err)
+	}
+	if !strutil.ListContains(mountPoints, where) {
+		return fmt.Errorf("cannot unmount %q: not a mount created by snap %q", where, context.InstanceName())
+	}
+
+	if err := sysd.RemoveMountUnitFile(filepath.Join(dirs.GlobalRootDir, where)); err != nil {
+		return fmt.Errorf("cannot unmount %q: %v", where, err)
+	}
+	return nil
+}
@@ -21,8 +21,12 @@ package hookstate
 
 import (
 	"time"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
 )
 
+var MaintenanceTimersParticipant snapstate.LinkSnapParticipant = maintenanceTimersParticipant{}
+
 func MockReadlink(f func(string) (string, error)) func() {
 	oldReadlink := osReadlink
 	osReadlink = f
@@ -59,6 +59,11 @@ type HookManager struct {
 
 	runningHooks int32
 	runner       *state.TaskRunner
+
+	// maintenanceTimers caches the maintenance hook timers of the
+	// active snaps, it is loaded by the first ensure and kept up to
+	// date on snap link/unlink
+	maintenanceTimers map[string]*maintenanceTimer
 }
 
 // Handler is the interface a client must satify to handle hooks.
@@ -136,6 +141,10 @@ func Manager(s *state.State, runner *state.TaskRunner) (*HookManager, error) {
 
 	snapstate.AddAffectedSnapsByAttr("hook-setup", manager.hookAffectedSnaps)
 
+	s.Lock()
+	s.Cache(hookMgrKey{}, manager)
+	s.Unlock()
+
 	return manager, nil
 }
 
@@ -147,7 +156,7 @@ func (m *HookManager) Register(pattern *regexp.Regexp, generator HandlerGenerato
 
 // Ensure implements StateManager.Ensure.
 func (m *HookManager) Ensure() error {
-	return nil
+	return m.ensureMaintenanceHooks()
 }
 
 // StopHooks kills all currently running hooks and returns after
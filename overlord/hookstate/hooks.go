@@ -54,11 +54,12 @@ func SetupInstallHook(st *state.State, snapName string) *state.Task {
 	return task
 }
 
-func SetupPostRefreshHook(st *state.State, snapName string) *state.Task {
+func SetupPostRefreshHook(st *state.State, snapName string, ignoreError bool) *state.Task {
 	hooksup := &HookSetup{
-		Snap:     snapName,
-		Hook:     "post-refresh",
-		Optional: true,
+		Snap:        snapName,
+		Hook:        "post-refresh",
+		Optional:    true,
+		IgnoreError: ignoreError,
 	}
 
 	summary := fmt.Sprintf(i18n.G("Run post-refresh hook of %q snap if present"), hooksup.Snap)
@@ -328,5 +329,6 @@ func setupHooks(hookMgr *HookManager) {
 	hookMgr.Register(regexp.MustCompile("^post-refresh$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^pre-refresh$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^remove$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^maintenance$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^gate-auto-refresh$"), gateAutoRefreshHandlerGenerator)
 }
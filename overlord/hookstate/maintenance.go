@@ -0,0 +1,245 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package hookstate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+// maxMaintenanceInterval is the longest time between two runs of a
+// maintenance hook, regardless of its timer
+var maxMaintenanceInterval = 32 * 24 * time.Hour
+
+// SetupMaintenanceHook returns a task running the maintenance hook of the
+// given snap.
+func SetupMaintenanceHook(st *state.State, snapName string) *state.Task {
+	hooksup := &HookSetup{
+		Snap:     snapName,
+		Hook:     "maintenance",
+		Optional: true,
+	}
+
+	summary := fmt.Sprintf(i18n.G("Run maintenance hook of %q snap"), hooksup.Snap)
+	return HookTask(st, summary, hooksup, nil)
+}
+
+// maintenanceSchedule tracks when the maintenance hook of a snap runs next.
+type maintenanceSchedule struct {
+	// Timer is the timer of the hook the next run was computed from
+	Timer   string    `json:"timer"`
+	Next    time.Time `json:"next"`
+	LastRun time.Time `json:"last-run,omitempty"`
+}
+
+// maintenanceTimer is the parsed timer of the maintenance hook of a snap.
+type maintenanceTimer struct {
+	timer    string
+	schedule []*timeutil.Schedule
+}
+
+// readMaintenanceTimer returns the timer of the maintenance hook of the
+// current revision of the given snap, or nil if the snap is not active or
+// its hook has no timer.
+func readMaintenanceTimer(instanceName string, snapst *snapstate.SnapState) (*maintenanceTimer, error) {
+	if !snapst.Active {
+		return nil, nil
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+	hook := info.Hooks["maintenance"]
+	if hook == nil || hook.Timer == "" {
+		return nil, nil
+	}
+	schedule, err := timeutil.ParseSchedule(hook.Timer)
+	if err != nil {
+		// the timer is validated when the snap is installed
+		logger.Noticef("cannot parse timer of maintenance hook of snap %q: %v", instanceName, err)
+		return nil, nil
+	}
+	return &maintenanceTimer{timer: hook.Timer, schedule: schedule}, nil
+}
+
+// loadMaintenanceTimers fills the cache of maintenance hook timers from the
+// current revisions of all the snaps.
+func (m *HookManager) loadMaintenanceTimers() error {
+	allStates, err := snapstate.All(m.state)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	timers := make(map[string]*maintenanceTimer)
+	for instanceName, snapst := range allStates {
+		timer, err := readMaintenanceTimer(instanceName, snapst)
+		if err != nil {
+			return err
+		}
+		if timer != nil {
+			timers[instanceName] = timer
+		}
+	}
+	m.maintenanceTimers = timers
+	return nil
+}
+
+func init() {
+	// the participant looks up the hook manager associated with the
+	// state, so it is registered only once
+	snapstate.AddLinkSnapParticipant(maintenanceTimersParticipant{})
+}
+
+type hookMgrKey struct{}
+
+// maintenanceTimersParticipant implements snapstate.LinkSnapParticipant, it
+// refreshes the cached maintenance hook timer of the snap in the hook manager
+// associated with the state.
+type maintenanceTimersParticipant struct{}
+
+func (maintenanceTimersParticipant) SnapLinkageChanged(st *state.State, instanceName string) error {
+	m, _ := st.Cached(hookMgrKey{}).(*HookManager)
+	if m == nil {
+		return nil
+	}
+	return m.refreshMaintenanceTimer(instanceName)
+}
+
+// refreshMaintenanceTimer refreshes the cached maintenance hook timer of the
+// snap.
+func (m *HookManager) refreshMaintenanceTimer(instanceName string) error {
+	st := m.state
+	if m.maintenanceTimers == nil {
+		// not loaded yet
+		return nil
+	}
+	delete(m.maintenanceTimers, instanceName)
+
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, instanceName, &snapst); err != nil {
+		if err == state.ErrNoState {
+			return nil
+		}
+		return err
+	}
+	timer, err := readMaintenanceTimer(instanceName, &snapst)
+	if err != nil {
+		return err
+	}
+	if timer != nil {
+		m.maintenanceTimers[instanceName] = timer
+	}
+	return nil
+}
+
+// ensureMaintenanceHooks runs the maintenance hooks of the snaps whose next
+// run according to their timer is due, each run is a change of its own.
+func (m *HookManager) ensureMaintenanceHooks() error {
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	var seeded bool
+	err := st.Get("seeded", &seeded)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !seeded {
+		return nil
+	}
+
+	if m.maintenanceTimers == nil {
+		if err := m.loadMaintenanceTimers(); err != nil {
+			return err
+		}
+	}
+
+	var schedules map[string]*maintenanceSchedule
+	if err := st.Get("maintenance-hooks", &schedules); err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	now := time.Now()
+	newSchedules := make(map[string]*maintenanceSchedule)
+	changed := false
+	var nextEnsure time.Duration
+	for instanceName, timer := range m.maintenanceTimers {
+		sched := schedules[instanceName]
+		if sched == nil || sched.Timer != timer.timer {
+			// first seen or the timer changed with a refresh
+			sched = &maintenanceSchedule{
+				Timer: timer.timer,
+				Next:  now.Add(timeutil.Next(timer.schedule, now, maxMaintenanceInterval)),
+			}
+			if old := schedules[instanceName]; old != nil {
+				sched.LastRun = old.LastRun
+			}
+			changed = true
+		}
+		newSchedules[instanceName] = sched
+
+		if delta := sched.Next.Sub(now); delta > 0 {
+			if nextEnsure == 0 || delta < nextEnsure {
+				nextEnsure = delta
+			}
+			continue
+		}
+
+		if err := snapstate.CheckChangeConflict(st, instanceName, nil); err != nil {
+			if _, ok := err.(*snapstate.ChangeConflictError); ok {
+				// try again with the next ensure
+				logger.Debugf("cannot run maintenance hook of snap %q: %v", instanceName, err)
+				continue
+			}
+			return err
+		}
+
+		logger.Debugf("running maintenance hook of snap %q", instanceName)
+		task := SetupMaintenanceHook(st, instanceName)
+		chg := st.NewChange("maintenance-hook", task.Summary())
+		chg.AddTask(task)
+
+		sched.LastRun = now
+		delta := timeutil.Next(timer.schedule, now, maxMaintenanceInterval)
+		sched.Next = now.Add(delta)
+		if nextEnsure == 0 || delta < nextEnsure {
+			nextEnsure = delta
+		}
+		changed = true
+	}
+
+	// drop the schedules of snaps that are gone or lost their hook
+	if changed || len(newSchedules) != len(schedules) {
+		if len(newSchedules) == 0 {
+			st.Set("maintenance-hooks", nil)
+		} else {
+			st.Set("maintenance-hooks", newSchedules)
+		}
+	}
+	if nextEnsure > 0 {
+		st.EnsureBefore(nextEnsure)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package hookstate_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+const snapWithMaintenanceYaml = `name: snap-a
+version: 1
+hooks:
+    maintenance:
+        timer: "00:00"
+`
+
+type maintenanceHookSuite struct {
+	baseHookManagerSuite
+}
+
+var _ = Suite(&maintenanceHookSuite{})
+
+func (s *maintenanceHookSuite) SetUpTest(c *C) {
+	s.commonSetUpTest(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("seeded", true)
+
+	si := &snap.SideInfo{RealName: "snap-a", SnapID: "snap-a-id1", Revision: snap.R(1)}
+	snaptest.MockSnap(c, snapWithMaintenanceYaml, si)
+	snapstate.Set(s.state, "snap-a", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+	})
+
+	si2 := &snap.SideInfo{RealName: "snap-b", SnapID: "snap-b-id1", Revision: snap.R(1)}
+	snaptest.MockSnap(c, snapbYaml, si2)
+	snapstate.Set(s.state, "snap-b", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si2},
+		Current:  snap.R(1),
+	})
+}
+
+func (s *maintenanceHookSuite) TearDownTest(c *C) {
+	s.commonTearDownTest(c)
+}
+
+type maintenanceSchedule struct {
+	Timer   string    `json:"timer"`
+	Next    time.Time `json:"next"`
+	LastRun time.Time `json:"last-run"`
+}
+
+func (s *maintenanceHookSuite) schedules(c *C) map[string]*maintenanceSchedule {
+	var schedules map[string]*maintenanceSchedule
+	err := s.state.Get("maintenance-hooks", &schedules)
+	if err == state.ErrNoState {
+		return nil
+	}
+	c.Assert(err, IsNil)
+	return schedules
+}
+
+func (s *maintenanceHookSuite) TestSetupMaintenanceHook(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	task := hookstate.SetupMaintenanceHook(s.state, "snap-a")
+	c.Check(task.Kind(), Equals, "run-hook")
+	c.Check(task.Summary(), Equals, `Run maintenance hook of "snap-a" snap`)
+
+	var hooksup hookstate.HookSetup
+	c.Assert(task.Get("hook-setup", &hooksup), IsNil)
+	c.Check(hooksup, DeepEquals, hookstate.HookSetup{
+		Snap:     "snap-a",
+		Hook:     "maintenance",
+		Optional: true,
+	})
+}
+
+func (s *maintenanceHookSuite) TestNotSeeded(c *C) {
+	s.state.Lock()
+	s.state.Set("seeded", nil)
+	s.state.Unlock()
+
+	c.Assert(s.manager.Ensure(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.schedules(c), IsNil)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *maintenanceHookSuite) TestFirstSeenSchedulesNextRun(c *C) {
+	before := time.Now()
+	c.Assert(s.manager.Ensure(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the next run is scheduled on the timer, the hook is not run yet
+	schedules := s.schedules(c)
+	c.Assert(schedules, HasLen, 1)
+	sched := schedules["snap-a"]
+	c.Assert(sched, NotNil)
+	c.Check(sched.Timer, Equals, "00:00")
+	c.Check(sched.Next.After(before), Equals, true)
+	c.Check(sched.Next.Before(before.Add(24*time.Hour+time.Minute)), Equals, true)
+	c.Check(sched.LastRun.IsZero(), Equals, true)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *maintenanceHookSuite) TestTimerChangeReschedules(c *C) {
+	s.state.Lock()
+	s.state.Set("maintenance-hooks", map[string]*maintenanceSchedule{
+		"snap-a": {Timer: "mon,10:00", Next: time.Now().Add(-time.Hour)},
+	})
+	s.state.Unlock()
+
+	c.Assert(s.manager.Ensure(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	sched := s.schedules(c)["snap-a"]
+	c.Assert(sched, NotNil)
+	c.Check(sched.Timer, Equals, "00:00")
+	c.Check(sched.Next.After(time.Now()), Equals, true)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *maintenanceHookSuite) TestRunsWhenTimerElapsed(c *C) {
+	var hookRuns []string
+	restore := hookstate.MockRunHook(func(ctx *hookstate.Context, tomb *tomb.Tomb) ([]byte, error) {
+		hookRuns = append(hookRuns, ctx.InstanceName()+":"+ctx.HookName())
+		return nil, nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	s.state.Set("maintenance-hooks", map[string]*maintenanceSchedule{
+		"snap-a": {Timer: "00:00", Next: time.Now().Add(-time.Minute)},
+	})
+	s.state.Unlock()
+
+	before := time.Now()
+	c.Assert(s.manager.Ensure(), IsNil)
+
+	s.state.Lock()
+	chgs := s.state.Changes()
+	sched := s.schedules(c)["snap-a"]
+	s.state.Unlock()
+	c.Assert(chgs, HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), Equals, "maintenance-hook")
+	c.Check(chg.Summary(), Equals, `Run maintenance hook of "snap-a" snap`)
+	c.Assert(sched, NotNil)
+	c.Check(sched.LastRun.Before(before), Equals, false)
+	c.Check(sched.Next.After(before), Equals, true)
+
+	c.Assert(s.o.Settle(5*time.Second), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(hookRuns, DeepEquals, []string{"snap-a:maintenance"})
+	// the hook is not run again until the timer elapses again
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *maintenanceHookSuite) TestSkippedOnConflict(c *C) {
+	s.state.Lock()
+	s.state.Set("maintenance-hooks", map[string]*maintenanceSchedule{
+		"snap-a": {Timer: "00:00", Next: time.Now().Add(-time.Minute)},
+	})
+	chg := s.state.NewChange("refresh-snap", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "snap-a", Revision: snap.R(2)},
+	})
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	c.Assert(s.manager.Ensure(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *maintenanceHookSuite) TestRecordsOfGoneSnapsDropped(c *C) {
+	s.state.Lock()
+	next := time.Now().Add(time.Hour)
+	s.state.Set("maintenance-hooks", map[string]*maintenanceSchedule{
+		"snap-a": {Timer: "00:00", Next: next},
+		"snap-c": {Timer: "00:00", Next: time.Now().Add(-time.Minute)},
+	})
+	s.state.Unlock()
+
+	c.Assert(s.manager.Ensure(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	schedules := s.schedules(c)
+	c.Check(schedules, HasLen, 1)
+	c.Assert(schedules["snap-a"], NotNil)
+	c.Check(schedules["snap-a"].Next.Equal(next), Equals, true)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *maintenanceHookSuite) TestTimersRefreshedOnLinkageChange(c *C) {
+	c.Assert(s.manager.Ensure(), IsNil)
+
+	s.state.Lock()
+	c.Check(s.schedules(c)["snap-a"].Timer, Equals, "00:00")
+	// the snap metadata is not read again by the next ensure
+	si := &snap.SideInfo{RealName: "snap-a", SnapID: "snap-a-id1", Revision: snap.R(1)}
+	snaptest.MockSnap(c, `name: snap-a
+version: 1
+hooks:
+    maintenance:
+        timer: "mon,10:00"
+`, si)
+	s.state.Unlock()
+
+	c.Assert(s.manager.Ensure(), IsNil)
+
+	s.state.Lock()
+	c.Check(s.schedules(c)["snap-a"].Timer, Equals, "00:00")
+	c.Assert(hookstate.MaintenanceTimersParticipant.SnapLinkageChanged(s.state, "snap-a"), IsNil)
+	s.state.Unlock()
+
+	c.Assert(s.manager.Ensure(), IsNil)
+
+	s.state.Lock()
+	c.Check(s.schedules(c)["snap-a"].Timer, Equals, "mon,10:00")
+	// the snap gets unlinked
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "snap-a", &snapst), IsNil)
+	snapst.Active = false
+	snapstate.Set(s.state, "snap-a", &snapst)
+	c.Assert(hookstate.MaintenanceTimersParticipant.SnapLinkageChanged(s.state, "snap-a"), IsNil)
+	s.state.Unlock()
+
+	c.Assert(s.manager.Ensure(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.schedules(c), IsNil)
+}
+
+func (s *maintenanceHookSuite) TestLinkageChangeWithoutManager(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	// no hook manager is associated with the state
+	c.Assert(hookstate.MaintenanceTimersParticipant.SnapLinkageChanged(st, "snap-a"), IsNil)
+}
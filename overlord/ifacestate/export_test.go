@@ -25,6 +25,7 @@ import (
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/timings"
+	"github.com/snapcore/snapd/wrappers"
 )
 
 var (
@@ -164,6 +165,14 @@ func MockWriteSystemKey(fn func() error) func() {
 	return func() { writeSystemKey = old }
 }
 
+// MockEnsureSnapServiceOrdering mocks the function ensuring the ordering of
+// services against the services of connected snaps.
+func MockEnsureSnapServiceOrdering(fn func(s *snap.Info, ordering *wrappers.ServiceOrdering, opts *wrappers.EnsureSnapServicesOptions) error) func() {
+	old := ensureSnapServiceOrdering
+	ensureSnapServiceOrdering = fn
+	return func() { ensureSnapServiceOrdering = old }
+}
+
 func (m *InterfaceManager) TransitionConnectionsCoreMigration(st *state.State, oldName, newName string) error {
 	return m.transitionConnectionsCoreMigration(st, oldName, newName)
 }
@@ -633,6 +633,14 @@ func (m *InterfaceManager) doDisconnect(task *state.Task, _ *tomb.Tomb) error {
 	}
 	setConns(st, conns)
 
+	// mounts created via mount-control are not undone by the security
+	// backends, remove the ones the snap is not allowed to have anymore
+	if conn.Interface == "mount-control" {
+		if err := removeStaleMountControlMounts(st, plugRef.Snap, conns); err != nil {
+			task.Errorf("cannot remove mounts of snap %q: %v", plugRef.Snap, err)
+		}
+	}
+
 	return nil
 }
 
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -39,8 +40,11 @@ import (
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/timings"
+	"github.com/snapcore/snapd/wrappers"
 )
 
 func (m *InterfaceManager) selectInterfaceMapper(snaps []*snap.Info) {
@@ -110,6 +114,9 @@ func profilesNeedRegenerationImpl() bool {
 
 var profilesNeedRegeneration = profilesNeedRegenerationImpl
 var writeSystemKey = interfaces.WriteSystemKey
+var ensureSnapServiceOrdering = func(s *snap.Info, ordering *wrappers.ServiceOrdering, opts *wrappers.EnsureSnapServicesOptions) error {
+	return wrappers.EnsureSnapServiceOrdering(s, ordering, opts, progress.Null)
+}
 
 // regenerateAllSecurityProfiles will regenerate all security profiles.
 func (m *InterfaceManager) regenerateAllSecurityProfiles(tm timings.Measurer) error {
@@ -389,9 +396,86 @@ func (m *InterfaceManager) setupSecurityByBackend(task *state.Task, snaps []*sna
 		}
 	}
 
+	// Regenerate the ordering of services against the services of
+	// connected snaps, as the connections may have changed.
+	ensureOpts := &wrappers.EnsureSnapServicesOptions{Preseeding: m.preseed}
+	for _, snapInfo := range snaps {
+		if len(snapInfo.Services()) == 0 {
+			continue
+		}
+		ordering, err := serviceOrdering(m.repo, snapInfo)
+		if err != nil {
+			return err
+		}
+		if err := ensureSnapServiceOrdering(snapInfo, ordering, ensureOpts); err != nil {
+			return fmt.Errorf("cannot setup service ordering for snap %q: %v", snapInfo.InstanceName(), err)
+		}
+	}
+
 	return nil
 }
 
+// serviceOrdering returns the ordering of the services of the given snap
+// against the services of connected snaps, as declared with the
+// after-services and before-services attributes of the plugs and slots
+// of the snap.
+func serviceOrdering(repo *interfaces.Repository, snapInfo *snap.Info) (*wrappers.ServiceOrdering, error) {
+	instanceName := snapInfo.InstanceName()
+	conns, err := repo.Connections(instanceName)
+	if err != nil {
+		return nil, err
+	}
+
+	ordering := &wrappers.ServiceOrdering{}
+	for _, connRef := range conns {
+		if connRef.PlugRef.Snap == connRef.SlotRef.Snap {
+			// ordering within a snap is expressed with after/before
+			// of the apps
+			continue
+		}
+		var attrs map[string]interface{}
+		var peer *snap.Info
+		if connRef.PlugRef.Snap == instanceName {
+			plug := repo.Plug(instanceName, connRef.PlugRef.Name)
+			slot := repo.Slot(connRef.SlotRef.Snap, connRef.SlotRef.Name)
+			if plug == nil || slot == nil {
+				continue
+			}
+			attrs, peer = plug.Attrs, slot.Snap
+		} else {
+			plug := repo.Plug(connRef.PlugRef.Snap, connRef.PlugRef.Name)
+			slot := repo.Slot(instanceName, connRef.SlotRef.Name)
+			if plug == nil || slot == nil {
+				continue
+			}
+			attrs, peer = slot.Attrs, plug.Snap
+		}
+		after, before, err := snap.ServiceOrderingAttrs(attrs)
+		if err != nil {
+			return nil, fmt.Errorf("cannot order services of snap %q: %v", instanceName, err)
+		}
+		ordering.After = append(ordering.After, peerServiceNames(snapInfo, peer, after)...)
+		ordering.Before = append(ordering.Before, peerServiceNames(snapInfo, peer, before)...)
+	}
+	return ordering, nil
+}
+
+// peerServiceNames returns the systemd unit names of the given services of
+// the peer snap. Names which are not system services of the peer are logged
+// and ignored, as the peer may have dropped them in a later revision.
+func peerServiceNames(snapInfo, peer *snap.Info, names []string) []string {
+	var units []string
+	for _, name := range names {
+		app := peer.Apps[name]
+		if app == nil || !app.IsService() || app.DaemonScope != snap.SystemDaemon {
+			logger.Noticef("cannot order services of snap %q against %q of snap %q: not a system service", snapInfo.InstanceName(), name, peer.InstanceName())
+			continue
+		}
+		units = append(units, app.ServiceName())
+	}
+	return units
+}
+
 func (m *InterfaceManager) setupSnapSecurity(task *state.Task, snapInfo *snap.Info, opts interfaces.ConfinementOptions, tm timings.Measurer) error {
 	return m.setupSecurityByBackend(task, []*snap.Info{snapInfo}, []interfaces.ConfinementOptions{opts}, tm)
 }
@@ -1257,3 +1341,59 @@ func (m *InterfaceManager) discardSecurityProfilesLate(name string, rev snap.Rev
 	}
 	return nil
 }
+
+// removeStaleMountControlMounts removes the mounts created by the given snap
+// with "snapctl mount" that none of its remaining mount-control connections
+// allows anymore.
+func removeStaleMountControlMounts(st *state.State, instanceName string, conns map[string]*connState) error {
+	info, err := snapstate.CurrentInfo(st, instanceName)
+	if err != nil {
+		return err
+	}
+
+	var entries []*builtin.MountControlEntry
+	for id, conn := range conns {
+		if conn.Undesired || conn.HotplugGone || conn.Interface != "mount-control" {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return err
+		}
+		if connRef.PlugRef.Snap != instanceName {
+			continue
+		}
+		plug := info.Plugs[connRef.PlugRef.Name]
+		if plug == nil {
+			continue
+		}
+		plugEntries, err := builtin.MountControlEntries(plug)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, plugEntries...)
+	}
+
+	sysd := systemd.New(systemd.SystemMode, progress.Null)
+	mountPoints, err := sysd.ListMountUnits(instanceName, builtin.MountControlOrigin)
+	if err != nil {
+		return err
+	}
+	for _, where := range mountPoints {
+		allowed := false
+		for _, entry := range entries {
+			if entry.AllowsWhere(info, where) {
+				allowed = true
+				break
+			}
+		}
+		if allowed {
+			continue
+		}
+		logger.Noticef("removing mount of snap %q at %q no longer allowed by its mount-control connections", instanceName, where)
+		if err := sysd.RemoveMountUnitFile(filepath.Join(dirs.GlobalRootDir, where)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -55,8 +55,10 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/snapdenv"
+	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/testutil"
 	"github.com/snapcore/snapd/timings"
+	"github.com/snapcore/snapd/wrappers"
 )
 
 func TestInterfaceManager(t *testing.T) { TestingT(t) }
@@ -1622,6 +1624,84 @@ func (s *interfaceManagerSuite) TestDisconnectFull(c *C) {
 	s.testDisconnect(c, "consumer", "plug", "producer", "slot")
 }
 
+func (s *interfaceManagerSuite) TestDisconnectMountControlRemovesMounts(c *C) {
+	var sysdArgs [][]string
+	s.AddCleanup(systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		sysdArgs = append(sysdArgs, args)
+		return nil, nil
+	}))
+
+	s.mockSnap(c, `name: consumer
+version: 1
+plugs:
+ media:
+  interface: mount-control
+  mount:
+   - what: /dev/sd*
+     where: /media/*
+     persistent: true
+ mnt:
+  interface: mount-control
+  mount:
+   - what: /dev/sd*
+     where: /mnt/*
+`)
+	s.mockSnap(c, `name: core
+version: 1
+type: os
+slots:
+ mount-control:
+`)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:media core:mount-control": map[string]interface{}{"interface": "mount-control"},
+		"consumer:mnt core:mount-control":   map[string]interface{}{"interface": "mount-control"},
+	})
+	s.state.Unlock()
+
+	sysd := systemd.New(systemd.SystemMode, nil)
+	for _, opts := range []*systemd.MountUnitOptions{
+		{Lifetime: systemd.Persistent, SnapName: "consumer", Revision: "1", What: "/dev/sdb1", Where: "/media/usb", Origin: "mount-control"},
+		{Lifetime: systemd.Transient, SnapName: "consumer", Revision: "1", What: "/dev/sdc1", Where: "/mnt/data", Origin: "mount-control"},
+		// mount of another snap
+		{Lifetime: systemd.Persistent, SnapName: "other", Revision: "1", What: "/dev/sdd1", Where: "/media/other", Origin: "mount-control"},
+	} {
+		_, err := sysd.AddMountUnitFileWithOptions(opts)
+		c.Assert(err, IsNil)
+	}
+	sysdArgs = nil
+
+	mgr := s.manager(c)
+	conn, err := mgr.Repository().Connection(&interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "media"},
+		SlotRef: interfaces.SlotRef{Snap: "core", Name: "mount-control"},
+	})
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	change := s.state.NewChange("disconnect", "...")
+	ts, err := ifacestate.Disconnect(s.state, conn)
+	c.Assert(err, IsNil)
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Err(), IsNil)
+
+	// only the mount no other connection allows is removed
+	c.Check(filepath.Join(dirs.SnapServicesDir, "media-usb.mount"), testutil.FileAbsent)
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, "mnt-data.mount"), testutil.FilePresent)
+	c.Check(filepath.Join(dirs.SnapServicesDir, "media-other.mount"), testutil.FilePresent)
+	c.Check(sysdArgs, DeepEquals, [][]string{
+		{"disable", "media-usb.mount"},
+		{"daemon-reload"},
+	})
+}
+
 func (s *interfaceManagerSuite) getConnection(c *C, plugSnap, plugName, slotSnap, slotName string) *interfaces.Connection {
 	conn, err := s.manager(c).Repository().Connection(&interfaces.ConnRef{
 		PlugRef: interfaces.PlugRef{Snap: plugSnap, Name: plugName},
@@ -4036,6 +4116,83 @@ func (s *interfaceManagerSuite) TestConnectSetsHotplugKeyFromTheSlot(c *C) {
 	})
 }
 
+func (s *interfaceManagerSuite) TestConnectDisconnectSetsUpServiceOrdering(c *C) {
+	s.MockModel(c, nil)
+
+	var orderings map[string]*wrappers.ServiceOrdering
+	restore := ifacestate.MockEnsureSnapServiceOrdering(func(info *snap.Info, ordering *wrappers.ServiceOrdering, opts *wrappers.EnsureSnapServicesOptions) error {
+		c.Check(opts, DeepEquals, &wrappers.EnsureSnapServicesOptions{})
+		orderings[info.InstanceName()] = ordering
+		return nil
+	})
+	defer restore()
+
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, `name: consumer
+version: 1
+apps:
+ worker:
+  daemon: simple
+plugs:
+ plug:
+  interface: test
+  after-services: [db, tool]
+`)
+	s.mockSnap(c, `name: producer
+version: 1
+apps:
+ db:
+  daemon: simple
+ tool:
+  command: bin/tool
+slots:
+ slot:
+  interface: test
+  before-services: [worker]
+`)
+	_ = s.manager(c)
+
+	orderings = make(map[string]*wrappers.ServiceOrdering)
+	s.state.Lock()
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("connect", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	c.Assert(change.Err(), IsNil)
+	s.state.Unlock()
+
+	// the tool app is not a service and is ignored
+	c.Check(orderings, DeepEquals, map[string]*wrappers.ServiceOrdering{
+		"consumer": {After: []string{"snap.producer.db.service"}},
+		"producer": {Before: []string{"snap.consumer.worker.service"}},
+	})
+
+	orderings = make(map[string]*wrappers.ServiceOrdering)
+	conn := s.getConnection(c, "consumer", "plug", "producer", "slot")
+	s.state.Lock()
+	ts, err = ifacestate.Disconnect(s.state, conn)
+	c.Assert(err, IsNil)
+	change = s.state.NewChange("disconnect", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Err(), IsNil)
+
+	c.Check(orderings, DeepEquals, map[string]*wrappers.ServiceOrdering{
+		"consumer": {},
+		"producer": {},
+	})
+}
+
 func (s *interfaceManagerSuite) TestDisconnectSetsUpSecurity(c *C) {
 	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
 	s.mockSnap(c, consumerYaml)
@@ -44,6 +44,7 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/asserts/sysdb"
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/boot/boottest"
@@ -4371,6 +4372,106 @@ func (s *mgrsSuite) TestRemodelRequiredSnapsAddedUndo(c *C) {
 	c.Assert(model, DeepEquals, curModel)
 }
 
+func (s *mgrsSuite) TestRemodelOfflineRequiredSnapsAdded(c *C) {
+	var snapAsserts []asserts.Assertion
+	snapPaths := make(map[string]string)
+	for _, name := range []string{"foo", "bar"} {
+		snapDecl := s.prereqSnapAssertions(c, map[string]interface{}{
+			"snap-name": name,
+		})
+		snapPath, digest := s.makeStoreTestSnap(c, fmt.Sprintf("{name: %s, version: 1.0}", name), "1")
+		snapRev, err := s.storeSigning.Find(asserts.SnapRevisionType, map[string]string{
+			"snap-sha3-384": digest,
+		})
+		c.Assert(err, IsNil)
+		snapAsserts = append(snapAsserts, snapDecl, snapRev)
+		snapPaths[name] = snapPath
+	}
+
+	// the store is not reachable
+	mockServer := s.mockStore(c)
+	mockServer.Close()
+
+	st := s.o.State()
+	st.Lock()
+	defer st.Unlock()
+
+	// create/set custom model assertion
+	assertstatetest.AddMany(st, s.brands.AccountsAndKeys("my-brand")...)
+
+	model := s.brands.Model("my-brand", "my-model", modelDefaults)
+
+	// setup model assertion
+	devicestatetest.SetDevice(st, &auth.DeviceState{
+		Brand:  "my-brand",
+		Model:  "my-model",
+		Serial: "serialserialserial",
+	})
+	err := assertstate.Add(st, model)
+	c.Assert(err, IsNil)
+	s.makeSerialAssertionInState(c, st, "my-brand", "my-model", "serialserialserial")
+
+	// the assertions for the local snaps are provided along them
+	assertstatetest.AddMany(st, s.devAcct)
+	assertstatetest.AddMany(st, snapAsserts...)
+	var localSnaps []*snap.SideInfo
+	var paths []string
+	for _, name := range []string{"foo", "bar"} {
+		si, err := snapasserts.DeriveSideInfo(snapPaths[name], assertstate.DB(st))
+		c.Assert(err, IsNil)
+		localSnaps = append(localSnaps, si)
+		paths = append(paths, snapPaths[name])
+	}
+
+	// create a new model
+	newModel := s.brands.Model("my-brand", "my-model", modelDefaults, map[string]interface{}{
+		"required-snaps": []interface{}{"foo", "bar"},
+		"revision":       "1",
+	})
+
+	chg, err := devicestate.RemodelWithLocalSnaps(st, newModel, localSnaps, paths)
+	c.Assert(err, IsNil)
+
+	st.Unlock()
+	err = s.o.Settle(settleTimeout)
+	st.Lock()
+	c.Assert(err, IsNil)
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("remodel change failed with: %v", chg.Err()))
+
+	for _, name := range []string{"foo", "bar"} {
+		var snapst snapstate.SnapState
+		err = snapstate.Get(st, name, &snapst)
+		c.Assert(err, IsNil)
+		info, err := snapst.CurrentInfo()
+		c.Assert(err, IsNil)
+		c.Check(info.Revision, Equals, snap.R(1))
+		c.Check(info.SnapID, Equals, fakeSnapID(name))
+		c.Check(snapst.Required, Equals, true)
+		// the provided file was consumed
+		c.Check(snapPaths[name], testutil.FileAbsent)
+	}
+
+	// all local snaps are prepared before anything is installed
+	tasks := chg.Tasks()
+	sort.Sort(byReadyTime(tasks))
+	var kinds []string
+	for _, t := range tasks[:6] {
+		kinds = append(kinds, t.Kind())
+	}
+	c.Check(kinds, DeepEquals, []string{
+		"prerequisites", "prepare-snap",
+		"prerequisites", "prepare-snap",
+		"mount-snap", "copy-snap-data",
+	})
+	c.Check(tasks[len(tasks)-1].Kind(), Equals, "set-model")
+
+	// the new model is in place
+	devModel, err := s.o.DeviceManager().Model()
+	c.Assert(err, IsNil)
+	c.Check(devModel.Revision(), Equals, 1)
+}
+
 func (s *mgrsSuite) TestRemodelDifferentBase(c *C) {
 	// make "core18" snap available in the store
 	snapYamlContent := `name: core18
@@ -21,7 +21,9 @@
 package overlord
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -33,6 +35,7 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
@@ -76,6 +79,8 @@ var pruneTickerC = func(t *time.Ticker) <-chan time.Time {
 // track of all available state managers and related helpers.
 type Overlord struct {
 	stateEng *StateEngine
+	// stateJournal is set if the state is persisted via a journal
+	stateJournal *state.Journal
 	// ensure loop
 	loopTomb    *tomb.Tomb
 	ensureLock  sync.Mutex
@@ -132,10 +137,11 @@ func New(restartBehavior RestartBehavior) (*Overlord, error) {
 		ensureBefore:   o.ensureBefore,
 		requestRestart: o.requestRestart,
 	}
-	s, err := loadState(backend, restartBehavior)
+	s, stateJournal, err := loadState(backend, restartBehavior)
 	if err != nil {
 		return nil, err
 	}
+	o.stateJournal = stateJournal
 
 	o.stateEng = NewStateEngine(s)
 	o.runner = state.NewTaskRunner(s)
@@ -224,10 +230,10 @@ func (o *Overlord) addManager(mgr StateManager) {
 	o.stateEng.AddManager(mgr)
 }
 
-func loadState(backend state.Backend, restartBehavior RestartBehavior) (*state.State, error) {
+func loadState(backend *overlordStateBackend, restartBehavior RestartBehavior) (*state.State, *state.Journal, error) {
 	curBootID, err := osutil.BootID()
 	if err != nil {
-		return nil, fmt.Errorf("fatal: cannot find current boot id: %v", err)
+		return nil, nil, fmt.Errorf("fatal: cannot find current boot id: %v", err)
 	}
 
 	perfTimings := timings.New(map[string]string{"startup": "load-state"})
@@ -237,28 +243,30 @@ func loadState(backend state.Backend, restartBehavior RestartBehavior) (*state.S
 		// by the snapd package
 		stateDir := filepath.Dir(dirs.SnapStateFile)
 		if !osutil.IsDirectory(stateDir) {
-			return nil, fmt.Errorf("fatal: directory %q must be present", stateDir)
+			return nil, nil, fmt.Errorf("fatal: directory %q must be present", stateDir)
 		}
-		s := state.New(backend)
+	}
+
+	stateBackend, stateJournal, data, err := openStateBackend(backend)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if data == nil {
+		s := state.New(stateBackend)
 		s.Lock()
 		s.VerifyReboot(curBootID)
 		s.Unlock()
 		patch.Init(s)
-		return s, nil
-	}
-
-	r, err := os.Open(dirs.SnapStateFile)
-	if err != nil {
-		return nil, fmt.Errorf("cannot read the state file: %s", err)
+		return s, stateJournal, nil
 	}
-	defer r.Close()
 
 	var s *state.State
 	timings.Run(perfTimings, "read-state", "read snapd state from disk", func(tm timings.Measurer) {
-		s, err = state.ReadState(backend, r)
+		s, err = state.ReadState(stateBackend, bytes.NewReader(data))
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	s.Lock()
 	perfTimings.Save(s)
@@ -266,15 +274,48 @@ func loadState(backend state.Backend, restartBehavior RestartBehavior) (*state.S
 
 	err = verifyReboot(s, curBootID, restartBehavior)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// one-shot migrations
 	err = patch.Apply(s)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return s, nil
+	return s, stateJournal, nil
+}
+
+// openStateBackend returns the backend persisting the state together with
+// the current serialized state, or nil if there is no state yet. With the
+// journaled-state feature enabled changes to the state are appended to a
+// journal next to the state file, otherwise any journal left over from
+// when the feature was enabled is folded back into the state file.
+func openStateBackend(backend *overlordStateBackend) (state.Backend, *state.Journal, []byte, error) {
+	if !features.JournaledState.IsEnabled() {
+		if err := state.DiscardJournal(backend.path); err != nil {
+			return nil, nil, nil, fmt.Errorf("cannot discard the state journal: %v", err)
+		}
+		data, err := ioutil.ReadFile(backend.path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, nil, nil, fmt.Errorf("cannot read the state file: %s", err)
+		}
+		return backend, nil, data, nil
+	}
+
+	stateJournal, err := state.OpenJournal(backend.path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	data, err := stateJournal.Data()
+	if err != nil {
+		stateJournal.Close()
+		return nil, nil, nil, fmt.Errorf("cannot read the state: %v", err)
+	}
+	journaledBackend := &overlordJournaledStateBackend{
+		overlordStateBackend: backend,
+		journal:              stateJournal,
+	}
+	return journaledBackend, stateJournal, data, nil
 }
 
 func verifyReboot(s *state.State, curBootID string, restartBehavior RestartBehavior) error {
@@ -474,6 +515,19 @@ func (o *Overlord) Stop() error {
 	o.loopTomb.Kill(nil)
 	err := o.loopTomb.Wait()
 	o.stateEng.Stop()
+	if o.stateJournal != nil {
+		// leave a complete state file behind, in case snapd is
+		// reverted to a version without journal support
+		st := o.stateEng.State()
+		st.Lock()
+		if err := o.stateJournal.Compact(); err != nil {
+			logger.Noticef("cannot compact the state journal: %v", err)
+		}
+		if err := o.stateJournal.Close(); err != nil {
+			logger.Noticef("cannot close the state journal: %v", err)
+		}
+		st.Unlock()
+	}
 	return err
 }
 
@@ -34,6 +34,7 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -213,6 +214,111 @@ func (ovs *overlordSuite) TestNewWithInvalidState(c *C) {
 	c.Assert(err, ErrorMatches, "cannot read state: EOF")
 }
 
+func (ovs *overlordSuite) enableJournaledState(c *C) {
+	c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(features.JournaledState.ControlFile(), nil, 0644), IsNil)
+}
+
+func (ovs *overlordSuite) TestNewWithJournaledState(c *C) {
+	ovs.enableJournaledState(c)
+
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d,"patch-sublevel-last-version":%q,"some":"data","refresh-privacy-key":"0123456789ABCDEF"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level, patch.Sublevel, snapdtool.Version))
+	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	markSeeded(o)
+	snapstate.CanAutoRefresh = nil
+
+	st := o.State()
+	st.Lock()
+	st.Set("some", "other-data")
+	st.Unlock()
+
+	// the existing state file is kept and the change is journaled
+	journalPath := state.JournalPath(dirs.SnapStateFile)
+	c.Check(journalPath, testutil.FileContains, `"data/some":"other-data"`)
+	data, err := state.ReadStateData(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	c.Check(string(data), testutil.Contains, `"some":"other-data"`)
+
+	// a new overlord sees the journaled change
+	o2, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	st2 := o2.State()
+	st2.Lock()
+	var some string
+	c.Check(st2.Get("some", &some), IsNil)
+	st2.Unlock()
+	c.Check(some, Equals, "other-data")
+
+	// stopping leaves a complete state file behind
+	c.Assert(o.StartUp(), IsNil)
+	o.Loop()
+	c.Assert(o.Stop(), IsNil)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"some":"other-data"`)
+	c.Check(journalPath, testutil.FileEquals, "")
+
+	// the journal is closed, later changes are written to the state
+	// file directly
+	st.Lock()
+	st.Set("some", "after-stop")
+	st.Unlock()
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"some":"after-stop"`)
+	c.Check(journalPath, testutil.FileAbsent)
+}
+
+func (ovs *overlordSuite) TestNewWithJournaledStateNoState(c *C) {
+	ovs.enableJournaledState(c)
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	st := o.State()
+	st.Lock()
+	st.Set("some", "data")
+	st.Unlock()
+
+	// a complete state file was written on the first checkpoint, later
+	// changes are journaled
+	c.Check(dirs.SnapStateFile, testutil.FilePresent)
+	c.Check(state.JournalPath(dirs.SnapStateFile), testutil.FileContains, `"data/some":"data"`)
+	data, err := state.ReadStateData(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	c.Check(string(data), testutil.Contains, `"some":"data"`)
+}
+
+func (ovs *overlordSuite) TestNewDiscardsJournalWhenDisabled(c *C) {
+	ovs.enableJournaledState(c)
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	st := o.State()
+	st.Lock()
+	st.Set("some", "data")
+	st.Unlock()
+	st.Lock()
+	st.Set("some", "other-data")
+	st.Unlock()
+	journalPath := state.JournalPath(dirs.SnapStateFile)
+	c.Check(journalPath, testutil.FileContains, `"data/some":"other-data"`)
+
+	c.Assert(os.Remove(features.JournaledState.ControlFile()), IsNil)
+
+	o2, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	c.Check(journalPath, testutil.FileAbsent)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"some":"other-data"`)
+
+	st2 := o2.State()
+	st2.Lock()
+	var some string
+	c.Check(st2.Get("some", &some), IsNil)
+	st2.Unlock()
+	c.Check(some, Equals, "other-data")
+}
+
 func (ovs *overlordSuite) TestNewWithPatches(c *C) {
 	p := func(s *state.State) error {
 		s.Set("patched", true)
@@ -20,8 +20,11 @@
 package servicestate
 
 import (
+	"time"
+
 	tomb "gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
@@ -46,3 +49,19 @@ func MockOsutilBootID(mockID string) (restore func()) {
 		osutilBootID = old
 	}
 }
+
+func MockFailedServicesCheckInterval(d time.Duration) (restore func()) {
+	old := failedServicesCheckInterval
+	failedServicesCheckInterval = d
+	return func() {
+		failedServicesCheckInterval = old
+	}
+}
+
+func MockSnapstateRevert(f func(st *state.State, name string, flags snapstate.Flags) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateRevert
+	snapstateRevert = f
+	return func() {
+		snapstateRevert = old
+	}
+}
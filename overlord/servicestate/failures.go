@@ -0,0 +1,213 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snapdenv"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	// failedServicesCheckInterval is the interval between checks for
+	// snap services that entered the failed state, the first check
+	// happens one interval after the manager was created to give the
+	// services a chance to start; the failure action is only taken for
+	// services found failed by two consecutive checks
+	failedServicesCheckInterval = 5 * time.Minute
+
+	snapstateRevert = snapstate.Revert
+)
+
+// failureEscalations maps snap instance names to the revision for which a
+// failure action was taken, so that the action is taken at most once per
+// revision.
+type failureEscalations map[string]snap.Revision
+
+func (m *ServiceManager) ensureFailedServicesHandled() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	if snapdenv.Preseeding() {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Sub(m.lastFailedServicesCheck) < failedServicesCheckInterval {
+		return nil
+	}
+
+	var seeded bool
+	err := m.state.Get("seeded", &seeded)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !seeded {
+		return nil
+	}
+	m.lastFailedServicesCheck = now
+
+	allStates, err := snapstate.All(m.state)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	var units []string
+	appsByUnit := make(map[string]*snap.AppInfo)
+	for instanceName, snapst := range allStates {
+		if !snapst.Active {
+			continue
+		}
+		if err := snapstate.CheckChangeConflict(m.state, instanceName, nil); err != nil {
+			if _, ok := err.(*snapstate.ChangeConflictError); ok {
+				// the snap is being refreshed, reverted etc, its
+				// services may be failing only transiently
+				continue
+			}
+			return err
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			return err
+		}
+		for _, app := range info.Services() {
+			if app.DaemonScope != snap.SystemDaemon {
+				continue
+			}
+			units = append(units, app.ServiceName())
+			appsByUnit[app.ServiceName()] = app
+		}
+	}
+	if len(units) == 0 {
+		m.failedServices = nil
+		return nil
+	}
+
+	sysd := systemd.New(systemd.SystemMode, progress.Null)
+	m.state.Unlock()
+	sts, err := sysd.Status(units...)
+	m.state.Lock()
+	if err != nil {
+		return fmt.Errorf("cannot check status of snap services: %v", err)
+	}
+
+	failed := make(map[string]bool)
+	for _, st := range sts {
+		if !st.Failed {
+			continue
+		}
+		failed[st.UnitName] = true
+		app := appsByUnit[st.UnitName]
+		m.state.Warnf("service %q of snap %q has failed", app.Name, app.Snap.InstanceName())
+		if !m.failedServices[st.UnitName] {
+			// the service may still recover, e.g. if it is
+			// being restarted by systemd
+			continue
+		}
+		if err := m.handleFailedService(app); err != nil {
+			return err
+		}
+	}
+	m.failedServices = failed
+	return nil
+}
+
+func (m *ServiceManager) handleFailedService(app *snap.AppInfo) error {
+	st := m.state
+	instanceName := app.Snap.InstanceName()
+
+	if app.FailureAction == "" {
+		return nil
+	}
+
+	var escalations failureEscalations
+	if err := st.Get("service-failure-escalations", &escalations); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if escalations == nil {
+		escalations = make(failureEscalations)
+	}
+	if rev, ok := escalations[instanceName]; ok && rev == app.Snap.Revision {
+		// already escalated for this revision
+		return nil
+	}
+
+	switch app.FailureAction {
+	case snap.FailureActionRevert:
+		ts, err := snapstateRevert(st, instanceName, snapstate.Flags{})
+		if err != nil {
+			st.Warnf("cannot revert snap %q after failure of service %q: %v", instanceName, app.Name, err)
+			return nil
+		}
+		logger.Noticef("Reverting snap %q after failure of service %q", instanceName, app.Name)
+		chg := st.NewChange("revert-snap", fmt.Sprintf("Revert %q snap after failure of service %q", instanceName, app.Name))
+		chg.AddAll(ts)
+		st.EnsureBefore(0)
+	case snap.FailureActionReboot:
+		critical, err := isCriticalSnap(st, app.Snap)
+		if err != nil {
+			return err
+		}
+		if !critical {
+			st.Warnf("cannot reboot after failure of service %q of snap %q: snap is not declared critical by the gadget", app.Name, instanceName)
+			return nil
+		}
+		logger.Noticef("Rebooting after failure of service %q of critical snap %q", app.Name, instanceName)
+		st.RequestRestart(state.RestartSystem)
+	}
+
+	escalations[instanceName] = app.Snap.Revision
+	st.Set("service-failure-escalations", escalations)
+	return nil
+}
+
+// isCriticalSnap returns whether the gadget declares the given snap as
+// critical.
+func isCriticalSnap(st *state.State, info *snap.Info) (bool, error) {
+	if info.SnapID == "" {
+		return false, nil
+	}
+	deviceCtx, err := snapstate.DeviceCtx(st, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	gadgetInfo, err := snapstate.GadgetInfo(st, deviceCtx)
+	if err == state.ErrNoState {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// no constraints enforced: those should have been checked before already
+	gi, err := gadget.ReadInfo(gadgetInfo.MountDir(), nil)
+	if err != nil {
+		return false, err
+	}
+	return strutil.ListContains(gi.CriticalSnaps, info.SnapID), nil
+}
@@ -0,0 +1,289 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type failedServicesSuite struct {
+	baseServiceMgrTestSuite
+
+	reverted []string
+}
+
+var _ = Suite(&failedServicesSuite{})
+
+func (s *failedServicesSuite) SetUpTest(c *C) {
+	s.baseServiceMgrTestSuite.SetUpTest(c)
+
+	s.AddCleanup(servicestate.MockFailedServicesCheckInterval(0))
+	s.AddCleanup(servicestate.MockEnsuredSnapServices(s.mgr, true))
+
+	s.reverted = nil
+	s.AddCleanup(servicestate.MockSnapstateRevert(func(st *state.State, name string, flags snapstate.Flags) (*state.TaskSet, error) {
+		s.reverted = append(s.reverted, name)
+		return state.NewTaskSet(st.NewTask("fake-revert", "...")), nil
+	}))
+
+	s.testSnapSideInfo.SnapID = "testsnapidtestsnapidtestsnapidte"
+}
+
+const failureActionYaml = `name: test-snap
+version: v1
+apps:
+  svc1:
+    command: bin.sh
+    daemon: simple
+    failure-action: %s
+`
+
+func (s *failedServicesSuite) mockTestSnap(c *C, failureAction string) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	if failureAction == "" {
+		snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
+	} else {
+		snaptest.MockSnapCurrent(c, fmt.Sprintf(failureActionYaml, failureAction), s.testSnapSideInfo)
+	}
+}
+
+func (s *failedServicesSuite) mockGadget(c *C, criticalSnaps ...string) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "pc", Revision: snap.R(1)}
+	snapstate.Set(s.state, "pc", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		Active:   true,
+		SnapType: "gadget",
+	})
+	gadgetYaml := "critical-snaps:\n"
+	for _, snapID := range criticalSnaps {
+		gadgetYaml += fmt.Sprintf("  - %s\n", snapID)
+	}
+	snaptest.MockSnapWithFiles(c, "name: pc\ntype: gadget\nversion: 1", si, [][]string{
+		{"meta/gadget.yaml", gadgetYaml},
+	})
+}
+
+func (s *failedServicesSuite) mockServiceStatus(c *C, activeState string, times int) (restore func()) {
+	var calls []expectedSystemctl
+	for i := 0; i < times; i++ {
+		calls = append(calls, expectedSystemctl{
+			expArgs: []string{"show", "--property=Id,ActiveState,UnitFileState,Type", "snap.test-snap.svc1.service"},
+			output:  fmt.Sprintf("Type=simple\nId=snap.test-snap.svc1.service\nActiveState=%s\nUnitFileState=enabled\n", activeState),
+		})
+	}
+	return s.mockSystemctlCalls(c, calls)
+}
+
+func (s *failedServicesSuite) warnings() []string {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	var msgs []string
+	for _, w := range s.state.AllWarnings() {
+		msgs = append(msgs, w.String())
+	}
+	return msgs
+}
+
+func (s *failedServicesSuite) TestNoSnapsDoesNothing(c *C) {
+	// no systemctl calls expected
+	r := s.mockSystemctlCalls(c, nil)
+	defer r()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.warnings(), HasLen, 0)
+}
+
+func (s *failedServicesSuite) TestNotYetTimeToCheck(c *C) {
+	s.AddCleanup(servicestate.MockFailedServicesCheckInterval(time.Hour))
+	s.mockTestSnap(c, "revert")
+
+	// no systemctl calls expected
+	r := s.mockSystemctlCalls(c, nil)
+	defer r()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.warnings(), HasLen, 0)
+}
+
+func (s *failedServicesSuite) TestActiveServiceDoesNothing(c *C) {
+	s.mockTestSnap(c, "revert")
+	r := s.mockServiceStatus(c, "active", 1)
+	defer r()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.warnings(), HasLen, 0)
+	c.Check(s.reverted, HasLen, 0)
+	c.Check(s.restartRequests, HasLen, 0)
+}
+
+func (s *failedServicesSuite) TestFailedServiceRecordsWarning(c *C) {
+	s.mockTestSnap(c, "")
+	r := s.mockServiceStatus(c, "failed", 1)
+	defer r()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.warnings(), DeepEquals, []string{`service "svc1" of snap "test-snap" has failed`})
+	c.Check(s.reverted, HasLen, 0)
+	c.Check(s.restartRequests, HasLen, 0)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *failedServicesSuite) TestFailedServiceRevertsOncePerRevision(c *C) {
+	s.mockTestSnap(c, "revert")
+	r := s.mockServiceStatus(c, "failed", 3)
+	defer r()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+	// nothing is done until the failure is seen by a second check
+	c.Check(s.reverted, HasLen, 0)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.reverted, DeepEquals, []string{"test-snap"})
+	// the service is still failed on the next check
+	c.Assert(s.mgr.Ensure(), IsNil)
+
+	c.Check(s.warnings(), DeepEquals, []string{`service "svc1" of snap "test-snap" has failed`})
+	c.Check(s.reverted, DeepEquals, []string{"test-snap"})
+	c.Check(s.restartRequests, HasLen, 0)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	chgs := s.state.Changes()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Kind(), Equals, "revert-snap")
+	c.Check(chgs[0].Summary(), Equals, `Revert "test-snap" snap after failure of service "svc1"`)
+	c.Check(chgs[0].Tasks(), HasLen, 1)
+}
+
+func (s *failedServicesSuite) TestFailedServiceRevertError(c *C) {
+	s.AddCleanup(servicestate.MockSnapstateRevert(func(st *state.State, name string, flags snapstate.Flags) (*state.TaskSet, error) {
+		return nil, fmt.Errorf("no revision to revert to")
+	}))
+	s.mockTestSnap(c, "revert")
+	r := s.mockServiceStatus(c, "failed", 2)
+	defer r()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.warnings(), DeepEquals, []string{
+		`service "svc1" of snap "test-snap" has failed`,
+		`cannot revert snap "test-snap" after failure of service "svc1": no revision to revert to`,
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *failedServicesSuite) TestFailedServiceRebootsCriticalSnap(c *C) {
+	s.mockGadget(c, s.testSnapSideInfo.SnapID)
+	s.mockTestSnap(c, "reboot")
+	r := s.mockServiceStatus(c, "failed", 3)
+	defer r()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.restartRequests, HasLen, 0)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.restartRequests, DeepEquals, []state.RestartType{state.RestartSystem})
+
+	// no reboot loop when the service keeps failing after the reboot
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.restartRequests, HasLen, 1)
+	c.Check(s.reverted, HasLen, 0)
+}
+
+func (s *failedServicesSuite) TestFailedServiceDoesNotRebootNonCriticalSnap(c *C) {
+	s.mockGadget(c, "othersnapidothersnapidothersnapi")
+	s.mockTestSnap(c, "reboot")
+	r := s.mockServiceStatus(c, "failed", 2)
+	defer r()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.restartRequests, HasLen, 0)
+	c.Check(s.warnings(), DeepEquals, []string{
+		`service "svc1" of snap "test-snap" has failed`,
+		`cannot reboot after failure of service "svc1" of snap "test-snap": snap is not declared critical by the gadget`,
+	})
+}
+
+func (s *failedServicesSuite) TestTransientFailureDoesNotRevert(c *C) {
+	s.mockTestSnap(c, "revert")
+	r := s.mockSystemctlCalls(c, []expectedSystemctl{{
+		expArgs: []string{"show", "--property=Id,ActiveState,UnitFileState,Type", "snap.test-snap.svc1.service"},
+		output:  "Type=simple\nId=snap.test-snap.svc1.service\nActiveState=failed\nUnitFileState=enabled\n",
+	}, {
+		expArgs: []string{"show", "--property=Id,ActiveState,UnitFileState,Type", "snap.test-snap.svc1.service"},
+		output:  "Type=simple\nId=snap.test-snap.svc1.service\nActiveState=active\nUnitFileState=enabled\n",
+	}, {
+		expArgs: []string{"show", "--property=Id,ActiveState,UnitFileState,Type", "snap.test-snap.svc1.service"},
+		output:  "Type=simple\nId=snap.test-snap.svc1.service\nActiveState=failed\nUnitFileState=enabled\n",
+	}})
+	defer r()
+
+	// the service recovers in between the failed checks
+	for i := 0; i < 3; i++ {
+		c.Assert(s.mgr.Ensure(), IsNil)
+	}
+	c.Check(s.reverted, HasLen, 0)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *failedServicesSuite) TestSnapWithChangeInProgressIsSkipped(c *C) {
+	s.mockTestSnap(c, "revert")
+
+	s.state.Lock()
+	chg := s.state.NewChange("refresh-snap", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: s.testSnapSideInfo})
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	// no systemctl calls expected
+	r := s.mockSystemctlCalls(c, nil)
+	defer r()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.warnings(), HasLen, 0)
+	c.Check(s.reverted, HasLen, 0)
+}
@@ -109,7 +109,7 @@ func PatchQuotas(st *state.State, grps ...*quota.Group) (map[string]*quota.Group
 
 // CreateQuotaInState creates a quota group with the given paremeters
 // in the state.  It takes the current map of all quota groups.
-func CreateQuotaInState(st *state.State, quotaName string, parentGrp *quota.Group, snaps []string, memoryLimit quantity.Size, allGrps map[string]*quota.Group) (*quota.Group, map[string]*quota.Group, error) {
+func CreateQuotaInState(st *state.State, quotaName string, parentGrp *quota.Group, snaps []string, memoryLimit quantity.Size, journalLimit *quota.JournalQuota, allGrps map[string]*quota.Group) (*quota.Group, map[string]*quota.Group, error) {
 	// make sure that the parent group exists if we are creating a sub-group
 	var grp *quota.Group
 	var err error
@@ -132,6 +132,7 @@ func CreateQuotaInState(st *state.State, quotaName string, parentGrp *quota.Grou
 
 	// put the snaps in the group
 	grp.Snaps = snaps
+	grp.JournalLimit = journalLimit
 	// update the modified groups in state
 	newAllGrps, err := PatchQuotas(st, updatedGrps...)
 	if err != nil {
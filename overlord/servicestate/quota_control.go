@@ -30,6 +30,7 @@ import (
 	"github.com/snapcore/snapd/overlord/servicestate/internal"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/systemd"
 )
@@ -83,10 +84,18 @@ func quotaGroupsAvailable(st *state.State) error {
 	return nil
 }
 
+func journalQuotaAvailable() error {
+	// journal namespaces were introduced in systemd 245
+	if systemdVersion < 245 {
+		return fmt.Errorf("systemd version too old: journal quotas require systemd 245 and newer (currently have %d)", systemdVersion)
+	}
+	return nil
+}
+
 // CreateQuota attempts to create the specified quota group with the specified
 // snaps in it.
 // TODO: should this use something like QuotaGroupUpdate with fewer fields?
-func CreateQuota(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size) (*state.TaskSet, error) {
+func CreateQuota(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size, journalLimit *quota.JournalQuota) (*state.TaskSet, error) {
 	if err := quotaGroupsAvailable(st); err != nil {
 		return nil, err
 	}
@@ -113,6 +122,15 @@ func CreateQuota(st *state.State, name string, parentName string, snaps []string
 		return nil, fmt.Errorf("memory limit for group %q is too small: size must be larger than 4KB", name)
 	}
 
+	if journalLimit != nil {
+		if err := journalQuotaAvailable(); err != nil {
+			return nil, err
+		}
+		if err := journalLimit.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid journal limit for group %q: %v", name, err)
+		}
+	}
+
 	// make sure the specified snaps exist and aren't currently in another group
 	if err := validateSnapForAddingToGroup(st, snaps, name, allGrps); err != nil {
 		return nil, err
@@ -127,11 +145,12 @@ func CreateQuota(st *state.State, name string, parentName string, snaps []string
 
 	// create the task with the action in it
 	qc := QuotaControlAction{
-		Action:      "create",
-		QuotaName:   name,
-		MemoryLimit: memoryLimit,
-		AddSnaps:    snaps,
-		ParentName:  parentName,
+		Action:       "create",
+		QuotaName:    name,
+		MemoryLimit:  memoryLimit,
+		AddSnaps:     snaps,
+		ParentName:   parentName,
+		JournalLimit: journalLimit,
 	}
 
 	ts := state.NewTaskSet()
@@ -203,6 +222,15 @@ type QuotaGroupUpdate struct {
 	// NewMemoryLimit is the new memory limit to be used for the quota group. If
 	// zero, then the quota group's memory limit is not changed.
 	NewMemoryLimit quantity.Size
+
+	// NewJournalLimit is the new journal limit to be used for the quota group.
+	// If nil, then the quota group's journal limit is not changed.
+	NewJournalLimit *quota.JournalQuota
+
+	// RemoveJournalLimit removes the journal limit of the quota group, its
+	// services then log into the system journal again, or into the journal
+	// namespace of a parent group with a journal limit.
+	RemoveJournalLimit bool
 }
 
 // UpdateQuota updates the quota as per the options.
@@ -235,6 +263,19 @@ func UpdateQuota(st *state.State, name string, updateOpts QuotaGroupUpdate) (*st
 		}
 	}
 
+	if updateOpts.RemoveJournalLimit && updateOpts.NewJournalLimit != nil {
+		return nil, fmt.Errorf("cannot both set and remove the journal limit of group %q", name)
+	}
+
+	if updateOpts.NewJournalLimit != nil {
+		if err := journalQuotaAvailable(); err != nil {
+			return nil, err
+		}
+		if err := updateOpts.NewJournalLimit.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid journal limit for group %q: %v", name, err)
+		}
+	}
+
 	// now ensure that all of the snaps mentioned in AddSnaps exist as snaps and
 	// that they aren't already in an existing quota group
 	if err := validateSnapForAddingToGroup(st, updateOpts.AddSnaps, name, allGrps); err != nil {
@@ -250,10 +291,12 @@ func UpdateQuota(st *state.State, name string, updateOpts QuotaGroupUpdate) (*st
 
 	// create the action and the correspoding task set
 	qc := QuotaControlAction{
-		Action:      "update",
-		QuotaName:   name,
-		MemoryLimit: updateOpts.NewMemoryLimit,
-		AddSnaps:    updateOpts.AddSnaps,
+		Action:             "update",
+		QuotaName:          name,
+		MemoryLimit:        updateOpts.NewMemoryLimit,
+		AddSnaps:           updateOpts.AddSnaps,
+		JournalLimit:       updateOpts.NewJournalLimit,
+		RemoveJournalLimit: updateOpts.RemoveJournalLimit,
 	}
 
 	ts := state.NewTaskSet()
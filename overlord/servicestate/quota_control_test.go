@@ -34,6 +34,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/systemd"
@@ -216,7 +217,7 @@ func (s *quotaControlSuite) TestCreateQuotaNotEnabled(c *C) {
 	tr.Commit()
 
 	// try to create an empty quota group
-	_, err := servicestate.CreateQuota(s.state, "foo", "", nil, quantity.SizeGiB)
+	_, err := servicestate.CreateQuota(s.state, "foo", "", nil, quantity.SizeGiB, nil)
 	c.Assert(err, ErrorMatches, `experimental feature disabled - test it by setting 'experimental.quota-groups' to true`)
 }
 
@@ -230,7 +231,7 @@ func (s *quotaControlSuite) TestCreateQuotaSystemdTooOld(c *C) {
 	err := servicestate.CheckSystemdVersion()
 	c.Assert(err, IsNil)
 
-	_, err = servicestate.CreateQuota(s.state, "foo", "", nil, quantity.SizeGiB)
+	_, err = servicestate.CreateQuota(s.state, "foo", "", nil, quantity.SizeGiB, nil)
 	c.Assert(err, ErrorMatches, `systemd version too old: snap quotas requires systemd 230 and newer \(currently have 229\)`)
 }
 
@@ -255,7 +256,7 @@ func (s *quotaControlSuite) TestCreateQuotaPrecond(c *C) {
 	}
 
 	for _, t := range tests {
-		_, err := servicestate.CreateQuota(st, t.name, "", t.snaps, t.mem)
+		_, err := servicestate.CreateQuota(st, t.name, "", t.snaps, t.mem, nil)
 		c.Check(err, ErrorMatches, t.err)
 	}
 }
@@ -272,7 +273,7 @@ func (s *quotaControlSuite) TestRemoveQuotaPreseeding(c *C) {
 	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
 
 	// create a quota group
-	ts, err := servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap"}, quantity.SizeGiB)
+	ts, err := servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap"}, quantity.SizeGiB, nil)
 	c.Assert(err, IsNil)
 
 	chg := st.NewChange("quota-control", "...")
@@ -333,7 +334,7 @@ func (s *quotaControlSuite) TestCreateUpdateRemoveQuotaHappy(c *C) {
 	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
 
 	// create the quota group
-	ts, err := servicestate.CreateQuota(st, "foo", "", []string{"test-snap"}, quantity.SizeGiB)
+	ts, err := servicestate.CreateQuota(st, "foo", "", []string{"test-snap"}, quantity.SizeGiB, nil)
 	c.Assert(err, IsNil)
 
 	chg := st.NewChange("quota-control", "...")
@@ -416,6 +417,195 @@ func (s *quotaControlSuite) TestCreateUpdateRemoveQuotaHappy(c *C) {
 	checkQuotaState(c, st, nil)
 }
 
+func (s *quotaControlSuite) TestCreateQuotaJournalPrecond(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := servicestate.CreateQuota(st, "foo", "", nil, quantity.SizeGiB, &quota.JournalQuota{RateCount: 10})
+	c.Assert(err, ErrorMatches, `invalid journal limit for group "foo": journal rate limit must have both a message count and a period`)
+
+	r := servicestate.MockSystemdVersion(244)
+	defer r()
+
+	_, err = servicestate.CreateQuota(st, "foo", "", nil, quantity.SizeGiB, &quota.JournalQuota{Size: quantity.SizeMiB})
+	c.Assert(err, ErrorMatches, `systemd version too old: journal quotas require systemd 245 and newer \(currently have 244\)`)
+}
+
+func (s *quotaControlSuite) TestCreateUpdateQuotaWithJournal(c *C) {
+	journalSvc := "systemd-journald@snap-foo.service"
+	r := s.mockSystemctlCalls(c, join(
+		// CreateQuota for foo
+		systemctlCallsForCreateQuota("foo", "test-snap"),
+
+		// UpdateQuota for foo only rewrites the journal configuration, so
+		// the running journald instance for the namespace is restarted
+		[]expectedSystemctl{
+			{expArgs: []string{"is-active", journalSvc}, output: "active"},
+			{expArgs: []string{"stop", journalSvc}},
+			{
+				expArgs: []string{"show", "--property=ActiveState", journalSvc},
+				output:  "ActiveState=inactive",
+			},
+			{expArgs: []string{"start", journalSvc}},
+		},
+	))
+	defer r()
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
+
+	journalLimit := &quota.JournalQuota{Size: 64 * quantity.SizeMiB}
+	ts, err := servicestate.CreateQuota(st, "foo", "", []string{"test-snap"}, quantity.SizeGiB, journalLimit)
+	c.Assert(err, IsNil)
+
+	chg := st.NewChange("quota-control", "...")
+	chg.AddAll(ts)
+
+	checkQuotaControlTasks(c, chg.Tasks(), &servicestate.QuotaControlAction{
+		Action:       "create",
+		QuotaName:    "foo",
+		AddSnaps:     []string{"test-snap"},
+		MemoryLimit:  quantity.SizeGiB,
+		JournalLimit: journalLimit,
+	})
+
+	st.Unlock()
+	defer s.se.Stop()
+	err = s.o.Settle(5 * time.Second)
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Assert(chg.Err(), IsNil)
+
+	journalFile := filepath.Join(dirs.SnapSystemdDir, "journald@snap-foo.conf")
+	c.Assert(journalFile, testutil.FileContains, "\nSystemMaxUse=67108864\n")
+	svcFile := filepath.Join(dirs.SnapServicesDir, "snap.test-snap.svc1.service")
+	c.Assert(svcFile, testutil.FileContains, "\nLogNamespace=snap-foo\n")
+
+	grps, err := servicestate.AllQuotas(st)
+	c.Assert(err, IsNil)
+	c.Check(grps["foo"].JournalLimit, DeepEquals, journalLimit)
+
+	// now add a rate limit to the journal quota
+	newJournalLimit := &quota.JournalQuota{
+		Size:       64 * quantity.SizeMiB,
+		RateCount:  100,
+		RatePeriod: time.Minute,
+	}
+	ts, err = servicestate.UpdateQuota(st, "foo", servicestate.QuotaGroupUpdate{NewJournalLimit: newJournalLimit})
+	c.Assert(err, IsNil)
+
+	chg = st.NewChange("quota-control", "...")
+	chg.AddAll(ts)
+
+	st.Unlock()
+	err = s.o.Settle(5 * time.Second)
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Assert(chg.Err(), IsNil)
+
+	c.Assert(journalFile, testutil.FileContains, "\nRateLimitIntervalSec=60000000us\nRateLimitBurst=100\n")
+
+	grps, err = servicestate.AllQuotas(st)
+	c.Assert(err, IsNil)
+	c.Check(grps["foo"].JournalLimit, DeepEquals, newJournalLimit)
+}
+
+func (s *quotaControlSuite) TestUpdateQuotaRemoveJournalInheritedBySubGroup(c *C) {
+	r := s.mockSystemctlCalls(c, join(
+		// CreateQuota for foo does nothing as it has no snaps
+
+		// CreateQuota for foo2 as a sub-group of foo writes the slices of
+		// both groups
+		[]expectedSystemctl{{expArgs: []string{"daemon-reload"}}},
+		systemctlCallsForSliceStart("foo"),
+		systemctlCallsForSliceStart("foo/foo2"),
+		systemctlCallsForServiceRestart("test-snap"),
+
+		// UpdateQuota removing the journal limit of foo modifies the
+		// service of the snap in the sub-group which is restarted
+		[]expectedSystemctl{{expArgs: []string{"daemon-reload"}}},
+		systemctlCallsForServiceRestart("test-snap"),
+	))
+	defer r()
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
+
+	journalLimit := &quota.JournalQuota{Size: 64 * quantity.SizeMiB}
+	ts, err := servicestate.CreateQuota(st, "foo", "", nil, quantity.SizeGiB, journalLimit)
+	c.Assert(err, IsNil)
+	chg := st.NewChange("quota-control", "...")
+	chg.AddAll(ts)
+
+	st.Unlock()
+	defer s.se.Stop()
+	err = s.o.Settle(5 * time.Second)
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Assert(chg.Err(), IsNil)
+
+	// the sub-group has no journal limit of its own
+	ts, err = servicestate.CreateQuota(st, "foo2", "foo", []string{"test-snap"}, quantity.SizeGiB/2, nil)
+	c.Assert(err, IsNil)
+	chg = st.NewChange("quota-control", "...")
+	chg.AddAll(ts)
+
+	st.Unlock()
+	err = s.o.Settle(5 * time.Second)
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Assert(chg.Err(), IsNil)
+
+	// so the services of the snap log into the namespace of the parent
+	journalFile := filepath.Join(dirs.SnapSystemdDir, "journald@snap-foo.conf")
+	c.Assert(journalFile, testutil.FilePresent)
+	c.Assert(filepath.Join(dirs.SnapSystemdDir, "journald@snap-foo2.conf"), testutil.FileAbsent)
+	svcFile := filepath.Join(dirs.SnapServicesDir, "snap.test-snap.svc1.service")
+	c.Assert(svcFile, testutil.FileContains, "\nLogNamespace=snap-foo\n")
+
+	// setting and removing the journal limit at once is not possible
+	_, err = servicestate.UpdateQuota(st, "foo", servicestate.QuotaGroupUpdate{
+		NewJournalLimit:    journalLimit,
+		RemoveJournalLimit: true,
+	})
+	c.Assert(err, ErrorMatches, `cannot both set and remove the journal limit of group "foo"`)
+
+	// now remove the journal limit of the parent
+	ts, err = servicestate.UpdateQuota(st, "foo", servicestate.QuotaGroupUpdate{RemoveJournalLimit: true})
+	c.Assert(err, IsNil)
+
+	chg = st.NewChange("quota-control", "...")
+	chg.AddAll(ts)
+
+	checkQuotaControlTasks(c, chg.Tasks(), &servicestate.QuotaControlAction{
+		Action:             "update",
+		QuotaName:          "foo",
+		RemoveJournalLimit: true,
+	})
+
+	st.Unlock()
+	err = s.o.Settle(5 * time.Second)
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Assert(chg.Err(), IsNil)
+
+	c.Assert(journalFile, testutil.FileAbsent)
+	c.Assert(svcFile, Not(testutil.FileContains), "LogNamespace=")
+
+	grps, err := servicestate.AllQuotas(st)
+	c.Assert(err, IsNil)
+	c.Check(grps["foo"].JournalLimit, IsNil)
+}
+
 func (s *quotaControlSuite) TestEnsureSnapAbsentFromQuotaGroup(c *C) {
 	r := s.mockSystemctlCalls(c, join(
 		// CreateQuota for foo
@@ -454,7 +644,7 @@ func (s *quotaControlSuite) TestEnsureSnapAbsentFromQuotaGroup(c *C) {
 	snaptest.MockSnapCurrent(c, testYaml2, si2)
 
 	// create a quota group
-	ts, err := servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap", "test-snap2"}, quantity.SizeGiB)
+	ts, err := servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap", "test-snap2"}, quantity.SizeGiB, nil)
 	c.Assert(err, IsNil)
 
 	chg := st.NewChange("quota-control", "...")
@@ -569,7 +759,7 @@ func (s *quotaControlSuite) TestRemoveQuotaPrecond(c *C) {
 }
 
 func (s *quotaControlSuite) createQuota(c *C, name string, limit quantity.Size, snaps ...string) {
-	ts, err := servicestate.CreateQuota(s.state, name, "", snaps, limit)
+	ts, err := servicestate.CreateQuota(s.state, name, "", snaps, limit, nil)
 	c.Assert(err, IsNil)
 
 	chg := s.state.NewChange("quota-control", "...")
@@ -634,7 +824,7 @@ func (s *quotaControlSuite) TestSnapOpCreateQuotaConflict(c *C) {
 	chg1 := s.state.NewChange("disable", "...")
 	chg1.AddAll(ts)
 
-	_, err = servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap"}, quantity.SizeGiB)
+	_, err = servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap"}, quantity.SizeGiB, nil)
 	c.Assert(err, ErrorMatches, `snap "test-snap" has "disable" change in progress`)
 }
 
@@ -675,7 +865,7 @@ func (s *quotaControlSuite) TestCreateQuotaSnapOpConflict(c *C) {
 	snapstate.Set(s.state, "test-snap", s.testSnapState)
 	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
 
-	ts, err := servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap"}, quantity.SizeGiB)
+	ts, err := servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap"}, quantity.SizeGiB, nil)
 	c.Assert(err, IsNil)
 	chg1 := s.state.NewChange("quota-control", "...")
 	chg1.AddAll(ts)
@@ -922,11 +1112,11 @@ func (s *quotaControlSuite) TestCreateQuotaCreateQuotaConflict(c *C) {
 	snapstate.Set(s.state, "test-snap2", snapst2)
 	snaptest.MockSnapCurrent(c, testYaml2, si2)
 
-	ts, err := servicestate.CreateQuota(st, "foo", "", []string{"test-snap"}, quantity.SizeGiB)
+	ts, err := servicestate.CreateQuota(st, "foo", "", []string{"test-snap"}, quantity.SizeGiB, nil)
 	c.Assert(err, IsNil)
 	chg1 := s.state.NewChange("quota-control", "...")
 	chg1.AddAll(ts)
 
-	_, err = servicestate.CreateQuota(st, "foo", "", []string{"test-snap2"}, 2*quantity.SizeGiB)
+	_, err = servicestate.CreateQuota(st, "foo", "", []string{"test-snap2"}, 2*quantity.SizeGiB, nil)
 	c.Assert(err, ErrorMatches, `quota group "foo" has "quota-control" change in progress`)
 }
@@ -67,6 +67,15 @@ type QuotaControlAction struct {
 	// support moving quota groups from one parent to another, but that is
 	// currently not supported.
 	ParentName string
+
+	// JournalLimit is the journal quota for the quota group, either the
+	// initial limit the group is created with for the "create" action, or if
+	// set for the "update" action, the new limit to be set.
+	JournalLimit *quota.JournalQuota `json:"journal-limit,omitempty"`
+
+	// RemoveJournalLimit is only used for the "update" action, it removes the
+	// journal quota of the quota group.
+	RemoveJournalLimit bool `json:"remove-journal-limit,omitempty"`
 }
 
 func (m *ServiceManager) doQuotaControl(t *state.Task, _ *tomb.Tomb) error {
@@ -263,12 +272,19 @@ func quotaCreate(st *state.State, action QuotaControlAction, allGrps map[string]
 		return nil, nil, fmt.Errorf("memory limit for group %q is too small: size must be larger than 4KB", action.QuotaName)
 	}
 
+	// make sure the journal limit is sensible if there is one
+	if action.JournalLimit != nil {
+		if err := action.JournalLimit.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("invalid journal limit for group %q: %v", action.QuotaName, err)
+		}
+	}
+
 	// make sure the specified snaps exist and aren't currently in another group
 	if err := validateSnapForAddingToGroup(st, action.AddSnaps, action.QuotaName, allGrps); err != nil {
 		return nil, nil, err
 	}
 
-	return internal.CreateQuotaInState(st, action.QuotaName, parentGrp, action.AddSnaps, action.MemoryLimit, allGrps)
+	return internal.CreateQuotaInState(st, action.QuotaName, parentGrp, action.AddSnaps, action.MemoryLimit, action.JournalLimit, allGrps)
 }
 
 func quotaRemove(st *state.State, action QuotaControlAction, allGrps map[string]*quota.Group) (*quota.Group, map[string]*quota.Group, error) {
@@ -292,6 +308,14 @@ func quotaRemove(st *state.State, action QuotaControlAction, allGrps map[string]
 		return nil, nil, fmt.Errorf("internal error, MemoryLimit option cannot be used with remove action")
 	}
 
+	if action.JournalLimit != nil {
+		return nil, nil, fmt.Errorf("internal error, JournalLimit option cannot be used with remove action")
+	}
+
+	if action.RemoveJournalLimit {
+		return nil, nil, fmt.Errorf("internal error, RemoveJournalLimit option cannot be used with remove action")
+	}
+
 	// XXX: remove this limitation eventually
 	if len(grp.SubGroups) != 0 {
 		return nil, nil, fmt.Errorf("cannot remove quota group with sub-groups, remove the sub-groups first")
@@ -378,6 +402,20 @@ func quotaUpdate(st *state.State, action QuotaControlAction, allGrps map[string]
 		grp.MemoryLimit = action.MemoryLimit
 	}
 
+	// if a journal limit is set then replace the existing one, or drop it
+	// altogether if asked to
+	switch {
+	case action.RemoveJournalLimit && action.JournalLimit != nil:
+		return nil, nil, fmt.Errorf("internal error, JournalLimit and RemoveJournalLimit options cannot be used together")
+	case action.RemoveJournalLimit:
+		grp.JournalLimit = nil
+	case action.JournalLimit != nil:
+		if err := action.JournalLimit.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("invalid journal limit for group %q: %v", action.QuotaName, err)
+		}
+		grp.JournalLimit = action.JournalLimit
+	}
+
 	// update the quota group state
 	allGrps, err := internal.PatchQuotas(st, modifiedGrps...)
 	if err != nil {
@@ -386,6 +424,20 @@ func quotaUpdate(st *state.State, action QuotaControlAction, allGrps map[string]
 	return grp, allGrps, nil
 }
 
+// snapsInGroupTree returns the snaps of the given group and of all of its
+// sub-groups.
+func snapsInGroupTree(grp *quota.Group, allGrps map[string]*quota.Group) []string {
+	snaps := append([]string(nil), grp.Snaps...)
+	for _, sub := range grp.SubGroups {
+		subGrp, ok := allGrps[sub]
+		if !ok {
+			continue
+		}
+		snaps = append(snaps, snapsInGroupTree(subGrp, allGrps)...)
+	}
+	return snaps
+}
+
 type ensureSnapServicesForGroupOptions struct {
 	// allGrps is the updated set of quota groups
 	allGrps map[string]*quota.Group
@@ -420,9 +472,11 @@ func ensureSnapServicesForGroup(st *state.State, t *state.Task, grp *quota.Group
 		meterLocked = snapstate.NewTaskProgressAdapterLocked(t)
 	}
 
-	// build the map of snap infos to options to provide to EnsureSnapServices
+	// build the map of snap infos to options to provide to EnsureSnapServices,
+	// the snaps of sub-groups are included as they may log into the journal
+	// namespace of this group
 	snapSvcMap := map[*snap.Info]*wrappers.SnapServiceOptions{}
-	for _, sn := range append(grp.Snaps, opts.extraSnaps...) {
+	for _, sn := range append(snapsInGroupTree(grp, allGrps), opts.extraSnaps...) {
 		info, err := snapstate.CurrentInfo(st, sn)
 		if err != nil {
 			return nil, err
@@ -453,6 +507,7 @@ func ensureSnapServicesForGroup(st *state.State, t *state.Task, grp *quota.Group
 	}
 
 	grpsToStart := []*quota.Group{}
+	journalsToRestart := []*quota.Group{}
 	appsToRestartBySnap = map[*snap.Info][]*snap.AppInfo{}
 
 	collectModifiedUnits := func(app *snap.AppInfo, grp *quota.Group, unitType string, name, old, new string) {
@@ -492,6 +547,16 @@ func ensureSnapServicesForGroup(st *state.State, t *state.Task, grp *quota.Group
 				grpsToStart = append(grpsToStart, grp)
 			}
 
+		case "journald":
+			// the journal namespace configuration is only read when the
+			// journald instance for the namespace starts, so if an existing
+			// configuration was modified the instance needs a restart, while
+			// new configurations are picked up when services start logging
+			// into the namespace
+			if old != "" && new != "" {
+				journalsToRestart = append(journalsToRestart, grp)
+			}
+
 		case "service":
 			// in this case, the only way that a service could have been changed
 			// was if it was moved into or out of a slice, in both cases we need
@@ -524,6 +589,21 @@ func ensureSnapServicesForGroup(st *state.State, t *state.Task, grp *quota.Group
 		}
 	}
 
+	// restart the journald instances of groups with modified journal limits
+	for _, grp := range journalsToRestart {
+		journalSvc := grp.JournalServiceName()
+		isActive, err := systemSysd.IsActive(journalSvc)
+		if err != nil {
+			return nil, err
+		}
+		if !isActive {
+			continue
+		}
+		if err := systemSysd.Restart(journalSvc, 5*time.Second); err != nil {
+			return nil, err
+		}
+	}
+
 	// after starting all the grps that we modified from EnsureSnapServices,
 	// we need to handle the case where a quota was removed, this will only
 	// happen one at a time and can be identified by the grp provided to us
@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ReloadOnConfig returns the task sets to reload, or restart if they
+// define no reload-command, the services of the given snap that declare
+// reload-on-config matching any of the changed configuration keys. Keys
+// are given without the snap name prefix. Only services that are currently
+// active are acted upon. It returns no task sets if no service is affected.
+func ReloadOnConfig(st *state.State, snapName string, changedKeys []string, context *hookstate.Context) ([]*state.TaskSet, error) {
+	if len(changedKeys) == 0 {
+		return nil, nil
+	}
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		return nil, err
+	}
+
+	var svcs []*snap.AppInfo
+	for _, app := range info.Services() {
+		if app.ReloadOnConfig == nil {
+			continue
+		}
+		for _, key := range changedKeys {
+			if app.ReloadOnConfig.Matches(key) {
+				svcs = append(svcs, app)
+				break
+			}
+		}
+	}
+	if len(svcs) == 0 {
+		return nil, nil
+	}
+
+	inst := &Instruction{
+		Action:         "restart",
+		RestartOptions: client.RestartOptions{Reload: true},
+	}
+	return Control(st, svcs, inst, nil, context)
+}
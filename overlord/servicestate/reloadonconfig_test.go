@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type reloadOnConfigSuite struct {
+	baseServiceMgrTestSuite
+}
+
+var _ = Suite(&reloadOnConfigSuite{})
+
+const reloadOnConfigYaml = `name: test-snap
+version: v1
+apps:
+  web:
+    command: bin.sh
+    daemon: simple
+    reload-command: reload.sh
+    reload-on-config: server
+  worker:
+    command: bin.sh
+    daemon: simple
+    reload-on-config: true
+  cache:
+    command: bin.sh
+    daemon: simple
+    reload-on-config: cache.size
+  plain:
+    command: bin.sh
+    daemon: simple
+  tool:
+    command: bin.sh
+`
+
+func (s *reloadOnConfigSuite) SetUpTest(c *C) {
+	s.baseServiceMgrTestSuite.SetUpTest(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	snaptest.MockSnapCurrent(c, reloadOnConfigYaml, s.testSnapSideInfo)
+}
+
+func (s *reloadOnConfigSuite) TestReloadOnConfig(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tts, err := servicestate.ReloadOnConfig(s.state, "test-snap", []string{"server.port", "unrelated"}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 1)
+	tasks := tts[0].Tasks()
+	c.Assert(tasks, HasLen, 1)
+	c.Check(tasks[0].Kind(), Equals, "service-control")
+
+	var sa servicestate.ServiceAction
+	c.Assert(tasks[0].Get("service-action", &sa), IsNil)
+	c.Check(sa, DeepEquals, servicestate.ServiceAction{
+		SnapName: "test-snap",
+		Action:   "reload-or-restart",
+		Services: []string{"web", "worker"},
+	})
+}
+
+func (s *reloadOnConfigSuite) TestReloadOnConfigParentKey(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tts, err := servicestate.ReloadOnConfig(s.state, "test-snap", []string{"cache"}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 1)
+
+	var sa servicestate.ServiceAction
+	c.Assert(tts[0].Tasks()[0].Get("service-action", &sa), IsNil)
+	c.Check(sa.Services, DeepEquals, []string{"cache", "worker"})
+}
+
+func (s *reloadOnConfigSuite) TestReloadOnConfigNothingToDo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tts, err := servicestate.ReloadOnConfig(s.state, "test-snap", nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(tts, HasLen, 0)
+
+	si := &snap.SideInfo{RealName: "other-snap", Revision: snap.R(1)}
+	snapstate.Set(s.state, "other-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+		Active:   true,
+		SnapType: "app",
+	})
+	snaptest.MockSnapCurrent(c, `name: other-snap
+version: v1
+apps:
+  svc:
+    command: bin.sh
+    daemon: simple
+`, si)
+	tts, err = servicestate.ReloadOnConfig(s.state, "other-snap", []string{"server.port"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(tts, HasLen, 0)
+}
+
+func (s *reloadOnConfigSuite) TestReloadOnConfigConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("other", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: s.testSnapSideInfo})
+	chg.AddTask(t)
+
+	_, err := servicestate.ReloadOnConfig(s.state, "test-snap", []string{"server.port"}, nil)
+	c.Assert(err, FitsTypeOf, &servicestate.ServiceActionConflictError{})
+}
@@ -46,13 +46,18 @@ type ServiceManager struct {
 	state *state.State
 
 	ensuredSnapSvcs bool
+
+	lastFailedServicesCheck time.Time
+	// failedServices holds the units found failed by the last check
+	failedServices map[string]bool
 }
 
 // Manager returns a new service manager.
 func Manager(st *state.State, runner *state.TaskRunner) *ServiceManager {
 	delayedCrossMgrInit()
 	m := &ServiceManager{
-		state: st,
+		state:                   st,
+		lastFailedServicesCheck: time.Now(),
 	}
 	// TODO: undo handler
 	runner.AddHandler("service-control", m.doServiceControl, nil)
@@ -193,6 +198,9 @@ func (m *ServiceManager) Ensure() error {
 	if err := m.ensureSnapServicesUpdated(); err != nil {
 		return err
 	}
+	if err := m.ensureFailedServicesHandled(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -49,6 +49,6 @@ func MockQuotaInState(st *state.State, quotaName string, parentName string, snap
 		}
 	}
 
-	_, _, err = internal.CreateQuotaInState(st, quotaName, parentGrp, snaps, memoryLimit, allGrps)
+	_, _, err = internal.CreateQuotaInState(st, quotaName, parentGrp, snaps, memoryLimit, nil, allGrps)
 	return err
 }
@@ -0,0 +1,126 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+var validAppEnvKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateAppEnvironment checks that the given environment variable can be
+// set as an override for an app. Variables in the SNAP_ namespace are
+// reserved for snapd.
+func ValidateAppEnvironment(key, value string) error {
+	if !validAppEnvKey.MatchString(key) {
+		return fmt.Errorf("invalid environment variable name %q", key)
+	}
+	if key == "SNAP" || strings.HasPrefix(key, "SNAP_") {
+		return fmt.Errorf("cannot set reserved environment variable %q", key)
+	}
+	if strings.ContainsAny(value, "\n\x00") {
+		return fmt.Errorf("invalid value for environment variable %q: cannot contain newlines or NUL characters", key)
+	}
+	return nil
+}
+
+// SetAppEnvironment sets and unsets environment overrides for the given app
+// of an installed snap. The overrides are applied on top of the environment
+// declared by the snap the next time the app, or the service, is started.
+func SetAppEnvironment(st *state.State, instanceName, appName string, set map[string]string, unset []string) error {
+	var snapst SnapState
+	if err := Get(st, instanceName, &snapst); err != nil {
+		if err == state.ErrNoState {
+			return &snap.NotInstalledError{Snap: instanceName}
+		}
+		return err
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+	if _, ok := info.Apps[appName]; !ok {
+		return fmt.Errorf("snap %q has no app %q", instanceName, appName)
+	}
+	for key, value := range set {
+		if err := ValidateAppEnvironment(key, value); err != nil {
+			return err
+		}
+	}
+
+	env := make(map[string]string, len(snapst.AppEnvironment[appName])+len(set))
+	for key, value := range snapst.AppEnvironment[appName] {
+		env[key] = value
+	}
+	for key, value := range set {
+		env[key] = value
+	}
+	for _, key := range unset {
+		delete(env, key)
+	}
+
+	if snapst.AppEnvironment == nil {
+		snapst.AppEnvironment = make(map[string]map[string]string)
+	}
+	if len(env) != 0 {
+		snapst.AppEnvironment[appName] = env
+	} else {
+		delete(snapst.AppEnvironment, appName)
+	}
+	if len(snapst.AppEnvironment) == 0 {
+		snapst.AppEnvironment = nil
+	}
+
+	if err := wrappers.EnsureSnapAppEnvironment(info, snapst.AppEnvironment); err != nil {
+		return fmt.Errorf("cannot update environment of %q: %v", instanceName, err)
+	}
+	Set(st, instanceName, &snapst)
+	return nil
+}
+
+// pruneAppEnvironment drops the environment overrides of apps that are not
+// part of the given revision of the snap anymore, together with the files
+// applying them.
+func pruneAppEnvironment(snapst *SnapState, info *snap.Info) error {
+	if len(snapst.AppEnvironment) == 0 {
+		return nil
+	}
+	var env map[string]map[string]string
+	for appName, appEnv := range snapst.AppEnvironment {
+		if _, ok := info.Apps[appName]; !ok {
+			continue
+		}
+		if env == nil {
+			env = make(map[string]map[string]string)
+		}
+		env[appName] = appEnv
+	}
+	if err := wrappers.EnsureSnapAppEnvironment(info, env); err != nil {
+		return fmt.Errorf("cannot update environment of %q: %v", info.InstanceName(), err)
+	}
+	snapst.AppEnvironment = env
+	return nil
+}
@@ -0,0 +1,99 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *snapmgrTestSuite) TestValidateAppEnvironment(c *C) {
+	for _, t := range []struct {
+		key, value string
+		err        string
+	}{
+		{"LOG_LEVEL", "debug", ""},
+		{"http_proxy", "http://proxy:3128/", ""},
+		{"_FOO1", "", ""},
+		{"SNAPPY", "1", ""},
+		{"1FOO", "bar", `invalid environment variable name "1FOO"`},
+		{"FOO-BAR", "bar", `invalid environment variable name "FOO-BAR"`},
+		{"", "bar", `invalid environment variable name ""`},
+		{"SNAP", "/", `cannot set reserved environment variable "SNAP"`},
+		{"SNAP_DATA", "/tmp", `cannot set reserved environment variable "SNAP_DATA"`},
+		{"FOO", "a\nb", `invalid value for environment variable "FOO": cannot contain newlines or NUL characters`},
+	} {
+		err := snapstate.ValidateAppEnvironment(t.key, t.value)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf(t.key))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf(t.key))
+		}
+	}
+}
+
+func (s *snapmgrTestSuite) TestSetAppEnvironment(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := snapstate.SetAppEnvironment(s.state, "hello-snap", "svc1", map[string]string{"FOO": "bar"}, nil)
+	c.Check(err, ErrorMatches, `snap "hello-snap" is not installed`)
+
+	si := &snap.SideInfo{RealName: "hello-snap", SnapID: "hello-snap-id", Revision: snap.R(1)}
+	snaptest.MockSnap(c, servicesSnap, si)
+	snapstate.Set(s.state, "hello-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		SnapType: "app",
+	})
+	snapstate.MockSnapReadInfo(snap.ReadInfo)
+	envFile := filepath.Join(dirs.SnapAppEnvDir, "snap.hello-snap.svc1.env")
+
+	err = snapstate.SetAppEnvironment(s.state, "hello-snap", "unknown", map[string]string{"FOO": "bar"}, nil)
+	c.Check(err, ErrorMatches, `snap "hello-snap" has no app "unknown"`)
+	err = snapstate.SetAppEnvironment(s.state, "hello-snap", "svc1", map[string]string{"SNAP_DATA": "/tmp"}, nil)
+	c.Check(err, ErrorMatches, `cannot set reserved environment variable "SNAP_DATA"`)
+
+	err = snapstate.SetAppEnvironment(s.state, "hello-snap", "svc1", map[string]string{"FOO": "bar", "LOG_LEVEL": "debug"}, nil)
+	c.Assert(err, IsNil)
+	err = snapstate.SetAppEnvironment(s.state, "hello-snap", "svc1", map[string]string{"LOG_LEVEL": "info"}, []string{"FOO", "UNSET"})
+	c.Assert(err, IsNil)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "hello-snap", &snapst), IsNil)
+	c.Check(snapst.AppEnvironment, DeepEquals, map[string]map[string]string{
+		"svc1": {"LOG_LEVEL": "info"},
+	})
+	c.Check(envFile, testutil.FileContains, "\nLOG_LEVEL=info\n")
+
+	err = snapstate.SetAppEnvironment(s.state, "hello-snap", "svc1", nil, []string{"LOG_LEVEL"})
+	c.Assert(err, IsNil)
+	c.Assert(snapstate.Get(s.state, "hello-snap", &snapst), IsNil)
+	c.Check(snapst.AppEnvironment, IsNil)
+	c.Check(envFile, testutil.FileAbsent)
+}
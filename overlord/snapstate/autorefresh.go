@@ -25,6 +25,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
@@ -58,6 +59,59 @@ var (
 	IsOnMeteredConnection func() (bool, error)
 )
 
+// hook setup by assertstate
+var RefreshPolicy func(st *state.State) (*asserts.RefreshPolicy, error)
+
+// refreshPolicy returns the refresh-policy assertion for the model of
+// the device, or nil if there is none.
+func refreshPolicy(st *state.State) (*asserts.RefreshPolicy, error) {
+	if RefreshPolicy == nil {
+		return nil, nil
+	}
+	policy, err := RefreshPolicy(st)
+	if asserts.IsNotFound(err) || err == state.ErrNoState {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// refreshPolicySnap returns the entry of the given refresh-policy for
+// the snap, or nil if there is no policy or the snap is not listed.
+func refreshPolicySnap(policy *asserts.RefreshPolicy, instanceName string) *asserts.RefreshPolicySnap {
+	if policy == nil {
+		return nil
+	}
+	snapName, _ := snap.SplitInstanceName(instanceName)
+	return policy.Snap(snapName)
+}
+
+// refreshPolicyChannel returns the channel the snap should track when
+// refreshed together with all the others, which is the one from the
+// refresh-policy if it sets one.
+func refreshPolicyChannel(policy *asserts.RefreshPolicy, instanceName string, snapst *SnapState) string {
+	if polSnap := refreshPolicySnap(policy, instanceName); polSnap != nil && polSnap.Channel != "" {
+		return polSnap.Channel
+	}
+	return snapst.TrackingChannel
+}
+
+// snapMaxPostponement returns for how long at most refreshes of the
+// given snap can be postponed, taking into account the refresh-policy.
+func snapMaxPostponement(st *state.State, instanceName string) (time.Duration, error) {
+	policy, err := refreshPolicy(st)
+	if err != nil {
+		return 0, err
+	}
+	polSnap := refreshPolicySnap(policy, instanceName)
+	if polSnap != nil && polSnap.MaxPostponement != 0 && polSnap.MaxPostponement < maxPostponement {
+		return polSnap.MaxPostponement, nil
+	}
+	return maxPostponement, nil
+}
+
 // refreshRetryDelay specified the minimum time to retry failed refreshes
 var refreshRetryDelay = 20 * time.Minute
 
@@ -168,7 +168,15 @@ func HoldRefresh(st *state.State, gatingSnap string, holdDuration time.Duration,
 			return err
 		}
 
+		// the refresh-policy can only shorten the postponement
 		mp := maxPostponement - maxPostponementBuffer
+		snapMax, err := snapMaxPostponement(st, heldSnap)
+		if err != nil {
+			return err
+		}
+		if snapMax < mp {
+			mp = snapMax
+		}
 		maxDur := maxAllowedPostponement(gatingSnap, heldSnap, mp)
 
 		// calculate max hold duration that's left considering previous hold
@@ -198,7 +206,7 @@ func HoldRefresh(st *state.State, gatingSnap string, holdDuration time.Duration,
 		}
 
 		newHold := now.Add(dur)
-		cutOff := lastRefreshTime.Add(maxPostponement - maxPostponementBuffer)
+		cutOff := lastRefreshTime.Add(mp)
 
 		// consider last refresh time and adjust hold duration if needed so it's
 		// not exceeded.
@@ -360,7 +368,12 @@ Loop:
 			return nil, err
 		}
 		// make sure we don't hold any snap for more than maxPostponement
-		if refreshed.Add(maxPostponement).Before(now) {
+		// or what its refresh-policy allows
+		snapMax, err := snapMaxPostponement(st, heldSnap)
+		if err != nil {
+			return nil, err
+		}
+		if refreshed.Add(snapMax).Before(now) {
 			continue
 		}
 		for _, hold := range holdingSnaps {
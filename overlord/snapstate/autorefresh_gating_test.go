@@ -446,6 +446,126 @@ func (s *autorefreshGatingSuite) TestHoldRefreshHelperCloseToMaxPostponement(c *
 	c.Check(gating["snap-a"]["snap-b"].HoldUntil.String(), DeepEquals, lastRefreshed.Add(90*time.Hour*24).String())
 }
 
+func (s *autorefreshGatingSuite) TestHoldRefreshHelperRefreshPolicyMaxPostponement(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	restore := snapstate.MockTimeNow(func() time.Time {
+		t, err := time.Parse(time.RFC3339, "2021-05-10T10:00:00Z")
+		c.Assert(err, IsNil)
+		return t
+	})
+	defer restore()
+
+	restore = MockRefreshPolicy(MakeRefreshPolicy(
+		map[string]interface{}{
+			"name":             "snap-b",
+			"id":               "snapbidididididididididididididi",
+			"max-postponement": "36h",
+		},
+		map[string]interface{}{
+			"name":             "snap-f",
+			"id":               "snapfidididididididididididididi",
+			"max-postponement": "240h",
+		},
+	))
+	defer restore()
+
+	mockInstalledSnap(c, st, snapAyaml, false)
+	mockInstalledSnap(c, st, snapByaml, false)
+	mockInstalledSnap(c, st, snapCyaml, false)
+	mockInstalledSnap(c, st, snapFyaml, false)
+
+	mockLastRefreshed(c, st, "2021-05-09T10:00:00Z", "snap-a", "snap-b", "snap-c", "snap-f")
+
+	c.Assert(snapstate.HoldRefresh(st, "snap-a", 0, "snap-b", "snap-c"), IsNil)
+	c.Assert(snapstate.HoldRefresh(st, "snap-f", 0, "snap-f"), IsNil)
+
+	var gating map[string]map[string]*snapstate.HoldState
+	c.Assert(st.Get("snaps-hold", &gating), IsNil)
+	c.Check(gating, DeepEquals, map[string]map[string]*snapstate.HoldState{
+		"snap-b": {
+			// capped by the max-postponement of the refresh-policy
+			// counting from the last refresh.
+			"snap-a": snapstate.MockHoldState("2021-05-10T10:00:00Z", "2021-05-10T22:00:00Z"),
+		},
+		"snap-c": {
+			// not listed in the refresh-policy
+			"snap-a": snapstate.MockHoldState("2021-05-10T10:00:00Z", "2021-05-12T10:00:00Z"),
+		},
+		"snap-f": {
+			"snap-f": snapstate.MockHoldState("2021-05-10T10:00:00Z", "2021-05-19T10:00:00Z"),
+		},
+	})
+
+	held, err := snapstate.HeldSnaps(st)
+	c.Assert(err, IsNil)
+	c.Check(held, DeepEquals, map[string]bool{
+		"snap-b": true,
+		"snap-c": true,
+		"snap-f": true,
+	})
+
+	// an explicit hold of snap-b is capped by its max-postponement too
+	c.Assert(snapstate.HoldRefresh(st, "snap-a", 24*time.Hour, "snap-b"), IsNil)
+	c.Assert(st.Get("snaps-hold", &gating), IsNil)
+	c.Check(gating["snap-b"]["snap-a"], DeepEquals, snapstate.MockHoldState("2021-05-10T10:00:00Z", "2021-05-10T22:00:00Z"))
+}
+
+func (s *autorefreshGatingSuite) TestHeldSnapsRefreshPolicyMaxPostponement(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	now := "2021-05-10T10:00:00Z"
+	restore := snapstate.MockTimeNow(func() time.Time {
+		t, err := time.Parse(time.RFC3339, now)
+		c.Assert(err, IsNil)
+		return t
+	})
+	defer restore()
+
+	restore = MockRefreshPolicy(MakeRefreshPolicy(
+		map[string]interface{}{
+			"name":             "snap-b",
+			"id":               "snapbidididididididididididididi",
+			"max-postponement": "48h",
+		},
+	))
+	defer restore()
+
+	mockInstalledSnap(c, st, snapAyaml, false)
+	mockInstalledSnap(c, st, snapByaml, false)
+	mockInstalledSnap(c, st, snapCyaml, false)
+	mockLastRefreshed(c, st, "2021-05-09T10:00:00Z", "snap-a", "snap-b", "snap-c")
+
+	c.Assert(snapstate.HoldRefresh(st, "snap-a", 0, "snap-b", "snap-c"), IsNil)
+
+	// pretend the hold state was recorded before the policy and is
+	// longer than the policy allows
+	var gating map[string]map[string]*snapstate.HoldState
+	c.Assert(st.Get("snaps-hold", &gating), IsNil)
+	gating["snap-b"]["snap-a"] = snapstate.MockHoldState(now, "2021-05-20T10:00:00Z")
+	gating["snap-c"]["snap-a"] = snapstate.MockHoldState(now, "2021-05-20T10:00:00Z")
+	st.Set("snaps-hold", gating)
+
+	held, err := snapstate.HeldSnaps(st)
+	c.Assert(err, IsNil)
+	c.Check(held, DeepEquals, map[string]bool{
+		"snap-b": true,
+		"snap-c": true,
+	})
+
+	// past the max-postponement of snap-b from its last refresh
+	now = "2021-05-11T11:00:00Z"
+	held, err = snapstate.HeldSnaps(st)
+	c.Assert(err, IsNil)
+	c.Check(held, DeepEquals, map[string]bool{
+		"snap-c": true,
+	})
+}
+
 func (s *autorefreshGatingSuite) TestHoldRefreshExplicitHoldTime(c *C) {
 	st := s.state
 	st.Lock()
@@ -20,9 +20,11 @@
 package backend
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil/sys"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
 )
@@ -47,13 +49,38 @@ func (b Backend) CopySnapData(newSnap, oldSnap *snap.Info, meter progress.Meter)
 	}
 
 	if oldSnap == nil {
-		return os.MkdirAll(newSnap.DataDir(), 0755)
-	} else if oldSnap.Revision == newSnap.Revision {
-		// nothing to do
-		return nil
+		if err := os.MkdirAll(newSnap.DataDir(), 0755); err != nil {
+			return err
+		}
+	} else if oldSnap.Revision != newSnap.Revision {
+		if err := copySnapData(oldSnap, newSnap); err != nil {
+			return err
+		}
 	}
 
-	return copySnapData(oldSnap, newSnap)
+	return chownDataDirsForRunAs(newSnap)
+}
+
+var sysChownPath = sys.ChownPath
+
+// chownDataDirsForRunAs hands $SNAP_DATA and $SNAP_COMMON over to the system
+// user the services of the snap run as so that they can write there, the
+// content of the directories is left alone.
+func chownDataDirsForRunAs(info *snap.Info) error {
+	runAs := info.RunAsUser()
+	if runAs == "" {
+		return nil
+	}
+	user, ok := snap.SupportedSystemUsernames[runAs]
+	if !ok {
+		return fmt.Errorf("cannot use unsupported system username %q for the data directories of snap %q", runAs, info.InstanceName())
+	}
+	for _, dir := range []string{info.DataDir(), info.CommonDataDir()} {
+		if err := sysChownPath(dir, sys.UserID(user.Id), sys.GroupID(user.Id)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // UndoCopySnapData removes the copy that may have been done for newInfo snap of oldInfo snap data and also the data directories that may have been created for newInfo snap.
@@ -31,6 +31,7 @@ import (
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
 	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
@@ -502,6 +503,65 @@ func (s *copydataSuite) TestCopyDataSameRevision(c *C) {
 
 }
 
+func (s *copydataSuite) TestCopyDataRunAsChownsDataDirs(c *C) {
+	const yaml = `name: hello
+version: 1.0
+system-usernames:
+  snap_daemon: shared
+apps:
+  svc:
+    daemon: simple
+    run-as: snap_daemon
+`
+	var chowned []string
+	restore := backend.MockSysChownPath(func(path string, uid sys.UserID, gid sys.GroupID) error {
+		c.Check(uid, Equals, sys.UserID(584788))
+		c.Check(gid, Equals, sys.GroupID(584788))
+		chowned = append(chowned, path)
+		return nil
+	})
+	defer restore()
+
+	v1 := snaptest.MockSnap(c, yaml, &snap.SideInfo{Revision: snap.R(10)})
+	err := s.be.CopySnapData(v1, nil, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(chowned, DeepEquals, []string{v1.DataDir(), v1.CommonDataDir()})
+
+	// a refresh copies the data and hands the new directory over too
+	chowned = nil
+	v2 := snaptest.MockSnap(c, yaml, &snap.SideInfo{Revision: snap.R(20)})
+	err = s.be.CopySnapData(v2, v1, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(chowned, DeepEquals, []string{v2.DataDir(), v2.CommonDataDir()})
+
+	// services running as root leave the ownership alone
+	chowned = nil
+	v3 := snaptest.MockSnap(c, helloYaml2, &snap.SideInfo{Revision: snap.R(30)})
+	err = s.be.CopySnapData(v3, v2, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(chowned, HasLen, 0)
+}
+
+func (s *copydataSuite) TestCopyDataRunAsChownError(c *C) {
+	const yaml = `name: hello
+version: 1.0
+system-usernames:
+  snap_daemon: shared
+apps:
+  svc:
+    daemon: simple
+    run-as: snap_daemon
+`
+	restore := backend.MockSysChownPath(func(path string, uid sys.UserID, gid sys.GroupID) error {
+		return fmt.Errorf("cannot chown %s", path)
+	})
+	defer restore()
+
+	v1 := snaptest.MockSnap(c, yaml, &snap.SideInfo{Revision: snap.R(10)})
+	err := s.be.CopySnapData(v1, nil, progress.Null)
+	c.Assert(err, ErrorMatches, "cannot chown "+regexp.QuoteMeta(v1.DataDir()))
+}
+
 func (s *copydataSuite) TestUndoCopyDataSameRevision(c *C) {
 	v1 := snaptest.MockSnap(c, helloYaml1, &snap.SideInfo{Revision: snap.R(10)})
 
@@ -21,6 +21,8 @@ package backend
 
 import (
 	"os/exec"
+
+	"github.com/snapcore/snapd/osutil/sys"
 )
 
 var (
@@ -43,3 +45,11 @@ func MockCommandFromSystemSnap(f func(string, ...string) (*exec.Cmd, error)) (re
 		commandFromSystemSnap = old
 	}
 }
+
+func MockSysChownPath(f func(path string, uid sys.UserID, gid sys.GroupID) error) (restore func()) {
+	old := sysChownPath
+	sysChownPath = f
+	return func() {
+		sysChownPath = old
+	}
+}
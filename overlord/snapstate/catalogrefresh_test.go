@@ -228,10 +228,18 @@ func (s *catalogRefreshTestSuite) TestCatalogRefreshUnSeeded(c *C) {
 }
 
 func (s *catalogRefreshTestSuite) TestCatalogRefreshUC20InstallMode(c *C) {
-	// mark system as being in install mode
+	s.testCatalogRefreshUC20EphemeralMode(c, "install")
+}
+
+func (s *catalogRefreshTestSuite) TestCatalogRefreshUC20FactoryResetMode(c *C) {
+	s.testCatalogRefreshUC20EphemeralMode(c, "factory-reset")
+}
+
+func (s *catalogRefreshTestSuite) testCatalogRefreshUC20EphemeralMode(c *C, mode string) {
+	// mark system as being in an ephemeral mode
 	trivialInstallDevice := &snapstatetest.TrivialDeviceContext{
 		DeviceModel: DefaultModel(),
-		SysMode:     "install",
+		SysMode:     mode,
 	}
 
 	r := snapstatetest.MockDeviceContext(trivialInstallDevice)
@@ -566,10 +566,39 @@ func checkAndCreateSystemUsernames(si *snap.Info) error {
 	return nil
 }
 
+// AllowedRunAs allows to hook support for retrieving the system usernames the
+// snap declaration allows the services of a snap to run as.
+var AllowedRunAs func(st *state.State, snapID string) ([]string, error)
+
+// checkRunAs checks that the snap declaration allows the services of the
+// snap to run as the system user they declare.
+func checkRunAs(st *state.State, snapInfo, _ *snap.Info, _ snap.Container, _ Flags, _ DeviceContext) error {
+	runAs := snapInfo.RunAsUser()
+	if runAs == "" {
+		return nil
+	}
+	if snapInfo.SnapID == "" {
+		// unasserted snap installed with --dangerous
+		return nil
+	}
+	if AllowedRunAs == nil {
+		return fmt.Errorf("internal error: cannot check run-as of snap %q without snap declarations support", snapInfo.InstanceName())
+	}
+	allowed, err := AllowedRunAs(st, snapInfo.SnapID)
+	if err != nil {
+		return fmt.Errorf("cannot check run-as of snap %q: %v", snapInfo.InstanceName(), err)
+	}
+	if !strutil.ListContains(allowed, runAs) {
+		return fmt.Errorf("snap %q is not allowed to run services as system user %q", snapInfo.InstanceName(), runAs)
+	}
+	return nil
+}
+
 func init() {
 	AddCheckSnapCallback(checkCoreName)
 	AddCheckSnapCallback(checkSnapdName)
 	AddCheckSnapCallback(checkGadgetOrKernel)
 	AddCheckSnapCallback(checkBases)
 	AddCheckSnapCallback(checkEpochs)
+	AddCheckSnapCallback(checkRunAs)
 }
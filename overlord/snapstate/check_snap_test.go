@@ -1277,6 +1277,70 @@ func (s *checkSnapSuite) testCheckSnapSystemUsernamesCallsCommon(c *C, expectedU
 	}
 }
 
+func (s *checkSnapSuite) TestCheckSnapRunAs(c *C) {
+	restore := seccomp_compiler.MockCompilerVersionInfo("dead 2.4.1 deadbeef bpf-actlog")
+	defer restore()
+	restore = snapstate.MockOsutilEnsureUserGroup(func(name string, id uint32, extraUsers bool) error {
+		return nil
+	})
+	defer restore()
+
+	const yaml = `name: foo
+version: 1.0
+system-usernames:
+  snap_daemon: shared
+apps:
+  svc:
+    daemon: simple
+    run-as: snap_daemon
+`
+	var allowed []string
+	var allowedErr error
+	var allowedCalls []string
+	oldAllowedRunAs := snapstate.AllowedRunAs
+	snapstate.AllowedRunAs = func(st *state.State, snapID string) ([]string, error) {
+		allowedCalls = append(allowedCalls, snapID)
+		return allowed, allowedErr
+	}
+	defer func() { snapstate.AllowedRunAs = oldAllowedRunAs }()
+
+	for _, tc := range []struct {
+		snapID  string
+		allowed []string
+		err     error
+		calls   []string
+		expErr  string
+	}{
+		// unasserted snaps are not checked
+		{snapID: ""},
+		{snapID: "foo-id", allowed: []string{"snap_daemon"}, calls: []string{"foo-id"}},
+		{snapID: "foo-id", allowed: []string{"snap_microk8s"}, calls: []string{"foo-id"},
+			expErr: `snap "foo" is not allowed to run services as system user "snap_daemon"`},
+		{snapID: "foo-id", calls: []string{"foo-id"},
+			expErr: `snap "foo" is not allowed to run services as system user "snap_daemon"`},
+		{snapID: "foo-id", err: errors.New("boom"), calls: []string{"foo-id"},
+			expErr: `cannot check run-as of snap "foo": boom`},
+	} {
+		allowed, allowedErr, allowedCalls = tc.allowed, tc.err, nil
+
+		info, err := snap.InfoFromSnapYaml([]byte(yaml))
+		c.Assert(err, IsNil)
+		info.SnapID = tc.snapID
+		restore := snapstate.MockOpenSnapFile(func(path string, si *snap.SideInfo) (*snap.Info, snap.Container, error) {
+			return info, emptyContainer(c), nil
+		})
+		defer restore()
+
+		err = snapstate.CheckSnap(s.st, "snap-path", "foo", nil, nil, snapstate.Flags{}, nil)
+		if tc.expErr != "" {
+			c.Check(err, ErrorMatches, tc.expErr)
+		} else {
+			c.Check(err, IsNil)
+		}
+		c.Check(allowedCalls, DeepEquals, tc.calls)
+	}
+}
+
 func (s *checkSnapSuite) TestCheckSnapRemodelKernel(c *C) {
 	reset := release.MockOnClassic(false)
 	defer reset()
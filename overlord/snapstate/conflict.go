@@ -122,6 +122,11 @@ func checkChangeConflictExclusiveKinds(st *state.State, newExclusiveChangeKind,
 				Message:    "creating recovery system in progress, no other changes allowed until this is done",
 				ChangeKind: "create-recovery-system",
 			}
+		case "remove-recovery-system":
+			return &ChangeConflictError{
+				Message:    "removing recovery system in progress, no other changes allowed until this is done",
+				ChangeKind: "remove-recovery-system",
+			}
 		default:
 			if newExclusiveChangeKind != "" {
 				// we want to run a new exclusive change, but other
@@ -66,6 +66,12 @@ func MockOpenSnapFile(mock func(path string, si *snap.SideInfo) (*snap.Info, sna
 	return func() { openSnapFile = prevOpenSnapFile }
 }
 
+func MockSnapdeltaApply(mock func(sourcePath, deltaPath, targetPath string) error) (restore func()) {
+	old := snapdeltaApply
+	snapdeltaApply = mock
+	return func() { snapdeltaApply = old }
+}
+
 func MockErrtrackerReport(mock func(string, string, string, map[string]string) (string, error)) (restore func()) {
 	prev := errtrackerReport
 	errtrackerReport = mock
@@ -272,6 +278,14 @@ func MockInstallSize(f func(st *state.State, snaps []minimalInstallInfo, userID
 	}
 }
 
+func MockInstallSizeInfo(f func(st *state.State, snaps []minimalInstallInfo, userID int) (map[string]uint64, map[string]*snap.Info, error)) func() {
+	old := installSizeInfo
+	installSizeInfo = f
+	return func() {
+		installSizeInfo = old
+	}
+}
+
 func MockGenerateSnapdWrappers(f func(snapInfo *snap.Info) error) func() {
 	old := generateSnapdWrappers
 	generateSnapdWrappers = f
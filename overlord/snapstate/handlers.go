@@ -1345,6 +1345,13 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) (err error) {
 		return err
 	}
 
+	// drop the environment overrides of apps the new revision does not have
+	oldAppEnvironment := snapst.AppEnvironment
+	if err := pruneAppEnvironment(snapst, newInfo); err != nil {
+		return err
+	}
+	t.Set("old-app-environment", oldAppEnvironment)
+
 	// Compatibility with old snapd: check if we have auto-connect task and
 	// if not, inject it after self (link-snap) for snaps that are not core
 	if newInfo.Type() != snap.TypeOS {
@@ -1577,6 +1584,10 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	if err := t.Get("old-cohort-key", &oldCohortKey); err != nil && err != state.ErrNoState {
 		return err
 	}
+	var oldAppEnvironment map[string]map[string]string
+	if err := t.Get("old-app-environment", &oldAppEnvironment); err != nil && err != state.ErrNoState {
+		return err
+	}
 
 	if len(snapst.Sequence) == 1 {
 		// XXX: shouldn't these two just log and carry on? this is an undo handler...
@@ -1622,6 +1633,7 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	snapst.RefreshInhibitedTime = oldRefreshInhibitedTime
 	snapst.LastRefreshTime = oldLastRefreshTime
 	snapst.CohortKey = oldCohortKey
+	snapst.AppEnvironment = oldAppEnvironment
 
 	newInfo, err := readInfo(snapsup.InstanceName(), snapsup.SideInfo, 0)
 	if err != nil {
@@ -1637,6 +1649,15 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 		if err = config.RestoreRevisionConfig(st, snapsup.InstanceName(), oldCurrent); err != nil {
 			return err
 		}
+		if len(snapst.AppEnvironment) != 0 {
+			oldInfo, err := snapst.CurrentInfo()
+			if err != nil {
+				return err
+			}
+			if err := wrappers.EnsureSnapAppEnvironment(oldInfo, snapst.AppEnvironment); err != nil {
+				return err
+			}
+		}
 	} else {
 		// in the case of an install we need to clear any config
 		err = config.DeleteSnapConfig(st, snapsup.InstanceName())
@@ -2276,6 +2297,9 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 		if err := m.removeSnapCookie(st, snapsup.InstanceName()); err != nil {
 			return fmt.Errorf("cannot remove snap cookie: %v", err)
 		}
+		if err := wrappers.RemoveSnapAppEnvironment(snapsup.InstanceName()); err != nil {
+			return fmt.Errorf("cannot remove app environment overrides: %v", err)
+		}
 
 		otherInstances, err := hasOtherInstances(st, snapsup.InstanceName())
 		if err != nil {
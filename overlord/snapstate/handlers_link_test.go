@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"time"
 
@@ -1144,6 +1145,98 @@ func (s *linkSnapSuite) TestDoUndoLinkSnapSequenceHadCandidate(c *C) {
 	c.Check(t.Status(), Equals, state.UndoneStatus)
 }
 
+func (s *linkSnapSuite) TestDoLinkSnapPrunesAppEnvironment(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	si1 := &snap.SideInfo{
+		RealName: "services-snap",
+		Revision: snap.R(1),
+	}
+	si2 := &snap.SideInfo{
+		RealName: "services-snap",
+		Revision: snap.R(2),
+	}
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si1},
+		Current:  si1.Revision,
+		AppEnvironment: map[string]map[string]string{
+			"svc1":    {"FOO": "bar"},
+			"removed": {"BAZ": "1"},
+		},
+	})
+	removedEnvFile := filepath.Join(dirs.SnapAppEnvDir, "snap.services-snap.removed.env")
+	c.Assert(os.MkdirAll(dirs.SnapAppEnvDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(removedEnvFile, []byte("BAZ=1\n"), 0644), IsNil)
+
+	t := s.state.NewTask("link-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si2,
+	})
+	s.state.NewChange("dummy", "...").AddTask(t)
+
+	s.state.Unlock()
+	s.se.Ensure()
+	s.se.Wait()
+	s.state.Lock()
+
+	var snapst snapstate.SnapState
+	err := snapstate.Get(s.state, "services-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(snapst.AppEnvironment, DeepEquals, map[string]map[string]string{
+		"svc1": {"FOO": "bar"},
+	})
+	c.Check(removedEnvFile, testutil.FileAbsent)
+	c.Check(filepath.Join(dirs.SnapAppEnvDir, "snap.services-snap.svc1.env"), testutil.FileContains, "\nFOO=bar\n")
+}
+
+func (s *linkSnapSuite) TestDoUndoLinkSnapRestoresAppEnvironment(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	si1 := &snap.SideInfo{
+		RealName: "services-snap",
+		Revision: snap.R(1),
+	}
+	si2 := &snap.SideInfo{
+		RealName: "services-snap",
+		Revision: snap.R(2),
+	}
+	appEnv := map[string]map[string]string{
+		"svc1":    {"FOO": "bar"},
+		"removed": {"BAZ": "1"},
+	}
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Sequence:       []*snap.SideInfo{si1},
+		Current:        si1.Revision,
+		AppEnvironment: appEnv,
+	})
+	t := s.state.NewTask("link-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si2,
+	})
+	chg := s.state.NewChange("dummy", "...")
+	chg.AddTask(t)
+
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(t)
+	chg.AddTask(terr)
+
+	s.state.Unlock()
+	for i := 0; i < 6; i++ {
+		s.se.Ensure()
+		s.se.Wait()
+	}
+	s.state.Lock()
+
+	var snapst snapstate.SnapState
+	err := snapstate.Get(s.state, "services-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(t.Status(), Equals, state.UndoneStatus)
+	c.Check(snapst.Current, Equals, snap.R(1))
+	c.Check(snapst.AppEnvironment, DeepEquals, appEnv)
+	c.Check(filepath.Join(dirs.SnapAppEnvDir, "snap.services-snap.svc1.env"), testutil.FileContains, "\nFOO=bar\n")
+}
+
 func (s *linkSnapSuite) TestDoUndoUnlinkCurrentSnapCore(c *C) {
 	restore := release.MockOnClassic(true)
 	defer restore()
@@ -20,8 +20,13 @@
 package snapstate_test
 
 import (
+	. "gopkg.in/check.v1"
+
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap/snaptest"
 )
 
@@ -97,3 +102,48 @@ func ClassicModel() *asserts.Model {
 	}
 	return assertstest.FakeAssertion(headers).(*asserts.Model)
 }
+
+func MakeRefreshPolicy(snaps ...interface{}) *asserts.RefreshPolicy {
+	headers := map[string]interface{}{
+		"type":         "refresh-policy",
+		"authority-id": "brand",
+		"series":       "16",
+		"brand-id":     "brand",
+		"model":        "baz-3000",
+		"snaps":        snaps,
+		"timestamp":    "2018-01-01T08:00:00+00:00",
+	}
+	return assertstest.FakeAssertion(headers).(*asserts.RefreshPolicy)
+}
+
+func MockRefreshPolicy(policy *asserts.RefreshPolicy) (restore func()) {
+	old := snapstate.RefreshPolicy
+	snapstate.RefreshPolicy = func(*state.State) (*asserts.RefreshPolicy, error) {
+		return policy, nil
+	}
+	return func() {
+		snapstate.RefreshPolicy = old
+	}
+}
+
+func mockEnforcedValidationSets(c *C, snaps ...interface{}) (restore func()) {
+	vs := assertstest.FakeAssertion(map[string]interface{}{
+		"type":         "validation-set",
+		"authority-id": "acme",
+		"series":       "16",
+		"account-id":   "acme",
+		"name":         "set",
+		"sequence":     "1",
+		"snaps":        snaps,
+	}).(*asserts.ValidationSet)
+	valsets := snapasserts.NewValidationSets()
+	c.Assert(valsets.Add(vs), IsNil)
+
+	old := snapstate.EnforcedValidationSets
+	snapstate.EnforcedValidationSets = func(*state.State) (*snapasserts.ValidationSets, error) {
+		return valsets, nil
+	}
+	return func() {
+		snapstate.EnforcedValidationSets = old
+	}
+}
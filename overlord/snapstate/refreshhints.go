@@ -150,6 +150,11 @@ func refreshHintsFromCandidates(st *state.State, updates []*snap.Info, ignoreVal
 		}
 	}
 
+	policy, err := refreshPolicy(st)
+	if err != nil {
+		return nil, err
+	}
+
 	hints := make(map[string]*refreshCandidate, len(updates))
 	for _, update := range updates {
 		var snapst SnapState
@@ -169,7 +174,7 @@ func refreshHintsFromCandidates(st *state.State, updates []*snap.Info, ignoreVal
 			SnapSetup: SnapSetup{
 				Base:      update.Base,
 				Prereq:    defaultContentPlugProviders(st, update),
-				Channel:   snapst.TrackingChannel,
+				Channel:   refreshPolicyChannel(policy, update.InstanceName(), &snapst),
 				CohortKey: snapst.CohortKey,
 				// UserID not set
 				Flags:        flags.ForSnapSetup(),
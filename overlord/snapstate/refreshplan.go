@@ -0,0 +1,266 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"context"
+	"sort"
+
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// RefreshPlan describes what a refresh would do, without doing it.
+type RefreshPlan struct {
+	// Snaps are the snaps that would be refreshed.
+	Snaps []string `json:"snaps"`
+	// Downloads are the snaps that would be downloaded, including
+	// prerequisites that are not installed yet.
+	Downloads []PlannedDownload `json:"downloads"`
+	// TotalDownloadSize is the sum of the sizes of all downloads.
+	TotalDownloadSize int64 `json:"total-download-size"`
+	// Tasks are the tasks that would make up the refresh change, in
+	// order, together with the tasks each of them would wait for.
+	Tasks []PlannedTask `json:"tasks"`
+	// AffectedSnaps maps the snaps with a gate-auto-refresh hook that
+	// are affected by the refresh to the snaps affecting them.
+	AffectedSnaps map[string][]string `json:"affected-snaps,omitempty"`
+	// RebootRequired is true if the refresh would require a reboot.
+	RebootRequired bool `json:"reboot-required"`
+}
+
+// PlannedDownload describes a snap that would be downloaded by a refresh.
+type PlannedDownload struct {
+	Snap         string        `json:"snap"`
+	Revision     snap.Revision `json:"revision"`
+	Channel      string        `json:"channel,omitempty"`
+	Size         int64         `json:"size"`
+	Prerequisite bool          `json:"prerequisite,omitempty"`
+}
+
+// PlannedTask describes a task that would be part of a refresh or of
+// another planned change.
+type PlannedTask struct {
+	ID        string   `json:"id"`
+	Kind      string   `json:"kind"`
+	Summary   string   `json:"summary"`
+	Snap      string   `json:"snap,omitempty"`
+	WaitTasks []string `json:"wait-tasks,omitempty"`
+}
+
+// PlanUpdateMany computes what UpdateMany would do for the given list of
+// names (or for everything if the list is empty) without queueing anything.
+// The store is queried and validation, refresh-control, disk-space and
+// conflict checks are performed as for a real refresh. The tasks of the
+// plan are created on a copy of the state and so never end up in it.
+// Note that the state must be locked by the caller.
+func PlanUpdateMany(ctx context.Context, st *state.State, names []string, userID int, flags *Flags) (*RefreshPlan, error) {
+	if flags == nil {
+		flags = &Flags{}
+	}
+
+	updates, params, deviceCtx, err := updateManyCandidates(ctx, st, names, userID, nil, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	toUpdate := make([]minimalInstallInfo, len(updates))
+	for i, up := range updates {
+		toUpdate[i] = installSnapInfo{up}
+	}
+
+	plan := &RefreshPlan{
+		Snaps:     []string{},
+		Downloads: []PlannedDownload{},
+		Tasks:     []PlannedTask{},
+	}
+	if len(updates) > 0 {
+		sizes, prereqs, err := installSizeInfo(st, toUpdate, userID)
+		if err != nil {
+			return nil, err
+		}
+		plan.Downloads = plannedDownloads(updates, prereqs)
+		for _, dl := range plan.Downloads {
+			plan.TotalDownloadSize += dl.Size
+		}
+
+		tr := config.NewTransaction(st)
+		checkDiskSpaceRefresh, err := features.Flag(tr, features.CheckDiskSpaceRefresh)
+		if err != nil && !config.IsNoOption(err) {
+			return nil, err
+		}
+		if checkDiskSpaceRefresh {
+			// same check as done by UpdateMany
+			var totalSize uint64
+			for _, sz := range sizes {
+				totalSize += sz
+			}
+			if err := checkRefreshDiskSpace(updates, totalSize); err != nil {
+				return nil, err
+			}
+		}
+
+		affected, err := affectedByRefresh(st, updates)
+		if err != nil {
+			return nil, err
+		}
+		for name, aff := range affected {
+			if plan.AffectedSnaps == nil {
+				plan.AffectedSnaps = make(map[string][]string, len(affected))
+			}
+			affecting := make([]string, 0, len(aff.AffectingSnaps))
+			for affectingSnap := range aff.AffectingSnaps {
+				affecting = append(affecting, affectingSnap)
+			}
+			sort.Strings(affecting)
+			plan.AffectedSnaps[name] = affecting
+		}
+
+		plan.RebootRequired = refreshRequiresReboot(deviceCtx, updates)
+	}
+
+	// the tasks of the plan are never run, create them on a copy of the
+	// state that is discarded afterwards
+	planSt := st.Copy()
+	planSt.Lock()
+	defer planSt.Unlock()
+
+	updated, tasksets, err := doUpdate(ctx, planSt, names, toUpdate, params, userID, flags, deviceCtx, "")
+	if err != nil {
+		return nil, err
+	}
+	tasksets = finalizeUpdate(planSt, tasksets, len(updates) > 0, updated, userID, flags)
+
+	if updated != nil {
+		plan.Snaps = updated
+	}
+	plan.Tasks = PlannedTasks(tasksets)
+
+	return plan, nil
+}
+
+func plannedDownloads(updates []*snap.Info, prereqs map[string]*snap.Info) []PlannedDownload {
+	downloads := make([]PlannedDownload, 0, len(updates)+len(prereqs))
+	for _, up := range updates {
+		downloads = append(downloads, PlannedDownload{
+			Snap:     up.InstanceName(),
+			Revision: up.Revision,
+			Channel:  up.Channel,
+			Size:     up.Size,
+		})
+	}
+
+	prereqNames := make([]string, 0, len(prereqs))
+	for name := range prereqs {
+		prereqNames = append(prereqNames, name)
+	}
+	sort.Strings(prereqNames)
+	for _, name := range prereqNames {
+		info := prereqs[name]
+		downloads = append(downloads, PlannedDownload{
+			Snap:         name,
+			Revision:     info.Revision,
+			Channel:      info.Channel,
+			Size:         info.Size,
+			Prerequisite: true,
+		})
+	}
+
+	return downloads
+}
+
+// PlannedTasks describes the tasks of the given task sets, which must not
+// have been added to a change, in order.
+func PlannedTasks(tasksets []*state.TaskSet) []PlannedTask {
+	// the tasks are not part of a change so state.Task() cannot be used
+	// to look up the task carrying the snap-setup
+	byID := make(map[string]*state.Task)
+	for _, ts := range tasksets {
+		for _, t := range ts.Tasks() {
+			byID[t.ID()] = t
+		}
+	}
+
+	snapName := func(t *state.Task) string {
+		var snapsup SnapSetup
+		if err := t.Get("snap-setup", &snapsup); err == nil {
+			return snapsup.InstanceName()
+		}
+		var hooksup struct {
+			Snap string `json:"snap"`
+		}
+		if err := t.Get("hook-setup", &hooksup); err == nil {
+			return hooksup.Snap
+		}
+		var id string
+		if err := t.Get("snap-setup-task", &id); err != nil {
+			return ""
+		}
+		if setupTask := byID[id]; setupTask != nil {
+			if err := setupTask.Get("snap-setup", &snapsup); err == nil {
+				return snapsup.InstanceName()
+			}
+		}
+		return ""
+	}
+
+	planned := []PlannedTask{}
+	for _, ts := range tasksets {
+		for _, t := range ts.Tasks() {
+			pt := PlannedTask{
+				ID:      t.ID(),
+				Kind:    t.Kind(),
+				Summary: t.Summary(),
+				Snap:    snapName(t),
+			}
+			for _, wt := range t.WaitTasks() {
+				pt.WaitTasks = append(pt.WaitTasks, wt.ID())
+			}
+			planned = append(planned, pt)
+		}
+	}
+
+	return planned
+}
+
+// refreshRequiresReboot returns whether refreshing the given snaps would
+// require a reboot of the device.
+func refreshRequiresReboot(deviceCtx DeviceContext, updates []*snap.Info) bool {
+	var bootBase string
+	if !deviceCtx.Classic() {
+		bootBase = deviceCtx.Model().Base()
+		if bootBase == "" {
+			bootBase = "core"
+		}
+	}
+
+	for _, up := range updates {
+		// XXX: gadget refresh doesn't always require reboot, refine this
+		if up.Type() == snap.TypeKernel || up.Type() == snap.TypeGadget {
+			return true
+		}
+		if bootBase != "" && up.InstanceName() == bootBase {
+			return true
+		}
+	}
+	return false
+}
@@ -175,6 +175,10 @@ type SnapState struct {
 
 	// LastRefreshTime records the time when the snap was last refreshed.
 	LastRefreshTime *time.Time `json:"last-refresh-time,omitempty"`
+
+	// AppEnvironment holds the environment overrides set by the
+	// administrator, keyed by app name, see appenv.go
+	AppEnvironment map[string]map[string]string `json:"app-environment,omitempty"`
 }
 
 func (snapst *SnapState) SetTrackingChannel(s string) error {
@@ -31,6 +31,7 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/features"
@@ -47,6 +48,7 @@ import (
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/snapdelta"
 	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/strutil"
 )
@@ -74,6 +76,8 @@ var ErrNothingToDo = errors.New("nothing to do")
 
 var osutilCheckFreeSpace = osutil.CheckFreeSpace
 
+var snapdeltaApply = snapdelta.Apply
+
 type minimalInstallInfo interface {
 	InstanceName() string
 	Type() snap.Type
@@ -413,7 +417,19 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	}
 
 	if runRefreshHooks {
-		postRefreshHook := SetupPostRefreshHook(st, snapsup.InstanceName())
+		// the refresh-policy can ask for automatic refreshes to
+		// not be reverted when the post-refresh hook fails
+		ignoreHookError := false
+		if snapsup.IsAutoRefresh {
+			policy, err := refreshPolicy(st)
+			if err != nil {
+				return nil, err
+			}
+			if polSnap := refreshPolicySnap(policy, snapsup.InstanceName()); polSnap != nil {
+				ignoreHookError = !polSnap.AutoRevert
+			}
+		}
+		postRefreshHook := SetupPostRefreshHook(st, snapsup.InstanceName(), ignoreHookError)
 		addTask(postRefreshHook)
 		prev = postRefreshHook
 	}
@@ -562,7 +578,7 @@ var SetupPreRefreshHook = func(st *state.State, snapName string) *state.Task {
 	panic("internal error: snapstate.SetupPreRefreshHook is unset")
 }
 
-var SetupPostRefreshHook = func(st *state.State, snapName string) *state.Task {
+var SetupPostRefreshHook = func(st *state.State, snapName string, ignoreError bool) *state.Task {
 	panic("internal error: snapstate.SetupPostRefreshHook is unset")
 }
 
@@ -813,6 +829,40 @@ func ensureInstallPreconditions(st *state.State, info *snap.Info, flags Flags, s
 // local revision and sideloading, or full metadata in which case it
 // the snap will appear as installed from the store.
 func InstallPath(st *state.State, si *snap.SideInfo, path, instanceName, channel string, flags Flags) (*state.TaskSet, *snap.Info, error) {
+	deviceCtx, err := DeviceCtxFromState(st, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return installPath(st, si, path, instanceName, &RevisionOptions{Channel: channel}, 0, flags, deviceCtx, "")
+}
+
+// InstallPathWithDeviceContext returns a set of tasks for installing a snap
+// from a file path, checked against the given deviceCtx. The change with
+// id fromChange is ignored when checking for conflicts. If the snap is
+// already installed the tasks refresh it to the local file instead.
+// Note that the state must be locked by the caller.
+//
+// The returned TaskSet will contain a DownloadAndChecksDoneEdge.
+func InstallPathWithDeviceContext(st *state.State, si *snap.SideInfo, path, name string, opts *RevisionOptions, userID int, flags Flags, deviceCtx DeviceContext, fromChange string) (*state.TaskSet, error) {
+	if opts == nil {
+		opts = &RevisionOptions{}
+	}
+	ts, _, err := installPath(st, si, path, name, opts, userID, flags, deviceCtx, fromChange)
+	if err != nil {
+		return nil, err
+	}
+	// local snaps come with their assertions already checked, the
+	// downloads and checks are done once the snap is prepared
+	for _, t := range ts.Tasks() {
+		if t.Kind() == "prepare-snap" {
+			ts.MarkEdge(t, DownloadAndChecksDoneEdge)
+			break
+		}
+	}
+	return ts, nil
+}
+
+func installPath(st *state.State, si *snap.SideInfo, path, instanceName string, opts *RevisionOptions, userID int, flags Flags, deviceCtx DeviceContext, fromChange string) (*state.TaskSet, *snap.Info, error) {
 	if si.RealName == "" {
 		return nil, nil, fmt.Errorf("internal error: snap name to install %q not provided", path)
 	}
@@ -821,13 +871,8 @@ func InstallPath(st *state.State, si *snap.SideInfo, path, instanceName, channel
 		instanceName = si.RealName
 	}
 
-	deviceCtx, err := DeviceCtxFromState(st, nil)
-	if err != nil {
-		return nil, nil, err
-	}
-
 	var snapst SnapState
-	err = Get(st, instanceName, &snapst)
+	err := Get(st, instanceName, &snapst)
 	if err != nil && err != state.ErrNoState {
 		return nil, nil, err
 	}
@@ -838,7 +883,7 @@ func InstallPath(st *state.State, si *snap.SideInfo, path, instanceName, channel
 		}
 	}
 
-	channel, err = resolveChannel(st, instanceName, snapst.TrackingChannel, channel, deviceCtx)
+	channel, err := resolveChannel(st, instanceName, snapst.TrackingChannel, opts.Channel, deviceCtx)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -887,14 +932,47 @@ func InstallPath(st *state.State, si *snap.SideInfo, path, instanceName, channel
 		Channel:     channel,
 		Flags:       flags.ForSnapSetup(),
 		Type:        info.Type(),
+		UserID:      userID,
 		PlugsOnly:   len(info.Slots) == 0,
 		InstanceKey: info.InstanceKey,
 	}
 
-	ts, err := doInstall(st, &snapst, snapsup, instFlags, "", inUseFor(deviceCtx))
+	ts, err := doInstall(st, &snapst, snapsup, instFlags, fromChange, inUseFor(deviceCtx))
 	return ts, info, err
 }
 
+// ApplyDelta reconstructs at targetPath the snap file obtained by
+// applying the delta at deltaPath to revision fromRev of the installed
+// snap instanceName, or to its current revision if fromRev is unset.
+// The reconstructed file is meant to be verified and then installed
+// with InstallPath.
+//
+// Note that the state must be locked by the caller. As reconstructing
+// large snaps can take a while, the state is unlocked while the delta is
+// applied and locked again before returning.
+func ApplyDelta(st *state.State, instanceName string, fromRev snap.Revision, deltaPath, targetPath string) error {
+	var snapst SnapState
+	err := Get(st, instanceName, &snapst)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !snapst.IsInstalled() {
+		return &snap.NotInstalledError{Snap: instanceName}
+	}
+	if fromRev.Unset() {
+		fromRev = snapst.Current
+	}
+	if snapst.LastIndex(fromRev) < 0 {
+		return &snap.NotInstalledError{Snap: instanceName, Rev: fromRev}
+	}
+
+	sourcePath := snap.MinimalPlaceInfo(instanceName, fromRev).MountFile()
+
+	st.Unlock()
+	defer st.Lock()
+	return snapdeltaApply(sourcePath, deltaPath, targetPath)
+}
+
 // TryPath returns a set of tasks for trying a snap from a file path.
 // Note that the state must be locked by the caller.
 func TryPath(st *state.State, name, path string, flags Flags) (*state.TaskSet, error) {
@@ -1150,21 +1228,62 @@ func updateManyFiltered(ctx context.Context, st *state.State, names []string, us
 	if flags == nil {
 		flags = &Flags{}
 	}
-	user, err := userFromUserID(st, userID)
+
+	updates, params, deviceCtx, err := updateManyCandidates(ctx, st, names, userID, filter, flags)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	toUpdate := make([]minimalInstallInfo, len(updates))
+	for i, up := range updates {
+		toUpdate[i] = installSnapInfo{up}
+	}
+
+	tr := config.NewTransaction(st)
+	checkDiskSpaceRefresh, err := features.Flag(tr, features.CheckDiskSpaceRefresh)
+	if err != nil && !config.IsNoOption(err) {
+		return nil, nil, err
+	}
+	if checkDiskSpaceRefresh {
+		// check if there is enough disk space for requested snap and its
+		// prerequisites.
+		totalSize, err := installSize(st, toUpdate, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkRefreshDiskSpace(updates, totalSize); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	updated, tasksets, err := doUpdate(ctx, st, names, toUpdate, params, userID, flags, deviceCtx, fromChange)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasksets = finalizeUpdate(st, tasksets, len(updates) > 0, updated, userID, flags)
+	return updated, tasksets, nil
+}
+
+// updateManyCandidates returns the snaps that the store says are updateable
+// out of the given names (or of all snaps if the list is empty) after
+// filtering and validation, together with the parameters to use for
+// updating each of them.
+func updateManyCandidates(ctx context.Context, st *state.State, names []string, userID int, filter updateFilter, flags *Flags) ([]*snap.Info, updateParamsFunc, DeviceContext, error) {
+	user, err := userFromUserID(st, userID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	// need to have a model set before trying to talk the store
 	deviceCtx, err := DevicePastSeeding(st, nil)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	refreshOpts := &store.RefreshOptions{IsAutoRefresh: flags.IsAutoRefresh}
 	updates, stateByInstanceName, ignoreValidation, err := refreshCandidates(ctx, st, names, user, refreshOpts)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	if filter != nil {
@@ -1182,13 +1301,18 @@ func updateManyFiltered(ctx context.Context, st *state.State, names []string, us
 		if err != nil {
 			// not doing "refresh all" report the error
 			if len(names) != 0 {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
 			// doing "refresh all", log the problems
 			logger.Noticef("cannot refresh some snaps: %v", err)
 		}
 	}
 
+	policy, err := refreshPolicy(st)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	params := func(update *snap.Info) (*RevisionOptions, Flags, *SnapState) {
 		snapst := stateByInstanceName[update.InstanceName()]
 		// setting options to what's in state as multi-refresh doesn't let you change these
@@ -1196,51 +1320,37 @@ func updateManyFiltered(ctx context.Context, st *state.State, names []string, us
 			Channel:   snapst.TrackingChannel,
 			CohortKey: snapst.CohortKey,
 		}
+		if len(names) == 0 {
+			// except for moving to the channel of the refresh-policy
+			opts.Channel = refreshPolicyChannel(policy, update.InstanceName(), snapst)
+		}
 		return opts, snapst.Flags, snapst
 
 	}
 
-	toUpdate := make([]minimalInstallInfo, len(updates))
-	for i, up := range updates {
-		toUpdate[i] = installSnapInfo{up}
-	}
+	return updates, params, deviceCtx, nil
+}
 
-	tr := config.NewTransaction(st)
-	checkDiskSpaceRefresh, err := features.Flag(tr, features.CheckDiskSpaceRefresh)
-	if err != nil && !config.IsNoOption(err) {
-		return nil, nil, err
-	}
-	if checkDiskSpaceRefresh {
-		// check if there is enough disk space for requested snap and its
-		// prerequisites.
-		totalSize, err := installSize(st, toUpdate, userID)
-		if err != nil {
-			return nil, nil, err
-		}
-		requiredSpace := safetyMarginDiskSpace(totalSize)
-		path := dirs.SnapdStateDir(dirs.GlobalRootDir)
-		if err := osutilCheckFreeSpace(path, requiredSpace); err != nil {
-			snaps := make([]string, len(updates))
-			for i, up := range updates {
-				snaps[i] = up.InstanceName()
-			}
-			if _, ok := err.(*osutil.NotEnoughDiskSpaceError); ok {
-				return nil, nil, &InsufficientSpaceError{
-					Path:       path,
-					Snaps:      snaps,
-					ChangeKind: "refresh",
-				}
+// checkRefreshDiskSpace checks that there is enough free disk space to
+// download the given updates, totalling totalSize with their prerequisites.
+func checkRefreshDiskSpace(updates []*snap.Info, totalSize uint64) error {
+	requiredSpace := safetyMarginDiskSpace(totalSize)
+	path := dirs.SnapdStateDir(dirs.GlobalRootDir)
+	if err := osutilCheckFreeSpace(path, requiredSpace); err != nil {
+		snaps := make([]string, len(updates))
+		for i, up := range updates {
+			snaps[i] = up.InstanceName()
+		}
+		if _, ok := err.(*osutil.NotEnoughDiskSpaceError); ok {
+			return &InsufficientSpaceError{
+				Path:       path,
+				Snaps:      snaps,
+				ChangeKind: "refresh",
 			}
-			return nil, nil, err
 		}
+		return err
 	}
-
-	updated, tasksets, err := doUpdate(ctx, st, names, toUpdate, params, userID, flags, deviceCtx, fromChange)
-	if err != nil {
-		return nil, nil, err
-	}
-	tasksets = finalizeUpdate(st, tasksets, len(updates) > 0, updated, userID, flags)
-	return updated, tasksets, nil
+	return nil
 }
 
 func doUpdate(ctx context.Context, st *state.State, names []string, updates []minimalInstallInfo, params updateParamsFunc, userID int, globalFlags *Flags, deviceCtx DeviceContext, fromChange string) ([]string, []*state.TaskSet, error) {
@@ -2531,6 +2641,74 @@ func RemoveMany(st *state.State, names []string) ([]string, []*state.TaskSet, er
 	return removed, tasksets, nil
 }
 
+// EnforceSnaps returns the task sets that bring the system into conformity
+// with the given validation sets, based on the validation error resulting
+// from checking the installed snaps against them. Missing snaps are
+// installed, snaps at the wrong revision are refreshed to the required one
+// and invalid snaps are removed. It also returns the names of the
+// affected snaps.
+// Note that the state must be locked by the caller.
+func EnforceSnaps(ctx context.Context, st *state.State, valsets *snapasserts.ValidationSets, validErr *snapasserts.ValidationSetsValidationError, userID int) ([]*state.TaskSet, []string, error) {
+	revisions, err := valsets.Revisions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tasksets []*state.TaskSet
+	var affected []string
+
+	missing := make([]string, 0, len(validErr.MissingSnaps))
+	for name := range validErr.MissingSnaps {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		// the revision is unset if not constrained by the validation
+		// sets, the default channel is used then
+		opts := &RevisionOptions{Revision: revisions[name]}
+		ts, err := Install(ctx, st, name, opts, userID, Flags{})
+		if err != nil {
+			return nil, nil, err
+		}
+		tasksets = append(tasksets, ts)
+		affected = append(affected, name)
+	}
+
+	wrongRev := make([]string, 0, len(validErr.WrongRevisionSnaps))
+	for name := range validErr.WrongRevisionSnaps {
+		wrongRev = append(wrongRev, name)
+	}
+	sort.Strings(wrongRev)
+	for _, name := range wrongRev {
+		rev, ok := revisions[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("internal error: no revision required for snap %q by validation sets", name)
+		}
+		ts, err := Update(st, name, &RevisionOptions{Revision: rev}, userID, Flags{})
+		if err != nil {
+			return nil, nil, err
+		}
+		tasksets = append(tasksets, ts)
+		affected = append(affected, name)
+	}
+
+	invalid := make([]string, 0, len(validErr.InvalidSnaps))
+	for name := range validErr.InvalidSnaps {
+		invalid = append(invalid, name)
+	}
+	sort.Strings(invalid)
+	for _, name := range invalid {
+		ts, err := Remove(st, name, snap.R(0), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		tasksets = append(tasksets, ts)
+		affected = append(affected, name)
+	}
+
+	return tasksets, affected, nil
+}
+
 // Revert returns a set of tasks for reverting to the previous version of the snap.
 // Note that the state must be locked by the caller.
 func Revert(st *state.State, name string, flags Flags) (*state.TaskSet, error) {
@@ -732,6 +732,67 @@ func (s *snapmgrTestSuite) TestInstallPathConflict(c *C) {
 	c.Assert(err, ErrorMatches, `snap "some-snap" has "install" change in progress`)
 }
 
+func (s *snapmgrTestSuite) TestInstallPathWithDeviceContext(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// unset the global store, installing from a file must not need it
+	snapstate.ReplaceStore(s.state, nil)
+
+	deviceCtx := &snapstatetest.TrivialDeviceContext{DeviceModel: DefaultModel(), CtxStore: s.fakeStore}
+
+	mockSnap := makeTestSnap(c, "name: some-snap\nversion: 1.0")
+	si := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(8)}
+	opts := &snapstate.RevisionOptions{Channel: "some-channel"}
+	ts, err := snapstate.InstallPathWithDeviceContext(s.state, si, mockSnap, "some-snap", opts, 0, snapstate.Flags{}, deviceCtx, "")
+	c.Assert(err, IsNil)
+
+	prepare := ts.Tasks()[1]
+	c.Check(prepare.Kind(), Equals, "prepare-snap")
+	te, err := ts.Edge(snapstate.DownloadAndChecksDoneEdge)
+	c.Assert(err, IsNil)
+	c.Check(te, Equals, prepare)
+
+	snapsup, err := snapstate.TaskSnapSetup(prepare)
+	c.Assert(err, IsNil)
+	c.Check(snapsup.SnapPath, Equals, mockSnap)
+	c.Check(snapsup.Channel, Equals, "some-channel")
+	c.Check(snapsup.SideInfo, DeepEquals, si)
+}
+
+func (s *snapmgrTestSuite) TestInstallPathNoDownloadAndChecksDoneEdge(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	mockSnap := makeTestSnap(c, "name: some-snap\nversion: 1.0")
+	ts, _, err := snapstate.InstallPath(s.state, &snap.SideInfo{RealName: "some-snap"}, mockSnap, "", "", snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	// only the remodel waits on local snaps being prepared
+	_, err = ts.Edge(snapstate.DownloadAndChecksDoneEdge)
+	c.Check(err, ErrorMatches, `internal error: missing "download-and-checks-done" edge in task set`)
+}
+
+func (s *snapmgrTestSuite) TestInstallPathWithDeviceContextConflictIgnoringChange(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	deviceCtx := &snapstatetest.TrivialDeviceContext{DeviceModel: DefaultModel(), CtxStore: s.fakeStore}
+
+	ts, err := snapstate.Install(context.Background(), s.state, "some-snap", nil, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("install", "...")
+	chg.AddAll(ts)
+
+	mockSnap := makeTestSnap(c, "name: some-snap\nversion: 1.0")
+	si := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(8)}
+	_, err = snapstate.InstallPathWithDeviceContext(s.state, si, mockSnap, "some-snap", nil, 0, snapstate.Flags{}, deviceCtx, "")
+	c.Assert(err, ErrorMatches, `snap "some-snap" has "install" change in progress`)
+
+	_, err = snapstate.InstallPathWithDeviceContext(s.state, si, mockSnap, "some-snap", nil, 0, snapstate.Flags{}, deviceCtx, chg.ID())
+	c.Assert(err, IsNil)
+}
+
 func (s *snapmgrTestSuite) TestInstallPathMissingName(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -884,6 +945,64 @@ func (s *snapmgrTestSuite) TestInstallPathFailsEarlyOnEpochMismatch(c *C) {
 	c.Assert(err, ErrorMatches, `cannot refresh "some-snap" to local snap with epoch 42, because it can't read the current epoch of 1\*`)
 }
 
+func (s *snapmgrTestSuite) TestApplyDelta(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)},
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(8)},
+		},
+		Current: snap.R(8),
+	})
+
+	var calls [][]string
+	restore := snapstate.MockSnapdeltaApply(func(sourcePath, deltaPath, targetPath string) error {
+		// the state is not held while the delta is applied, this
+		// would deadlock otherwise
+		s.state.Lock()
+		s.state.Unlock()
+		calls = append(calls, []string{sourcePath, deltaPath, targetPath})
+		return nil
+	})
+	defer restore()
+
+	err := snapstate.ApplyDelta(s.state, "some-snap", snap.Revision{}, "/path/to/delta", "/path/to/target")
+	c.Assert(err, IsNil)
+	err = snapstate.ApplyDelta(s.state, "some-snap", snap.R(7), "/path/to/delta", "/path/to/target")
+	c.Assert(err, IsNil)
+
+	c.Check(calls, DeepEquals, [][]string{
+		{filepath.Join(dirs.SnapBlobDir, "some-snap_8.snap"), "/path/to/delta", "/path/to/target"},
+		{filepath.Join(dirs.SnapBlobDir, "some-snap_7.snap"), "/path/to/delta", "/path/to/target"},
+	})
+}
+
+func (s *snapmgrTestSuite) TestApplyDeltaNotInstalled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := snapstate.MockSnapdeltaApply(func(sourcePath, deltaPath, targetPath string) error {
+		c.Fatalf("unexpected call")
+		return nil
+	})
+	defer restore()
+
+	err := snapstate.ApplyDelta(s.state, "some-snap", snap.Revision{}, "/path/to/delta", "/path/to/target")
+	c.Assert(err, ErrorMatches, `snap "some-snap" is not installed`)
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+	})
+
+	err = snapstate.ApplyDelta(s.state, "some-snap", snap.R(6), "/path/to/delta", "/path/to/target")
+	c.Assert(err, ErrorMatches, `revision 6 of snap "some-snap" is not installed`)
+}
+
 func (s *snapmgrTestSuite) TestInstallRunThrough(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
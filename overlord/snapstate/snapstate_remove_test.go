@@ -21,6 +21,7 @@ package snapstate_test
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"time"
@@ -229,6 +230,9 @@ func (s *snapmgrTestSuite) TestRemoveDiskSpaceForSnapshotError(c *C) {
 func (s *snapmgrTestSuite) TestRemoveRunThrough(c *C) {
 	c.Assert(snapstate.KeepAuxStoreInfo("some-snap-id", nil), IsNil)
 	c.Check(snapstate.AuxStoreInfoFilename("some-snap-id"), testutil.FilePresent)
+	envFile := filepath.Join(dirs.SnapAppEnvDir, "snap.some-snap.app.env")
+	c.Assert(os.MkdirAll(dirs.SnapAppEnvDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(envFile, []byte("FOO=bar\n"), 0644), IsNil)
 	si := snap.SideInfo{
 		SnapID:   "some-snap-id",
 		RealName: "some-snap",
@@ -361,6 +365,7 @@ func (s *snapmgrTestSuite) TestRemoveRunThrough(c *C) {
 	err = snapstate.Get(s.state, "some-snap", &snapst)
 	c.Assert(err, Equals, state.ErrNoState)
 	c.Check(snapstate.AuxStoreInfoFilename("some-snap-id"), testutil.FileAbsent)
+	c.Check(envFile, testutil.FileAbsent)
 
 }
 
@@ -5324,6 +5324,24 @@ func (s *snapmgrTestSuite) TestConflictCreateRecovery(c *C) {
 	c.Check(err, ErrorMatches, `creating recovery system in progress, no other changes allowed until this is done`)
 }
 
+func (s *snapmgrTestSuite) TestConflictRemoveRecovery(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("remove-recovery-system", "...")
+	chg.SetStatus(state.DoingStatus)
+
+	err := snapstate.CheckChangeConflictMany(s.state, []string{"a-snap"}, "")
+	c.Check(err, FitsTypeOf, &snapstate.ChangeConflictError{})
+	c.Check(err, ErrorMatches, `removing recovery system in progress, no other changes allowed until this is done`)
+
+	// so do changes that modify recovery systems
+	for _, kind := range []string{"remodel", "create-recovery-system", "remove-recovery-system"} {
+		err = snapstate.CheckChangeConflictRunExclusively(s.state, kind)
+		c.Check(err, ErrorMatches, `removing recovery system in progress, no other changes allowed until this is done`)
+	}
+}
+
 func (s *snapmgrTestSuite) TestConflictExclusive(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
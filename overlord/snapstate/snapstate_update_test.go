@@ -23,6 +23,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
@@ -31,6 +32,9 @@ import (
 	. "gopkg.in/check.v1"
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/osutil"
@@ -39,6 +43,7 @@ import (
 	// So it registers Configure.
 	_ "github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
@@ -4483,6 +4488,199 @@ func (s *snapmgrTestSuite) TestUpdateMany(c *C) {
 	checkIsAutoRefresh(c, ts.Tasks(), false)
 }
 
+func (s *snapmgrTestSuite) TestPlanUpdateMany(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	restore := snapstate.MockInstallSizeInfo(func(st *state.State, snaps []snapstate.MinimalInstallInfo, userID int) (map[string]uint64, map[string]*snap.Info, error) {
+		c.Assert(snaps, HasLen, 1)
+		c.Check(snaps[0].InstanceName(), Equals, "some-snap")
+		prereq := &snap.Info{
+			SideInfo: snap.SideInfo{RealName: "some-base", Revision: snap.R(3), Channel: "stable"},
+			DownloadInfo: snap.DownloadInfo{
+				Size: 10,
+			},
+		}
+		return map[string]uint64{"some-base": 10}, map[string]*snap.Info{"some-base": prereq}, nil
+	})
+	defer restore()
+
+	plan, err := snapstate.PlanUpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(plan.Snaps, DeepEquals, []string{"some-snap"})
+	c.Check(plan.Downloads, DeepEquals, []snapstate.PlannedDownload{
+		{Snap: "some-snap", Revision: snap.R(11)},
+		{Snap: "some-base", Revision: snap.R(3), Channel: "stable", Size: 10, Prerequisite: true},
+	})
+	c.Check(plan.TotalDownloadSize, Equals, int64(10))
+	c.Check(plan.RebootRequired, Equals, false)
+	c.Check(plan.AffectedSnaps, IsNil)
+
+	// nothing was queued nor left behind in the state
+	c.Check(s.state.Changes(), HasLen, 0)
+	c.Check(s.state.Tasks(), HasLen, 0)
+
+	c.Assert(len(plan.Tasks) > 2, Equals, true)
+	first := plan.Tasks[0]
+	c.Check(first.Kind, Equals, "prerequisites")
+	c.Check(first.Snap, Equals, "some-snap")
+	c.Check(first.WaitTasks, HasLen, 0)
+	c.Check(plan.Tasks[1].WaitTasks, DeepEquals, []string{first.ID})
+	for _, t := range plan.Tasks[:len(plan.Tasks)-1] {
+		c.Check(t.Snap, Equals, "some-snap", Commentf("task %s", t.Kind))
+	}
+	last := plan.Tasks[len(plan.Tasks)-1]
+	c.Check(last.Kind, Equals, "check-rerefresh")
+	c.Check(last.Snap, Equals, "")
+}
+
+func (s *snapmgrTestSuite) TestPlanUpdateManyNothingToDo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(11)},
+		},
+		Current:  snap.R(11),
+		SnapType: "app",
+	})
+
+	plan, err := snapstate.PlanUpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(plan, DeepEquals, &snapstate.RefreshPlan{
+		Snaps:     []string{},
+		Downloads: []snapstate.PlannedDownload{},
+		Tasks:     []snapstate.PlannedTask{},
+	})
+}
+
+func (s *snapmgrTestSuite) TestPlanUpdateManyRebootRequired(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "core", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "core", SnapID: "core-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "os",
+	})
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+	snaptest.MockSnap(c, "name: core\ntype: os\nversion: 1", &snap.SideInfo{Revision: snap.R(1)})
+
+	restore := snapstate.MockInstallSizeInfo(func(st *state.State, snaps []snapstate.MinimalInstallInfo, userID int) (map[string]uint64, map[string]*snap.Info, error) {
+		return nil, nil, nil
+	})
+	defer restore()
+
+	plan, err := snapstate.PlanUpdateMany(context.Background(), s.state, []string{"some-snap"}, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(plan.Snaps, DeepEquals, []string{"some-snap"})
+	c.Check(plan.RebootRequired, Equals, false)
+
+	// refreshing the boot base requires a reboot
+	plan, err = snapstate.PlanUpdateMany(context.Background(), s.state, []string{"core"}, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(plan.Snaps, DeepEquals, []string{"core"})
+	c.Check(plan.RebootRequired, Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestPlanUpdateManyAffectedSnaps(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+	// the gate-auto-refresh hook is looked up on disk
+	restore = snapstate.MockSnapReadInfo(snap.ReadInfo)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-base", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-base", SnapID: "some-base-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "base",
+	})
+	snaptest.MockSnap(c, "name: some-base\ntype: base\nversion: 1", &snap.SideInfo{Revision: snap.R(1)})
+
+	snapstate.Set(s.state, "other-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "other-snap", SnapID: "other-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+	otherInfo := snaptest.MockSnap(c, "name: other-snap\nversion: 1\nbase: some-base", &snap.SideInfo{Revision: snap.R(1)})
+	c.Assert(os.MkdirAll(otherInfo.HooksDir(), 0775), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(otherInfo.HooksDir(), "gate-auto-refresh"), nil, 0755), IsNil)
+
+	restore = snapstate.MockInstallSizeInfo(func(st *state.State, snaps []snapstate.MinimalInstallInfo, userID int) (map[string]uint64, map[string]*snap.Info, error) {
+		return nil, nil, nil
+	})
+	defer restore()
+
+	plan, err := snapstate.PlanUpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(plan.Snaps, DeepEquals, []string{"some-base"})
+	c.Check(plan.AffectedSnaps, DeepEquals, map[string][]string{
+		"other-snap": {"some-base"},
+	})
+}
+
+func (s *snapmgrTestSuite) TestPlanUpdateManyDiskSpaceCheck(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "experimental.check-disk-space-refresh", true)
+	tr.Commit()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	restore := snapstate.MockInstallSizeInfo(func(st *state.State, snaps []snapstate.MinimalInstallInfo, userID int) (map[string]uint64, map[string]*snap.Info, error) {
+		return map[string]uint64{"some-snap": 100}, nil, nil
+	})
+	defer restore()
+	restore = snapstate.MockOsutilCheckFreeSpace(func(path string, sz uint64) error {
+		c.Check(sz, Equals, snapstate.SafetyMarginDiskSpace(100))
+		return &osutil.NotEnoughDiskSpaceError{}
+	})
+	defer restore()
+
+	_, err := snapstate.PlanUpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Check(err, ErrorMatches, `insufficient space in .* to perform "refresh" change for the following snaps: some-snap`)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
 func (s *snapmgrTestSuite) TestUpdateManyFailureDoesntUndoSnapdRefresh(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -4899,6 +5097,372 @@ func (s *snapmgrTestSuite) TestUpdateManyValidateRefreshes(c *C) {
 	c.Check(validateCalled, Equals, true)
 }
 
+func (s *snapmgrTestSuite) TestUpdateManyRefreshPolicyChannel(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := MockRefreshPolicy(MakeRefreshPolicy(
+		map[string]interface{}{
+			"name":    "some-snap",
+			"id":      "somesnapidididididididididididid",
+			"channel": "some-channel",
+		},
+	))
+	defer restore()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		TrackingChannel: "latest/stable",
+		Current:         snap.R(1),
+		SnapType:        "app",
+	})
+
+	updates, tts, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 2)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+
+	// the store was asked for the channel of the refresh-policy
+	var action *store.SnapAction
+	for _, op := range s.fakeBackend.ops {
+		if op.op == "storesvc-snap-action:action" {
+			action = &op.action
+		}
+	}
+	c.Assert(action, NotNil)
+	c.Check(action.Channel, Equals, "some-channel")
+
+	// and the snap is switched to it
+	snapsup, err := snapstate.TaskSnapSetup(tts[0].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Channel, Equals, "some-channel")
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyNamedIgnoresRefreshPolicyChannel(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := MockRefreshPolicy(MakeRefreshPolicy(
+		map[string]interface{}{
+			"name":    "some-snap",
+			"id":      "somesnapidididididididididididid",
+			"channel": "some-channel",
+		},
+	))
+	defer restore()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		TrackingChannel: "latest/stable",
+		Current:         snap.R(1),
+		SnapType:        "app",
+	})
+
+	updates, tts, err := snapstate.UpdateMany(context.Background(), s.state, []string{"some-snap"}, 0, nil)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 2)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+
+	snapsup, err := snapstate.TaskSnapSetup(tts[0].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Channel, Equals, "latest/stable")
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyAutoRefreshRefreshPolicyWindow(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := snapstate.MockTimeNow(func() time.Time {
+		return time.Date(2021, time.June, 7, 10, 0, 0, 0, time.Local)
+	})
+	defer restore()
+
+	restore = MockRefreshPolicy(MakeRefreshPolicy(
+		map[string]interface{}{
+			"name":           "some-snap",
+			"id":             "somesnapidididididididididididid",
+			"refresh-window": "02:00-04:00",
+		},
+	))
+	defer restore()
+
+	for _, name := range []string{"some-snap", "some-other-snap"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: []*snap.SideInfo{
+				{RealName: name, SnapID: name + "-id", Revision: snap.R(1)},
+			},
+			Current:  snap.R(1),
+			SnapType: "app",
+		})
+	}
+
+	// some-snap is outside of its refresh window
+	updates, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-other-snap"})
+
+	// but manual refreshes are not constrained
+	updates, _, err = snapstate.UpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	sort.Strings(updates)
+	c.Check(updates, DeepEquals, []string{"some-other-snap", "some-snap"})
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyAutoRefreshRefreshPolicyNoAutoRevert(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := MockRefreshPolicy(MakeRefreshPolicy(
+		map[string]interface{}{
+			"name":        "some-snap",
+			"id":          "somesnapidididididididididididid",
+			"auto-revert": "false",
+		},
+	))
+	defer restore()
+
+	for _, name := range []string{"some-snap", "some-other-snap"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: []*snap.SideInfo{
+				{RealName: name, SnapID: name + "-id", Revision: snap.R(1)},
+			},
+			Current:  snap.R(1),
+			SnapType: "app",
+		})
+	}
+
+	checkPostRefreshHook := func(tts []*state.TaskSet, expected map[string]bool) {
+		seen := make(map[string]bool)
+		for _, ts := range tts {
+			for _, t := range ts.Tasks() {
+				if t.Kind() != "run-hook" {
+					continue
+				}
+				var hs hookstate.HookSetup
+				c.Assert(t.Get("hook-setup", &hs), IsNil)
+				if hs.Hook != "post-refresh" {
+					continue
+				}
+				seen[hs.Snap] = hs.IgnoreError
+			}
+		}
+		c.Check(seen, DeepEquals, expected)
+	}
+
+	updates, tts, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 2)
+	// a failing post-refresh hook of some-snap does not revert it
+	checkPostRefreshHook(tts, map[string]bool{
+		"some-snap":       true,
+		"some-other-snap": false,
+	})
+
+	// manual refreshes are reverted as usual
+	_, tts, err = snapstate.UpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	checkPostRefreshHook(tts, map[string]bool{
+		"some-snap":       false,
+		"some-other-snap": false,
+	})
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyEnforcedValidationSets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := mockEnforcedValidationSets(c, map[string]interface{}{
+		"name":     "some-snap",
+		"id":       "somesnapidididididididididididid",
+		"presence": "required",
+		"revision": "5",
+	}, map[string]interface{}{
+		"name":     "some-other-snap",
+		"id":       "someothersnapidididididididididi",
+		"presence": "required",
+		"revision": "1",
+	})
+	defer restore()
+
+	for _, name := range []string{"some-snap", "some-other-snap"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: []*snap.SideInfo{
+				{RealName: name, SnapID: name + "-id", Revision: snap.R(1)},
+			},
+			TrackingChannel: "latest/stable",
+			Current:         snap.R(1),
+			SnapType:        "app",
+		})
+	}
+
+	// some-other-snap is already at the required revision
+	updates, tts, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 2)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+
+	// and some-snap is moved to the required one
+	var action *store.SnapAction
+	for _, op := range s.fakeBackend.ops {
+		if op.op == "storesvc-snap-action:action" {
+			action = &op.action
+		}
+	}
+	c.Assert(action, NotNil)
+	c.Check(action.InstanceName, Equals, "some-snap")
+	c.Check(action.Revision, Equals, snap.R(5))
+	c.Check(action.Channel, Equals, "")
+
+	snapsup, err := snapstate.TaskSnapSetup(tts[0].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Revision(), Equals, snap.R(5))
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyEnforcedValidationSetsConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	valsets := snapasserts.NewValidationSets()
+	for i, rev := range []string{"5", "6"} {
+		vs := assertstest.FakeAssertion(map[string]interface{}{
+			"type":         "validation-set",
+			"authority-id": "acme",
+			"series":       "16",
+			"account-id":   "acme",
+			"name":         fmt.Sprintf("set-%d", i),
+			"sequence":     "1",
+			"snaps": []interface{}{
+				map[string]interface{}{
+					"name":     "some-snap",
+					"id":       "somesnapidididididididididididid",
+					"presence": "required",
+					"revision": rev,
+				},
+			},
+		}).(*asserts.ValidationSet)
+		c.Assert(valsets.Add(vs), IsNil)
+	}
+	snapstate.EnforcedValidationSets = func(*state.State) (*snapasserts.ValidationSets, error) {
+		return valsets, nil
+	}
+	defer func() { snapstate.EnforcedValidationSets = nil }()
+
+	_, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, ErrorMatches, `validation sets are in conflict:\n- cannot constrain snap "some-snap" at different revisions 5 \(acme/set-0\), 6 \(acme/set-1\)`)
+}
+
+func (s *snapmgrTestSuite) TestEnforceSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-other-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-other-snap", SnapID: "some-other-snap-id", Revision: snap.R(1)},
+		},
+		TrackingChannel: "latest/stable",
+		Current:         snap.R(1),
+		SnapType:        "app",
+	})
+	snapstate.Set(s.state, "bad-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "bad-snap", SnapID: "bad-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	vs := assertstest.FakeAssertion(map[string]interface{}{
+		"type":         "validation-set",
+		"authority-id": "acme",
+		"series":       "16",
+		"account-id":   "acme",
+		"name":         "set",
+		"sequence":     "1",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":     "some-snap",
+				"id":       "somesnapidididididididididididid",
+				"presence": "required",
+				"revision": "11",
+			},
+			map[string]interface{}{
+				"name":     "some-other-snap",
+				"id":       "someothersnapidididididididididi",
+				"presence": "required",
+				"revision": "12",
+			},
+			map[string]interface{}{
+				"name":     "bad-snap",
+				"id":       "badsnapididididididididididididi",
+				"presence": "invalid",
+			},
+		},
+	}).(*asserts.ValidationSet)
+	valsets := snapasserts.NewValidationSets()
+	c.Assert(valsets.Add(vs), IsNil)
+
+	validErr := &snapasserts.ValidationSetsValidationError{
+		MissingSnaps: map[string][]string{"some-snap": {"acme/set"}},
+		InvalidSnaps: map[string][]string{"bad-snap": {"acme/set"}},
+		WrongRevisionSnaps: map[string]map[snap.Revision][]string{
+			"some-other-snap": {snap.R(12): {"acme/set"}},
+		},
+	}
+
+	tss, affected, err := snapstate.EnforceSnaps(context.Background(), s.state, valsets, validErr, 0)
+	c.Assert(err, IsNil)
+	c.Check(affected, DeepEquals, []string{"some-snap", "some-other-snap", "bad-snap"})
+	c.Assert(tss, HasLen, 3)
+
+	chg := s.state.NewChange("enforce", "...")
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+
+	// install of the missing snap at the required revision
+	snapsup, err := snapstate.TaskSnapSetup(tss[0].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.InstanceName(), Equals, "some-snap")
+	c.Check(snapsup.Revision(), Equals, snap.R(11))
+
+	// refresh to the required revision
+	snapsup, err = snapstate.TaskSnapSetup(tss[1].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.InstanceName(), Equals, "some-other-snap")
+	c.Check(snapsup.Revision(), Equals, snap.R(12))
+
+	// removal of the invalid snap
+	var removeSnapsup *snapstate.SnapSetup
+	for _, t := range tss[2].Tasks() {
+		if t.Kind() == "unlink-snap" {
+			removeSnapsup, err = snapstate.TaskSnapSetup(t)
+			c.Assert(err, IsNil)
+		}
+	}
+	c.Assert(removeSnapsup, NotNil)
+	c.Check(removeSnapsup.InstanceName(), Equals, "bad-snap")
+}
+
 func (s *snapmgrTestSuite) TestParallelInstanceUpdateMany(c *C) {
 	restore := release.MockOnClassic(false)
 	defer restore()
@@ -40,6 +40,19 @@ var currentSnaps = currentSnapsImpl
 // assertstate.
 var EnforcedValidationSets func(st *state.State) (*snapasserts.ValidationSets, error)
 
+// enforcedRevisions returns the revisions that snaps are required to be
+// at by the validation sets in enforce mode, keyed by snap name.
+func enforcedRevisions(st *state.State) (map[string]snap.Revision, error) {
+	if EnforcedValidationSets == nil {
+		return nil, nil
+	}
+	valsets, err := EnforcedValidationSets(st)
+	if err != nil {
+		return nil, err
+	}
+	return valsets.Revisions()
+}
+
 func userIDForSnap(st *state.State, snapst *SnapState, fallbackUserID int) (int, error) {
 	userID := snapst.UserID
 	_, err := auth.User(st, userID)
@@ -96,14 +109,32 @@ func refreshOptions(st *state.State, origOpts *store.RefreshOptions) (*store.Ref
 // download infos set.
 // The state must be locked by the caller.
 var installSize = func(st *state.State, snaps []minimalInstallInfo, userID int) (uint64, error) {
-	curSnaps, err := currentSnaps(st)
+	sizes, _, err := installSizeInfo(st, snaps, userID)
 	if err != nil {
 		return 0, err
 	}
 
+	var total uint64
+	for _, sz := range sizes {
+		total += sz
+	}
+
+	return total, nil
+}
+
+// installSizeInfo returns the download sizes of snaps and their prerequisites
+// that would actually need to be installed, keyed by instance name, together
+// with the store information of those prerequisites.
+// The state must be locked by the caller.
+var installSizeInfo = func(st *state.State, snaps []minimalInstallInfo, userID int) (map[string]uint64, map[string]*snap.Info, error) {
+	curSnaps, err := currentSnaps(st)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	user, err := userFromUserID(st, userID)
 	if err != nil {
-		return 0, err
+		return nil, nil, err
 	}
 
 	accountedSnaps := map[string]bool{}
@@ -136,9 +167,10 @@ var installSize = func(st *state.State, snaps []minimalInstallInfo, userID int)
 	}
 
 	snapSizes := map[string]uint64{}
+	prereqInfos := map[string]*snap.Info{}
 	for _, inst := range snaps {
 		if inst.DownloadSize() == 0 {
-			return 0, fmt.Errorf("internal error: download info missing for %q", inst.InstanceName())
+			return nil, nil, fmt.Errorf("internal error: download info missing for %q", inst.InstanceName())
 		}
 		snapSizes[inst.InstanceName()] = uint64(inst.DownloadSize())
 		resolveBaseAndContentProviders(inst)
@@ -146,7 +178,7 @@ var installSize = func(st *state.State, snaps []minimalInstallInfo, userID int)
 
 	opts, err := refreshOptions(st, nil)
 	if err != nil {
-		return 0, err
+		return nil, nil, err
 	}
 
 	theStore := Store(st, nil)
@@ -170,11 +202,12 @@ var installSize = func(st *state.State, snaps []minimalInstallInfo, userID int)
 		results, _, err := theStore.SnapAction(context.TODO(), curSnaps, actions, nil, user, opts)
 		st.Lock()
 		if err != nil {
-			return 0, err
+			return nil, nil, err
 		}
 		prereqs = []string{}
 		for _, res := range results {
 			snapSizes[res.InstanceName()] = uint64(res.Size)
+			prereqInfos[res.InstanceName()] = res.Info
 			// results may have new base or content providers
 			resolveBaseAndContentProviders(installSnapInfo{res.Info})
 		}
@@ -187,18 +220,14 @@ var installSize = func(st *state.State, snaps []minimalInstallInfo, userID int)
 	// size of snaps that would actually need to be installed.
 	curSnaps, err = currentSnaps(st)
 	if err != nil {
-		return 0, err
+		return nil, nil, err
 	}
 	for _, snap := range curSnaps {
 		delete(snapSizes, snap.InstanceName)
+		delete(prereqInfos, snap.InstanceName)
 	}
 
-	var total uint64
-	for _, sz := range snapSizes {
-		total += sz
-	}
-
-	return total, nil
+	return snapSizes, prereqInfos, nil
 }
 
 func installInfo(ctx context.Context, st *state.State, name string, revOpts *RevisionOptions, userID int, deviceCtx DeviceContext) (store.SnapActionResult, error) {
@@ -450,6 +479,17 @@ func refreshCandidates(ctx context.Context, st *state.State, names []string, use
 		return nil, nil, nil, err
 	}
 
+	policy, err := refreshPolicy(st)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	now := timeNow()
+
+	enforced, err := enforcedRevisions(st)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	// check if we have this name at all
 	for _, name := range names {
 		if _, ok := snapStates[name]; !ok {
@@ -488,6 +528,18 @@ func refreshCandidates(ctx context.Context, st *state.State, names []string, use
 			return
 		}
 
+		if polSnap := refreshPolicySnap(policy, installed.InstanceName); opts.IsAutoRefresh && polSnap != nil && !polSnap.InRefreshWindow(now) {
+			logger.Debugf("not auto-refreshing snap %q outside of its refresh window", installed.InstanceName)
+			return
+		}
+
+		snapName, _ := snap.SplitInstanceName(installed.InstanceName)
+		enforcedRev, isEnforced := enforced[snapName]
+		if isEnforced && enforcedRev == installed.Revision {
+			logger.Debugf("not refreshing snap %q held at revision %s by enforced validation sets", installed.InstanceName, enforcedRev)
+			return
+		}
+
 		stateByInstanceName[installed.InstanceName] = snapst
 
 		if len(names) == 0 {
@@ -498,11 +550,25 @@ func refreshCandidates(ctx context.Context, st *state.State, names []string, use
 		if userID == 0 {
 			userID = fallbackID
 		}
-		actionsByUserID[userID] = append(actionsByUserID[userID], &store.SnapAction{
+		action := &store.SnapAction{
 			Action:       "refresh",
 			SnapID:       installed.SnapID,
 			InstanceName: installed.InstanceName,
-		})
+		}
+		if len(names) == 0 {
+			// refreshing all snaps moves them to the channel
+			// set by the refresh-policy, if any
+			if ch := refreshPolicyChannel(policy, installed.InstanceName, snapst); ch != snapst.TrackingChannel {
+				action.Channel = ch
+			}
+		}
+		if isEnforced {
+			// move to the revision required by enforced
+			// validation sets instead
+			action.Channel = ""
+			action.Revision = enforcedRev
+		}
+		actionsByUserID[userID] = append(actionsByUserID[userID], action)
 		if snapst.IgnoreValidation {
 			ignoreValidationByInstanceName[installed.InstanceName] = true
 		}
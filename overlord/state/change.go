@@ -149,6 +149,11 @@ func newChange(state *State, id, kind, summary string) *Change {
 	}
 }
 
+func (c *Change) writing() {
+	c.state.writing()
+	c.state.entries.changeModified(c.id)
+}
+
 type marshalledChange struct {
 	ID      string                      `json:"id"`
 	Kind    string                      `json:"kind"`
@@ -240,7 +245,7 @@ func (c *Change) Summary() string {
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (c *Change) Set(key string, value interface{}) {
-	c.state.writing()
+	c.writing()
 	c.data.set(key, value)
 }
 
@@ -300,7 +305,7 @@ func (c *Change) Status() Status {
 
 // SetStatus sets the change status, overriding the default behavior (see Status method).
 func (c *Change) SetStatus(s Status) {
-	c.state.writing()
+	c.writing()
 	c.status = s
 	if s.Ready() {
 		c.markReady()
@@ -454,18 +459,19 @@ func (c *Change) State() *State {
 // AddTask registers a task as required for the state change to
 // be accomplished.
 func (c *Change) AddTask(t *Task) {
-	c.state.writing()
+	c.writing()
 	if t.change != "" {
 		panic(fmt.Sprintf("internal error: cannot add one %q task to multiple changes", t.Kind()))
 	}
 	t.change = c.id
 	c.taskIDs = addOnce(c.taskIDs, t.ID())
+	c.state.entries.taskModified(t)
 }
 
 // AddAll registers all tasks in the set as required for the state
 // change to be accomplished.
 func (c *Change) AddAll(ts *TaskSet) {
-	c.state.writing()
+	c.writing()
 	for _, t := range ts.tasks {
 		c.AddTask(t)
 	}
@@ -504,7 +510,7 @@ func (c *Change) LaneTasks(lanes ...int) []*Task {
 // Abort flags the change for cancellation, whether in progress or not.
 // Cancellation will proceed at the next ensure pass.
 func (c *Change) Abort() {
-	c.state.writing()
+	c.writing()
 	tasks := make([]*Task, len(c.taskIDs))
 	for i, tid := range c.taskIDs {
 		tasks[i] = c.state.tasks[tid]
@@ -516,7 +522,7 @@ func (c *Change) Abort() {
 // except for tasks that are also in a healthy lane (not aborted, and not waiting
 // on aborted).
 func (c *Change) AbortLanes(lanes []int) {
-	c.state.writing()
+	c.writing()
 	c.abortLanes(lanes, make(map[int]bool), make(map[string]bool))
 }
 
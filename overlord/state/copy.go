@@ -110,15 +110,15 @@ func CopyState(srcStatePath, dstStatePath string, dataEntries []string) error {
 		return fmt.Errorf("cannot copy state: must provide at least one data entry to copy")
 	}
 
-	f, err := os.Open(srcStatePath)
+	// the source state may have been persisted with a journal
+	data, err := ReadStateData(srcStatePath)
 	if err != nil {
 		return fmt.Errorf("cannot open state: %s", err)
 	}
-	defer f.Close()
 
 	// No need to lock/unlock the state here, srcState should not be
 	// in use at all.
-	srcState, err := ReadState(nil, f)
+	srcState, err := ReadState(nil, bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
@@ -142,3 +142,21 @@ func CopyState(srcStatePath, dstStatePath string, dataEntries []string) error {
 
 	return nil
 }
+
+// Copy returns an unlocked copy of the state that is not backed by any
+// backend, so that nothing done to it is ever persisted. The cached
+// values of the state are carried over. It is meant for computing what
+// operations would do, including creating their tasks, without modifying
+// the state. The state must be locked by the caller.
+func (s *State) Copy() *State {
+	s.reading()
+	cpy, err := ReadState(nil, bytes.NewReader(s.checkpointData()))
+	if err != nil {
+		// this shouldn't happen, the state was just marshalled
+		panic(fmt.Sprintf("internal error: cannot copy state: %v", err))
+	}
+	for k, v := range s.cache {
+		cpy.cache[k] = v
+	}
+	return cpy
+}
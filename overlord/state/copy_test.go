@@ -144,3 +144,37 @@ func (ss *stateSuite) TestCopyStateDuplicatesInDataEntriesAreFine(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(string(dstContent), Equals, `{"data":{"E":{"F":2,"G":3}}`+stateSuffix)
 }
+
+func (ss *stateSuite) TestCopy(c *C) {
+	b := new(fakeStateBackend)
+	st := state.New(b)
+	st.Lock()
+	defer st.Unlock()
+
+	st.Set("foo", "bar")
+	chg := st.NewChange("change", "...")
+	chg.AddTask(st.NewTask("task", "..."))
+	type cacheKey struct{}
+	st.Cache(cacheKey{}, "cached")
+
+	cpy := st.Copy()
+	cpy.Lock()
+	var foo string
+	c.Check(cpy.Get("foo", &foo), IsNil)
+	c.Check(foo, Equals, "bar")
+	c.Check(cpy.Changes(), HasLen, 1)
+	c.Check(cpy.Cached(cacheKey{}), Equals, "cached")
+
+	cpy.Set("foo", "baz")
+	t := cpy.NewTask("other-task", "...")
+	cpy.Cache(cacheKey{}, "changed")
+	cpy.Unlock()
+
+	// the original state is not affected and the copy is never persisted
+	c.Check(b.checkpoints, HasLen, 0)
+	c.Check(st.Get("foo", &foo), IsNil)
+	c.Check(foo, Equals, "bar")
+	c.Check(st.Task(t.ID()), IsNil)
+	c.Check(st.Tasks(), HasLen, 1)
+	c.Check(st.Cached(cacheKey{}), Equals, "cached")
+}
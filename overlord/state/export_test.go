@@ -73,3 +73,11 @@ var (
 	ErrNoWarningExpireAfter = errNoWarningExpireAfter
 	ErrNoWarningRepeatAfter = errNoWarningRepeatAfter
 )
+
+func MockJournalMinCompactSize(size int64) (restore func()) {
+	old := journalMinCompactSize
+	journalMinCompactSize = size
+	return func() {
+		journalMinCompactSize = old
+	}
+}
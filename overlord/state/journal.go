@@ -0,0 +1,621 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// EntriesBackend is implemented by backends that persist the state
+// incrementally. The state is then handed over to the backend split into
+// entries, keyed by top-level data key, change id and task id, instead of
+// serialized as a whole, and only the entries modified since the last
+// checkpoint are handed over.
+type EntriesBackend interface {
+	Backend
+	// CheckpointEntries persists the given update of the entries of
+	// the state.
+	CheckpointEntries(delta *EntriesDelta) error
+}
+
+// EntriesDelta is an update of the entries of the state.
+type EntriesDelta struct {
+	// Full is set if Set holds all the entries of the state, replacing
+	// any previous ones.
+	Full bool
+	// Set holds the entries that were added or modified.
+	Set map[string][]byte
+	// Del holds the keys of the entries that were removed.
+	Del []string
+}
+
+const (
+	dataEntryPrefix   = "data/"
+	changeEntryPrefix = "changes/"
+	taskEntryPrefix   = "tasks/"
+	warningsEntry     = "warnings"
+	lastChangeIdEntry = "last-change-id"
+	lastTaskIdEntry   = "last-task-id"
+	lastLaneIdEntry   = "last-lane-id"
+)
+
+func mustMarshal(what string, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// this shouldn't happen, because the actual delicate serializing happens at various Set()s
+		logger.Panicf("internal error: could not marshal %s for checkpointing: %v", what, err)
+	}
+	return data
+}
+
+// entriesTracker records the entries of the state modified since the last
+// checkpoint so that only those need to be marshalled and handed over to
+// an EntriesBackend. A nil entriesTracker, used with other backends,
+// records nothing.
+type entriesTracker struct {
+	all      bool
+	data     map[string]bool
+	changes  map[string]bool
+	tasks    map[string]bool
+	warnings bool
+}
+
+func newEntriesTracker(backend Backend, all bool) *entriesTracker {
+	if _, ok := backend.(EntriesBackend); !ok {
+		return nil
+	}
+	return &entriesTracker{
+		all:     all,
+		data:    make(map[string]bool),
+		changes: make(map[string]bool),
+		tasks:   make(map[string]bool),
+	}
+}
+
+func (tr *entriesTracker) dataModified(key string) {
+	if tr != nil {
+		tr.data[key] = true
+	}
+}
+
+func (tr *entriesTracker) changeModified(id string) {
+	if tr != nil {
+		tr.changes[id] = true
+	}
+}
+
+func (tr *entriesTracker) taskModified(t *Task) {
+	if tr != nil {
+		tr.tasks[t.id] = true
+		// the status and clean flag of the change follow its tasks
+		if t.change != "" {
+			tr.changes[t.change] = true
+		}
+	}
+}
+
+func (tr *entriesTracker) warningsModified() {
+	if tr != nil {
+		tr.warnings = true
+	}
+}
+
+func (s *State) warningsEntry() []byte {
+	warnings := s.flattenWarnings()
+	if len(warnings) == 0 {
+		return nil
+	}
+	// keep the entry stable across checkpoints
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[i].message < warnings[j].message
+	})
+	return mustMarshal("warnings", warnings)
+}
+
+// checkpointEntries returns the update of the entries of the state since
+// the last checkpoint, see JoinEntries for the whole set of entries.
+func (s *State) checkpointEntries() *EntriesDelta {
+	s.reading()
+	tr := s.entries
+	delta := &EntriesDelta{Full: tr.all}
+	if tr.all {
+		delta.Set = make(map[string][]byte, len(s.data)+len(s.changes)+len(s.tasks)+4)
+		for k, v := range s.data {
+			delta.Set[dataEntryPrefix+k] = []byte(*v)
+		}
+		for id, chg := range s.changes {
+			delta.Set[changeEntryPrefix+id] = mustMarshal("change", chg)
+		}
+		for id, t := range s.tasks {
+			delta.Set[taskEntryPrefix+id] = mustMarshal("task", t)
+		}
+		if warnings := s.warningsEntry(); warnings != nil {
+			delta.Set[warningsEntry] = warnings
+		}
+	} else {
+		delta.Set = make(map[string][]byte, len(tr.data)+len(tr.changes)+len(tr.tasks)+4)
+		for k := range tr.data {
+			if v := s.data[k]; v != nil {
+				delta.Set[dataEntryPrefix+k] = []byte(*v)
+			} else {
+				delta.Del = append(delta.Del, dataEntryPrefix+k)
+			}
+		}
+		for id := range tr.changes {
+			if chg := s.changes[id]; chg != nil {
+				delta.Set[changeEntryPrefix+id] = mustMarshal("change", chg)
+			} else {
+				delta.Del = append(delta.Del, changeEntryPrefix+id)
+			}
+		}
+		for id := range tr.tasks {
+			if t := s.tasks[id]; t != nil {
+				delta.Set[taskEntryPrefix+id] = mustMarshal("task", t)
+			} else {
+				delta.Del = append(delta.Del, taskEntryPrefix+id)
+			}
+		}
+		if tr.warnings {
+			if warnings := s.warningsEntry(); warnings != nil {
+				delta.Set[warningsEntry] = warnings
+			} else {
+				delta.Del = append(delta.Del, warningsEntry)
+			}
+		}
+		sort.Strings(delta.Del)
+	}
+	delta.Set[lastChangeIdEntry] = []byte(strconv.Itoa(s.lastChangeId))
+	delta.Set[lastTaskIdEntry] = []byte(strconv.Itoa(s.lastTaskId))
+	delta.Set[lastLaneIdEntry] = []byte(strconv.Itoa(s.lastLaneId))
+
+	s.entries = newEntriesTracker(s.backend, false)
+	return delta
+}
+
+type rawState struct {
+	Data     map[string]*json.RawMessage `json:"data"`
+	Changes  map[string]*json.RawMessage `json:"changes"`
+	Tasks    map[string]*json.RawMessage `json:"tasks"`
+	Warnings *json.RawMessage            `json:"warnings,omitempty"`
+
+	LastChangeId *json.RawMessage `json:"last-change-id"`
+	LastTaskId   *json.RawMessage `json:"last-task-id"`
+	LastLaneId   *json.RawMessage `json:"last-lane-id"`
+}
+
+// SplitEntries splits the serialized state into entries, as handed over
+// to an EntriesBackend.
+func SplitEntries(data []byte) (map[string][]byte, error) {
+	var raw rawState
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("cannot split state into entries: %v", err)
+	}
+	entries := make(map[string][]byte, len(raw.Data)+len(raw.Changes)+len(raw.Tasks)+4)
+	for prefix, m := range map[string]map[string]*json.RawMessage{
+		dataEntryPrefix:   raw.Data,
+		changeEntryPrefix: raw.Changes,
+		taskEntryPrefix:   raw.Tasks,
+	} {
+		for k, v := range m {
+			if v != nil {
+				entries[prefix+k] = []byte(*v)
+			}
+		}
+	}
+	for k, v := range map[string]*json.RawMessage{
+		warningsEntry:     raw.Warnings,
+		lastChangeIdEntry: raw.LastChangeId,
+		lastTaskIdEntry:   raw.LastTaskId,
+		lastLaneIdEntry:   raw.LastLaneId,
+	} {
+		if v != nil {
+			entries[k] = []byte(*v)
+		}
+	}
+	return entries, nil
+}
+
+// JoinEntries serializes the state made up of the given entries, the
+// inverse of SplitEntries.
+func JoinEntries(entries map[string][]byte) ([]byte, error) {
+	raw := rawState{
+		Data:    make(map[string]*json.RawMessage),
+		Changes: make(map[string]*json.RawMessage),
+		Tasks:   make(map[string]*json.RawMessage),
+	}
+	for k, v := range entries {
+		msg := json.RawMessage(v)
+		switch {
+		case strings.HasPrefix(k, dataEntryPrefix):
+			raw.Data[k[len(dataEntryPrefix):]] = &msg
+		case strings.HasPrefix(k, changeEntryPrefix):
+			raw.Changes[k[len(changeEntryPrefix):]] = &msg
+		case strings.HasPrefix(k, taskEntryPrefix):
+			raw.Tasks[k[len(taskEntryPrefix):]] = &msg
+		case k == warningsEntry:
+			raw.Warnings = &msg
+		case k == lastChangeIdEntry:
+			raw.LastChangeId = &msg
+		case k == lastTaskIdEntry:
+			raw.LastTaskId = &msg
+		case k == lastLaneIdEntry:
+			raw.LastLaneId = &msg
+		default:
+			return nil, fmt.Errorf("cannot join state entries: unknown entry %q", k)
+		}
+	}
+	zero := json.RawMessage("0")
+	for _, id := range []**json.RawMessage{&raw.LastChangeId, &raw.LastTaskId, &raw.LastLaneId} {
+		if *id == nil {
+			*id = &zero
+		}
+	}
+	return json.Marshal(raw)
+}
+
+// JournalPath returns the path of the journal kept next to the given state
+// file by Journal.
+func JournalPath(statePath string) string {
+	return statePath + ".journal"
+}
+
+// the journal is compacted into the state file once it grows larger than
+// the state file itself and than journalMinCompactSize
+var journalMinCompactSize int64 = 1024 * 1024
+
+// journalRecord is a set of entries updates committed atomically.
+type journalRecord struct {
+	Set map[string]*json.RawMessage `json:"set,omitempty"`
+	Del []string                    `json:"del,omitempty"`
+}
+
+// Each record is framed by its length and its checksum so that a record
+// partially written because of a crash is detected and ignored.
+const journalRecordHeaderSize = 8
+
+var errJournalRecordTruncated = errors.New("truncated journal record")
+
+func readJournalRecord(r io.Reader) (*journalRecord, int64, error) {
+	var header [journalRecordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, errJournalRecordTruncated
+	}
+	size := binary.LittleEndian.Uint32(header[0:4])
+	sum := binary.LittleEndian.Uint32(header[4:8])
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, errJournalRecordTruncated
+	}
+	if crc32.ChecksumIEEE(payload) != sum {
+		return nil, 0, errJournalRecordTruncated
+	}
+	var rec journalRecord
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return nil, 0, fmt.Errorf("cannot decode journal record: %v", err)
+	}
+	return &rec, int64(journalRecordHeaderSize + size), nil
+}
+
+func encodeJournalRecord(rec *journalRecord) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, journalRecordHeaderSize, journalRecordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	return append(buf, payload...), nil
+}
+
+// replayJournal applies the complete records read from r to entries and
+// returns the size of the valid part of the journal.
+func replayJournal(r io.Reader, entries map[string][]byte) (int64, error) {
+	var valid int64
+	for {
+		rec, n, err := readJournalRecord(r)
+		if err == io.EOF || err == errJournalRecordTruncated {
+			// anything after the last complete record was not
+			// committed
+			return valid, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		for k, v := range rec.Set {
+			entries[k] = []byte(*v)
+		}
+		for _, k := range rec.Del {
+			delete(entries, k)
+		}
+		valid += n
+	}
+}
+
+// readEntries reads the entries of the state stored in the state file at
+// statePath and its journal. It returns whether the state file exists and
+// the size of the valid part of the journal.
+func readEntries(statePath string) (entries map[string][]byte, stateSize int64, haveState bool, journalSize int64, err error) {
+	entries = make(map[string][]byte)
+	data, err := ioutil.ReadFile(statePath)
+	switch {
+	case err == nil:
+		haveState = true
+		stateSize = int64(len(data))
+		entries, err = SplitEntries(data)
+		if err != nil {
+			return nil, 0, false, 0, err
+		}
+	case !os.IsNotExist(err):
+		return nil, 0, false, 0, err
+	}
+
+	f, err := os.Open(JournalPath(statePath))
+	if os.IsNotExist(err) {
+		return entries, stateSize, haveState, 0, nil
+	}
+	if err != nil {
+		return nil, 0, false, 0, err
+	}
+	defer f.Close()
+	journalSize, err = replayJournal(f, entries)
+	if err != nil {
+		return nil, 0, false, 0, fmt.Errorf("cannot replay state journal: %v", err)
+	}
+	return entries, stateSize, haveState, journalSize, nil
+}
+
+// ReadStateData returns the serialized state stored in the state file at
+// statePath, with the updates from its journal, if any, applied.
+// It returns an error satisfying os.IsNotExist if neither exist.
+func ReadStateData(statePath string) ([]byte, error) {
+	if !osutil.FileExists(JournalPath(statePath)) {
+		return ioutil.ReadFile(statePath)
+	}
+	entries, _, _, _, err := readEntries(statePath)
+	if err != nil {
+		return nil, err
+	}
+	return JoinEntries(entries)
+}
+
+// Journal persists the state incrementally: the state file holds a full
+// serialized snapshot of the state, compatible with the one written by a
+// plain Backend, while the entries modified since then are appended to a
+// journal next to it. The journal is folded back into the state file when
+// it grows too large.
+type Journal struct {
+	statePath string
+	f         *os.File
+
+	entries     map[string][]byte
+	haveState   bool
+	stateSize   int64
+	journalSize int64
+}
+
+// OpenJournal opens the journal of the state file at statePath, creating
+// it if needed, and loads the state entries from both.
+func OpenJournal(statePath string) (*Journal, error) {
+	entries, stateSize, haveState, journalSize, err := readEntries(statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(JournalPath(statePath), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open state journal: %v", err)
+	}
+	// drop any record left incomplete by a crash
+	if err := f.Truncate(journalSize); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot open state journal: %v", err)
+	}
+
+	return &Journal{
+		statePath:   statePath,
+		f:           f,
+		entries:     entries,
+		haveState:   haveState,
+		stateSize:   stateSize,
+		journalSize: journalSize,
+	}, nil
+}
+
+// Data returns the serialized state, or nil if there is no state yet.
+func (j *Journal) Data() ([]byte, error) {
+	if !j.haveState && len(j.entries) == 0 {
+		return nil, nil
+	}
+	return JoinEntries(j.entries)
+}
+
+// Commit persists the given entries, making up the whole state, by
+// appending the ones which changed since the last commit to the journal.
+// The journal is compacted if it grew too large.
+func (j *Journal) Commit(entries map[string][]byte) error {
+	if !j.haveState {
+		// always have a full state file around
+		j.entries = entries
+		return j.Compact()
+	}
+
+	rec := &journalRecord{Set: make(map[string]*json.RawMessage)}
+	for k, v := range entries {
+		j.recordSet(rec, k, v)
+	}
+	for k := range j.entries {
+		if _, ok := entries[k]; !ok {
+			rec.Del = append(rec.Del, k)
+		}
+	}
+	sort.Strings(rec.Del)
+	return j.commit(rec)
+}
+
+// CommitEntries persists the given update of the entries of the state by
+// appending the entries it actually changes to the journal. The journal is
+// compacted if it grew too large.
+func (j *Journal) CommitEntries(delta *EntriesDelta) error {
+	if delta.Full {
+		return j.Commit(delta.Set)
+	}
+
+	rec := &journalRecord{Set: make(map[string]*json.RawMessage)}
+	for k, v := range delta.Set {
+		j.recordSet(rec, k, v)
+	}
+	for _, k := range delta.Del {
+		if _, ok := j.entries[k]; ok {
+			rec.Del = append(rec.Del, k)
+		}
+	}
+	return j.commit(rec)
+}
+
+func (j *Journal) recordSet(rec *journalRecord, k string, v []byte) {
+	if old, ok := j.entries[k]; ok && bytes.Equal(old, v) {
+		return
+	}
+	msg := json.RawMessage(v)
+	rec.Set[k] = &msg
+}
+
+func (j *Journal) apply(rec *journalRecord) {
+	for k, v := range rec.Set {
+		j.entries[k] = []byte(*v)
+	}
+	for _, k := range rec.Del {
+		delete(j.entries, k)
+	}
+}
+
+func (j *Journal) commit(rec *journalRecord) error {
+	if len(rec.Set) == 0 && len(rec.Del) == 0 {
+		return nil
+	}
+	if !j.haveState || j.f == nil {
+		// always have a full state file around, and once closed
+		// only the state file is written
+		j.apply(rec)
+		return j.Compact()
+	}
+
+	buf, err := encodeJournalRecord(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(buf); err != nil {
+		// do not leave a partial record behind
+		j.f.Truncate(j.journalSize)
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+	j.apply(rec)
+	j.journalSize += int64(len(buf))
+
+	if j.journalSize > journalMinCompactSize && j.journalSize > j.stateSize {
+		return j.Compact()
+	}
+	return nil
+}
+
+// Compact writes the whole state to the state file and empties the
+// journal.
+func (j *Journal) Compact() error {
+	data, err := JoinEntries(j.entries)
+	if err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(j.statePath, data, 0600, 0); err != nil {
+		return err
+	}
+	j.haveState = true
+	j.stateSize = int64(len(data))
+
+	if j.f == nil {
+		// the journal was closed, its records must not be replayed
+		// onto the new state file
+		if err := os.Remove(JournalPath(j.statePath)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		j.journalSize = 0
+		return nil
+	}
+
+	// replaying the old journal onto the new state file would be
+	// harmless, so there is no need to be atomic here
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+	j.journalSize = 0
+	return nil
+}
+
+// Close closes the journal. The state can still be committed afterwards,
+// it is then written as a whole to the state file.
+func (j *Journal) Close() error {
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
+
+// DiscardJournal folds the journal of the state file at statePath, if
+// any, into the state file and removes it.
+func DiscardJournal(statePath string) error {
+	if !osutil.FileExists(JournalPath(statePath)) {
+		return nil
+	}
+	j, err := OpenJournal(statePath)
+	if err != nil {
+		return err
+	}
+	defer j.Close()
+	if j.haveState || len(j.entries) != 0 {
+		if err := j.Compact(); err != nil {
+			return err
+		}
+	}
+	return os.Remove(JournalPath(statePath))
+}
@@ -0,0 +1,442 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type journalSuite struct {
+	statePath string
+}
+
+var _ = Suite(&journalSuite{})
+
+func (s *journalSuite) SetUpTest(c *C) {
+	s.statePath = filepath.Join(c.MkDir(), "state.json")
+}
+
+type fakeEntriesBackend struct {
+	fakeStateBackend
+	deltas []*state.EntriesDelta
+}
+
+func (b *fakeEntriesBackend) CheckpointEntries(delta *state.EntriesDelta) error {
+	b.deltas = append(b.deltas, delta)
+	return nil
+}
+
+func mockStateWithContent(c *C) *state.State {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	st.Set("foo", map[string]int{"a": 1})
+	st.Set("bar", "baz")
+	chg := st.NewChange("install", "summary")
+	t1 := st.NewTask("download", "1...")
+	chg.AddTask(t1)
+	t2 := st.NewTask("install", "2...")
+	t2.WaitFor(t1)
+	chg.AddTask(t2)
+	st.NewLane()
+	st.Warnf("hello")
+	return st
+}
+
+func (s *journalSuite) TestSplitJoinEntries(c *C) {
+	st := mockStateWithContent(c)
+	st.Lock()
+	data, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+
+	entries, err := state.SplitEntries(data)
+	c.Assert(err, IsNil)
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	c.Check(keys, DeepEquals, []string{
+		"changes/1", "data/bar", "data/foo", "last-change-id", "last-lane-id",
+		"last-task-id", "tasks/1", "tasks/2", "warnings",
+	})
+	c.Check(string(entries["data/bar"]), Equals, `"baz"`)
+	c.Check(string(entries["last-task-id"]), Equals, `2`)
+
+	joined, err := state.JoinEntries(entries)
+	c.Assert(err, IsNil)
+	c.Check(string(joined), Equals, string(data))
+
+	_, err = state.JoinEntries(map[string][]byte{"potato": []byte("1")})
+	c.Check(err, ErrorMatches, `cannot join state entries: unknown entry "potato"`)
+}
+
+func (s *journalSuite) TestCheckpointEntries(c *C) {
+	b := new(fakeEntriesBackend)
+	st := state.New(b)
+	st.Lock()
+	st.Set("foo", "bar")
+	chg := st.NewChange("install", "summary")
+	chg.AddTask(st.NewTask("download", "1..."))
+	st.Unlock()
+
+	// the plain checkpoint is not used
+	c.Check(b.checkpoints, HasLen, 0)
+	c.Assert(b.deltas, HasLen, 1)
+	// the first checkpoint of a new state has all entries
+	c.Check(b.deltas[0].Full, Equals, true)
+
+	data, err := state.JoinEntries(b.deltas[0].Set)
+	c.Assert(err, IsNil)
+	st2, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+	var v string
+	c.Check(st2.Get("foo", &v), IsNil)
+	c.Check(v, Equals, "bar")
+	c.Check(st2.Changes(), HasLen, 1)
+	c.Check(st2.Tasks(), HasLen, 1)
+}
+
+func deltaKeys(delta *state.EntriesDelta) []string {
+	keys := make([]string, 0, len(delta.Set))
+	for k := range delta.Set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *journalSuite) TestCheckpointEntriesOnlyModified(c *C) {
+	b := new(fakeEntriesBackend)
+	st := state.New(b)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Set("other", "value")
+	chg1 := st.NewChange("install", "summary")
+	t1 := st.NewTask("download", "1...")
+	chg1.AddTask(t1)
+	chg2 := st.NewChange("remove", "summary")
+	t2 := st.NewTask("unlink", "2...")
+	chg2.AddTask(t2)
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 1)
+
+	// only what was modified is handed over
+	st.Lock()
+	st.Set("foo", "baz")
+	t1.SetStatus(state.DoingStatus)
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 2)
+	delta := b.deltas[1]
+	c.Check(delta.Full, Equals, false)
+	c.Check(deltaKeys(delta), DeepEquals, []string{
+		"changes/1", "data/foo", "last-change-id", "last-lane-id",
+		"last-task-id", "tasks/1",
+	})
+	c.Check(string(delta.Set["data/foo"]), Equals, `"baz"`)
+	c.Check(delta.Del, HasLen, 0)
+
+	// removals are handed over as well
+	st.Lock()
+	st.Set("other", nil)
+	st.Warnf("hello")
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 3)
+	delta = b.deltas[2]
+	c.Check(deltaKeys(delta), DeepEquals, []string{
+		"last-change-id", "last-lane-id", "last-task-id", "warnings",
+	})
+	c.Check(delta.Del, DeepEquals, []string{"data/other"})
+
+	// nothing modified, nothing checkpointed
+	st.Lock()
+	st.Unlock()
+	c.Check(b.deltas, HasLen, 3)
+}
+
+func (s *journalSuite) TestCheckpointEntriesAfterReadState(c *C) {
+	st := mockStateWithContent(c)
+	st.Lock()
+	data, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+
+	// the backend holds what is read already
+	b := new(fakeEntriesBackend)
+	st2, err := state.ReadState(b, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	t := st2.Task("2")
+	c.Assert(t, NotNil)
+	t.SetStatus(state.DoneStatus)
+	st2.Unlock()
+
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(b.deltas[0].Full, Equals, false)
+	c.Check(deltaKeys(b.deltas[0]), DeepEquals, []string{
+		"changes/1", "last-change-id", "last-lane-id", "last-task-id", "tasks/2",
+	})
+}
+
+func (s *journalSuite) checkpoint(c *C, j *state.Journal, st *state.State) {
+	st.Lock()
+	data, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	entries, err := state.SplitEntries(data)
+	c.Assert(err, IsNil)
+	c.Assert(j.Commit(entries), IsNil)
+}
+
+func (s *journalSuite) journalSize(c *C) int64 {
+	fi, err := os.Stat(state.JournalPath(s.statePath))
+	c.Assert(err, IsNil)
+	return fi.Size()
+}
+
+func (s *journalSuite) TestJournalCommitAndReplay(c *C) {
+	j, err := state.OpenJournal(s.statePath)
+	c.Assert(err, IsNil)
+	defer j.Close()
+	data, err := j.Data()
+	c.Assert(err, IsNil)
+	c.Check(data, IsNil)
+
+	st := mockStateWithContent(c)
+	s.checkpoint(c, j, st)
+
+	// the first commit writes a full state file
+	c.Check(s.statePath, testutil.FilePresent)
+	c.Check(s.journalSize(c), Equals, int64(0))
+	fullState, err := ioutil.ReadFile(s.statePath)
+	c.Assert(err, IsNil)
+
+	// nothing changed, nothing is written
+	s.checkpoint(c, j, st)
+	c.Check(s.journalSize(c), Equals, int64(0))
+
+	st.Lock()
+	st.Set("bar", "other")
+	st.Set("foo", nil)
+	expected, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	s.checkpoint(c, j, st)
+
+	// only the journal was written to
+	c.Check(s.statePath, testutil.FileEquals, string(fullState))
+	size := s.journalSize(c)
+	c.Check(size > 0, Equals, true)
+	c.Check(size < int64(len(fullState)), Equals, true)
+	journal, err := ioutil.ReadFile(state.JournalPath(s.statePath))
+	c.Assert(err, IsNil)
+	c.Check(strings.Contains(string(journal), `"data/bar":"other"`), Equals, true)
+	c.Check(strings.Contains(string(journal), `"del":["data/foo"]`), Equals, true)
+	c.Check(strings.Contains(string(journal), `"tasks/1"`), Equals, false)
+
+	data, err = state.ReadStateData(s.statePath)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, string(expected))
+
+	// and reopening replays the journal
+	j2, err := state.OpenJournal(s.statePath)
+	c.Assert(err, IsNil)
+	defer j2.Close()
+	data, err = j2.Data()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, string(expected))
+}
+
+func (s *journalSuite) TestJournalCommitEntries(c *C) {
+	j, err := state.OpenJournal(s.statePath)
+	c.Assert(err, IsNil)
+	defer j.Close()
+
+	st := mockStateWithContent(c)
+	s.checkpoint(c, j, st)
+	fullState, err := ioutil.ReadFile(s.statePath)
+	c.Assert(err, IsNil)
+
+	err = j.CommitEntries(&state.EntriesDelta{
+		Set: map[string][]byte{
+			"data/bar":     []byte(`"other"`),
+			"last-task-id": []byte(`2`),
+		},
+		Del: []string{"data/foo", "data/unknown"},
+	})
+	c.Assert(err, IsNil)
+
+	// only the journal was written to, with the entries that changed
+	c.Check(s.statePath, testutil.FileEquals, string(fullState))
+	journal, err := ioutil.ReadFile(state.JournalPath(s.statePath))
+	c.Assert(err, IsNil)
+	c.Check(strings.Contains(string(journal), `"data/bar":"other"`), Equals, true)
+	c.Check(strings.Contains(string(journal), `"del":["data/foo"]`), Equals, true)
+	c.Check(strings.Contains(string(journal), `last-task-id`), Equals, false)
+
+	st.Lock()
+	st.Set("bar", "other")
+	st.Set("foo", nil)
+	expected, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	data, err := state.ReadStateData(s.statePath)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, string(expected))
+}
+
+func (s *journalSuite) TestJournalCommitAfterClose(c *C) {
+	j, err := state.OpenJournal(s.statePath)
+	c.Assert(err, IsNil)
+
+	st := mockStateWithContent(c)
+	s.checkpoint(c, j, st)
+	st.Lock()
+	st.Set("bar", "other")
+	st.Unlock()
+	s.checkpoint(c, j, st)
+	c.Check(s.journalSize(c) > 0, Equals, true)
+	c.Assert(j.Close(), IsNil)
+	// closing again is fine
+	c.Assert(j.Close(), IsNil)
+
+	// once closed the state is written as a whole
+	st.Lock()
+	st.Set("bar", "closed")
+	expected, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	s.checkpoint(c, j, st)
+	c.Check(state.JournalPath(s.statePath), testutil.FileAbsent)
+	c.Check(s.statePath, testutil.FileEquals, string(expected))
+}
+
+func (s *journalSuite) TestJournalIgnoresTruncatedRecord(c *C) {
+	j, err := state.OpenJournal(s.statePath)
+	c.Assert(err, IsNil)
+	defer j.Close()
+
+	st := mockStateWithContent(c)
+	s.checkpoint(c, j, st)
+	st.Lock()
+	st.Set("bar", "other")
+	expected, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	s.checkpoint(c, j, st)
+	size := s.journalSize(c)
+
+	// simulate a crash in the middle of writing a record
+	f, err := os.OpenFile(state.JournalPath(s.statePath), os.O_WRONLY|os.O_APPEND, 0600)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte{100, 0, 0, 0, 1, 2, 3, 4, '{', '"'})
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	data, err := state.ReadStateData(s.statePath)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, string(expected))
+
+	// opening for writing drops the partial record
+	j2, err := state.OpenJournal(s.statePath)
+	c.Assert(err, IsNil)
+	defer j2.Close()
+	c.Check(s.journalSize(c), Equals, size)
+	data, err = j2.Data()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, string(expected))
+}
+
+func (s *journalSuite) TestJournalCompaction(c *C) {
+	restore := state.MockJournalMinCompactSize(0)
+	defer restore()
+
+	j, err := state.OpenJournal(s.statePath)
+	c.Assert(err, IsNil)
+	defer j.Close()
+
+	st := state.New(nil)
+	s.checkpoint(c, j, st)
+
+	st.Lock()
+	st.Set("foo", "small")
+	st.Unlock()
+	s.checkpoint(c, j, st)
+	// the journal is not yet larger than the state file
+	c.Check(s.journalSize(c) > 0, Equals, true)
+
+	st.Lock()
+	st.Set("foo", strings.Repeat("x", 1000))
+	expected, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	s.checkpoint(c, j, st)
+
+	// the journal was folded into the state file
+	c.Check(s.journalSize(c), Equals, int64(0))
+	c.Check(s.statePath, testutil.FileEquals, string(expected))
+}
+
+func (s *journalSuite) TestDiscardJournal(c *C) {
+	// nothing to do
+	c.Assert(state.DiscardJournal(s.statePath), IsNil)
+	c.Check(s.statePath, testutil.FileAbsent)
+
+	j, err := state.OpenJournal(s.statePath)
+	c.Assert(err, IsNil)
+	st := mockStateWithContent(c)
+	s.checkpoint(c, j, st)
+	st.Lock()
+	st.Set("bar", "other")
+	expected, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	s.checkpoint(c, j, st)
+	c.Assert(j.Close(), IsNil)
+
+	c.Assert(state.DiscardJournal(s.statePath), IsNil)
+	c.Check(state.JournalPath(s.statePath), testutil.FileAbsent)
+	c.Check(s.statePath, testutil.FileEquals, string(expected))
+}
+
+func (s *journalSuite) TestReadStateDataNoJournal(c *C) {
+	_, err := state.ReadStateData(s.statePath)
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	c.Assert(ioutil.WriteFile(s.statePath, []byte(`{"data":{}}`), 0600), IsNil)
+	data, err := state.ReadStateData(s.statePath)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"data":{}}`)
+}
@@ -100,7 +100,8 @@ const (
 // operations without it.
 //
 // The state is persisted on every unlock operation via the StateBackend
-// it was initialized with.
+// it was initialized with, as a whole or split into entries if the backend
+// is an EntriesBackend.
 type State struct {
 	mu  sync.Mutex
 	muC int32
@@ -116,6 +117,9 @@ type State struct {
 	warnings map[string]*Warning
 
 	modified bool
+	// entries tracks the parts of the state modified since the last
+	// checkpoint when the backend is an EntriesBackend
+	entries *entriesTracker
 
 	cache map[interface{}]interface{}
 
@@ -133,6 +137,7 @@ func New(backend Backend) *State {
 		tasks:    make(map[string]*Task),
 		warnings: make(map[string]*Warning),
 		modified: true,
+		entries:  newEntriesTracker(backend, true),
 		cache:    make(map[interface{}]interface{}),
 	}
 }
@@ -243,11 +248,18 @@ func (s *State) Unlock() {
 		return
 	}
 
-	data := s.checkpointData()
+	var checkpoint func() error
+	if eb, ok := s.backend.(EntriesBackend); ok {
+		delta := s.checkpointEntries()
+		checkpoint = func() error { return eb.CheckpointEntries(delta) }
+	} else {
+		data := s.checkpointData()
+		checkpoint = func() error { return s.backend.Checkpoint(data) }
+	}
 	var err error
 	start := time.Now()
 	for time.Since(start) <= unlockCheckpointRetryMaxTime {
-		if err = s.backend.Checkpoint(data); err == nil {
+		if err = checkpoint(); err == nil {
 			s.modified = false
 			return
 		}
@@ -343,6 +355,7 @@ func (s *State) Get(key string, value interface{}) error {
 func (s *State) Set(key string, value interface{}) {
 	s.writing()
 	s.data.set(key, value)
+	s.entries.dataModified(key)
 }
 
 // Cached returns the cached value associated with the provided key.
@@ -370,6 +383,7 @@ func (s *State) NewChange(kind, summary string) *Change {
 	id := strconv.Itoa(s.lastChangeId)
 	chg := newChange(s, id, kind, summary)
 	s.changes[id] = chg
+	s.entries.changeModified(id)
 	return chg
 }
 
@@ -405,6 +419,7 @@ func (s *State) NewTask(kind, summary string) *Task {
 	id := strconv.Itoa(s.lastTaskId)
 	t := newTask(s, id, kind, summary)
 	s.tasks[id] = t
+	s.entries.taskModified(t)
 	return t
 }
 
@@ -480,6 +495,7 @@ func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Dura
 	for k, w := range s.warnings {
 		if w.ExpiredBefore(now) {
 			delete(s.warnings, k)
+			s.entries.warningsModified()
 		}
 	}
 
@@ -493,6 +509,7 @@ func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Dura
 			if spawnTime.Before(pruneLimit) && len(chg.Tasks()) == 0 {
 				chg.Abort()
 				delete(s.changes, chg.ID())
+				s.entries.changeModified(chg.ID())
 			} else if spawnTime.Before(abortLimit) {
 				chg.Abort()
 			}
@@ -503,8 +520,10 @@ func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Dura
 			s.writing()
 			for _, t := range chg.Tasks() {
 				delete(s.tasks, t.ID())
+				s.entries.taskModified(t)
 			}
 			delete(s.changes, chg.ID())
+			s.entries.changeModified(chg.ID())
 			readyChangesCount--
 		}
 	}
@@ -514,6 +533,7 @@ func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Dura
 		if t.Change() == nil && t.SpawnTime().Before(pruneLimit) {
 			s.writing()
 			delete(s.tasks, tid)
+			s.entries.taskModified(t)
 		}
 	}
 }
@@ -544,6 +564,8 @@ func ReadState(backend Backend, r io.Reader) (*State, error) {
 	}
 	s.backend = backend
 	s.modified = false
+	// the backend is expected to hold what was read already
+	s.entries = newEntriesTracker(backend, false)
 	s.cache = make(map[interface{}]interface{})
 	return s, err
 }
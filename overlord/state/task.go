@@ -76,6 +76,11 @@ func newTask(state *State, id, kind, summary string) *Task {
 	}
 }
 
+func (t *Task) writing() {
+	t.state.writing()
+	t.state.entries.taskModified(t)
+}
+
 type marshalledTask struct {
 	ID        string                      `json:"id"`
 	Kind      string                      `json:"kind"`
@@ -198,7 +203,7 @@ func (t *Task) Status() Status {
 
 // SetStatus sets the task status, overriding the default behavior (see Status method).
 func (t *Task) SetStatus(new Status) {
-	t.state.writing()
+	t.writing()
 	old := t.status
 	t.status = new
 	if !old.Ready() && new.Ready() {
@@ -220,7 +225,7 @@ func (t *Task) IsClean() bool {
 //
 // Cleaning a task must only be done after the change is ready.
 func (t *Task) SetClean() {
-	t.state.writing()
+	t.writing()
 	if t.clean {
 		return
 	}
@@ -260,7 +265,7 @@ func (t *Task) Progress() (label string, done, total int) {
 func (t *Task) SetProgress(label string, done, total int) {
 	// Only mark state for checkpointing if progress is final.
 	if total > 0 && done == total {
-		t.state.writing()
+		t.writing()
 	} else {
 		t.state.reading()
 	}
@@ -291,12 +296,12 @@ func (t *Task) AtTime() time.Time {
 }
 
 func (t *Task) accumulateDoingTime(duration time.Duration) {
-	t.state.writing()
+	t.writing()
 	t.doingTime += duration
 }
 
 func (t *Task) accumulateUndoingTime(duration time.Duration) {
-	t.state.writing()
+	t.writing()
 	t.undoingTime += duration
 }
 
@@ -355,20 +360,20 @@ func (t *Task) Log() []string {
 
 // Logf logs information about the progress of the task.
 func (t *Task) Logf(format string, args ...interface{}) {
-	t.state.writing()
+	t.writing()
 	t.addLog(LogInfo, format, args)
 }
 
 // Errorf logs error information about the progress of the task.
 func (t *Task) Errorf(format string, args ...interface{}) {
-	t.state.writing()
+	t.writing()
 	t.addLog(LogError, format, args)
 }
 
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (t *Task) Set(key string, value interface{}) {
-	t.state.writing()
+	t.writing()
 	t.data.set(key, value)
 }
 
@@ -387,7 +392,7 @@ func (t *Task) Has(key string) bool {
 
 // Clear disassociates the value from key.
 func (t *Task) Clear(key string) {
-	t.state.writing()
+	t.writing()
 	delete(t.data, key)
 }
 
@@ -402,9 +407,10 @@ func addOnce(set []string, s string) []string {
 
 // WaitFor registers another task as a requirement for t to make progress.
 func (t *Task) WaitFor(another *Task) {
-	t.state.writing()
+	t.writing()
 	t.waitTasks = addOnce(t.waitTasks, another.id)
 	another.haltTasks = addOnce(another.haltTasks, t.id)
+	t.state.entries.taskModified(another)
 }
 
 // WaitAll registers all the tasks in the set as a requirement for t
@@ -444,13 +450,13 @@ func (t *Task) Lanes() []int {
 // JoinLane registers the task in the provided lane. Tasks in different lanes
 // abort independently on errors. See Change.AbortLane for details.
 func (t *Task) JoinLane(lane int) {
-	t.state.writing()
+	t.writing()
 	t.lanes = append(t.lanes, lane)
 }
 
 // At schedules the task, if it's not ready, to happen no earlier than when, if when is the zero time any previous special scheduling is suppressed.
 func (t *Task) At(when time.Time) {
-	t.state.writing()
+	t.writing()
 	iszero := when.IsZero()
 	if t.Status().Ready() && !iszero {
 		return
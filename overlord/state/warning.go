@@ -193,6 +193,7 @@ func (s *State) Warnf(template string, args ...interface{}) {
 
 func (s *State) addWarning(w Warning, t time.Time) {
 	s.writing()
+	s.entries.warningsModified()
 
 	if s.warnings[w.message] == nil {
 		w.firstAdded = t
@@ -227,6 +228,7 @@ func (s *State) AllWarnings() []*Warning {
 func (s *State) OkayWarnings(t time.Time) int {
 	t = t.UTC()
 	s.writing()
+	s.entries.warningsModified()
 
 	n := 0
 	for _, w := range s.warnings {
@@ -286,6 +288,7 @@ func (s *State) WarningsSummary() (int, time.Time) {
 // warnings. For use in debugging.
 func (s *State) UnshowAllWarnings() {
 	s.writing()
+	s.entries.warningsModified()
 	for _, w := range s.warnings {
 		w.lastShown = time.Time{}
 	}
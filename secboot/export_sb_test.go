@@ -202,3 +202,21 @@ func MockSbDeactivateVolume(f func(volumeName string) error) (restore func()) {
 		sbDeactivateVolume = old
 	}
 }
+
+func MockSbGetDiskUnlockKeyFromKernel(f func(prefix, devicePath string, remove bool) (sb.DiskUnlockKey, error)) (restore func()) {
+	old := sbGetDiskUnlockKeyFromKernel
+	sbGetDiskUnlockKeyFromKernel = f
+	return func() {
+		sbGetDiskUnlockKeyFromKernel = old
+	}
+}
+
+var TPMProvision = tpmProvision
+
+func MockTPMSetLockoutAuthValue(f func(tpm *sb.TPMConnection, lockoutAuth []byte)) (restore func()) {
+	old := tpmSetLockoutAuthValue
+	tpmSetLockoutAuthValue = f
+	return func() {
+		tpmSetLockoutAuthValue = old
+	}
+}
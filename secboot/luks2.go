@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// AddLUKS2ContainerKey adds newKey to a free keyslot of the existing LUKS2
+// container on the block device given by node. One of the keys already
+// present in the container must be provided in existingKey to authorize the
+// operation.
+func AddLUKS2ContainerKey(node string, existingKey, newKey []byte) error {
+	if len(newKey) == 0 {
+		return fmt.Errorf("internal error: cannot add an empty key")
+	}
+	// the new key is passed through a pipe rather than a file so that it
+	// never touches the disk
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	// the keys are small enough to fit in the pipe buffer
+	_, err = w.Write(newKey)
+	w.Close()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("cryptsetup", "luksAddKey",
+		// the existing key is read from stdin
+		"--key-file", "-",
+		// use argon2i as the KDF
+		"--pbkdf", "argon2i",
+		node,
+		// the new key is read from the pipe
+		"/dev/fd/3")
+	cmd.Stdin = bytes.NewReader(existingKey)
+	cmd.ExtraFiles = []*os.File{r}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot add key to %s: %v", node, osutil.OutputErr(output, err))
+	}
+	return nil
+}
+
+// RemoveLUKS2ContainerKey removes the keyslot which can be unlocked by the
+// given key from the LUKS2 container on the block device given by node.
+func RemoveLUKS2ContainerKey(node string, key []byte) error {
+	cmd := exec.Command("cryptsetup", "luksRemoveKey",
+		// the key to remove is read from stdin
+		"--key-file", "-",
+		node)
+	cmd.Stdin = bytes.NewReader(key)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot remove key from %s: %v", node, osutil.OutputErr(output, err))
+	}
+	return nil
+}
+
+// LUKS2ContainerHasKey returns whether one of the keyslots of the LUKS2
+// container on the block device given by node can be unlocked by key.
+func LUKS2ContainerHasKey(node string, key []byte) (bool, error) {
+	cmd := exec.Command("cryptsetup", "open", "--test-passphrase",
+		// the key to check is read from stdin
+		"--key-file", "-",
+		node)
+	cmd.Stdin = bytes.NewReader(key)
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return true, nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// cryptsetup exits with 2 when no keyslot can be unlocked
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.ExitStatus() == 2 {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("cannot check key of %s: %v", node, osutil.OutputErr(output, err))
+}
@@ -0,0 +1,142 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secboot_test
+
+import (
+	"fmt"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/secboot"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type luks2Suite struct {
+	testutil.BaseTest
+
+	dir string
+}
+
+var _ = Suite(&luks2Suite{})
+
+func (s *luks2Suite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.dir = c.MkDir()
+}
+
+func (s *luks2Suite) TestAddLUKS2ContainerKeyHappy(c *C) {
+	existingKeyFile := filepath.Join(s.dir, "existing-key")
+	newKeyFile := filepath.Join(s.dir, "new-key")
+	mockCryptsetup := testutil.MockCommand(c, "cryptsetup", fmt.Sprintf(`
+cat > %s
+cat /dev/fd/3 > %s
+`, existingKeyFile, newKeyFile))
+	s.AddCleanup(mockCryptsetup.Restore)
+
+	err := secboot.AddLUKS2ContainerKey("/dev/node", []byte("existing"), []byte("new-key"))
+	c.Assert(err, IsNil)
+	c.Check(mockCryptsetup.Calls(), DeepEquals, [][]string{
+		{"cryptsetup", "luksAddKey", "--key-file", "-", "--pbkdf", "argon2i", "/dev/node", "/dev/fd/3"},
+	})
+	c.Check(existingKeyFile, testutil.FileEquals, "existing")
+	c.Check(newKeyFile, testutil.FileEquals, "new-key")
+}
+
+func (s *luks2Suite) TestAddLUKS2ContainerKeyError(c *C) {
+	mockCryptsetup := testutil.MockCommand(c, "cryptsetup", `
+echo "No key available with this passphrase." >&2
+exit 2
+`)
+	s.AddCleanup(mockCryptsetup.Restore)
+
+	err := secboot.AddLUKS2ContainerKey("/dev/node", []byte("existing"), []byte("new-key"))
+	c.Assert(err, ErrorMatches, "cannot add key to /dev/node: No key available with this passphrase.")
+}
+
+func (s *luks2Suite) TestAddLUKS2ContainerKeyEmpty(c *C) {
+	mockCryptsetup := testutil.MockCommand(c, "cryptsetup", "exit 1")
+	s.AddCleanup(mockCryptsetup.Restore)
+
+	err := secboot.AddLUKS2ContainerKey("/dev/node", []byte("existing"), nil)
+	c.Assert(err, ErrorMatches, "internal error: cannot add an empty key")
+	c.Check(mockCryptsetup.Calls(), HasLen, 0)
+}
+
+func (s *luks2Suite) TestRemoveLUKS2ContainerKeyHappy(c *C) {
+	keyFile := filepath.Join(s.dir, "key")
+	mockCryptsetup := testutil.MockCommand(c, "cryptsetup", fmt.Sprintf("cat > %s", keyFile))
+	s.AddCleanup(mockCryptsetup.Restore)
+
+	err := secboot.RemoveLUKS2ContainerKey("/dev/node", []byte("old-key"))
+	c.Assert(err, IsNil)
+	c.Check(mockCryptsetup.Calls(), DeepEquals, [][]string{
+		{"cryptsetup", "luksRemoveKey", "--key-file", "-", "/dev/node"},
+	})
+	c.Check(keyFile, testutil.FileEquals, "old-key")
+}
+
+func (s *luks2Suite) TestRemoveLUKS2ContainerKeyError(c *C) {
+	mockCryptsetup := testutil.MockCommand(c, "cryptsetup", `
+echo "No key available with this passphrase." >&2
+exit 2
+`)
+	s.AddCleanup(mockCryptsetup.Restore)
+
+	err := secboot.RemoveLUKS2ContainerKey("/dev/node", []byte("old-key"))
+	c.Assert(err, ErrorMatches, "cannot remove key from /dev/node: No key available with this passphrase.")
+}
+
+func (s *luks2Suite) TestLUKS2ContainerHasKey(c *C) {
+	keyFile := filepath.Join(s.dir, "key")
+	mockCryptsetup := testutil.MockCommand(c, "cryptsetup", fmt.Sprintf("cat > %s", keyFile))
+	s.AddCleanup(mockCryptsetup.Restore)
+
+	hasKey, err := secboot.LUKS2ContainerHasKey("/dev/node", []byte("some-key"))
+	c.Assert(err, IsNil)
+	c.Check(hasKey, Equals, true)
+	c.Check(mockCryptsetup.Calls(), DeepEquals, [][]string{
+		{"cryptsetup", "open", "--test-passphrase", "--key-file", "-", "/dev/node"},
+	})
+	c.Check(keyFile, testutil.FileEquals, "some-key")
+}
+
+func (s *luks2Suite) TestLUKS2ContainerHasKeyNoKey(c *C) {
+	mockCryptsetup := testutil.MockCommand(c, "cryptsetup", `
+echo "No key available with this passphrase." >&2
+exit 2
+`)
+	s.AddCleanup(mockCryptsetup.Restore)
+
+	hasKey, err := secboot.LUKS2ContainerHasKey("/dev/node", []byte("some-key"))
+	c.Assert(err, IsNil)
+	c.Check(hasKey, Equals, false)
+}
+
+func (s *luks2Suite) TestLUKS2ContainerHasKeyError(c *C) {
+	mockCryptsetup := testutil.MockCommand(c, "cryptsetup", `
+echo "Device /dev/node does not exist or access denied." >&2
+exit 4
+`)
+	s.AddCleanup(mockCryptsetup.Restore)
+
+	_, err := secboot.LUKS2ContainerHasKey("/dev/node", []byte("some-key"))
+	c.Assert(err, ErrorMatches, "cannot check key of /dev/node: Device /dev/node does not exist or access denied.")
+}
@@ -43,3 +43,7 @@ func SealKeysWithFDESetupHook(runHook fde.RunSetupHookFunc, keys []SealKeyReques
 func ResealKeys(params *ResealKeysParams) error {
 	return errBuildWithoutSecboot
 }
+
+func UnlockKeyFromKernel(devicePath string) (EncryptionKey, error) {
+	return nil, errBuildWithoutSecboot
+}
@@ -37,6 +37,7 @@ var (
 	sbActivateVolumeWithKeyData     = sb.ActivateVolumeWithKeyData
 	sbActivateVolumeWithRecoveryKey = sb.ActivateVolumeWithRecoveryKey
 	sbDeactivateVolume              = sb.DeactivateVolume
+	sbGetDiskUnlockKeyFromKernel    = sb.GetDiskUnlockKeyFromKernel
 )
 
 func init() {
@@ -167,3 +168,15 @@ func UnlockEncryptedVolumeWithRecoveryKey(name, device string) error {
 
 	return nil
 }
+
+// UnlockKeyFromKernel returns the key that was used to unlock the encrypted
+// partition device at the given path. The key is retrieved from the kernel
+// keyring, where it was stored when the device was unlocked with a sealed key
+// or the recovery key.
+func UnlockKeyFromKernel(devicePath string) (EncryptionKey, error) {
+	key, err := sbGetDiskUnlockKeyFromKernel(keyringPrefix, devicePath, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get unlock key for %q from the kernel keyring: %v", devicePath, err)
+	}
+	return EncryptionKey(key), nil
+}
@@ -910,6 +910,51 @@ func (s *secbootSuite) TestSealKey(c *C) {
 	}
 }
 
+func (s *secbootSuite) TestTPMProvisionWithExistingLockoutAuth(c *C) {
+	lockoutAuthFile := filepath.Join(c.MkDir(), "lockout-auth-file")
+	err := ioutil.WriteFile(lockoutAuthFile, []byte("old-lockout-auth"), 0600)
+	c.Assert(err, IsNil)
+
+	var calls []string
+	restore := secboot.MockTPMSetLockoutAuthValue(func(tpm *sb.TPMConnection, lockoutAuth []byte) {
+		calls = append(calls, "set-auth")
+		c.Check(lockoutAuth, DeepEquals, []byte("old-lockout-auth"))
+	})
+	defer restore()
+	restore = secboot.MockProvisionTPM(func(tpm *sb.TPMConnection, mode sb.ProvisionMode, newLockoutAuth []byte) error {
+		calls = append(calls, "provision")
+		c.Check(mode, Equals, sb.ProvisionModeFull)
+		c.Check(newLockoutAuth, HasLen, 16)
+		c.Check(lockoutAuthFile, testutil.FileEquals, newLockoutAuth)
+		return nil
+	})
+	defer restore()
+
+	err = secboot.TPMProvision(nil, lockoutAuthFile)
+	c.Assert(err, IsNil)
+	c.Check(calls, DeepEquals, []string{"set-auth", "provision"})
+}
+
+func (s *secbootSuite) TestTPMProvisionNoExistingLockoutAuth(c *C) {
+	lockoutAuthFile := filepath.Join(c.MkDir(), "lockout-auth-file")
+
+	restore := secboot.MockTPMSetLockoutAuthValue(func(tpm *sb.TPMConnection, lockoutAuth []byte) {
+		c.Fatalf("unexpected call")
+	})
+	defer restore()
+	provisionCalls := 0
+	restore = secboot.MockProvisionTPM(func(tpm *sb.TPMConnection, mode sb.ProvisionMode, newLockoutAuth []byte) error {
+		provisionCalls++
+		return nil
+	})
+	defer restore()
+
+	err := secboot.TPMProvision(nil, lockoutAuthFile)
+	c.Assert(err, IsNil)
+	c.Check(provisionCalls, Equals, 1)
+	c.Check(lockoutAuthFile, testutil.FilePresent)
+}
+
 func (s *secbootSuite) TestResealKey(c *C) {
 	mockErr := errors.New("some error")
 
@@ -1157,6 +1202,30 @@ func (s *secbootSuite) TestUnlockEncryptedVolumeUsingKeyErr(c *C) {
 	})
 }
 
+func (s *secbootSuite) TestUnlockKeyFromKernelHappy(c *C) {
+	restore := secboot.MockSbGetDiskUnlockKeyFromKernel(func(prefix, devicePath string, remove bool) (sb.DiskUnlockKey, error) {
+		c.Check(prefix, Equals, "ubuntu-fde")
+		c.Check(devicePath, Equals, "/dev/disk/by-partuuid/123-123-123")
+		c.Check(remove, Equals, false)
+		return sb.DiskUnlockKey("unlock-key"), nil
+	})
+	defer restore()
+
+	key, err := secboot.UnlockKeyFromKernel("/dev/disk/by-partuuid/123-123-123")
+	c.Assert(err, IsNil)
+	c.Check(key, DeepEquals, secboot.EncryptionKey("unlock-key"))
+}
+
+func (s *secbootSuite) TestUnlockKeyFromKernelErr(c *C) {
+	restore := secboot.MockSbGetDiskUnlockKeyFromKernel(func(prefix, devicePath string, remove bool) (sb.DiskUnlockKey, error) {
+		return nil, fmt.Errorf("not found")
+	})
+	defer restore()
+
+	_, err := secboot.UnlockKeyFromKernel("/dev/disk/by-partuuid/123-123-123")
+	c.Assert(err, ErrorMatches, `cannot get unlock key for "/dev/disk/by-partuuid/123-123-123" from the kernel keyring: not found`)
+}
+
 func (s *secbootSuite) TestUnlockVolumeUsingSealedKeyIfEncryptedFdeRevealKeyErr(c *C) {
 	restore := fde.MockRunFDERevealKey(func(req *fde.RevealKeyRequest) ([]byte, error) {
 		return nil, fmt.Errorf("helper error")
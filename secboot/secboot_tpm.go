@@ -63,6 +63,10 @@ var (
 	isTPMEnabled = isTPMEnabledImpl
 	provisionTPM = provisionTPMImpl
 
+	tpmSetLockoutAuthValue = func(tpm *sb.TPMConnection, lockoutAuth []byte) {
+		tpm.LockoutHandleContext().SetAuthValue(lockoutAuth)
+	}
+
 	// dummy to check whether the interfaces match
 	_ (secboot.SnapModel) = ModelForSealing(nil)
 )
@@ -469,10 +473,21 @@ func buildPCRProtectionProfile(modelParams []*SealKeyModelParams) (*sb.PCRProtec
 }
 
 func tpmProvision(tpm *sb.TPMConnection, lockoutAuthFile string) error {
+	// A TPM that was provisioned by a previous install, for instance when
+	// performing a factory reset, can only be cleared using the lockout
+	// authorization that was set back then
+	currentLockoutAuth, err := ioutil.ReadFile(lockoutAuthFile)
+	switch {
+	case err == nil:
+		tpmSetLockoutAuthValue(tpm, currentLockoutAuth)
+	case !os.IsNotExist(err):
+		return fmt.Errorf("cannot read existing lockout authorization file: %v", err)
+	}
+
 	// Create and save the lockout authorization file
 	lockoutAuth := make([]byte, 16)
 	// crypto rand is protected against short reads
-	_, err := rand.Read(lockoutAuth)
+	_, err = rand.Read(lockoutAuth)
 	if err != nil {
 		return fmt.Errorf("cannot create lockout authorization: %v", err)
 	}
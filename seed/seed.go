@@ -118,6 +118,12 @@ type Seed interface {
 	ModeSnaps(mode string) ([]*Snap, error)
 }
 
+// ValidateUC20SeedSystemLabel checks whether the string is a valid UC20 seed
+// system label.
+func ValidateUC20SeedSystemLabel(label string) error {
+	return internal.ValidateUC20SeedSystemLabel(label)
+}
+
 // Open returns a Seed implementation for the seed at seedDir.
 // label if not empty is used to identify a Core 20 recovery system seed.
 func Open(seedDir, label string) (Seed, error) {
@@ -530,6 +530,17 @@ func (s *Info) Services() []*AppInfo {
 	return svcs
 }
 
+// RunAsUser returns the system username the services of the snap that do
+// not run as root run as, or "" if all of them run as root.
+func (s *Info) RunAsUser() string {
+	for _, app := range s.Apps {
+		if app.RunAs != "" {
+			return app.RunAs
+		}
+	}
+	return ""
+}
+
 // ExpandSnapVariables resolves $SNAP, $SNAP_DATA and $SNAP_COMMON inside the
 // snap's mount namespace.
 func (s *Info) ExpandSnapVariables(path string) string {
@@ -865,14 +876,24 @@ type AppInfo struct {
 	WatchdogTimeout timeout.Timeout
 	StopCommand     string
 	ReloadCommand   string
+	ReloadOnConfig  *ReloadOnConfig
 	PostStopCommand string
 	RestartCond     RestartCondition
 	RestartDelay    timeout.Timeout
+	RestartLimit    *RestartLimit
+	FailureAction   FailureAction
 	Completer       string
 	RefreshMode     string
 	StopMode        StopModeType
 	InstallMode     string
 
+	// RunAs is the system username declared by the snap that the service
+	// runs as, by default services run as root. snap-confine is then
+	// entered as that user like for any other unprivileged invocation, so
+	// privileges are never dropped inside the sandbox and the AppArmor and
+	// seccomp profiles need no extra permissions for it.
+	RunAs string
+
 	// TODO: this should go away once we have more plumbing and can change
 	// things vs refactor
 	// https://github.com/snapcore/snapd/pull/794#discussion_r58688496
@@ -932,6 +953,10 @@ type HookInfo struct {
 	Environment  strutil.OrderedMap
 	CommandChain []string
 
+	// Timer is the schedule, in the timeutil schedule format, on which
+	// snapd runs the maintenance hook.
+	Timer string
+
 	Explicit bool
 }
 
@@ -978,6 +1003,12 @@ func (app *AppInfo) DesktopFile() string {
 	return filepath.Join(dirs.SnapDesktopFilesDir, fmt.Sprintf("%s_%s.desktop", app.Snap.DesktopPrefix(), app.Name))
 }
 
+// EnvOverridesFile returns the path to the file holding the environment
+// overrides set by the administrator for the application.
+func (app *AppInfo) EnvOverridesFile() string {
+	return filepath.Join(dirs.SnapAppEnvDir, app.SecurityTag()+".env")
+}
+
 // WrapperPath returns the path to wrapper invoking the app binary.
 func (app *AppInfo) WrapperPath() string {
 	return filepath.Join(dirs.SnapBinariesDir, JoinSnapApp(app.Snap.InstanceName(), app.Name))
@@ -1309,6 +1340,53 @@ func (r ByType) Less(i, j int) bool {
 	return r[i].Type().SortsBefore(r[j].Type())
 }
 
+const (
+	// AfterServicesAttr is the plug or slot attribute listing the services
+	// of the connected snaps that the services of the snap start after.
+	AfterServicesAttr = "after-services"
+	// BeforeServicesAttr is the plug or slot attribute listing the services
+	// of the connected snaps that the services of the snap start before.
+	BeforeServicesAttr = "before-services"
+)
+
+// ServiceOrderingAttrs returns the names of the services of connected snaps
+// that the services of a snap start after and before, as declared by the
+// given plug or slot attributes.
+func ServiceOrderingAttrs(attrs map[string]interface{}) (after, before []string, err error) {
+	after, err = serviceNamesAttr(attrs, AfterServicesAttr)
+	if err != nil {
+		return nil, nil, err
+	}
+	before, err = serviceNamesAttr(attrs, BeforeServicesAttr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return after, before, nil
+}
+
+func serviceNamesAttr(attrs map[string]interface{}, key string) ([]string, error) {
+	value, ok := attrs[key]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of service names", key)
+	}
+	names := make([]string, 0, len(list))
+	for _, item := range list {
+		name, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list of service names", key)
+		}
+		if err := naming.ValidateApp(name); err != nil {
+			return nil, fmt.Errorf("invalid service name %q in %s", name, key)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
 // SortServices sorts the apps based on their Before and After specs, such that
 // starting the services in the returned ordering will satisfy all specs.
 func SortServices(apps []*AppInfo) (sorted []*AppInfo, err error) {
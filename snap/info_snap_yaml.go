@@ -77,6 +77,7 @@ type appYaml struct {
 
 	StopCommand     string          `yaml:"stop-command,omitempty"`
 	ReloadCommand   string          `yaml:"reload-command,omitempty"`
+	ReloadOnConfig  *ReloadOnConfig `yaml:"reload-on-config,omitempty"`
 	PostStopCommand string          `yaml:"post-stop-command,omitempty"`
 	StopTimeout     timeout.Timeout `yaml:"stop-timeout,omitempty"`
 	StartTimeout    timeout.Timeout `yaml:"start-timeout,omitempty"`
@@ -85,9 +86,12 @@ type appYaml struct {
 	RefreshMode     string          `yaml:"refresh-mode,omitempty"`
 	StopMode        StopModeType    `yaml:"stop-mode,omitempty"`
 	InstallMode     string          `yaml:"install-mode,omitempty"`
+	RunAs           string          `yaml:"run-as,omitempty"`
+	FailureAction   FailureAction   `yaml:"failure-action,omitempty"`
 
 	RestartCond  RestartCondition `yaml:"restart-condition,omitempty"`
 	RestartDelay timeout.Timeout  `yaml:"restart-delay,omitempty"`
+	RestartLimit *RestartLimit    `yaml:"restart-limit,omitempty"`
 	SlotNames    []string         `yaml:"slots,omitempty"`
 	PlugNames    []string         `yaml:"plugs,omitempty"`
 
@@ -112,6 +116,7 @@ type hookYaml struct {
 	SlotNames    []string           `yaml:"slots,omitempty"`
 	Environment  strutil.OrderedMap `yaml:"environment,omitempty"`
 	CommandChain []string           `yaml:"command-chain,omitempty"`
+	Timer        string             `yaml:"timer,omitempty"`
 }
 
 type layoutYaml struct {
@@ -356,9 +361,12 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) error {
 			StopTimeout:     yApp.StopTimeout,
 			StopCommand:     yApp.StopCommand,
 			ReloadCommand:   yApp.ReloadCommand,
+			ReloadOnConfig:  yApp.ReloadOnConfig,
 			PostStopCommand: yApp.PostStopCommand,
 			RestartCond:     yApp.RestartCond,
 			RestartDelay:    yApp.RestartDelay,
+			RestartLimit:    yApp.RestartLimit,
+			FailureAction:   yApp.FailureAction,
 			BusName:         yApp.BusName,
 			CommonID:        yApp.CommonID,
 			Environment:     yApp.Environment,
@@ -366,6 +374,7 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) error {
 			StopMode:        yApp.StopMode,
 			RefreshMode:     yApp.RefreshMode,
 			InstallMode:     yApp.InstallMode,
+			RunAs:           yApp.RunAs,
 			Before:          yApp.Before,
 			After:           yApp.After,
 			Autostart:       yApp.Autostart,
@@ -387,6 +396,10 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) error {
 		if app.Daemon != "" && app.DaemonScope == "" {
 			app.DaemonScope = SystemDaemon
 		}
+		// "reload-on-config: false" is the same as not setting it
+		if r := app.ReloadOnConfig; r != nil && !r.All && r.Prefix == "" {
+			app.ReloadOnConfig = nil
+		}
 
 		snap.Apps[appName] = app
 		for _, alias := range app.LegacyAliases {
@@ -474,6 +487,7 @@ func setHooksFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) {
 			Name:         hookName,
 			Environment:  yHook.Environment,
 			CommandChain: yHook.CommandChain,
+			Timer:        yHook.Timer,
 			Explicit:     true,
 		}
 		if len(y.Plugs) > 0 || len(yHook.PlugNames) > 0 {
@@ -1926,6 +1926,20 @@ hooks:
 	c.Check(hook.CommandChain, DeepEquals, []string{"hookchain1", "hookchain2"})
 }
 
+func (s *YamlSuite) TestSnapYamlMaintenanceHookTimer(c *C) {
+	y := []byte(`name: wat
+version: 42
+hooks:
+ maintenance:
+  timer: mon,10:00-12:00
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	hook := info.Hooks["maintenance"]
+	c.Assert(hook, NotNil)
+	c.Check(hook.Timer, Equals, "mon,10:00-12:00")
+}
+
 func (s *YamlSuite) TestSnapYamlRestartDelay(c *C) {
 	yAutostart := []byte(`name: wat
 version: 42
@@ -1942,6 +1956,81 @@ apps:
 	c.Check(app.RestartDelay, Equals, timeout.Timeout(12*time.Second))
 }
 
+func (s *YamlSuite) TestSnapYamlRestartLimitAndFailureAction(c *C) {
+	y := []byte(`name: wat
+version: 42
+apps:
+ foo:
+  command: bin/foo
+  daemon: simple
+  restart-limit:
+   burst: 5
+   interval: 1m
+  failure-action: revert
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	app := info.Apps["foo"]
+	c.Assert(app, NotNil)
+	c.Check(app.RestartLimit, DeepEquals, &snap.RestartLimit{
+		Burst:    5,
+		Interval: timeout.Timeout(time.Minute),
+	})
+	c.Check(app.FailureAction, Equals, snap.FailureActionRevert)
+}
+
+func (s *YamlSuite) TestSnapYamlReloadOnConfig(c *C) {
+	y := []byte(`name: wat
+version: 42
+apps:
+ all:
+  command: bin/foo
+  daemon: simple
+  reload-on-config: true
+ prefix:
+  command: bin/foo
+  daemon: simple
+  reload-on-config: server.http
+ off:
+  command: bin/foo
+  daemon: simple
+  reload-on-config: false
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Check(info.Apps["all"].ReloadOnConfig, DeepEquals, &snap.ReloadOnConfig{All: true})
+	c.Check(info.Apps["prefix"].ReloadOnConfig, DeepEquals, &snap.ReloadOnConfig{Prefix: "server.http"})
+	c.Check(info.Apps["off"].ReloadOnConfig, IsNil)
+
+	_, err = snap.InfoFromSnapYaml([]byte(`name: wat
+version: 42
+apps:
+ foo:
+  daemon: simple
+  reload-on-config: [a, b]
+`))
+	c.Check(err, ErrorMatches, `.*"reload-on-config" must be a boolean or a configuration key prefix`)
+}
+
+func (s *YamlSuite) TestReloadOnConfigMatches(c *C) {
+	all := &snap.ReloadOnConfig{All: true}
+	c.Check(all.Matches("foo"), Equals, true)
+
+	prefix := &snap.ReloadOnConfig{Prefix: "server.http"}
+	for key, matches := range map[string]bool{
+		"server.http":         true,
+		"server.http.port":    true,
+		"server":              true,
+		"server.https":        false,
+		"server.ftp":          false,
+		"serverless":          false,
+		"other.server.http":   false,
+		"server.http-timeout": false,
+	} {
+		c.Check(prefix.Matches(key), Equals, matches, Commentf("%s", key))
+	}
+}
+
 func (s *YamlSuite) TestSnapYamlSystemUsernamesParsing(c *C) {
 	y := []byte(`name: binary
 version: 1.0
@@ -25,6 +25,7 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"time"
 
 	// TODO: move this to snap/quantity? or similar
 	"github.com/snapcore/snapd/gadget/quantity"
@@ -34,8 +35,8 @@ import (
 )
 
 // Group is a quota group of snaps, services or sub-groups that are all subject
-// to specific resource quotas. The only quota resource types currently
-// supported is memory, but this can be expanded in the future.
+// to specific resource quotas. The quota resource types currently supported
+// are memory and journal, but this can be expanded in the future.
 type Group struct {
 	// Name is the name of the quota group. This name is used the
 	// name of the systemd slice underlying the quota group.
@@ -59,6 +60,13 @@ type Group struct {
 	// ExhaustionBehavior. MemoryLimit is expressed in bytes.
 	MemoryLimit quantity.Size `json:"memory-limit,omitempty"`
 
+	// JournalLimit is the optional limit on the systemd journal used by the
+	// services of the snaps in the group. When set, the services log into a
+	// journal namespace of their own, so that they cannot evict the logs of
+	// services outside of the group. Sub-groups without a journal limit log
+	// into the journal namespace of their parent, see JournalQuotaGroup.
+	JournalLimit *JournalQuota `json:"journal-limit,omitempty"`
+
 	// ParentGroup is the the parent group that this group is a child of. If it
 	// is empty, then this is a "root" quota group.
 	ParentGroup string `json:"parent-group,omitempty"`
@@ -72,6 +80,48 @@ type Group struct {
 	Snaps []string `json:"snaps,omitempty"`
 }
 
+// JournalQuota describes the limits of the journal namespace of a quota
+// group.
+type JournalQuota struct {
+	// Size is the maximum disk space used by the journal namespace,
+	// expressed in bytes. A size of 0 leaves the journald default in place.
+	Size quantity.Size `json:"size,omitempty"`
+
+	// RateCount is the number of messages allowed to be logged by each
+	// service in the group within RatePeriod, after which further messages
+	// are dropped until the period is over.
+	RateCount int `json:"rate-count,omitempty"`
+
+	// RatePeriod is the period over which RateCount is enforced.
+	RatePeriod time.Duration `json:"rate-period,omitempty"`
+}
+
+// minJournalSize is the smallest journal size that can be set for a journal
+// namespace, journald will not be able to rotate anything smaller than that
+// in a meaningful way.
+const minJournalSize = 64 * quantity.SizeKiB
+
+// Validate checks that the journal quota is sensible.
+func (jq *JournalQuota) Validate() error {
+	if jq.Size == 0 && jq.RateCount == 0 && jq.RatePeriod == 0 {
+		return fmt.Errorf("journal quota must have a size or a rate limit")
+	}
+	if jq.Size != 0 && jq.Size < minJournalSize {
+		min := minJournalSize
+		return fmt.Errorf("journal size %s is too small, it must be at least %s", jq.Size.IECString(), min.IECString())
+	}
+	if jq.RateCount < 0 || jq.RatePeriod < 0 {
+		return fmt.Errorf("journal rate limit cannot be negative")
+	}
+	if (jq.RateCount == 0) != (jq.RatePeriod == 0) {
+		return fmt.Errorf("journal rate limit must have both a message count and a period")
+	}
+	if jq.RatePeriod != 0 && jq.RatePeriod < time.Second {
+		return fmt.Errorf("journal rate limit period must be at least one second")
+	}
+	return nil
+}
+
 // NewGroup creates a new top quota group with the given name and memory limit.
 func NewGroup(name string, memLimit quantity.Size) (*Group, error) {
 	grp := &Group{
@@ -111,6 +161,37 @@ func (grp *Group) CurrentMemoryUsage() (quantity.Size, error) {
 	return mem, nil
 }
 
+// JournalNamespaceName returns the name of the systemd-journald namespace
+// used by the services of the group when the group has a journal limit.
+func (grp *Group) JournalNamespaceName() string {
+	return fmt.Sprintf("snap-%s", grp.Name)
+}
+
+// JournalQuotaGroup returns the group whose journal namespace the services of
+// the snaps in the group log into. Sub-groups without a journal limit of their
+// own inherit the journal namespace of the closest parent group with one. It
+// returns nil if neither the group nor any of its parents has a journal limit.
+func (grp *Group) JournalQuotaGroup() *Group {
+	for g := grp; g != nil; g = g.parentGroup {
+		if g.JournalLimit != nil {
+			return g
+		}
+	}
+	return nil
+}
+
+// JournalConfFileName returns the name of the systemd-journald configuration
+// file for the journal namespace of the group.
+func (grp *Group) JournalConfFileName() string {
+	return fmt.Sprintf("journald@%s.conf", grp.JournalNamespaceName())
+}
+
+// JournalServiceName returns the name of the systemd-journald instance
+// serving the journal namespace of the group.
+func (grp *Group) JournalServiceName() string {
+	return fmt.Sprintf("systemd-journald@%s.service", grp.JournalNamespaceName())
+}
+
 // SliceFileName returns the name of the slice file that should be used for this
 // quota group. This name will include all of the group's parents in the name.
 // For example, a group named "bar" that is a child of the "foo" group will have
@@ -159,6 +240,12 @@ func (grp *Group) validate() error {
 	// TODO: probably there is a minimum amount of bytes here that is
 	// technically usable/enforcable, should we check that too?
 
+	if grp.JournalLimit != nil {
+		if err := grp.JournalLimit.Validate(); err != nil {
+			return err
+		}
+	}
+
 	if grp.ParentGroup != "" && grp.Name == grp.ParentGroup {
 		return fmt.Errorf("group has circular parent reference to itself")
 	}
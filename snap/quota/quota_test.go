@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"math"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -729,3 +730,113 @@ func (ts *quotaTestSuite) TestCurrentMemoryUsage(c *C) {
 	const sixteenExb = quantity.Size(1<<64 - 1)
 	c.Assert(currentMem, Equals, sixteenExb)
 }
+
+func (ts *quotaTestSuite) TestJournalQuotaValidate(c *C) {
+	tt := []struct {
+		jq      quota.JournalQuota
+		err     string
+		comment string
+	}{
+		{
+			jq:      quota.JournalQuota{Size: 64 * quantity.SizeMiB},
+			comment: "size only",
+		},
+		{
+			jq:      quota.JournalQuota{RateCount: 100, RatePeriod: time.Minute},
+			comment: "rate limit only",
+		},
+		{
+			jq:      quota.JournalQuota{Size: quantity.SizeMiB, RateCount: 100, RatePeriod: 5 * time.Second},
+			comment: "size and rate limit",
+		},
+		{
+			jq:      quota.JournalQuota{},
+			err:     `journal quota must have a size or a rate limit`,
+			comment: "empty",
+		},
+		{
+			jq:      quota.JournalQuota{Size: quantity.SizeKiB},
+			err:     `journal size 1 KiB is too small, it must be at least 64 KiB`,
+			comment: "too small",
+		},
+		{
+			jq:      quota.JournalQuota{RateCount: 100},
+			err:     `journal rate limit must have both a message count and a period`,
+			comment: "count without period",
+		},
+		{
+			jq:      quota.JournalQuota{RatePeriod: time.Minute},
+			err:     `journal rate limit must have both a message count and a period`,
+			comment: "period without count",
+		},
+		{
+			jq:      quota.JournalQuota{RateCount: -1, RatePeriod: time.Minute},
+			err:     `journal rate limit cannot be negative`,
+			comment: "negative count",
+		},
+		{
+			jq:      quota.JournalQuota{RateCount: 10, RatePeriod: time.Millisecond},
+			err:     `journal rate limit period must be at least one second`,
+			comment: "tiny period",
+		},
+	}
+
+	for _, t := range tt {
+		comment := Commentf(t.comment)
+		err := t.jq.Validate()
+		if t.err != "" {
+			c.Assert(err, ErrorMatches, t.err, comment)
+		} else {
+			c.Assert(err, IsNil, comment)
+		}
+	}
+}
+
+func (ts *quotaTestSuite) TestJournalLimitValidatedWithGroup(c *C) {
+	grps := map[string]*quota.Group{
+		"foogroup": {
+			Name:         "foogroup",
+			MemoryLimit:  quantity.SizeMiB,
+			JournalLimit: &quota.JournalQuota{RateCount: 10},
+		},
+	}
+	err := quota.ResolveCrossReferences(grps)
+	c.Assert(err, ErrorMatches, `group "foogroup" is invalid: journal rate limit must have both a message count and a period`)
+
+	grps["foogroup"].JournalLimit.RatePeriod = time.Second
+	c.Assert(quota.ResolveCrossReferences(grps), IsNil)
+}
+
+func (ts *quotaTestSuite) TestJournalNames(c *C) {
+	grp, err := quota.NewGroup("foo-group", quantity.SizeGiB)
+	c.Assert(err, IsNil)
+
+	c.Check(grp.JournalNamespaceName(), Equals, "snap-foo-group")
+	c.Check(grp.JournalConfFileName(), Equals, "journald@snap-foo-group.conf")
+	c.Check(grp.JournalServiceName(), Equals, "systemd-journald@snap-foo-group.service")
+}
+
+func (ts *quotaTestSuite) TestJournalQuotaGroupInherited(c *C) {
+	grp, err := quota.NewGroup("foo", quantity.SizeGiB)
+	c.Assert(err, IsNil)
+	sub, err := grp.NewSubGroup("bar", quantity.SizeMiB)
+	c.Assert(err, IsNil)
+	subsub, err := sub.NewSubGroup("baz", quantity.SizeMiB)
+	c.Assert(err, IsNil)
+
+	// no journal limit anywhere
+	c.Check(grp.JournalQuotaGroup(), IsNil)
+	c.Check(subsub.JournalQuotaGroup(), IsNil)
+
+	// sub-groups inherit the journal namespace of the closest parent
+	grp.JournalLimit = &quota.JournalQuota{Size: quantity.SizeMiB}
+	c.Check(grp.JournalQuotaGroup(), Equals, grp)
+	c.Check(sub.JournalQuotaGroup(), Equals, grp)
+	c.Check(subsub.JournalQuotaGroup(), Equals, grp)
+
+	// unless they have one of their own
+	sub.JournalLimit = &quota.JournalQuota{Size: quantity.SizeMiB}
+	c.Check(grp.JournalQuotaGroup(), Equals, grp)
+	c.Check(sub.JournalQuotaGroup(), Equals, sub)
+	c.Check(subsub.JournalQuotaGroup(), Equals, sub)
+}
@@ -0,0 +1,80 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ReloadOnConfig selects the configuration changes after which a service is
+// reloaded, or restarted when it has no reload-command.
+type ReloadOnConfig struct {
+	// All is set when any configuration change applies.
+	All bool
+	// Prefix is the configuration key, in dotted notation, whose
+	// changes, including changes of its sub-keys, apply.
+	Prefix string
+}
+
+// UnmarshalYAML so ReloadOnConfig implements yaml's Unmarshaler interface,
+// it accepts either a boolean or a configuration key prefix.
+func (r *ReloadOnConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var all bool
+	if err := unmarshal(&all); err == nil {
+		*r = ReloadOnConfig{All: all}
+		return nil
+	}
+	var prefix string
+	if err := unmarshal(&prefix); err != nil || prefix == "" {
+		return fmt.Errorf(`"reload-on-config" must be a boolean or a configuration key prefix`)
+	}
+	*r = ReloadOnConfig{Prefix: prefix}
+	return nil
+}
+
+// validConfigSubkey matches the components of configuration keys, it
+// mirrors the check done when setting configuration.
+var validConfigSubkey = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
+
+// Validate ensures that the key prefix of ReloadOnConfig is a valid
+// configuration key.
+func (r *ReloadOnConfig) Validate() error {
+	if r.All {
+		return nil
+	}
+	for _, subkey := range strings.Split(r.Prefix, ".") {
+		if !validConfigSubkey.MatchString(subkey) {
+			return fmt.Errorf("invalid reload-on-config key prefix %q", r.Prefix)
+		}
+	}
+	return nil
+}
+
+// Matches returns whether a change of the given configuration key, in dotted
+// notation, applies.
+func (r *ReloadOnConfig) Matches(key string) bool {
+	if r.All {
+		return true
+	}
+	// changing a parent key also changes the keys below it
+	return key == r.Prefix || strings.HasPrefix(key, r.Prefix+".") || strings.HasPrefix(r.Prefix, key+".")
+}
@@ -21,6 +21,9 @@ package snap
 
 import (
 	"errors"
+	"fmt"
+
+	"github.com/snapcore/snapd/timeout"
 )
 
 // RestartCondition encapsulates the different systemd 'restart' options
@@ -75,3 +78,33 @@ func (rc *RestartCondition) UnmarshalYAML(unmarshal func(interface{}) error) err
 
 	return nil
 }
+
+// RestartLimit carries the rate limiting of service starts, a service started
+// more than Burst times within Interval is not started anymore and enters the
+// failed state.
+type RestartLimit struct {
+	Burst    int             `yaml:"burst"`
+	Interval timeout.Timeout `yaml:"interval"`
+}
+
+// FailureAction describes how snapd escalates a service entering the failed
+// state.
+type FailureAction string
+
+// These are the supported failure actions
+const (
+	// FailureActionRevert reverts the snap to its previous revision.
+	FailureActionRevert FailureAction = "revert"
+	// FailureActionReboot reboots the device, it only applies to snaps
+	// declared critical by the gadget.
+	FailureActionReboot FailureAction = "reboot"
+)
+
+// Validate ensures that the FailureAction has a valid value.
+func (fa FailureAction) Validate() error {
+	switch fa {
+	case "", FailureActionRevert, FailureActionReboot:
+		return nil
+	}
+	return fmt.Errorf(`"failure-action" field contains invalid value %q`, fa)
+}
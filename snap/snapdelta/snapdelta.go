@@ -0,0 +1,116 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package snapdelta creates and applies binary deltas between two
+// revisions of the same snap.
+package snapdelta
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/squashfs"
+	"github.com/snapcore/snapd/snapdtool"
+)
+
+// Format is the format of the deltas created and applied by this
+// package, it matches the format of the deltas served by the store.
+const Format = "xdelta3"
+
+var xdelta3Command = xdelta3CommandImpl
+
+func xdelta3CommandImpl(args ...string) (*exec.Cmd, error) {
+	// prefer the xdelta3 from the snapd or core snap, like the store
+	// does for deltas it downloads
+	if cmd, err := snapdtool.CommandFromSystemSnap("/usr/bin/xdelta3", args...); err == nil {
+		return cmd, nil
+	}
+	loc, err := exec.LookPath("xdelta3")
+	if err != nil {
+		return nil, fmt.Errorf("cannot find xdelta3: %v", err)
+	}
+	return exec.Command(loc, args...), nil
+}
+
+func snapName(snapPath string) (string, error) {
+	if !squashfs.FileHasSquashfsHeader(snapPath) {
+		return "", snap.NotSnapError{Path: snapPath}
+	}
+	info, err := snap.ReadInfoFromSnapFile(squashfs.New(snapPath), nil)
+	if err != nil {
+		return "", err
+	}
+	return info.SnapName(), nil
+}
+
+func runXdelta3(partialPath, targetPath string, args ...string) error {
+	cmd, err := xdelta3Command(args...)
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(partialPath)
+		return osutil.OutputErr(output, err)
+	}
+	if err := os.Chmod(partialPath, 0600); err != nil {
+		os.Remove(partialPath)
+		return err
+	}
+	return os.Rename(partialPath, targetPath)
+}
+
+// Generate creates at deltaPath a delta that turns the snap file at
+// sourcePath into the snap file at targetPath. Both files must be
+// squashfs snaps of the same snap.
+func Generate(sourcePath, targetPath, deltaPath string) error {
+	sourceName, err := snapName(sourcePath)
+	if err != nil {
+		return fmt.Errorf("cannot use %q as delta source: %v", sourcePath, err)
+	}
+	targetName, err := snapName(targetPath)
+	if err != nil {
+		return fmt.Errorf("cannot use %q as delta target: %v", targetPath, err)
+	}
+	if sourceName != targetName {
+		return fmt.Errorf("cannot create delta between different snaps %q and %q", sourceName, targetName)
+	}
+
+	partialPath := deltaPath + ".partial"
+	if err := runXdelta3(partialPath, deltaPath, "-e", "-9", "-f", "-s", sourcePath, targetPath, partialPath); err != nil {
+		return fmt.Errorf("cannot create delta: %v", err)
+	}
+	return nil
+}
+
+// Apply reconstructs at targetPath the snap file obtained by applying
+// the delta at deltaPath to the squashfs snap file at sourcePath.
+func Apply(sourcePath, deltaPath, targetPath string) error {
+	if !squashfs.FileHasSquashfsHeader(sourcePath) {
+		return fmt.Errorf("cannot use %q as delta source: %v", sourcePath, snap.NotSnapError{Path: sourcePath})
+	}
+
+	partialPath := targetPath + ".partial"
+	if err := runXdelta3(partialPath, targetPath, "-d", "-f", "-s", sourcePath, deltaPath, partialPath); err != nil {
+		return fmt.Errorf("cannot apply delta: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapdelta_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapdelta"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func TestSnapdelta(t *testing.T) { TestingT(t) }
+
+type snapdeltaSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&snapdeltaSuite{})
+
+func (s *snapdeltaSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+}
+
+// the mocked xdelta3 writes its arguments into its output file, which
+// is always the last argument
+const mockXdelta3 = `for last; do :; done; echo "$*" > "$last"`
+
+func (s *snapdeltaSuite) TestGenerate(c *C) {
+	cmd := testutil.MockCommand(c, "xdelta3", mockXdelta3)
+	defer cmd.Restore()
+
+	source := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 1", nil)
+	target := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 2", nil)
+	delta := filepath.Join(c.MkDir(), "foo.xdelta3")
+
+	err := snapdelta.Generate(source, target, delta)
+	c.Assert(err, IsNil)
+
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"xdelta3", "-e", "-9", "-f", "-s", source, target, delta + ".partial"},
+	})
+	c.Check(delta, testutil.FileContains, delta+".partial")
+	c.Check(osutil.FileExists(delta+".partial"), Equals, false)
+	st, err := os.Stat(delta)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0600))
+}
+
+func (s *snapdeltaSuite) TestGenerateDifferentSnaps(c *C) {
+	cmd := testutil.MockCommand(c, "xdelta3", mockXdelta3)
+	defer cmd.Restore()
+
+	source := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 1", nil)
+	target := snaptest.MakeTestSnapWithFiles(c, "name: bar\nversion: 2", nil)
+
+	err := snapdelta.Generate(source, target, filepath.Join(c.MkDir(), "delta"))
+	c.Assert(err, ErrorMatches, `cannot create delta between different snaps "foo" and "bar"`)
+	c.Check(cmd.Calls(), HasLen, 0)
+}
+
+func (s *snapdeltaSuite) TestGenerateNotASnap(c *C) {
+	source := filepath.Join(c.MkDir(), "source.snap")
+	c.Assert(ioutil.WriteFile(source, []byte("not a snap"), 0644), IsNil)
+	target := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 2", nil)
+
+	err := snapdelta.Generate(source, target, filepath.Join(c.MkDir(), "delta"))
+	c.Assert(err, ErrorMatches, `cannot use ".*/source.snap" as delta source: .* is not a snap or snapdir`)
+}
+
+func (s *snapdeltaSuite) TestApply(c *C) {
+	cmd := testutil.MockCommand(c, "xdelta3", mockXdelta3)
+	defer cmd.Restore()
+
+	source := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 1", nil)
+	delta := filepath.Join(c.MkDir(), "foo.xdelta3")
+	c.Assert(ioutil.WriteFile(delta, []byte("delta"), 0644), IsNil)
+	target := filepath.Join(c.MkDir(), "foo.snap")
+
+	err := snapdelta.Apply(source, delta, target)
+	c.Assert(err, IsNil)
+
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"xdelta3", "-d", "-f", "-s", source, delta, target + ".partial"},
+	})
+	c.Check(osutil.FileExists(target), Equals, true)
+	c.Check(osutil.FileExists(target+".partial"), Equals, false)
+}
+
+func (s *snapdeltaSuite) TestApplyError(c *C) {
+	cmd := testutil.MockCommand(c, "xdelta3", `for last; do :; done; echo partial > "$last"; echo "boom" >&2; exit 1`)
+	defer cmd.Restore()
+
+	source := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 1", nil)
+	target := filepath.Join(c.MkDir(), "foo.snap")
+
+	err := snapdelta.Apply(source, "/some/delta", target)
+	c.Assert(err, ErrorMatches, "cannot apply delta: boom")
+	c.Check(osutil.FileExists(target), Equals, false)
+	c.Check(osutil.FileExists(target+".partial"), Equals, false)
+}
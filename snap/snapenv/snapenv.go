@@ -20,9 +20,12 @@
 package snapenv
 
 import (
+	"bufio"
+	"fmt"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/dirs"
@@ -37,6 +40,74 @@ import (
 // them through snap-confine (for classic confined snaps).
 const PreservedUnsafePrefix = "SNAP_SAVED_"
 
+// EnvOverridePrefix is used to convey the environment overrides of an app,
+// as set by the administrator, from snap run to snap-exec, which applies
+// them on top of the environment declared by the snap.
+const EnvOverridePrefix = "SNAP_ENV_OVERRIDE_"
+
+// EnvOverrides returns the environment overrides set by the administrator
+// for the given app, if any.
+func EnvOverrides(app *snap.AppInfo) (map[string]string, error) {
+	f, err := os.Open(app.EnvOverridesFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	overrides := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		l := strings.SplitN(line, "=", 2)
+		if len(l) != 2 || l[0] == "" {
+			return nil, fmt.Errorf("cannot parse environment overrides of %q: invalid line %q", snap.JoinSnapApp(app.Snap.InstanceName(), app.Name), line)
+		}
+		overrides[l[0]] = l[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+// ExtendEnvWithOverrides extends the given environment with the environment
+// overrides of the given app, escaped with EnvOverridePrefix so that they
+// survive the trip through snap-confine and are only applied by snap-exec.
+// Escaped overrides already present in the environment are dropped.
+func ExtendEnvWithOverrides(env osutil.Environment, app *snap.AppInfo) error {
+	for key := range env {
+		if strings.HasPrefix(key, EnvOverridePrefix) {
+			delete(env, key)
+		}
+	}
+	overrides, err := EnvOverrides(app)
+	if err != nil {
+		return err
+	}
+	for key, value := range overrides {
+		env[EnvOverridePrefix+key] = value
+	}
+	return nil
+}
+
+// UnescapeEnvOverrides applies the environment overrides escaped with
+// EnvOverridePrefix to the given environment, replacing any existing values,
+// and drops the escaped variables.
+func UnescapeEnvOverrides(env osutil.Environment) {
+	for key, value := range env {
+		if newKey := strings.TrimPrefix(key, EnvOverridePrefix); newKey != key {
+			delete(env, key)
+			env[newKey] = value
+		}
+	}
+}
+
 // ExtendEnvForRun extends the given environment with what is is
 // required for snap-{confine,exec}, that means SNAP_{NAME,REVISION}
 // etc are all set.
@@ -55,7 +126,8 @@ func ExtendEnvForRun(env osutil.Environment, info *snap.Info) {
 func snapEnv(info *snap.Info) osutil.Environment {
 	// Environment variables with basic properties of a snap.
 	env := basicEnv(info)
-	if usr, err := user.Current(); err == nil && usr.HomeDir != "" {
+	// system users such as the ones services run as have no home directory
+	if usr, err := user.Current(); err == nil && usr.HomeDir != "" && usr.HomeDir != osutil.NonexistentHomeDir {
 		// Environment variables with values specific to the calling user.
 		for k, v := range userEnv(info, usr.HomeDir) {
 			env[k] = v
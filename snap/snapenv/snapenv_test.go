@@ -287,3 +287,38 @@ func (s *HTestSuite) TestExtendEnvForRunForClassic(c *C) {
 
 	c.Assert(env["TMPDIR"], Equals, "/var/tmp")
 }
+
+func (s *HTestSuite) TestExtendEnvWithOverrides(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	info, err := snap.InfoFromSnapYaml(mockYaml)
+	c.Assert(err, IsNil)
+	app := info.Apps["app"]
+
+	// no overrides
+	env := osutil.Environment{"SNAP_ENV_OVERRIDE_FOO": "bar", "TMPDIR": "/var/tmp"}
+	c.Assert(ExtendEnvWithOverrides(env, app), IsNil)
+	c.Check(env, DeepEquals, osutil.Environment{"TMPDIR": "/var/tmp"})
+
+	c.Assert(os.MkdirAll(dirs.SnapAppEnvDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(app.EnvOverridesFile(), []byte("# comment\nLOG_LEVEL=debug\nOPTS=a=b\n"), 0644), IsNil)
+
+	env = osutil.Environment{"SNAP_ENV_OVERRIDE_FOO": "bar", "TMPDIR": "/var/tmp"}
+	c.Assert(ExtendEnvWithOverrides(env, app), IsNil)
+	c.Check(env, DeepEquals, osutil.Environment{
+		"SNAP_ENV_OVERRIDE_LOG_LEVEL": "debug",
+		"SNAP_ENV_OVERRIDE_OPTS":      "a=b",
+		"TMPDIR":                      "/var/tmp",
+	})
+
+	UnescapeEnvOverrides(env)
+	c.Check(env, DeepEquals, osutil.Environment{
+		"LOG_LEVEL": "debug",
+		"OPTS":      "a=b",
+		"TMPDIR":    "/var/tmp",
+	})
+
+	c.Assert(ioutil.WriteFile(app.EnvOverridesFile(), []byte("garbage\n"), 0644), IsNil)
+	c.Check(ExtendEnvWithOverrides(env, app), ErrorMatches, `cannot parse environment overrides of "snapname.app": invalid line "garbage"`)
+}
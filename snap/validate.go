@@ -173,6 +173,15 @@ func ValidateHook(hook *HookInfo) error {
 		}
 	}
 
+	if hook.Timer != "" {
+		if hook.Name != "maintenance" {
+			return fmt.Errorf("hook %q: timer is only applicable to the maintenance hook", hook.Name)
+		}
+		if _, err := timeutil.ParseSchedule(hook.Timer); err != nil {
+			return fmt.Errorf("hook %q: timer has invalid format: %v", hook.Name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -344,6 +353,11 @@ func Validate(info *Info) error {
 		return err
 	}
 
+	// validate that services run as a single system user
+	if err := validateAppsRunAs(info.Services()); err != nil {
+		return err
+	}
+
 	// validate aliases
 	for alias, app := range info.LegacyAliases {
 		if err := naming.ValidateAlias(alias); err != nil {
@@ -368,6 +382,11 @@ func Validate(info *Info) error {
 		return err
 	}
 
+	// Ensure that service ordering across connections is well formed.
+	if err := plugsSlotsServiceOrdering(info); err != nil {
+		return err
+	}
+
 	// Ensure that base field is valid
 	if err := ValidateBase(info); err != nil {
 		return err
@@ -518,6 +537,21 @@ func plugsSlotsInterfacesNames(info *Info) error {
 	}
 	return nil
 }
+
+func plugsSlotsServiceOrdering(info *Info) error {
+	for plugName, plug := range info.Plugs {
+		if _, _, err := ServiceOrderingAttrs(plug.Attrs); err != nil {
+			return fmt.Errorf("invalid service ordering for plug %q: %v", plugName, err)
+		}
+	}
+	for slotName, slot := range info.Slots {
+		if _, _, err := ServiceOrderingAttrs(slot.Attrs); err != nil {
+			return fmt.Errorf("invalid service ordering for slot %q: %v", slotName, err)
+		}
+	}
+	return nil
+}
+
 func plugsSlotsUniqueNames(info *Info) error {
 	// we could choose the smaller collection if we wanted to optimize this check
 	for plugName := range info.Plugs {
@@ -621,7 +655,7 @@ func validateAppTimer(app *AppInfo) error {
 func validateAppRestart(app *AppInfo) error {
 	// app.RestartCond value is validated when unmarshalling
 
-	if app.RestartDelay == 0 && app.RestartCond == "" {
+	if app.RestartDelay == 0 && app.RestartCond == "" && app.RestartLimit == nil && app.FailureAction == "" {
 		return nil
 	}
 
@@ -640,6 +674,87 @@ func validateAppRestart(app *AppInfo) error {
 			return errors.New("restart-condition is only applicable to services")
 		}
 	}
+
+	if app.RestartLimit != nil {
+		if !app.IsService() {
+			return errors.New("restart-limit is only applicable to services")
+		}
+
+		if app.RestartLimit.Burst <= 0 {
+			return errors.New("restart-limit burst must be positive")
+		}
+		if app.RestartLimit.Interval <= 0 {
+			return errors.New("restart-limit interval must be positive")
+		}
+	}
+
+	if app.FailureAction != "" {
+		if !app.IsService() {
+			return errors.New("failure-action is only applicable to services")
+		}
+
+		if err := app.FailureAction.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateAppReloadOnConfig(app *AppInfo) error {
+	if app.ReloadOnConfig == nil {
+		return nil
+	}
+
+	if !app.IsService() {
+		return errors.New("reload-on-config is only applicable to services")
+	}
+	if app.DaemonScope != SystemDaemon {
+		return fmt.Errorf("reload-on-config cannot be used with daemon-scope %q", app.DaemonScope)
+	}
+	return app.ReloadOnConfig.Validate()
+}
+
+func validateAppRunAs(app *AppInfo) error {
+	if app.RunAs == "" {
+		return nil
+	}
+
+	if !app.IsService() {
+		return errors.New("run-as is only applicable to services")
+	}
+	if app.DaemonScope != SystemDaemon {
+		return fmt.Errorf("run-as cannot be used with daemon-scope %q", app.DaemonScope)
+	}
+	if app.RunAs == "root" {
+		return errors.New(`run-as cannot be "root", services run as root by default`)
+	}
+	if _, ok := app.Snap.SystemUsernames[app.RunAs]; !ok {
+		return fmt.Errorf("run-as refers to system username %q not declared in system-usernames", app.RunAs)
+	}
+	return nil
+}
+
+// validateAppsRunAs checks that the services not running as root all run as
+// the same system user, it owns $SNAP_DATA and $SNAP_COMMON.
+func validateAppsRunAs(svcs []*AppInfo) error {
+	var runAs *AppInfo
+	for _, app := range svcs {
+		if app.RunAs == "" {
+			continue
+		}
+		if runAs == nil {
+			runAs = app
+			continue
+		}
+		if app.RunAs != runAs.RunAs {
+			// sort for a stable error
+			first, second := runAs, app
+			if first.Name > second.Name {
+				first, second = second, first
+			}
+			return fmt.Errorf("cannot run services %q and %q as different system users %q and %q", first.Name, second.Name, first.RunAs, second.RunAs)
+		}
+	}
 	return nil
 }
 
@@ -761,6 +876,12 @@ func ValidateApp(app *AppInfo) error {
 	if err := validateAppRestart(app); err != nil {
 		return err
 	}
+	if err := validateAppReloadOnConfig(app); err != nil {
+		return err
+	}
+	if err := validateAppRunAs(app); err != nil {
+		return err
+	}
 	if err := validateAppOrderNames(app, app.Before); err != nil {
 		return err
 	}
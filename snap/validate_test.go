@@ -170,6 +170,16 @@ func (s *ValidateSuite) TestValidateHook(c *C) {
 	}
 }
 
+func (s *ValidateSuite) TestValidateHookTimer(c *C) {
+	c.Check(ValidateHook(&HookInfo{Name: "maintenance", Timer: "mon,10:00-12:00"}), IsNil)
+	c.Check(ValidateHook(&HookInfo{Name: "maintenance"}), IsNil)
+
+	err := ValidateHook(&HookInfo{Name: "maintenance", Timer: "mon,10:00-12:00/zz"})
+	c.Check(err, ErrorMatches, `hook "maintenance": timer has invalid format: .*`)
+	err = ValidateHook(&HookInfo{Name: "configure", Timer: "mon,10:00-12:00"})
+	c.Check(err, ErrorMatches, `hook "configure": timer is only applicable to the maintenance hook`)
+}
+
 // ValidateApp
 
 func (s *ValidateSuite) TestValidateAppSockets(c *C) {
@@ -776,6 +786,44 @@ slots:
 	c.Check(err, ErrorMatches, `cannot have plug and slot with the same name: "foo"`)
 }
 
+func (s *ValidateSuite) TestPlugSlotServiceOrdering(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`name: snap
+version: 0
+plugs:
+ db:
+  interface: content
+  after-services: [postgres, pg-backup]
+slots:
+ data:
+  interface: content
+  before-services: [consumer]
+`))
+	c.Assert(err, IsNil)
+	c.Check(Validate(info), IsNil)
+
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{`plugs:
+ db:
+  after-services: postgres
+`, `invalid service ordering for plug "db": after-services must be a list of service names`},
+		{`plugs:
+ db:
+  before-services: [1]
+`, `invalid service ordering for plug "db": before-services must be a list of service names`},
+		{`slots:
+ db:
+  after-services: [pg$]
+`, `invalid service ordering for slot "db": invalid service name "pg\$" in after-services`},
+	} {
+		info, err := InfoFromSnapYaml([]byte("name: snap\nversion: 0\n" + t.yaml))
+		c.Assert(err, IsNil)
+		c.Check(Validate(info), ErrorMatches, t.err, Commentf(t.yaml))
+	}
+}
+
 func (s *ValidateSuite) TestIllegalAliasName(c *C) {
 	info, err := InfoFromSnapYaml([]byte(`name: foo
 version: 1.0
@@ -1837,6 +1885,267 @@ apps:
 	}
 }
 
+func (s *ValidateSuite) TestValidateAppRestartLimitAndFailureAction(c *C) {
+	meta := []byte(`
+name: foo
+version: 1.0
+`)
+
+	tcs := []struct {
+		name string
+		desc string
+		err  string
+	}{{
+		name: "all good",
+		desc: `
+    daemon: simple
+    restart-limit:
+      burst: 5
+      interval: 30s
+    failure-action: revert
+`,
+	}, {
+		name: "all good with reboot",
+		desc: `
+    daemon: simple
+    failure-action: reboot
+`,
+	}, {
+		name: "restart-limit but not a service",
+		desc: `
+    restart-limit:
+      burst: 5
+      interval: 30s
+`,
+		err: `restart-limit is only applicable to services`,
+	}, {
+		name: "restart-limit without burst",
+		desc: `
+    daemon: simple
+    restart-limit:
+      interval: 30s
+`,
+		err: `restart-limit burst must be positive`,
+	}, {
+		name: "restart-limit with negative interval",
+		desc: `
+    daemon: simple
+    restart-limit:
+      burst: 5
+      interval: -30s
+`,
+		err: `restart-limit interval must be positive`,
+	}, {
+		name: "failure-action but not a service",
+		desc: `
+    failure-action: revert
+`,
+		err: `failure-action is only applicable to services`,
+	}, {
+		name: "invalid failure-action",
+		desc: `
+    daemon: simple
+    failure-action: explode
+`,
+		err: `"failure-action" field contains invalid value "explode"`,
+	}}
+	for _, tc := range tcs {
+		c.Logf("trying %q", tc.name)
+		info, err := InfoFromSnapYaml(append(meta, []byte("apps:\n  foo:"+tc.desc)...))
+		c.Assert(err, IsNil)
+		c.Assert(info, NotNil)
+
+		err = Validate(info)
+		if tc.err != "" {
+			c.Assert(err, ErrorMatches, `invalid definition of application "foo": `+tc.err)
+		} else {
+			c.Assert(err, IsNil)
+		}
+	}
+}
+
+func (s *ValidateSuite) TestValidateAppReloadOnConfig(c *C) {
+	meta := []byte(`
+name: foo
+version: 1.0
+`)
+
+	tcs := []struct {
+		name string
+		desc string
+		err  string
+	}{{
+		name: "all good",
+		desc: `
+    daemon: simple
+    reload-on-config: true
+`,
+	}, {
+		name: "all good with a prefix",
+		desc: `
+    daemon: simple
+    reload-on-config: server.http-port
+`,
+	}, {
+		name: "not a service",
+		desc: `
+    reload-on-config: true
+`,
+		err: `reload-on-config is only applicable to services`,
+	}, {
+		name: "user daemon",
+		desc: `
+    daemon: simple
+    daemon-scope: user
+    reload-on-config: true
+`,
+		err: `reload-on-config cannot be used with daemon-scope "user"`,
+	}, {
+		name: "invalid prefix",
+		desc: `
+    daemon: simple
+    reload-on-config: server..port
+`,
+		err: `invalid reload-on-config key prefix "server..port"`,
+	}, {
+		name: "invalid prefix characters",
+		desc: `
+    daemon: simple
+    reload-on-config: Server
+`,
+		err: `invalid reload-on-config key prefix "Server"`,
+	}}
+	for _, tc := range tcs {
+		c.Logf("trying %q", tc.name)
+		info, err := InfoFromSnapYaml(append(meta, []byte("apps:\n  foo:"+tc.desc)...))
+		c.Assert(err, IsNil)
+		c.Assert(info, NotNil)
+
+		err = Validate(info)
+		if tc.err != "" {
+			c.Assert(err, ErrorMatches, `invalid definition of application "foo": `+tc.err)
+		} else {
+			c.Assert(err, IsNil)
+		}
+	}
+}
+
+func (s *ValidateSuite) TestValidateAppRunAs(c *C) {
+	meta := []byte(`
+name: foo
+version: 1.0
+system-usernames:
+  snap_daemon: shared
+`)
+
+	tcs := []struct {
+		name string
+		desc string
+		err  string
+	}{{
+		name: "all good",
+		desc: `
+apps:
+  foo:
+    daemon: simple
+    run-as: snap_daemon
+`,
+	}, {
+		name: "not a service",
+		desc: `
+apps:
+  foo:
+    run-as: snap_daemon
+`,
+		err: `run-as is only applicable to services`,
+	}, {
+		name: "user daemon",
+		desc: `
+apps:
+  foo:
+    daemon: simple
+    daemon-scope: user
+    run-as: snap_daemon
+`,
+		err: `run-as cannot be used with daemon-scope "user"`,
+	}, {
+		name: "root",
+		desc: `
+apps:
+  foo:
+    daemon: simple
+    run-as: root
+`,
+		err: `run-as cannot be "root", services run as root by default`,
+	}, {
+		name: "undeclared user",
+		desc: `
+apps:
+  foo:
+    daemon: simple
+    run-as: snap_microk8s
+`,
+		err: `run-as refers to system username "snap_microk8s" not declared in system-usernames`,
+	}}
+	for _, tc := range tcs {
+		c.Logf("trying %q", tc.name)
+		info, err := InfoFromSnapYaml(append(meta, tc.desc...))
+		c.Assert(err, IsNil)
+
+		err = Validate(info)
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, `invalid definition of application "foo": `+tc.err)
+		} else {
+			c.Check(err, IsNil)
+			c.Check(info.Apps["foo"].RunAs, Equals, "snap_daemon")
+		}
+	}
+}
+
+func (s *ValidateSuite) TestValidateAppsRunAsDifferentUsers(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`
+name: foo
+version: 1.0
+system-usernames:
+  snap_daemon: shared
+  snap_microk8s: shared
+apps:
+  foo:
+    daemon: simple
+    run-as: snap_daemon
+  bar:
+    daemon: simple
+    run-as: snap_microk8s
+  baz:
+    daemon: simple
+`))
+	c.Assert(err, IsNil)
+
+	err = Validate(info)
+	c.Check(err, ErrorMatches, `cannot run services "bar" and "foo" as different system users "snap_microk8s" and "snap_daemon"`)
+}
+
+func (s *ValidateSuite) TestRunAsUser(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`
+name: foo
+version: 1.0
+system-usernames:
+  snap_daemon: shared
+apps:
+  foo:
+    daemon: simple
+    run-as: snap_daemon
+  bar:
+    daemon: simple
+`))
+	c.Assert(err, IsNil)
+	c.Check(Validate(info), IsNil)
+	c.Check(info.RunAsUser(), Equals, "snap_daemon")
+
+	delete(info.Apps, "foo")
+	c.Check(info.RunAsUser(), Equals, "")
+}
+
 func (s *ValidateSuite) TestValidateSystemUsernames(c *C) {
 	const yaml1 = `name: binary
 version: 1.0
@@ -111,7 +111,7 @@ func (s *emulation) IsActive(service string) (bool, error) {
 	return false, errNotImplemented
 }
 
-func (s *emulation) LogReader(services []string, n int, follow bool) (io.ReadCloser, error) {
+func (s *emulation) LogReader(services []string, n int, follow, namespaces bool) (io.ReadCloser, error) {
 	return nil, errNotImplemented
 }
 
@@ -150,6 +150,14 @@ func (s *emulation) AddMountUnitFile(snapName, revision, what, where, fstype str
 	return mountUnitName, nil
 }
 
+func (s *emulation) AddMountUnitFileWithOptions(unitOptions *MountUnitOptions) (string, error) {
+	return "", errNotImplemented
+}
+
+func (s *emulation) ListMountUnits(snapName, origin string) ([]string, error) {
+	return nil, errNotImplemented
+}
+
 func (s *emulation) RemoveMountUnitFile(mountedDir string) error {
 	unit := MountUnitPath(dirs.StripRootDir(mountedDir))
 	if !osutil.FileExists(unit) {
@@ -26,15 +26,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode"
 
 	_ "github.com/snapcore/squashfuse"
 
@@ -174,11 +177,13 @@ func Version() (int, error) {
 
 var osutilStreamCommand = osutil.StreamCommand
 
-// jctl calls journalctl to get the JSON logs of the given services.
-var jctl = func(svcs []string, n int, follow bool) (io.ReadCloser, error) {
+// jctl calls journalctl to get the JSON logs of the given services. If
+// namespaces is set, the logs of all the journal namespaces are read as
+// well as the ones in the default journal.
+var jctl = func(svcs []string, n int, follow, namespaces bool) (io.ReadCloser, error) {
 	// args will need two entries per service, plus a fixed number (give or take
 	// one) for the initial options.
-	args := make([]string, 0, 2*len(svcs)+6)        // the fixed number is 6
+	args := make([]string, 0, 2*len(svcs)+7)        // the fixed number is 7
 	args = append(args, "-o", "json", "--no-pager") //   3...
 	if n < 0 {
 		args = append(args, "--no-tail") // < 2
@@ -186,7 +191,10 @@ var jctl = func(svcs []string, n int, follow bool) (io.ReadCloser, error) {
 		args = append(args, "-n", strconv.Itoa(n)) // ... + 2 ...
 	}
 	if follow {
-		args = append(args, "-f") // ... + 1 == 6
+		args = append(args, "-f") // ... + 1 ...
+	}
+	if namespaces {
+		args = append(args, "--namespace=*") // ... + 1 == 7
 	}
 
 	for i := range svcs {
@@ -196,7 +204,7 @@ var jctl = func(svcs []string, n int, follow bool) (io.ReadCloser, error) {
 	return osutilStreamCommand("journalctl", args...)
 }
 
-func MockJournalctl(f func(svcs []string, n int, follow bool) (io.ReadCloser, error)) func() {
+func MockJournalctl(f func(svcs []string, n int, follow, namespaces bool) (io.ReadCloser, error)) func() {
 	oldJctl := jctl
 	jctl = f
 	return func() {
@@ -249,12 +257,19 @@ type Systemd interface {
 	IsEnabled(service string) (bool, error)
 	// IsActive checks whether the given service is Active
 	IsActive(service string) (bool, error)
-	// LogReader returns a reader for the given services' log.
-	LogReader(services []string, n int, follow bool) (io.ReadCloser, error)
+	// LogReader returns a reader for the given services' log. If namespaces
+	// is set, the logs are also read from all journal namespaces.
+	LogReader(services []string, n int, follow, namespaces bool) (io.ReadCloser, error)
 	// AddMountUnitFile adds/enables/starts a mount unit.
 	AddMountUnitFile(name, revision, what, where, fstype string) (string, error)
+	// AddMountUnitFileWithOptions adds/enables/starts a mount unit
+	// described by the given options.
+	AddMountUnitFileWithOptions(unitOptions *MountUnitOptions) (string, error)
 	// RemoveMountUnitFile unmounts/stops/disables/removes a mount unit.
 	RemoveMountUnitFile(baseDir string) error
+	// ListMountUnits returns the mount points of the mount units of the
+	// given snap that were added with the given origin.
+	ListMountUnits(snapName, origin string) ([]string, error)
 	// Mask the given service.
 	Mask(service string) error
 	// Unmask the given service.
@@ -456,8 +471,8 @@ func (s *systemd) StartNoBlock(serviceNames ...string) error {
 	return err
 }
 
-func (*systemd) LogReader(serviceNames []string, n int, follow bool) (io.ReadCloser, error) {
-	return jctl(serviceNames, n, follow)
+func (*systemd) LogReader(serviceNames []string, n int, follow, namespaces bool) (io.ReadCloser, error) {
+	return jctl(serviceNames, n, follow, namespaces)
 }
 
 var statusregex = regexp.MustCompile(`(?m)^(?:(.+?)=(.*)|(.*))?$`)
@@ -467,6 +482,9 @@ type UnitStatus struct {
 	UnitName string
 	Enabled  bool
 	Active   bool
+	// Failed is true if the unit entered the failed state, eg. because
+	// its start rate limit was hit.
+	Failed bool
 	// Installed is false if the queried unit doesn't exist.
 	Installed bool
 }
@@ -545,6 +563,7 @@ func (s *systemd) getUnitStatus(properties []string, unitNames []string) ([]*Uni
 		case "ActiveState":
 			// made to match “systemctl is-active” behaviour, at least at systemd 229
 			cur.Active = v == "active" || v == "reloading"
+			cur.Failed = v == "failed"
 		case "UnitFileState":
 			// "static" means it can't be disabled
 			cur.Enabled = v == "enabled" || v == "static"
@@ -1130,13 +1149,165 @@ func (s *systemd) AddMountUnitFile(snapName, revision, what, where, fstype strin
 	return mountUnitName, nil
 }
 
+// MountUnitLifetime is the lifetime of a mount unit.
+type MountUnitLifetime int
+
+const (
+	// Persistent mount units are enabled and survive reboots.
+	Persistent MountUnitLifetime = iota
+	// Transient mount units are only started and are gone after a reboot.
+	Transient
+)
+
+// MountUnitOptions describes a mount unit added with
+// AddMountUnitFileWithOptions.
+type MountUnitOptions struct {
+	Lifetime MountUnitLifetime
+	SnapName string
+	Revision string
+	What     string
+	Where    string
+	// Fstype is the filesystem type, systemd detects it when empty.
+	Fstype  string
+	Options []string
+	// Origin records what requested the mount unit, for example the
+	// interface that allowed it.
+	Origin string
+}
+
+// runtimeMountUnitPath returns the path of a transient mount unit.
+func runtimeMountUnitPath(baseDir string) string {
+	escapedPath := EscapeUnitNamePath(baseDir)
+	return filepath.Join(dirs.SnapRuntimeServicesDir, escapedPath+".mount")
+}
+
+// validateMountUnitOptions checks that the given options can be written
+// verbatim into a mount unit without adding directives to it.
+func validateMountUnitOptions(unitOptions *MountUnitOptions) error {
+	for _, path := range []string{unitOptions.What, unitOptions.Where} {
+		if strings.IndexFunc(path, func(r rune) bool { return unicode.IsControl(r) || unicode.IsSpace(r) }) >= 0 {
+			return fmt.Errorf("cannot use %q in a mount unit: contains whitespace or control characters", path)
+		}
+	}
+	values := append([]string{unitOptions.SnapName, unitOptions.Revision, unitOptions.Fstype, unitOptions.Origin}, unitOptions.Options...)
+	for _, value := range values {
+		if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return fmt.Errorf("cannot use %q in a mount unit: contains control characters", value)
+		}
+	}
+	return nil
+}
+
+func writeMountUnitFileWithOptions(unitOptions *MountUnitOptions) (mountUnitName string, err error) {
+	if err := validateMountUnitOptions(unitOptions); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "[Unit]\n")
+	fmt.Fprintf(&buf, "Description=Mount unit for %s, revision %s\n", unitOptions.SnapName, unitOptions.Revision)
+	if unitOptions.Origin != "" {
+		fmt.Fprintf(&buf, "X-SnapdOrigin=%s\n", unitOptions.Origin)
+	}
+	fmt.Fprintf(&buf, "\n[Mount]\n")
+	fmt.Fprintf(&buf, "What=%s\n", unitOptions.What)
+	fmt.Fprintf(&buf, "Where=%s\n", unitOptions.Where)
+	if unitOptions.Fstype != "" {
+		fmt.Fprintf(&buf, "Type=%s\n", unitOptions.Fstype)
+	}
+	if len(unitOptions.Options) != 0 {
+		fmt.Fprintf(&buf, "Options=%s\n", strings.Join(unitOptions.Options, ","))
+	}
+	fmt.Fprintf(&buf, "LazyUnmount=yes\n")
+
+	mu := runtimeMountUnitPath(unitOptions.Where)
+	if unitOptions.Lifetime == Persistent {
+		fmt.Fprintf(&buf, "\n[Install]\nWantedBy=multi-user.target\n")
+		mu = MountUnitPath(unitOptions.Where)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(mu), 0755); err != nil {
+		return "", err
+	}
+	if err := osutil.AtomicWriteFile(mu, buf.Bytes(), 0644, 0); err != nil {
+		return "", err
+	}
+	return filepath.Base(mu), nil
+}
+
+func (s *systemd) AddMountUnitFileWithOptions(unitOptions *MountUnitOptions) (string, error) {
+	daemonReloadLock.Lock()
+	defer daemonReloadLock.Unlock()
+
+	mountUnitName, err := writeMountUnitFileWithOptions(unitOptions)
+	if err != nil {
+		return "", err
+	}
+
+	// we need to do a daemon-reload here to ensure that systemd really
+	// knows about this new mount unit file
+	if err := s.daemonReloadNoLock(); err != nil {
+		return "", err
+	}
+
+	if unitOptions.Lifetime == Persistent {
+		if err := s.Enable(mountUnitName); err != nil {
+			return "", err
+		}
+	}
+	if err := s.Start(mountUnitName); err != nil {
+		return "", err
+	}
+
+	return mountUnitName, nil
+}
+
+func (s *systemd) ListMountUnits(snapName, origin string) ([]string, error) {
+	var mountPoints []string
+	description := fmt.Sprintf("Description=Mount unit for %s, revision ", snapName)
+	for _, dir := range []string{dirs.SnapServicesDir, dirs.SnapRuntimeServicesDir} {
+		units, err := filepath.Glob(filepath.Join(dir, "*.mount"))
+		if err != nil {
+			return nil, err
+		}
+		for _, unit := range units {
+			content, err := ioutil.ReadFile(unit)
+			if err != nil {
+				return nil, err
+			}
+			var ownedBySnap, sameOrigin bool
+			var where string
+			for _, line := range strings.Split(string(content), "\n") {
+				switch {
+				case strings.HasPrefix(line, description):
+					ownedBySnap = true
+				case strings.HasPrefix(line, "X-SnapdOrigin="):
+					sameOrigin = strings.TrimPrefix(line, "X-SnapdOrigin=") == origin
+				case strings.HasPrefix(line, "Where="):
+					where = strings.TrimPrefix(line, "Where=")
+				}
+			}
+			if ownedBySnap && sameOrigin && where != "" {
+				mountPoints = append(mountPoints, where)
+			}
+		}
+	}
+	sort.Strings(mountPoints)
+	return mountPoints, nil
+}
+
 func (s *systemd) RemoveMountUnitFile(mountedDir string) error {
 	daemonReloadLock.Lock()
 	defer daemonReloadLock.Unlock()
 
+	persistent := true
 	unit := MountUnitPath(dirs.StripRootDir(mountedDir))
 	if !osutil.FileExists(unit) {
-		return nil
+		unit = runtimeMountUnitPath(dirs.StripRootDir(mountedDir))
+		if !osutil.FileExists(unit) {
+			return nil
+		}
+		persistent = false
 	}
 
 	// use umount -d (cleanup loopback devices) -l (lazy) to ensure that even busy mount points
@@ -1156,8 +1327,10 @@ func (s *systemd) RemoveMountUnitFile(mountedDir string) error {
 			return err
 		}
 	}
-	if err := s.Disable(filepath.Base(unit)); err != nil {
-		return err
+	if persistent {
+		if err := s.Disable(filepath.Base(unit)); err != nil {
+			return err
+		}
 	}
 	if err := os.Remove(unit); err != nil {
 		return err
@@ -60,12 +60,13 @@ type SystemdTestSuite struct {
 	errors []error
 	outs   [][]byte
 
-	j        int
-	jns      []string
-	jsvcs    [][]string
-	jouts    [][]byte
-	jerrs    []error
-	jfollows []bool
+	j           int
+	jns         []string
+	jsvcs       [][]string
+	jouts       [][]byte
+	jerrs       []error
+	jfollows    []bool
+	jnamespaces []bool
 
 	rep *testreporter
 
@@ -98,6 +99,7 @@ func (s *SystemdTestSuite) SetUpTest(c *C) {
 	s.jouts = nil
 	s.jerrs = nil
 	s.jfollows = nil
+	s.jnamespaces = nil
 
 	s.rep = new(testreporter)
 
@@ -122,13 +124,14 @@ func (s *SystemdTestSuite) myRun(args ...string) (out []byte, err error) {
 	return out, err
 }
 
-func (s *SystemdTestSuite) myJctl(svcs []string, n int, follow bool) (io.ReadCloser, error) {
+func (s *SystemdTestSuite) myJctl(svcs []string, n int, follow, namespaces bool) (io.ReadCloser, error) {
 	var err error
 	var out []byte
 
 	s.jns = append(s.jns, strconv.Itoa(n))
 	s.jsvcs = append(s.jsvcs, svcs)
 	s.jfollows = append(s.jfollows, follow)
+	s.jnamespaces = append(s.jnamespaces, namespaces)
 
 	if s.j < len(s.jouts) {
 		out = s.jouts[s.j]
@@ -268,6 +271,30 @@ UnitFileState=disabled
 	})
 }
 
+func (s *SystemdTestSuite) TestStatusFailed(c *C) {
+	s.outs = [][]byte{
+		[]byte(`
+Type=simple
+Id=foo.service
+ActiveState=failed
+UnitFileState=enabled
+`[1:]),
+	}
+	s.errors = []error{nil}
+	out, err := New(SystemMode, s.rep).Status("foo.service")
+	c.Assert(err, IsNil)
+	c.Check(out, DeepEquals, []*UnitStatus{
+		{
+			Daemon:    "simple",
+			UnitName:  "foo.service",
+			Active:    false,
+			Failed:    true,
+			Enabled:   true,
+			Installed: true,
+		},
+	})
+}
+
 func (s *SystemdTestSuite) TestStatusBadNumberOfValues(c *C) {
 	s.outs = [][]byte{
 		[]byte(`
@@ -522,12 +549,13 @@ func (s *SystemdTestSuite) TestIsTimeout(c *C) {
 func (s *SystemdTestSuite) TestLogErrJctl(c *C) {
 	s.jerrs = []error{&Timeout{}}
 
-	reader, err := New(SystemMode, s.rep).LogReader([]string{"foo"}, 24, false)
+	reader, err := New(SystemMode, s.rep).LogReader([]string{"foo"}, 24, false, false)
 	c.Check(err, NotNil)
 	c.Check(reader, IsNil)
 	c.Check(s.jns, DeepEquals, []string{"24"})
 	c.Check(s.jsvcs, DeepEquals, [][]string{{"foo"}})
 	c.Check(s.jfollows, DeepEquals, []bool{false})
+	c.Check(s.jnamespaces, DeepEquals, []bool{false})
 	c.Check(s.j, Equals, 1)
 }
 
@@ -537,7 +565,7 @@ func (s *SystemdTestSuite) TestLogs(c *C) {
 `
 	s.jouts = [][]byte{[]byte(expected)}
 
-	reader, err := New(SystemMode, s.rep).LogReader([]string{"foo"}, 24, false)
+	reader, err := New(SystemMode, s.rep).LogReader([]string{"foo"}, 24, false, false)
 	c.Check(err, IsNil)
 	logs, err := ioutil.ReadAll(reader)
 	c.Assert(err, IsNil)
@@ -545,6 +573,7 @@ func (s *SystemdTestSuite) TestLogs(c *C) {
 	c.Check(s.jns, DeepEquals, []string{"24"})
 	c.Check(s.jsvcs, DeepEquals, [][]string{{"foo"}})
 	c.Check(s.jfollows, DeepEquals, []bool{false})
+	c.Check(s.jnamespaces, DeepEquals, []bool{false})
 	c.Check(s.j, Equals, 1)
 }
 
@@ -939,20 +968,23 @@ func (s *SystemdTestSuite) TestJctl(c *C) {
 	var args []string
 	var err error
 	MockOsutilStreamCommand(func(name string, myargs ...string) (io.ReadCloser, error) {
-		c.Check(cap(myargs) <= len(myargs)+2, Equals, true, Commentf("cap:%d, len:%d", cap(myargs), len(myargs)))
+		c.Check(cap(myargs) <= len(myargs)+3, Equals, true, Commentf("cap:%d, len:%d", cap(myargs), len(myargs)))
 		args = myargs
 		return nil, nil
 	})
 
-	_, err = Jctl([]string{"foo", "bar"}, 10, false)
+	_, err = Jctl([]string{"foo", "bar"}, 10, false, false)
 	c.Assert(err, IsNil)
 	c.Check(args, DeepEquals, []string{"-o", "json", "--no-pager", "-n", "10", "-u", "foo", "-u", "bar"})
-	_, err = Jctl([]string{"foo", "bar", "baz"}, 99, true)
+	_, err = Jctl([]string{"foo", "bar", "baz"}, 99, true, false)
 	c.Assert(err, IsNil)
 	c.Check(args, DeepEquals, []string{"-o", "json", "--no-pager", "-n", "99", "-f", "-u", "foo", "-u", "bar", "-u", "baz"})
-	_, err = Jctl([]string{"foo", "bar"}, -1, false)
+	_, err = Jctl([]string{"foo", "bar"}, -1, false, false)
 	c.Assert(err, IsNil)
 	c.Check(args, DeepEquals, []string{"-o", "json", "--no-pager", "--no-tail", "-u", "foo", "-u", "bar"})
+	_, err = Jctl([]string{"foo"}, 10, true, true)
+	c.Assert(err, IsNil)
+	c.Check(args, DeepEquals, []string{"-o", "json", "--no-pager", "-n", "10", "-f", "--namespace=*", "-u", "foo"})
 }
 
 func (s *SystemdTestSuite) TestIsActiveUnderRoot(c *C) {
@@ -1058,6 +1090,154 @@ func (s *SystemdTestSuite) TestRemoveMountUnit(c *C) {
 	})
 }
 
+func (s *SystemdTestSuite) TestAddMountUnitWithOptionsPersistent(c *C) {
+	mountUnitName, err := New(SystemMode, nil).AddMountUnitFileWithOptions(&MountUnitOptions{
+		Lifetime: Persistent,
+		SnapName: "foo",
+		Revision: "42",
+		What:     "/dev/sdb1",
+		Where:    "/media/usb",
+		Fstype:   "ext4",
+		Options:  []string{"nodev", "nosuid", "rw"},
+		Origin:   "mount-control",
+	})
+	c.Assert(err, IsNil)
+	c.Check(mountUnitName, Equals, "media-usb.mount")
+
+	c.Check(filepath.Join(dirs.SnapServicesDir, mountUnitName), testutil.FileEquals, `
+[Unit]
+Description=Mount unit for foo, revision 42
+X-SnapdOrigin=mount-control
+
+[Mount]
+What=/dev/sdb1
+Where=/media/usb
+Type=ext4
+Options=nodev,nosuid,rw
+LazyUnmount=yes
+
+[Install]
+WantedBy=multi-user.target
+`[1:])
+
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"enable", "media-usb.mount"},
+		{"start", "media-usb.mount"},
+	})
+}
+
+func (s *SystemdTestSuite) TestAddMountUnitWithOptionsTransient(c *C) {
+	mountUnitName, err := New(SystemMode, nil).AddMountUnitFileWithOptions(&MountUnitOptions{
+		Lifetime: Transient,
+		SnapName: "foo",
+		Revision: "42",
+		What:     "server:/export",
+		Where:    "/var/snap/foo/common/nfs",
+		Origin:   "mount-control",
+	})
+	c.Assert(err, IsNil)
+	c.Check(mountUnitName, Equals, "var-snap-foo-common-nfs.mount")
+
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, mountUnitName), testutil.FileEquals, `
+[Unit]
+Description=Mount unit for foo, revision 42
+X-SnapdOrigin=mount-control
+
+[Mount]
+What=server:/export
+Where=/var/snap/foo/common/nfs
+LazyUnmount=yes
+`[1:])
+	c.Check(filepath.Join(dirs.SnapServicesDir, mountUnitName), testutil.FileAbsent)
+
+	// transient units are not enabled
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"start", "var-snap-foo-common-nfs.mount"},
+	})
+}
+
+func (s *SystemdTestSuite) TestAddMountUnitWithOptionsInjection(c *C) {
+	for _, t := range []struct {
+		opts *MountUnitOptions
+		err  string
+	}{
+		{&MountUnitOptions{SnapName: "foo", Revision: "42", What: "/dev/sdb1\nExecStartPre=/bin/sh", Where: "/media/usb"},
+			`cannot use "/dev/sdb1\\nExecStartPre=/bin/sh" in a mount unit: contains whitespace or control characters`},
+		{&MountUnitOptions{SnapName: "foo", Revision: "42", What: "/dev/sdb1", Where: "/media/usb\n[Install]"},
+			`cannot use "/media/usb\\n\[Install\]" in a mount unit: contains whitespace or control characters`},
+		{&MountUnitOptions{SnapName: "foo", Revision: "42", What: "/dev/sdb1", Where: "/media/my usb"},
+			`cannot use "/media/my usb" in a mount unit: contains whitespace or control characters`},
+		{&MountUnitOptions{SnapName: "foo", Revision: "42", What: "/dev/sdb1", Where: "/media/usb", Fstype: "ext4\rUser=root"},
+			`cannot use "ext4\\rUser=root" in a mount unit: contains control characters`},
+		{&MountUnitOptions{SnapName: "foo", Revision: "42", What: "/dev/sdb1", Where: "/media/usb", Options: []string{"ro\nUser=root"}},
+			`cannot use "ro\\nUser=root" in a mount unit: contains control characters`},
+	} {
+		_, err := New(SystemMode, nil).AddMountUnitFileWithOptions(t.opts)
+		c.Check(err, ErrorMatches, t.err)
+	}
+	c.Check(filepath.Join(dirs.SnapServicesDir, "media-usb.mount"), testutil.FileAbsent)
+	c.Check(s.argses, HasLen, 0)
+}
+
+func (s *SystemdTestSuite) TestListMountUnits(c *C) {
+	sysd := New(SystemMode, nil)
+	for _, opts := range []*MountUnitOptions{
+		{Lifetime: Persistent, SnapName: "foo", Revision: "1", What: "/dev/sda", Where: "/media/a", Origin: "mount-control"},
+		{Lifetime: Transient, SnapName: "foo", Revision: "1", What: "/dev/sdb", Where: "/media/b", Origin: "mount-control"},
+		// different snap
+		{Lifetime: Persistent, SnapName: "bar", Revision: "1", What: "/dev/sdc", Where: "/media/c", Origin: "mount-control"},
+		// different origin
+		{Lifetime: Persistent, SnapName: "foo", Revision: "1", What: "/dev/sdd", Where: "/media/d", Origin: "other"},
+		// snap name prefix of another snap
+		{Lifetime: Persistent, SnapName: "foo-bar", Revision: "1", What: "/dev/sde", Where: "/media/e", Origin: "mount-control"},
+	} {
+		_, err := sysd.AddMountUnitFileWithOptions(opts)
+		c.Assert(err, IsNil)
+	}
+	// mount units of snaps themselves have no origin
+	mockSnapPath := filepath.Join(c.MkDir(), "/var/lib/snappy/snaps/foo_1.snap")
+	makeMockFile(c, mockSnapPath)
+	_, err := sysd.AddMountUnitFile("foo", "1", mockSnapPath, "/snap/foo/1", "squashfs")
+	c.Assert(err, IsNil)
+
+	mountPoints, err := sysd.ListMountUnits("foo", "mount-control")
+	c.Assert(err, IsNil)
+	c.Check(mountPoints, DeepEquals, []string{"/media/a", "/media/b"})
+
+	mountPoints, err = sysd.ListMountUnits("foo", "other")
+	c.Assert(err, IsNil)
+	c.Check(mountPoints, DeepEquals, []string{"/media/d"})
+
+	mountPoints, err = sysd.ListMountUnits("baz", "mount-control")
+	c.Assert(err, IsNil)
+	c.Check(mountPoints, HasLen, 0)
+}
+
+func (s *SystemdTestSuite) TestRemoveTransientMountUnit(c *C) {
+	restore := osutil.MockMountInfo("")
+	defer restore()
+
+	sysd := New(SystemMode, nil)
+	mountUnitName, err := sysd.AddMountUnitFileWithOptions(&MountUnitOptions{
+		Lifetime: Transient,
+		SnapName: "foo",
+		Revision: "42",
+		What:     "/dev/sdb1",
+		Where:    "/media/usb",
+	})
+	c.Assert(err, IsNil)
+	s.argses = nil
+
+	c.Assert(sysd.RemoveMountUnitFile(filepath.Join(dirs.GlobalRootDir, "/media/usb")), IsNil)
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, mountUnitName), testutil.FileAbsent)
+	// transient units are not disabled
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+}
+
 func (s *SystemdTestSuite) TestDaemonReloadMutex(c *C) {
 	s.testDaemonReloadMutex(c, Systemd.DaemonReload)
 }
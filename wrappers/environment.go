@@ -0,0 +1,84 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+func appEnvGlob(instanceName string) string {
+	return fmt.Sprintf("snap.%s.*.env", instanceName)
+}
+
+func genAppEnvFile(env map[string]string) []byte {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("# Auto-generated by snapd, DO NOT EDIT, use snap set-env instead\n")
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s=%s\n", key, env[key])
+	}
+	return buf.Bytes()
+}
+
+// EnsureSnapAppEnvironment ensures that the environment override files of the
+// apps of the given snap match the given overrides, keyed by app name. The
+// files are read by snap run, which is also what service units execute, so
+// the overrides apply to commands and services alike the next time they are
+// started.
+func EnsureSnapAppEnvironment(s *snap.Info, overrides map[string]map[string]string) error {
+	content := make(map[string]osutil.FileState, len(overrides))
+	for appName, env := range overrides {
+		app := s.Apps[appName]
+		if app == nil || len(env) == 0 {
+			continue
+		}
+		content[filepath.Base(app.EnvOverridesFile())] = &osutil.MemoryFileState{
+			Content: genAppEnvFile(env),
+			Mode:    0644,
+		}
+	}
+	if len(content) != 0 {
+		if err := os.MkdirAll(dirs.SnapAppEnvDir, 0755); err != nil {
+			return err
+		}
+	}
+	_, _, err := osutil.EnsureDirState(dirs.SnapAppEnvDir, appEnvGlob(s.InstanceName()), content)
+	return err
+}
+
+// RemoveSnapAppEnvironment removes the environment override files of all the
+// apps of the given snap.
+func RemoveSnapAppEnvironment(instanceName string) error {
+	_, _, err := osutil.EnsureDirState(dirs.SnapAppEnvDir, appEnvGlob(instanceName), nil)
+	return err
+}
@@ -0,0 +1,99 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers_test
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+type environmentTestSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&environmentTestSuite{})
+
+func (s *environmentTestSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.BaseTest.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *environmentTestSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+	s.BaseTest.TearDownTest(c)
+}
+
+func (s *environmentTestSuite) TestEnsureSnapAppEnvironment(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(11)})
+	svcFile := filepath.Join(dirs.SnapAppEnvDir, "snap.hello-snap.svc1.env")
+	helloFile := filepath.Join(dirs.SnapAppEnvDir, "snap.hello-snap.hello.env")
+	otherFile := filepath.Join(dirs.SnapAppEnvDir, "snap.other.app.env")
+
+	err := wrappers.EnsureSnapAppEnvironment(info, map[string]map[string]string{
+		"svc1":  {"LOG_LEVEL": "debug", "HTTP_PROXY": "http://proxy:3128"},
+		"hello": {"OPTS": "a=b"},
+		// unknown apps are ignored
+		"unknown": {"FOO": "bar"},
+	})
+	c.Assert(err, IsNil)
+	c.Check(svcFile, testutil.FileEquals, `# Auto-generated by snapd, DO NOT EDIT, use snap set-env instead
+HTTP_PROXY=http://proxy:3128
+LOG_LEVEL=debug
+`)
+	c.Check(helloFile, testutil.FileEquals, `# Auto-generated by snapd, DO NOT EDIT, use snap set-env instead
+OPTS=a=b
+`)
+	c.Check(filepath.Join(dirs.SnapAppEnvDir, "snap.hello-snap.unknown.env"), testutil.FileAbsent)
+
+	// files of other snaps are left alone
+	other := snaptest.MockSnap(c, "name: other\nversion: 1\napps:\n app:\n  command: bin/app\n", &snap.SideInfo{Revision: snap.R(1)})
+	err = wrappers.EnsureSnapAppEnvironment(other, map[string]map[string]string{
+		"app": {"FOO": "bar"},
+	})
+	c.Assert(err, IsNil)
+
+	// overrides which are gone are removed
+	err = wrappers.EnsureSnapAppEnvironment(info, map[string]map[string]string{
+		"svc1":  {"LOG_LEVEL": "info"},
+		"hello": {},
+	})
+	c.Assert(err, IsNil)
+	c.Check(svcFile, testutil.FileEquals, `# Auto-generated by snapd, DO NOT EDIT, use snap set-env instead
+LOG_LEVEL=info
+`)
+	c.Check(helloFile, testutil.FileAbsent)
+	c.Check(otherFile, testutil.FilePresent)
+
+	c.Assert(wrappers.RemoveSnapAppEnvironment("hello-snap"), IsNil)
+	c.Check(svcFile, testutil.FileAbsent)
+	c.Check(otherFile, testutil.FilePresent)
+}
+
+func (s *environmentTestSuite) TestRemoveSnapAppEnvironmentNoDir(c *C) {
+	c.Check(wrappers.RemoveSnapAppEnvironment("hello-snap"), IsNil)
+}
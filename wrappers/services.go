@@ -102,6 +102,29 @@ TasksAccounting=true
 	return buf.Bytes(), nil
 }
 
+// generateGroupJournalFile generates a systemd-journald namespace
+// configuration for the specified quota group.
+func generateGroupJournalFile(grp *quota.Group) ([]byte, error) {
+	if grp.JournalLimit == nil {
+		return nil, fmt.Errorf("internal error: quota group %q has no journal limit", grp.Name)
+	}
+
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, `# Journal namespace for snap quota group %s
+# Generated by snapd, do not edit.
+[Journal]
+Storage=auto
+`, grp.Name)
+	if grp.JournalLimit.Size != 0 {
+		fmt.Fprintf(&buf, "SystemMaxUse=%[1]d\nRuntimeMaxUse=%[1]d\n", grp.JournalLimit.Size)
+	}
+	if grp.JournalLimit.RateCount != 0 {
+		fmt.Fprintf(&buf, "RateLimitIntervalSec=%dus\nRateLimitBurst=%d\n", grp.JournalLimit.RatePeriod.Microseconds(), grp.JournalLimit.RateCount)
+	}
+
+	return buf.Bytes(), nil
+}
+
 func stopUserServices(cli *client.Client, inter interacter, services ...string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout.DefaultTimeout))
 	defer cancel()
@@ -678,6 +701,52 @@ func EnsureSnapServices(snaps map[*snap.Info]*SnapServiceOptions, opts *EnsureSn
 		return nil
 	}
 
+	// handleJournalModification writes or removes the journal namespace
+	// configuration of a quota group, journald reads it when the namespace
+	// instance is started so no daemon-reload is needed for it
+	handleJournalModification := func(grp *quota.Group, path string, content []byte) error {
+		var old *osutil.MemoryFileState
+		var modifiedFile bool
+		if content != nil {
+			var err error
+			old, modifiedFile, err = tryFileUpdate(path, content)
+			if err != nil {
+				return err
+			}
+		} else {
+			st, err := os.Stat(path)
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			old = &osutil.MemoryFileState{Content: b, Mode: st.Mode()}
+			modifiedFile = true
+		}
+
+		if modifiedFile {
+			if observeChange != nil {
+				var oldContent []byte
+				if old != nil {
+					oldContent = old.Content
+				}
+				observeChange(nil, grp, "journald", grp.Name, string(oldContent), string(content))
+			}
+
+			modifiedUnitsPreviousState[path] = old
+		}
+
+		return nil
+	}
+
 	// now make sure that all of the slice units exist
 	for _, grp := range neededQuotaGrps.AllQuotaGroups() {
 		content, err := generateGroupSliceFile(grp)
@@ -690,6 +759,19 @@ func EnsureSnapServices(snaps map[*snap.Info]*SnapServiceOptions, opts *EnsureSn
 		if err := handleSliceModification(grp, path, content); err != nil {
 			return err
 		}
+
+		// and that the journal namespace configuration matches the group
+		var journalContent []byte
+		if grp.JournalLimit != nil {
+			journalContent, err = generateGroupJournalFile(grp)
+			if err != nil {
+				return err
+			}
+		}
+		journalPath := filepath.Join(dirs.SnapSystemdDir, grp.JournalConfFileName())
+		if err := handleJournalModification(grp, journalPath, journalContent); err != nil {
+			return err
+		}
 	}
 
 	if !preseeding {
@@ -751,6 +833,93 @@ func AddSnapServices(s *snap.Info, opts *AddSnapServicesOptions, inter interacte
 	return EnsureSnapServices(m, ensureOpts, nil, inter)
 }
 
+// ServiceOrdering describes how the services of a snap are ordered relative
+// to units outside of the snap, typically services of connected snaps.
+type ServiceOrdering struct {
+	// After is the list of units the services start after.
+	After []string
+	// Before is the list of units the services start before.
+	Before []string
+}
+
+func serviceOrderingDropInFile(app *snap.AppInfo) string {
+	return filepath.Join(app.ServiceFile()+".d", "snapd-connections.conf")
+}
+
+func genServiceOrderingDropIn(ordering *ServiceOrdering) []byte {
+	if ordering == nil {
+		return nil
+	}
+	after := sortedUnique(ordering.After)
+	before := sortedUnique(ordering.Before)
+	if len(after) == 0 && len(before) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("[Unit]\n")
+	buf.WriteString("# Auto-generated by snapd from interface connections, DO NOT EDIT\n")
+	// only order against the services of other snaps, pulling them in would
+	// start services their snap has disabled
+	if len(after) != 0 {
+		fmt.Fprintf(&buf, "After=%s\n", strings.Join(after, " "))
+	}
+	if len(before) != 0 {
+		fmt.Fprintf(&buf, "Before=%s\n", strings.Join(before, " "))
+	}
+	return buf.Bytes()
+}
+
+func sortedUnique(l []string) []string {
+	sorted := append([]string(nil), l...)
+	sort.Strings(sorted)
+	return strutil.SortedListsUniqueMerge(sorted, nil)
+}
+
+// EnsureSnapServiceOrdering ensures that the system services of the given
+// snap carry a drop-in with the given ordering against units outside of the
+// snap. The drop-in is removed if the ordering is empty. Only the ordering at
+// the next start of the services is affected, running services are left
+// alone.
+// This function is idempotent.
+func EnsureSnapServiceOrdering(s *snap.Info, ordering *ServiceOrdering, opts *EnsureSnapServicesOptions, inter interacter) error {
+	if opts == nil {
+		opts = &EnsureSnapServicesOptions{}
+	}
+
+	content := genServiceOrderingDropIn(ordering)
+	modified := false
+	for _, app := range s.Services() {
+		if app.DaemonScope != snap.SystemDaemon {
+			continue
+		}
+		path := serviceOrderingDropInFile(app)
+		if content == nil {
+			err := os.Remove(path)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err == nil {
+				modified = true
+				// the drop-in directory is only removed when empty
+				os.Remove(filepath.Dir(path))
+			}
+			continue
+		}
+		_, changed, err := tryFileUpdate(path, content)
+		if err != nil {
+			return err
+		}
+		modified = modified || changed
+	}
+
+	if modified && !opts.Preseeding {
+		sysd := systemd.New(systemd.SystemMode, inter)
+		return sysd.DaemonReload()
+	}
+	return nil
+}
+
 // StopServicesFlags carries extra flags for StopServices.
 type StopServicesFlags struct {
 	Disable bool
@@ -836,7 +1005,9 @@ func ServicesEnableState(s *snap.Info, inter interacter) (map[string]bool, error
 	return snapSvcsState, nil
 }
 
-// RemoveQuotaGroup ensures that the slice file for a quota group is removed. It
+// RemoveQuotaGroup ensures that the slice file and the journal namespace
+// configuration for a quota group are removed, and that the journald
+// instance of the namespace is stopped. It
 // assumes that the slice corresponding to the group is not in use anymore by
 // any services or sub-groups of the group when it is invoked. To remove a group
 // with sub-groups, one must remove all the sub-groups first.
@@ -850,6 +1021,18 @@ func RemoveQuotaGroup(grp *quota.Group, inter interacter) error {
 
 	systemSysd := systemd.New(systemd.SystemMode, inter)
 
+	// remove the journal namespace configuration, if any, along with the
+	// journald instance serving the namespace
+	journalErr := os.Remove(filepath.Join(dirs.SnapSystemdDir, grp.JournalConfFileName()))
+	if journalErr != nil && !os.IsNotExist(journalErr) {
+		return journalErr
+	}
+	if journalErr == nil {
+		if err := systemSysd.Stop(grp.JournalServiceName(), time.Duration(timeout.DefaultTimeout)); err != nil {
+			return err
+		}
+	}
+
 	// remove the slice file
 	err := os.Remove(filepath.Join(dirs.SnapServicesDir, grp.SliceFileName()))
 	if err != nil && !os.IsNotExist(err) {
@@ -925,6 +1108,13 @@ func RemoveSnapServices(s *snap.Info, inter interacter) error {
 			logger.Noticef("Failed to remove service file for %q: %v", serviceName, err)
 		}
 
+		dropIn := serviceOrderingDropInFile(app)
+		if err := os.Remove(dropIn); err != nil && !os.IsNotExist(err) {
+			logger.Noticef("Failed to remove service ordering drop-in for %q: %v", serviceName, err)
+		}
+		// the drop-in directory is only removed when empty
+		os.Remove(filepath.Dir(dropIn))
+
 	}
 
 	// only reload if we actually had services
@@ -1012,6 +1202,10 @@ Before={{ stringsJoin .Before " "}}
 Wants={{ stringsJoin .CoreMountedSnapdSnapDep " "}}
 After={{ stringsJoin .CoreMountedSnapdSnapDep " "}}
 {{- end}}
+{{- if .App.RestartLimit}}
+StartLimitIntervalSec={{.StartLimitIntervalSec}}
+StartLimitBurst={{.App.RestartLimit.Burst}}
+{{- end}}
 X-Snappy=yes
 
 [Service]
@@ -1057,12 +1251,19 @@ KillSignal={{.KillSignal}}
 {{- if .OOMAdjustScore }}
 OOMScoreAdjust={{.OOMAdjustScore}}
 {{- end}}
+{{- if .App.RunAs}}
+User={{.App.RunAs}}
+Group={{.App.RunAs}}
+{{- end}}
 {{- if .InterfaceServiceSnippets}}
 {{.InterfaceServiceSnippets}}
 {{- end}}
 {{- if .SliceUnit}}
 Slice={{.SliceUnit}}
 {{- end}}
+{{- if .LogNamespace}}
+LogNamespace={{.LogNamespace}}
+{{- end}}
 {{- if not (or .App.Sockets .App.Timer .App.ActivatesOn) }}
 
 [Install]
@@ -1135,6 +1336,8 @@ WantedBy={{.ServicesTarget}}
 		After                    []string
 		InterfaceServiceSnippets string
 		SliceUnit                string
+		LogNamespace             string
+		StartLimitIntervalSec    int64
 
 		Home    string
 		EnvVars string
@@ -1160,6 +1363,13 @@ WantedBy={{.ServicesTarget}}
 		// systemd runs as PID 1 so %h will not work.
 		Home: "/root",
 	}
+	if appInfo.RestartLimit != nil {
+		// systemd wants whole seconds here, round up so that a
+		// sub-second interval does not turn into 0, which would
+		// disable the rate limiting altogether
+		interval := time.Duration(appInfo.RestartLimit.Interval)
+		wrapperData.StartLimitIntervalSec = int64((interval + time.Second - 1) / time.Second)
+	}
 	switch appInfo.DaemonScope {
 	case snap.SystemDaemon:
 		wrapperData.ServicesTarget = systemd.ServicesTarget
@@ -1179,6 +1389,11 @@ WantedBy={{.ServicesTarget}}
 	// check the quota group slice
 	if opts.QuotaGroup != nil {
 		wrapperData.SliceUnit = opts.QuotaGroup.SliceFileName()
+		// and log into the journal namespace of the group, or of the
+		// parent group it inherits it from, if there is one
+		if journalGrp := opts.QuotaGroup.JournalQuotaGroup(); journalGrp != nil {
+			wrapperData.LogNamespace = journalGrp.JournalNamespaceName()
+		}
 	}
 
 	// Add extra "After" targets
@@ -978,6 +978,118 @@ WantedBy=multi-user.target
 `, mountUnitPrefix, mountUnitPrefix))
 }
 
+func (s *servicesWrapperGenSuite) TestRestartLimit(c *C) {
+	service := &snap.AppInfo{
+		Snap: &snap.Info{
+			SuggestedName: "snap",
+			Version:       "0.3.4",
+			SideInfo:      snap.SideInfo{Revision: snap.R(44)},
+		},
+		Name:        "app",
+		Command:     "bin/foo start",
+		Daemon:      "simple",
+		DaemonScope: snap.SystemDaemon,
+		RestartLimit: &snap.RestartLimit{
+			Burst:    5,
+			Interval: timeout.Timeout(2 * time.Minute),
+		},
+	}
+
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(service, nil)
+	c.Assert(err, IsNil)
+
+	c.Check(string(generatedWrapper), Equals, fmt.Sprintf(`[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Service for snap application snap.app
+Requires=%s-snap-44.mount
+Wants=network.target
+After=%s-snap-44.mount network.target snapd.apparmor.service
+StartLimitIntervalSec=120
+StartLimitBurst=5
+X-Snappy=yes
+
+[Service]
+EnvironmentFile=-/etc/environment
+ExecStart=/usr/bin/snap run snap.app
+SyslogIdentifier=snap.app
+Restart=on-failure
+WorkingDirectory=/var/snap/snap/44
+TimeoutStopSec=30
+Type=simple
+
+[Install]
+WantedBy=multi-user.target
+`, mountUnitPrefix, mountUnitPrefix))
+}
+
+func (s *servicesWrapperGenSuite) TestRestartLimitFractionalInterval(c *C) {
+	service := &snap.AppInfo{
+		Snap: &snap.Info{
+			SuggestedName: "snap",
+			Version:       "0.3.4",
+			SideInfo:      snap.SideInfo{Revision: snap.R(44)},
+		},
+		Name:        "app",
+		Command:     "bin/foo start",
+		Daemon:      "simple",
+		DaemonScope: snap.SystemDaemon,
+		RestartLimit: &snap.RestartLimit{
+			Burst:    5,
+			Interval: timeout.Timeout(1500 * time.Millisecond),
+		},
+	}
+
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(service, nil)
+	c.Assert(err, IsNil)
+
+	// systemd only accepts whole seconds
+	c.Check(string(generatedWrapper), testutil.Contains, "\nStartLimitIntervalSec=2\nStartLimitBurst=5\n")
+}
+
+func (s *servicesWrapperGenSuite) TestRunAs(c *C) {
+	service := &snap.AppInfo{
+		Snap: &snap.Info{
+			SuggestedName: "snap",
+			Version:       "0.3.4",
+			SideInfo:      snap.SideInfo{Revision: snap.R(44)},
+			SystemUsernames: map[string]*snap.SystemUsernameInfo{
+				"snap_daemon": {Name: "snap_daemon", Scope: "shared"},
+			},
+		},
+		Name:        "app",
+		Command:     "bin/foo start",
+		Daemon:      "simple",
+		DaemonScope: snap.SystemDaemon,
+		RunAs:       "snap_daemon",
+	}
+
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(service, nil)
+	c.Assert(err, IsNil)
+
+	c.Check(string(generatedWrapper), Equals, fmt.Sprintf(`[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Service for snap application snap.app
+Requires=%s-snap-44.mount
+Wants=network.target
+After=%s-snap-44.mount network.target snapd.apparmor.service
+X-Snappy=yes
+
+[Service]
+EnvironmentFile=-/etc/environment
+ExecStart=/usr/bin/snap run snap.app
+SyslogIdentifier=snap.app
+Restart=on-failure
+WorkingDirectory=/var/snap/snap/44
+TimeoutStopSec=30
+Type=simple
+User=snap_daemon
+Group=snap_daemon
+
+[Install]
+WantedBy=multi-user.target
+`, mountUnitPrefix, mountUnitPrefix))
+}
+
 func (s *servicesWrapperGenSuite) TestVitalityScore(c *C) {
 	service := &snap.AppInfo{
 		Snap: &snap.Info{
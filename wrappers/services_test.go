@@ -163,6 +163,107 @@ WantedBy=multi-user.target
 		{"daemon-reload"},
 	})
 }
+
+func (s *servicesTestSuite) TestEnsureSnapServiceOrdering(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+	dropIn := svcFile + ".d/snapd-connections.conf"
+
+	ordering := &wrappers.ServiceOrdering{
+		After:  []string{"snap.db.postgres.service", "snap.db.cache.service", "snap.db.postgres.service"},
+		Before: []string{"snap.web.frontend.service"},
+	}
+	err := wrappers.EnsureSnapServiceOrdering(info, ordering, nil, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(dropIn, testutil.FileEquals, `[Unit]
+# Auto-generated by snapd from interface connections, DO NOT EDIT
+After=snap.db.cache.service snap.db.postgres.service
+Before=snap.web.frontend.service
+`)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+
+	// ensuring the same ordering again is a no-op
+	s.sysdLog = nil
+	err = wrappers.EnsureSnapServiceOrdering(info, ordering, nil, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, HasLen, 0)
+
+	// no reload when preseeding
+	ordering.Before = nil
+	err = wrappers.EnsureSnapServiceOrdering(info, ordering, &wrappers.EnsureSnapServicesOptions{Preseeding: true}, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(dropIn, testutil.FileEquals, `[Unit]
+# Auto-generated by snapd from interface connections, DO NOT EDIT
+After=snap.db.cache.service snap.db.postgres.service
+`)
+	c.Check(s.sysdLog, HasLen, 0)
+
+	// an empty ordering removes the drop-in
+	err = wrappers.EnsureSnapServiceOrdering(info, &wrappers.ServiceOrdering{}, nil, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(dropIn, testutil.FileAbsent)
+	c.Check(filepath.Dir(dropIn), testutil.FileAbsent)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+}
+
+func (s *servicesTestSuite) TestServiceOrderingKeepsDisabledPeerStopped(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+	dropIn := svcFile + ".d/snapd-connections.conf"
+
+	// the service of the connected snap is disabled
+	peerSvc := "snap.db.postgres.service"
+	ordering := &wrappers.ServiceOrdering{After: []string{peerSvc}}
+	err := wrappers.EnsureSnapServiceOrdering(info, ordering, nil, progress.Null)
+	c.Assert(err, IsNil)
+
+	// the drop-in only orders against the peer, it does not pull it in
+	c.Check(dropIn, testutil.FileEquals, `[Unit]
+# Auto-generated by snapd from interface connections, DO NOT EDIT
+After=snap.db.postgres.service
+`)
+	c.Check(dropIn, Not(testutil.FileContains), "Wants=")
+	c.Check(dropIn, Not(testutil.FileContains), "Requires=")
+
+	// and starting the services of the snap leaves the peer stopped
+	s.sysdLog = nil
+	flags := &wrappers.StartServicesFlags{Enable: true}
+	err = wrappers.StartServices(info.Services(), nil, flags, progress.Null, s.perfTimings)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"enable", filepath.Base(svcFile)},
+		{"start", filepath.Base(svcFile)},
+	})
+}
+
+func (s *servicesTestSuite) TestRemoveSnapServicesRemovesOrdering(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+	dropIn := svcFile + ".d/snapd-connections.conf"
+
+	err := wrappers.AddSnapServices(info, nil, progress.Null)
+	c.Assert(err, IsNil)
+	ordering := &wrappers.ServiceOrdering{After: []string{"snap.db.postgres.service"}}
+	err = wrappers.EnsureSnapServiceOrdering(info, ordering, nil, progress.Null)
+	c.Assert(err, IsNil)
+	c.Assert(dropIn, testutil.FilePresent)
+
+	s.sysdLog = nil
+	err = wrappers.RemoveSnapServices(info, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(svcFile, testutil.FileAbsent)
+	c.Check(dropIn, testutil.FileAbsent)
+	c.Check(filepath.Dir(dropIn), testutil.FileAbsent)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"disable", filepath.Base(svcFile)},
+		{"daemon-reload"},
+	})
+}
+
 func (s *servicesTestSuite) TestEnsureSnapServicesAdds(c *C) {
 	// map unit -> new
 	seen := make(map[string]bool)
@@ -334,7 +435,7 @@ func expChangeObserver(c *C, exp []changesObservation) (restore func(), obs wrap
 		svcObservations := make([]changesObservation, 0, len(changesObserved))
 
 		for _, chg := range changesObserved {
-			if chg.unitType == "slice" {
+			if chg.unitType == "slice" || chg.unitType == "journald" {
 				groupObservations = append(groupObservations, chg)
 			} else {
 				svcObservations = append(svcObservations, chg)
@@ -355,6 +456,86 @@ func expChangeObserver(c *C, exp []changesObservation) (restore func(), obs wrap
 	return r, f
 }
 
+func (s *servicesTestSuite) TestEnsureSnapServicesWithJournalQuota(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+	journalFile := filepath.Join(s.tempdir, "/etc/systemd/journald@snap-foogroup.conf")
+
+	grp, err := quota.NewGroup("foogroup", quantity.SizeGiB)
+	c.Assert(err, IsNil)
+	grp.JournalLimit = &quota.JournalQuota{
+		Size:       64 * quantity.SizeMiB,
+		RateCount:  100,
+		RatePeriod: 10 * time.Second,
+	}
+
+	m := map[*snap.Info]*wrappers.SnapServiceOptions{
+		info: {QuotaGroup: grp},
+	}
+
+	err = wrappers.EnsureSnapServices(m, nil, nil, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+
+	c.Assert(svcFile, testutil.FileContains, "\nSlice=snap.foogroup.slice\nLogNamespace=snap-foogroup\n")
+	journalContent := `# Journal namespace for snap quota group foogroup
+# Generated by snapd, do not edit.
+[Journal]
+Storage=auto
+SystemMaxUse=67108864
+RuntimeMaxUse=67108864
+RateLimitIntervalSec=10000000us
+RateLimitBurst=100
+`
+	c.Assert(journalFile, testutil.FileEquals, journalContent)
+
+	// dropping the journal limit removes the namespace configuration and
+	// the service no longer logs into it
+	grp.JournalLimit = nil
+	var observed []string
+	observe := func(app *snap.AppInfo, obsGrp *quota.Group, unitType, name, old, new string) {
+		observed = append(observed, unitType)
+		if unitType == "journald" {
+			c.Check(obsGrp, Equals, grp)
+			c.Check(old, Equals, journalContent)
+			c.Check(new, Equals, "")
+		}
+	}
+	err = wrappers.EnsureSnapServices(m, nil, observe, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(observed, DeepEquals, []string{"service", "journald"})
+	c.Assert(svcFile, Not(testutil.FileContains), "LogNamespace=")
+	c.Assert(journalFile, testutil.FileAbsent)
+}
+
+func (s *servicesTestSuite) TestEnsureSnapServicesJournalQuotaRateOnly(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	journalFile := filepath.Join(s.tempdir, "/etc/systemd/journald@snap-foogroup.conf")
+
+	grp, err := quota.NewGroup("foogroup", quantity.SizeGiB)
+	c.Assert(err, IsNil)
+	grp.JournalLimit = &quota.JournalQuota{
+		RateCount:  5,
+		RatePeriod: time.Minute,
+	}
+
+	m := map[*snap.Info]*wrappers.SnapServiceOptions{
+		info: {QuotaGroup: grp},
+	}
+
+	err = wrappers.EnsureSnapServices(m, nil, nil, progress.Null)
+	c.Assert(err, IsNil)
+	c.Assert(journalFile, testutil.FileEquals, `# Journal namespace for snap quota group foogroup
+# Generated by snapd, do not edit.
+[Journal]
+Storage=auto
+RateLimitIntervalSec=60000000us
+RateLimitBurst=5
+`)
+}
+
 func (s *servicesTestSuite) TestEnsureSnapServicesRewritesQuotaSlices(c *C) {
 	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
 	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
@@ -572,15 +753,35 @@ MemoryLimit=1024
 	err = ioutil.WriteFile(sliceFile, []byte(fmt.Sprintf(sliceTempl, "foogroup")), 0644)
 	c.Assert(err, IsNil)
 
-	// removing it deletes it
+	// along with the journal namespace configuration
+	journalFile := filepath.Join(s.tempdir, "/etc/systemd/journald@snap-foogroup.conf")
+	err = ioutil.WriteFile(journalFile, []byte("[Journal]\n"), 0644)
+	c.Assert(err, IsNil)
+
+	// removing it deletes it and stops the journal namespace instance
 	err = wrappers.RemoveQuotaGroup(grp, progress.Null)
 	c.Assert(err, IsNil)
 
 	c.Assert(s.sysdLog, DeepEquals, [][]string{
+		{"stop", "systemd-journald@snap-foogroup.service"},
+		{"show", "--property=ActiveState", "systemd-journald@snap-foogroup.service"},
 		{"daemon-reload"},
 	})
 
 	c.Assert(sliceFile, testutil.FileAbsent)
+	c.Assert(journalFile, testutil.FileAbsent)
+
+	// without a journal namespace configuration there is nothing to stop
+	err = ioutil.WriteFile(sliceFile, []byte(fmt.Sprintf(sliceTempl, "foogroup")), 0644)
+	c.Assert(err, IsNil)
+	s.sysdLog = nil
+
+	err = wrappers.RemoveQuotaGroup(grp, progress.Null)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
 }
 
 func (s *servicesTestSuite) TestEnsureSnapServicesWithSubGroupQuotaGroupsForSnaps(c *C) {